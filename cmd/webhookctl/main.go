@@ -0,0 +1,122 @@
+// webhookctl is a command line tool for administrators of the Rancher webhook.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+
+	"github.com/rancher/webhook/pkg/celpolicy"
+	"github.com/sirupsen/logrus"
+)
+
+const usage = `Usage: webhookctl <command> [flags]
+
+Commands:
+  test-policies  Evaluate external policies against example requests with expected outcomes.
+`
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Stdout, os.Stderr))
+}
+
+func run(args []string, stdout, stderr io.Writer) int {
+	if len(args) == 0 {
+		fmt.Fprint(stderr, usage)
+		return 2
+	}
+	switch args[0] {
+	case "test-policies":
+		return testPolicies(args[1:], stdout, stderr)
+	case "-h", "--help", "help":
+		fmt.Fprint(stdout, usage)
+		return 0
+	default:
+		fmt.Fprintf(stderr, "unknown command %q\n\n%s", args[0], usage)
+		return 2
+	}
+}
+
+// testPolicies loads the policies of a policy ConfigMap and evaluates them against the test cases of the given test
+// suite files. It returns a non-zero exit code if a policy is invalid or a test case fails.
+func testPolicies(args []string, stdout, stderr io.Writer) int {
+	flags := flag.NewFlagSet("test-policies", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	flags.Usage = func() {
+		fmt.Fprintln(stderr, "Usage: webhookctl test-policies --policies <configmap.yaml> <tests.yaml>...")
+		flags.PrintDefaults()
+	}
+	policiesFile := flags.String("policies", "", "path to the YAML manifest of the policy ConfigMap")
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	if *policiesFile == "" || flags.NArg() == 0 {
+		flags.Usage()
+		return 2
+	}
+
+	// the engine logs the policies it loads and the evaluations it ignores, which would clutter the test results
+	logrus.SetLevel(logrus.FatalLevel)
+
+	data, err := os.ReadFile(*policiesFile)
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return 1
+	}
+	policies, err := celpolicy.LoadPolicyConfigMap(data)
+	if err != nil {
+		fmt.Fprintf(stderr, "%s: %v\n", *policiesFile, err)
+		return 1
+	}
+	engine, err := celpolicy.NewEngine()
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return 1
+	}
+	engine.Load(policies)
+
+	failed := false
+	if errs := engine.Errors(); len(errs) > 0 {
+		failed = true
+		names := make([]string, 0, len(errs))
+		for name := range errs {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			fmt.Fprintf(stdout, "INVALID %s: %v\n", name, errs[name])
+		}
+	}
+
+	var passed, total int
+	for _, file := range flags.Args() {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			fmt.Fprintln(stderr, err)
+			return 1
+		}
+		suite, err := celpolicy.LoadTestSuite(data)
+		if err != nil {
+			fmt.Fprintf(stderr, "%s: %v\n", file, err)
+			return 1
+		}
+		for _, result := range engine.RunTests(suite) {
+			total++
+			if result.Err != nil {
+				failed = true
+				fmt.Fprintf(stdout, "FAIL %s: %s: %v\n", file, result.Name, result.Err)
+				continue
+			}
+			passed++
+			fmt.Fprintf(stdout, "PASS %s: %s\n", file, result.Name)
+		}
+	}
+
+	fmt.Fprintf(stdout, "%d/%d tests passed\n", passed, total)
+	if failed {
+		return 1
+	}
+	return 0
+}
@@ -3,9 +3,12 @@
 package main
 
 import (
+	"flag"
 	"fmt"
 	"os"
 
+	"github.com/rancher/webhook/pkg/admission"
+	"github.com/rancher/webhook/pkg/config"
 	"github.com/rancher/webhook/pkg/server"
 	_ "github.com/rancher/wrangler/v3/pkg/generated/controllers/admissionregistration.k8s.io"
 	"github.com/rancher/wrangler/v3/pkg/k8scheck"
@@ -21,20 +24,34 @@ var (
 )
 
 func main() {
-	if err := run(); err != nil {
+	if len(os.Args) > 1 && os.Args[1] == "validate" {
+		os.Exit(validate(os.Args[2:], os.Stdout, os.Stderr))
+	}
+	if len(os.Args) > 1 && os.Args[1] == "replay" {
+		os.Exit(replay(os.Args[2:], os.Stdout, os.Stderr))
+	}
+	if err := run(os.Args[1:]); err != nil {
 		logrus.Fatal(err)
 	}
 }
 
-func run() error {
-	if os.Getenv("CATTLE_DEBUG") == "true" || os.Getenv("RANCHER_DEBUG") == "true" {
-		logrus.SetLevel(logrus.DebugLevel)
+func run(args []string) error {
+	flags := flag.NewFlagSet("webhook", flag.ExitOnError)
+	configPath := flags.String("config", os.Getenv(config.FileEnv), "path to a YAML configuration file; environment variables override its values")
+	if err := flags.Parse(args); err != nil {
+		return err
 	}
-	if os.Getenv("CATTLE_TRACE") == "true" {
-		logrus.SetLevel(logrus.TraceLevel)
+	var loader *config.Loader
+	if *configPath != "" {
+		loader = config.NewLoader(*configPath)
+		if err := loader.Load(); err != nil {
+			return err
+		}
 	}
+	config.SetLogLevel()
 
 	logrus.Infof("Rancher-webhook version %s is starting", fmt.Sprintf("%s (%s)", Version, GitCommit))
+	admission.SetWebhookVersion(Version + "+" + GitCommit)
 
 	cfg, err := kubeconfig.GetNonInteractiveClientConfig(os.Getenv("KUBECONFIG")).ClientConfig()
 	if err != nil {
@@ -44,16 +61,14 @@ func run() error {
 	cfg.RateLimiter = ratelimit.None
 
 	ctx := signals.SetupSignalContext()
+	if loader != nil {
+		loader.WatchSIGHUP(ctx)
+	}
 
 	err = k8scheck.Wait(ctx, *cfg)
 	if err != nil {
 		return err
 	}
 
-	if err := server.ListenAndServe(ctx, cfg, os.Getenv("ENABLE_MCM") != "false"); err != nil {
-		return err
-	}
-
-	<-ctx.Done()
-	return nil
+	return server.ListenAndServe(ctx, cfg, os.Getenv(config.MCMEnv) != "false")
 }
@@ -10,6 +10,7 @@ import (
 	"path"
 	"time"
 
+	"github.com/rancher/webhook/pkg/metrics"
 	"github.com/sirupsen/logrus"
 	admissionv1 "k8s.io/api/admission/v1"
 	v1 "k8s.io/api/admissionregistration/v1"
@@ -19,9 +20,11 @@ import (
 )
 
 const (
-	webhookQualifier     = "rancher.cattle.io"
-	bypassServiceAccount = "system:serviceaccount:cattle-system:rancher-webhook-sudo"
-	systemMasters        = "system:masters"
+	webhookQualifier = "rancher.cattle.io"
+	// bypassNamespace and bypassName are the namespace and name of the sudo service account.
+	bypassNamespace = "cattle-system"
+	bypassName      = "rancher-webhook-sudo"
+	systemMasters   = "system:masters"
 )
 
 var (
@@ -68,6 +71,7 @@ type ValidatingAdmissionHandler interface {
 
 	// Admitters returns the admitters that this handler will call when evaluating a resource. If any one of these
 	// fails or encounters an error, the failure/error is immediately returned and the rest are short-circuted.
+	// Handlers needing more control over the order, concurrency or denials of their checks can return an AdmitterChain.
 	Admitters() []Admitter
 }
 
@@ -96,6 +100,12 @@ type Request struct {
 // The return webhook will not be nil.
 func NewDefaultValidatingWebhook(handler WebhookHandler, clientConfig v1.WebhookClientConfig, scope v1.ScopeType, ops []v1.OperationType) *v1.ValidatingWebhook {
 	info := defaultWebhookInfo(handler, clientConfig, scope, ops)
+	if subresourceValidator, ok := handler.(SubresourceValidator); ok {
+		info.rules[0].Resources = append(info.rules[0].Resources, subresourceResources(handler.GVR().Resource, subresourceValidator.Subresources())...)
+	}
+	if connectValidator, ok := handler.(ConnectValidator); ok {
+		info.rules[0].Resources = append(info.rules[0].Resources, subresourceResources(handler.GVR().Resource, connectValidator.ConnectSubresources())...)
+	}
 	return &v1.ValidatingWebhook{
 		Name:                    info.name,
 		ClientConfig:            info.clientConfig,
@@ -182,9 +192,14 @@ func SubPath(gvr schema.GroupVersionResource) string {
 
 // NewValidatingHandlerFunc returns a new HandlerFunc that will call the functions returned by the ValidatingAdmissionHandler's AdmitFuncs() call.
 // If it encounters a failure or an error, it short-circuts and returns immediately.
+// The warnings of all the admitters which were called are returned with the response.
 func NewValidatingHandlerFunc(handler ValidatingAdmissionHandler) http.HandlerFunc {
 	return func(responseWriter http.ResponseWriter, req *http.Request) {
+		start := time.Now()
+		req, span := startReviewSpan(req, metrics.WebhookTypeValidating, handler)
 		review, webReq, err := getReviewAndRequestForHandler(req, handler)
+		defer observeRequest(metrics.WebhookTypeValidating, handler, review, start)
+		defer func() { endReviewSpan(span, review) }()
 		if err != nil {
 			sendError(responseWriter, review, err)
 			return
@@ -196,13 +211,23 @@ func NewValidatingHandlerFunc(handler ValidatingAdmissionHandler) http.HandlerFu
 			return
 		}
 
+		if webReq.SubResource != "" && webReq.Operation != admissionv1.Connect {
+			response := admitSubresource(webReq)
+			logrus.Debugf("admit result: %s %s %s/%s user=%s allowed=%v", webReq.Operation, webReq.Kind.String(), resourceString(webReq.Namespace, webReq.Name), webReq.SubResource, webReq.UserInfo.Username, response.Allowed)
+			sendResponse(responseWriter, review, response)
+			return
+		}
+
+		stripRequest(&webReq.AdmissionRequest, true)
+
 		// save the response from the loop so we can return on success
 		var response *admissionv1.AdmissionResponse
+		var warnings []string
 		for _, admitter := range handler.Admitters() {
 			if admitter == nil {
 				continue
 			}
-			response, err = admitter.Admit(webReq)
+			response, err = admitWithSpan(admitter, webReq)
 			if response == nil {
 				response = &admissionv1.AdmissionResponse{}
 			}
@@ -214,6 +239,8 @@ func NewValidatingHandlerFunc(handler ValidatingAdmissionHandler) http.HandlerFu
 				sendError(responseWriter, review, err)
 				return
 			}
+			warnings = append(warnings, response.Warnings...)
+			response.Warnings = warnings
 			if !response.Allowed {
 				sendResponse(responseWriter, review, response)
 				return
@@ -227,7 +254,11 @@ func NewValidatingHandlerFunc(handler ValidatingAdmissionHandler) http.HandlerFu
 // NewMutatingHandlerFunc returns a new HandlerFunc that will call the function returned by the MutatingAdmissionHandler's AdmitFunc() call.
 func NewMutatingHandlerFunc(handler MutatingAdmissionHandler) http.HandlerFunc {
 	return func(responseWriter http.ResponseWriter, req *http.Request) {
+		start := time.Now()
+		req, span := startReviewSpan(req, metrics.WebhookTypeMutating, handler)
 		review, webReq, err := getReviewAndRequestForHandler(req, handler)
+		defer observeRequest(metrics.WebhookTypeMutating, handler, review, start)
+		defer func() { endReviewSpan(span, review) }()
 		if err != nil {
 			// review could not be valid, so initialize some safe defaults
 			sendError(responseWriter, review, err)
@@ -240,7 +271,8 @@ func NewMutatingHandlerFunc(handler MutatingAdmissionHandler) http.HandlerFunc {
 			return
 		}
 
-		response, err := handler.Admit(webReq)
+		stripRequest(&webReq.AdmissionRequest, false)
+		response, err := admitWithSpan(handler, webReq)
 		if response == nil {
 			response = &admissionv1.AdmissionResponse{}
 		}
@@ -259,7 +291,7 @@ func NewMutatingHandlerFunc(handler MutatingAdmissionHandler) http.HandlerFunc {
 // Returns an error if this handler can't handle this request or if the http.Request couldn't be decoded into an admissionReview.
 func getReviewAndRequestForHandler(req *http.Request, handler WebhookHandler) (*admissionv1.AdmissionReview, *Request, error) {
 	review := admissionv1.AdmissionReview{}
-	err := json.NewDecoder(req.Body).Decode(&review)
+	err := decodeReview(req.Body, &review)
 	if err != nil {
 		return nil, nil, err
 	}
@@ -310,6 +342,22 @@ func sendError(responseWriter http.ResponseWriter, review *admissionv1.Admission
 	writeResponse(responseWriter, review)
 }
 
+// observeRequest records the metrics of a request once it has been handled. Requests which could not be decoded are
+// not recorded since their operation is unknown.
+func observeRequest(webhookType string, handler WebhookHandler, review *admissionv1.AdmissionReview, start time.Time) {
+	if review == nil || review.Request == nil {
+		return
+	}
+	result := metrics.ResultAllowed
+	switch {
+	case review.Response == nil || (review.Response.Result != nil && review.Response.Result.Code == http.StatusInternalServerError):
+		result = metrics.ResultError
+	case !review.Response.Allowed:
+		result = metrics.ResultDenied
+	}
+	metrics.ObserveAdmissionRequest(webhookType, handler.GVR(), string(review.Request.Operation), result, start)
+}
+
 func writeResponse(responseWriter http.ResponseWriter, review *admissionv1.AdmissionReview) {
 	responseWriter.Header().Set("Content-Type", "application/json")
 	err := json.NewEncoder(responseWriter).Encode(review)
@@ -343,6 +391,14 @@ func ResponseAllowed() *admissionv1.AdmissionResponse {
 	}
 }
 
+// ResponseAllowedWithWarnings returns an AdmissionResponse which allows the request and returns the warnings to the client.
+// Warnings are used to report deprecated fields or behaviors which will be denied in the future.
+func ResponseAllowedWithWarnings(warnings ...string) *admissionv1.AdmissionResponse {
+	response := ResponseAllowed()
+	response.Warnings = warnings
+	return response
+}
+
 // ResponseBadRequest returns an AdmissionResponse for BadRequest(err code 400)
 // the message is used as the message in the response
 func ResponseBadRequest(message string) *admissionv1.AdmissionResponse {
@@ -379,9 +435,10 @@ func CreateWebhookName(handler WebhookHandler, suffix string) string {
 	return fmt.Sprintf("%s.%s.%s", webhookQualifier, subPath, suffix)
 }
 
-// bypassValidation users can bypass the webhook if they are the sudo account and system:masters group
+// bypassValidation users can bypass the webhook if they are the sudo account, verified by IsServiceAccount, and in the
+// system:masters group
 func bypassValidation(request *admissionv1.AdmissionRequest) bool {
-	if request.UserInfo.Username != bypassServiceAccount {
+	if !IsServiceAccount(request.UserInfo, bypassNamespace, bypassName) {
 		return false
 	}
 	for _, group := range request.UserInfo.Groups {
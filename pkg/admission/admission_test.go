@@ -25,11 +25,13 @@ const (
 type handlerResponse struct {
 	hasAllow bool
 	hasError bool
+	warnings []string
 }
 
 type reviewResponse struct {
 	wantReviewAllow bool
 	wantReviewError bool
+	wantWarnings    []string
 }
 
 func TestNewValidatingHandlerFunc(t *testing.T) {
@@ -96,6 +98,37 @@ func TestNewValidatingHandlerFunc(t *testing.T) {
 				wantReviewAllow: false,
 			},
 		},
+		{
+			name:    "handler matches, both allow with warnings",
+			request: defaultRequest,
+			firstHandlerResponse: &handlerResponse{
+				hasAllow: true,
+				warnings: []string{"first warning"},
+			},
+			secondHandlerResponse: &handlerResponse{
+				hasAllow: true,
+				warnings: []string{"second warning"},
+			},
+			wantResponse: &reviewResponse{
+				wantReviewAllow: true,
+				wantWarnings:    []string{"first warning", "second warning"},
+			},
+		},
+		{
+			name:    "handler matches, first allows with warnings, second denies",
+			request: defaultRequest,
+			firstHandlerResponse: &handlerResponse{
+				hasAllow: true,
+				warnings: []string{"first warning"},
+			},
+			secondHandlerResponse: &handlerResponse{
+				hasAllow: false,
+			},
+			wantResponse: &reviewResponse{
+				wantReviewAllow: false,
+				wantWarnings:    []string{"first warning"},
+			},
+		},
 		{
 			name:    "handler matches, first error",
 			request: defaultRequest,
@@ -289,6 +322,7 @@ func TestNewValidatingHandlerFunc(t *testing.T) {
 				assert.NoError(t, err)
 				assert.Equal(t, types.UID("1"), review.Response.UID)
 				assert.Equal(t, test.wantResponse.wantReviewAllow, review.Response.Allowed)
+				assert.Equal(t, test.wantResponse.wantWarnings, review.Response.Warnings)
 				if test.wantResponse.wantReviewError {
 					assert.Greater(t, int(review.Response.Result.Code), 399, "expected an error code of 400 or higher")
 				}
@@ -298,6 +332,53 @@ func TestNewValidatingHandlerFunc(t *testing.T) {
 
 }
 
+// TestBypassVerifiesServiceAccount sets the package's ServiceAccountGetter, so it must not run in parallel with other
+// tests.
+func TestBypassVerifiesServiceAccount(t *testing.T) {
+	admission.SetServiceAccountGetter(fakeServiceAccountGetter{
+		"cattle-system/rancher-webhook-sudo": {ObjectMeta: metav1.ObjectMeta{Name: "rancher-webhook-sudo", Namespace: "cattle-system", UID: "sudo-uid"}},
+	})
+	t.Cleanup(func() { admission.SetServiceAccountGetter(nil) })
+
+	tests := []struct {
+		name      string
+		uid       string
+		wantAllow bool
+	}{
+		{
+			name:      "verified sudo account",
+			uid:       "sudo-uid",
+			wantAllow: true,
+		},
+		{
+			name: "sudo account with another UID",
+			uid:  "other-uid",
+		},
+		{
+			name: "sudo account without UID",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			handler := fakeValidatingAdmissionHandler{
+				gvr:        schema.GroupVersionResource{Group: "test.cattle.io", Version: "v1alpha1", Resource: "resources"},
+				operations: []v1.OperationType{v1.Create},
+				admitters:  []fakeAdmitter{setupAdmitter(&handlerResponse{hasAllow: false})},
+			}
+			request := defaultRequest()
+			request.UserInfo = authenticationv1.UserInfo{Username: bypassServiceAccount, UID: test.uid, Groups: []string{systemMasters}}
+			body, err := json.Marshal(admissionv1.AdmissionReview{Request: request})
+			assert.NoError(t, err)
+			response := httptest.NewRecorder()
+			admission.NewValidatingHandlerFunc(&handler)(response, httptest.NewRequest("get", "/testEndpoint", strings.NewReader(string(body))))
+
+			review := admissionv1.AdmissionReview{}
+			assert.NoError(t, json.NewDecoder(response.Result().Body).Decode(&review))
+			assert.Equal(t, test.wantAllow, review.Response.Allowed)
+		})
+	}
+}
+
 func TestNewMutatingHandlerFunc(t *testing.T) {
 	tests := []struct {
 		name            string
@@ -504,7 +585,8 @@ func setupAdmitter(response *handlerResponse) fakeAdmitter {
 		admitter.err = fmt.Errorf("handler/admitter error")
 	}
 	admitter.response = admissionv1.AdmissionResponse{
-		Allowed: response.hasAllow,
+		Allowed:  response.hasAllow,
+		Warnings: response.warnings,
 	}
 	return admitter
 }
@@ -0,0 +1,176 @@
+package admission
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+
+	admissionv1 "k8s.io/api/admission/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// ChainMode controls what an AdmitterChain does when one of its links denies a request.
+type ChainMode int
+
+const (
+	// StopOnFirstDeny returns the first denial, without running the links ordered after the denying one.
+	StopOnFirstDeny ChainMode = iota
+	// CollectDenials runs all the links and returns a single denial aggregating the denials of all of them.
+	CollectDenials
+)
+
+// AdmitterFunc is a function implementing Admitter.
+type AdmitterFunc func(*Request) (*admissionv1.AdmissionResponse, error)
+
+// Admit calls the function.
+func (f AdmitterFunc) Admit(request *Request) (*admissionv1.AdmissionResponse, error) {
+	return f(request)
+}
+
+// ChainLink is an Admitter run by an AdmitterChain.
+type ChainLink struct {
+	// Name identifies the link in errors and traces.
+	Name string
+	// Order is the position of the link in the chain. Links with a lower order run first, and links with the same
+	// order run in the order they were given to NewAdmitterChain.
+	Order int
+	// Parallel links run concurrently with the adjacent parallel links of the same order, so they must not depend on
+	// each other. Their responses are still handled in the order of the links.
+	Parallel bool
+	// Admitter reviews the request.
+	Admitter Admitter
+}
+
+// AdmitterChain is an Admitter running its links in an explicit order. Errors are returned immediately, while denials
+// are handled according to the ChainMode of the chain. The warnings of all the responses are kept.
+type AdmitterChain struct {
+	mode   ChainMode
+	stages [][]ChainLink
+}
+
+// NewAdmitterChain returns a chain running the links by their order. Chains are Admitters themselves, so they can be
+// nested to, for example, collect the denials of some independent checks before stopping at the first denial.
+func NewAdmitterChain(mode ChainMode, links ...ChainLink) *AdmitterChain {
+	sorted := append([]ChainLink(nil), links...)
+	sort.SliceStable(sorted, func(i, j int) bool { return sorted[i].Order < sorted[j].Order })
+
+	var stages [][]ChainLink
+	for i, link := range sorted {
+		if i > 0 && link.Parallel {
+			last := stages[len(stages)-1]
+			if prev := last[len(last)-1]; prev.Parallel && prev.Order == link.Order {
+				stages[len(stages)-1] = append(last, link)
+				continue
+			}
+		}
+		stages = append(stages, []ChainLink{link})
+	}
+	return &AdmitterChain{mode: mode, stages: stages}
+}
+
+// Admit runs the links of the chain.
+func (c *AdmitterChain) Admit(request *Request) (*admissionv1.AdmissionResponse, error) {
+	var denials []*admissionv1.AdmissionResponse
+	var warnings []string
+	for _, stage := range c.stages {
+		responses, err := admitStage(stage, request)
+		if err != nil {
+			return nil, err
+		}
+		for _, response := range responses {
+			warnings = append(warnings, response.Warnings...)
+			if response.Allowed {
+				continue
+			}
+			if c.mode == StopOnFirstDeny {
+				response.Warnings = warnings
+				return response, nil
+			}
+			denials = append(denials, response)
+		}
+	}
+	if len(denials) == 0 {
+		response := ResponseAllowed()
+		response.Warnings = warnings
+		return response, nil
+	}
+	response := aggregateDenials(denials)
+	response.Warnings = warnings
+	return response, nil
+}
+
+// admitStage runs the links of the stage, concurrently if there are several, and returns their responses in order.
+// The first error, in the order of the links, is returned.
+func admitStage(stage []ChainLink, request *Request) ([]*admissionv1.AdmissionResponse, error) {
+	responses := make([]*admissionv1.AdmissionResponse, len(stage))
+	errs := make([]error, len(stage))
+	if len(stage) == 1 {
+		responses[0], errs[0] = admitLink(stage[0], request)
+	} else {
+		var wg sync.WaitGroup
+		for i := range stage {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				responses[i], errs[i] = admitLink(stage[i], request)
+			}(i)
+		}
+		wg.Wait()
+	}
+	for _, err := range errs {
+		if err != nil {
+			return nil, err
+		}
+	}
+	return responses, nil
+}
+
+// admitLink runs the admitter of the link with its own copy of the request, so that links running concurrently don't
+// share the request's context.
+func admitLink(link ChainLink, request *Request) (*admissionv1.AdmissionResponse, error) {
+	linkRequest := *request
+	response, err := admitWithNamedSpan(link.Name, link.Admitter, &linkRequest)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", link.Name, err)
+	}
+	if response == nil {
+		return nil, fmt.Errorf("%s: no response returned", link.Name)
+	}
+	return response, nil
+}
+
+// aggregateDenials returns a single denial holding the messages and causes of all the denials. The code and reason
+// are those of the first denial.
+func aggregateDenials(denials []*admissionv1.AdmissionResponse) *admissionv1.AdmissionResponse {
+	if len(denials) == 1 {
+		return denials[0]
+	}
+	result := &metav1.Status{
+		Status: "Failure",
+		Reason: metav1.StatusReasonBadRequest,
+		Code:   http.StatusBadRequest,
+	}
+	messages := make([]string, 0, len(denials))
+	var causes []metav1.StatusCause
+	for i, denial := range denials {
+		if denial.Result == nil {
+			messages = append(messages, "request denied")
+			continue
+		}
+		if i == 0 {
+			result.Reason = denial.Result.Reason
+			result.Code = denial.Result.Code
+		}
+		messages = append(messages, denial.Result.Message)
+		if denial.Result.Details != nil {
+			causes = append(causes, denial.Result.Details.Causes...)
+		}
+	}
+	result.Message = strings.Join(messages, "; ")
+	if len(causes) > 0 {
+		result.Details = &metav1.StatusDetails{Causes: causes}
+	}
+	return &admissionv1.AdmissionResponse{Allowed: false, Result: result}
+}
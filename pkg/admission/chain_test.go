@@ -0,0 +1,152 @@
+package admission_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/rancher/webhook/pkg/admission"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	admissionv1 "k8s.io/api/admission/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// chainRecorder records the order in which the links of a chain ran.
+type chainRecorder struct {
+	mu  sync.Mutex
+	ran []string
+}
+
+func (c *chainRecorder) link(name string, order int, response *admissionv1.AdmissionResponse, err error) admission.ChainLink {
+	return admission.ChainLink{
+		Name:  name,
+		Order: order,
+		Admitter: admission.AdmitterFunc(func(_ *admission.Request) (*admissionv1.AdmissionResponse, error) {
+			c.mu.Lock()
+			defer c.mu.Unlock()
+			c.ran = append(c.ran, name)
+			return response, err
+		}),
+	}
+}
+
+func denied(message string, code int32, causes ...metav1.StatusCause) *admissionv1.AdmissionResponse {
+	response := admission.ResponseBadRequest(message)
+	response.Result.Code = code
+	if len(causes) > 0 {
+		response.Result.Details = &metav1.StatusDetails{Causes: causes}
+	}
+	return response
+}
+
+func TestAdmitterChainOrder(t *testing.T) {
+	t.Parallel()
+	recorder := &chainRecorder{}
+	allowed := admission.ResponseAllowed()
+	chain := admission.NewAdmitterChain(admission.StopOnFirstDeny,
+		recorder.link("third", 20, allowed, nil),
+		recorder.link("first", 0, allowed, nil),
+		recorder.link("second", 10, allowed, nil),
+		recorder.link("first-again", 0, allowed, nil),
+	)
+	response, err := chain.Admit(&admission.Request{Context: context.Background()})
+	require.NoError(t, err)
+	assert.True(t, response.Allowed)
+	assert.Equal(t, []string{"first", "first-again", "second", "third"}, recorder.ran)
+}
+
+func TestAdmitterChainStopOnFirstDeny(t *testing.T) {
+	t.Parallel()
+	recorder := &chainRecorder{}
+	warned := admission.ResponseAllowed()
+	warned.Warnings = []string{"warning"}
+	chain := admission.NewAdmitterChain(admission.StopOnFirstDeny,
+		recorder.link("warned", 0, warned, nil),
+		recorder.link("denied", 1, denied("first denial", http.StatusForbidden), nil),
+		recorder.link("skipped", 2, denied("second denial", http.StatusBadRequest), nil),
+	)
+	response, err := chain.Admit(&admission.Request{Context: context.Background()})
+	require.NoError(t, err)
+	assert.False(t, response.Allowed)
+	assert.Equal(t, "first denial", response.Result.Message)
+	assert.Equal(t, []string{"warning"}, response.Warnings)
+	assert.Equal(t, []string{"warned", "denied"}, recorder.ran)
+}
+
+func TestAdmitterChainCollectDenials(t *testing.T) {
+	t.Parallel()
+	recorder := &chainRecorder{}
+	cause := metav1.StatusCause{Type: metav1.CauseTypeFieldValueInvalid, Field: "spec.field"}
+	chain := admission.NewAdmitterChain(admission.CollectDenials,
+		recorder.link("forbidden", 0, denied("first denial", http.StatusForbidden), nil),
+		recorder.link("allowed", 1, admission.ResponseAllowed(), nil),
+		recorder.link("invalid", 2, denied("second denial", http.StatusBadRequest, cause), nil),
+	)
+	response, err := chain.Admit(&admission.Request{Context: context.Background()})
+	require.NoError(t, err)
+	assert.False(t, response.Allowed)
+	assert.Equal(t, "first denial; second denial", response.Result.Message)
+	assert.Equal(t, int32(http.StatusForbidden), response.Result.Code)
+	require.NotNil(t, response.Result.Details)
+	assert.Equal(t, []metav1.StatusCause{cause}, response.Result.Details.Causes)
+	assert.Equal(t, []string{"forbidden", "allowed", "invalid"}, recorder.ran)
+}
+
+func TestAdmitterChainErrors(t *testing.T) {
+	t.Parallel()
+	recorder := &chainRecorder{}
+	chain := admission.NewAdmitterChain(admission.CollectDenials,
+		recorder.link("failing", 0, nil, errors.New("unexpected error")),
+		recorder.link("skipped", 1, admission.ResponseAllowed(), nil),
+	)
+	_, err := chain.Admit(&admission.Request{Context: context.Background()})
+	require.ErrorContains(t, err, "failing: unexpected error")
+	assert.Equal(t, []string{"failing"}, recorder.ran)
+
+	chain = admission.NewAdmitterChain(admission.StopOnFirstDeny, recorder.link("nil", 0, nil, nil))
+	_, err = chain.Admit(&admission.Request{Context: context.Background()})
+	require.Error(t, err)
+}
+
+func TestAdmitterChainParallel(t *testing.T) {
+	t.Parallel()
+	// each parallel link waits for the other to start, which only completes if they run concurrently
+	var started sync.WaitGroup
+	started.Add(2)
+	parallelLink := func(name string, response *admissionv1.AdmissionResponse) admission.ChainLink {
+		return admission.ChainLink{
+			Name:     name,
+			Order:    5,
+			Parallel: true,
+			Admitter: admission.AdmitterFunc(func(_ *admission.Request) (*admissionv1.AdmissionResponse, error) {
+				started.Done()
+				started.Wait()
+				return response, nil
+			}),
+		}
+	}
+	chain := admission.NewAdmitterChain(admission.StopOnFirstDeny,
+		parallelLink("slow-denied", denied("first denial", http.StatusBadRequest)),
+		parallelLink("denied", denied("second denial", http.StatusBadRequest)),
+	)
+
+	done := make(chan struct{})
+	var response *admissionv1.AdmissionResponse
+	var err error
+	go func() {
+		defer close(done)
+		response, err = chain.Admit(&admission.Request{Context: context.Background()})
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("parallel links did not run concurrently")
+	}
+	require.NoError(t, err)
+	// the denials are handled in the order of the links
+	assert.Equal(t, "first denial", response.Result.Message)
+}
@@ -0,0 +1,34 @@
+package admission
+
+// WithAdmitters returns a handler reviewing requests with the given admitters before the admitters of the handler.
+// It is used to add checks shared by several resources to their existing handlers, instead of registering another
+// webhook for them. The returned handler still reviews the subresources of handlers implementing SubresourceValidator.
+func WithAdmitters(handler ValidatingAdmissionHandler, admitters ...Admitter) ValidatingAdmissionHandler {
+	extended := &extendedHandler{ValidatingAdmissionHandler: handler, admitters: admitters}
+	if _, ok := handler.(SubresourceValidator); ok {
+		return &extendedSubresourceHandler{extendedHandler: extended}
+	}
+	return extended
+}
+
+// extendedHandler is a ValidatingAdmissionHandler with additional admitters.
+type extendedHandler struct {
+	ValidatingAdmissionHandler
+	admitters []Admitter
+}
+
+// Admitters returns the additional admitters followed by the admitters of the handler.
+func (e *extendedHandler) Admitters() []Admitter {
+	admitters := append([]Admitter(nil), e.admitters...)
+	return append(admitters, e.ValidatingAdmissionHandler.Admitters()...)
+}
+
+// extendedSubresourceHandler is an extendedHandler whose handler implements SubresourceValidator.
+type extendedSubresourceHandler struct {
+	*extendedHandler
+}
+
+// Subresources returns the subresources of the handler.
+func (e *extendedSubresourceHandler) Subresources() []string {
+	return e.ValidatingAdmissionHandler.(SubresourceValidator).Subresources()
+}
@@ -0,0 +1,54 @@
+package admission_test
+
+import (
+	"testing"
+
+	"github.com/rancher/webhook/pkg/admission"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	admissionv1 "k8s.io/api/admission/v1"
+	v1 "k8s.io/api/admissionregistration/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+func TestWithAdmitters(t *testing.T) {
+	t.Parallel()
+	var calls []string
+	added := admission.AdmitterFunc(func(*admission.Request) (*admissionv1.AdmissionResponse, error) {
+		calls = append(calls, "added")
+		return admission.ResponseAllowed(), nil
+	})
+	handler := &fakeValidatingAdmissionHandler{
+		gvr:        schema.GroupVersionResource{Group: "test.cattle.io", Version: "v1alpha1", Resource: "resources"},
+		operations: []v1.OperationType{v1.Create},
+		admitters:  []fakeAdmitter{setupAdmitter(&handlerResponse{hasAllow: true})},
+	}
+
+	extended := admission.WithAdmitters(handler, added)
+	assert.Equal(t, handler.GVR(), extended.GVR())
+	assert.Equal(t, handler.Operations(), extended.Operations())
+	_, isSubresourceValidator := extended.(admission.SubresourceValidator)
+	assert.False(t, isSubresourceValidator)
+
+	admitters := extended.Admitters()
+	require.Len(t, admitters, 2)
+	for _, admitter := range admitters {
+		response, err := admitter.Admit(&admission.Request{})
+		require.NoError(t, err)
+		assert.True(t, response.Allowed)
+	}
+	assert.Equal(t, []string{"added"}, calls)
+	_, isFake := admitters[1].(*fakeAdmitter)
+	assert.True(t, isFake, "the admitters of the handler should run after the added ones")
+}
+
+func TestWithAdmittersSubresources(t *testing.T) {
+	t.Parallel()
+	extended := admission.WithAdmitters(newFakeSubresourceValidator(), admission.AdmitterFunc(func(*admission.Request) (*admissionv1.AdmissionResponse, error) {
+		return admission.ResponseAllowed(), nil
+	}))
+	subresourceValidator, ok := extended.(admission.SubresourceValidator)
+	require.True(t, ok)
+	assert.Equal(t, []string{"status"}, subresourceValidator.Subresources())
+	assert.Len(t, extended.Admitters(), 2)
+}
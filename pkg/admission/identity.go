@@ -0,0 +1,91 @@
+package admission
+
+import (
+	"strings"
+	"sync/atomic"
+
+	"github.com/sirupsen/logrus"
+	authenticationv1 "k8s.io/api/authentication/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apiserver/pkg/authentication/serviceaccount"
+)
+
+// ServiceAccountGetter gets ServiceAccounts, such as a ServiceAccountCache.
+type ServiceAccountGetter interface {
+	Get(namespace, name string) (*corev1.ServiceAccount, error)
+}
+
+var serviceAccounts atomic.Pointer[ServiceAccountGetter]
+
+// SetServiceAccountGetter sets the getter used to verify the identity of the service accounts making requests. Until
+// it is set, service accounts are identified by their groups alone.
+func SetServiceAccountGetter(getter ServiceAccountGetter) {
+	if getter == nil {
+		serviceAccounts.Store(nil)
+		return
+	}
+	serviceAccounts.Store(&getter)
+}
+
+// IsServiceAccountOfNamespace returns true if the user is a service account of the namespace. The request's user
+// names and groups can be set freely by users allowed to impersonate, so once a ServiceAccountGetter is set the user
+// must also be the service account the API server authenticated: its name must be the service account's user name,
+// and its UID, which the API server sets from the credentials of the service account and which can't be guessed, must
+// be the UID of the existing ServiceAccount.
+func IsServiceAccountOfNamespace(user authenticationv1.UserInfo, namespace string) bool {
+	inGroup := false
+	for _, group := range user.Groups {
+		if group == serviceaccount.MakeNamespaceGroupName(namespace) {
+			inGroup = true
+			break
+		}
+	}
+	if !inGroup {
+		return false
+	}
+	getter := serviceAccounts.Load()
+	if getter == nil {
+		return true
+	}
+	userNamespace, name, err := serviceaccount.SplitUsername(user.Username)
+	if err != nil || userNamespace != namespace {
+		return false
+	}
+	return isServiceAccount(*getter, user, namespace, name)
+}
+
+// IsServiceAccount returns true if the user is the service account with the given namespace and name, verified as
+// done by IsServiceAccountOfNamespace.
+func IsServiceAccount(user authenticationv1.UserInfo, namespace, name string) bool {
+	if user.Username != serviceaccount.MakeUsername(namespace, name) {
+		return false
+	}
+	getter := serviceAccounts.Load()
+	if getter == nil {
+		return true
+	}
+	return isServiceAccount(*getter, user, namespace, name)
+}
+
+func isServiceAccount(getter ServiceAccountGetter, user authenticationv1.UserInfo, namespace, name string) bool {
+	if user.UID == "" {
+		return false
+	}
+	account, err := getter.Get(namespace, name)
+	if err != nil {
+		logrus.Debugf("failed to verify service account %s/%s: %v", namespace, name, err)
+		return false
+	}
+	if string(account.UID) != user.UID {
+		logrus.Warnf("request by service account %s/%s with UID %s not matching the ServiceAccount's UID, it may be impersonated",
+			namespace, name, user.UID)
+		return false
+	}
+	return true
+}
+
+// serviceAccountNamespace returns the namespace of a service account group, such as system:serviceaccounts:kube-system.
+func serviceAccountNamespace(group string) (string, bool) {
+	namespace, ok := strings.CutPrefix(group, serviceaccount.ServiceAccountGroupPrefix)
+	return namespace, ok && namespace != ""
+}
@@ -0,0 +1,95 @@
+package admission_test
+
+import (
+	"testing"
+
+	"github.com/rancher/webhook/pkg/admission"
+	"github.com/stretchr/testify/assert"
+	admissionv1 "k8s.io/api/admission/v1"
+	authenticationv1 "k8s.io/api/authentication/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+type fakeServiceAccountGetter map[string]*corev1.ServiceAccount
+
+func (f fakeServiceAccountGetter) Get(namespace, name string) (*corev1.ServiceAccount, error) {
+	if account, ok := f[namespace+"/"+name]; ok {
+		return account, nil
+	}
+	return nil, apierrors.NewNotFound(corev1.Resource("serviceaccounts"), name)
+}
+
+// TestVerifiedServiceAccounts sets the package's ServiceAccountGetter, so it must not run in parallel with other tests.
+func TestVerifiedServiceAccounts(t *testing.T) {
+	rancher := authenticationv1.UserInfo{
+		Username: "system:serviceaccount:cattle-system:rancher",
+		UID:      "8c1b5a6e-rancher",
+		Groups:   []string{"system:serviceaccounts", "system:serviceaccounts:cattle-system", "system:authenticated"},
+	}
+	withUser := func(user authenticationv1.UserInfo, edit func(*authenticationv1.UserInfo)) authenticationv1.UserInfo {
+		user.Groups = append([]string{}, user.Groups...)
+		edit(&user)
+		return user
+	}
+	tests := []struct {
+		name               string
+		user               authenticationv1.UserInfo
+		wantUnverified     bool
+		wantVerified       bool
+		wantServiceAccount bool
+	}{
+		{
+			name:               "service account",
+			user:               rancher,
+			wantUnverified:     true,
+			wantVerified:       true,
+			wantServiceAccount: true,
+		},
+		{
+			name:           "impersonated service account without UID",
+			user:           withUser(rancher, func(u *authenticationv1.UserInfo) { u.UID = "" }),
+			wantUnverified: true,
+		},
+		{
+			name:           "service account with another UID",
+			user:           withUser(rancher, func(u *authenticationv1.UserInfo) { u.UID = "guessed" }),
+			wantUnverified: true,
+		},
+		{
+			name:           "user impersonating the group of the namespace",
+			user:           withUser(rancher, func(u *authenticationv1.UserInfo) { u.Username = "mallory" }),
+			wantUnverified: true,
+		},
+		{
+			name:           "service account of a deleted ServiceAccount",
+			user:           withUser(rancher, func(u *authenticationv1.UserInfo) { u.Username = "system:serviceaccount:cattle-system:deleted" }),
+			wantUnverified: true,
+		},
+		{
+			name: "service account of another namespace",
+			user: withUser(rancher, func(u *authenticationv1.UserInfo) {
+				u.Username = "system:serviceaccount:default:rancher"
+				u.Groups = []string{"system:serviceaccounts", "system:serviceaccounts:default"}
+			}),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			admission.SetServiceAccountGetter(nil)
+			assert.Equal(t, tt.wantUnverified, admission.IsServiceAccountOfNamespace(tt.user, "cattle-system"))
+
+			admission.SetServiceAccountGetter(fakeServiceAccountGetter{
+				"cattle-system/rancher": {ObjectMeta: metav1.ObjectMeta{Name: "rancher", Namespace: "cattle-system", UID: "8c1b5a6e-rancher"}},
+			})
+			t.Cleanup(func() { admission.SetServiceAccountGetter(nil) })
+			assert.Equal(t, tt.wantVerified, admission.IsServiceAccountOfNamespace(tt.user, "cattle-system"))
+			assert.Equal(t, tt.wantServiceAccount, admission.IsServiceAccount(tt.user, "cattle-system", "rancher"))
+			// controllers are trusted as verified service accounts of their namespace
+			assert.Equal(t, tt.wantVerified, admission.IsController(&admission.Request{
+				AdmissionRequest: admissionv1.AdmissionRequest{UserInfo: tt.user},
+			}))
+		})
+	}
+}
@@ -0,0 +1,89 @@
+package admission
+
+import (
+	"fmt"
+	"strings"
+
+	authenticationv1 "k8s.io/api/authentication/v1"
+	"k8s.io/apiserver/pkg/authentication/serviceaccount"
+)
+
+// The user extras Rancher sets when it impersonates one of its users to make a request on their behalf.
+const (
+	// ImpersonationExtraUsername holds the username the user logged into Rancher with.
+	ImpersonationExtraUsername = "username"
+	// ImpersonationExtraPrincipalID holds the principal IDs of the user, such as "local://u-abcde".
+	ImpersonationExtraPrincipalID = "principalid"
+	// ImpersonationExtraRequestHost holds the host the request was made to.
+	ImpersonationExtraRequestHost = "requesthost"
+	// ImpersonationExtraRequestTokenID holds the name of the Rancher token the request was authenticated with.
+	ImpersonationExtraRequestTokenID = "requesttokenid"
+)
+
+// Impersonation is the context of a request Rancher made on behalf of one of its users, parsed from the extras of the
+// request's user. The request's user is the impersonated Rancher user, while the Impersonation identifies the actor
+// who authenticated to Rancher.
+type Impersonation struct {
+	// Username is the username the actor logged into Rancher with.
+	Username string `json:"username,omitempty"`
+	// PrincipalIDs are the principals of the actor.
+	PrincipalIDs []string `json:"principalIDs,omitempty"`
+	// RequestHost is the host the actor made the request to.
+	RequestHost string `json:"requestHost,omitempty"`
+	// RequestTokenID is the name of the token the actor authenticated with.
+	RequestTokenID string `json:"requestTokenID,omitempty"`
+}
+
+// ImpersonationOf returns the impersonation context of the user, or nil if the user doesn't have any of the extras
+// Rancher sets when it impersonates its users.
+func ImpersonationOf(user authenticationv1.UserInfo) *Impersonation {
+	impersonation := Impersonation{
+		Username:       firstExtra(user, ImpersonationExtraUsername),
+		PrincipalIDs:   user.Extra[ImpersonationExtraPrincipalID],
+		RequestHost:    firstExtra(user, ImpersonationExtraRequestHost),
+		RequestTokenID: firstExtra(user, ImpersonationExtraRequestTokenID),
+	}
+	if impersonation.Username == "" && len(impersonation.PrincipalIDs) == 0 && impersonation.RequestHost == "" && impersonation.RequestTokenID == "" {
+		return nil
+	}
+	return &impersonation
+}
+
+// Impersonation returns the impersonation context of the request, or nil if the request wasn't made by Rancher on
+// behalf of one of its users.
+func (r *Request) Impersonation() *Impersonation {
+	return ImpersonationOf(r.UserInfo)
+}
+
+// ValidateImpersonation returns an error if the request carries the impersonation context of a Rancher user but could
+// not have been made by Rancher on their behalf. Rancher only impersonates its own users and sets each extra at most
+// once, so a request from a service account, a system user or a member of system:masters carrying these extras was
+// impersonated by someone else claiming to act for a Rancher user, and must not be trusted with the permissions of the
+// impersonated identity.
+func ValidateImpersonation(request *Request) error {
+	if request.Impersonation() == nil {
+		return nil
+	}
+	user := request.UserInfo
+	for _, key := range []string{ImpersonationExtraUsername, ImpersonationExtraRequestHost, ImpersonationExtraRequestTokenID} {
+		if len(user.Extra[key]) > 1 {
+			return fmt.Errorf("invalid impersonation of user %s: extra %s has %d values", user.Username, key, len(user.Extra[key]))
+		}
+	}
+	if strings.HasPrefix(user.Username, serviceaccount.ServiceAccountUsernamePrefix) || strings.HasPrefix(user.Username, "system:") {
+		return fmt.Errorf("invalid impersonation: %s can't act on behalf of a Rancher user", user.Username)
+	}
+	for _, group := range user.Groups {
+		if group == systemMasters {
+			return fmt.Errorf("invalid impersonation of user %s: members of %s can't act on behalf of a Rancher user", user.Username, systemMasters)
+		}
+	}
+	return nil
+}
+
+func firstExtra(user authenticationv1.UserInfo, key string) string {
+	if values := user.Extra[key]; len(values) > 0 {
+		return values[0]
+	}
+	return ""
+}
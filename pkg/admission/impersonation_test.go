@@ -0,0 +1,95 @@
+package admission_test
+
+import (
+	"testing"
+
+	"github.com/rancher/webhook/pkg/admission"
+	"github.com/stretchr/testify/assert"
+	admissionv1 "k8s.io/api/admission/v1"
+	authenticationv1 "k8s.io/api/authentication/v1"
+)
+
+func TestImpersonationOf(t *testing.T) {
+	t.Parallel()
+	assert.Nil(t, admission.ImpersonationOf(authenticationv1.UserInfo{Username: "u-abcde", Extra: map[string]authenticationv1.ExtraValue{"other": {"value"}}}))
+
+	impersonation := admission.ImpersonationOf(authenticationv1.UserInfo{
+		Username: "u-abcde",
+		Extra: map[string]authenticationv1.ExtraValue{
+			admission.ImpersonationExtraUsername:       {"alice"},
+			admission.ImpersonationExtraPrincipalID:    {"local://u-abcde", "github_user://1234"},
+			admission.ImpersonationExtraRequestHost:    {"rancher.example.com"},
+			admission.ImpersonationExtraRequestTokenID: {"token-xyz"},
+		},
+	})
+	assert.Equal(t, &admission.Impersonation{
+		Username:       "alice",
+		PrincipalIDs:   []string{"local://u-abcde", "github_user://1234"},
+		RequestHost:    "rancher.example.com",
+		RequestTokenID: "token-xyz",
+	}, impersonation)
+}
+
+func TestValidateImpersonation(t *testing.T) {
+	t.Parallel()
+	rancherExtras := map[string]authenticationv1.ExtraValue{
+		admission.ImpersonationExtraUsername:    {"alice"},
+		admission.ImpersonationExtraPrincipalID: {"local://u-abcde"},
+	}
+	tests := []struct {
+		name             string
+		userInfo         authenticationv1.UserInfo
+		wantErr          bool
+		wantIsController bool
+	}{
+		{
+			name:     "not impersonated",
+			userInfo: authenticationv1.UserInfo{Username: "u-abcde"},
+		},
+		{
+			name:     "rancher user",
+			userInfo: authenticationv1.UserInfo{Username: "u-abcde", Groups: []string{"system:authenticated"}, Extra: rancherExtras},
+		},
+		{
+			name:             "controller without impersonation context",
+			userInfo:         authenticationv1.UserInfo{Username: "admin", Groups: []string{"system:masters"}},
+			wantIsController: true,
+		},
+		{
+			name:     "system:masters member",
+			userInfo: authenticationv1.UserInfo{Username: "admin", Groups: []string{"system:masters"}, Extra: rancherExtras},
+			wantErr:  true,
+		},
+		{
+			name:     "service account",
+			userInfo: authenticationv1.UserInfo{Username: "system:serviceaccount:cattle-system:rancher", Extra: rancherExtras},
+			wantErr:  true,
+		},
+		{
+			name:     "system user",
+			userInfo: authenticationv1.UserInfo{Username: "system:kube-controller-manager", Extra: rancherExtras},
+			wantErr:  true,
+		},
+		{
+			name: "repeated extras",
+			userInfo: authenticationv1.UserInfo{Username: "u-abcde", Extra: map[string]authenticationv1.ExtraValue{
+				admission.ImpersonationExtraUsername: {"alice", "admin"},
+			}},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			request := &admission.Request{AdmissionRequest: admissionv1.AdmissionRequest{UserInfo: tt.userInfo}}
+			err := admission.ValidateImpersonation(request)
+			if tt.wantErr {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+			assert.Equal(t, tt.wantIsController, admission.IsController(request), "impersonated requests are never made by controllers")
+		})
+	}
+}
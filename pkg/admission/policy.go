@@ -0,0 +1,81 @@
+package admission
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"sync/atomic"
+
+	admissionv1 "k8s.io/api/admission/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	sigsjson "sigs.k8s.io/json"
+)
+
+// PolicyVersionEnv is the environment variable used to opt in to stricter admission policies.
+const PolicyVersionEnv = "CATTLE_WEBHOOK_POLICY_VERSION"
+
+// PolicyVersion gates admission behavior which may reject requests that were previously accepted.
+// Each version includes the behavior of all previous versions.
+type PolicyVersion int32
+
+const (
+	// PolicyVersion1 is the default policy version.
+	PolicyVersion1 PolicyVersion = 1
+	// PolicyVersion2 rejects unknown fields on security-critical resources.
+	PolicyVersion2 PolicyVersion = 2
+	// PolicyVersion3 requires confirmation to widen the permissions of RoleTemplates which inherit builtin RoleTemplates.
+	PolicyVersion3 PolicyVersion = 3
+
+	latestPolicyVersion = PolicyVersion3
+)
+
+var currentPolicyVersion atomic.Int32
+
+func init() {
+	currentPolicyVersion.Store(int32(PolicyVersion1))
+}
+
+// CurrentPolicyVersion returns the policy version used by the webhook.
+func CurrentPolicyVersion() PolicyVersion {
+	return PolicyVersion(currentPolicyVersion.Load())
+}
+
+// SetPolicyVersion sets the policy version used by the webhook.
+func SetPolicyVersion(version PolicyVersion) {
+	currentPolicyVersion.Store(int32(version))
+}
+
+// PolicyVersionFromEnv returns the policy version configured through PolicyVersionEnv, or PolicyVersion1 if unset.
+func PolicyVersionFromEnv() (PolicyVersion, error) {
+	value := os.Getenv(PolicyVersionEnv)
+	if value == "" {
+		return PolicyVersion1, nil
+	}
+	version, err := strconv.Atoi(value)
+	if err != nil || version < int(PolicyVersion1) || version > int(latestPolicyVersion) {
+		return PolicyVersion1, fmt.Errorf("invalid value '%s' for %s: must be between %d and %d", value, PolicyVersionEnv, PolicyVersion1, latestPolicyVersion)
+	}
+	return PolicyVersion(version), nil
+}
+
+// DisallowUnknownFields rejects Create and Update requests whose object contains fields unknown to obj's type, when the
+// current policy version is at least PolicyVersion2. obj should be a pointer to an empty object of the request's type.
+// Objects being deleted are not checked so that finalizers can always be removed.
+// A nil response is returned if the request should continue to be validated.
+func DisallowUnknownFields(request *Request, obj metav1.Object) (*admissionv1.AdmissionResponse, error) {
+	if CurrentPolicyVersion() < PolicyVersion2 {
+		return nil, nil
+	}
+	if request.Operation != admissionv1.Create && request.Operation != admissionv1.Update {
+		return nil, nil
+	}
+	strictErrs, err := sigsjson.UnmarshalStrict(request.Object.Raw, obj, sigsjson.DisallowUnknownFields)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode request object: %w", err)
+	}
+	if len(strictErrs) > 0 && obj.GetDeletionTimestamp() == nil {
+		return ResponseBadRequest(errors.Join(strictErrs...).Error()), nil
+	}
+	return nil, nil
+}
@@ -0,0 +1,117 @@
+package admission_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/rancher/webhook/pkg/admission"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	admissionv1 "k8s.io/api/admission/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+func TestPolicyVersionFromEnv(t *testing.T) {
+	tests := []struct {
+		name    string
+		value   string
+		want    admission.PolicyVersion
+		wantErr bool
+	}{
+		{name: "unset", want: admission.PolicyVersion1},
+		{name: "version 1", value: "1", want: admission.PolicyVersion1},
+		{name: "version 2", value: "2", want: admission.PolicyVersion2},
+		{name: "version 3", value: "3", want: admission.PolicyVersion3},
+		{name: "unknown version", value: "4", wantErr: true},
+		{name: "not a number", value: "latest", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv(admission.PolicyVersionEnv, tt.value)
+			got, err := admission.PolicyVersionFromEnv()
+			if tt.wantErr {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestDisallowUnknownFields(t *testing.T) {
+	const (
+		knownFields   = `{"metadata":{"name":"test"},"data":{"key":"value"}}`
+		unknownFields = `{"metadata":{"name":"test"},"dataa":{"key":"value"}}`
+		beingDeleted  = `{"metadata":{"name":"test","deletionTimestamp":"2024-01-01T00:00:00Z"},"dataa":{"key":"value"}}`
+	)
+	tests := []struct {
+		name          string
+		policyVersion admission.PolicyVersion
+		operation     admissionv1.Operation
+		object        string
+		wantDenied    bool
+	}{
+		{
+			name:          "unknown fields allowed with policy version 1",
+			policyVersion: admission.PolicyVersion1,
+			operation:     admissionv1.Create,
+			object:        unknownFields,
+		},
+		{
+			name:          "known fields allowed with policy version 2",
+			policyVersion: admission.PolicyVersion2,
+			operation:     admissionv1.Create,
+			object:        knownFields,
+		},
+		{
+			name:          "unknown fields denied on create with policy version 2",
+			policyVersion: admission.PolicyVersion2,
+			operation:     admissionv1.Create,
+			object:        unknownFields,
+			wantDenied:    true,
+		},
+		{
+			name:          "unknown fields denied on update with policy version 2",
+			policyVersion: admission.PolicyVersion2,
+			operation:     admissionv1.Update,
+			object:        unknownFields,
+			wantDenied:    true,
+		},
+		{
+			name:          "delete is not checked",
+			policyVersion: admission.PolicyVersion2,
+			operation:     admissionv1.Delete,
+		},
+		{
+			name:          "objects being deleted are not checked",
+			policyVersion: admission.PolicyVersion2,
+			operation:     admissionv1.Update,
+			object:        beingDeleted,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			admission.SetPolicyVersion(tt.policyVersion)
+			t.Cleanup(func() { admission.SetPolicyVersion(admission.PolicyVersion1) })
+
+			request := &admission.Request{
+				AdmissionRequest: admissionv1.AdmissionRequest{
+					Operation: tt.operation,
+					Object:    runtime.RawExtension{Raw: []byte(tt.object)},
+				},
+				Context: context.Background(),
+			}
+			response, err := admission.DisallowUnknownFields(request, &corev1.ConfigMap{})
+			require.NoError(t, err)
+			if !tt.wantDenied {
+				assert.Nil(t, response)
+				return
+			}
+			require.NotNil(t, response)
+			assert.False(t, response.Allowed)
+			assert.Contains(t, response.Result.Message, `unknown field "dataa"`)
+		})
+	}
+}
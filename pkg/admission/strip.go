@@ -0,0 +1,130 @@
+package admission
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"sync"
+	"sync/atomic"
+
+	admissionv1 "k8s.io/api/admission/v1"
+)
+
+// StripManagedFieldsEnv is the environment variable enabling the stripping of the metadata no handler reads from the
+// objects of admission requests when "true".
+const StripManagedFieldsEnv = "CATTLE_WEBHOOK_STRIP_MANAGED_FIELDS"
+
+// lastAppliedConfigAnnotation holds a full copy of objects managed with kubectl apply.
+const lastAppliedConfigAnnotation = "kubectl.kubernetes.io/last-applied-configuration"
+
+// maxPooledBufferBytes is the capacity above which the buffers used to read admission requests are not reused, so that
+// a few large requests don't keep their memory alive.
+const maxPooledBufferBytes = 4 << 20
+
+var (
+	stripManagedFields atomic.Bool
+
+	managedFieldsKey = []byte(`"managedFields"`)
+	lastAppliedKey   = []byte(`"` + lastAppliedConfigAnnotation + `"`)
+
+	bufferPool = sync.Pool{New: func() any { return new(bytes.Buffer) }}
+)
+
+// SetStripManagedFields sets whether the managedFields of the objects of admission requests are removed before the
+// requests are admitted.
+func SetStripManagedFields(enabled bool) {
+	stripManagedFields.Store(enabled)
+}
+
+// StripManagedFieldsFromEnv returns whether StripManagedFieldsEnv enables the stripping of managedFields, which is
+// disabled if unset.
+func StripManagedFieldsFromEnv() (bool, error) {
+	value := os.Getenv(StripManagedFieldsEnv)
+	if value == "" {
+		return false, nil
+	}
+	enabled, err := strconv.ParseBool(value)
+	if err != nil {
+		return false, fmt.Errorf("invalid value '%s' for %s: must be a boolean", value, StripManagedFieldsEnv)
+	}
+	return enabled, nil
+}
+
+// decodeReview decodes the AdmissionReview of the body into a pooled buffer. The buffer can be reused as soon as the
+// review is decoded, since the raw objects of the review are copies.
+func decodeReview(body io.Reader, review *admissionv1.AdmissionReview) error {
+	buf := bufferPool.Get().(*bytes.Buffer)
+	defer func() {
+		if buf.Cap() <= maxPooledBufferBytes {
+			buf.Reset()
+			bufferPool.Put(buf)
+		}
+	}()
+	if _, err := buf.ReadFrom(body); err != nil {
+		return err
+	}
+	return json.Unmarshal(buf.Bytes(), review)
+}
+
+// stripRequest removes the managedFields of the objects of the request when enabled by SetStripManagedFields, so that
+// the handlers don't decode them. The last-applied-configuration annotation is also removed when annotations is true,
+// which must only be the case for validating handlers: the patches of mutating handlers would otherwise remove it from
+// the objects when they replace their annotations. Objects which can't be decoded are left as they are, for the
+// handlers to report.
+func stripRequest(request *admissionv1.AdmissionRequest, annotations bool) {
+	if !stripManagedFields.Load() {
+		return
+	}
+	request.Object.Raw = stripMetadata(request.Object.Raw, annotations)
+	request.OldObject.Raw = stripMetadata(request.OldObject.Raw, annotations)
+}
+
+// stripMetadata returns the raw object without its managedFields, and without its last-applied-configuration annotation
+// if annotations is true. Only the top level of the object and of its metadata are decoded.
+func stripMetadata(raw []byte, annotations bool) []byte {
+	hasManagedFields := bytes.Contains(raw, managedFieldsKey)
+	hasLastApplied := annotations && bytes.Contains(raw, lastAppliedKey)
+	if !hasManagedFields && !hasLastApplied {
+		return raw
+	}
+	var object map[string]json.RawMessage
+	if err := json.Unmarshal(raw, &object); err != nil {
+		return raw
+	}
+	var metadata map[string]json.RawMessage
+	if err := json.Unmarshal(object["metadata"], &metadata); err != nil {
+		return raw
+	}
+	changed := false
+	if _, ok := metadata["managedFields"]; ok {
+		delete(metadata, "managedFields")
+		changed = true
+	}
+	if hasLastApplied {
+		var objectAnnotations map[string]json.RawMessage
+		if err := json.Unmarshal(metadata["annotations"], &objectAnnotations); err == nil {
+			if _, ok := objectAnnotations[lastAppliedConfigAnnotation]; ok {
+				delete(objectAnnotations, lastAppliedConfigAnnotation)
+				if metadata["annotations"], err = json.Marshal(objectAnnotations); err != nil {
+					return raw
+				}
+				changed = true
+			}
+		}
+	}
+	if !changed {
+		return raw
+	}
+	var err error
+	if object["metadata"], err = json.Marshal(metadata); err != nil {
+		return raw
+	}
+	stripped, err := json.Marshal(object)
+	if err != nil {
+		return raw
+	}
+	return stripped
+}
@@ -0,0 +1,188 @@
+package admission_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/rancher/webhook/pkg/admission"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	admissionv1 "k8s.io/api/admission/v1"
+	v1 "k8s.io/api/admissionregistration/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+const lastAppliedConfigAnnotation = "kubectl.kubernetes.io/last-applied-configuration"
+
+var stripGVR = schema.GroupVersionResource{Group: "test.cattle.io", Version: "v1alpha1", Resource: "resources"}
+
+// recordingHandler decodes the objects of the requests it admits, and records the last ones.
+type recordingHandler struct {
+	object    *unstructured.Unstructured
+	oldObject *unstructured.Unstructured
+}
+
+func (r *recordingHandler) GVR() schema.GroupVersionResource { return stripGVR }
+
+func (r *recordingHandler) Operations() []v1.OperationType {
+	return []v1.OperationType{v1.Update}
+}
+
+func (r *recordingHandler) ValidatingWebhook(_ v1.WebhookClientConfig) []v1.ValidatingWebhook {
+	return nil
+}
+
+func (r *recordingHandler) MutatingWebhook(_ v1.WebhookClientConfig) []v1.MutatingWebhook {
+	return nil
+}
+
+func (r *recordingHandler) Admitters() []admission.Admitter {
+	return []admission.Admitter{r}
+}
+
+func (r *recordingHandler) Admit(request *admission.Request) (*admissionv1.AdmissionResponse, error) {
+	r.object, r.oldObject = &unstructured.Unstructured{}, &unstructured.Unstructured{}
+	if err := json.Unmarshal(request.Object.Raw, r.object); err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(request.OldObject.Raw, r.oldObject); err != nil {
+		return nil, err
+	}
+	return admission.ResponseAllowed(), nil
+}
+
+// newStripReview returns an AdmissionReview updating an object with the given number of managedFields entries.
+func newStripReview(tb testing.TB, managedFields int) []byte {
+	tb.Helper()
+	object := &unstructured.Unstructured{}
+	object.SetAPIVersion("test.cattle.io/v1alpha1")
+	object.SetKind("Resource")
+	object.SetName("test")
+	object.SetAnnotations(map[string]string{lastAppliedConfigAnnotation: `{"spec":{}}`, "team": "a"})
+	var entries []metav1.ManagedFieldsEntry
+	for i := 0; i < managedFields; i++ {
+		entries = append(entries, metav1.ManagedFieldsEntry{
+			Manager:    fmt.Sprintf("manager-%d", i),
+			Operation:  metav1.ManagedFieldsOperationUpdate,
+			APIVersion: "test.cattle.io/v1alpha1",
+			FieldsType: "FieldsV1",
+			FieldsV1:   &metav1.FieldsV1{Raw: []byte(fmt.Sprintf(`{"f:spec":{"f:field-%d":{}}}`, i))},
+		})
+	}
+	object.SetManagedFields(entries)
+	require.NoError(tb, unstructured.SetNestedField(object.Object, "value", "spec", "field"))
+	raw, err := json.Marshal(object)
+	require.NoError(tb, err)
+
+	request := defaultRequest()
+	request.Operation = admissionv1.Update
+	request.Object = runtime.RawExtension{Raw: raw}
+	request.OldObject = runtime.RawExtension{Raw: raw}
+	review, err := json.Marshal(&admissionv1.AdmissionReview{Request: request})
+	require.NoError(tb, err)
+	return review
+}
+
+func TestStripManagedFieldsFromEnv(t *testing.T) {
+	tests := []struct {
+		name    string
+		value   string
+		want    bool
+		wantErr bool
+	}{
+		{name: "unset"},
+		{name: "enabled", value: "true", want: true},
+		{name: "disabled", value: "false"},
+		{name: "invalid", value: "yes please", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv(admission.StripManagedFieldsEnv, tt.value)
+			got, err := admission.StripManagedFieldsFromEnv()
+			if tt.wantErr {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestStripManagedFields(t *testing.T) {
+	t.Cleanup(func() { admission.SetStripManagedFields(false) })
+
+	tests := []struct {
+		name              string
+		enabled           bool
+		mutating          bool
+		wantManagedFields bool
+		wantLastApplied   bool
+	}{
+		{
+			name:              "disabled",
+			wantManagedFields: true,
+			wantLastApplied:   true,
+		},
+		{
+			name:    "validating handler",
+			enabled: true,
+		},
+		{
+			name:            "mutating handler keeps the annotations",
+			enabled:         true,
+			mutating:        true,
+			wantLastApplied: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			admission.SetStripManagedFields(tt.enabled)
+			handler := &recordingHandler{}
+			handlerFunc := admission.NewValidatingHandlerFunc(handler)
+			if tt.mutating {
+				handlerFunc = admission.NewMutatingHandlerFunc(handler)
+			}
+			recorder := httptest.NewRecorder()
+			handlerFunc(recorder, httptest.NewRequest("POST", "/", bytes.NewReader(newStripReview(t, 3))))
+
+			review := admissionv1.AdmissionReview{}
+			require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &review))
+			require.True(t, review.Response.Allowed, review.Response.Result)
+			for _, obj := range []*unstructured.Unstructured{handler.object, handler.oldObject} {
+				assert.Equal(t, tt.wantManagedFields, len(obj.GetManagedFields()) > 0)
+				_, ok := obj.GetAnnotations()[lastAppliedConfigAnnotation]
+				assert.Equal(t, tt.wantLastApplied, ok)
+				assert.Equal(t, "a", obj.GetAnnotations()["team"])
+				assert.Equal(t, "test", obj.GetName())
+				value, _, _ := unstructured.NestedString(obj.Object, "spec", "field")
+				assert.Equal(t, "value", value)
+			}
+		})
+	}
+}
+
+// BenchmarkStripManagedFields compares admitting requests whose objects have large managedFields with and without
+// stripping them.
+func BenchmarkStripManagedFields(b *testing.B) {
+	b.Cleanup(func() { admission.SetStripManagedFields(false) })
+	for _, managedFields := range []int{1, 100} {
+		review := newStripReview(b, managedFields)
+		for _, enabled := range []bool{false, true} {
+			b.Run(fmt.Sprintf("managedFields=%d/strip=%t", managedFields, enabled), func(b *testing.B) {
+				admission.SetStripManagedFields(enabled)
+				handlerFunc := admission.NewValidatingHandlerFunc(&recordingHandler{})
+				b.ReportAllocs()
+				for i := 0; i < b.N; i++ {
+					handlerFunc(httptest.NewRecorder(), httptest.NewRequest("POST", "/", bytes.NewReader(review)))
+				}
+			})
+		}
+	}
+}
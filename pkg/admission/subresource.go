@@ -0,0 +1,83 @@
+package admission
+
+import (
+	"fmt"
+	"slices"
+
+	admissionv1 "k8s.io/api/admission/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// SubresourceValidator is implemented by ValidatingAdmissionHandlers which also review writes to subresources of the
+// resource they validate, such as "status" or "finalize". The webhooks created by NewDefaultValidatingWebhook for
+// these handlers include the subresources, and writes to them are only allowed for controllers (see IsController).
+// The handler's admitters are not called for subresource requests.
+type SubresourceValidator interface {
+	// Subresources returns the names of the subresources whose writes are reviewed.
+	Subresources() []string
+}
+
+// ConnectValidator is implemented by ValidatingAdmissionHandlers which review CONNECT requests to subresources of the
+// resource they validate, such as "proxy" or "exec". The webhooks created by NewDefaultValidatingWebhook for these
+// handlers include the subresources. Unlike other subresource requests, CONNECT requests are reviewed by the handler's
+// admitters, so the handler's operations must include CONNECT.
+type ConnectValidator interface {
+	// ConnectSubresources returns the names of the subresources whose CONNECT requests are reviewed.
+	ConnectSubresources() []string
+}
+
+// controllerNamespaces are the namespaces whose service accounts are trusted to write to guarded subresources.
+var controllerNamespaces = []string{
+	"kube-system",
+	"cattle-system",
+	"cattle-fleet-system",
+	"cattle-fleet-local-system",
+}
+
+// controllerUsers are the users trusted to write to guarded subresources.
+var controllerUsers = []string{
+	"system:kube-controller-manager",
+}
+
+// IsController returns true if the request was made by a Kubernetes or Rancher controller: a member of the
+// system:masters group, one of the controllerUsers, or a service account of one of the controllerNamespaces verified
+// with IsServiceAccountOfNamespace. Requests carrying the impersonation context of a Rancher user are never made by a
+// controller.
+func IsController(request *Request) bool {
+	if request.Impersonation() != nil {
+		return false
+	}
+	for _, user := range controllerUsers {
+		if request.UserInfo.Username == user {
+			return true
+		}
+	}
+	for _, group := range request.UserInfo.Groups {
+		if group == systemMasters {
+			return true
+		}
+		if namespace, ok := serviceAccountNamespace(group); ok && slices.Contains(controllerNamespaces, namespace) &&
+			IsServiceAccountOfNamespace(request.UserInfo, namespace) {
+			return true
+		}
+	}
+	return false
+}
+
+// subresourceResources returns the webhook rule resources for the subresources of the given resource.
+func subresourceResources(resource string, subresources []string) []string {
+	resources := make([]string, 0, len(subresources))
+	for _, subresource := range subresources {
+		resources = append(resources, resource+"/"+subresource)
+	}
+	return resources
+}
+
+// admitSubresource only allows controllers to write to subresources.
+func admitSubresource(request *Request) *admissionv1.AdmissionResponse {
+	if IsController(request) {
+		return ResponseAllowed()
+	}
+	groupResource := schema.GroupResource{Group: request.Resource.Group, Resource: request.Resource.Resource}
+	return ResponseFailedEscalation(fmt.Sprintf("only controllers can write to the %s subresource of %s", request.SubResource, groupResource))
+}
@@ -0,0 +1,155 @@
+package admission_test
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/rancher/webhook/pkg/admission"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	admissionv1 "k8s.io/api/admission/v1"
+	v1 "k8s.io/api/admissionregistration/v1"
+	authenticationv1 "k8s.io/api/authentication/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+type fakeSubresourceValidator struct {
+	fakeValidatingAdmissionHandler
+	subresources []string
+}
+
+func (f *fakeSubresourceValidator) Subresources() []string {
+	return f.subresources
+}
+
+func newFakeSubresourceValidator() *fakeSubresourceValidator {
+	return &fakeSubresourceValidator{
+		fakeValidatingAdmissionHandler: fakeValidatingAdmissionHandler{
+			gvr:        schema.GroupVersionResource{Group: "test.cattle.io", Version: "v1alpha1", Resource: "resources"},
+			operations: []v1.OperationType{v1.Update},
+			admitters:  []fakeAdmitter{setupAdmitter(&handlerResponse{hasAllow: false})},
+		},
+		subresources: []string{"status"},
+	}
+}
+
+func TestNewDefaultValidatingWebhookSubresources(t *testing.T) {
+	t.Parallel()
+	handler := newFakeSubresourceValidator()
+	webhook := admission.NewDefaultValidatingWebhook(handler, v1.WebhookClientConfig{}, v1.ClusterScope, handler.Operations())
+	require.Len(t, webhook.Rules, 1)
+	assert.Equal(t, []string{"resources", "resources/status"}, webhook.Rules[0].Resources)
+
+	plain := admission.NewDefaultValidatingWebhook(&handler.fakeValidatingAdmissionHandler, v1.WebhookClientConfig{}, v1.ClusterScope, handler.Operations())
+	assert.Equal(t, []string{"resources"}, plain.Rules[0].Resources)
+}
+
+func TestSubresourceWrites(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name        string
+		subresource string
+		userInfo    authenticationv1.UserInfo
+		wantAllowed bool
+	}{
+		{
+			name:        "non-controller write to status",
+			subresource: "status",
+			userInfo:    authenticationv1.UserInfo{Username: "user", Groups: []string{"system:authenticated"}},
+		},
+		{
+			name:        "rancher write to status",
+			subresource: "status",
+			userInfo: authenticationv1.UserInfo{
+				Username: "system:serviceaccount:cattle-system:rancher",
+				Groups:   []string{"system:serviceaccounts", "system:serviceaccounts:cattle-system", "system:authenticated"},
+			},
+			wantAllowed: true,
+		},
+		{
+			name:        "kube-controller-manager write to status",
+			subresource: "status",
+			userInfo:    authenticationv1.UserInfo{Username: "system:kube-controller-manager"},
+			wantAllowed: true,
+		},
+		{
+			name:        "write to the main resource is sent to the admitters",
+			subresource: "",
+			userInfo: authenticationv1.UserInfo{
+				Username: "system:serviceaccount:cattle-system:rancher",
+				Groups:   []string{"system:serviceaccounts:cattle-system"},
+			},
+			wantAllowed: false,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			review := admissionv1.AdmissionReview{
+				Request: &admissionv1.AdmissionRequest{
+					UID:         "1",
+					Operation:   admissionv1.Update,
+					Kind:        metav1.GroupVersionKind{Group: "test.cattle.io", Version: "v1alpha1", Kind: "Resource"},
+					Resource:    metav1.GroupVersionResource{Group: "test.cattle.io", Version: "v1alpha1", Resource: "resources"},
+					SubResource: tt.subresource,
+					Name:        "test",
+					UserInfo:    tt.userInfo,
+				},
+			}
+			body, err := json.Marshal(review)
+			require.NoError(t, err)
+			recorder := httptest.NewRecorder()
+			admission.NewValidatingHandlerFunc(newFakeSubresourceValidator())(recorder, httptest.NewRequest("get", "/testEndpoint", strings.NewReader(string(body))))
+
+			got := admissionv1.AdmissionReview{}
+			require.NoError(t, json.NewDecoder(recorder.Result().Body).Decode(&got))
+			assert.Equal(t, tt.wantAllowed, got.Response.Allowed)
+		})
+	}
+}
+
+type fakeConnectValidator struct {
+	fakeValidatingAdmissionHandler
+}
+
+func (f *fakeConnectValidator) ConnectSubresources() []string {
+	return []string{"proxy"}
+}
+
+func TestConnectValidator(t *testing.T) {
+	t.Parallel()
+	handler := &fakeConnectValidator{
+		fakeValidatingAdmissionHandler: fakeValidatingAdmissionHandler{
+			gvr:        schema.GroupVersionResource{Version: "v1", Resource: "nodes"},
+			operations: []v1.OperationType{v1.Connect},
+			admitters:  []fakeAdmitter{setupAdmitter(&handlerResponse{hasAllow: true})},
+		},
+	}
+	webhook := admission.NewDefaultValidatingWebhook(handler, v1.WebhookClientConfig{}, v1.ClusterScope, handler.Operations())
+	require.Len(t, webhook.Rules, 1)
+	assert.Equal(t, []string{"nodes", "nodes/proxy"}, webhook.Rules[0].Resources)
+
+	// CONNECT requests are sent to the admitters instead of being limited to controllers
+	review := admissionv1.AdmissionReview{
+		Request: &admissionv1.AdmissionRequest{
+			UID:         "1",
+			Operation:   admissionv1.Connect,
+			Kind:        metav1.GroupVersionKind{Version: "v1", Kind: "NodeProxyOptions"},
+			Resource:    metav1.GroupVersionResource{Version: "v1", Resource: "nodes"},
+			SubResource: "proxy",
+			Name:        "node-1",
+			UserInfo:    authenticationv1.UserInfo{Username: "user"},
+		},
+	}
+	body, err := json.Marshal(review)
+	require.NoError(t, err)
+	recorder := httptest.NewRecorder()
+	admission.NewValidatingHandlerFunc(handler)(recorder, httptest.NewRequest("get", "/testEndpoint", strings.NewReader(string(body))))
+	got := admissionv1.AdmissionReview{}
+	require.NoError(t, json.NewDecoder(recorder.Result().Body).Decode(&got))
+	assert.True(t, got.Response.Allowed)
+}
@@ -0,0 +1,87 @@
+package admission
+
+import (
+	"fmt"
+	"net/http"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+	admissionv1 "k8s.io/api/admission/v1"
+)
+
+// tracerName is the name of the tracer creating the spans of admission requests.
+const tracerName = "github.com/rancher/webhook/pkg/admission"
+
+// startReviewSpan starts the span of an AdmissionReview sent to the handler. The returned request carries the span in
+// its context. Without a configured tracer provider the span is a no-op.
+func startReviewSpan(req *http.Request, webhookType string, handler WebhookHandler) (*http.Request, trace.Span) {
+	gvr := handler.GVR()
+	ctx, span := otel.Tracer(tracerName).Start(req.Context(), fmt.Sprintf("%s %s", webhookType, SubPath(gvr)),
+		trace.WithSpanKind(trace.SpanKindServer),
+		trace.WithAttributes(
+			attribute.String("webhook.type", webhookType),
+			attribute.String("webhook.group", gvr.Group),
+			attribute.String("webhook.version", gvr.Version),
+			attribute.String("webhook.resource", gvr.Resource),
+		))
+	return req.WithContext(ctx), span
+}
+
+// endReviewSpan records the request and decision of the review on the span and ends it.
+func endReviewSpan(span trace.Span, review *admissionv1.AdmissionReview) {
+	defer span.End()
+	if review == nil || review.Request == nil {
+		span.SetStatus(codes.Error, "invalid admission review")
+		return
+	}
+	span.SetAttributes(
+		attribute.String("admission.uid", string(review.Request.UID)),
+		attribute.String("admission.operation", string(review.Request.Operation)),
+		attribute.String("admission.namespace", review.Request.Namespace),
+		attribute.String("admission.name", review.Request.Name),
+		attribute.String("admission.subresource", review.Request.SubResource),
+		attribute.String("admission.user", review.Request.UserInfo.Username),
+	)
+	recordResponse(span, review.Response, nil)
+}
+
+// admitWithSpan calls the admitter within a child span of the request's span, so that the external calls made by the
+// admitter with the request's context are recorded under it.
+func admitWithSpan(admitter Admitter, request *Request) (*admissionv1.AdmissionResponse, error) {
+	return admitWithNamedSpan(fmt.Sprintf("%T.Admit", admitter), admitter, request)
+}
+
+// admitWithNamedSpan calls the admitter within a child span with the given name.
+func admitWithNamedSpan(name string, admitter Admitter, request *Request) (*admissionv1.AdmissionResponse, error) {
+	ctx, span := otel.Tracer(tracerName).Start(request.Context, name)
+	defer span.End()
+
+	parent := request.Context
+	request.Context = ctx
+	defer func() { request.Context = parent }()
+
+	response, err := admitter.Admit(request)
+	recordResponse(span, response, err)
+	return response, err
+}
+
+// recordResponse records whether the response allowed the request, or the error, on the span.
+func recordResponse(span trace.Span, response *admissionv1.AdmissionResponse, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return
+	}
+	if response == nil {
+		return
+	}
+	span.SetAttributes(attribute.Bool("admission.allowed", response.Allowed))
+	if response.Result != nil {
+		span.SetAttributes(attribute.Int("admission.code", int(response.Result.Code)))
+		if response.Result.Code == http.StatusInternalServerError {
+			span.SetStatus(codes.Error, response.Result.Message)
+		}
+	}
+}
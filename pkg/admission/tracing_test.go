@@ -0,0 +1,53 @@
+package admission_test
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/rancher/webhook/pkg/admission"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	admissionv1 "k8s.io/api/admission/v1"
+	v1 "k8s.io/api/admissionregistration/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+func TestAdmissionSpans(t *testing.T) {
+	// the tracer provider is global, so this test can't run in parallel with other tests setting it
+	recorder := tracetest.NewSpanRecorder()
+	previous := otel.GetTracerProvider()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+	defer otel.SetTracerProvider(previous)
+
+	handler := fakeValidatingAdmissionHandler{
+		gvr:        schema.GroupVersionResource{Group: "test.cattle.io", Version: "v1alpha1", Resource: "resources"},
+		operations: []v1.OperationType{v1.Create},
+		admitters: []fakeAdmitter{
+			setupAdmitter(&handlerResponse{hasAllow: true}),
+			setupAdmitter(&handlerResponse{hasAllow: false}),
+		},
+	}
+	body, err := json.Marshal(admissionv1.AdmissionReview{Request: defaultRequest()})
+	require.NoError(t, err)
+	admission.NewValidatingHandlerFunc(&handler)(httptest.NewRecorder(), httptest.NewRequest("POST", "/testEndpoint", strings.NewReader(string(body))))
+
+	spans := recorder.Ended()
+	require.Len(t, spans, 3, "expected a span for the review and one for each admitter")
+	review := spans[2]
+	assert.Equal(t, "validating resources.test.cattle.io", review.Name())
+	assert.Contains(t, review.Attributes(), attribute.String("admission.operation", "CREATE"))
+	assert.Contains(t, review.Attributes(), attribute.String("admission.name", "test"))
+	assert.Contains(t, review.Attributes(), attribute.Bool("admission.allowed", false))
+	for _, admitter := range spans[:2] {
+		assert.Equal(t, "*admission_test.fakeAdmitter.Admit", admitter.Name())
+		assert.Equal(t, review.SpanContext().SpanID(), admitter.Parent().SpanID())
+	}
+	assert.Contains(t, spans[0].Attributes(), attribute.Bool("admission.allowed", true))
+	assert.Contains(t, spans[1].Attributes(), attribute.Bool("admission.allowed", false))
+}
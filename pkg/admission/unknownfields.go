@@ -0,0 +1,97 @@
+package admission
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	admissionv1 "k8s.io/api/admission/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	sigsjson "sigs.k8s.io/json"
+)
+
+// UnknownFieldsEnv is the environment variable setting how objects with fields unknown to the webhook are admitted.
+const UnknownFieldsEnv = "CATTLE_WEBHOOK_UNKNOWN_FIELDS"
+
+// UnknownFieldsMode is how objects with fields unknown to the webhook are admitted. Such fields were usually added by a
+// newer version of Rancher than the one the webhook was built for, so the webhook can't validate them.
+type UnknownFieldsMode string
+
+const (
+	// UnknownFieldsIgnore admits objects with unknown fields. It is the default.
+	UnknownFieldsIgnore UnknownFieldsMode = "ignore"
+	// UnknownFieldsWarn admits objects with unknown fields with a warning listing them.
+	UnknownFieldsWarn UnknownFieldsMode = "warn"
+	// UnknownFieldsDeny denies objects with unknown fields.
+	UnknownFieldsDeny UnknownFieldsMode = "deny"
+)
+
+// UnknownFieldsModeFromEnv returns the UnknownFieldsMode configured through UnknownFieldsEnv, or UnknownFieldsIgnore if
+// unset.
+func UnknownFieldsModeFromEnv() (UnknownFieldsMode, error) {
+	switch mode := UnknownFieldsMode(os.Getenv(UnknownFieldsEnv)); mode {
+	case "":
+		return UnknownFieldsIgnore, nil
+	case UnknownFieldsIgnore, UnknownFieldsWarn, UnknownFieldsDeny:
+		return mode, nil
+	default:
+		return UnknownFieldsIgnore, fmt.Errorf("invalid value '%s' for %s: must be %s, %s or %s", mode, UnknownFieldsEnv,
+			UnknownFieldsIgnore, UnknownFieldsWarn, UnknownFieldsDeny)
+	}
+}
+
+// WithUnknownFieldsCheck adds a check of the fields of the created and updated objects to the handlers, warning on or
+// denying the objects with fields unknown to their type in the scheme, depending on the mode. Objects of kinds which
+// aren't in the scheme are not checked. The handlers are returned as they are with UnknownFieldsIgnore.
+func WithUnknownFieldsCheck(handlers []ValidatingAdmissionHandler, mode UnknownFieldsMode, scheme *runtime.Scheme) []ValidatingAdmissionHandler {
+	if mode == UnknownFieldsIgnore || mode == "" {
+		return handlers
+	}
+	checker := &unknownFieldsChecker{mode: mode, scheme: scheme}
+	checked := make([]ValidatingAdmissionHandler, 0, len(handlers))
+	for _, handler := range handlers {
+		checked = append(checked, WithAdmitters(handler, AdmitterFunc(checker.admit)))
+	}
+	return checked
+}
+
+type unknownFieldsChecker struct {
+	mode   UnknownFieldsMode
+	scheme *runtime.Scheme
+}
+
+func (c *unknownFieldsChecker) admit(request *Request) (*admissionv1.AdmissionResponse, error) {
+	if request.Operation != admissionv1.Create && request.Operation != admissionv1.Update || request.SubResource != "" {
+		return ResponseAllowed(), nil
+	}
+	gvk := schema.GroupVersionKind(request.Kind)
+	obj, err := c.scheme.New(gvk)
+	if runtime.IsNotRegisteredError(err) {
+		return ResponseAllowed(), nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to create object of kind %s: %w", gvk, err)
+	}
+	strictErrs, err := sigsjson.UnmarshalStrict(request.Object.Raw, obj, sigsjson.DisallowUnknownFields)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode request object: %w", err)
+	}
+	if len(strictErrs) == 0 {
+		return ResponseAllowed(), nil
+	}
+	// objects being deleted are not checked so that finalizers can always be removed
+	if metaObj, ok := obj.(metav1.Object); ok && metaObj.GetDeletionTimestamp() != nil {
+		return ResponseAllowed(), nil
+	}
+
+	messages := make([]string, 0, len(strictErrs))
+	for _, strictErr := range strictErrs {
+		messages = append(messages, fmt.Sprintf("%v of %s %s can't be validated by webhook version %s", strictErr, gvk.Kind, request.Name, WebhookVersion()))
+	}
+	if c.mode == UnknownFieldsDeny {
+		return ResponseBadRequest(strings.Join(messages, "; ")), nil
+	}
+	return ResponseAllowedWithWarnings(messages...), nil
+}
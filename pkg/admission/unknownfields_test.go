@@ -0,0 +1,151 @@
+package admission_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/rancher/webhook/pkg/admission"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	admissionv1 "k8s.io/api/admission/v1"
+	v1 "k8s.io/api/admissionregistration/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+func TestUnknownFieldsModeFromEnv(t *testing.T) {
+	tests := []struct {
+		name    string
+		value   string
+		want    admission.UnknownFieldsMode
+		wantErr bool
+	}{
+		{name: "unset", want: admission.UnknownFieldsIgnore},
+		{name: "ignore", value: "ignore", want: admission.UnknownFieldsIgnore},
+		{name: "warn", value: "warn", want: admission.UnknownFieldsWarn},
+		{name: "deny", value: "deny", want: admission.UnknownFieldsDeny},
+		{name: "unknown mode", value: "reject", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv(admission.UnknownFieldsEnv, tt.value)
+			got, err := admission.UnknownFieldsModeFromEnv()
+			if tt.wantErr {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestWithUnknownFieldsCheck(t *testing.T) {
+	t.Parallel()
+	const (
+		knownFields   = `{"metadata":{"name":"test"},"data":{"key":"value"}}`
+		unknownFields = `{"metadata":{"name":"test"},"dataa":{"key":"value"}}`
+		beingDeleted  = `{"metadata":{"name":"test","deletionTimestamp":"2024-01-01T00:00:00Z"},"dataa":{"key":"value"}}`
+	)
+	scheme := runtime.NewScheme()
+	require.NoError(t, corev1.AddToScheme(scheme))
+	configMapKind := metav1.GroupVersionKind{Version: "v1", Kind: "ConfigMap"}
+
+	tests := []struct {
+		name         string
+		mode         admission.UnknownFieldsMode
+		operation    admissionv1.Operation
+		kind         metav1.GroupVersionKind
+		object       string
+		wantDenied   bool
+		wantWarnings bool
+	}{
+		{
+			name:      "known fields",
+			mode:      admission.UnknownFieldsDeny,
+			operation: admissionv1.Create,
+			kind:      configMapKind,
+			object:    knownFields,
+		},
+		{
+			name:         "unknown fields with warn",
+			mode:         admission.UnknownFieldsWarn,
+			operation:    admissionv1.Update,
+			kind:         configMapKind,
+			object:       unknownFields,
+			wantWarnings: true,
+		},
+		{
+			name:       "unknown fields with deny",
+			mode:       admission.UnknownFieldsDeny,
+			operation:  admissionv1.Create,
+			kind:       configMapKind,
+			object:     unknownFields,
+			wantDenied: true,
+		},
+		{
+			name:      "kind not in the scheme",
+			mode:      admission.UnknownFieldsDeny,
+			operation: admissionv1.Create,
+			kind:      metav1.GroupVersionKind{Group: "test.cattle.io", Version: "v1", Kind: "Test"},
+			object:    unknownFields,
+		},
+		{
+			name:      "delete is not checked",
+			mode:      admission.UnknownFieldsDeny,
+			operation: admissionv1.Delete,
+			kind:      configMapKind,
+		},
+		{
+			name:      "objects being deleted are not checked",
+			mode:      admission.UnknownFieldsDeny,
+			operation: admissionv1.Update,
+			kind:      configMapKind,
+			object:    beingDeleted,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			handler := &fakeValidatingAdmissionHandler{
+				gvr:        schema.GroupVersionResource{Version: "v1", Resource: "configmaps"},
+				operations: []v1.OperationType{v1.Create, v1.Update, v1.Delete},
+				admitters:  []fakeAdmitter{setupAdmitter(&handlerResponse{hasAllow: true})},
+			}
+			checked := admission.WithUnknownFieldsCheck([]admission.ValidatingAdmissionHandler{handler}, tt.mode, scheme)
+			require.Len(t, checked, 1)
+			admitters := checked[0].Admitters()
+			require.Len(t, admitters, 2)
+
+			response, err := admitters[0].Admit(&admission.Request{
+				AdmissionRequest: admissionv1.AdmissionRequest{
+					Name:      "test",
+					Kind:      tt.kind,
+					Operation: tt.operation,
+					Object:    runtime.RawExtension{Raw: []byte(tt.object)},
+				},
+				Context: context.Background(),
+			})
+			require.NoError(t, err)
+			assert.Equal(t, !tt.wantDenied, response.Allowed)
+			if tt.wantDenied {
+				assert.Contains(t, response.Result.Message, `unknown field "dataa"`)
+			}
+			if tt.wantWarnings {
+				require.Len(t, response.Warnings, 1)
+				assert.Contains(t, response.Warnings[0], `unknown field "dataa" of ConfigMap test`)
+			} else {
+				assert.Empty(t, response.Warnings)
+			}
+		})
+	}
+}
+
+func TestWithUnknownFieldsCheckIgnore(t *testing.T) {
+	t.Parallel()
+	handlers := []admission.ValidatingAdmissionHandler{&fakeValidatingAdmissionHandler{}}
+	assert.Equal(t, handlers, admission.WithUnknownFieldsCheck(handlers, admission.UnknownFieldsIgnore, runtime.NewScheme()))
+}
@@ -0,0 +1,18 @@
+package admission
+
+import "sync/atomic"
+
+var webhookVersion atomic.Pointer[string]
+
+// SetWebhookVersion sets the version of the running webhook build, such as "v0.6.1+abc1234".
+func SetWebhookVersion(version string) {
+	webhookVersion.Store(&version)
+}
+
+// WebhookVersion returns the version of the running webhook build, or an empty string if it was not set.
+func WebhookVersion() string {
+	if version := webhookVersion.Load(); version != nil {
+		return *version
+	}
+	return ""
+}
@@ -0,0 +1,4 @@
+// Package v1 holds the types of the webhook.cattle.io API group, which configure the webhook itself.
+// +k8s:deepcopy-gen=package
+// +groupName=webhook.cattle.io
+package v1
@@ -0,0 +1,41 @@
+package v1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// +genclient
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+
+// TenantPolicy tightens the validation of the objects of its namespace, such as the provisioning clusters of a
+// tenant's Fleet workspace. A policy can only make validation stricter: when a namespace has several policies, the
+// strictest value of each setting applies.
+type TenantPolicy struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec TenantPolicySpec `json:"spec"`
+}
+
+// TenantPolicySpec holds the validations tightened by a TenantPolicy.
+type TenantPolicySpec struct {
+	// RequirePodSecurityAdmissionConfigurationTemplate requires provisioning clusters with an rkeConfig to set
+	// spec.defaultPodSecurityAdmissionConfigurationTemplateName.
+	RequirePodSecurityAdmissionConfigurationTemplate bool `json:"requirePodSecurityAdmissionConfigurationTemplate,omitempty"`
+	// AllowedPodSecurityAdmissionConfigurationTemplates, if set, are the only PodSecurityAdmissionConfigurationTemplates
+	// provisioning clusters can use.
+	AllowedPodSecurityAdmissionConfigurationTemplates []string `json:"allowedPodSecurityAdmissionConfigurationTemplates,omitempty"`
+	// ForbidInsecureS3Endpoints forbids provisioning clusters from allowing plain http S3 endpoints for their etcd
+	// snapshots.
+	ForbidInsecureS3Endpoints bool `json:"forbidInsecureS3Endpoints,omitempty"`
+}
+
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+
+// TenantPolicyList is a list of TenantPolicies.
+type TenantPolicyList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+
+	Items []TenantPolicy `json:"items"`
+}
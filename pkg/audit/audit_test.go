@@ -0,0 +1,226 @@
+package audit
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/rancher/webhook/pkg/admission"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	admissionv1 "k8s.io/api/admission/v1"
+	authenticationv1 "k8s.io/api/authentication/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+func TestConfigFromEnv(t *testing.T) {
+	tests := []struct {
+		name    string
+		env     map[string]string
+		want    Config
+		wantErr bool
+	}{
+		{
+			name: "disabled",
+			want: Config{MaxSizeMB: defaultMaxSizeMB, MaxBackups: defaultMaxBackups},
+		},
+		{
+			name: "custom values",
+			env: map[string]string{
+				LogPathEnv:       "/var/log/webhook/audit.log",
+				LogMaxSizeEnv:    "20",
+				LogMaxBackupsEnv: "0",
+				WebhookURLEnv:    "https://audit.example.com/records",
+			},
+			want: Config{
+				FilePath:   "/var/log/webhook/audit.log",
+				MaxSizeMB:  20,
+				MaxBackups: 0,
+				WebhookURL: "https://audit.example.com/records",
+			},
+		},
+		{name: "invalid size", env: map[string]string{LogMaxSizeEnv: "0"}, wantErr: true},
+		{name: "invalid backups", env: map[string]string{LogMaxBackupsEnv: "many"}, wantErr: true},
+		{name: "http url", env: map[string]string{WebhookURLEnv: "http://audit.example.com"}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for _, key := range []string{LogPathEnv, LogMaxSizeEnv, LogMaxBackupsEnv, WebhookURLEnv} {
+				t.Setenv(key, tt.env[key])
+			}
+			got, err := ConfigFromEnv()
+			if tt.wantErr {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestChangedPaths(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name     string
+		old, new string
+		want     []string
+	}{
+		{
+			name: "create",
+			new:  `{"metadata":{"name":"test"}}`,
+		},
+		{
+			name: "nested changes",
+			old:  `{"metadata":{"name":"test","resourceVersion":"1","labels":{"a":"1","b/c":"2"}},"rules":[{"verbs":["get"]}],"spec":{"x":1}}`,
+			new:  `{"metadata":{"name":"test","resourceVersion":"2","labels":{"a":"2"}},"rules":[{"verbs":["*"]}],"spec":{"x":1,"y":true}}`,
+			want: []string{"/metadata/labels/a", "/metadata/labels/b~1c", "/rules", "/spec/y"},
+		},
+		{
+			name: "not an object",
+			old:  `[]`,
+			new:  `{}`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			assert.Equal(t, tt.want, changedPaths([]byte(tt.old), []byte(tt.new)))
+		})
+	}
+
+	old := map[string]any{}
+	updated := map[string]any{}
+	for i := 0; i < maxChangedPaths+5; i++ {
+		updated[string(rune('a'+i))] = i
+	}
+	oldRaw, err := json.Marshal(old)
+	require.NoError(t, err)
+	newRaw, err := json.Marshal(updated)
+	require.NoError(t, err)
+	paths := changedPaths(oldRaw, newRaw)
+	require.Len(t, paths, maxChangedPaths+1)
+	assert.Equal(t, "...", paths[maxChangedPaths])
+}
+
+func TestNewRecord(t *testing.T) {
+	t.Parallel()
+	request := &admissionv1.AdmissionRequest{
+		UID:       "1234",
+		Resource:  metav1.GroupVersionResource{Group: "management.cattle.io", Version: "v3", Resource: "globalroles"},
+		Name:      "admin",
+		Operation: admissionv1.Update,
+		UserInfo:  authenticationv1.UserInfo{Username: "user", Groups: []string{"system:authenticated"}},
+		Object:    runtime.RawExtension{Raw: []byte(`{"rules":[{"verbs":["*"]}],"secret":"new"}`)},
+		OldObject: runtime.RawExtension{Raw: []byte(`{"rules":[],"secret":"old"}`)},
+	}
+	response := &admissionv1.AdmissionResponse{Result: &metav1.Status{Code: http.StatusForbidden, Reason: metav1.StatusReasonForbidden, Message: "escalation"}}
+
+	record := NewRecord("validating", request, response)
+	assert.Equal(t, "1234", record.UID)
+	assert.Equal(t, "user", record.User)
+	assert.Equal(t, []string{"system:authenticated"}, record.Groups)
+	assert.Equal(t, "UPDATE", record.Operation)
+	assert.Equal(t, "globalroles", record.Resource)
+	assert.Equal(t, int32(http.StatusForbidden), record.Code)
+	assert.Equal(t, "Forbidden", record.Reason)
+	assert.Equal(t, "escalation", record.Message)
+	assert.Equal(t, []string{"/rules", "/secret"}, record.ChangedPaths)
+	assert.Nil(t, record.Impersonation)
+
+	request.UserInfo.Extra = map[string]authenticationv1.ExtraValue{
+		admission.ImpersonationExtraUsername:    {"alice"},
+		admission.ImpersonationExtraPrincipalID: {"local://u-abcde"},
+	}
+	record = NewRecord("validating", request, response)
+	assert.Equal(t, &admission.Impersonation{Username: "alice", PrincipalIDs: []string{"local://u-abcde"}}, record.Impersonation)
+
+	data, err := json.Marshal(record)
+	require.NoError(t, err)
+	assert.NotContains(t, string(data), "old", "records must not hold the values of the objects")
+}
+
+func TestLoggerFileSink(t *testing.T) {
+	t.Parallel()
+	path := filepath.Join(t.TempDir(), "audit.log")
+	logger := NewLogger(Config{FilePath: path, MaxSizeMB: 1, MaxBackups: 1})
+	require.NotNil(t, logger)
+	for _, uid := range []string{"a", "b", "c"} {
+		logger.Log(Record{Time: time.Now().UTC(), UID: uid, Message: "denied"})
+	}
+	require.NoError(t, logger.Close())
+
+	records := readRecords(t, path)
+	require.Len(t, records, 3)
+	assert.Empty(t, records[0].PreviousHash)
+	assert.Equal(t, records[0].Hash, records[1].PreviousHash)
+	assert.Equal(t, -1, Verify(records))
+
+	tampered := append([]Record{}, records...)
+	tampered[1].Message = "allowed"
+	assert.Equal(t, 1, Verify(tampered))
+
+	removed := []Record{records[0], records[2]}
+	assert.Equal(t, 1, Verify(removed))
+
+	assert.Equal(t, -1, Verify(records[1:]), "a chain can start with a record whose predecessor was rotated out")
+}
+
+func TestLoggerWebhookSink(t *testing.T) {
+	t.Parallel()
+	var mu sync.Mutex
+	var received []Record
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "application/json", r.Header.Get("Content-Type"))
+		body, err := io.ReadAll(r.Body)
+		require.NoError(t, err)
+		record := Record{}
+		require.NoError(t, json.Unmarshal(body, &record))
+		mu.Lock()
+		received = append(received, record)
+		mu.Unlock()
+	}))
+	defer server.Close()
+
+	logger := &Logger{sinks: []sink{newWebhookSink(server.URL, server.Client())}}
+	logger.Log(Record{UID: "a"})
+	logger.Log(Record{UID: "b"})
+	require.NoError(t, logger.Close())
+	// records logged after closing are discarded
+	logger.Log(Record{UID: "c"})
+
+	mu.Lock()
+	defer mu.Unlock()
+	require.Len(t, received, 2)
+	assert.Equal(t, "a", received[0].UID)
+	assert.Equal(t, -1, Verify(received))
+}
+
+func TestNewLoggerDisabled(t *testing.T) {
+	t.Parallel()
+	assert.Nil(t, NewLogger(Config{MaxSizeMB: defaultMaxSizeMB}))
+}
+
+func readRecords(t *testing.T, path string) []Record {
+	t.Helper()
+	file, err := os.Open(path)
+	require.NoError(t, err)
+	defer file.Close()
+	var records []Record
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		record := Record{}
+		require.NoError(t, json.Unmarshal(scanner.Bytes(), &record))
+		records = append(records, record)
+	}
+	require.NoError(t, scanner.Err())
+	return records
+}
@@ -0,0 +1,74 @@
+package audit
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+)
+
+const (
+	// LogPathEnv is the environment variable setting the path of the file audit records are written to. The file sink
+	// is disabled if unset.
+	LogPathEnv = "CATTLE_WEBHOOK_AUDIT_LOG_PATH"
+	// LogMaxSizeEnv is the environment variable setting the size in megabytes at which the audit log file is rotated.
+	LogMaxSizeEnv = "CATTLE_WEBHOOK_AUDIT_LOG_MAX_SIZE_MB"
+	// LogMaxBackupsEnv is the environment variable setting the number of rotated audit log files which are kept.
+	LogMaxBackupsEnv = "CATTLE_WEBHOOK_AUDIT_LOG_MAX_BACKUPS"
+	// WebhookURLEnv is the environment variable setting the https URL audit records are posted to. The webhook sink is
+	// disabled if unset.
+	WebhookURLEnv = "CATTLE_WEBHOOK_AUDIT_WEBHOOK_URL"
+
+	defaultMaxSizeMB  = 100
+	defaultMaxBackups = 10
+)
+
+// Config configures the sinks audit records are written to. Auditing is disabled if no sink is set.
+type Config struct {
+	// FilePath is the path of the audit log file.
+	FilePath string
+	// MaxSizeMB is the size in megabytes at which the audit log file is rotated.
+	MaxSizeMB int
+	// MaxBackups is the number of rotated audit log files which are kept, or 0 to keep all of them.
+	MaxBackups int
+	// WebhookURL is the https URL audit records are posted to.
+	WebhookURL string
+}
+
+// Enabled returns true if at least one sink is set.
+func (c Config) Enabled() bool {
+	return c.FilePath != "" || c.WebhookURL != ""
+}
+
+// ConfigFromEnv returns the Config set in the environment, using the defaults for unset values.
+func ConfigFromEnv() (Config, error) {
+	config := Config{
+		FilePath:   os.Getenv(LogPathEnv),
+		MaxSizeMB:  defaultMaxSizeMB,
+		MaxBackups: defaultMaxBackups,
+		WebhookURL: os.Getenv(WebhookURLEnv),
+	}
+	var err error
+	if config.MaxSizeMB, err = intFromEnv(LogMaxSizeEnv, defaultMaxSizeMB, 1); err != nil {
+		return config, err
+	}
+	if config.MaxBackups, err = intFromEnv(LogMaxBackupsEnv, defaultMaxBackups, 0); err != nil {
+		return config, err
+	}
+	if config.WebhookURL != "" && !strings.HasPrefix(config.WebhookURL, "https://") {
+		return config, fmt.Errorf("invalid value '%s' for %s: must be an https URL", config.WebhookURL, WebhookURLEnv)
+	}
+	return config, nil
+}
+
+func intFromEnv(key string, defaultValue, minValue int) (int, error) {
+	value := os.Getenv(key)
+	if value == "" {
+		return defaultValue, nil
+	}
+	parsed, err := strconv.Atoi(value)
+	if err != nil || parsed < minValue {
+		return 0, fmt.Errorf("invalid value '%s' for %s: must be an integer of at least %d", value, key, minValue)
+	}
+	return parsed, nil
+}
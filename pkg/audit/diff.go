@@ -0,0 +1,39 @@
+package audit
+
+import (
+	"github.com/rancher/webhook/pkg/diff"
+)
+
+// maxChangedPaths bounds the number of changed paths kept in a record, so that large updates don't produce huge
+// records.
+const maxChangedPaths = 20
+
+// ignoredPaths are the paths which change on most updates and say nothing about why a request was denied.
+var ignoredPaths = map[string]bool{
+	"/metadata/managedFields":   true,
+	"/metadata/resourceVersion": true,
+	"/metadata/generation":      true,
+}
+
+// changedPaths returns the sorted JSON pointers of the fields which differ between the old and new objects, or nil if
+// either is missing or not a JSON object. Paths beyond the first maxChangedPaths are summarized by a final "..."
+// entry.
+func changedPaths(oldRaw, newRaw []byte) []string {
+	if len(oldRaw) == 0 || len(newRaw) == 0 {
+		return nil
+	}
+	changes, err := diff.JSON(oldRaw, newRaw)
+	if err != nil {
+		return nil
+	}
+	var paths []string
+	for _, change := range changes {
+		if pointer := change.Pointer(); !ignoredPaths[pointer] {
+			paths = append(paths, pointer)
+		}
+	}
+	if len(paths) > maxChangedPaths {
+		paths = append(paths[:maxChangedPaths], "...")
+	}
+	return paths
+}
@@ -0,0 +1,182 @@
+package audit
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/rancher/webhook/pkg/metrics"
+	"github.com/sirupsen/logrus"
+	"gopkg.in/natefinch/lumberjack.v2"
+)
+
+const (
+	webhookTimeout = 10 * time.Second
+	// maxWebhookQueue bounds the number of records waiting to be sent to the audit webhook. Records above it are
+	// dropped, so that a slow audit webhook can not exhaust the webhook's memory or delay admission requests.
+	maxWebhookQueue = 256
+)
+
+// Logger writes the audit records of denied admission requests to the configured sinks. Each record is chained to the
+// previous one by its hash, so that removed or altered records can be detected with Verify.
+type Logger struct {
+	mu       sync.Mutex
+	lastHash string
+	sinks    []sink
+}
+
+// sink is a destination of audit records.
+type sink interface {
+	// write writes a single JSON encoded record. It must not block on slow destinations.
+	write(line []byte)
+	close() error
+}
+
+// NewLogger returns the Logger for the given config, or nil if auditing is disabled.
+func NewLogger(config Config) *Logger {
+	if !config.Enabled() {
+		return nil
+	}
+	logger := &Logger{}
+	if config.FilePath != "" {
+		logrus.Infof("[audit] writing denied admission requests to %s", config.FilePath)
+		logger.sinks = append(logger.sinks, &fileSink{writer: &lumberjack.Logger{
+			Filename:   config.FilePath,
+			MaxSize:    config.MaxSizeMB,
+			MaxBackups: config.MaxBackups,
+		}})
+	}
+	if config.WebhookURL != "" {
+		logrus.Infof("[audit] sending denied admission requests to %s", config.WebhookURL)
+		logger.sinks = append(logger.sinks, newWebhookSink(config.WebhookURL, &http.Client{
+			Timeout:   webhookTimeout,
+			Transport: &http.Transport{TLSClientConfig: &tls.Config{MinVersion: tls.VersionTLS12}},
+		}))
+	}
+	return logger
+}
+
+// Log chains the record to the previously logged one and writes it to the sinks.
+func (l *Logger) Log(record Record) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	record.PreviousHash = l.lastHash
+	hash, err := record.hash()
+	if err != nil {
+		logrus.Errorf("[audit] failed to hash the record of admission request %s: %v", record.UID, err)
+		return
+	}
+	record.Hash = hash
+	line, err := json.Marshal(record)
+	if err != nil {
+		logrus.Errorf("[audit] failed to encode the record of admission request %s: %v", record.UID, err)
+		return
+	}
+	l.lastHash = hash
+	// the sinks are written while holding the lock so that records are written in the order of the chain
+	for _, s := range l.sinks {
+		s.write(line)
+	}
+}
+
+// Close flushes the records waiting to be sent and closes the sinks.
+func (l *Logger) Close() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	var errs []error
+	for _, s := range l.sinks {
+		errs = append(errs, s.close())
+	}
+	// records logged after closing are discarded
+	l.sinks = nil
+	return errors.Join(errs...)
+}
+
+// fileSink writes records as JSON lines to a file rotated by size.
+type fileSink struct {
+	writer *lumberjack.Logger
+}
+
+func (f *fileSink) write(line []byte) {
+	if _, err := f.writer.Write(append(line, '\n')); err != nil {
+		logrus.Errorf("[audit] failed to write to %s: %v", f.writer.Filename, err)
+		metrics.AuditRecords.WithLabelValues(metrics.AuditSinkFile, metrics.AuditResultError).Inc()
+		return
+	}
+	metrics.AuditRecords.WithLabelValues(metrics.AuditSinkFile, metrics.AuditResultWritten).Inc()
+}
+
+func (f *fileSink) close() error {
+	return f.writer.Close()
+}
+
+// webhookSink asynchronously posts each record to an https URL.
+type webhookSink struct {
+	url    string
+	client *http.Client
+	queue  chan []byte
+	done   chan struct{}
+}
+
+func newWebhookSink(url string, client *http.Client) *webhookSink {
+	w := &webhookSink{
+		url:    url,
+		client: client,
+		queue:  make(chan []byte, maxWebhookQueue),
+		done:   make(chan struct{}),
+	}
+	go w.run()
+	return w
+}
+
+func (w *webhookSink) write(line []byte) {
+	select {
+	case w.queue <- line:
+	default:
+		logrus.Warnf("[audit] dropped a record, %d records are already waiting to be sent to %s", maxWebhookQueue, w.url)
+		metrics.AuditRecords.WithLabelValues(metrics.AuditSinkWebhook, metrics.AuditResultDropped).Inc()
+	}
+}
+
+func (w *webhookSink) close() error {
+	close(w.queue)
+	<-w.done
+	return nil
+}
+
+func (w *webhookSink) run() {
+	defer close(w.done)
+	for line := range w.queue {
+		if err := w.send(line); err != nil {
+			logrus.Errorf("[audit] failed to send a record to %s: %v", w.url, err)
+			metrics.AuditRecords.WithLabelValues(metrics.AuditSinkWebhook, metrics.AuditResultError).Inc()
+			continue
+		}
+		metrics.AuditRecords.WithLabelValues(metrics.AuditSinkWebhook, metrics.AuditResultWritten).Inc()
+	}
+}
+
+func (w *webhookSink) send(line []byte) error {
+	ctx, cancel := context.WithTimeout(context.Background(), webhookTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, w.url, bytes.NewReader(line))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := w.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("unexpected status %s", resp.Status)
+	}
+	return nil
+}
@@ -0,0 +1,102 @@
+package audit
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"time"
+
+	"github.com/rancher/webhook/pkg/admission"
+	admissionv1 "k8s.io/api/admission/v1"
+)
+
+// Record is the audit record of a denied admission request. Records never hold the values of the reviewed objects,
+// only the paths of the fields changed by the request.
+type Record struct {
+	// Time is when the request was denied.
+	Time time.Time `json:"time"`
+	// UID is the UID of the AdmissionReview.
+	UID string `json:"uid"`
+	// WebhookType is "validating" or "mutating".
+	WebhookType string `json:"webhookType"`
+	// User and Groups identify the user who made the request to the Kubernetes API server.
+	User   string   `json:"user"`
+	Groups []string `json:"groups,omitempty"`
+	// Impersonation identifies the actor on whose behalf Rancher made the request, if it did.
+	Impersonation *admission.Impersonation `json:"impersonation,omitempty"`
+	// Operation is the operation of the request, such as "CREATE".
+	Operation string `json:"operation"`
+	// Group, Version, Resource and SubResource identify the resource of the request.
+	Group       string `json:"group"`
+	Version     string `json:"version"`
+	Resource    string `json:"resource"`
+	SubResource string `json:"subResource,omitempty"`
+	// Namespace and Name identify the object of the request.
+	Namespace string `json:"namespace,omitempty"`
+	Name      string `json:"name,omitempty"`
+	// Code, Reason and Message are the status returned to the Kubernetes API server.
+	Code    int32  `json:"code"`
+	Reason  string `json:"reason,omitempty"`
+	Message string `json:"message,omitempty"`
+	// ChangedPaths are the JSON paths of the fields changed by an update.
+	ChangedPaths []string `json:"changedPaths,omitempty"`
+	// PreviousHash is the Hash of the record logged before this one, and is empty for the first record logged since the
+	// webhook started.
+	PreviousHash string `json:"previousHash"`
+	// Hash is the hex encoded SHA-256 of the record without its Hash. Since each record includes the hash of the
+	// previous one, removing or altering a record breaks the chain.
+	Hash string `json:"hash"`
+}
+
+// NewRecord returns the Record of the request denied with the response by a webhook of the given type.
+func NewRecord(webhookType string, request *admissionv1.AdmissionRequest, response *admissionv1.AdmissionResponse) Record {
+	record := Record{
+		Time:          time.Now().UTC(),
+		UID:           string(request.UID),
+		WebhookType:   webhookType,
+		User:          request.UserInfo.Username,
+		Groups:        request.UserInfo.Groups,
+		Impersonation: admission.ImpersonationOf(request.UserInfo),
+		Operation:     string(request.Operation),
+		Group:         request.Resource.Group,
+		Version:       request.Resource.Version,
+		Resource:      request.Resource.Resource,
+		SubResource:   request.SubResource,
+		Namespace:     request.Namespace,
+		Name:          request.Name,
+		ChangedPaths:  changedPaths(request.OldObject.Raw, request.Object.Raw),
+	}
+	if response.Result != nil {
+		record.Code = response.Result.Code
+		record.Reason = string(response.Result.Reason)
+		record.Message = response.Result.Message
+	}
+	return record
+}
+
+// hash returns the hash of the record, ignoring its current Hash.
+func (r Record) hash() (string, error) {
+	r.Hash = ""
+	data, err := json.Marshal(r)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// Verify checks that each record holds its own hash and the hash of the record before it. It returns the index of
+// the first record breaking the chain, or -1 if the chain is intact. The first record may continue a chain whose
+// earlier records were rotated out.
+func Verify(records []Record) int {
+	for i, record := range records {
+		hash, err := record.hash()
+		if err != nil || hash != record.Hash {
+			return i
+		}
+		if i > 0 && record.PreviousHash != records[i-1].Hash {
+			return i
+		}
+	}
+	return -1
+}
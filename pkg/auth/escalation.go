@@ -19,6 +19,15 @@ import (
 	"k8s.io/kubernetes/pkg/registry/rbac/validation"
 )
 
+// allResources is used to check if a user is an administrator, i.e. can perform any verb on any resource.
+var allResources = schema.GroupVersionResource{Group: "*", Version: "*", Resource: "*"}
+
+// RequestUserIsAdmin checks if the user associated with the request is an administrator, i.e. can perform any verb on
+// any resource.
+func RequestUserIsAdmin(request *admission.Request, sar authorizationv1.SubjectAccessReviewInterface) (bool, error) {
+	return RequestUserHasVerb(request, allResources, sar, "*", "", "")
+}
+
 // RequestUserHasVerb checks if the user associated with the context has a given verb on a given gvr for a specified name/namespace
 func RequestUserHasVerb(request *admission.Request, gvr schema.GroupVersionResource, sar authorizationv1.SubjectAccessReviewInterface, verb, name, namespace string) (bool, error) {
 	extras := map[string]v1.ExtraValue{}
@@ -50,8 +59,11 @@ func RequestUserHasVerb(request *admission.Request, gvr schema.GroupVersionResou
 }
 
 // ConfirmNoEscalation checks that the user attempting to create a binding/role has all the permissions they are attempting
-// to grant.
+// to grant. Requests with an invalid impersonation context (see admission.ValidateImpersonation) are always escalations.
 func ConfirmNoEscalation(request *admission.Request, rules []rbacv1.PolicyRule, namespace string, ruleResolver validation.AuthorizationRuleResolver) error {
+	if err := admission.ValidateImpersonation(request); err != nil {
+		return err
+	}
 	userInfo := &user.DefaultInfo{
 		Name:   request.UserInfo.Username,
 		UID:    request.UserInfo.UID,
@@ -141,6 +141,19 @@ func (e *EscalationSuite) TestConfirmNoEscalation() {
 			wantErr: true,
 		},
 		// Denied Escalation attemptß
+		{
+			name: "Admin with an invalid impersonation context denied escalation",
+			args: args{
+				request: func() *admission.Request {
+					request := e.newDefaultRequest(adminUser)
+					request.UserInfo.Groups = []string{"system:masters"}
+					request.UserInfo.Extra[admission.ImpersonationExtraPrincipalID] = []string{"local://u-abcde"}
+					return request
+				}(),
+				rules: []rbacv1.PolicyRule{e.ruleReadPods},
+			},
+			wantErr: true,
+		},
 	}
 	for i := range tests {
 		test := tests[i]
@@ -238,3 +251,24 @@ func (e *EscalationSuite) TestRequestUserHasVerb() {
 		})
 	}
 }
+
+func (e *EscalationSuite) TestRequestUserIsAdmin() {
+	const adminUser = "adminUser"
+	k8Fake := &k8testing.Fake{}
+	fakeSAR := &k8fake.FakeSubjectAccessReviews{Fake: &k8fake.FakeAuthorizationV1{Fake: k8Fake}}
+	k8Fake.AddReactor("create", "subjectaccessreviews", func(action k8testing.Action) (handled bool, ret runtime.Object, err error) {
+		review := action.(k8testing.CreateActionImpl).GetObject().(*authorizationv1.SubjectAccessReview)
+		attributes := review.Spec.ResourceAttributes
+		review.Status.Allowed = review.Spec.User == adminUser && attributes.Verb == "*" && attributes.Group == "*" &&
+			attributes.Version == "*" && attributes.Resource == "*" && attributes.Name == "" && attributes.Namespace == ""
+		return true, review, nil
+	})
+
+	isAdmin, err := auth.RequestUserIsAdmin(e.newDefaultRequest(adminUser), fakeSAR)
+	e.NoError(err)
+	e.True(isAdmin)
+
+	isAdmin, err = auth.RequestUserIsAdmin(e.newDefaultRequest("testUser"), fakeSAR)
+	e.NoError(err)
+	e.False(isAdmin)
+}
@@ -2,17 +2,38 @@ package auth
 
 import (
 	"fmt"
+	"slices"
+	"sync"
 
 	rancherv3 "github.com/rancher/rancher/pkg/apis/management.cattle.io/v3"
 	v3 "github.com/rancher/webhook/pkg/generated/controllers/management.cattle.io/v3"
+	"github.com/rancher/webhook/pkg/metrics"
 	v1 "github.com/rancher/wrangler/v3/pkg/generated/controllers/rbac/v1"
 	rbacv1 "k8s.io/api/rbac/v1"
 )
 
 // RoleTemplateResolver provides an interface to flatten role templates into slice of rules.
+// The rules resolved by name are memoized, keyed on the resourceVersions of the RoleTemplates and ClusterRoles they were
+// resolved from, so that the inheritance chain of a RoleTemplate is only walked again once one of them changed.
 type RoleTemplateResolver struct {
 	roleTemplates v3.RoleTemplateCache
 	clusterRoles  v1.ClusterRoleCache
+
+	mutex    sync.Mutex
+	resolved map[string]*resolvedRules
+}
+
+// resolvedRules are the memoized rules of a RoleTemplate.
+type resolvedRules struct {
+	// sources holds the resourceVersion of each RoleTemplate and ClusterRole the rules were resolved from.
+	sources map[ruleSource]string
+	rules   []rbacv1.PolicyRule
+}
+
+// ruleSource is a RoleTemplate, or a ClusterRole backing an external RoleTemplate, rules are resolved from.
+type ruleSource struct {
+	clusterRole bool
+	name        string
 }
 
 // NewRoleTemplateResolver creates a newly allocated RoleTemplateResolver from the provided caches
@@ -20,19 +41,45 @@ func NewRoleTemplateResolver(roleTemplates v3.RoleTemplateCache, clusterRoles v1
 	return &RoleTemplateResolver{
 		roleTemplates: roleTemplates,
 		clusterRoles:  clusterRoles,
+		resolved:      map[string]*resolvedRules{},
 	}
 }
 
 // RoleTemplateCache allows caller to retrieve the roleTemplateCache used by the resolver.
 func (r *RoleTemplateResolver) RoleTemplateCache() v3.RoleTemplateCache { return r.roleTemplates }
 
-// RulesFromTemplateName gets the rules for a roleTemplate with a given name. Simple wrapper around RulesFromTemplate.
+// RulesFromTemplateName gets the rules for a roleTemplate with a given name, and all referenced templates. The rules
+// are memoized until one of the RoleTemplates or ClusterRoles they were resolved from changes.
 func (r *RoleTemplateResolver) RulesFromTemplateName(name string) ([]rbacv1.PolicyRule, error) {
 	rt, err := r.roleTemplates.Get(name)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get RoleTemplate '%s': %w", name, err)
 	}
-	return r.RulesFromTemplate(rt)
+	if rules, ok := r.memoized(rt); ok {
+		metrics.RoleTemplateResolutionCacheRequests.WithLabelValues("hit").Inc()
+		return rules, nil
+	}
+	metrics.RoleTemplateResolutionCacheRequests.WithLabelValues("miss").Inc()
+
+	sources := make(map[ruleSource]string)
+	rules, err := r.gatherRules(rt, nil, sources)
+	if err != nil {
+		return rules, err
+	}
+	r.memoize(name, sources, rules)
+	return rules, nil
+}
+
+// InvalidateRoleTemplate forgets the memoized rules resolved from the RoleTemplate with the given name. It should be
+// called whenever a RoleTemplate changes or is deleted.
+func (r *RoleTemplateResolver) InvalidateRoleTemplate(name string) {
+	r.invalidate(ruleSource{name: name})
+}
+
+// InvalidateClusterRole forgets the memoized rules resolved from the ClusterRole with the given name. It should be
+// called whenever a ClusterRole changes or is deleted.
+func (r *RoleTemplateResolver) InvalidateClusterRole(name string) {
+	r.invalidate(ruleSource{clusterRole: true, name: name})
 }
 
 // RulesFromTemplate gets all rules from the template and all referenced templates.
@@ -44,10 +91,10 @@ func (r *RoleTemplateResolver) RulesFromTemplate(roleTemplate *rancherv3.RoleTem
 		return rules, nil
 	}
 
-	templatesSeen := make(map[string]bool)
+	sources := make(map[ruleSource]string)
 
 	// Kickoff gathering rules
-	rules, err = r.gatherRules(roleTemplate, rules, templatesSeen)
+	rules, err = r.gatherRules(roleTemplate, rules, sources)
 	if err != nil {
 		return rules, err
 	}
@@ -55,8 +102,9 @@ func (r *RoleTemplateResolver) RulesFromTemplate(roleTemplate *rancherv3.RoleTem
 }
 
 // gatherRules appends the rules from current template and does a recursive call to get all inherited roles referenced.
-func (r *RoleTemplateResolver) gatherRules(roleTemplate *rancherv3.RoleTemplate, rules []rbacv1.PolicyRule, seen map[string]bool) ([]rbacv1.PolicyRule, error) {
-	seen[roleTemplate.Name] = true
+// The resourceVersion of each RoleTemplate and ClusterRole the rules are gathered from is added to sources.
+func (r *RoleTemplateResolver) gatherRules(roleTemplate *rancherv3.RoleTemplate, rules []rbacv1.PolicyRule, sources map[ruleSource]string) ([]rbacv1.PolicyRule, error) {
+	sources[ruleSource{name: roleTemplate.Name}] = roleTemplate.ResourceVersion
 
 	if roleTemplate.External {
 		if roleTemplate.ExternalRules != nil {
@@ -66,6 +114,7 @@ func (r *RoleTemplateResolver) gatherRules(roleTemplate *rancherv3.RoleTemplate,
 			if err != nil {
 				return nil, fmt.Errorf("for external RoleTemplates, externalRules must be provided or a backing clusterRole must be installed to check for privilege escalations: failed to get ClusterRole %q: %w", roleTemplate.Name, err)
 			}
+			sources[ruleSource{clusterRole: true, name: cr.Name}] = cr.ResourceVersion
 			rules = append(rules, cr.Rules...)
 		}
 	}
@@ -74,17 +123,78 @@ func (r *RoleTemplateResolver) gatherRules(roleTemplate *rancherv3.RoleTemplate,
 
 	for _, templateName := range roleTemplate.RoleTemplateNames {
 		// If we have already seen the roleTemplate, skip it
-		if seen[templateName] {
+		if _, seen := sources[ruleSource{name: templateName}]; seen {
 			continue
 		}
 		next, err := r.roleTemplates.Get(templateName)
 		if err != nil {
 			return nil, fmt.Errorf("failed to get RoleTemplate '%s': %w", templateName, err)
 		}
-		rules, err = r.gatherRules(next, rules, seen)
+		rules, err = r.gatherRules(next, rules, sources)
 		if err != nil {
 			return nil, err
 		}
 	}
 	return rules, nil
 }
+
+// memoized returns the memoized rules of the RoleTemplate if none of the RoleTemplates and ClusterRoles they were
+// resolved from changed since.
+func (r *RoleTemplateResolver) memoized(roleTemplate *rancherv3.RoleTemplate) ([]rbacv1.PolicyRule, bool) {
+	r.mutex.Lock()
+	resolved, ok := r.resolved[roleTemplate.Name]
+	r.mutex.Unlock()
+	if !ok || resolved.sources[ruleSource{name: roleTemplate.Name}] != roleTemplate.ResourceVersion {
+		return nil, false
+	}
+	// The sources are checked even though changes invalidate the rules, as a resolution which raced with a change
+	// could have memoized rules resolved from the previous version of a source after it was invalidated.
+	for source, resourceVersion := range resolved.sources {
+		if source.name == roleTemplate.Name && !source.clusterRole {
+			continue
+		}
+		if r.resourceVersion(source) != resourceVersion {
+			return nil, false
+		}
+	}
+	return slices.Clone(resolved.rules), true
+}
+
+// memoize stores the rules resolved for the RoleTemplate with the given name. Rules resolved from objects without a
+// resourceVersion, which didn't come from the API server, aren't memoized.
+func (r *RoleTemplateResolver) memoize(name string, sources map[ruleSource]string, rules []rbacv1.PolicyRule) {
+	for _, resourceVersion := range sources {
+		if resourceVersion == "" {
+			return
+		}
+	}
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.resolved[name] = &resolvedRules{sources: sources, rules: slices.Clone(rules)}
+}
+
+func (r *RoleTemplateResolver) invalidate(source ruleSource) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	for name, resolved := range r.resolved {
+		if _, ok := resolved.sources[source]; ok {
+			delete(r.resolved, name)
+		}
+	}
+}
+
+// resourceVersion returns the current resourceVersion of the source, or an empty string if it can't be found.
+func (r *RoleTemplateResolver) resourceVersion(source ruleSource) string {
+	if source.clusterRole {
+		clusterRole, err := r.clusterRoles.Get(source.name)
+		if err != nil {
+			return ""
+		}
+		return clusterRole.ResourceVersion
+	}
+	roleTemplate, err := r.roleTemplates.Get(source.name)
+	if err != nil {
+		return ""
+	}
+	return roleTemplate.ResourceVersion
+}
@@ -6,9 +6,11 @@ import (
 	"sort"
 	"testing"
 
+	"github.com/prometheus/client_golang/prometheus/testutil"
 	apisv3 "github.com/rancher/rancher/pkg/apis/management.cattle.io/v3"
 	"github.com/rancher/webhook/pkg/auth"
 	v3 "github.com/rancher/webhook/pkg/generated/controllers/management.cattle.io/v3"
+	"github.com/rancher/webhook/pkg/metrics"
 	wranglerv1 "github.com/rancher/wrangler/v3/pkg/generated/controllers/rbac/v1"
 	"github.com/rancher/wrangler/v3/pkg/generic/fake"
 	"github.com/stretchr/testify/suite"
@@ -388,3 +390,66 @@ func (r *RoleTemplateResolverSuite) TestGetCache() {
 	resolver := auth.NewRoleTemplateResolver(roleTemplateCache, clusterRoleCache)
 	r.Equal(resolver.RoleTemplateCache(), roleTemplateCache, "Resolver did not correctly return cache")
 }
+
+func (r *RoleTemplateResolverSuite) TestMemoizedRules() {
+	withResourceVersion := func(rt *apisv3.RoleTemplate, resourceVersion string) *apisv3.RoleTemplate {
+		rt = rt.DeepCopy()
+		rt.ResourceVersion = resourceVersion
+		return rt
+	}
+	inheritedRT := withResourceVersion(r.inheritedRT, "1")
+	writeNodesRT := withResourceVersion(r.writeNodesRT, "2")
+	readNodesRT := withResourceVersion(r.readNodesRT, "3")
+	want := append(append(Rules{}, writeNodesRT.Rules...), readNodesRT.Rules...)
+
+	ctrl := gomock.NewController(r.T())
+	roleTemplateCache := fake.NewMockNonNamespacedCacheInterface[*apisv3.RoleTemplate](ctrl)
+	roleTemplates := map[string]*apisv3.RoleTemplate{
+		inheritedRT.Name:  inheritedRT,
+		writeNodesRT.Name: writeNodesRT,
+		readNodesRT.Name:  readNodesRT,
+	}
+	roleTemplateCache.EXPECT().Get(gomock.Any()).DoAndReturn(func(name string) (*apisv3.RoleTemplate, error) {
+		return roleTemplates[name], nil
+	}).AnyTimes()
+	clusterRoleCache := fake.NewMockNonNamespacedCacheInterface[*rbacv1.ClusterRole](ctrl)
+	resolver := auth.NewRoleTemplateResolver(roleTemplateCache, clusterRoleCache)
+	hits := metrics.RoleTemplateResolutionCacheRequests.WithLabelValues("hit")
+	initialHits := testutil.ToFloat64(hits)
+
+	got, err := resolver.RulesFromTemplateName(inheritedRT.Name)
+	r.Require().NoError(err)
+	r.True(want.Equal(got), "wanted=%+v got=%+v", want, got)
+	r.Equal(initialHits, testutil.ToFloat64(hits))
+
+	// the memoized rules are returned while the inherited templates don't change
+	got, err = resolver.RulesFromTemplateName(inheritedRT.Name)
+	r.Require().NoError(err)
+	r.True(want.Equal(got), "wanted=%+v got=%+v", want, got)
+	r.Equal(initialHits+1, testutil.ToFloat64(hits))
+
+	// a new version of an inherited template is resolved again, even without being invalidated
+	readNodesRT = withResourceVersion(r.readNodesRT, "4")
+	readNodesRT.Rules = append(readNodesRT.Rules, r.adminRT.Rules...)
+	roleTemplates[readNodesRT.Name] = readNodesRT
+	want = append(append(Rules{}, writeNodesRT.Rules...), readNodesRT.Rules...)
+	got, err = resolver.RulesFromTemplateName(inheritedRT.Name)
+	r.Require().NoError(err)
+	r.True(want.Equal(got), "wanted=%+v got=%+v", want, got)
+
+	r.Equal(initialHits+1, testutil.ToFloat64(hits))
+
+	// invalidated rules are resolved again
+	resolver.InvalidateRoleTemplate(readNodesRT.Name)
+	got, err = resolver.RulesFromTemplateName(inheritedRT.Name)
+	r.Require().NoError(err)
+	r.True(want.Equal(got), "wanted=%+v got=%+v", want, got)
+	r.Equal(initialHits+1, testutil.ToFloat64(hits))
+
+	// the memoized rules are copies
+	got[0].Verbs = []string{"delete"}
+	got, err = resolver.RulesFromTemplateName(inheritedRT.Name)
+	r.Require().NoError(err)
+	r.True(want.Equal(got), "wanted=%+v got=%+v", want, got)
+	r.Equal(initialHits+2, testutil.ToFloat64(hits))
+}
@@ -0,0 +1,150 @@
+package auth
+
+import (
+	"slices"
+	"strings"
+
+	rbacv1 "k8s.io/api/rbac/v1"
+)
+
+// CanonicalRules reduces rules to an equivalent canonical set: the rules grant exactly the same permissions, but each
+// permission is only granted once and rules granting the same verbs are merged. Checking if the rules are covered by
+// the rules of a user, which breaks every rule down to each of the permissions it grants, is then proportional to the
+// number of distinct permissions instead of the number of rules, which matters for roles with thousands of overlapping
+// rules. The canonical rules are sorted, so the same permissions always produce the same rules.
+func CanonicalRules(rules []rbacv1.PolicyRule) []rbacv1.PolicyRule {
+	// verbs granted for each resource, resource name and non-resource URL
+	resourceVerbs := map[resourceKey]map[string]struct{}{}
+	urlVerbs := map[string]map[string]struct{}{}
+	for _, rule := range rules {
+		for _, group := range rule.APIGroups {
+			for _, resource := range rule.Resources {
+				if len(rule.ResourceNames) == 0 {
+					addVerbs(resourceVerbs, resourceKey{group: group, resource: resource}, rule.Verbs)
+					continue
+				}
+				for _, name := range rule.ResourceNames {
+					addVerbs(resourceVerbs, resourceKey{group: group, resource: resource, name: name, named: true}, rule.Verbs)
+				}
+			}
+		}
+		for _, url := range rule.NonResourceURLs {
+			addVerbs(urlVerbs, url, rule.Verbs)
+		}
+	}
+
+	// verbs granted on all the objects of a resource don't need to be granted on some of them by name
+	for key, verbs := range resourceVerbs {
+		if !key.named {
+			continue
+		}
+		unnamed := resourceVerbs[resourceKey{group: key.group, resource: key.resource}]
+		for verb := range verbs {
+			if _, ok := unnamed[verb]; ok {
+				delete(verbs, verb)
+			}
+		}
+		if len(verbs) == 0 {
+			delete(resourceVerbs, key)
+		}
+	}
+
+	// merge the names of the objects of a resource granted the same verbs
+	type namesKey struct {
+		group, resource, verbs string
+		named                  bool
+	}
+	names := map[namesKey][]string{}
+	for key, verbs := range resourceVerbs {
+		k := namesKey{group: key.group, resource: key.resource, verbs: joinSet(verbs), named: key.named}
+		if key.named {
+			names[k] = append(names[k], key.name)
+		} else {
+			names[k] = nil
+		}
+	}
+	// then merge the resources of a group granted the same verbs on the same names
+	type resourcesKey struct {
+		group, verbs, names string
+		named               bool
+	}
+	resources := map[resourcesKey][]string{}
+	for key, keyNames := range names {
+		slices.Sort(keyNames)
+		k := resourcesKey{group: key.group, verbs: key.verbs, names: strings.Join(keyNames, ","), named: key.named}
+		resources[k] = append(resources[k], key.resource)
+	}
+	canonical := make([]rbacv1.PolicyRule, 0, len(resources))
+	for key, keyResources := range resources {
+		slices.Sort(keyResources)
+		rule := rbacv1.PolicyRule{
+			Verbs:     strings.Split(key.verbs, ","),
+			APIGroups: []string{key.group},
+			Resources: keyResources,
+		}
+		if key.named {
+			rule.ResourceNames = strings.Split(key.names, ",")
+		}
+		canonical = append(canonical, rule)
+	}
+
+	// merge the non-resource URLs granted the same verbs
+	urls := map[string][]string{}
+	for url, verbs := range urlVerbs {
+		k := joinSet(verbs)
+		urls[k] = append(urls[k], url)
+	}
+	for verbs, verbURLs := range urls {
+		slices.Sort(verbURLs)
+		canonical = append(canonical, rbacv1.PolicyRule{Verbs: strings.Split(verbs, ","), NonResourceURLs: verbURLs})
+	}
+
+	slices.SortFunc(canonical, compareRules)
+	return canonical
+}
+
+// resourceKey identifies a resource, or an object of a resource by name when named is true.
+type resourceKey struct {
+	group, resource, name string
+	named                 bool
+}
+
+func addVerbs[K comparable](granted map[K]map[string]struct{}, key K, verbs []string) {
+	if len(verbs) == 0 {
+		return
+	}
+	set := granted[key]
+	if set == nil {
+		set = map[string]struct{}{}
+		granted[key] = set
+	}
+	for _, verb := range verbs {
+		set[verb] = struct{}{}
+	}
+}
+
+// joinSet returns the sorted values of the set joined by commas. Verbs, resources and names can't contain commas, so
+// it can be split back into the values.
+func joinSet(set map[string]struct{}) string {
+	values := make([]string, 0, len(set))
+	for value := range set {
+		values = append(values, value)
+	}
+	slices.Sort(values)
+	return strings.Join(values, ",")
+}
+
+func compareRules(a, b rbacv1.PolicyRule) int {
+	for _, fields := range [][2][]string{
+		{a.APIGroups, b.APIGroups},
+		{a.Resources, b.Resources},
+		{a.ResourceNames, b.ResourceNames},
+		{a.NonResourceURLs, b.NonResourceURLs},
+		{a.Verbs, b.Verbs},
+	} {
+		if c := slices.Compare(fields[0], fields[1]); c != 0 {
+			return c
+		}
+	}
+	return 0
+}
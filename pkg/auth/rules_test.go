@@ -0,0 +1,131 @@
+package auth_test
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/rancher/webhook/pkg/auth"
+	"github.com/stretchr/testify/assert"
+	rbacv1 "k8s.io/api/rbac/v1"
+	rbacvalidation "k8s.io/component-helpers/auth/rbac/validation"
+)
+
+func TestCanonicalRules(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name  string
+		rules []rbacv1.PolicyRule
+		want  []rbacv1.PolicyRule
+	}{
+		{
+			name: "no rules",
+			want: []rbacv1.PolicyRule{},
+		},
+		{
+			name: "duplicate rules",
+			rules: []rbacv1.PolicyRule{
+				{APIGroups: []string{""}, Resources: []string{"pods"}, Verbs: []string{"get", "list"}},
+				{APIGroups: []string{""}, Resources: []string{"pods"}, Verbs: []string{"list", "get"}},
+			},
+			want: []rbacv1.PolicyRule{
+				{APIGroups: []string{""}, Resources: []string{"pods"}, Verbs: []string{"get", "list"}},
+			},
+		},
+		{
+			name: "resources with the same verbs are merged",
+			rules: []rbacv1.PolicyRule{
+				{APIGroups: []string{""}, Resources: []string{"pods"}, Verbs: []string{"get"}},
+				{APIGroups: []string{""}, Resources: []string{"configmaps"}, Verbs: []string{"get"}},
+				{APIGroups: []string{"", "apps"}, Resources: []string{"deployments"}, Verbs: []string{"get", "watch"}},
+			},
+			want: []rbacv1.PolicyRule{
+				{APIGroups: []string{""}, Resources: []string{"configmaps", "pods"}, Verbs: []string{"get"}},
+				{APIGroups: []string{""}, Resources: []string{"deployments"}, Verbs: []string{"get", "watch"}},
+				{APIGroups: []string{"apps"}, Resources: []string{"deployments"}, Verbs: []string{"get", "watch"}},
+			},
+		},
+		{
+			name: "named objects granted on all objects are dropped",
+			rules: []rbacv1.PolicyRule{
+				{APIGroups: []string{""}, Resources: []string{"secrets"}, Verbs: []string{"get"}},
+				{APIGroups: []string{""}, Resources: []string{"secrets"}, ResourceNames: []string{"b", "a"}, Verbs: []string{"get", "update"}},
+			},
+			want: []rbacv1.PolicyRule{
+				{APIGroups: []string{""}, Resources: []string{"secrets"}, Verbs: []string{"get"}},
+				{APIGroups: []string{""}, Resources: []string{"secrets"}, ResourceNames: []string{"a", "b"}, Verbs: []string{"update"}},
+			},
+		},
+		{
+			name: "empty resource name is kept",
+			rules: []rbacv1.PolicyRule{
+				{APIGroups: []string{""}, Resources: []string{"secrets"}, ResourceNames: []string{""}, Verbs: []string{"get"}},
+			},
+			want: []rbacv1.PolicyRule{
+				{APIGroups: []string{""}, Resources: []string{"secrets"}, ResourceNames: []string{""}, Verbs: []string{"get"}},
+			},
+		},
+		{
+			name: "non-resource URLs",
+			rules: []rbacv1.PolicyRule{
+				{NonResourceURLs: []string{"/metrics"}, Verbs: []string{"get"}},
+				{NonResourceURLs: []string{"/healthz", "/metrics"}, Verbs: []string{"get"}},
+			},
+			want: []rbacv1.PolicyRule{
+				{NonResourceURLs: []string{"/healthz", "/metrics"}, Verbs: []string{"get"}},
+			},
+		},
+		{
+			name: "rules without verbs grant nothing",
+			rules: []rbacv1.PolicyRule{
+				{APIGroups: []string{""}, Resources: []string{"pods"}},
+			},
+			want: []rbacv1.PolicyRule{},
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			got := auth.CanonicalRules(tt.rules)
+			assert.Equal(t, tt.want, got)
+			// the canonical rules grant exactly the same permissions
+			covers, _ := rbacvalidation.Covers(tt.rules, got)
+			assert.True(t, covers, "rules don't cover the canonical rules")
+			covers, _ = rbacvalidation.Covers(got, tt.rules)
+			assert.True(t, covers, "canonical rules don't cover the rules")
+		})
+	}
+}
+
+// largeRules returns n rules as synced from an external system: rules of a few hundred resources, with the same
+// permissions granted many times over.
+func largeRules(n int) []rbacv1.PolicyRule {
+	verbs := [][]string{{"get", "list", "watch"}, {"get"}, {"create", "update", "patch", "delete"}}
+	rules := make([]rbacv1.PolicyRule, 0, n)
+	for i := 0; i < n; i++ {
+		rules = append(rules, rbacv1.PolicyRule{
+			APIGroups: []string{fmt.Sprintf("group%d.example.com", i%10)},
+			Resources: []string{fmt.Sprintf("resource%d", i%300), fmt.Sprintf("resource%d/status", i%300)},
+			Verbs:     verbs[i%len(verbs)],
+		})
+	}
+	return rules
+}
+
+func BenchmarkCovers(b *testing.B) {
+	ownerRules := []rbacv1.PolicyRule{
+		{APIGroups: []string{"*"}, Resources: []string{"configmaps", "secrets"}, Verbs: []string{"*"}},
+		{APIGroups: []string{"*"}, Resources: []string{"*"}, Verbs: []string{"get", "list", "watch", "create", "update", "patch", "delete"}},
+	}
+	rules := largeRules(5000)
+	b.Run("rules", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			rbacvalidation.Covers(ownerRules, rules)
+		}
+	})
+	b.Run("canonical rules", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			rbacvalidation.Covers(ownerRules, auth.CanonicalRules(rules))
+		}
+	})
+}
@@ -0,0 +1,191 @@
+package auth
+
+import (
+	"container/list"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/rancher/webhook/pkg/metrics"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+	v1 "k8s.io/api/authorization/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	authorizationv1 "k8s.io/client-go/kubernetes/typed/authorization/v1"
+)
+
+const (
+	// SARCacheTTLEnv is the environment variable used to configure how long SubjectAccessReview results are cached.
+	SARCacheTTLEnv = "CATTLE_SAR_CACHE_TTL"
+	// SARCacheSizeEnv is the environment variable used to configure the maximum number of cached SubjectAccessReview results.
+	SARCacheSizeEnv = "CATTLE_SAR_CACHE_SIZE"
+
+	defaultSARCacheTTL  = 10 * time.Second
+	defaultSARCacheSize = 4096
+)
+
+// SARCacheConfig configures a SubjectAccessReviewCache.
+type SARCacheConfig struct {
+	// TTL is how long a SubjectAccessReview result is cached. A TTL of 0 disables caching.
+	TTL time.Duration
+	// MaxSize is the maximum number of cached results. Once reached, the oldest result is evicted.
+	MaxSize int
+}
+
+// SARCacheConfigFromEnv returns a SARCacheConfig using the values of SARCacheTTLEnv and SARCacheSizeEnv,
+// falling back to the defaults for unset values.
+func SARCacheConfigFromEnv() (SARCacheConfig, error) {
+	config := SARCacheConfig{
+		TTL:     defaultSARCacheTTL,
+		MaxSize: defaultSARCacheSize,
+	}
+	if ttl := os.Getenv(SARCacheTTLEnv); ttl != "" {
+		parsed, err := time.ParseDuration(ttl)
+		if err != nil || parsed < 0 {
+			return config, fmt.Errorf("invalid value '%s' for %s: must be a non-negative duration", ttl, SARCacheTTLEnv)
+		}
+		config.TTL = parsed
+	}
+	if size := os.Getenv(SARCacheSizeEnv); size != "" {
+		parsed, err := strconv.Atoi(size)
+		if err != nil || parsed <= 0 {
+			return config, fmt.Errorf("invalid value '%s' for %s: must be a positive integer", size, SARCacheSizeEnv)
+		}
+		config.MaxSize = parsed
+	}
+	return config, nil
+}
+
+// SubjectAccessReviewCache is a SubjectAccessReviewInterface which caches the results of the wrapped
+// SubjectAccessReviewInterface for a short period of time. Results are keyed on the user (name, UID, groups and extras)
+// and the resource or non-resource attributes of the review. Failed reviews are never cached.
+type SubjectAccessReviewCache struct {
+	sar     authorizationv1.SubjectAccessReviewInterface
+	config  SARCacheConfig
+	now     func() time.Time
+	mutex   sync.Mutex
+	entries map[string]*list.Element
+	// order holds the keys of entries, oldest at the front.
+	order *list.List
+}
+
+type sarCacheEntry struct {
+	key     string
+	status  v1.SubjectAccessReviewStatus
+	expires time.Time
+}
+
+// NewSubjectAccessReviewCache returns a new SubjectAccessReviewCache wrapping the given SubjectAccessReviewInterface.
+func NewSubjectAccessReviewCache(sar authorizationv1.SubjectAccessReviewInterface, config SARCacheConfig) *SubjectAccessReviewCache {
+	return &SubjectAccessReviewCache{
+		sar:     sar,
+		config:  config,
+		now:     time.Now,
+		entries: map[string]*list.Element{},
+		order:   list.New(),
+	}
+}
+
+// Create returns the cached result for an equivalent SubjectAccessReview if one has not expired.
+// Otherwise, the SubjectAccessReview is created with the wrapped SubjectAccessReviewInterface and the result is cached.
+func (s *SubjectAccessReviewCache) Create(ctx context.Context, review *v1.SubjectAccessReview, opts metav1.CreateOptions) (*v1.SubjectAccessReview, error) {
+	if s.config.TTL <= 0 || len(opts.DryRun) > 0 {
+		return s.sar.Create(ctx, review, opts)
+	}
+	key, err := sarCacheKey(review)
+	if err != nil {
+		return s.sar.Create(ctx, review, opts)
+	}
+	if status, ok := s.get(key); ok {
+		metrics.SARCacheRequests.WithLabelValues("hit").Inc()
+		// cache hits don't reach the API server, so they are recorded on the caller's span instead of their own
+		trace.SpanFromContext(ctx).AddEvent("SubjectAccessReview cache hit", trace.WithAttributes(attribute.Bool("allowed", status.Allowed)))
+		result := review.DeepCopy()
+		result.Status = status
+		return result, nil
+	}
+	metrics.SARCacheRequests.WithLabelValues("miss").Inc()
+
+	result, err := s.sar.Create(ctx, review, opts)
+	if err != nil {
+		return result, err
+	}
+	if result.Status.EvaluationError == "" {
+		s.add(key, result.Status)
+	}
+	return result, nil
+}
+
+// Invalidate removes all cached results. It should be called whenever permissions may have changed.
+func (s *SubjectAccessReviewCache) Invalidate() {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if len(s.entries) == 0 {
+		return
+	}
+	metrics.SARCacheEvictions.WithLabelValues("invalidated").Add(float64(len(s.entries)))
+	s.entries = map[string]*list.Element{}
+	s.order.Init()
+	metrics.SARCacheEntries.Set(0)
+}
+
+// Len returns the number of cached results, including expired results that have not been removed yet.
+func (s *SubjectAccessReviewCache) Len() int {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return len(s.entries)
+}
+
+func (s *SubjectAccessReviewCache) get(key string) (v1.SubjectAccessReviewStatus, bool) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	element, ok := s.entries[key]
+	if !ok {
+		return v1.SubjectAccessReviewStatus{}, false
+	}
+	entry := element.Value.(*sarCacheEntry)
+	if !s.now().Before(entry.expires) {
+		s.remove(element)
+		return v1.SubjectAccessReviewStatus{}, false
+	}
+	return entry.status, true
+}
+
+func (s *SubjectAccessReviewCache) add(key string, status v1.SubjectAccessReviewStatus) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if element, ok := s.entries[key]; ok {
+		s.remove(element)
+	}
+	for s.config.MaxSize > 0 && len(s.entries) >= s.config.MaxSize {
+		s.remove(s.order.Front())
+		metrics.SARCacheEvictions.WithLabelValues("size").Inc()
+	}
+	s.entries[key] = s.order.PushBack(&sarCacheEntry{
+		key:     key,
+		status:  status,
+		expires: s.now().Add(s.config.TTL),
+	})
+	metrics.SARCacheEntries.Set(float64(len(s.entries)))
+}
+
+// remove must be called while holding the mutex.
+func (s *SubjectAccessReviewCache) remove(element *list.Element) {
+	delete(s.entries, element.Value.(*sarCacheEntry).key)
+	s.order.Remove(element)
+	metrics.SARCacheEntries.Set(float64(len(s.entries)))
+}
+
+// sarCacheKey returns the key used to cache the result of the given review. The whole spec is used so that reviews
+// for users with different groups or extras never share results.
+func sarCacheKey(review *v1.SubjectAccessReview) (string, error) {
+	key, err := json.Marshal(review.Spec)
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal SubjectAccessReview spec: %w", err)
+	}
+	return string(key), nil
+}
@@ -0,0 +1,203 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	authorizationv1 "k8s.io/api/authorization/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	k8fake "k8s.io/client-go/kubernetes/typed/authorization/v1/fake"
+	k8testing "k8s.io/client-go/testing"
+)
+
+const (
+	allowedUser = "allowed-user"
+	deniedUser  = "denied-user"
+	errorUser   = "error-user"
+)
+
+// newCountingSAR returns a fake SubjectAccessReviewInterface which allows allowedUser, fails for errorUser,
+// and counts the number of reviews created.
+func newCountingSAR(calls *int) *k8fake.FakeSubjectAccessReviews {
+	k8Fake := &k8testing.Fake{}
+	k8Fake.AddReactor("create", "subjectaccessreviews", func(action k8testing.Action) (bool, runtime.Object, error) {
+		*calls++
+		review := action.(k8testing.CreateActionImpl).GetObject().(*authorizationv1.SubjectAccessReview)
+		if review.Spec.User == errorUser {
+			return true, nil, errors.New("expected test error")
+		}
+		review.Status.Allowed = review.Spec.User == allowedUser
+		return true, review, nil
+	})
+	return &k8fake.FakeSubjectAccessReviews{Fake: &k8fake.FakeAuthorizationV1{Fake: k8Fake}}
+}
+
+func newReview(user, verb string, groups ...string) *authorizationv1.SubjectAccessReview {
+	return &authorizationv1.SubjectAccessReview{
+		Spec: authorizationv1.SubjectAccessReviewSpec{
+			ResourceAttributes: &authorizationv1.ResourceAttributes{
+				Verb:     verb,
+				Group:    "management.cattle.io",
+				Version:  "v3",
+				Resource: "globalroles",
+			},
+			User:   user,
+			Groups: groups,
+		},
+	}
+}
+
+func TestSubjectAccessReviewCache(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+
+	t.Run("cached results are reused until they expire", func(t *testing.T) {
+		t.Parallel()
+		var calls int
+		now := time.Now()
+		cache := NewSubjectAccessReviewCache(newCountingSAR(&calls), SARCacheConfig{TTL: time.Minute, MaxSize: 10})
+		cache.now = func() time.Time { return now }
+
+		for i := 0; i < 3; i++ {
+			resp, err := cache.Create(ctx, newReview(allowedUser, "escalate"), metav1.CreateOptions{})
+			require.NoError(t, err)
+			assert.True(t, resp.Status.Allowed)
+		}
+		resp, err := cache.Create(ctx, newReview(deniedUser, "escalate"), metav1.CreateOptions{})
+		require.NoError(t, err)
+		assert.False(t, resp.Status.Allowed)
+		assert.Equal(t, 2, calls)
+
+		now = now.Add(time.Minute)
+		_, err = cache.Create(ctx, newReview(allowedUser, "escalate"), metav1.CreateOptions{})
+		require.NoError(t, err)
+		assert.Equal(t, 3, calls)
+	})
+
+	t.Run("reviews which differ are not shared", func(t *testing.T) {
+		t.Parallel()
+		var calls int
+		cache := NewSubjectAccessReviewCache(newCountingSAR(&calls), SARCacheConfig{TTL: time.Minute, MaxSize: 10})
+
+		_, err := cache.Create(ctx, newReview(allowedUser, "escalate"), metav1.CreateOptions{})
+		require.NoError(t, err)
+		_, err = cache.Create(ctx, newReview(allowedUser, "bind"), metav1.CreateOptions{})
+		require.NoError(t, err)
+		_, err = cache.Create(ctx, newReview(allowedUser, "escalate", "group1"), metav1.CreateOptions{})
+		require.NoError(t, err)
+		assert.Equal(t, 3, calls)
+		assert.Equal(t, 3, cache.Len())
+	})
+
+	t.Run("errors are not cached", func(t *testing.T) {
+		t.Parallel()
+		var calls int
+		cache := NewSubjectAccessReviewCache(newCountingSAR(&calls), SARCacheConfig{TTL: time.Minute, MaxSize: 10})
+
+		for i := 0; i < 2; i++ {
+			_, err := cache.Create(ctx, newReview(errorUser, "escalate"), metav1.CreateOptions{})
+			require.Error(t, err)
+		}
+		assert.Equal(t, 2, calls)
+		assert.Equal(t, 0, cache.Len())
+	})
+
+	t.Run("oldest entry is evicted when full", func(t *testing.T) {
+		t.Parallel()
+		var calls int
+		cache := NewSubjectAccessReviewCache(newCountingSAR(&calls), SARCacheConfig{TTL: time.Minute, MaxSize: 2})
+
+		for _, verb := range []string{"get", "list", "watch"} {
+			_, err := cache.Create(ctx, newReview(allowedUser, verb), metav1.CreateOptions{})
+			require.NoError(t, err)
+		}
+		assert.Equal(t, 2, cache.Len())
+		_, err := cache.Create(ctx, newReview(allowedUser, "watch"), metav1.CreateOptions{})
+		require.NoError(t, err)
+		assert.Equal(t, 3, calls)
+		_, err = cache.Create(ctx, newReview(allowedUser, "get"), metav1.CreateOptions{})
+		require.NoError(t, err)
+		assert.Equal(t, 4, calls)
+	})
+
+	t.Run("invalidate removes all entries", func(t *testing.T) {
+		t.Parallel()
+		var calls int
+		cache := NewSubjectAccessReviewCache(newCountingSAR(&calls), SARCacheConfig{TTL: time.Minute, MaxSize: 10})
+
+		_, err := cache.Create(ctx, newReview(allowedUser, "escalate"), metav1.CreateOptions{})
+		require.NoError(t, err)
+		cache.Invalidate()
+		assert.Equal(t, 0, cache.Len())
+		_, err = cache.Create(ctx, newReview(allowedUser, "escalate"), metav1.CreateOptions{})
+		require.NoError(t, err)
+		assert.Equal(t, 2, calls)
+	})
+
+	t.Run("a TTL of 0 disables caching", func(t *testing.T) {
+		t.Parallel()
+		var calls int
+		cache := NewSubjectAccessReviewCache(newCountingSAR(&calls), SARCacheConfig{})
+
+		for i := 0; i < 2; i++ {
+			_, err := cache.Create(ctx, newReview(allowedUser, "escalate"), metav1.CreateOptions{})
+			require.NoError(t, err)
+		}
+		assert.Equal(t, 2, calls)
+		assert.Equal(t, 0, cache.Len())
+	})
+}
+
+func TestSARCacheConfigFromEnv(t *testing.T) {
+	tests := []struct {
+		name    string
+		ttl     string
+		size    string
+		want    SARCacheConfig
+		wantErr bool
+	}{
+		{
+			name: "defaults",
+			want: SARCacheConfig{TTL: defaultSARCacheTTL, MaxSize: defaultSARCacheSize},
+		},
+		{
+			name: "overrides",
+			ttl:  "30s",
+			size: "100",
+			want: SARCacheConfig{TTL: 30 * time.Second, MaxSize: 100},
+		},
+		{
+			name: "disabled",
+			ttl:  "0s",
+			want: SARCacheConfig{TTL: 0, MaxSize: defaultSARCacheSize},
+		},
+		{
+			name:    "invalid ttl",
+			ttl:     "soon",
+			wantErr: true,
+		},
+		{
+			name:    "invalid size",
+			size:    "0",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv(SARCacheTTLEnv, tt.ttl)
+			t.Setenv(SARCacheSizeEnv, tt.size)
+			got, err := SARCacheConfigFromEnv()
+			if tt.wantErr {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
@@ -0,0 +1,290 @@
+// Package celpolicy evaluates user-defined CEL policies against admission requests once the built-in admitters have
+// allowed them.
+package celpolicy
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"sync/atomic"
+
+	"github.com/google/cel-go/cel"
+	"github.com/rancher/webhook/pkg/admission"
+	"github.com/rancher/webhook/pkg/metrics"
+	"github.com/sirupsen/logrus"
+	admissionv1 "k8s.io/api/admission/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"sigs.k8s.io/yaml"
+)
+
+const (
+	// ConfigMapNamespace is the namespace of the ConfigMap holding the policies.
+	ConfigMapNamespace = "cattle-system"
+	// ConfigMapName is the name of the ConfigMap holding the policies. Each key of the ConfigMap's data is the name of
+	// a policy and its value is the YAML encoded Policy.
+	ConfigMapName = "rancher-webhook-policies"
+
+	// costLimit bounds the cost of evaluating a single expression, matching the per call limit used by Kubernetes.
+	costLimit = 1000000
+)
+
+// FailurePolicy defines how a policy which can not be compiled or evaluated is handled.
+type FailurePolicy string
+
+const (
+	// Fail denies requests matched by a policy which can not be compiled or evaluated.
+	Fail FailurePolicy = "Fail"
+	// Ignore allows requests matched by a policy which can not be compiled or evaluated.
+	Ignore FailurePolicy = "Ignore"
+)
+
+// Policy is a CEL expression evaluated against the admission requests it matches.
+type Policy struct {
+	// Resources are the resources the policy applies to, formatted as "resource.group" (for example
+	// "globalroles.management.cattle.io"), or "*" for all resources.
+	Resources []string `json:"resources"`
+	// Operations are the operations the policy applies to. The policy applies to all operations if empty.
+	Operations []admissionv1.Operation `json:"operations,omitempty"`
+	// Expression must evaluate to true for the request to be allowed. The expression has access to the "request",
+	// "object" and "oldObject" variables. "object" is null on delete and "oldObject" is null on create.
+	Expression string `json:"expression"`
+	// Message is returned when the expression evaluates to false.
+	Message string `json:"message,omitempty"`
+	// FailurePolicy defines how compilation and evaluation errors are handled. Defaults to Fail.
+	FailurePolicy FailurePolicy `json:"failurePolicy,omitempty"`
+}
+
+// compiledPolicy is a named Policy with its compiled program. Exactly one of program and err is set.
+type compiledPolicy struct {
+	Policy
+	name    string
+	program cel.Program
+	err     error
+}
+
+// Engine holds the compiled policies and evaluates them against requests. The zero value is not usable, use NewEngine.
+type Engine struct {
+	env      *cel.Env
+	policies atomic.Pointer[[]*compiledPolicy]
+}
+
+// NewEngine returns a new Engine without any policies.
+func NewEngine() (*Engine, error) {
+	env, err := cel.NewEnv(
+		cel.Variable("request", cel.DynType),
+		cel.Variable("object", cel.DynType),
+		cel.Variable("oldObject", cel.DynType),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create CEL environment: %w", err)
+	}
+	engine := &Engine{env: env}
+	engine.policies.Store(&[]*compiledPolicy{})
+	return engine, nil
+}
+
+// Sync loads the policies from the policy ConfigMap whenever it changes.
+func (e *Engine) Sync(key string, configMap *corev1.ConfigMap) (*corev1.ConfigMap, error) {
+	if key != ConfigMapNamespace+"/"+ConfigMapName {
+		return configMap, nil
+	}
+	if configMap == nil || configMap.DeletionTimestamp != nil {
+		e.Load(nil)
+		return configMap, nil
+	}
+	e.Load(configMap.Data)
+	return configMap, nil
+}
+
+// Load replaces the policies of the engine. Each key of data is the name of a policy and its value is the YAML encoded
+// Policy. Policies which can not be decoded or compiled are kept so that their FailurePolicy can be applied.
+func (e *Engine) Load(data map[string]string) {
+	names := make([]string, 0, len(data))
+	for name := range data {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	policies := make([]*compiledPolicy, 0, len(names))
+	var invalid int
+	for _, name := range names {
+		policy := e.compile(name, data[name])
+		if policy.err != nil {
+			invalid++
+			logrus.Errorf("[celpolicy] policy %s is invalid: %v", name, policy.err)
+		}
+		policies = append(policies, policy)
+	}
+	e.policies.Store(&policies)
+	metrics.ExternalPolicies.WithLabelValues("active").Set(float64(len(policies) - invalid))
+	metrics.ExternalPolicies.WithLabelValues("invalid").Set(float64(invalid))
+	logrus.Infof("[celpolicy] loaded %d policies, %d invalid", len(policies), invalid)
+}
+
+// compile decodes and compiles a single policy.
+func (e *Engine) compile(name, data string) *compiledPolicy {
+	policy := &compiledPolicy{name: name}
+	if err := yaml.UnmarshalStrict([]byte(data), &policy.Policy); err != nil {
+		// Without a valid policy, it is unknown which requests are matched, so match all of them.
+		policy.Resources = []string{"*"}
+		policy.err = fmt.Errorf("failed to decode policy: %w", err)
+		return policy
+	}
+	switch policy.FailurePolicy {
+	case "":
+		policy.FailurePolicy = Fail
+	case Fail, Ignore:
+	default:
+		policy.FailurePolicy = Fail
+		policy.err = fmt.Errorf("unsupported failurePolicy %q: must be %q or %q", policy.FailurePolicy, Fail, Ignore)
+		return policy
+	}
+	if len(policy.Resources) == 0 {
+		policy.err = fmt.Errorf("at least one resource must be set")
+		return policy
+	}
+
+	ast, issues := e.env.Compile(policy.Expression)
+	if issues != nil && issues.Err() != nil {
+		policy.err = fmt.Errorf("failed to compile expression: %w", issues.Err())
+		return policy
+	}
+	if !ast.OutputType().IsExactType(cel.BoolType) && !ast.OutputType().IsExactType(cel.DynType) {
+		policy.err = fmt.Errorf("expression must evaluate to a bool, not %s", ast.OutputType())
+		return policy
+	}
+	program, err := e.env.Program(ast, cel.CostLimit(costLimit))
+	if err != nil {
+		policy.err = fmt.Errorf("failed to create program: %w", err)
+		return policy
+	}
+	policy.program = program
+	return policy
+}
+
+// Evaluate evaluates all the policies matching the request. A nil response is returned if the request is allowed by
+// all policies.
+func (e *Engine) Evaluate(request *admission.Request) (*admissionv1.AdmissionResponse, error) {
+	policies := *e.policies.Load()
+	if len(policies) == 0 {
+		return nil, nil
+	}
+
+	var activation map[string]any
+	for _, policy := range policies {
+		if !policy.matches(request) {
+			continue
+		}
+		if activation == nil {
+			var err error
+			if activation, err = newActivation(request); err != nil {
+				return nil, err
+			}
+		}
+		allowed, err := policy.evaluate(activation)
+		if err != nil {
+			metrics.ExternalPolicyEvaluations.WithLabelValues(policy.name, metrics.ResultError).Inc()
+			if policy.FailurePolicy == Ignore {
+				logrus.Warnf("[celpolicy] ignoring policy %s which could not be evaluated: %v", policy.name, err)
+				continue
+			}
+			return admission.ResponseBadRequest(fmt.Sprintf("policy %s could not be evaluated: %v", policy.name, err)), nil
+		}
+		if !allowed {
+			metrics.ExternalPolicyEvaluations.WithLabelValues(policy.name, metrics.ResultDenied).Inc()
+			message := policy.Message
+			if message == "" {
+				message = fmt.Sprintf("denied by policy %s", policy.name)
+			}
+			return admission.ResponseFailedEscalation(message), nil
+		}
+		metrics.ExternalPolicyEvaluations.WithLabelValues(policy.name, metrics.ResultAllowed).Inc()
+	}
+	return nil, nil
+}
+
+// matches returns true if the policy applies to the request.
+func (p *compiledPolicy) matches(request *admission.Request) bool {
+	if len(p.Operations) > 0 {
+		found := false
+		for _, op := range p.Operations {
+			if strings.EqualFold(string(op), string(request.Operation)) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	resource := schema.GroupResource{Group: request.Resource.Group, Resource: request.Resource.Resource}.String()
+	for _, r := range p.Resources {
+		if r == "*" || r == resource {
+			return true
+		}
+	}
+	return false
+}
+
+func (p *compiledPolicy) evaluate(activation map[string]any) (bool, error) {
+	if p.err != nil {
+		return false, p.err
+	}
+	out, _, err := p.program.Eval(activation)
+	if err != nil {
+		return false, err
+	}
+	allowed, ok := out.Value().(bool)
+	if !ok {
+		return false, fmt.Errorf("expression evaluated to %s, not a bool", out.Type().TypeName())
+	}
+	return allowed, nil
+}
+
+// newActivation returns the variables available to the expressions for the given request.
+func newActivation(request *admission.Request) (map[string]any, error) {
+	object, err := decodeRaw(request.Object.Raw)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode object: %w", err)
+	}
+	oldObject, err := decodeRaw(request.OldObject.Raw)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode oldObject: %w", err)
+	}
+	groups := make([]any, 0, len(request.UserInfo.Groups))
+	for _, group := range request.UserInfo.Groups {
+		groups = append(groups, group)
+	}
+	return map[string]any{
+		"request": map[string]any{
+			"operation":   string(request.Operation),
+			"namespace":   request.Namespace,
+			"name":        request.Name,
+			"subResource": request.SubResource,
+			"resource": map[string]any{
+				"group":    request.Resource.Group,
+				"version":  request.Resource.Version,
+				"resource": request.Resource.Resource,
+			},
+			"userInfo": map[string]any{
+				"username": request.UserInfo.Username,
+				"groups":   groups,
+			},
+		},
+		"object":    object,
+		"oldObject": oldObject,
+	}, nil
+}
+
+func decodeRaw(raw []byte) (any, error) {
+	if len(raw) == 0 {
+		return nil, nil
+	}
+	var obj map[string]any
+	if err := json.Unmarshal(raw, &obj); err != nil {
+		return nil, err
+	}
+	return obj, nil
+}
@@ -0,0 +1,321 @@
+package celpolicy_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/rancher/webhook/pkg/admission"
+	"github.com/rancher/webhook/pkg/celpolicy"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	admissionv1 "k8s.io/api/admission/v1"
+	v1 "k8s.io/api/admissionregistration/v1"
+	authenticationv1 "k8s.io/api/authentication/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+const (
+	requireDisplayName = `
+resources: ["globalroles.management.cattle.io"]
+operations: ["CREATE"]
+expression: has(object.displayName) && object.displayName != ""
+message: global roles must have a display name
+`
+	adminOnly = `
+resources: ["*"]
+expression: request.userInfo.username == "admin"
+`
+	invalidExpression = `
+resources: ["globalroles.management.cattle.io"]
+expression: object.displayName ==
+`
+	invalidExpressionIgnored = `
+resources: ["globalroles.management.cattle.io"]
+expression: object.displayName ==
+failurePolicy: Ignore
+`
+	nonBoolExpression = `
+resources: ["globalroles.management.cattle.io"]
+expression: object.displayName
+`
+	missingField = `
+resources: ["globalroles.management.cattle.io"]
+expression: object.displayName != ""
+`
+	unknownField = `
+resources: ["globalroles.management.cattle.io"]
+expresion: "true"
+`
+)
+
+func newRequest(t *testing.T, operation admissionv1.Operation, username string, object map[string]any) *admission.Request {
+	t.Helper()
+	request := &admission.Request{
+		AdmissionRequest: admissionv1.AdmissionRequest{
+			Operation: operation,
+			Resource:  metav1.GroupVersionResource{Group: "management.cattle.io", Version: "v3", Resource: "globalroles"},
+			Name:      "test-gr",
+			UserInfo:  authenticationv1.UserInfo{Username: username},
+		},
+		Context: context.Background(),
+	}
+	if object != nil {
+		raw, err := json.Marshal(object)
+		require.NoError(t, err)
+		request.Object.Raw = raw
+	}
+	return request
+}
+
+func TestEvaluate(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name        string
+		policies    map[string]string
+		request     func(t *testing.T) *admission.Request
+		wantAllowed bool
+		wantCode    int32
+		wantMessage string
+	}{
+		{
+			name:     "no policies",
+			policies: nil,
+			request: func(t *testing.T) *admission.Request {
+				return newRequest(t, admissionv1.Create, "user", map[string]any{})
+			},
+			wantAllowed: true,
+		},
+		{
+			name:     "expression evaluates to true",
+			policies: map[string]string{"display-name": requireDisplayName},
+			request: func(t *testing.T) *admission.Request {
+				return newRequest(t, admissionv1.Create, "user", map[string]any{"displayName": "Test"})
+			},
+			wantAllowed: true,
+		},
+		{
+			name:     "expression evaluates to false",
+			policies: map[string]string{"display-name": requireDisplayName},
+			request: func(t *testing.T) *admission.Request {
+				return newRequest(t, admissionv1.Create, "user", map[string]any{})
+			},
+			wantCode:    http.StatusForbidden,
+			wantMessage: "global roles must have a display name",
+		},
+		{
+			name:     "operation not matched",
+			policies: map[string]string{"display-name": requireDisplayName},
+			request: func(t *testing.T) *admission.Request {
+				return newRequest(t, admissionv1.Update, "user", map[string]any{})
+			},
+			wantAllowed: true,
+		},
+		{
+			name:     "resource not matched",
+			policies: map[string]string{"display-name": requireDisplayName},
+			request: func(t *testing.T) *admission.Request {
+				request := newRequest(t, admissionv1.Create, "user", map[string]any{})
+				request.Resource.Resource = "roletemplates"
+				return request
+			},
+			wantAllowed: true,
+		},
+		{
+			name:     "default message",
+			policies: map[string]string{"admin-only": adminOnly},
+			request: func(t *testing.T) *admission.Request {
+				return newRequest(t, admissionv1.Delete, "user", nil)
+			},
+			wantCode:    http.StatusForbidden,
+			wantMessage: "denied by policy admin-only",
+		},
+		{
+			name:     "all policies must allow",
+			policies: map[string]string{"admin-only": adminOnly, "display-name": requireDisplayName},
+			request: func(t *testing.T) *admission.Request {
+				return newRequest(t, admissionv1.Create, "admin", map[string]any{})
+			},
+			wantCode:    http.StatusForbidden,
+			wantMessage: "global roles must have a display name",
+		},
+		{
+			name:     "invalid expression fails closed",
+			policies: map[string]string{"invalid": invalidExpression},
+			request: func(t *testing.T) *admission.Request {
+				return newRequest(t, admissionv1.Create, "user", map[string]any{"displayName": "Test"})
+			},
+			wantCode:    http.StatusBadRequest,
+			wantMessage: "policy invalid could not be evaluated",
+		},
+		{
+			name:     "invalid expression is ignored",
+			policies: map[string]string{"invalid": invalidExpressionIgnored},
+			request: func(t *testing.T) *admission.Request {
+				return newRequest(t, admissionv1.Create, "user", map[string]any{"displayName": "Test"})
+			},
+			wantAllowed: true,
+		},
+		{
+			name:     "expression not evaluating to a bool",
+			policies: map[string]string{"non-bool": nonBoolExpression},
+			request: func(t *testing.T) *admission.Request {
+				return newRequest(t, admissionv1.Create, "user", map[string]any{"displayName": "Test"})
+			},
+			wantCode:    http.StatusBadRequest,
+			wantMessage: "not a bool",
+		},
+		{
+			name:     "evaluation error fails closed",
+			policies: map[string]string{"missing-field": missingField},
+			request: func(t *testing.T) *admission.Request {
+				return newRequest(t, admissionv1.Create, "user", map[string]any{})
+			},
+			wantCode:    http.StatusBadRequest,
+			wantMessage: "policy missing-field could not be evaluated",
+		},
+		{
+			name:     "policy with unknown field fails closed for all resources",
+			policies: map[string]string{"unknown": unknownField},
+			request: func(t *testing.T) *admission.Request {
+				request := newRequest(t, admissionv1.Create, "user", map[string]any{})
+				request.Resource.Resource = "roletemplates"
+				return request
+			},
+			wantCode:    http.StatusBadRequest,
+			wantMessage: "failed to decode policy",
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			engine, err := celpolicy.NewEngine()
+			require.NoError(t, err)
+			engine.Load(tt.policies)
+
+			response, err := engine.Evaluate(tt.request(t))
+			require.NoError(t, err)
+			if tt.wantAllowed {
+				assert.Nil(t, response)
+				return
+			}
+			require.NotNil(t, response)
+			assert.False(t, response.Allowed)
+			assert.Equal(t, tt.wantCode, response.Result.Code)
+			assert.Contains(t, response.Result.Message, tt.wantMessage)
+		})
+	}
+}
+
+func TestSync(t *testing.T) {
+	t.Parallel()
+	engine, err := celpolicy.NewEngine()
+	require.NoError(t, err)
+	key := celpolicy.ConfigMapNamespace + "/" + celpolicy.ConfigMapName
+	configMap := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Namespace: celpolicy.ConfigMapNamespace, Name: celpolicy.ConfigMapName},
+		Data:       map[string]string{"admin-only": adminOnly},
+	}
+
+	_, err = engine.Sync("cattle-system/other", &corev1.ConfigMap{Data: configMap.Data})
+	require.NoError(t, err)
+	response, err := engine.Evaluate(newRequest(t, admissionv1.Delete, "user", nil))
+	require.NoError(t, err)
+	assert.Nil(t, response, "policies must only be loaded from the policy ConfigMap")
+
+	_, err = engine.Sync(key, configMap)
+	require.NoError(t, err)
+	response, err = engine.Evaluate(newRequest(t, admissionv1.Delete, "user", nil))
+	require.NoError(t, err)
+	require.NotNil(t, response)
+	assert.False(t, response.Allowed)
+
+	_, err = engine.Sync(key, nil)
+	require.NoError(t, err)
+	response, err = engine.Evaluate(newRequest(t, admissionv1.Delete, "user", nil))
+	require.NoError(t, err)
+	assert.Nil(t, response, "policies must be removed with the ConfigMap")
+}
+
+type fakeAdmitter struct {
+	response *admissionv1.AdmissionResponse
+	called   bool
+}
+
+func (f *fakeAdmitter) Admit(_ *admission.Request) (*admissionv1.AdmissionResponse, error) {
+	f.called = true
+	return f.response, nil
+}
+
+type fakeValidator struct {
+	admitters []admission.Admitter
+}
+
+func (f *fakeValidator) GVR() schema.GroupVersionResource {
+	return schema.GroupVersionResource{Group: "management.cattle.io", Version: "v3", Resource: "globalroles"}
+}
+
+func (f *fakeValidator) Operations() []v1.OperationType {
+	return []v1.OperationType{v1.Create}
+}
+
+func (f *fakeValidator) ValidatingWebhook(clientConfig v1.WebhookClientConfig) []v1.ValidatingWebhook {
+	return []v1.ValidatingWebhook{*admission.NewDefaultValidatingWebhook(f, clientConfig, v1.ClusterScope, f.Operations())}
+}
+
+func (f *fakeValidator) Admitters() []admission.Admitter {
+	return f.admitters
+}
+
+type fakeSubresourceValidator struct {
+	fakeValidator
+}
+
+func (f *fakeSubresourceValidator) Subresources() []string {
+	return []string{"status"}
+}
+
+func TestWrapValidators(t *testing.T) {
+	t.Parallel()
+	engine, err := celpolicy.NewEngine()
+	require.NoError(t, err)
+	engine.Load(map[string]string{"display-name": requireDisplayName})
+
+	warning := admission.ResponseAllowed()
+	warning.Warnings = []string{"test warning"}
+	allowing := &fakeAdmitter{response: warning}
+	denying := &fakeAdmitter{response: admission.ResponseBadRequest("denied by the admitter")}
+
+	validators := celpolicy.WrapValidators(engine, []admission.ValidatingAdmissionHandler{
+		&fakeValidator{admitters: []admission.Admitter{allowing}},
+		&fakeSubresourceValidator{fakeValidator{admitters: []admission.Admitter{denying, allowing}}},
+	})
+	require.Len(t, validators, 2)
+	_, ok := validators[1].(admission.SubresourceValidator)
+	assert.True(t, ok, "wrapped validators must keep their subresources")
+
+	admitters := validators[0].Admitters()
+	require.Len(t, admitters, 1)
+	response, err := admitters[0].Admit(newRequest(t, admissionv1.Create, "user", map[string]any{"displayName": "Test"}))
+	require.NoError(t, err)
+	assert.True(t, response.Allowed)
+	assert.Equal(t, []string{"test warning"}, response.Warnings, "warnings of the built-in admitters must be kept")
+
+	response, err = admitters[0].Admit(newRequest(t, admissionv1.Create, "user", map[string]any{}))
+	require.NoError(t, err)
+	assert.False(t, response.Allowed)
+	assert.Equal(t, "global roles must have a display name", response.Result.Message)
+
+	allowing.called = false
+	admitters = validators[1].Admitters()
+	require.Len(t, admitters, 1)
+	response, err = admitters[0].Admit(newRequest(t, admissionv1.Create, "user", map[string]any{"displayName": "Test"}))
+	require.NoError(t, err)
+	assert.False(t, response.Allowed)
+	assert.Equal(t, "denied by the admitter", response.Result.Message)
+	assert.False(t, allowing.called, "admitters after a denial must not be called")
+}
@@ -0,0 +1,165 @@
+package celpolicy
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/rancher/webhook/pkg/admission"
+	admissionv1 "k8s.io/api/admission/v1"
+	authenticationv1 "k8s.io/api/authentication/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"sigs.k8s.io/yaml"
+)
+
+// TestSuite is a list of example requests with the outcome expected from evaluating the policies against them.
+type TestSuite struct {
+	Tests []TestCase `json:"tests"`
+}
+
+// TestCase is an example request and its expected outcome.
+type TestCase struct {
+	// Name identifies the test case in the results.
+	Name string `json:"name"`
+	// Request describes the admission request evaluated by the policies.
+	Request TestRequest `json:"request"`
+	// Object is the object of the request. It should be unset for DELETE requests.
+	Object map[string]any `json:"object,omitempty"`
+	// OldObject is the existing object of the request. It should be unset for CREATE requests.
+	OldObject map[string]any `json:"oldObject,omitempty"`
+	// Allowed is the expected outcome of the policies.
+	Allowed bool `json:"allowed"`
+	// Message, if set, must be contained in the message returned when the request is denied.
+	Message string `json:"message,omitempty"`
+}
+
+// TestRequest holds the attributes of an admission request which are available to the policies.
+type TestRequest struct {
+	// Operation is the operation of the request, such as CREATE.
+	Operation admissionv1.Operation `json:"operation"`
+	// Resource is the resource of the request formatted as "resource.group", for example "globalroles.management.cattle.io".
+	Resource string `json:"resource"`
+	// Version, Namespace, Name and SubResource are optional.
+	Version     string `json:"version,omitempty"`
+	Namespace   string `json:"namespace,omitempty"`
+	Name        string `json:"name,omitempty"`
+	SubResource string `json:"subResource,omitempty"`
+	// UserInfo is the user making the request.
+	UserInfo authenticationv1.UserInfo `json:"userInfo,omitempty"`
+}
+
+// TestResult is the outcome of a TestCase. Err is nil if the test case passed.
+type TestResult struct {
+	Name string
+	Err  error
+}
+
+// LoadTestSuite decodes a YAML encoded TestSuite.
+func LoadTestSuite(data []byte) (*TestSuite, error) {
+	suite := &TestSuite{}
+	if err := yaml.UnmarshalStrict(data, suite); err != nil {
+		return nil, fmt.Errorf("failed to decode test suite: %w", err)
+	}
+	for i, test := range suite.Tests {
+		if test.Name == "" {
+			return nil, fmt.Errorf("test %d has no name", i)
+		}
+		if test.Request.Operation == "" || test.Request.Resource == "" {
+			return nil, fmt.Errorf("test %s must set request.operation and request.resource", test.Name)
+		}
+	}
+	return suite, nil
+}
+
+// LoadPolicyConfigMap decodes a YAML encoded ConfigMap holding policies, as applied to the cluster, and returns its data.
+func LoadPolicyConfigMap(data []byte) (map[string]string, error) {
+	configMap := &corev1.ConfigMap{}
+	if err := yaml.Unmarshal(data, configMap); err != nil {
+		return nil, fmt.Errorf("failed to decode ConfigMap: %w", err)
+	}
+	if configMap.Kind != "ConfigMap" {
+		return nil, fmt.Errorf("expected a ConfigMap, got kind %q", configMap.Kind)
+	}
+	return configMap.Data, nil
+}
+
+// Errors returns the errors of the policies which could not be decoded or compiled, by policy name.
+func (e *Engine) Errors() map[string]error {
+	errs := map[string]error{}
+	for _, policy := range *e.policies.Load() {
+		if policy.err != nil {
+			errs[policy.name] = policy.err
+		}
+	}
+	return errs
+}
+
+// RunTests evaluates the policies of the engine against each test case of the suite.
+func (e *Engine) RunTests(suite *TestSuite) []TestResult {
+	results := make([]TestResult, 0, len(suite.Tests))
+	for _, test := range suite.Tests {
+		results = append(results, TestResult{Name: test.Name, Err: e.runTest(test)})
+	}
+	return results
+}
+
+func (e *Engine) runTest(test TestCase) error {
+	request, err := test.admissionRequest()
+	if err != nil {
+		return err
+	}
+	response, err := e.Evaluate(request)
+	if err != nil {
+		return fmt.Errorf("failed to evaluate policies: %w", err)
+	}
+
+	allowed := response == nil || response.Allowed
+	if allowed != test.Allowed {
+		if allowed {
+			return fmt.Errorf("expected the request to be denied, but it was allowed")
+		}
+		return fmt.Errorf("expected the request to be allowed, but it was denied: %s", response.Result.Message)
+	}
+	if !allowed && test.Message != "" && !strings.Contains(response.Result.Message, test.Message) {
+		return fmt.Errorf("expected the denial message to contain %q, got %q", test.Message, response.Result.Message)
+	}
+	return nil
+}
+
+// admissionRequest returns the admission request described by the test case.
+func (t *TestCase) admissionRequest() (*admission.Request, error) {
+	groupResource := schema.ParseGroupResource(t.Request.Resource)
+	request := &admission.Request{
+		Context: context.Background(),
+		AdmissionRequest: admissionv1.AdmissionRequest{
+			Operation: admissionv1.Operation(strings.ToUpper(string(t.Request.Operation))),
+			Resource: metav1.GroupVersionResource{
+				Group:    groupResource.Group,
+				Version:  t.Request.Version,
+				Resource: groupResource.Resource,
+			},
+			Namespace:   t.Request.Namespace,
+			Name:        t.Request.Name,
+			SubResource: t.Request.SubResource,
+			UserInfo:    t.Request.UserInfo,
+		},
+	}
+	if t.Object != nil {
+		raw, err := json.Marshal(t.Object)
+		if err != nil {
+			return nil, fmt.Errorf("failed to encode object: %w", err)
+		}
+		request.Object.Raw = raw
+	}
+	if t.OldObject != nil {
+		raw, err := json.Marshal(t.OldObject)
+		if err != nil {
+			return nil, fmt.Errorf("failed to encode oldObject: %w", err)
+		}
+		request.OldObject.Raw = raw
+	}
+	return request, nil
+}
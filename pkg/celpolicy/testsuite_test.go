@@ -0,0 +1,144 @@
+package celpolicy_test
+
+import (
+	"testing"
+
+	"github.com/rancher/webhook/pkg/celpolicy"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	policyConfigMap = `
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: rancher-webhook-policies
+  namespace: cattle-system
+data:
+  display-name: |
+    resources: ["globalroles.management.cattle.io"]
+    operations: ["CREATE"]
+    expression: has(object.displayName) && object.displayName != ""
+    message: global roles must have a display name
+  invalid: |
+    resources: ["globalroles.management.cattle.io"]
+    expression: object.displayName ==
+    failurePolicy: Ignore
+`
+	testSuite = `
+tests:
+- name: with a display name
+  request:
+    operation: CREATE
+    resource: globalroles.management.cattle.io
+    userInfo:
+      username: admin
+  object:
+    displayName: Test
+  allowed: true
+- name: without a display name
+  request:
+    operation: create
+    resource: globalroles.management.cattle.io
+  object: {}
+  allowed: false
+  message: display name
+- name: wrong expected message
+  request:
+    operation: CREATE
+    resource: globalroles.management.cattle.io
+  object: {}
+  allowed: false
+  message: something else
+- name: wrong expected outcome
+  request:
+    operation: CREATE
+    resource: globalroles.management.cattle.io
+  object: {}
+  allowed: true
+- name: other resource
+  request:
+    operation: CREATE
+    resource: roletemplates.management.cattle.io
+  object: {}
+  allowed: true
+`
+)
+
+func TestRunTests(t *testing.T) {
+	t.Parallel()
+	policies, err := celpolicy.LoadPolicyConfigMap([]byte(policyConfigMap))
+	require.NoError(t, err)
+	suite, err := celpolicy.LoadTestSuite([]byte(testSuite))
+	require.NoError(t, err)
+
+	engine, err := celpolicy.NewEngine()
+	require.NoError(t, err)
+	engine.Load(policies)
+
+	errs := engine.Errors()
+	require.Len(t, errs, 1)
+	assert.Contains(t, errs, "invalid")
+
+	results := engine.RunTests(suite)
+	require.Len(t, results, 5)
+	passed := map[string]bool{}
+	for _, result := range results {
+		passed[result.Name] = result.Err == nil
+	}
+	assert.Equal(t, map[string]bool{
+		"with a display name":    true,
+		"without a display name": true,
+		"wrong expected message": false,
+		"wrong expected outcome": false,
+		"other resource":         true,
+	}, passed)
+}
+
+func TestLoadTestSuite(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name    string
+		data    string
+		wantErr bool
+	}{
+		{
+			name: "valid",
+			data: testSuite,
+		},
+		{
+			name:    "unknown field",
+			data:    "tests:\n- name: test\n  request: {operation: CREATE, resource: globalroles.management.cattle.io}\n  alowed: true\n",
+			wantErr: true,
+		},
+		{
+			name:    "missing name",
+			data:    "tests:\n- request: {operation: CREATE, resource: globalroles.management.cattle.io}\n",
+			wantErr: true,
+		},
+		{
+			name:    "missing resource",
+			data:    "tests:\n- name: test\n  request: {operation: CREATE}\n",
+			wantErr: true,
+		},
+	}
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+			_, err := celpolicy.LoadTestSuite([]byte(test.data))
+			if test.wantErr {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestLoadPolicyConfigMap(t *testing.T) {
+	t.Parallel()
+	_, err := celpolicy.LoadPolicyConfigMap([]byte("apiVersion: v1\nkind: Secret\n"))
+	assert.Error(t, err)
+}
@@ -0,0 +1,73 @@
+package celpolicy
+
+import (
+	"github.com/rancher/webhook/pkg/admission"
+	admissionv1 "k8s.io/api/admission/v1"
+)
+
+// WrapValidators returns the given validators with the engine's policies evaluated after each validator's admitters.
+// The policies are only evaluated for requests allowed by all of the validator's admitters.
+func WrapValidators(engine *Engine, validators []admission.ValidatingAdmissionHandler) []admission.ValidatingAdmissionHandler {
+	wrapped := make([]admission.ValidatingAdmissionHandler, 0, len(validators))
+	for _, validator := range validators {
+		v := &validatorWithPolicies{ValidatingAdmissionHandler: validator, engine: engine}
+		if subresourceValidator, ok := validator.(admission.SubresourceValidator); ok {
+			wrapped = append(wrapped, &subresourceValidatorWithPolicies{validatorWithPolicies: v, subresources: subresourceValidator})
+			continue
+		}
+		wrapped = append(wrapped, v)
+	}
+	return wrapped
+}
+
+// validatorWithPolicies is a ValidatingAdmissionHandler which evaluates the engine's policies after the wrapped
+// handler's admitters.
+type validatorWithPolicies struct {
+	admission.ValidatingAdmissionHandler
+	engine *Engine
+}
+
+// Admitters returns a single admitter running the wrapped handler's admitters followed by the engine's policies.
+func (v *validatorWithPolicies) Admitters() []admission.Admitter {
+	return []admission.Admitter{&policyAdmitter{admitters: v.ValidatingAdmissionHandler.Admitters(), engine: v.engine}}
+}
+
+// subresourceValidatorWithPolicies keeps the admission.SubresourceValidator implementation of the wrapped handler.
+type subresourceValidatorWithPolicies struct {
+	*validatorWithPolicies
+	subresources admission.SubresourceValidator
+}
+
+// Subresources returns the subresources of the wrapped handler.
+func (s *subresourceValidatorWithPolicies) Subresources() []string {
+	return s.subresources.Subresources()
+}
+
+type policyAdmitter struct {
+	admitters []admission.Admitter
+	engine    *Engine
+}
+
+// Admit runs the built-in admitters and, if they all allowed the request, the engine's policies. The response of the
+// last built-in admitter is returned when the policies allow the request so that its warnings are kept.
+func (p *policyAdmitter) Admit(request *admission.Request) (*admissionv1.AdmissionResponse, error) {
+	var response *admissionv1.AdmissionResponse
+	for _, admitter := range p.admitters {
+		if admitter == nil {
+			continue
+		}
+		var err error
+		response, err = admitter.Admit(request)
+		if err != nil || response == nil || !response.Allowed {
+			return response, err
+		}
+	}
+	denied, err := p.engine.Evaluate(request)
+	if err != nil || denied != nil {
+		return denied, err
+	}
+	if response == nil {
+		return admission.ResponseAllowed(), nil
+	}
+	return response, nil
+}
@@ -2,15 +2,26 @@ package clients
 
 import (
 	"context"
+	"fmt"
+	"slices"
 
+	v3 "github.com/rancher/rancher/pkg/apis/management.cattle.io/v3"
+	webhookv1 "github.com/rancher/webhook/pkg/apis/webhook.cattle.io/v1"
 	"github.com/rancher/webhook/pkg/auth"
 	"github.com/rancher/webhook/pkg/generated/controllers/management.cattle.io"
 	managementv3 "github.com/rancher/webhook/pkg/generated/controllers/management.cattle.io/v3"
 	"github.com/rancher/webhook/pkg/generated/controllers/provisioning.cattle.io"
 	provv1 "github.com/rancher/webhook/pkg/generated/controllers/provisioning.cattle.io/v1"
+	"github.com/rancher/webhook/pkg/resolvers"
+	"github.com/rancher/webhook/pkg/tenantpolicy"
 	"github.com/rancher/wrangler/v3/pkg/clients"
+	"github.com/rancher/wrangler/v3/pkg/generic"
 	"github.com/rancher/wrangler/v3/pkg/schemes"
+	"github.com/sirupsen/logrus"
 	v1 "k8s.io/api/admissionregistration/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/client-go/rest"
 	"k8s.io/kubernetes/pkg/registry/rbac/validation"
 )
@@ -23,7 +34,12 @@ type Clients struct {
 	Provisioning           provv1.Interface
 	RoleTemplateResolver   *auth.RoleTemplateResolver
 	GlobalRoleResolver     *auth.GlobalRoleResolver
+	CRTBResolver           *resolvers.CRTBRuleResolver
+	PRTBResolver           *resolvers.PRTBRuleResolver
 	DefaultResolver        validation.AuthorizationRuleResolver
+	SubjectAccessReviews   *auth.SubjectAccessReviewCache
+	// TenantPolicies is nil when the TenantPolicy CRD isn't installed.
+	TenantPolicies *tenantpolicy.Resolver
 }
 
 func New(ctx context.Context, rest *rest.Config, mcmEnabled bool) (*Clients, error) {
@@ -46,6 +62,10 @@ func New(ctx context.Context, rest *rest.Config, mcmEnabled bool) (*Clients, err
 		return nil, err
 	}
 
+	if err := trimInformers(clients, mgmt, prov, mcmEnabled); err != nil {
+		return nil, err
+	}
+
 	if err = mgmt.Start(ctx, 5); err != nil {
 		return nil, err
 	}
@@ -57,18 +77,106 @@ func New(ctx context.Context, rest *rest.Config, mcmEnabled bool) (*Clients, err
 		ClusterRoleBindings: clients.RBAC.ClusterRoleBinding().Cache(),
 	}
 
+	sarCacheConfig, err := auth.SARCacheConfigFromEnv()
+	if err != nil {
+		return nil, err
+	}
+	sarCache := auth.NewSubjectAccessReviewCache(clients.K8s.AuthorizationV1().SubjectAccessReviews(), sarCacheConfig)
+	registerSARCacheInvalidation(ctx, clients, sarCache)
+
 	result := &Clients{
 		Clients:                *clients,
 		Management:             mgmt.Management().V3(),
 		Provisioning:           prov.Provisioning().V1(),
 		MultiClusterManagement: mcmEnabled,
 		DefaultResolver:        validation.NewDefaultRuleResolver(rbacRestGetter, rbacRestGetter, rbacRestGetter, rbacRestGetter),
+		SubjectAccessReviews:   sarCache,
+	}
+
+	result.TenantPolicies, err = newTenantPolicyResolver(clients)
+	if err != nil {
+		return nil, err
 	}
 
 	if mcmEnabled {
 		result.RoleTemplateResolver = auth.NewRoleTemplateResolver(mgmt.Management().V3().RoleTemplate().Cache(), clients.RBAC.ClusterRole().Cache())
+		registerRoleTemplateResolverInvalidation(ctx, clients, mgmt, result.RoleTemplateResolver)
 		result.GlobalRoleResolver = auth.NewGlobalRoleResolver(result.RoleTemplateResolver, mgmt.Management().V3().GlobalRole().Cache())
+		result.CRTBResolver = resolvers.NewCRTBRuleResolver(mgmt.Management().V3().ClusterRoleTemplateBinding().Cache(), result.RoleTemplateResolver)
+		result.PRTBResolver = resolvers.NewPRTBRuleResolver(mgmt.Management().V3().ProjectRoleTemplateBinding().Cache(), result.RoleTemplateResolver)
 	}
 
 	return result, nil
 }
+
+// newTenantPolicyResolver returns a resolver of the cached TenantPolicies, or nil if the TenantPolicy CRD isn't
+// installed, since the cache of a resource which isn't served never syncs.
+func newTenantPolicyResolver(clients *clients.Clients) (*tenantpolicy.Resolver, error) {
+	groupVersion := webhookv1.SchemeGroupVersion
+	resources, err := clients.K8s.Discovery().ServerResourcesForGroupVersion(groupVersion.String())
+	if err != nil && !apierrors.IsNotFound(err) {
+		return nil, fmt.Errorf("failed to discover the resources of %s: %w", groupVersion, err)
+	}
+	if resources == nil || !slices.ContainsFunc(resources.APIResources, func(resource metav1.APIResource) bool {
+		return resource.Name == webhookv1.TenantPolicyResourceName
+	}) {
+		logrus.Infof("[clients] %s.%s are not served, tenant policies are disabled", webhookv1.TenantPolicyResourceName, groupVersion.Group)
+		return nil, nil
+	}
+	if err := schemes.Register(webhookv1.AddToScheme); err != nil {
+		return nil, err
+	}
+	controller := generic.NewController[*webhookv1.TenantPolicy, *webhookv1.TenantPolicyList](groupVersion.WithKind("TenantPolicy"),
+		webhookv1.TenantPolicyResourceName, true, clients.SharedControllerFactory)
+	return tenantpolicy.NewResolver(controller.Cache()), nil
+}
+
+// registerRoleTemplateResolverInvalidation forgets the rules memoized by the RoleTemplateResolver whenever one of the
+// RoleTemplates or ClusterRoles they were resolved from changes or is deleted.
+func registerRoleTemplateResolverInvalidation(ctx context.Context, clients *clients.Clients, mgmt *management.Factory, resolver *auth.RoleTemplateResolver) {
+	mgmt.Management().V3().RoleTemplate().OnChange(ctx, "roletemplate-resolver-roletemplate", func(name string, obj *v3.RoleTemplate) (*v3.RoleTemplate, error) {
+		resolver.InvalidateRoleTemplate(name)
+		return obj, nil
+	})
+	clients.RBAC.ClusterRole().OnChange(ctx, "roletemplate-resolver-clusterrole", func(name string, obj *rbacv1.ClusterRole) (*rbacv1.ClusterRole, error) {
+		resolver.InvalidateClusterRole(name)
+		return obj, nil
+	})
+}
+
+// trimInformers strips the largest cached objects of the fields no admitter reads. It must be called before the
+// informers are started. Clusters are only cached when multi-cluster management is enabled.
+func trimInformers(clients *clients.Clients, mgmt *management.Factory, prov *provisioning.Factory, mcmEnabled bool) error {
+	if err := trimInformer("secrets", clients.Core.Secret().Informer(), stripObject); err != nil {
+		return err
+	}
+	if !mcmEnabled {
+		return nil
+	}
+	if err := trimInformer("clusters.management.cattle.io", mgmt.Management().V3().Cluster().Informer(), stripManagementCluster); err != nil {
+		return err
+	}
+	return trimInformer("clusters.provisioning.cattle.io", prov.Provisioning().V1().Cluster().Informer(), stripObject)
+}
+
+// registerSARCacheInvalidation clears the SubjectAccessReview cache whenever an RBAC object changes, since any change may
+// alter the outcome of a cached review. Rancher bindings (GRBs, CRTBs, PRTBs) are materialized as RBAC objects, so
+// they are covered as well.
+func registerSARCacheInvalidation(ctx context.Context, clients *clients.Clients, sarCache *auth.SubjectAccessReviewCache) {
+	clients.RBAC.Role().OnChange(ctx, "sar-cache-role", func(_ string, obj *rbacv1.Role) (*rbacv1.Role, error) {
+		sarCache.Invalidate()
+		return obj, nil
+	})
+	clients.RBAC.RoleBinding().OnChange(ctx, "sar-cache-rolebinding", func(_ string, obj *rbacv1.RoleBinding) (*rbacv1.RoleBinding, error) {
+		sarCache.Invalidate()
+		return obj, nil
+	})
+	clients.RBAC.ClusterRole().OnChange(ctx, "sar-cache-clusterrole", func(_ string, obj *rbacv1.ClusterRole) (*rbacv1.ClusterRole, error) {
+		sarCache.Invalidate()
+		return obj, nil
+	})
+	clients.RBAC.ClusterRoleBinding().OnChange(ctx, "sar-cache-clusterrolebinding", func(_ string, obj *rbacv1.ClusterRoleBinding) (*rbacv1.ClusterRoleBinding, error) {
+		sarCache.Invalidate()
+		return obj, nil
+	})
+}
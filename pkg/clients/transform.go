@@ -0,0 +1,121 @@
+package clients
+
+import (
+	"encoding/json"
+	"fmt"
+	"sync"
+
+	v3 "github.com/rancher/rancher/pkg/apis/management.cattle.io/v3"
+	"github.com/rancher/webhook/pkg/metrics"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/cache"
+)
+
+// lastAppliedConfigAnnotation holds a full copy of objects managed with kubectl apply, including the data of secrets.
+const lastAppliedConfigAnnotation = "kubectl.kubernetes.io/last-applied-configuration"
+
+// stripObject removes the fields that no admitter reads from an object before it is stored in an informer cache:
+// managedFields and the last-applied-configuration annotation. Objects are stripped in place, which is safe since
+// transform functions receive freshly decoded objects.
+func stripObject(obj any) (any, error) {
+	if _, ok := obj.(cache.DeletedFinalStateUnknown); ok {
+		return obj, nil
+	}
+	meta, ok := obj.(metav1.Object)
+	if !ok {
+		return obj, nil
+	}
+	meta.SetManagedFields(nil)
+	if annotations := meta.GetAnnotations(); annotations != nil {
+		delete(annotations, lastAppliedConfigAnnotation)
+	}
+	return obj, nil
+}
+
+// stripManagementCluster strips a management cluster like stripObject, and additionally drops the status fields which
+// hold copies of the spec, certificates and resource lists. The spec, conditions and the other status fields are kept.
+func stripManagementCluster(obj any) (any, error) {
+	obj, err := stripObject(obj)
+	if err != nil {
+		return nil, err
+	}
+	if cluster, ok := obj.(*v3.Cluster); ok {
+		cluster.Status.AppliedSpec = v3.ClusterSpec{}
+		cluster.Status.FailedSpec = nil
+		cluster.Status.CACert = ""
+		cluster.Status.ComponentStatuses = nil
+		cluster.Status.Capacity = nil
+		cluster.Status.Allocatable = nil
+		cluster.Status.Requested = nil
+		cluster.Status.Limits = nil
+	}
+	return obj, nil
+}
+
+// trimInformer sets the transform of an informer which has not been started yet and reports the estimated size of its
+// cache in the metrics.InformerCacheBytes gauge for the given resource.
+func trimInformer(resource string, informer cache.SharedIndexInformer, transform cache.TransformFunc) error {
+	if err := informer.SetTransform(transform); err != nil {
+		return fmt.Errorf("failed to set transform for %s informer: %w", resource, err)
+	}
+	tracker := newSizeTracker(resource)
+	if _, err := informer.AddEventHandler(tracker); err != nil {
+		return fmt.Errorf("failed to track the cache size of %s informer: %w", resource, err)
+	}
+	return nil
+}
+
+// sizeTracker estimates the memory held by an informer cache as the sum of the JSON size of the cached objects.
+type sizeTracker struct {
+	resource string
+	mu       sync.Mutex
+	sizes    map[string]int
+	total    int
+}
+
+func newSizeTracker(resource string) *sizeTracker {
+	return &sizeTracker{resource: resource, sizes: map[string]int{}}
+}
+
+// OnAdd implements cache.ResourceEventHandler.
+func (s *sizeTracker) OnAdd(obj any, _ bool) {
+	s.set(obj, false)
+}
+
+// OnUpdate implements cache.ResourceEventHandler.
+func (s *sizeTracker) OnUpdate(_, newObj any) {
+	s.set(newObj, false)
+}
+
+// OnDelete implements cache.ResourceEventHandler.
+func (s *sizeTracker) OnDelete(obj any) {
+	if tombstone, ok := obj.(cache.DeletedFinalStateUnknown); ok {
+		obj = tombstone.Obj
+	}
+	s.set(obj, true)
+}
+
+func (s *sizeTracker) set(obj any, deleted bool) {
+	key, err := cache.MetaNamespaceKeyFunc(obj)
+	if err != nil {
+		return
+	}
+	size := 0
+	if !deleted {
+		data, err := json.Marshal(obj)
+		if err != nil {
+			return
+		}
+		size = len(data)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.total += size - s.sizes[key]
+	if deleted {
+		delete(s.sizes, key)
+	} else {
+		s.sizes[key] = size
+	}
+	metrics.InformerCacheBytes.WithLabelValues(s.resource).Set(float64(s.total))
+}
@@ -0,0 +1,150 @@
+package clients
+
+import (
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	v3 "github.com/rancher/rancher/pkg/apis/management.cattle.io/v3"
+	provv1 "github.com/rancher/rancher/pkg/apis/provisioning.cattle.io/v1"
+	rketypes "github.com/rancher/rke/types"
+	"github.com/rancher/webhook/pkg/metrics"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/cache"
+)
+
+var strippedMeta = metav1.ObjectMeta{
+	Name:      "test",
+	Namespace: "fleet-default",
+	Labels:    map[string]string{"app": "test"},
+	Annotations: map[string]string{
+		"field.cattle.io/creatorId": "u-12345",
+		lastAppliedConfigAnnotation: `{"data":{"password":"secret"}}`,
+	},
+	ManagedFields: []metav1.ManagedFieldsEntry{{Manager: "kubectl", Operation: metav1.ManagedFieldsOperationApply}},
+}
+
+func TestStripObject(t *testing.T) {
+	t.Parallel()
+	secret := &corev1.Secret{
+		ObjectMeta: *strippedMeta.DeepCopy(),
+		Type:       corev1.SecretTypeOpaque,
+		Data:       map[string][]byte{"password": []byte("secret")},
+	}
+
+	obj, err := stripObject(secret)
+	require.NoError(t, err)
+	stripped := obj.(*corev1.Secret)
+
+	assert.Nil(t, stripped.ManagedFields)
+	assert.Equal(t, map[string]string{"field.cattle.io/creatorId": "u-12345"}, stripped.Annotations)
+	assert.Equal(t, map[string]string{"app": "test"}, stripped.Labels)
+	assert.Equal(t, "test", stripped.Name)
+	assert.Equal(t, "fleet-default", stripped.Namespace)
+	assert.Equal(t, corev1.SecretTypeOpaque, stripped.Type)
+	assert.Equal(t, map[string][]byte{"password": []byte("secret")}, stripped.Data)
+
+	tombstone := cache.DeletedFinalStateUnknown{Key: "fleet-default/test", Obj: secret}
+	obj, err = stripObject(tombstone)
+	require.NoError(t, err)
+	assert.Equal(t, tombstone, obj)
+}
+
+func TestStripProvisioningCluster(t *testing.T) {
+	t.Parallel()
+	cluster := &provv1.Cluster{
+		ObjectMeta: *strippedMeta.DeepCopy(),
+		Spec: provv1.ClusterSpec{
+			KubernetesVersion: "v1.30.2+rke2r1",
+			DefaultPodSecurityAdmissionConfigurationTemplateName: "rancher-restricted",
+		},
+	}
+	expected := cluster.DeepCopy()
+	expected.ManagedFields = nil
+	expected.Annotations = map[string]string{"field.cattle.io/creatorId": "u-12345"}
+
+	obj, err := stripObject(cluster)
+	require.NoError(t, err)
+	assert.Equal(t, expected, obj)
+}
+
+func TestStripManagementCluster(t *testing.T) {
+	t.Parallel()
+	cluster := &v3.Cluster{
+		ObjectMeta: *strippedMeta.DeepCopy(),
+		Spec: v3.ClusterSpec{
+			ClusterSpecBase: v3.ClusterSpecBase{
+				RancherKubernetesEngineConfig:                        &rketypes.RancherKubernetesEngineConfig{Version: "v1.28.9-rancher1-1"},
+				DefaultPodSecurityAdmissionConfigurationTemplateName: "rancher-restricted",
+			},
+			DisplayName:        "test",
+			FleetWorkspaceName: "fleet-default",
+		},
+		Status: v3.ClusterStatus{
+			Conditions: []v3.ClusterCondition{{Type: "AgentTlsStrictCheck", Status: corev1.ConditionTrue}},
+			Driver:     "rancherKubernetesEngine",
+			AppliedSpec: v3.ClusterSpec{
+				DisplayName: "test",
+			},
+			FailedSpec:        &v3.ClusterSpec{DisplayName: "test"},
+			CACert:            "certificate",
+			ComponentStatuses: []v3.ClusterComponentStatus{{Name: "etcd-0"}},
+			Capacity:          corev1.ResourceList{corev1.ResourceCPU: {}},
+			Allocatable:       corev1.ResourceList{corev1.ResourceCPU: {}},
+			Requested:         corev1.ResourceList{corev1.ResourceCPU: {}},
+			Limits:            corev1.ResourceList{corev1.ResourceCPU: {}},
+		},
+	}
+
+	obj, err := stripManagementCluster(cluster)
+	require.NoError(t, err)
+	stripped := obj.(*v3.Cluster)
+
+	// Fields read by admitters and indexers are preserved.
+	assert.Equal(t, map[string]string{"field.cattle.io/creatorId": "u-12345"}, stripped.Annotations)
+	assert.Equal(t, "rancher-restricted", stripped.Spec.DefaultPodSecurityAdmissionConfigurationTemplateName)
+	assert.Equal(t, "v1.28.9-rancher1-1", stripped.Spec.RancherKubernetesEngineConfig.Version)
+	assert.Equal(t, "fleet-default", stripped.Spec.FleetWorkspaceName)
+	assert.Equal(t, []v3.ClusterCondition{{Type: "AgentTlsStrictCheck", Status: corev1.ConditionTrue}}, stripped.Status.Conditions)
+	assert.Equal(t, "rancherKubernetesEngine", stripped.Status.Driver)
+
+	assert.Nil(t, stripped.ManagedFields)
+	assert.Equal(t, v3.ClusterSpec{}, stripped.Status.AppliedSpec)
+	assert.Nil(t, stripped.Status.FailedSpec)
+	assert.Empty(t, stripped.Status.CACert)
+	assert.Nil(t, stripped.Status.ComponentStatuses)
+	assert.Nil(t, stripped.Status.Capacity)
+	assert.Nil(t, stripped.Status.Allocatable)
+	assert.Nil(t, stripped.Status.Requested)
+	assert.Nil(t, stripped.Status.Limits)
+}
+
+func TestSizeTracker(t *testing.T) {
+	t.Parallel()
+	tracker := newSizeTracker("test-resource")
+	gauge := metrics.InformerCacheBytes.WithLabelValues("test-resource")
+
+	small := &corev1.Secret{ObjectMeta: metav1.ObjectMeta{Name: "small", Namespace: "default"}}
+	large := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "large", Namespace: "default"},
+		Data:       map[string][]byte{"data": make([]byte, 1024)},
+	}
+
+	tracker.OnAdd(small, true)
+	smallSize := testutil.ToFloat64(gauge)
+	assert.Positive(t, smallSize)
+
+	tracker.OnAdd(large, true)
+	assert.Greater(t, testutil.ToFloat64(gauge), smallSize+1024)
+
+	tracker.OnUpdate(large, large)
+	assert.Greater(t, testutil.ToFloat64(gauge), smallSize+1024)
+
+	tracker.OnDelete(cache.DeletedFinalStateUnknown{Key: "default/large", Obj: large})
+	assert.Equal(t, smallSize, testutil.ToFloat64(gauge))
+
+	tracker.OnDelete(small)
+	assert.Zero(t, testutil.ToFloat64(gauge))
+}
@@ -17,6 +17,7 @@ import (
 	catalogv1 "github.com/rancher/rancher/pkg/apis/catalog.cattle.io/v1"
 	v3 "github.com/rancher/rancher/pkg/apis/management.cattle.io/v3"
 	v1 "github.com/rancher/rancher/pkg/apis/provisioning.cattle.io/v1"
+	rkev1 "github.com/rancher/rancher/pkg/apis/rke.cattle.io/v1"
 	controllergen "github.com/rancher/wrangler/v3/pkg/controller-gen"
 	"github.com/rancher/wrangler/v3/pkg/controller-gen/args"
 	"golang.org/x/tools/imports"
@@ -41,7 +42,9 @@ func main() {
 		Groups: map[string]args.Group{
 			"management.cattle.io": {
 				Types: []interface{}{
+					v3.AuthConfig{},
 					v3.Cluster{},
+					v3.ClusterTemplateRevision{},
 					v3.GlobalRole{},
 					v3.GlobalRoleBinding{},
 					v3.PodSecurityAdmissionConfigurationTemplate{},
@@ -49,10 +52,12 @@ func main() {
 					v3.ClusterRoleTemplateBinding{},
 					v3.ProjectRoleTemplateBinding{},
 					v3.Node{},
+					v3.NodeTemplate{},
 					v3.Project{},
 					v3.ClusterProxyConfig{},
 					v3.Feature{},
 					v3.Setting{},
+					v3.Token{},
 					v3.User{},
 				},
 			},
@@ -80,6 +85,8 @@ func main() {
 			Types: []interface{}{
 				&v3.Cluster{},
 				&v3.ClusterRoleTemplateBinding{},
+				&v3.ClusterTemplate{},
+				&v3.ClusterTemplateRevision{},
 				&v3.Feature{},
 				&v3.FleetWorkspace{},
 				&v3.PodSecurityAdmissionConfigurationTemplate{},
@@ -88,8 +95,11 @@ func main() {
 				&v3.RoleTemplate{},
 				&v3.ProjectRoleTemplateBinding{},
 				&v3.NodeDriver{},
+				&v3.NodeTemplate{},
 				&v3.Project{},
 				&v3.Setting{},
+				&v3.User{},
+				&v3.UserAttribute{},
 			},
 		},
 		"provisioning.cattle.io": {
@@ -97,6 +107,11 @@ func main() {
 				&v1.Cluster{},
 			},
 		},
+		"rke.cattle.io": {
+			Types: []interface{}{
+				&rkev1.ETCDSnapshot{},
+			},
+		},
 		"core": {
 			Types: []interface{}{
 				&unstructured.Unstructured{},
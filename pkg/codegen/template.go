@@ -10,6 +10,7 @@ import (
 	{{ range .types }}
 	"{{ .Package }}"{{ end }}
 	admissionv1 "k8s.io/api/admission/v1"
+	"k8s.io/apimachinery/pkg/runtime"
 )
 {{ range .types }}
 
@@ -25,7 +26,8 @@ func {{ .Name }}OldAndNewFromRequest(request *admissionv1.AdmissionRequest) ({{
 	oldObject := {{ replace .Type "*" "&" }}{}
 
 	if request.Operation != admissionv1.Delete {
-		err := json.Unmarshal(request.Object.Raw, object)
+		var err error
+		object, err = decode{{ .Name }}(&request.Object)
 		if err != nil {
 			return nil, nil, fmt.Errorf("failed to unmarshal request object: %w", err)
 		}
@@ -35,7 +37,7 @@ func {{ .Name }}OldAndNewFromRequest(request *admissionv1.AdmissionRequest) ({{
 		return oldObject, object, nil
 	}
 
-	err := json.Unmarshal(request.OldObject.Raw, oldObject)
+	oldObject, err := decode{{ .Name }}(&request.OldObject)
 	if err != nil {
 		return nil, nil, fmt.Errorf("failed to unmarshal request oldObject: %w", err)
 	}
@@ -51,19 +53,33 @@ func {{ .Name }}FromRequest(request *admissionv1.AdmissionRequest) ({{ .Type }},
 		return nil, fmt.Errorf("nil request")
 	}
 
-	object := {{ replace .Type "*" "&" }}{}
-	raw := request.Object.Raw
-	
+	raw := &request.Object
+
 	if request.Operation == admissionv1.Delete {
-		raw = request.OldObject.Raw
+		raw = &request.OldObject
 	}
 
-	err := json.Unmarshal(raw, object)
+	object, err := decode{{ .Name }}(raw)
 	if err != nil {
 		return nil, fmt.Errorf("failed to unmarshal request object: %w", err)
 	}
 
 	return object, nil
 }
+
+// decode{{ .Name }} returns the {{ .Name }} object of the raw extension. The decoded object is kept in the extension,
+// so that the admitters of a request only decode its payload once, and a copy of it is returned so that admitters
+// can't alter the objects seen by the others.
+func decode{{ .Name }}(raw *runtime.RawExtension) ({{ .Type }}, error) {
+	if decoded, ok := raw.Object.({{ .Type }}); ok {
+		return decoded.DeepCopy(), nil
+	}
+	object := {{ replace .Type "*" "&" }}{}
+	if err := json.Unmarshal(raw.Raw, object); err != nil {
+		return nil, err
+	}
+	raw.Object = object.DeepCopy()
+	return object, nil
+}
 {{ end }}
 `
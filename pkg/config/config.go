@@ -0,0 +1,322 @@
+// Package config loads the optional configuration file of the webhook.
+//
+// Every setting of the webhook is read from an environment variable by the package it configures. A configuration
+// file is a structured alternative to those variables: its values are exported as the variables they correspond to,
+// unless the variable is already set in the environment of the process, so the environment always overrides the file.
+package config
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+
+	"github.com/rancher/webhook/pkg/admission"
+	"github.com/rancher/webhook/pkg/audit"
+	"github.com/rancher/webhook/pkg/auth"
+	"github.com/rancher/webhook/pkg/resources/common"
+	"github.com/rancher/webhook/pkg/resources/management.cattle.io/v3/globalrole"
+	"github.com/rancher/webhook/pkg/resources/management.cattle.io/v3/globalrolebinding"
+	"github.com/rancher/webhook/pkg/resources/management.cattle.io/v3/nodedriver"
+	"github.com/rancher/webhook/pkg/resources/management.cattle.io/v3/podsecurityadmissionconfigurationtemplate"
+	"github.com/rancher/webhook/pkg/resources/management.cattle.io/v3/userattribute"
+	provisioningcluster "github.com/rancher/webhook/pkg/resources/provisioning.cattle.io/v1/cluster"
+	"github.com/rancher/webhook/pkg/server"
+	"github.com/sirupsen/logrus"
+	"sigs.k8s.io/yaml"
+)
+
+const (
+	// FileEnv is the environment variable holding the path of the configuration file, used when the --config flag
+	// isn't given.
+	FileEnv = "CATTLE_WEBHOOK_CONFIG_FILE"
+	// LogLevelEnv is the environment variable holding the log level of the webhook. CATTLE_DEBUG, RANCHER_DEBUG and
+	// CATTLE_TRACE take precedence over it.
+	LogLevelEnv = "CATTLE_WEBHOOK_LOG_LEVEL"
+	// MCMEnv is the environment variable disabling the multi-cluster management handlers when "false".
+	MCMEnv = "ENABLE_MCM"
+	// PortEnv is the environment variable holding the port the webhook listens on.
+	PortEnv = "CATTLE_PORT"
+
+	debugEnv        = "CATTLE_DEBUG"
+	rancherDebugEnv = "RANCHER_DEBUG"
+	traceEnv        = "CATTLE_TRACE"
+)
+
+// tuningEnvs are the environment variables which can be set in the tuning section of the configuration file.
+var tuningEnvs = []string{
+	admission.PolicyVersionEnv,
+	admission.StripManagedFieldsEnv,
+	admission.UnknownFieldsEnv,
+	audit.LogMaxBackupsEnv,
+	audit.LogMaxSizeEnv,
+	audit.LogPathEnv,
+	audit.WebhookURLEnv,
+	auth.SARCacheSizeEnv,
+	auth.SARCacheTTLEnv,
+	common.UninstallServiceAccountEnv,
+	globalrole.ValidateNamespacesEnv,
+	globalrolebinding.MaxExpirationEnv,
+	nodedriver.URLAllowListEnv,
+	podsecurityadmissionconfigurationtemplate.RequiredExemptionsEnv,
+	provisioningcluster.ChartValuesValidationEnv,
+	server.BypassGroupEnv,
+	server.BypassResourcesEnv,
+	server.DenialEventsEnv,
+	server.DenialEventsIntervalEnv,
+	server.MaxRequestBytesEnv,
+	server.RateLimitBurstEnv,
+	server.RateLimitQPSEnv,
+	server.ShadowCAFileEnv,
+	server.ShadowCertFileEnv,
+	server.ShadowKeyFileEnv,
+	server.ShadowPercentEnv,
+	server.ShadowURLEnv,
+	server.ShutdownDelayEnv,
+	server.ShutdownTimeoutEnv,
+	server.UnsyncedModeEnv,
+	userattribute.MaxGroupPrincipalsEnv,
+}
+
+// File is the content of the configuration file.
+type File struct {
+	// LogLevel is the log level of the webhook, one of trace, debug, info, warning or error.
+	LogLevel string `json:"logLevel,omitempty"`
+	// Port is the port the webhook listens on.
+	Port int `json:"port,omitempty"`
+	// Handlers selects the groups of handlers which are enabled.
+	Handlers Handlers `json:"handlers,omitempty"`
+	// TLS configures the TLS server of the webhook.
+	TLS TLS `json:"tls,omitempty"`
+	// Tuning holds the values of the other settings of the webhook, keyed by the name of their environment variable.
+	Tuning map[string]string `json:"tuning,omitempty"`
+}
+
+// Handlers selects the groups of handlers which are enabled.
+type Handlers struct {
+	// MultiClusterManagement enables the handlers for Rancher's multi-cluster management resources.
+	MultiClusterManagement *bool `json:"multiClusterManagement,omitempty"`
+	// DynamicMultiClusterManagement follows the multi-cluster-management Feature at runtime.
+	DynamicMultiClusterManagement *bool `json:"dynamicMultiClusterManagement,omitempty"`
+}
+
+// TLS configures the TLS server of the webhook.
+type TLS struct {
+	// ClientCAFile is the CA bundle client certificates are verified with.
+	ClientCAFile string `json:"clientCAFile,omitempty"`
+	// RequireClientCert fails the startup if the CA bundle can't be read.
+	RequireClientCert *bool `json:"requireClientCert,omitempty"`
+	// AllowedCNs are the common names allowed for client certificates.
+	AllowedCNs []string `json:"allowedCNs,omitempty"`
+	// MaxConcurrentStreams is the maximum number of concurrent HTTP/2 streams of a connection.
+	MaxConcurrentStreams int `json:"maxConcurrentStreams,omitempty"`
+}
+
+// Parse parses and validates the content of a configuration file. Unknown fields are rejected.
+func Parse(data []byte) (*File, error) {
+	var file File
+	if err := yaml.UnmarshalStrict(data, &file); err != nil {
+		return nil, err
+	}
+	if file.LogLevel != "" {
+		if _, err := logrus.ParseLevel(file.LogLevel); err != nil {
+			return nil, fmt.Errorf("invalid logLevel: %w", err)
+		}
+	}
+	if file.Port < 0 || file.Port > 65535 {
+		return nil, fmt.Errorf("invalid port %d: must be between 1 and 65535", file.Port)
+	}
+	if file.TLS.MaxConcurrentStreams < 0 {
+		return nil, fmt.Errorf("invalid tls.maxConcurrentStreams %d: must not be negative", file.TLS.MaxConcurrentStreams)
+	}
+	for key := range file.Tuning {
+		if !isTuningEnv(key) {
+			return nil, fmt.Errorf("unknown tuning setting '%s'", key)
+		}
+	}
+	return &file, nil
+}
+
+// Env returns the environment variables corresponding to the values of the file.
+func (f *File) Env() map[string]string {
+	env := map[string]string{}
+	for key, value := range f.Tuning {
+		env[key] = value
+	}
+	if f.LogLevel != "" {
+		env[LogLevelEnv] = f.LogLevel
+	}
+	if f.Port != 0 {
+		env[PortEnv] = strconv.Itoa(f.Port)
+	}
+	if f.Handlers.MultiClusterManagement != nil {
+		env[MCMEnv] = strconv.FormatBool(*f.Handlers.MultiClusterManagement)
+	}
+	if f.Handlers.DynamicMultiClusterManagement != nil {
+		env[server.DynamicMCMEnv] = strconv.FormatBool(*f.Handlers.DynamicMultiClusterManagement)
+	}
+	if f.TLS.ClientCAFile != "" {
+		env[server.ClientCAFileEnv] = f.TLS.ClientCAFile
+	}
+	if f.TLS.RequireClientCert != nil {
+		env[server.RequireClientCertEnv] = strconv.FormatBool(*f.TLS.RequireClientCert)
+	}
+	if len(f.TLS.AllowedCNs) > 0 {
+		env[server.AllowedCNsEnv] = strings.Join(f.TLS.AllowedCNs, ",")
+	}
+	if f.TLS.MaxConcurrentStreams != 0 {
+		env[server.MaxConcurrentStreamsEnv] = strconv.Itoa(f.TLS.MaxConcurrentStreams)
+	}
+	return env
+}
+
+// Loader exports the values of a configuration file as environment variables, and reloads them on demand.
+type Loader struct {
+	path string
+
+	mu sync.Mutex
+	// fromFile holds the environment variables which were set from the file, and their value.
+	fromFile map[string]string
+}
+
+// NewLoader returns a Loader for the configuration file at path.
+func NewLoader(path string) *Loader {
+	return &Loader{path: path, fromFile: map[string]string{}}
+}
+
+// Load reads the configuration file and sets the environment variables of its values which are not already set in
+// the environment of the process. It must be called before the settings are read.
+func (l *Loader) Load() error {
+	file, err := l.read()
+	if err != nil {
+		return err
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for key, value := range file.Env() {
+		if _, set := os.LookupEnv(key); set {
+			logrus.Debugf("Ignoring the value of %s in the configuration file, it is set in the environment", key)
+			continue
+		}
+		if err := os.Setenv(key, value); err != nil {
+			return fmt.Errorf("failed to set %s: %w", key, err)
+		}
+		l.fromFile[key] = value
+	}
+	return nil
+}
+
+// Reload reads the configuration file again and updates the environment variables which were set from it. Variables
+// set in the environment of the process are left untouched. The log level is applied immediately, the other settings
+// are only read on startup: the names of the environment variables which changed are returned so they can be reported.
+func (l *Loader) Reload() ([]string, error) {
+	file, err := l.read()
+	if err != nil {
+		return nil, err
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	var changed []string
+	env := file.Env()
+	for key, value := range env {
+		previous, fromFile := l.fromFile[key]
+		if !fromFile {
+			if _, set := os.LookupEnv(key); set {
+				continue
+			}
+		} else if previous == value {
+			continue
+		}
+		if err := os.Setenv(key, value); err != nil {
+			return nil, fmt.Errorf("failed to set %s: %w", key, err)
+		}
+		l.fromFile[key] = value
+		changed = append(changed, key)
+	}
+	for key := range l.fromFile {
+		if _, ok := env[key]; ok {
+			continue
+		}
+		if err := os.Unsetenv(key); err != nil {
+			return nil, fmt.Errorf("failed to unset %s: %w", key, err)
+		}
+		delete(l.fromFile, key)
+		changed = append(changed, key)
+	}
+	sort.Strings(changed)
+	SetLogLevel()
+	return changed, nil
+}
+
+// WatchSIGHUP reloads the configuration file whenever the process receives a SIGHUP, until ctx is done.
+func (l *Loader) WatchSIGHUP(ctx context.Context) {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGHUP)
+	go func() {
+		defer signal.Stop(signals)
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-signals:
+			}
+			changed, err := l.Reload()
+			if err != nil {
+				logrus.Errorf("Failed to reload the configuration file %s, keeping the current configuration: %v", l.path, err)
+				continue
+			}
+			logrus.Infof("Reloaded the configuration file %s", l.path)
+			for _, key := range changed {
+				if key != LogLevelEnv {
+					logrus.Warnf("The change of %s in the configuration file takes effect when the webhook restarts", key)
+				}
+			}
+		}
+	}()
+}
+
+func (l *Loader) read() (*File, error) {
+	data, err := os.ReadFile(l.path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read the configuration file: %w", err)
+	}
+	file, err := Parse(data)
+	if err != nil {
+		return nil, fmt.Errorf("invalid configuration file %s: %w", l.path, err)
+	}
+	return file, nil
+}
+
+// SetLogLevel sets the log level from the environment. CATTLE_TRACE and CATTLE_DEBUG or RANCHER_DEBUG take precedence
+// over CATTLE_WEBHOOK_LOG_LEVEL, and the level is info when none of them is set.
+func SetLogLevel() {
+	level := logrus.InfoLevel
+	switch {
+	case os.Getenv(traceEnv) == "true":
+		level = logrus.TraceLevel
+	case os.Getenv(debugEnv) == "true" || os.Getenv(rancherDebugEnv) == "true":
+		level = logrus.DebugLevel
+	case os.Getenv(LogLevelEnv) != "":
+		parsed, err := logrus.ParseLevel(os.Getenv(LogLevelEnv))
+		if err != nil {
+			logrus.Errorf("invalid value '%s' for %s: %v", os.Getenv(LogLevelEnv), LogLevelEnv, err)
+			break
+		}
+		level = parsed
+	}
+	logrus.SetLevel(level)
+}
+
+func isTuningEnv(key string) bool {
+	for _, env := range tuningEnvs {
+		if env == key {
+			return true
+		}
+	}
+	return false
+}
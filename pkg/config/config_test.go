@@ -0,0 +1,138 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/rancher/webhook/pkg/server"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testFile = `
+logLevel: debug
+port: 8443
+handlers:
+  multiClusterManagement: false
+tls:
+  allowedCNs: [kube-apiserver, rancher]
+  maxConcurrentStreams: 50
+tuning:
+  CATTLE_WEBHOOK_RATE_LIMIT_QPS: "10"
+`
+
+func TestParse(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name    string
+		data    string
+		want    map[string]string
+		wantErr bool
+	}{
+		{
+			name: "full file",
+			data: testFile,
+			want: map[string]string{
+				LogLevelEnv:                    "debug",
+				PortEnv:                        "8443",
+				MCMEnv:                         "false",
+				server.AllowedCNsEnv:           "kube-apiserver,rancher",
+				server.MaxConcurrentStreamsEnv: "50",
+				server.RateLimitQPSEnv:         "10",
+			},
+		},
+		{name: "empty file", want: map[string]string{}},
+		{name: "unknown field", data: "logLevel: info\nverbose: true", wantErr: true},
+		{name: "invalid log level", data: "logLevel: loud", wantErr: true},
+		{name: "invalid port", data: "port: 70000", wantErr: true},
+		{name: "negative streams", data: "tls:\n  maxConcurrentStreams: -1", wantErr: true},
+		{name: "unknown tuning setting", data: "tuning:\n  KUBECONFIG: /etc/kubeconfig", wantErr: true},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			file, err := Parse([]byte(tt.data))
+			if tt.wantErr {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, file.Env())
+		})
+	}
+}
+
+func TestLoaderLoadAndReload(t *testing.T) {
+	keys := []string{LogLevelEnv, PortEnv, MCMEnv, server.AllowedCNsEnv, server.MaxConcurrentStreamsEnv, server.RateLimitQPSEnv, server.RateLimitBurstEnv}
+	for _, key := range keys {
+		t.Setenv(key, "")
+		require.NoError(t, os.Unsetenv(key))
+	}
+	t.Setenv(PortEnv, "9443")
+	t.Setenv(debugEnv, "")
+	t.Setenv(rancherDebugEnv, "")
+	t.Setenv(traceEnv, "")
+	level := logrus.GetLevel()
+	t.Cleanup(func() { logrus.SetLevel(level) })
+
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	require.NoError(t, os.WriteFile(path, []byte(testFile), 0o600))
+	loader := NewLoader(path)
+	require.NoError(t, loader.Load())
+	SetLogLevel()
+
+	assert.Equal(t, "9443", os.Getenv(PortEnv), "the environment overrides the file")
+	assert.Equal(t, "false", os.Getenv(MCMEnv))
+	assert.Equal(t, "10", os.Getenv(server.RateLimitQPSEnv))
+	assert.Equal(t, logrus.DebugLevel, logrus.GetLevel())
+
+	require.NoError(t, os.WriteFile(path, []byte(`
+logLevel: warn
+port: 8443
+handlers:
+  multiClusterManagement: false
+tuning:
+  CATTLE_WEBHOOK_RATE_LIMIT_BURST: "20"
+`), 0o600))
+	changed, err := loader.Reload()
+	require.NoError(t, err)
+	assert.Equal(t, []string{server.AllowedCNsEnv, server.MaxConcurrentStreamsEnv, LogLevelEnv, server.RateLimitBurstEnv, server.RateLimitQPSEnv}, changed)
+	assert.Equal(t, logrus.WarnLevel, logrus.GetLevel())
+	assert.Equal(t, "9443", os.Getenv(PortEnv))
+	assert.Equal(t, "20", os.Getenv(server.RateLimitBurstEnv))
+	_, set := os.LookupEnv(server.RateLimitQPSEnv)
+	assert.False(t, set, "values removed from the file are unset")
+
+	require.NoError(t, os.WriteFile(path, []byte("logLevel: loud"), 0o600))
+	_, err = loader.Reload()
+	require.Error(t, err)
+	assert.Equal(t, logrus.WarnLevel, logrus.GetLevel(), "an invalid file keeps the current configuration")
+}
+
+func TestSetLogLevel(t *testing.T) {
+	level := logrus.GetLevel()
+	t.Cleanup(func() { logrus.SetLevel(level) })
+	tests := []struct {
+		name string
+		env  map[string]string
+		want logrus.Level
+	}{
+		{name: "default", want: logrus.InfoLevel},
+		{name: "log level", env: map[string]string{LogLevelEnv: "error"}, want: logrus.ErrorLevel},
+		{name: "invalid log level", env: map[string]string{LogLevelEnv: "loud"}, want: logrus.InfoLevel},
+		{name: "debug overrides log level", env: map[string]string{LogLevelEnv: "error", rancherDebugEnv: "true"}, want: logrus.DebugLevel},
+		{name: "trace overrides debug", env: map[string]string{debugEnv: "true", traceEnv: "true"}, want: logrus.TraceLevel},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for _, key := range []string{LogLevelEnv, debugEnv, rancherDebugEnv, traceEnv} {
+				t.Setenv(key, tt.env[key])
+			}
+			SetLogLevel()
+			assert.Equal(t, tt.want, logrus.GetLevel())
+		})
+	}
+}
@@ -0,0 +1,169 @@
+// Package diff compares the old and new versions of objects, so that validators share how they decide which fields an
+// update changed.
+package diff
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"slices"
+	"sort"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/util/validation/field"
+)
+
+// ChangeType is the kind of a change to a field.
+type ChangeType string
+
+const (
+	// Added fields are only set in the new object.
+	Added ChangeType = "added"
+	// Removed fields are only set in the old object.
+	Removed ChangeType = "removed"
+	// Modified fields are set to different values in the old and new objects.
+	Modified ChangeType = "modified"
+)
+
+// Change is a change to a field of an object.
+type Change struct {
+	// Path holds the JSON keys leading to the field.
+	Path []string
+	// Type is the kind of change.
+	Type ChangeType
+}
+
+// FieldPath returns the path of the changed field, for use in field errors.
+func (c Change) FieldPath() *field.Path {
+	if len(c.Path) == 0 {
+		return nil
+	}
+	return field.NewPath(c.Path[0], c.Path[1:]...)
+}
+
+// Pointer returns the path of the changed field as a JSON pointer, as defined by RFC 6901.
+func (c Change) Pointer() string {
+	var pointer strings.Builder
+	for _, key := range c.Path {
+		pointer.WriteString("/")
+		pointer.WriteString(strings.ReplaceAll(strings.ReplaceAll(key, "~", "~0"), "/", "~1"))
+	}
+	return pointer.String()
+}
+
+// ChangeSet holds the changes between two objects, sorted by path. Objects are compared field by field, while lists
+// and scalars are compared as a whole: a change to an element of a list is a change to the list.
+type ChangeSet []Change
+
+// Objects returns the changes between the JSON representations of the old and new objects, which must be structs or
+// maps. A nil or zero old object, as the old object of a create request, makes every field of the new object added.
+func Objects(oldObj, newObj any) (ChangeSet, error) {
+	oldRaw, err := json.Marshal(oldObj)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal old object: %w", err)
+	}
+	newRaw, err := json.Marshal(newObj)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal new object: %w", err)
+	}
+	return JSON(oldRaw, newRaw)
+}
+
+// JSON returns the changes between the old and new JSON objects. An empty or null document is an empty object.
+func JSON(oldRaw, newRaw []byte) (ChangeSet, error) {
+	oldMap, err := unmarshalObject(oldRaw)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode old object: %w", err)
+	}
+	newMap, err := unmarshalObject(newRaw)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode new object: %w", err)
+	}
+	var changes ChangeSet
+	diffObjects(nil, oldMap, newMap, &changes)
+	sort.Slice(changes, func(i, j int) bool {
+		return changes[i].Pointer() < changes[j].Pointer()
+	})
+	return changes, nil
+}
+
+// Changed returns true if the field at the path, a field under it, or a field holding it changed.
+func (s ChangeSet) Changed(path ...string) bool {
+	for _, change := range s {
+		n := min(len(change.Path), len(path))
+		if slices.Equal(change.Path[:n], path[:n]) {
+			return true
+		}
+	}
+	return false
+}
+
+// Paths returns the field paths of the changes.
+func (s ChangeSet) Paths() []string {
+	paths := make([]string, 0, len(s))
+	for _, change := range s {
+		paths = append(paths, change.FieldPath().String())
+	}
+	return paths
+}
+
+// Filter returns the changes of the given types.
+func (s ChangeSet) Filter(types ...ChangeType) ChangeSet {
+	var filtered ChangeSet
+	for _, change := range s {
+		for _, changeType := range types {
+			if change.Type == changeType {
+				filtered = append(filtered, change)
+				break
+			}
+		}
+	}
+	return filtered
+}
+
+func unmarshalObject(raw []byte) (map[string]any, error) {
+	if len(raw) == 0 {
+		return nil, nil
+	}
+	var obj map[string]any
+	if err := json.Unmarshal(raw, &obj); err != nil {
+		return nil, err
+	}
+	return obj, nil
+}
+
+func diffObjects(prefix []string, oldObj, newObj map[string]any, changes *ChangeSet) {
+	for key, oldValue := range oldObj {
+		diffValues(appendKey(prefix, key), oldValue, newObj[key], changes)
+	}
+	for key, newValue := range newObj {
+		if _, ok := oldObj[key]; !ok {
+			diffValues(appendKey(prefix, key), nil, newValue, changes)
+		}
+	}
+}
+
+func diffValues(path []string, oldValue, newValue any, changes *ChangeSet) {
+	oldMap, oldIsMap := oldValue.(map[string]any)
+	newMap, newIsMap := newValue.(map[string]any)
+	if oldIsMap && newIsMap {
+		diffObjects(path, oldMap, newMap, changes)
+		return
+	}
+	switch {
+	case reflect.DeepEqual(oldValue, newValue):
+	case oldValue == nil:
+		*changes = append(*changes, Change{Path: path, Type: Added})
+	case newValue == nil:
+		*changes = append(*changes, Change{Path: path, Type: Removed})
+	default:
+		*changes = append(*changes, Change{Path: path, Type: Modified})
+	}
+}
+
+// appendKey returns a new path, so that the paths of sibling fields don't share their backing array.
+func appendKey(prefix []string, key string) []string {
+	path := make([]string, len(prefix), len(prefix)+1)
+	copy(path, prefix)
+	return append(path, key)
+}
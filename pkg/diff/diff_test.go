@@ -0,0 +1,105 @@
+package diff
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type spec struct {
+	Name     string            `json:"name,omitempty"`
+	Replicas int               `json:"replicas"`
+	Labels   map[string]string `json:"labels,omitempty"`
+	Ports    []int             `json:"ports,omitempty"`
+	Nested   *spec             `json:"nested,omitempty"`
+}
+
+func TestObjects(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name   string
+		oldObj any
+		newObj any
+		want   ChangeSet
+	}{
+		{
+			name:   "unchanged",
+			oldObj: spec{Name: "a", Labels: map[string]string{"a": "1"}},
+			newObj: spec{Name: "a", Labels: map[string]string{"a": "1"}},
+		},
+		{
+			name:   "added, removed and modified fields",
+			oldObj: spec{Name: "a", Replicas: 1, Labels: map[string]string{"a": "1", "b/c": "2"}},
+			newObj: spec{Replicas: 2, Labels: map[string]string{"a": "1", "d": "3"}},
+			want: ChangeSet{
+				{Path: []string{"labels", "b/c"}, Type: Removed},
+				{Path: []string{"labels", "d"}, Type: Added},
+				{Path: []string{"name"}, Type: Removed},
+				{Path: []string{"replicas"}, Type: Modified},
+			},
+		},
+		{
+			name:   "lists are compared as a whole",
+			oldObj: spec{Ports: []int{80, 443}},
+			newObj: spec{Ports: []int{80, 8443}},
+			want:   ChangeSet{{Path: []string{"ports"}, Type: Modified}},
+		},
+		{
+			name:   "nested object set",
+			oldObj: spec{},
+			newObj: spec{Nested: &spec{Name: "b"}},
+			want:   ChangeSet{{Path: []string{"nested"}, Type: Added}},
+		},
+		{
+			name:   "create",
+			oldObj: nil,
+			newObj: spec{Name: "a"},
+			want: ChangeSet{
+				{Path: []string{"name"}, Type: Added},
+				{Path: []string{"replicas"}, Type: Added},
+			},
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			changes, err := Objects(tt.oldObj, tt.newObj)
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, changes)
+		})
+	}
+}
+
+func TestJSONNotAnObject(t *testing.T) {
+	t.Parallel()
+	_, err := JSON([]byte(`[]`), []byte(`{}`))
+	assert.Error(t, err)
+}
+
+func TestChangeSetChanged(t *testing.T) {
+	t.Parallel()
+	changes, err := Objects(
+		spec{Nested: &spec{Name: "a", Labels: map[string]string{"team": "a"}}},
+		spec{Nested: &spec{Name: "a", Labels: map[string]string{"team": "b"}, Nested: &spec{Name: "c"}}},
+	)
+	require.NoError(t, err)
+
+	assert.True(t, changes.Changed("nested", "labels", "team"), "changed field")
+	assert.True(t, changes.Changed("nested", "labels"), "field holding a changed field")
+	assert.True(t, changes.Changed("nested", "nested", "name"), "field under an added field")
+	assert.False(t, changes.Changed("nested", "name"), "unchanged field")
+	assert.False(t, changes.Changed("ports"), "unset field")
+}
+
+func TestChangeSetPaths(t *testing.T) {
+	t.Parallel()
+	changes := ChangeSet{
+		{Path: []string{"metadata", "labels", "b/c"}, Type: Removed},
+		{Path: []string{"spec", "replicas"}, Type: Modified},
+	}
+	assert.Equal(t, []string{"metadata.labels.b/c", "spec.replicas"}, changes.Paths())
+	assert.Equal(t, "/metadata/labels/b~1c", changes[0].Pointer())
+	assert.Equal(t, ChangeSet{changes[1]}, changes.Filter(Modified, Added))
+}
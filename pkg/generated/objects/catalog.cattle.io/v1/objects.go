@@ -6,6 +6,7 @@ import (
 
 	"github.com/rancher/rancher/pkg/apis/catalog.cattle.io/v1"
 	admissionv1 "k8s.io/api/admission/v1"
+	"k8s.io/apimachinery/pkg/runtime"
 )
 
 // ClusterRepoOldAndNewFromRequest gets the old and new ClusterRepo objects, respectively, from the webhook request.
@@ -20,7 +21,8 @@ func ClusterRepoOldAndNewFromRequest(request *admissionv1.AdmissionRequest) (*v1
 	oldObject := &v1.ClusterRepo{}
 
 	if request.Operation != admissionv1.Delete {
-		err := json.Unmarshal(request.Object.Raw, object)
+		var err error
+		object, err = decodeClusterRepo(&request.Object)
 		if err != nil {
 			return nil, nil, fmt.Errorf("failed to unmarshal request object: %w", err)
 		}
@@ -30,7 +32,7 @@ func ClusterRepoOldAndNewFromRequest(request *admissionv1.AdmissionRequest) (*v1
 		return oldObject, object, nil
 	}
 
-	err := json.Unmarshal(request.OldObject.Raw, oldObject)
+	oldObject, err := decodeClusterRepo(&request.OldObject)
 	if err != nil {
 		return nil, nil, fmt.Errorf("failed to unmarshal request oldObject: %w", err)
 	}
@@ -46,17 +48,31 @@ func ClusterRepoFromRequest(request *admissionv1.AdmissionRequest) (*v1.ClusterR
 		return nil, fmt.Errorf("nil request")
 	}
 
-	object := &v1.ClusterRepo{}
-	raw := request.Object.Raw
+	raw := &request.Object
 
 	if request.Operation == admissionv1.Delete {
-		raw = request.OldObject.Raw
+		raw = &request.OldObject
 	}
 
-	err := json.Unmarshal(raw, object)
+	object, err := decodeClusterRepo(raw)
 	if err != nil {
 		return nil, fmt.Errorf("failed to unmarshal request object: %w", err)
 	}
 
 	return object, nil
 }
+
+// decodeClusterRepo returns the ClusterRepo object of the raw extension. The decoded object is kept in the extension,
+// so that the admitters of a request only decode its payload once, and a copy of it is returned so that admitters
+// can't alter the objects seen by the others.
+func decodeClusterRepo(raw *runtime.RawExtension) (*v1.ClusterRepo, error) {
+	if decoded, ok := raw.Object.(*v1.ClusterRepo); ok {
+		return decoded.DeepCopy(), nil
+	}
+	object := &v1.ClusterRepo{}
+	if err := json.Unmarshal(raw.Raw, object); err != nil {
+		return nil, err
+	}
+	raw.Object = object.DeepCopy()
+	return object, nil
+}
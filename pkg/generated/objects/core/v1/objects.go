@@ -7,6 +7,7 @@ import (
 	admissionv1 "k8s.io/api/admission/v1"
 	"k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
 )
 
 // UnstructuredOldAndNewFromRequest gets the old and new Unstructured objects, respectively, from the webhook request.
@@ -21,7 +22,8 @@ func UnstructuredOldAndNewFromRequest(request *admissionv1.AdmissionRequest) (*u
 	oldObject := &unstructured.Unstructured{}
 
 	if request.Operation != admissionv1.Delete {
-		err := json.Unmarshal(request.Object.Raw, object)
+		var err error
+		object, err = decodeUnstructured(&request.Object)
 		if err != nil {
 			return nil, nil, fmt.Errorf("failed to unmarshal request object: %w", err)
 		}
@@ -31,7 +33,7 @@ func UnstructuredOldAndNewFromRequest(request *admissionv1.AdmissionRequest) (*u
 		return oldObject, object, nil
 	}
 
-	err := json.Unmarshal(request.OldObject.Raw, oldObject)
+	oldObject, err := decodeUnstructured(&request.OldObject)
 	if err != nil {
 		return nil, nil, fmt.Errorf("failed to unmarshal request oldObject: %w", err)
 	}
@@ -47,14 +49,13 @@ func UnstructuredFromRequest(request *admissionv1.AdmissionRequest) (*unstructur
 		return nil, fmt.Errorf("nil request")
 	}
 
-	object := &unstructured.Unstructured{}
-	raw := request.Object.Raw
+	raw := &request.Object
 
 	if request.Operation == admissionv1.Delete {
-		raw = request.OldObject.Raw
+		raw = &request.OldObject
 	}
 
-	err := json.Unmarshal(raw, object)
+	object, err := decodeUnstructured(raw)
 	if err != nil {
 		return nil, fmt.Errorf("failed to unmarshal request object: %w", err)
 	}
@@ -62,6 +63,21 @@ func UnstructuredFromRequest(request *admissionv1.AdmissionRequest) (*unstructur
 	return object, nil
 }
 
+// decodeUnstructured returns the Unstructured object of the raw extension. The decoded object is kept in the extension,
+// so that the admitters of a request only decode its payload once, and a copy of it is returned so that admitters
+// can't alter the objects seen by the others.
+func decodeUnstructured(raw *runtime.RawExtension) (*unstructured.Unstructured, error) {
+	if decoded, ok := raw.Object.(*unstructured.Unstructured); ok {
+		return decoded.DeepCopy(), nil
+	}
+	object := &unstructured.Unstructured{}
+	if err := json.Unmarshal(raw.Raw, object); err != nil {
+		return nil, err
+	}
+	raw.Object = object.DeepCopy()
+	return object, nil
+}
+
 // SecretOldAndNewFromRequest gets the old and new Secret objects, respectively, from the webhook request.
 // If the request is a Delete operation, then the new object is the zero value for Secret.
 // Similarly, if the request is a Create operation, then the old object is the zero value for Secret.
@@ -74,7 +90,8 @@ func SecretOldAndNewFromRequest(request *admissionv1.AdmissionRequest) (*v1.Secr
 	oldObject := &v1.Secret{}
 
 	if request.Operation != admissionv1.Delete {
-		err := json.Unmarshal(request.Object.Raw, object)
+		var err error
+		object, err = decodeSecret(&request.Object)
 		if err != nil {
 			return nil, nil, fmt.Errorf("failed to unmarshal request object: %w", err)
 		}
@@ -84,7 +101,7 @@ func SecretOldAndNewFromRequest(request *admissionv1.AdmissionRequest) (*v1.Secr
 		return oldObject, object, nil
 	}
 
-	err := json.Unmarshal(request.OldObject.Raw, oldObject)
+	oldObject, err := decodeSecret(&request.OldObject)
 	if err != nil {
 		return nil, nil, fmt.Errorf("failed to unmarshal request oldObject: %w", err)
 	}
@@ -100,14 +117,13 @@ func SecretFromRequest(request *admissionv1.AdmissionRequest) (*v1.Secret, error
 		return nil, fmt.Errorf("nil request")
 	}
 
-	object := &v1.Secret{}
-	raw := request.Object.Raw
+	raw := &request.Object
 
 	if request.Operation == admissionv1.Delete {
-		raw = request.OldObject.Raw
+		raw = &request.OldObject
 	}
 
-	err := json.Unmarshal(raw, object)
+	object, err := decodeSecret(raw)
 	if err != nil {
 		return nil, fmt.Errorf("failed to unmarshal request object: %w", err)
 	}
@@ -115,6 +131,21 @@ func SecretFromRequest(request *admissionv1.AdmissionRequest) (*v1.Secret, error
 	return object, nil
 }
 
+// decodeSecret returns the Secret object of the raw extension. The decoded object is kept in the extension,
+// so that the admitters of a request only decode its payload once, and a copy of it is returned so that admitters
+// can't alter the objects seen by the others.
+func decodeSecret(raw *runtime.RawExtension) (*v1.Secret, error) {
+	if decoded, ok := raw.Object.(*v1.Secret); ok {
+		return decoded.DeepCopy(), nil
+	}
+	object := &v1.Secret{}
+	if err := json.Unmarshal(raw.Raw, object); err != nil {
+		return nil, err
+	}
+	raw.Object = object.DeepCopy()
+	return object, nil
+}
+
 // NamespaceOldAndNewFromRequest gets the old and new Namespace objects, respectively, from the webhook request.
 // If the request is a Delete operation, then the new object is the zero value for Namespace.
 // Similarly, if the request is a Create operation, then the old object is the zero value for Namespace.
@@ -127,7 +158,8 @@ func NamespaceOldAndNewFromRequest(request *admissionv1.AdmissionRequest) (*v1.N
 	oldObject := &v1.Namespace{}
 
 	if request.Operation != admissionv1.Delete {
-		err := json.Unmarshal(request.Object.Raw, object)
+		var err error
+		object, err = decodeNamespace(&request.Object)
 		if err != nil {
 			return nil, nil, fmt.Errorf("failed to unmarshal request object: %w", err)
 		}
@@ -137,7 +169,7 @@ func NamespaceOldAndNewFromRequest(request *admissionv1.AdmissionRequest) (*v1.N
 		return oldObject, object, nil
 	}
 
-	err := json.Unmarshal(request.OldObject.Raw, oldObject)
+	oldObject, err := decodeNamespace(&request.OldObject)
 	if err != nil {
 		return nil, nil, fmt.Errorf("failed to unmarshal request oldObject: %w", err)
 	}
@@ -153,17 +185,31 @@ func NamespaceFromRequest(request *admissionv1.AdmissionRequest) (*v1.Namespace,
 		return nil, fmt.Errorf("nil request")
 	}
 
-	object := &v1.Namespace{}
-	raw := request.Object.Raw
+	raw := &request.Object
 
 	if request.Operation == admissionv1.Delete {
-		raw = request.OldObject.Raw
+		raw = &request.OldObject
 	}
 
-	err := json.Unmarshal(raw, object)
+	object, err := decodeNamespace(raw)
 	if err != nil {
 		return nil, fmt.Errorf("failed to unmarshal request object: %w", err)
 	}
 
 	return object, nil
 }
+
+// decodeNamespace returns the Namespace object of the raw extension. The decoded object is kept in the extension,
+// so that the admitters of a request only decode its payload once, and a copy of it is returned so that admitters
+// can't alter the objects seen by the others.
+func decodeNamespace(raw *runtime.RawExtension) (*v1.Namespace, error) {
+	if decoded, ok := raw.Object.(*v1.Namespace); ok {
+		return decoded.DeepCopy(), nil
+	}
+	object := &v1.Namespace{}
+	if err := json.Unmarshal(raw.Raw, object); err != nil {
+		return nil, err
+	}
+	raw.Object = object.DeepCopy()
+	return object, nil
+}
@@ -6,6 +6,7 @@ import (
 
 	"github.com/rancher/rancher/pkg/apis/management.cattle.io/v3"
 	admissionv1 "k8s.io/api/admission/v1"
+	"k8s.io/apimachinery/pkg/runtime"
 )
 
 // ClusterOldAndNewFromRequest gets the old and new Cluster objects, respectively, from the webhook request.
@@ -20,7 +21,8 @@ func ClusterOldAndNewFromRequest(request *admissionv1.AdmissionRequest) (*v3.Clu
 	oldObject := &v3.Cluster{}
 
 	if request.Operation != admissionv1.Delete {
-		err := json.Unmarshal(request.Object.Raw, object)
+		var err error
+		object, err = decodeCluster(&request.Object)
 		if err != nil {
 			return nil, nil, fmt.Errorf("failed to unmarshal request object: %w", err)
 		}
@@ -30,7 +32,7 @@ func ClusterOldAndNewFromRequest(request *admissionv1.AdmissionRequest) (*v3.Clu
 		return oldObject, object, nil
 	}
 
-	err := json.Unmarshal(request.OldObject.Raw, oldObject)
+	oldObject, err := decodeCluster(&request.OldObject)
 	if err != nil {
 		return nil, nil, fmt.Errorf("failed to unmarshal request oldObject: %w", err)
 	}
@@ -46,14 +48,13 @@ func ClusterFromRequest(request *admissionv1.AdmissionRequest) (*v3.Cluster, err
 		return nil, fmt.Errorf("nil request")
 	}
 
-	object := &v3.Cluster{}
-	raw := request.Object.Raw
+	raw := &request.Object
 
 	if request.Operation == admissionv1.Delete {
-		raw = request.OldObject.Raw
+		raw = &request.OldObject
 	}
 
-	err := json.Unmarshal(raw, object)
+	object, err := decodeCluster(raw)
 	if err != nil {
 		return nil, fmt.Errorf("failed to unmarshal request object: %w", err)
 	}
@@ -61,6 +62,21 @@ func ClusterFromRequest(request *admissionv1.AdmissionRequest) (*v3.Cluster, err
 	return object, nil
 }
 
+// decodeCluster returns the Cluster object of the raw extension. The decoded object is kept in the extension,
+// so that the admitters of a request only decode its payload once, and a copy of it is returned so that admitters
+// can't alter the objects seen by the others.
+func decodeCluster(raw *runtime.RawExtension) (*v3.Cluster, error) {
+	if decoded, ok := raw.Object.(*v3.Cluster); ok {
+		return decoded.DeepCopy(), nil
+	}
+	object := &v3.Cluster{}
+	if err := json.Unmarshal(raw.Raw, object); err != nil {
+		return nil, err
+	}
+	raw.Object = object.DeepCopy()
+	return object, nil
+}
+
 // ClusterRoleTemplateBindingOldAndNewFromRequest gets the old and new ClusterRoleTemplateBinding objects, respectively, from the webhook request.
 // If the request is a Delete operation, then the new object is the zero value for ClusterRoleTemplateBinding.
 // Similarly, if the request is a Create operation, then the old object is the zero value for ClusterRoleTemplateBinding.
@@ -73,7 +89,8 @@ func ClusterRoleTemplateBindingOldAndNewFromRequest(request *admissionv1.Admissi
 	oldObject := &v3.ClusterRoleTemplateBinding{}
 
 	if request.Operation != admissionv1.Delete {
-		err := json.Unmarshal(request.Object.Raw, object)
+		var err error
+		object, err = decodeClusterRoleTemplateBinding(&request.Object)
 		if err != nil {
 			return nil, nil, fmt.Errorf("failed to unmarshal request object: %w", err)
 		}
@@ -83,7 +100,7 @@ func ClusterRoleTemplateBindingOldAndNewFromRequest(request *admissionv1.Admissi
 		return oldObject, object, nil
 	}
 
-	err := json.Unmarshal(request.OldObject.Raw, oldObject)
+	oldObject, err := decodeClusterRoleTemplateBinding(&request.OldObject)
 	if err != nil {
 		return nil, nil, fmt.Errorf("failed to unmarshal request oldObject: %w", err)
 	}
@@ -99,14 +116,81 @@ func ClusterRoleTemplateBindingFromRequest(request *admissionv1.AdmissionRequest
 		return nil, fmt.Errorf("nil request")
 	}
 
+	raw := &request.Object
+
+	if request.Operation == admissionv1.Delete {
+		raw = &request.OldObject
+	}
+
+	object, err := decodeClusterRoleTemplateBinding(raw)
+	if err != nil {
+		return nil, fmt.Errorf("failed to unmarshal request object: %w", err)
+	}
+
+	return object, nil
+}
+
+// decodeClusterRoleTemplateBinding returns the ClusterRoleTemplateBinding object of the raw extension. The decoded object is kept in the extension,
+// so that the admitters of a request only decode its payload once, and a copy of it is returned so that admitters
+// can't alter the objects seen by the others.
+func decodeClusterRoleTemplateBinding(raw *runtime.RawExtension) (*v3.ClusterRoleTemplateBinding, error) {
+	if decoded, ok := raw.Object.(*v3.ClusterRoleTemplateBinding); ok {
+		return decoded.DeepCopy(), nil
+	}
 	object := &v3.ClusterRoleTemplateBinding{}
-	raw := request.Object.Raw
+	if err := json.Unmarshal(raw.Raw, object); err != nil {
+		return nil, err
+	}
+	raw.Object = object.DeepCopy()
+	return object, nil
+}
+
+// ClusterTemplateOldAndNewFromRequest gets the old and new ClusterTemplate objects, respectively, from the webhook request.
+// If the request is a Delete operation, then the new object is the zero value for ClusterTemplate.
+// Similarly, if the request is a Create operation, then the old object is the zero value for ClusterTemplate.
+func ClusterTemplateOldAndNewFromRequest(request *admissionv1.AdmissionRequest) (*v3.ClusterTemplate, *v3.ClusterTemplate, error) {
+	if request == nil {
+		return nil, nil, fmt.Errorf("nil request")
+	}
+
+	object := &v3.ClusterTemplate{}
+	oldObject := &v3.ClusterTemplate{}
+
+	if request.Operation != admissionv1.Delete {
+		var err error
+		object, err = decodeClusterTemplate(&request.Object)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to unmarshal request object: %w", err)
+		}
+	}
+
+	if request.Operation == admissionv1.Create {
+		return oldObject, object, nil
+	}
+
+	oldObject, err := decodeClusterTemplate(&request.OldObject)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to unmarshal request oldObject: %w", err)
+	}
+
+	return oldObject, object, nil
+}
+
+// ClusterTemplateFromRequest returns a ClusterTemplate object from the webhook request.
+// If the operation is a Delete operation, then the old object is returned.
+// Otherwise, the new object is returned.
+func ClusterTemplateFromRequest(request *admissionv1.AdmissionRequest) (*v3.ClusterTemplate, error) {
+	if request == nil {
+		return nil, fmt.Errorf("nil request")
+	}
+
+	raw := &request.Object
 
 	if request.Operation == admissionv1.Delete {
-		raw = request.OldObject.Raw
+		raw = &request.OldObject
 	}
 
-	err := json.Unmarshal(raw, object)
+	object, err := decodeClusterTemplate(raw)
 	if err != nil {
 		return nil, fmt.Errorf("failed to unmarshal request object: %w", err)
 	}
@@ -114,6 +198,89 @@ func ClusterRoleTemplateBindingFromRequest(request *admissionv1.AdmissionRequest
 	return object, nil
 }
 
+// decodeClusterTemplate returns the ClusterTemplate object of the raw extension. The decoded object is kept in the extension,
+// so that the admitters of a request only decode its payload once, and a copy of it is returned so that admitters
+// can't alter the objects seen by the others.
+func decodeClusterTemplate(raw *runtime.RawExtension) (*v3.ClusterTemplate, error) {
+	if decoded, ok := raw.Object.(*v3.ClusterTemplate); ok {
+		return decoded.DeepCopy(), nil
+	}
+	object := &v3.ClusterTemplate{}
+	if err := json.Unmarshal(raw.Raw, object); err != nil {
+		return nil, err
+	}
+	raw.Object = object.DeepCopy()
+	return object, nil
+}
+
+// ClusterTemplateRevisionOldAndNewFromRequest gets the old and new ClusterTemplateRevision objects, respectively, from the webhook request.
+// If the request is a Delete operation, then the new object is the zero value for ClusterTemplateRevision.
+// Similarly, if the request is a Create operation, then the old object is the zero value for ClusterTemplateRevision.
+func ClusterTemplateRevisionOldAndNewFromRequest(request *admissionv1.AdmissionRequest) (*v3.ClusterTemplateRevision, *v3.ClusterTemplateRevision, error) {
+	if request == nil {
+		return nil, nil, fmt.Errorf("nil request")
+	}
+
+	object := &v3.ClusterTemplateRevision{}
+	oldObject := &v3.ClusterTemplateRevision{}
+
+	if request.Operation != admissionv1.Delete {
+		var err error
+		object, err = decodeClusterTemplateRevision(&request.Object)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to unmarshal request object: %w", err)
+		}
+	}
+
+	if request.Operation == admissionv1.Create {
+		return oldObject, object, nil
+	}
+
+	oldObject, err := decodeClusterTemplateRevision(&request.OldObject)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to unmarshal request oldObject: %w", err)
+	}
+
+	return oldObject, object, nil
+}
+
+// ClusterTemplateRevisionFromRequest returns a ClusterTemplateRevision object from the webhook request.
+// If the operation is a Delete operation, then the old object is returned.
+// Otherwise, the new object is returned.
+func ClusterTemplateRevisionFromRequest(request *admissionv1.AdmissionRequest) (*v3.ClusterTemplateRevision, error) {
+	if request == nil {
+		return nil, fmt.Errorf("nil request")
+	}
+
+	raw := &request.Object
+
+	if request.Operation == admissionv1.Delete {
+		raw = &request.OldObject
+	}
+
+	object, err := decodeClusterTemplateRevision(raw)
+	if err != nil {
+		return nil, fmt.Errorf("failed to unmarshal request object: %w", err)
+	}
+
+	return object, nil
+}
+
+// decodeClusterTemplateRevision returns the ClusterTemplateRevision object of the raw extension. The decoded object is kept in the extension,
+// so that the admitters of a request only decode its payload once, and a copy of it is returned so that admitters
+// can't alter the objects seen by the others.
+func decodeClusterTemplateRevision(raw *runtime.RawExtension) (*v3.ClusterTemplateRevision, error) {
+	if decoded, ok := raw.Object.(*v3.ClusterTemplateRevision); ok {
+		return decoded.DeepCopy(), nil
+	}
+	object := &v3.ClusterTemplateRevision{}
+	if err := json.Unmarshal(raw.Raw, object); err != nil {
+		return nil, err
+	}
+	raw.Object = object.DeepCopy()
+	return object, nil
+}
+
 // FeatureOldAndNewFromRequest gets the old and new Feature objects, respectively, from the webhook request.
 // If the request is a Delete operation, then the new object is the zero value for Feature.
 // Similarly, if the request is a Create operation, then the old object is the zero value for Feature.
@@ -126,7 +293,8 @@ func FeatureOldAndNewFromRequest(request *admissionv1.AdmissionRequest) (*v3.Fea
 	oldObject := &v3.Feature{}
 
 	if request.Operation != admissionv1.Delete {
-		err := json.Unmarshal(request.Object.Raw, object)
+		var err error
+		object, err = decodeFeature(&request.Object)
 		if err != nil {
 			return nil, nil, fmt.Errorf("failed to unmarshal request object: %w", err)
 		}
@@ -136,7 +304,7 @@ func FeatureOldAndNewFromRequest(request *admissionv1.AdmissionRequest) (*v3.Fea
 		return oldObject, object, nil
 	}
 
-	err := json.Unmarshal(request.OldObject.Raw, oldObject)
+	oldObject, err := decodeFeature(&request.OldObject)
 	if err != nil {
 		return nil, nil, fmt.Errorf("failed to unmarshal request oldObject: %w", err)
 	}
@@ -152,14 +320,13 @@ func FeatureFromRequest(request *admissionv1.AdmissionRequest) (*v3.Feature, err
 		return nil, fmt.Errorf("nil request")
 	}
 
-	object := &v3.Feature{}
-	raw := request.Object.Raw
+	raw := &request.Object
 
 	if request.Operation == admissionv1.Delete {
-		raw = request.OldObject.Raw
+		raw = &request.OldObject
 	}
 
-	err := json.Unmarshal(raw, object)
+	object, err := decodeFeature(raw)
 	if err != nil {
 		return nil, fmt.Errorf("failed to unmarshal request object: %w", err)
 	}
@@ -167,6 +334,21 @@ func FeatureFromRequest(request *admissionv1.AdmissionRequest) (*v3.Feature, err
 	return object, nil
 }
 
+// decodeFeature returns the Feature object of the raw extension. The decoded object is kept in the extension,
+// so that the admitters of a request only decode its payload once, and a copy of it is returned so that admitters
+// can't alter the objects seen by the others.
+func decodeFeature(raw *runtime.RawExtension) (*v3.Feature, error) {
+	if decoded, ok := raw.Object.(*v3.Feature); ok {
+		return decoded.DeepCopy(), nil
+	}
+	object := &v3.Feature{}
+	if err := json.Unmarshal(raw.Raw, object); err != nil {
+		return nil, err
+	}
+	raw.Object = object.DeepCopy()
+	return object, nil
+}
+
 // FleetWorkspaceOldAndNewFromRequest gets the old and new FleetWorkspace objects, respectively, from the webhook request.
 // If the request is a Delete operation, then the new object is the zero value for FleetWorkspace.
 // Similarly, if the request is a Create operation, then the old object is the zero value for FleetWorkspace.
@@ -179,7 +361,8 @@ func FleetWorkspaceOldAndNewFromRequest(request *admissionv1.AdmissionRequest) (
 	oldObject := &v3.FleetWorkspace{}
 
 	if request.Operation != admissionv1.Delete {
-		err := json.Unmarshal(request.Object.Raw, object)
+		var err error
+		object, err = decodeFleetWorkspace(&request.Object)
 		if err != nil {
 			return nil, nil, fmt.Errorf("failed to unmarshal request object: %w", err)
 		}
@@ -189,7 +372,7 @@ func FleetWorkspaceOldAndNewFromRequest(request *admissionv1.AdmissionRequest) (
 		return oldObject, object, nil
 	}
 
-	err := json.Unmarshal(request.OldObject.Raw, oldObject)
+	oldObject, err := decodeFleetWorkspace(&request.OldObject)
 	if err != nil {
 		return nil, nil, fmt.Errorf("failed to unmarshal request oldObject: %w", err)
 	}
@@ -205,14 +388,13 @@ func FleetWorkspaceFromRequest(request *admissionv1.AdmissionRequest) (*v3.Fleet
 		return nil, fmt.Errorf("nil request")
 	}
 
-	object := &v3.FleetWorkspace{}
-	raw := request.Object.Raw
+	raw := &request.Object
 
 	if request.Operation == admissionv1.Delete {
-		raw = request.OldObject.Raw
+		raw = &request.OldObject
 	}
 
-	err := json.Unmarshal(raw, object)
+	object, err := decodeFleetWorkspace(raw)
 	if err != nil {
 		return nil, fmt.Errorf("failed to unmarshal request object: %w", err)
 	}
@@ -220,6 +402,21 @@ func FleetWorkspaceFromRequest(request *admissionv1.AdmissionRequest) (*v3.Fleet
 	return object, nil
 }
 
+// decodeFleetWorkspace returns the FleetWorkspace object of the raw extension. The decoded object is kept in the extension,
+// so that the admitters of a request only decode its payload once, and a copy of it is returned so that admitters
+// can't alter the objects seen by the others.
+func decodeFleetWorkspace(raw *runtime.RawExtension) (*v3.FleetWorkspace, error) {
+	if decoded, ok := raw.Object.(*v3.FleetWorkspace); ok {
+		return decoded.DeepCopy(), nil
+	}
+	object := &v3.FleetWorkspace{}
+	if err := json.Unmarshal(raw.Raw, object); err != nil {
+		return nil, err
+	}
+	raw.Object = object.DeepCopy()
+	return object, nil
+}
+
 // PodSecurityAdmissionConfigurationTemplateOldAndNewFromRequest gets the old and new PodSecurityAdmissionConfigurationTemplate objects, respectively, from the webhook request.
 // If the request is a Delete operation, then the new object is the zero value for PodSecurityAdmissionConfigurationTemplate.
 // Similarly, if the request is a Create operation, then the old object is the zero value for PodSecurityAdmissionConfigurationTemplate.
@@ -232,7 +429,8 @@ func PodSecurityAdmissionConfigurationTemplateOldAndNewFromRequest(request *admi
 	oldObject := &v3.PodSecurityAdmissionConfigurationTemplate{}
 
 	if request.Operation != admissionv1.Delete {
-		err := json.Unmarshal(request.Object.Raw, object)
+		var err error
+		object, err = decodePodSecurityAdmissionConfigurationTemplate(&request.Object)
 		if err != nil {
 			return nil, nil, fmt.Errorf("failed to unmarshal request object: %w", err)
 		}
@@ -242,7 +440,7 @@ func PodSecurityAdmissionConfigurationTemplateOldAndNewFromRequest(request *admi
 		return oldObject, object, nil
 	}
 
-	err := json.Unmarshal(request.OldObject.Raw, oldObject)
+	oldObject, err := decodePodSecurityAdmissionConfigurationTemplate(&request.OldObject)
 	if err != nil {
 		return nil, nil, fmt.Errorf("failed to unmarshal request oldObject: %w", err)
 	}
@@ -258,14 +456,13 @@ func PodSecurityAdmissionConfigurationTemplateFromRequest(request *admissionv1.A
 		return nil, fmt.Errorf("nil request")
 	}
 
-	object := &v3.PodSecurityAdmissionConfigurationTemplate{}
-	raw := request.Object.Raw
+	raw := &request.Object
 
 	if request.Operation == admissionv1.Delete {
-		raw = request.OldObject.Raw
+		raw = &request.OldObject
 	}
 
-	err := json.Unmarshal(raw, object)
+	object, err := decodePodSecurityAdmissionConfigurationTemplate(raw)
 	if err != nil {
 		return nil, fmt.Errorf("failed to unmarshal request object: %w", err)
 	}
@@ -273,6 +470,21 @@ func PodSecurityAdmissionConfigurationTemplateFromRequest(request *admissionv1.A
 	return object, nil
 }
 
+// decodePodSecurityAdmissionConfigurationTemplate returns the PodSecurityAdmissionConfigurationTemplate object of the raw extension. The decoded object is kept in the extension,
+// so that the admitters of a request only decode its payload once, and a copy of it is returned so that admitters
+// can't alter the objects seen by the others.
+func decodePodSecurityAdmissionConfigurationTemplate(raw *runtime.RawExtension) (*v3.PodSecurityAdmissionConfigurationTemplate, error) {
+	if decoded, ok := raw.Object.(*v3.PodSecurityAdmissionConfigurationTemplate); ok {
+		return decoded.DeepCopy(), nil
+	}
+	object := &v3.PodSecurityAdmissionConfigurationTemplate{}
+	if err := json.Unmarshal(raw.Raw, object); err != nil {
+		return nil, err
+	}
+	raw.Object = object.DeepCopy()
+	return object, nil
+}
+
 // GlobalRoleOldAndNewFromRequest gets the old and new GlobalRole objects, respectively, from the webhook request.
 // If the request is a Delete operation, then the new object is the zero value for GlobalRole.
 // Similarly, if the request is a Create operation, then the old object is the zero value for GlobalRole.
@@ -285,7 +497,8 @@ func GlobalRoleOldAndNewFromRequest(request *admissionv1.AdmissionRequest) (*v3.
 	oldObject := &v3.GlobalRole{}
 
 	if request.Operation != admissionv1.Delete {
-		err := json.Unmarshal(request.Object.Raw, object)
+		var err error
+		object, err = decodeGlobalRole(&request.Object)
 		if err != nil {
 			return nil, nil, fmt.Errorf("failed to unmarshal request object: %w", err)
 		}
@@ -295,7 +508,7 @@ func GlobalRoleOldAndNewFromRequest(request *admissionv1.AdmissionRequest) (*v3.
 		return oldObject, object, nil
 	}
 
-	err := json.Unmarshal(request.OldObject.Raw, oldObject)
+	oldObject, err := decodeGlobalRole(&request.OldObject)
 	if err != nil {
 		return nil, nil, fmt.Errorf("failed to unmarshal request oldObject: %w", err)
 	}
@@ -311,14 +524,13 @@ func GlobalRoleFromRequest(request *admissionv1.AdmissionRequest) (*v3.GlobalRol
 		return nil, fmt.Errorf("nil request")
 	}
 
-	object := &v3.GlobalRole{}
-	raw := request.Object.Raw
+	raw := &request.Object
 
 	if request.Operation == admissionv1.Delete {
-		raw = request.OldObject.Raw
+		raw = &request.OldObject
 	}
 
-	err := json.Unmarshal(raw, object)
+	object, err := decodeGlobalRole(raw)
 	if err != nil {
 		return nil, fmt.Errorf("failed to unmarshal request object: %w", err)
 	}
@@ -326,6 +538,21 @@ func GlobalRoleFromRequest(request *admissionv1.AdmissionRequest) (*v3.GlobalRol
 	return object, nil
 }
 
+// decodeGlobalRole returns the GlobalRole object of the raw extension. The decoded object is kept in the extension,
+// so that the admitters of a request only decode its payload once, and a copy of it is returned so that admitters
+// can't alter the objects seen by the others.
+func decodeGlobalRole(raw *runtime.RawExtension) (*v3.GlobalRole, error) {
+	if decoded, ok := raw.Object.(*v3.GlobalRole); ok {
+		return decoded.DeepCopy(), nil
+	}
+	object := &v3.GlobalRole{}
+	if err := json.Unmarshal(raw.Raw, object); err != nil {
+		return nil, err
+	}
+	raw.Object = object.DeepCopy()
+	return object, nil
+}
+
 // GlobalRoleBindingOldAndNewFromRequest gets the old and new GlobalRoleBinding objects, respectively, from the webhook request.
 // If the request is a Delete operation, then the new object is the zero value for GlobalRoleBinding.
 // Similarly, if the request is a Create operation, then the old object is the zero value for GlobalRoleBinding.
@@ -338,7 +565,8 @@ func GlobalRoleBindingOldAndNewFromRequest(request *admissionv1.AdmissionRequest
 	oldObject := &v3.GlobalRoleBinding{}
 
 	if request.Operation != admissionv1.Delete {
-		err := json.Unmarshal(request.Object.Raw, object)
+		var err error
+		object, err = decodeGlobalRoleBinding(&request.Object)
 		if err != nil {
 			return nil, nil, fmt.Errorf("failed to unmarshal request object: %w", err)
 		}
@@ -348,7 +576,7 @@ func GlobalRoleBindingOldAndNewFromRequest(request *admissionv1.AdmissionRequest
 		return oldObject, object, nil
 	}
 
-	err := json.Unmarshal(request.OldObject.Raw, oldObject)
+	oldObject, err := decodeGlobalRoleBinding(&request.OldObject)
 	if err != nil {
 		return nil, nil, fmt.Errorf("failed to unmarshal request oldObject: %w", err)
 	}
@@ -364,14 +592,13 @@ func GlobalRoleBindingFromRequest(request *admissionv1.AdmissionRequest) (*v3.Gl
 		return nil, fmt.Errorf("nil request")
 	}
 
-	object := &v3.GlobalRoleBinding{}
-	raw := request.Object.Raw
+	raw := &request.Object
 
 	if request.Operation == admissionv1.Delete {
-		raw = request.OldObject.Raw
+		raw = &request.OldObject
 	}
 
-	err := json.Unmarshal(raw, object)
+	object, err := decodeGlobalRoleBinding(raw)
 	if err != nil {
 		return nil, fmt.Errorf("failed to unmarshal request object: %w", err)
 	}
@@ -379,6 +606,21 @@ func GlobalRoleBindingFromRequest(request *admissionv1.AdmissionRequest) (*v3.Gl
 	return object, nil
 }
 
+// decodeGlobalRoleBinding returns the GlobalRoleBinding object of the raw extension. The decoded object is kept in the extension,
+// so that the admitters of a request only decode its payload once, and a copy of it is returned so that admitters
+// can't alter the objects seen by the others.
+func decodeGlobalRoleBinding(raw *runtime.RawExtension) (*v3.GlobalRoleBinding, error) {
+	if decoded, ok := raw.Object.(*v3.GlobalRoleBinding); ok {
+		return decoded.DeepCopy(), nil
+	}
+	object := &v3.GlobalRoleBinding{}
+	if err := json.Unmarshal(raw.Raw, object); err != nil {
+		return nil, err
+	}
+	raw.Object = object.DeepCopy()
+	return object, nil
+}
+
 // RoleTemplateOldAndNewFromRequest gets the old and new RoleTemplate objects, respectively, from the webhook request.
 // If the request is a Delete operation, then the new object is the zero value for RoleTemplate.
 // Similarly, if the request is a Create operation, then the old object is the zero value for RoleTemplate.
@@ -391,7 +633,8 @@ func RoleTemplateOldAndNewFromRequest(request *admissionv1.AdmissionRequest) (*v
 	oldObject := &v3.RoleTemplate{}
 
 	if request.Operation != admissionv1.Delete {
-		err := json.Unmarshal(request.Object.Raw, object)
+		var err error
+		object, err = decodeRoleTemplate(&request.Object)
 		if err != nil {
 			return nil, nil, fmt.Errorf("failed to unmarshal request object: %w", err)
 		}
@@ -401,7 +644,7 @@ func RoleTemplateOldAndNewFromRequest(request *admissionv1.AdmissionRequest) (*v
 		return oldObject, object, nil
 	}
 
-	err := json.Unmarshal(request.OldObject.Raw, oldObject)
+	oldObject, err := decodeRoleTemplate(&request.OldObject)
 	if err != nil {
 		return nil, nil, fmt.Errorf("failed to unmarshal request oldObject: %w", err)
 	}
@@ -417,14 +660,13 @@ func RoleTemplateFromRequest(request *admissionv1.AdmissionRequest) (*v3.RoleTem
 		return nil, fmt.Errorf("nil request")
 	}
 
-	object := &v3.RoleTemplate{}
-	raw := request.Object.Raw
+	raw := &request.Object
 
 	if request.Operation == admissionv1.Delete {
-		raw = request.OldObject.Raw
+		raw = &request.OldObject
 	}
 
-	err := json.Unmarshal(raw, object)
+	object, err := decodeRoleTemplate(raw)
 	if err != nil {
 		return nil, fmt.Errorf("failed to unmarshal request object: %w", err)
 	}
@@ -432,6 +674,21 @@ func RoleTemplateFromRequest(request *admissionv1.AdmissionRequest) (*v3.RoleTem
 	return object, nil
 }
 
+// decodeRoleTemplate returns the RoleTemplate object of the raw extension. The decoded object is kept in the extension,
+// so that the admitters of a request only decode its payload once, and a copy of it is returned so that admitters
+// can't alter the objects seen by the others.
+func decodeRoleTemplate(raw *runtime.RawExtension) (*v3.RoleTemplate, error) {
+	if decoded, ok := raw.Object.(*v3.RoleTemplate); ok {
+		return decoded.DeepCopy(), nil
+	}
+	object := &v3.RoleTemplate{}
+	if err := json.Unmarshal(raw.Raw, object); err != nil {
+		return nil, err
+	}
+	raw.Object = object.DeepCopy()
+	return object, nil
+}
+
 // ProjectRoleTemplateBindingOldAndNewFromRequest gets the old and new ProjectRoleTemplateBinding objects, respectively, from the webhook request.
 // If the request is a Delete operation, then the new object is the zero value for ProjectRoleTemplateBinding.
 // Similarly, if the request is a Create operation, then the old object is the zero value for ProjectRoleTemplateBinding.
@@ -444,7 +701,8 @@ func ProjectRoleTemplateBindingOldAndNewFromRequest(request *admissionv1.Admissi
 	oldObject := &v3.ProjectRoleTemplateBinding{}
 
 	if request.Operation != admissionv1.Delete {
-		err := json.Unmarshal(request.Object.Raw, object)
+		var err error
+		object, err = decodeProjectRoleTemplateBinding(&request.Object)
 		if err != nil {
 			return nil, nil, fmt.Errorf("failed to unmarshal request object: %w", err)
 		}
@@ -454,7 +712,7 @@ func ProjectRoleTemplateBindingOldAndNewFromRequest(request *admissionv1.Admissi
 		return oldObject, object, nil
 	}
 
-	err := json.Unmarshal(request.OldObject.Raw, oldObject)
+	oldObject, err := decodeProjectRoleTemplateBinding(&request.OldObject)
 	if err != nil {
 		return nil, nil, fmt.Errorf("failed to unmarshal request oldObject: %w", err)
 	}
@@ -470,14 +728,13 @@ func ProjectRoleTemplateBindingFromRequest(request *admissionv1.AdmissionRequest
 		return nil, fmt.Errorf("nil request")
 	}
 
-	object := &v3.ProjectRoleTemplateBinding{}
-	raw := request.Object.Raw
+	raw := &request.Object
 
 	if request.Operation == admissionv1.Delete {
-		raw = request.OldObject.Raw
+		raw = &request.OldObject
 	}
 
-	err := json.Unmarshal(raw, object)
+	object, err := decodeProjectRoleTemplateBinding(raw)
 	if err != nil {
 		return nil, fmt.Errorf("failed to unmarshal request object: %w", err)
 	}
@@ -485,6 +742,21 @@ func ProjectRoleTemplateBindingFromRequest(request *admissionv1.AdmissionRequest
 	return object, nil
 }
 
+// decodeProjectRoleTemplateBinding returns the ProjectRoleTemplateBinding object of the raw extension. The decoded object is kept in the extension,
+// so that the admitters of a request only decode its payload once, and a copy of it is returned so that admitters
+// can't alter the objects seen by the others.
+func decodeProjectRoleTemplateBinding(raw *runtime.RawExtension) (*v3.ProjectRoleTemplateBinding, error) {
+	if decoded, ok := raw.Object.(*v3.ProjectRoleTemplateBinding); ok {
+		return decoded.DeepCopy(), nil
+	}
+	object := &v3.ProjectRoleTemplateBinding{}
+	if err := json.Unmarshal(raw.Raw, object); err != nil {
+		return nil, err
+	}
+	raw.Object = object.DeepCopy()
+	return object, nil
+}
+
 // NodeDriverOldAndNewFromRequest gets the old and new NodeDriver objects, respectively, from the webhook request.
 // If the request is a Delete operation, then the new object is the zero value for NodeDriver.
 // Similarly, if the request is a Create operation, then the old object is the zero value for NodeDriver.
@@ -497,7 +769,8 @@ func NodeDriverOldAndNewFromRequest(request *admissionv1.AdmissionRequest) (*v3.
 	oldObject := &v3.NodeDriver{}
 
 	if request.Operation != admissionv1.Delete {
-		err := json.Unmarshal(request.Object.Raw, object)
+		var err error
+		object, err = decodeNodeDriver(&request.Object)
 		if err != nil {
 			return nil, nil, fmt.Errorf("failed to unmarshal request object: %w", err)
 		}
@@ -507,7 +780,7 @@ func NodeDriverOldAndNewFromRequest(request *admissionv1.AdmissionRequest) (*v3.
 		return oldObject, object, nil
 	}
 
-	err := json.Unmarshal(request.OldObject.Raw, oldObject)
+	oldObject, err := decodeNodeDriver(&request.OldObject)
 	if err != nil {
 		return nil, nil, fmt.Errorf("failed to unmarshal request oldObject: %w", err)
 	}
@@ -523,14 +796,81 @@ func NodeDriverFromRequest(request *admissionv1.AdmissionRequest) (*v3.NodeDrive
 		return nil, fmt.Errorf("nil request")
 	}
 
+	raw := &request.Object
+
+	if request.Operation == admissionv1.Delete {
+		raw = &request.OldObject
+	}
+
+	object, err := decodeNodeDriver(raw)
+	if err != nil {
+		return nil, fmt.Errorf("failed to unmarshal request object: %w", err)
+	}
+
+	return object, nil
+}
+
+// decodeNodeDriver returns the NodeDriver object of the raw extension. The decoded object is kept in the extension,
+// so that the admitters of a request only decode its payload once, and a copy of it is returned so that admitters
+// can't alter the objects seen by the others.
+func decodeNodeDriver(raw *runtime.RawExtension) (*v3.NodeDriver, error) {
+	if decoded, ok := raw.Object.(*v3.NodeDriver); ok {
+		return decoded.DeepCopy(), nil
+	}
 	object := &v3.NodeDriver{}
-	raw := request.Object.Raw
+	if err := json.Unmarshal(raw.Raw, object); err != nil {
+		return nil, err
+	}
+	raw.Object = object.DeepCopy()
+	return object, nil
+}
+
+// NodeTemplateOldAndNewFromRequest gets the old and new NodeTemplate objects, respectively, from the webhook request.
+// If the request is a Delete operation, then the new object is the zero value for NodeTemplate.
+// Similarly, if the request is a Create operation, then the old object is the zero value for NodeTemplate.
+func NodeTemplateOldAndNewFromRequest(request *admissionv1.AdmissionRequest) (*v3.NodeTemplate, *v3.NodeTemplate, error) {
+	if request == nil {
+		return nil, nil, fmt.Errorf("nil request")
+	}
+
+	object := &v3.NodeTemplate{}
+	oldObject := &v3.NodeTemplate{}
+
+	if request.Operation != admissionv1.Delete {
+		var err error
+		object, err = decodeNodeTemplate(&request.Object)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to unmarshal request object: %w", err)
+		}
+	}
+
+	if request.Operation == admissionv1.Create {
+		return oldObject, object, nil
+	}
+
+	oldObject, err := decodeNodeTemplate(&request.OldObject)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to unmarshal request oldObject: %w", err)
+	}
+
+	return oldObject, object, nil
+}
+
+// NodeTemplateFromRequest returns a NodeTemplate object from the webhook request.
+// If the operation is a Delete operation, then the old object is returned.
+// Otherwise, the new object is returned.
+func NodeTemplateFromRequest(request *admissionv1.AdmissionRequest) (*v3.NodeTemplate, error) {
+	if request == nil {
+		return nil, fmt.Errorf("nil request")
+	}
+
+	raw := &request.Object
 
 	if request.Operation == admissionv1.Delete {
-		raw = request.OldObject.Raw
+		raw = &request.OldObject
 	}
 
-	err := json.Unmarshal(raw, object)
+	object, err := decodeNodeTemplate(raw)
 	if err != nil {
 		return nil, fmt.Errorf("failed to unmarshal request object: %w", err)
 	}
@@ -538,6 +878,21 @@ func NodeDriverFromRequest(request *admissionv1.AdmissionRequest) (*v3.NodeDrive
 	return object, nil
 }
 
+// decodeNodeTemplate returns the NodeTemplate object of the raw extension. The decoded object is kept in the extension,
+// so that the admitters of a request only decode its payload once, and a copy of it is returned so that admitters
+// can't alter the objects seen by the others.
+func decodeNodeTemplate(raw *runtime.RawExtension) (*v3.NodeTemplate, error) {
+	if decoded, ok := raw.Object.(*v3.NodeTemplate); ok {
+		return decoded.DeepCopy(), nil
+	}
+	object := &v3.NodeTemplate{}
+	if err := json.Unmarshal(raw.Raw, object); err != nil {
+		return nil, err
+	}
+	raw.Object = object.DeepCopy()
+	return object, nil
+}
+
 // ProjectOldAndNewFromRequest gets the old and new Project objects, respectively, from the webhook request.
 // If the request is a Delete operation, then the new object is the zero value for Project.
 // Similarly, if the request is a Create operation, then the old object is the zero value for Project.
@@ -550,7 +905,8 @@ func ProjectOldAndNewFromRequest(request *admissionv1.AdmissionRequest) (*v3.Pro
 	oldObject := &v3.Project{}
 
 	if request.Operation != admissionv1.Delete {
-		err := json.Unmarshal(request.Object.Raw, object)
+		var err error
+		object, err = decodeProject(&request.Object)
 		if err != nil {
 			return nil, nil, fmt.Errorf("failed to unmarshal request object: %w", err)
 		}
@@ -560,7 +916,7 @@ func ProjectOldAndNewFromRequest(request *admissionv1.AdmissionRequest) (*v3.Pro
 		return oldObject, object, nil
 	}
 
-	err := json.Unmarshal(request.OldObject.Raw, oldObject)
+	oldObject, err := decodeProject(&request.OldObject)
 	if err != nil {
 		return nil, nil, fmt.Errorf("failed to unmarshal request oldObject: %w", err)
 	}
@@ -576,14 +932,13 @@ func ProjectFromRequest(request *admissionv1.AdmissionRequest) (*v3.Project, err
 		return nil, fmt.Errorf("nil request")
 	}
 
-	object := &v3.Project{}
-	raw := request.Object.Raw
+	raw := &request.Object
 
 	if request.Operation == admissionv1.Delete {
-		raw = request.OldObject.Raw
+		raw = &request.OldObject
 	}
 
-	err := json.Unmarshal(raw, object)
+	object, err := decodeProject(raw)
 	if err != nil {
 		return nil, fmt.Errorf("failed to unmarshal request object: %w", err)
 	}
@@ -591,6 +946,21 @@ func ProjectFromRequest(request *admissionv1.AdmissionRequest) (*v3.Project, err
 	return object, nil
 }
 
+// decodeProject returns the Project object of the raw extension. The decoded object is kept in the extension,
+// so that the admitters of a request only decode its payload once, and a copy of it is returned so that admitters
+// can't alter the objects seen by the others.
+func decodeProject(raw *runtime.RawExtension) (*v3.Project, error) {
+	if decoded, ok := raw.Object.(*v3.Project); ok {
+		return decoded.DeepCopy(), nil
+	}
+	object := &v3.Project{}
+	if err := json.Unmarshal(raw.Raw, object); err != nil {
+		return nil, err
+	}
+	raw.Object = object.DeepCopy()
+	return object, nil
+}
+
 // SettingOldAndNewFromRequest gets the old and new Setting objects, respectively, from the webhook request.
 // If the request is a Delete operation, then the new object is the zero value for Setting.
 // Similarly, if the request is a Create operation, then the old object is the zero value for Setting.
@@ -603,7 +973,8 @@ func SettingOldAndNewFromRequest(request *admissionv1.AdmissionRequest) (*v3.Set
 	oldObject := &v3.Setting{}
 
 	if request.Operation != admissionv1.Delete {
-		err := json.Unmarshal(request.Object.Raw, object)
+		var err error
+		object, err = decodeSetting(&request.Object)
 		if err != nil {
 			return nil, nil, fmt.Errorf("failed to unmarshal request object: %w", err)
 		}
@@ -613,7 +984,7 @@ func SettingOldAndNewFromRequest(request *admissionv1.AdmissionRequest) (*v3.Set
 		return oldObject, object, nil
 	}
 
-	err := json.Unmarshal(request.OldObject.Raw, oldObject)
+	oldObject, err := decodeSetting(&request.OldObject)
 	if err != nil {
 		return nil, nil, fmt.Errorf("failed to unmarshal request oldObject: %w", err)
 	}
@@ -629,17 +1000,167 @@ func SettingFromRequest(request *admissionv1.AdmissionRequest) (*v3.Setting, err
 		return nil, fmt.Errorf("nil request")
 	}
 
+	raw := &request.Object
+
+	if request.Operation == admissionv1.Delete {
+		raw = &request.OldObject
+	}
+
+	object, err := decodeSetting(raw)
+	if err != nil {
+		return nil, fmt.Errorf("failed to unmarshal request object: %w", err)
+	}
+
+	return object, nil
+}
+
+// decodeSetting returns the Setting object of the raw extension. The decoded object is kept in the extension,
+// so that the admitters of a request only decode its payload once, and a copy of it is returned so that admitters
+// can't alter the objects seen by the others.
+func decodeSetting(raw *runtime.RawExtension) (*v3.Setting, error) {
+	if decoded, ok := raw.Object.(*v3.Setting); ok {
+		return decoded.DeepCopy(), nil
+	}
 	object := &v3.Setting{}
-	raw := request.Object.Raw
+	if err := json.Unmarshal(raw.Raw, object); err != nil {
+		return nil, err
+	}
+	raw.Object = object.DeepCopy()
+	return object, nil
+}
+
+// UserOldAndNewFromRequest gets the old and new User objects, respectively, from the webhook request.
+// If the request is a Delete operation, then the new object is the zero value for User.
+// Similarly, if the request is a Create operation, then the old object is the zero value for User.
+func UserOldAndNewFromRequest(request *admissionv1.AdmissionRequest) (*v3.User, *v3.User, error) {
+	if request == nil {
+		return nil, nil, fmt.Errorf("nil request")
+	}
+
+	object := &v3.User{}
+	oldObject := &v3.User{}
+
+	if request.Operation != admissionv1.Delete {
+		var err error
+		object, err = decodeUser(&request.Object)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to unmarshal request object: %w", err)
+		}
+	}
+
+	if request.Operation == admissionv1.Create {
+		return oldObject, object, nil
+	}
+
+	oldObject, err := decodeUser(&request.OldObject)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to unmarshal request oldObject: %w", err)
+	}
+
+	return oldObject, object, nil
+}
+
+// UserFromRequest returns a User object from the webhook request.
+// If the operation is a Delete operation, then the old object is returned.
+// Otherwise, the new object is returned.
+func UserFromRequest(request *admissionv1.AdmissionRequest) (*v3.User, error) {
+	if request == nil {
+		return nil, fmt.Errorf("nil request")
+	}
+
+	raw := &request.Object
+
+	if request.Operation == admissionv1.Delete {
+		raw = &request.OldObject
+	}
+
+	object, err := decodeUser(raw)
+	if err != nil {
+		return nil, fmt.Errorf("failed to unmarshal request object: %w", err)
+	}
+
+	return object, nil
+}
+
+// decodeUser returns the User object of the raw extension. The decoded object is kept in the extension,
+// so that the admitters of a request only decode its payload once, and a copy of it is returned so that admitters
+// can't alter the objects seen by the others.
+func decodeUser(raw *runtime.RawExtension) (*v3.User, error) {
+	if decoded, ok := raw.Object.(*v3.User); ok {
+		return decoded.DeepCopy(), nil
+	}
+	object := &v3.User{}
+	if err := json.Unmarshal(raw.Raw, object); err != nil {
+		return nil, err
+	}
+	raw.Object = object.DeepCopy()
+	return object, nil
+}
+
+// UserAttributeOldAndNewFromRequest gets the old and new UserAttribute objects, respectively, from the webhook request.
+// If the request is a Delete operation, then the new object is the zero value for UserAttribute.
+// Similarly, if the request is a Create operation, then the old object is the zero value for UserAttribute.
+func UserAttributeOldAndNewFromRequest(request *admissionv1.AdmissionRequest) (*v3.UserAttribute, *v3.UserAttribute, error) {
+	if request == nil {
+		return nil, nil, fmt.Errorf("nil request")
+	}
+
+	object := &v3.UserAttribute{}
+	oldObject := &v3.UserAttribute{}
+
+	if request.Operation != admissionv1.Delete {
+		var err error
+		object, err = decodeUserAttribute(&request.Object)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to unmarshal request object: %w", err)
+		}
+	}
+
+	if request.Operation == admissionv1.Create {
+		return oldObject, object, nil
+	}
+
+	oldObject, err := decodeUserAttribute(&request.OldObject)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to unmarshal request oldObject: %w", err)
+	}
+
+	return oldObject, object, nil
+}
+
+// UserAttributeFromRequest returns a UserAttribute object from the webhook request.
+// If the operation is a Delete operation, then the old object is returned.
+// Otherwise, the new object is returned.
+func UserAttributeFromRequest(request *admissionv1.AdmissionRequest) (*v3.UserAttribute, error) {
+	if request == nil {
+		return nil, fmt.Errorf("nil request")
+	}
+
+	raw := &request.Object
 
 	if request.Operation == admissionv1.Delete {
-		raw = request.OldObject.Raw
+		raw = &request.OldObject
 	}
 
-	err := json.Unmarshal(raw, object)
+	object, err := decodeUserAttribute(raw)
 	if err != nil {
 		return nil, fmt.Errorf("failed to unmarshal request object: %w", err)
 	}
 
 	return object, nil
 }
+
+// decodeUserAttribute returns the UserAttribute object of the raw extension. The decoded object is kept in the extension,
+// so that the admitters of a request only decode its payload once, and a copy of it is returned so that admitters
+// can't alter the objects seen by the others.
+func decodeUserAttribute(raw *runtime.RawExtension) (*v3.UserAttribute, error) {
+	if decoded, ok := raw.Object.(*v3.UserAttribute); ok {
+		return decoded.DeepCopy(), nil
+	}
+	object := &v3.UserAttribute{}
+	if err := json.Unmarshal(raw.Raw, object); err != nil {
+		return nil, err
+	}
+	raw.Object = object.DeepCopy()
+	return object, nil
+}
@@ -0,0 +1,125 @@
+package v1
+
+import (
+	"encoding/json"
+	"fmt"
+	"testing"
+
+	v1 "github.com/rancher/rancher/pkg/apis/provisioning.cattle.io/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	admissionv1 "k8s.io/api/admission/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+// admittersPerRequest is roughly the number of times the admitters of provisioning clusters decode a request.
+const admittersPerRequest = 5
+
+func TestClusterOldAndNewFromRequestDecodesOnce(t *testing.T) {
+	t.Parallel()
+	request := newClusterUpdateRequest(t, 3)
+
+	oldCluster, newCluster, err := ClusterOldAndNewFromRequest(request)
+	require.NoError(t, err)
+	require.IsType(t, &v1.Cluster{}, request.Object.Object)
+	require.IsType(t, &v1.Cluster{}, request.OldObject.Object)
+	assert.Equal(t, "2", oldCluster.ResourceVersion)
+	assert.Equal(t, "3", newCluster.ResourceVersion)
+
+	// changes of one admitter aren't seen by the next ones
+	newCluster.Spec.RKEConfig.MachinePools = nil
+	oldCluster.Labels = nil
+	oldAgain, newAgain, err := ClusterOldAndNewFromRequest(request)
+	require.NoError(t, err)
+	assert.Len(t, newAgain.Spec.RKEConfig.MachinePools, 3)
+	assert.NotEmpty(t, oldAgain.Labels)
+
+	cluster, err := ClusterFromRequest(request)
+	require.NoError(t, err)
+	assert.Equal(t, newAgain, cluster)
+	assert.NotSame(t, newAgain, cluster)
+}
+
+func TestClusterFromRequestInvalidPayload(t *testing.T) {
+	t.Parallel()
+	request := &admissionv1.AdmissionRequest{
+		Operation: admissionv1.Create,
+		Object:    runtime.RawExtension{Raw: []byte("{")},
+	}
+	_, err := ClusterFromRequest(request)
+	require.Error(t, err)
+	assert.Nil(t, request.Object.Object, "invalid payloads aren't kept")
+}
+
+// BenchmarkClusterOldAndNewFromRequest compares decoding the payloads of a request once per admitter with decoding
+// them once per request.
+func BenchmarkClusterOldAndNewFromRequest(b *testing.B) {
+	for _, pools := range []int{1, 50} {
+		request := newClusterUpdateRequest(b, pools)
+		b.Run(fmt.Sprintf("pools=%d/uncached", pools), func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				for j := 0; j < admittersPerRequest; j++ {
+					request.Object.Object = nil
+					request.OldObject.Object = nil
+					if _, _, err := ClusterOldAndNewFromRequest(request); err != nil {
+						b.Fatal(err)
+					}
+				}
+			}
+		})
+		b.Run(fmt.Sprintf("pools=%d/cached", pools), func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				request.Object.Object = nil
+				request.OldObject.Object = nil
+				for j := 0; j < admittersPerRequest; j++ {
+					if _, _, err := ClusterOldAndNewFromRequest(request); err != nil {
+						b.Fatal(err)
+					}
+				}
+			}
+		})
+	}
+}
+
+func newClusterUpdateRequest(tb testing.TB, pools int) *admissionv1.AdmissionRequest {
+	tb.Helper()
+	cluster := &v1.Cluster{
+		TypeMeta: metav1.TypeMeta{APIVersion: "provisioning.cattle.io/v1", Kind: "Cluster"},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:            "test",
+			Namespace:       "fleet-default",
+			ResourceVersion: "2",
+			Labels:          map[string]string{"env": "test"},
+		},
+		Spec: v1.ClusterSpec{
+			KubernetesVersion: "v1.30.5+rke2r1",
+			RKEConfig:         &v1.RKEConfig{},
+		},
+	}
+	for i := 0; i < pools; i++ {
+		pool := v1.RKEMachinePool{
+			Name:       fmt.Sprintf("pool%d", i),
+			WorkerRole: true,
+			NodeConfig: &corev1.ObjectReference{Kind: "Amazonec2Config", Name: fmt.Sprintf("nc-test-pool%d", i)},
+		}
+		pool.Labels = map[string]string{}
+		for j := 0; j < 20; j++ {
+			pool.Labels[fmt.Sprintf("example.com/label-%d", j)] = fmt.Sprintf("value-%d", j)
+		}
+		cluster.Spec.RKEConfig.MachinePools = append(cluster.Spec.RKEConfig.MachinePools, pool)
+	}
+	oldRaw, err := json.Marshal(cluster)
+	require.NoError(tb, err)
+	cluster.ResourceVersion = "3"
+	newRaw, err := json.Marshal(cluster)
+	require.NoError(tb, err)
+	return &admissionv1.AdmissionRequest{
+		Operation: admissionv1.Update,
+		Object:    runtime.RawExtension{Raw: newRaw},
+		OldObject: runtime.RawExtension{Raw: oldRaw},
+	}
+}
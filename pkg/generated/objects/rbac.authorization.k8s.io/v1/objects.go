@@ -6,6 +6,7 @@ import (
 
 	admissionv1 "k8s.io/api/admission/v1"
 	"k8s.io/api/rbac/v1"
+	"k8s.io/apimachinery/pkg/runtime"
 )
 
 // RoleOldAndNewFromRequest gets the old and new Role objects, respectively, from the webhook request.
@@ -20,7 +21,8 @@ func RoleOldAndNewFromRequest(request *admissionv1.AdmissionRequest) (*v1.Role,
 	oldObject := &v1.Role{}
 
 	if request.Operation != admissionv1.Delete {
-		err := json.Unmarshal(request.Object.Raw, object)
+		var err error
+		object, err = decodeRole(&request.Object)
 		if err != nil {
 			return nil, nil, fmt.Errorf("failed to unmarshal request object: %w", err)
 		}
@@ -30,7 +32,7 @@ func RoleOldAndNewFromRequest(request *admissionv1.AdmissionRequest) (*v1.Role,
 		return oldObject, object, nil
 	}
 
-	err := json.Unmarshal(request.OldObject.Raw, oldObject)
+	oldObject, err := decodeRole(&request.OldObject)
 	if err != nil {
 		return nil, nil, fmt.Errorf("failed to unmarshal request oldObject: %w", err)
 	}
@@ -46,14 +48,13 @@ func RoleFromRequest(request *admissionv1.AdmissionRequest) (*v1.Role, error) {
 		return nil, fmt.Errorf("nil request")
 	}
 
-	object := &v1.Role{}
-	raw := request.Object.Raw
+	raw := &request.Object
 
 	if request.Operation == admissionv1.Delete {
-		raw = request.OldObject.Raw
+		raw = &request.OldObject
 	}
 
-	err := json.Unmarshal(raw, object)
+	object, err := decodeRole(raw)
 	if err != nil {
 		return nil, fmt.Errorf("failed to unmarshal request object: %w", err)
 	}
@@ -61,6 +62,21 @@ func RoleFromRequest(request *admissionv1.AdmissionRequest) (*v1.Role, error) {
 	return object, nil
 }
 
+// decodeRole returns the Role object of the raw extension. The decoded object is kept in the extension,
+// so that the admitters of a request only decode its payload once, and a copy of it is returned so that admitters
+// can't alter the objects seen by the others.
+func decodeRole(raw *runtime.RawExtension) (*v1.Role, error) {
+	if decoded, ok := raw.Object.(*v1.Role); ok {
+		return decoded.DeepCopy(), nil
+	}
+	object := &v1.Role{}
+	if err := json.Unmarshal(raw.Raw, object); err != nil {
+		return nil, err
+	}
+	raw.Object = object.DeepCopy()
+	return object, nil
+}
+
 // RoleBindingOldAndNewFromRequest gets the old and new RoleBinding objects, respectively, from the webhook request.
 // If the request is a Delete operation, then the new object is the zero value for RoleBinding.
 // Similarly, if the request is a Create operation, then the old object is the zero value for RoleBinding.
@@ -73,7 +89,8 @@ func RoleBindingOldAndNewFromRequest(request *admissionv1.AdmissionRequest) (*v1
 	oldObject := &v1.RoleBinding{}
 
 	if request.Operation != admissionv1.Delete {
-		err := json.Unmarshal(request.Object.Raw, object)
+		var err error
+		object, err = decodeRoleBinding(&request.Object)
 		if err != nil {
 			return nil, nil, fmt.Errorf("failed to unmarshal request object: %w", err)
 		}
@@ -83,7 +100,7 @@ func RoleBindingOldAndNewFromRequest(request *admissionv1.AdmissionRequest) (*v1
 		return oldObject, object, nil
 	}
 
-	err := json.Unmarshal(request.OldObject.Raw, oldObject)
+	oldObject, err := decodeRoleBinding(&request.OldObject)
 	if err != nil {
 		return nil, nil, fmt.Errorf("failed to unmarshal request oldObject: %w", err)
 	}
@@ -99,14 +116,13 @@ func RoleBindingFromRequest(request *admissionv1.AdmissionRequest) (*v1.RoleBind
 		return nil, fmt.Errorf("nil request")
 	}
 
-	object := &v1.RoleBinding{}
-	raw := request.Object.Raw
+	raw := &request.Object
 
 	if request.Operation == admissionv1.Delete {
-		raw = request.OldObject.Raw
+		raw = &request.OldObject
 	}
 
-	err := json.Unmarshal(raw, object)
+	object, err := decodeRoleBinding(raw)
 	if err != nil {
 		return nil, fmt.Errorf("failed to unmarshal request object: %w", err)
 	}
@@ -114,6 +130,21 @@ func RoleBindingFromRequest(request *admissionv1.AdmissionRequest) (*v1.RoleBind
 	return object, nil
 }
 
+// decodeRoleBinding returns the RoleBinding object of the raw extension. The decoded object is kept in the extension,
+// so that the admitters of a request only decode its payload once, and a copy of it is returned so that admitters
+// can't alter the objects seen by the others.
+func decodeRoleBinding(raw *runtime.RawExtension) (*v1.RoleBinding, error) {
+	if decoded, ok := raw.Object.(*v1.RoleBinding); ok {
+		return decoded.DeepCopy(), nil
+	}
+	object := &v1.RoleBinding{}
+	if err := json.Unmarshal(raw.Raw, object); err != nil {
+		return nil, err
+	}
+	raw.Object = object.DeepCopy()
+	return object, nil
+}
+
 // ClusterRoleOldAndNewFromRequest gets the old and new ClusterRole objects, respectively, from the webhook request.
 // If the request is a Delete operation, then the new object is the zero value for ClusterRole.
 // Similarly, if the request is a Create operation, then the old object is the zero value for ClusterRole.
@@ -126,7 +157,8 @@ func ClusterRoleOldAndNewFromRequest(request *admissionv1.AdmissionRequest) (*v1
 	oldObject := &v1.ClusterRole{}
 
 	if request.Operation != admissionv1.Delete {
-		err := json.Unmarshal(request.Object.Raw, object)
+		var err error
+		object, err = decodeClusterRole(&request.Object)
 		if err != nil {
 			return nil, nil, fmt.Errorf("failed to unmarshal request object: %w", err)
 		}
@@ -136,7 +168,7 @@ func ClusterRoleOldAndNewFromRequest(request *admissionv1.AdmissionRequest) (*v1
 		return oldObject, object, nil
 	}
 
-	err := json.Unmarshal(request.OldObject.Raw, oldObject)
+	oldObject, err := decodeClusterRole(&request.OldObject)
 	if err != nil {
 		return nil, nil, fmt.Errorf("failed to unmarshal request oldObject: %w", err)
 	}
@@ -152,14 +184,13 @@ func ClusterRoleFromRequest(request *admissionv1.AdmissionRequest) (*v1.ClusterR
 		return nil, fmt.Errorf("nil request")
 	}
 
-	object := &v1.ClusterRole{}
-	raw := request.Object.Raw
+	raw := &request.Object
 
 	if request.Operation == admissionv1.Delete {
-		raw = request.OldObject.Raw
+		raw = &request.OldObject
 	}
 
-	err := json.Unmarshal(raw, object)
+	object, err := decodeClusterRole(raw)
 	if err != nil {
 		return nil, fmt.Errorf("failed to unmarshal request object: %w", err)
 	}
@@ -167,6 +198,21 @@ func ClusterRoleFromRequest(request *admissionv1.AdmissionRequest) (*v1.ClusterR
 	return object, nil
 }
 
+// decodeClusterRole returns the ClusterRole object of the raw extension. The decoded object is kept in the extension,
+// so that the admitters of a request only decode its payload once, and a copy of it is returned so that admitters
+// can't alter the objects seen by the others.
+func decodeClusterRole(raw *runtime.RawExtension) (*v1.ClusterRole, error) {
+	if decoded, ok := raw.Object.(*v1.ClusterRole); ok {
+		return decoded.DeepCopy(), nil
+	}
+	object := &v1.ClusterRole{}
+	if err := json.Unmarshal(raw.Raw, object); err != nil {
+		return nil, err
+	}
+	raw.Object = object.DeepCopy()
+	return object, nil
+}
+
 // ClusterRoleBindingOldAndNewFromRequest gets the old and new ClusterRoleBinding objects, respectively, from the webhook request.
 // If the request is a Delete operation, then the new object is the zero value for ClusterRoleBinding.
 // Similarly, if the request is a Create operation, then the old object is the zero value for ClusterRoleBinding.
@@ -179,7 +225,8 @@ func ClusterRoleBindingOldAndNewFromRequest(request *admissionv1.AdmissionReques
 	oldObject := &v1.ClusterRoleBinding{}
 
 	if request.Operation != admissionv1.Delete {
-		err := json.Unmarshal(request.Object.Raw, object)
+		var err error
+		object, err = decodeClusterRoleBinding(&request.Object)
 		if err != nil {
 			return nil, nil, fmt.Errorf("failed to unmarshal request object: %w", err)
 		}
@@ -189,7 +236,7 @@ func ClusterRoleBindingOldAndNewFromRequest(request *admissionv1.AdmissionReques
 		return oldObject, object, nil
 	}
 
-	err := json.Unmarshal(request.OldObject.Raw, oldObject)
+	oldObject, err := decodeClusterRoleBinding(&request.OldObject)
 	if err != nil {
 		return nil, nil, fmt.Errorf("failed to unmarshal request oldObject: %w", err)
 	}
@@ -205,17 +252,31 @@ func ClusterRoleBindingFromRequest(request *admissionv1.AdmissionRequest) (*v1.C
 		return nil, fmt.Errorf("nil request")
 	}
 
-	object := &v1.ClusterRoleBinding{}
-	raw := request.Object.Raw
+	raw := &request.Object
 
 	if request.Operation == admissionv1.Delete {
-		raw = request.OldObject.Raw
+		raw = &request.OldObject
 	}
 
-	err := json.Unmarshal(raw, object)
+	object, err := decodeClusterRoleBinding(raw)
 	if err != nil {
 		return nil, fmt.Errorf("failed to unmarshal request object: %w", err)
 	}
 
 	return object, nil
 }
+
+// decodeClusterRoleBinding returns the ClusterRoleBinding object of the raw extension. The decoded object is kept in the extension,
+// so that the admitters of a request only decode its payload once, and a copy of it is returned so that admitters
+// can't alter the objects seen by the others.
+func decodeClusterRoleBinding(raw *runtime.RawExtension) (*v1.ClusterRoleBinding, error) {
+	if decoded, ok := raw.Object.(*v1.ClusterRoleBinding); ok {
+		return decoded.DeepCopy(), nil
+	}
+	object := &v1.ClusterRoleBinding{}
+	if err := json.Unmarshal(raw.Raw, object); err != nil {
+		return nil, err
+	}
+	raw.Object = object.DeepCopy()
+	return object, nil
+}
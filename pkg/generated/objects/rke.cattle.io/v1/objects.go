@@ -0,0 +1,78 @@
+package v1
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/rancher/rancher/pkg/apis/rke.cattle.io/v1"
+	admissionv1 "k8s.io/api/admission/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+// ETCDSnapshotOldAndNewFromRequest gets the old and new ETCDSnapshot objects, respectively, from the webhook request.
+// If the request is a Delete operation, then the new object is the zero value for ETCDSnapshot.
+// Similarly, if the request is a Create operation, then the old object is the zero value for ETCDSnapshot.
+func ETCDSnapshotOldAndNewFromRequest(request *admissionv1.AdmissionRequest) (*v1.ETCDSnapshot, *v1.ETCDSnapshot, error) {
+	if request == nil {
+		return nil, nil, fmt.Errorf("nil request")
+	}
+
+	object := &v1.ETCDSnapshot{}
+	oldObject := &v1.ETCDSnapshot{}
+
+	if request.Operation != admissionv1.Delete {
+		var err error
+		object, err = decodeETCDSnapshot(&request.Object)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to unmarshal request object: %w", err)
+		}
+	}
+
+	if request.Operation == admissionv1.Create {
+		return oldObject, object, nil
+	}
+
+	oldObject, err := decodeETCDSnapshot(&request.OldObject)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to unmarshal request oldObject: %w", err)
+	}
+
+	return oldObject, object, nil
+}
+
+// ETCDSnapshotFromRequest returns a ETCDSnapshot object from the webhook request.
+// If the operation is a Delete operation, then the old object is returned.
+// Otherwise, the new object is returned.
+func ETCDSnapshotFromRequest(request *admissionv1.AdmissionRequest) (*v1.ETCDSnapshot, error) {
+	if request == nil {
+		return nil, fmt.Errorf("nil request")
+	}
+
+	raw := &request.Object
+
+	if request.Operation == admissionv1.Delete {
+		raw = &request.OldObject
+	}
+
+	object, err := decodeETCDSnapshot(raw)
+	if err != nil {
+		return nil, fmt.Errorf("failed to unmarshal request object: %w", err)
+	}
+
+	return object, nil
+}
+
+// decodeETCDSnapshot returns the ETCDSnapshot object of the raw extension. The decoded object is kept in the extension,
+// so that the admitters of a request only decode its payload once, and a copy of it is returned so that admitters
+// can't alter the objects seen by the others.
+func decodeETCDSnapshot(raw *runtime.RawExtension) (*v1.ETCDSnapshot, error) {
+	if decoded, ok := raw.Object.(*v1.ETCDSnapshot); ok {
+		return decoded.DeepCopy(), nil
+	}
+	object := &v1.ETCDSnapshot{}
+	if err := json.Unmarshal(raw.Raw, object); err != nil {
+		return nil, err
+	}
+	raw.Object = object.DeepCopy()
+	return object, nil
+}
@@ -0,0 +1,92 @@
+package health
+
+import (
+	"context"
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/rancher/webhook/pkg/metrics"
+	corecontrollers "github.com/rancher/wrangler/v3/pkg/generated/controllers/core/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/client-go/rest"
+)
+
+const apiServerCheckTimeout = 5 * time.Second
+
+// CertificateChecker is a HealthChecker that fails if the webhook's serving certificate can not be read or has expired.
+// Each check records the number of days until the certificate expires in metrics.TLSCertificateExpiryDays.
+type CertificateChecker struct {
+	secrets   corecontrollers.SecretCache
+	namespace string
+	name      string
+	now       func() time.Time
+}
+
+// NewCertificateChecker returns a new CertificateChecker for the TLS secret with the given namespace and name.
+func NewCertificateChecker(secrets corecontrollers.SecretCache, namespace, name string) *CertificateChecker {
+	return &CertificateChecker{
+		secrets:   secrets,
+		namespace: namespace,
+		name:      name,
+		now:       time.Now,
+	}
+}
+
+// Name returns the Name of the checker.
+func (c *CertificateChecker) Name() string { return "TLS Certificate" }
+
+// Check returns an error if the certificate can not be read or has expired.
+func (c *CertificateChecker) Check(_ *http.Request) error {
+	days, err := c.DaysUntilExpiry()
+	if err != nil {
+		return err
+	}
+	metrics.TLSCertificateExpiryDays.Set(days)
+	if days <= 0 {
+		return fmt.Errorf("certificate in secret %s/%s has expired", c.namespace, c.name)
+	}
+	return nil
+}
+
+// DaysUntilExpiry returns the number of days until the certificate expires.
+func (c *CertificateChecker) DaysUntilExpiry() (float64, error) {
+	secret, err := c.secrets.Get(c.namespace, c.name)
+	if err != nil {
+		return 0, fmt.Errorf("failed to get secret %s/%s: %w", c.namespace, c.name, err)
+	}
+	block, _ := pem.Decode(secret.Data[corev1.TLSCertKey])
+	if block == nil {
+		return 0, fmt.Errorf("secret %s/%s does not contain a PEM encoded certificate", c.namespace, c.name)
+	}
+	cert, err := x509.ParseCertificate(block.Bytes)
+	if err != nil {
+		return 0, fmt.Errorf("failed to parse certificate in secret %s/%s: %w", c.namespace, c.name, err)
+	}
+	return cert.NotAfter.Sub(c.now()).Hours() / 24, nil
+}
+
+// APIServerChecker is a HealthChecker that fails if the Kubernetes API server can not be reached.
+type APIServerChecker struct {
+	client rest.Interface
+}
+
+// NewAPIServerChecker returns a new APIServerChecker which uses the given client to reach the API server.
+func NewAPIServerChecker(client rest.Interface) *APIServerChecker {
+	return &APIServerChecker{client: client}
+}
+
+// Name returns the Name of the checker.
+func (a *APIServerChecker) Name() string { return "API Server" }
+
+// Check returns an error if the API server's version endpoint can not be reached.
+func (a *APIServerChecker) Check(r *http.Request) error {
+	ctx, cancel := context.WithTimeout(r.Context(), apiServerCheckTimeout)
+	defer cancel()
+	if err := a.client.Get().AbsPath("/version").Do(ctx).Error(); err != nil {
+		return fmt.Errorf("failed to reach the API server: %w", err)
+	}
+	return nil
+}
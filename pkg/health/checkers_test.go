@@ -0,0 +1,152 @@
+package health
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/rancher/wrangler/v3/pkg/generic/fake"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+)
+
+const (
+	testNamespace = "cattle-system"
+	testName      = "cattle-webhook-tls"
+)
+
+func newCertPEM(t *testing.T, notAfter time.Time) []byte {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "rancher-webhook.cattle-system.svc"},
+		NotBefore:    notAfter.Add(-365 * 24 * time.Hour),
+		NotAfter:     notAfter,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	require.NoError(t, err)
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+}
+
+func TestCertificateChecker(t *testing.T) {
+	t.Parallel()
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name     string
+		secret   *corev1.Secret
+		getErr   error
+		wantDays float64
+		wantErr  bool
+	}{
+		{
+			name: "valid certificate",
+			secret: &corev1.Secret{Data: map[string][]byte{
+				corev1.TLSCertKey: newCertPEM(t, now.Add(30*24*time.Hour)),
+			}},
+			wantDays: 30,
+		},
+		{
+			name: "expired certificate",
+			secret: &corev1.Secret{Data: map[string][]byte{
+				corev1.TLSCertKey: newCertPEM(t, now.Add(-24*time.Hour)),
+			}},
+			wantDays: -1,
+			wantErr:  true,
+		},
+		{
+			name:    "missing secret",
+			getErr:  apierrors.NewNotFound(schema.GroupResource{Resource: "secrets"}, testName),
+			wantErr: true,
+		},
+		{
+			name:    "missing certificate",
+			secret:  &corev1.Secret{},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			secrets := fake.NewMockCacheInterface[*corev1.Secret](gomock.NewController(t))
+			secrets.EXPECT().Get(testNamespace, testName).Return(tt.secret, tt.getErr).AnyTimes()
+			checker := NewCertificateChecker(secrets, testNamespace, testName)
+			checker.now = func() time.Time { return now }
+
+			if tt.secret != nil && tt.wantDays != 0 {
+				days, err := checker.DaysUntilExpiry()
+				require.NoError(t, err)
+				assert.InDelta(t, tt.wantDays, days, 0.001)
+			}
+			err := checker.Check(nil)
+			if tt.wantErr {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestAPIServerChecker(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name    string
+		status  int
+		wantErr bool
+	}{
+		{name: "api server reachable", status: http.StatusOK},
+		{name: "api server unavailable", status: http.StatusServiceUnavailable, wantErr: true},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				assert.Equal(t, "/version", r.URL.Path)
+				w.Header().Set("Content-Type", "application/json")
+				w.WriteHeader(tt.status)
+				_, _ = w.Write([]byte(`{}`))
+			}))
+			defer server.Close()
+			client, err := kubernetes.NewForConfig(&rest.Config{Host: server.URL})
+			require.NoError(t, err)
+
+			checker := NewAPIServerChecker(client.Discovery().RESTClient())
+			err = checker.Check(httptest.NewRequest(http.MethodGet, "/readyz", nil))
+			if tt.wantErr {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestIsHealthPath(t *testing.T) {
+	t.Parallel()
+	assert.True(t, IsHealthPath("/healthz"))
+	assert.True(t, IsHealthPath("/healthz/ping"))
+	assert.True(t, IsHealthPath("/readyz"))
+	assert.True(t, IsHealthPath("/readyz/API Server"))
+	assert.False(t, IsHealthPath("/healthzz"))
+	assert.False(t, IsHealthPath("/metrics"))
+	assert.False(t, IsHealthPath("/v1/webhook/validation/globalroles.management.cattle.io"))
+}
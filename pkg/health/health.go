@@ -4,6 +4,7 @@ package health
 import (
 	"fmt"
 	"net/http"
+	"strings"
 	"sync"
 
 	"github.com/gorilla/mux"
@@ -17,6 +18,21 @@ func RegisterHealthCheckers(router *mux.Router, checkers ...healthz.HealthChecke
 	healthz.InstallHandler(&muxWrapper{router}, checkers...)
 }
 
+// RegisterReadinessCheckers adds the readyz endpoint to the webhook.
+func RegisterReadinessCheckers(router *mux.Router, checkers ...healthz.HealthChecker) {
+	healthz.InstallReadyzHandler(&muxWrapper{router}, checkers...)
+}
+
+// IsHealthPath returns true if path is served by the healthz or readyz endpoints.
+func IsHealthPath(path string) bool {
+	for _, prefix := range []string{"/healthz", "/readyz"} {
+		if path == prefix || strings.HasPrefix(path, prefix+"/") {
+			return true
+		}
+	}
+	return false
+}
+
 // NewErrorChecker returns a new error checker initialized with a "not ready" error
 func NewErrorChecker(name string) *ErrorChecker {
 	return &ErrorChecker{
@@ -0,0 +1,162 @@
+// Package metrics holds the prometheus metrics exposed by the webhook.
+package metrics
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+var (
+	// Registry is the prometheus registry that all webhook metrics are registered with.
+	Registry = prometheus.NewRegistry()
+
+	// AdmissionRequests counts the admission requests handled by the webhook, labeled by webhook type, the
+	// GroupVersionResource of the webhook, operation and result ("allowed", "denied" or "error").
+	AdmissionRequests = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: AdmissionRequestsTotalName,
+		Help: "Number of admission requests, partitioned by webhook, operation and result.",
+	}, []string{LabelWebhookType, LabelGroup, LabelVersion, LabelResource, LabelOperation, LabelResult})
+
+	// AdmissionRequestDuration is the time spent handling admission requests, labeled by webhook type, the
+	// GroupVersionResource of the webhook and operation.
+	AdmissionRequestDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    AdmissionRequestDurationSecondsName,
+		Help:    "Time spent handling admission requests in seconds, partitioned by webhook and operation.",
+		Buckets: []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10},
+	}, []string{LabelWebhookType, LabelGroup, LabelVersion, LabelResource, LabelOperation})
+
+	// AdmissionRequestsLimited counts the admission requests rejected before being handled, labeled by reason ("size"
+	// or "rate").
+	AdmissionRequestsLimited = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: AdmissionRequestsLimitedTotalName,
+		Help: "Number of admission requests rejected for exceeding the size or rate limits, partitioned by reason.",
+	}, []string{LabelReason})
+
+	// SARCacheRequests counts SubjectAccessReview cache lookups, labeled by result ("hit" or "miss").
+	SARCacheRequests = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: SARCacheRequestsTotalName,
+		Help: "Number of SubjectAccessReview cache lookups, partitioned by result.",
+	}, []string{LabelResult})
+
+	// SARCacheEvictions counts SubjectAccessReview cache entries removed before they expired, labeled by reason
+	// ("size" or "invalidated").
+	SARCacheEvictions = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: SARCacheEvictionsTotalName,
+		Help: "Number of SubjectAccessReview cache entries evicted, partitioned by reason.",
+	}, []string{LabelReason})
+
+	// SARCacheEntries is the number of entries currently held by the SubjectAccessReview cache.
+	SARCacheEntries = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: SARCacheEntriesName,
+		Help: "Number of entries currently held by the SubjectAccessReview cache.",
+	})
+
+	// RoleTemplateResolutionCacheRequests counts lookups of the memoized rules of RoleTemplates, labeled by result
+	// ("hit" or "miss").
+	RoleTemplateResolutionCacheRequests = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: RoleTemplateResolutionCacheRequestsTotalName,
+		Help: "Number of RoleTemplate rule resolution cache lookups, partitioned by result.",
+	}, []string{LabelResult})
+
+	// TLSCertificateExpiryDays is the number of days until the webhook's serving certificate expires, as of the
+	// last health check.
+	TLSCertificateExpiryDays = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: TLSCertificateExpiryDaysName,
+		Help: "Number of days until the webhook's serving certificate expires.",
+	})
+
+	// ExternalPolicyEvaluations counts evaluations of external CEL policies, labeled by policy name and result
+	// ("allowed", "denied" or "error").
+	ExternalPolicyEvaluations = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: ExternalPolicyEvaluationsTotalName,
+		Help: "Number of external policy evaluations, partitioned by policy and result.",
+	}, []string{LabelPolicy, LabelResult})
+
+	// ExternalPolicies is the number of loaded external CEL policies, labeled by state ("active" or "invalid").
+	ExternalPolicies = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: ExternalPoliciesName,
+		Help: "Number of loaded external policies, partitioned by state.",
+	}, []string{LabelState})
+
+	// InformerCacheBytes is an estimate of the memory held by the informer caches, labeled by resource. The estimate is
+	// the size of the JSON encoding of the cached objects after they were stripped of the fields no admitter reads.
+	InformerCacheBytes = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: InformerCacheEstimatedBytesName,
+		Help: "Estimated size in bytes of the objects held by the informer caches, partitioned by resource.",
+	}, []string{LabelResource})
+
+	// ShadowRequests counts the admission requests mirrored to the shadow webhook, labeled by webhook type, the
+	// GroupVersionResource of the request and result ("match", "diverged", "error" or "dropped").
+	ShadowRequests = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: ShadowRequestsTotalName,
+		Help: "Number of admission requests mirrored to the shadow webhook, partitioned by webhook and result.",
+	}, []string{LabelWebhookType, LabelGroup, LabelVersion, LabelResource, LabelResult})
+
+	// AuditRecords counts the audit records of denied admission requests, labeled by sink and result ("written",
+	// "error" or "dropped").
+	AuditRecords = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: AuditRecordsTotalName,
+		Help: "Number of audit records of denied admission requests, partitioned by sink and result.",
+	}, []string{LabelSink, LabelResult})
+
+	// BypassedRequests counts the admission requests which a validator denied but were allowed because the user is a
+	// member of the bypass group, labeled by the GroupVersionResource and operation of the request.
+	BypassedRequests = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: BypassedRequestsTotalName,
+		Help: "Number of denied admission requests allowed for members of the bypass group, partitioned by resource and operation.",
+	}, []string{LabelGroup, LabelVersion, LabelResource, LabelOperation})
+
+	// ClientCertRejections counts the requests rejected because the client certificate couldn't be verified, labeled
+	// by reason ("missing", "invalid" or "common_name").
+	ClientCertRejections = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: ClientCertRejectionsTotalName,
+		Help: "Number of requests rejected for lacking a valid client certificate, partitioned by reason.",
+	}, []string{LabelReason})
+
+	// AdmissionRequestsUnsynced counts the admission requests received before the webhook's caches synced, labeled by
+	// result ("queued", "timed_out" or "allowed").
+	AdmissionRequestsUnsynced = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: AdmissionRequestsUnsyncedTotalName,
+		Help: "Number of admission requests received before the caches synced, partitioned by result.",
+	}, []string{LabelResult})
+)
+
+func init() {
+	Registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		AdmissionRequests,
+		AdmissionRequestDuration,
+		AdmissionRequestsLimited,
+		SARCacheRequests,
+		SARCacheEvictions,
+		SARCacheEntries,
+		RoleTemplateResolutionCacheRequests,
+		TLSCertificateExpiryDays,
+		ExternalPolicyEvaluations,
+		ExternalPolicies,
+		InformerCacheBytes,
+		ShadowRequests,
+		AuditRecords,
+		BypassedRequests,
+		ClientCertRejections,
+		AdmissionRequestsUnsynced,
+	)
+}
+
+// Handler returns an http.Handler which serves the metrics in Registry.
+func Handler() http.Handler {
+	return promhttp.HandlerFor(Registry, promhttp.HandlerOpts{})
+}
+
+// ObserveAdmissionRequest records the result and duration of an admission request handled by the webhook of the given
+// type for the given GroupVersionResource.
+func ObserveAdmissionRequest(webhookType string, gvr schema.GroupVersionResource, operation string, result string, start time.Time) {
+	AdmissionRequests.WithLabelValues(webhookType, gvr.Group, gvr.Version, gvr.Resource, operation, result).Inc()
+	AdmissionRequestDuration.WithLabelValues(webhookType, gvr.Group, gvr.Version, gvr.Resource, operation).Observe(time.Since(start).Seconds())
+}
@@ -0,0 +1,101 @@
+package metrics_test
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/rancher/webhook/pkg/metrics"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"sigs.k8s.io/yaml"
+)
+
+var globalRoles = schema.GroupVersionResource{Group: "management.cattle.io", Version: "v3", Resource: "globalroles"}
+
+// TestNamingContract guards the names and labels that dashboards and alerts rely on.
+func TestNamingContract(t *testing.T) {
+	metrics.ObserveAdmissionRequest(metrics.WebhookTypeValidating, globalRoles, "CREATE", metrics.ResultAllowed, time.Now())
+	metrics.AdmissionRequestsLimited.WithLabelValues(metrics.LimitReasonRate)
+	metrics.SARCacheRequests.WithLabelValues("hit")
+	metrics.SARCacheEvictions.WithLabelValues("size")
+	metrics.RoleTemplateResolutionCacheRequests.WithLabelValues("hit")
+	metrics.ExternalPolicyEvaluations.WithLabelValues("test", metrics.ResultAllowed)
+	metrics.ExternalPolicies.WithLabelValues("active")
+	metrics.InformerCacheBytes.WithLabelValues("secrets")
+	metrics.ShadowRequests.WithLabelValues(metrics.WebhookTypeValidating, "management.cattle.io", "v3", "globalroles", metrics.ShadowResultMatch)
+	metrics.AuditRecords.WithLabelValues(metrics.AuditSinkFile, metrics.AuditResultWritten)
+	metrics.BypassedRequests.WithLabelValues("management.cattle.io", "v3", "settings", "UPDATE")
+	metrics.ClientCertRejections.WithLabelValues(metrics.ClientCertReasonMissing)
+	metrics.AdmissionRequestsUnsynced.WithLabelValues(metrics.UnsyncedResultQueued)
+
+	families, err := metrics.Registry.Gather()
+	require.NoError(t, err)
+	labels := map[string][]string{}
+	for _, family := range families {
+		if !strings.HasPrefix(family.GetName(), "rancher_webhook_") {
+			continue
+		}
+		labels[family.GetName()] = nil
+		for _, label := range family.GetMetric()[0].GetLabel() {
+			labels[family.GetName()] = append(labels[family.GetName()], label.GetName())
+		}
+	}
+
+	assert.Equal(t, map[string][]string{
+		"rancher_webhook_admission_requests_total":                     {"group", "operation", "resource", "result", "version", "webhook_type"},
+		"rancher_webhook_admission_request_duration_seconds":           {"group", "operation", "resource", "version", "webhook_type"},
+		"rancher_webhook_admission_requests_limited_total":             {"reason"},
+		"rancher_webhook_sar_cache_requests_total":                     {"result"},
+		"rancher_webhook_sar_cache_evictions_total":                    {"reason"},
+		"rancher_webhook_sar_cache_entries":                            nil,
+		"rancher_webhook_roletemplate_resolution_cache_requests_total": {"result"},
+		"rancher_webhook_tls_certificate_expiry_days":                  nil,
+		"rancher_webhook_external_policy_evaluations_total":            {"policy", "result"},
+		"rancher_webhook_external_policy_policies":                     {"state"},
+		"rancher_webhook_informer_cache_estimated_bytes":               {"resource"},
+		"rancher_webhook_shadow_requests_total":                        {"group", "resource", "result", "version", "webhook_type"},
+		"rancher_webhook_audit_records_total":                          {"result", "sink"},
+		"rancher_webhook_bypassed_requests_total":                      {"group", "operation", "resource", "version"},
+		"rancher_webhook_admission_requests_unsynced_total":            {"result"},
+		"rancher_webhook_client_cert_rejections_total":                 {"reason"},
+	}, labels)
+}
+
+func TestObserveAdmissionRequest(t *testing.T) {
+	pods := schema.GroupVersionResource{Version: "v1", Resource: "pods"}
+	counter := metrics.AdmissionRequests.WithLabelValues(metrics.WebhookTypeMutating, "", "v1", "pods", "UPDATE", metrics.ResultDenied)
+	before := testutil.ToFloat64(counter)
+
+	metrics.ObserveAdmissionRequest(metrics.WebhookTypeMutating, pods, "UPDATE", metrics.ResultDenied, time.Now())
+
+	assert.Equal(t, before+1, testutil.ToFloat64(counter))
+}
+
+func TestPrometheusRules(t *testing.T) {
+	t.Parallel()
+	rules := metrics.PrometheusRules([]metrics.Webhook{
+		{Type: metrics.WebhookTypeValidating, GVR: globalRoles},
+		{Type: metrics.WebhookTypeMutating, GVR: schema.GroupVersionResource{Version: "v1", Resource: "secrets"}},
+	})
+	require.Len(t, rules.Groups, 2)
+	recording, alerting := rules.Groups[0], rules.Groups[1]
+	require.Len(t, recording.Rules, 4)
+	require.Len(t, alerting.Rules, 4)
+
+	// Webhooks are sorted by type, so the mutating webhook comes first.
+	assert.Equal(t, metrics.AdmissionRequestDurationP99Record, recording.Rules[0].Record)
+	assert.Equal(t, `histogram_quantile(0.99, sum by (le) (rate(rancher_webhook_admission_request_duration_seconds_bucket{group="",resource="secrets",version="v1",webhook_type="mutating"}[5m])))`, recording.Rules[0].Expr)
+	assert.Equal(t, map[string]string{"webhook_type": "mutating", "group": "", "version": "v1", "resource": "secrets"}, recording.Rules[0].Labels)
+
+	assert.Equal(t, metrics.AdmissionRequestDenialRatioRecord, recording.Rules[3].Record)
+	assert.Equal(t, `sum(rate(rancher_webhook_admission_requests_total{group="management.cattle.io",resource="globalroles",version="v3",webhook_type="validating",result="denied"}[5m])) / sum(rate(rancher_webhook_admission_requests_total{group="management.cattle.io",resource="globalroles",version="v3",webhook_type="validating"}[5m]))`, recording.Rules[3].Expr)
+
+	assert.Equal(t, "RancherWebhookHighDenialRate", alerting.Rules[3].Alert)
+	assert.Equal(t, `rancher_webhook:admission_requests_denied:ratio_rate5m{group="management.cattle.io",resource="globalroles",version="v3",webhook_type="validating"} > 0.5`, alerting.Rules[3].Expr)
+
+	_, err := yaml.Marshal(rules)
+	require.NoError(t, err)
+}
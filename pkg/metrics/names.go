@@ -0,0 +1,125 @@
+package metrics
+
+// The names and labels below are the metrics naming contract of the webhook: dashboards, recording rules and alerts
+// rely on them. A metric or label must never be renamed or removed; add a new one and deprecate the old one instead.
+// Metrics are labeled with the GroupVersionResource of the webhook rather than the name of its handler so that
+// renaming or splitting handlers does not change the series.
+
+// Metric names.
+const (
+	// AdmissionRequestsTotalName is the name of the AdmissionRequests metric.
+	AdmissionRequestsTotalName = "rancher_webhook_admission_requests_total"
+	// AdmissionRequestDurationSecondsName is the name of the AdmissionRequestDuration metric.
+	AdmissionRequestDurationSecondsName = "rancher_webhook_admission_request_duration_seconds"
+	// AdmissionRequestsLimitedTotalName is the name of the AdmissionRequestsLimited metric.
+	AdmissionRequestsLimitedTotalName = "rancher_webhook_admission_requests_limited_total"
+	// SARCacheRequestsTotalName is the name of the SARCacheRequests metric.
+	SARCacheRequestsTotalName = "rancher_webhook_sar_cache_requests_total"
+	// SARCacheEvictionsTotalName is the name of the SARCacheEvictions metric.
+	SARCacheEvictionsTotalName = "rancher_webhook_sar_cache_evictions_total"
+	// SARCacheEntriesName is the name of the SARCacheEntries metric.
+	SARCacheEntriesName = "rancher_webhook_sar_cache_entries"
+	// RoleTemplateResolutionCacheRequestsTotalName is the name of the RoleTemplateResolutionCacheRequests metric.
+	RoleTemplateResolutionCacheRequestsTotalName = "rancher_webhook_roletemplate_resolution_cache_requests_total"
+	// TLSCertificateExpiryDaysName is the name of the TLSCertificateExpiryDays metric.
+	TLSCertificateExpiryDaysName = "rancher_webhook_tls_certificate_expiry_days"
+	// ExternalPolicyEvaluationsTotalName is the name of the ExternalPolicyEvaluations metric.
+	ExternalPolicyEvaluationsTotalName = "rancher_webhook_external_policy_evaluations_total"
+	// ExternalPoliciesName is the name of the ExternalPolicies metric.
+	ExternalPoliciesName = "rancher_webhook_external_policy_policies"
+	// InformerCacheEstimatedBytesName is the name of the InformerCacheBytes metric.
+	InformerCacheEstimatedBytesName = "rancher_webhook_informer_cache_estimated_bytes"
+	// ShadowRequestsTotalName is the name of the ShadowRequests metric.
+	ShadowRequestsTotalName = "rancher_webhook_shadow_requests_total"
+	// AuditRecordsTotalName is the name of the AuditRecords metric.
+	AuditRecordsTotalName = "rancher_webhook_audit_records_total"
+	// BypassedRequestsTotalName is the name of the BypassedRequests metric.
+	BypassedRequestsTotalName = "rancher_webhook_bypassed_requests_total"
+	// ClientCertRejectionsTotalName is the name of the ClientCertRejections metric.
+	ClientCertRejectionsTotalName = "rancher_webhook_client_cert_rejections_total"
+	// AdmissionRequestsUnsyncedTotalName is the name of the AdmissionRequestsUnsynced metric.
+	AdmissionRequestsUnsyncedTotalName = "rancher_webhook_admission_requests_unsynced_total"
+)
+
+// Label names.
+const (
+	// LabelWebhookType is the type of the webhook: WebhookTypeValidating or WebhookTypeMutating.
+	LabelWebhookType = "webhook_type"
+	// LabelGroup is the API group of the resource reviewed by the webhook.
+	LabelGroup = "group"
+	// LabelVersion is the API version of the resource reviewed by the webhook.
+	LabelVersion = "version"
+	// LabelResource is the resource reviewed by the webhook.
+	LabelResource = "resource"
+	// LabelOperation is the operation of the admission request, such as "CREATE".
+	LabelOperation = "operation"
+	// LabelResult is the outcome of an operation. The possible values depend on the metric.
+	LabelResult = "result"
+	// LabelReason is the reason of an event, such as a cache eviction.
+	LabelReason = "reason"
+	// LabelPolicy is the name of an external policy.
+	LabelPolicy = "policy"
+	// LabelState is the state of an object, such as an external policy.
+	LabelState = "state"
+	// LabelSink is the sink an audit record is written to: AuditSinkFile or AuditSinkWebhook.
+	LabelSink = "sink"
+)
+
+// Label values.
+const (
+	// WebhookTypeValidating is the LabelWebhookType of validating webhooks.
+	WebhookTypeValidating = "validating"
+	// WebhookTypeMutating is the LabelWebhookType of mutating webhooks.
+	WebhookTypeMutating = "mutating"
+
+	// ResultAllowed is the LabelResult of admission requests and policy evaluations which were allowed.
+	ResultAllowed = "allowed"
+	// ResultDenied is the LabelResult of admission requests and policy evaluations which were denied.
+	ResultDenied = "denied"
+	// ResultError is the LabelResult of admission requests and policy evaluations which failed.
+	ResultError = "error"
+
+	// LimitReasonSize is the LabelReason of admission requests rejected for exceeding the maximum size.
+	LimitReasonSize = "size"
+	// LimitReasonRate is the LabelReason of admission requests rejected for exceeding the user's rate limit.
+	LimitReasonRate = "rate"
+
+	// ShadowResultMatch is the LabelResult of mirrored requests for which the shadow webhook made the same decision.
+	ShadowResultMatch = "match"
+	// ShadowResultDiverged is the LabelResult of mirrored requests for which the shadow webhook made another decision.
+	ShadowResultDiverged = "diverged"
+	// ShadowResultError is the LabelResult of mirrored requests which the shadow webhook failed to answer.
+	ShadowResultError = "error"
+	// ShadowResultDropped is the LabelResult of requests which were not mirrored because too many mirrored requests
+	// were in flight.
+	ShadowResultDropped = "dropped"
+
+	// AuditSinkFile is the LabelSink of audit records written to the audit log file.
+	AuditSinkFile = "file"
+	// AuditSinkWebhook is the LabelSink of audit records sent to the audit webhook.
+	AuditSinkWebhook = "webhook"
+	// AuditResultWritten is the LabelResult of audit records which were written to their sink.
+	AuditResultWritten = "written"
+	// AuditResultError is the LabelResult of audit records which failed to be written to their sink.
+	AuditResultError = "error"
+	// AuditResultDropped is the LabelResult of audit records which were dropped because too many records were waiting
+	// to be sent to the audit webhook.
+	AuditResultDropped = "dropped"
+
+	// ClientCertReasonMissing is the LabelReason of requests rejected because the client presented no certificate.
+	ClientCertReasonMissing = "missing"
+	// ClientCertReasonInvalid is the LabelReason of requests rejected because the client certificate isn't signed by
+	// the client CA.
+	ClientCertReasonInvalid = "invalid"
+	// ClientCertReasonCommonName is the LabelReason of requests rejected because the common name of the client
+	// certificate isn't allowed.
+	ClientCertReasonCommonName = "common_name"
+
+	// UnsyncedResultQueued is the LabelResult of admission requests handled once the caches synced.
+	UnsyncedResultQueued = "queued"
+	// UnsyncedResultTimedOut is the LabelResult of admission requests which failed because the caches didn't sync in
+	// time.
+	UnsyncedResultTimedOut = "timed_out"
+	// UnsyncedResultAllowed is the LabelResult of admission requests allowed without being handled.
+	UnsyncedResultAllowed = "allowed"
+)
@@ -0,0 +1,139 @@
+package metrics
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// Names of the generated recording rules. Like the metric names, they are part of the naming contract.
+const (
+	// AdmissionRequestDurationP99Record is the 99th percentile of AdmissionRequestDuration over 5 minutes.
+	AdmissionRequestDurationP99Record = "rancher_webhook:admission_request_duration_seconds:p99_5m"
+	// AdmissionRequestDenialRatioRecord is the ratio of denied admission requests over 5 minutes.
+	AdmissionRequestDenialRatioRecord = "rancher_webhook:admission_requests_denied:ratio_rate5m"
+)
+
+// Thresholds of the generated alerting rules.
+const (
+	highLatencySeconds = 1
+	highDenialRatio    = 0.5
+)
+
+// Webhook identifies a webhook served by the webhook server.
+type Webhook struct {
+	// Type is the type of the webhook, WebhookTypeValidating or WebhookTypeMutating.
+	Type string
+	// GVR is the GroupVersionResource reviewed by the webhook.
+	GVR schema.GroupVersionResource
+}
+
+// RuleFile is a Prometheus rule file. It can also be used as the spec of a prometheus-operator PrometheusRule.
+type RuleFile struct {
+	Groups []RuleGroup `json:"groups"`
+}
+
+// RuleGroup is a group of Prometheus rules.
+type RuleGroup struct {
+	Name  string `json:"name"`
+	Rules []Rule `json:"rules"`
+}
+
+// Rule is a Prometheus recording or alerting rule.
+type Rule struct {
+	Record      string            `json:"record,omitempty"`
+	Alert       string            `json:"alert,omitempty"`
+	Expr        string            `json:"expr"`
+	For         string            `json:"for,omitempty"`
+	Labels      map[string]string `json:"labels,omitempty"`
+	Annotations map[string]string `json:"annotations,omitempty"`
+}
+
+// PrometheusRules returns recording rules for the latency and denial ratio of each of the given webhooks, and alerting
+// rules firing when they are too high.
+func PrometheusRules(webhooks []Webhook) RuleFile {
+	webhooks = append([]Webhook(nil), webhooks...)
+	sort.Slice(webhooks, func(i, j int) bool {
+		if webhooks[i].Type != webhooks[j].Type {
+			return webhooks[i].Type < webhooks[j].Type
+		}
+		return webhooks[i].GVR.String() < webhooks[j].GVR.String()
+	})
+
+	recording := RuleGroup{Name: "rancher-webhook.rules"}
+	alerting := RuleGroup{Name: "rancher-webhook.alerts"}
+	for _, webhook := range webhooks {
+		labels := webhookLabels(webhook)
+		selector := labelSelector(labels)
+		recording.Rules = append(recording.Rules,
+			Rule{
+				Record: AdmissionRequestDurationP99Record,
+				Expr:   fmt.Sprintf("histogram_quantile(0.99, sum by (le) (rate(%s_bucket{%s}[5m])))", AdmissionRequestDurationSecondsName, selector),
+				Labels: labels,
+			},
+			Rule{
+				Record: AdmissionRequestDenialRatioRecord,
+				Expr: fmt.Sprintf("sum(rate(%s{%s,%s=%q}[5m])) / sum(rate(%s{%s}[5m]))",
+					AdmissionRequestsTotalName, selector, LabelResult, ResultDenied, AdmissionRequestsTotalName, selector),
+				Labels: labels,
+			},
+		)
+		alerting.Rules = append(alerting.Rules,
+			Rule{
+				Alert:  "RancherWebhookHighLatency",
+				Expr:   fmt.Sprintf("%s{%s} > %v", AdmissionRequestDurationP99Record, selector, highLatencySeconds),
+				For:    "10m",
+				Labels: map[string]string{"severity": "warning"},
+				Annotations: map[string]string{
+					"summary": fmt.Sprintf("The %s webhook for %s is slow to respond.", webhook.Type, webhookName(webhook.GVR)),
+					"description": fmt.Sprintf("The 99th percentile latency of the %s webhook for %s has been above %vs for 10 minutes.",
+						webhook.Type, webhookName(webhook.GVR), highLatencySeconds),
+				},
+			},
+			Rule{
+				Alert:  "RancherWebhookHighDenialRate",
+				Expr:   fmt.Sprintf("%s{%s} > %v", AdmissionRequestDenialRatioRecord, selector, highDenialRatio),
+				For:    "15m",
+				Labels: map[string]string{"severity": "info"},
+				Annotations: map[string]string{
+					"summary": fmt.Sprintf("The %s webhook for %s denies most requests.", webhook.Type, webhookName(webhook.GVR)),
+					"description": fmt.Sprintf("More than %v%% of the requests to the %s webhook for %s have been denied for 15 minutes.",
+						highDenialRatio*100, webhook.Type, webhookName(webhook.GVR)),
+				},
+			},
+		)
+	}
+	return RuleFile{Groups: []RuleGroup{recording, alerting}}
+}
+
+func webhookLabels(webhook Webhook) map[string]string {
+	return map[string]string{
+		LabelWebhookType: webhook.Type,
+		LabelGroup:       webhook.GVR.Group,
+		LabelVersion:     webhook.GVR.Version,
+		LabelResource:    webhook.GVR.Resource,
+	}
+}
+
+// labelSelector returns the PromQL label matchers for the given labels, sorted by label name.
+func labelSelector(labels map[string]string) string {
+	names := make([]string, 0, len(labels))
+	for name := range labels {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	matchers := make([]string, 0, len(names))
+	for _, name := range names {
+		matchers = append(matchers, fmt.Sprintf("%s=%q", name, labels[name]))
+	}
+	return strings.Join(matchers, ",")
+}
+
+func webhookName(gvr schema.GroupVersionResource) string {
+	if gvr.Group == "" {
+		return gvr.Resource
+	}
+	return gvr.Resource + "." + gvr.Group
+}
@@ -0,0 +1,97 @@
+package offline
+
+import (
+	"github.com/rancher/wrangler/v3/pkg/generic"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
+	"k8s.io/client-go/tools/cache"
+)
+
+// objectCache is an in-memory generic.CacheInterface holding the objects read from manifests, in place of the informer
+// caches of a cluster.
+type objectCache[T runtime.Object] struct {
+	indexer  cache.Indexer
+	resource schema.GroupResource
+}
+
+func newObjectCache[T runtime.Object](resource schema.GroupResource) *objectCache[T] {
+	return &objectCache[T]{
+		indexer:  cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{cache.NamespaceIndex: cache.MetaNamespaceIndexFunc}),
+		resource: resource,
+	}
+}
+
+// add adds or replaces the object in the cache.
+func (c *objectCache[T]) add(obj T) error {
+	return c.indexer.Add(obj)
+}
+
+// Get returns the object with the given name in the given namespace.
+func (c *objectCache[T]) Get(namespace, name string) (T, error) {
+	var nilObj T
+	key := name
+	if namespace != metav1.NamespaceAll {
+		key = namespace + "/" + name
+	}
+	obj, exists, err := c.indexer.GetByKey(key)
+	if err != nil {
+		return nilObj, err
+	}
+	if !exists {
+		return nilObj, apierrors.NewNotFound(c.resource, name)
+	}
+	return obj.(T), nil
+}
+
+// List returns the objects in the given namespace matching the selector.
+func (c *objectCache[T]) List(namespace string, selector labels.Selector) (ret []T, err error) {
+	err = cache.ListAllByNamespace(c.indexer, namespace, selector, func(m interface{}) {
+		ret = append(ret, m.(T))
+	})
+	return ret, err
+}
+
+// AddIndexer adds a new index to the cache.
+func (c *objectCache[T]) AddIndexer(indexName string, indexer generic.Indexer[T]) {
+	utilruntime.Must(c.indexer.AddIndexers(map[string]cache.IndexFunc{
+		indexName: func(obj interface{}) ([]string, error) {
+			return indexer(obj.(T))
+		},
+	}))
+}
+
+// GetByIndex returns the objects whose indexed values for the named index include the given value.
+func (c *objectCache[T]) GetByIndex(indexName, key string) ([]T, error) {
+	objs, err := c.indexer.ByIndex(indexName, key)
+	if err != nil {
+		return nil, err
+	}
+	result := make([]T, 0, len(objs))
+	for _, obj := range objs {
+		result = append(result, obj.(T))
+	}
+	return result, nil
+}
+
+// nonNamespacedCache is an objectCache implementing generic.NonNamespacedCacheInterface.
+type nonNamespacedCache[T runtime.Object] struct {
+	*objectCache[T]
+}
+
+func newNonNamespacedCache[T runtime.Object](resource schema.GroupResource) nonNamespacedCache[T] {
+	return nonNamespacedCache[T]{objectCache: newObjectCache[T](resource)}
+}
+
+// Get returns the object with the given name.
+func (c nonNamespacedCache[T]) Get(name string) (T, error) {
+	return c.objectCache.Get(metav1.NamespaceAll, name)
+}
+
+// List returns the objects matching the selector.
+func (c nonNamespacedCache[T]) List(selector labels.Selector) ([]T, error) {
+	return c.objectCache.List(metav1.NamespaceAll, selector)
+}
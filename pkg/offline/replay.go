@@ -0,0 +1,146 @@
+package offline
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/rancher/webhook/pkg/admission"
+	"github.com/rancher/webhook/pkg/auth"
+	admissionv1 "k8s.io/api/admission/v1"
+	authzv1 "k8s.io/api/authorization/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apiserver/pkg/authentication/user"
+	"k8s.io/apiserver/pkg/authorization/authorizer"
+	authorizationv1 "k8s.io/client-go/kubernetes/typed/authorization/v1"
+	"k8s.io/kubernetes/plugin/pkg/auth/authorizer/rbac"
+)
+
+// ReplayResult is the outcome of the replay of an AdmissionReview.
+type ReplayResult struct {
+	UID       string
+	Kind      string
+	Operation admissionv1.Operation
+	Namespace string
+	Name      string
+	// Skipped is true if no validator supports the kind of the request.
+	Skipped bool
+	// Verdicts are the verdicts of each admitter of the kind's validator, in order.
+	Verdicts []Verdict
+}
+
+// Verdict is the response of an admitter to a replayed request.
+type Verdict struct {
+	Allowed  bool
+	Code     int32
+	Message  string
+	Warnings []string
+	// Err is set if the admitter failed to evaluate the request.
+	Err error
+}
+
+// Replay runs the validators against the requests of the given AdmissionReviews, as JSON, in order. The caches of the
+// validators are filled with the objects of the fixtures, YAML or JSON manifests in the format accepted by Validate, in
+// place of the objects of the cluster the reviews were captured in. SubjectAccessReviews are evaluated against the
+// Roles, ClusterRoles and their bindings in the fixtures, so that the verdicts don't depend on anything but the files.
+// Unlike Validate, every admitter of a validator is run, even after one of them denied the request.
+func Replay(fixtures [][]byte, reviews ...[]byte) ([]ReplayResult, error) {
+	var objects []*unstructured.Unstructured
+	for _, fixture := range fixtures {
+		decoded, err := decodeManifest(fixture)
+		if err != nil {
+			return nil, err
+		}
+		objects = append(objects, decoded...)
+	}
+
+	v := newValidators(func(getter auth.RBACRestGetter) authorizationv1.SubjectAccessReviewInterface {
+		return rbacSubjectAccessReviews{authorizer: rbac.New(getter, getter, getter, getter)}
+	})
+	if err := v.load(objects); err != nil {
+		return nil, err
+	}
+
+	results := make([]ReplayResult, 0, len(reviews))
+	for i, data := range reviews {
+		review := &admissionv1.AdmissionReview{}
+		if err := json.Unmarshal(data, review); err != nil {
+			return nil, fmt.Errorf("failed to decode AdmissionReview %d: %w", i, err)
+		}
+		if review.Request == nil {
+			return nil, fmt.Errorf("AdmissionReview %d has no request", i)
+		}
+		results = append(results, v.replay(review.Request))
+	}
+	return results, nil
+}
+
+// replay runs every admitter of the request's validator against the request.
+func (v *validators) replay(request *admissionv1.AdmissionRequest) ReplayResult {
+	result := ReplayResult{
+		UID:       string(request.UID),
+		Kind:      request.Kind.Kind,
+		Operation: request.Operation,
+		Namespace: request.Namespace,
+		Name:      request.Name,
+	}
+	gvk := schema.GroupVersionKind{Group: request.Kind.Group, Version: request.Kind.Version, Kind: request.Kind.Kind}
+	handler, ok := v.handlers[gvk]
+	if !ok {
+		result.Skipped = true
+		return result
+	}
+	for _, admitter := range handler.Admitters() {
+		response, err := admitter.Admit(&admission.Request{Context: context.Background(), AdmissionRequest: *request})
+		if err != nil {
+			result.Verdicts = append(result.Verdicts, Verdict{Err: err})
+			continue
+		}
+		verdict := Verdict{Allowed: response.Allowed, Warnings: response.Warnings}
+		if response.Result != nil {
+			verdict.Code = response.Result.Code
+			verdict.Message = response.Result.Message
+		}
+		result.Verdicts = append(result.Verdicts, verdict)
+	}
+	return result
+}
+
+// rbacSubjectAccessReviews evaluates SubjectAccessReviews with the RBAC authorizer of Kubernetes.
+type rbacSubjectAccessReviews struct {
+	authorizationv1.SubjectAccessReviewInterface
+	authorizer *rbac.RBACAuthorizer
+}
+
+// Create returns the SubjectAccessReview with the status decided by the RBAC authorizer.
+func (r rbacSubjectAccessReviews) Create(ctx context.Context, review *authzv1.SubjectAccessReview, _ metav1.CreateOptions) (*authzv1.SubjectAccessReview, error) {
+	extra := make(map[string][]string, len(review.Spec.Extra))
+	for key, value := range review.Spec.Extra {
+		extra[key] = value
+	}
+	attributes := authorizer.AttributesRecord{
+		User: &user.DefaultInfo{Name: review.Spec.User, UID: review.Spec.UID, Groups: review.Spec.Groups, Extra: extra},
+	}
+	if resource := review.Spec.ResourceAttributes; resource != nil {
+		attributes.ResourceRequest = true
+		attributes.Verb = resource.Verb
+		attributes.Namespace = resource.Namespace
+		attributes.APIGroup = resource.Group
+		attributes.APIVersion = resource.Version
+		attributes.Resource = resource.Resource
+		attributes.Subresource = resource.Subresource
+		attributes.Name = resource.Name
+	} else if nonResource := review.Spec.NonResourceAttributes; nonResource != nil {
+		attributes.Verb = nonResource.Verb
+		attributes.Path = nonResource.Path
+	}
+	decision, reason, err := r.authorizer.Authorize(ctx, attributes)
+	if err != nil {
+		return nil, err
+	}
+	result := review.DeepCopy()
+	result.Status = authzv1.SubjectAccessReviewStatus{Allowed: decision == authorizer.DecisionAllow, Reason: reason}
+	return result, nil
+}
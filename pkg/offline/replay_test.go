@@ -0,0 +1,101 @@
+package offline
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	admissionv1 "k8s.io/api/admission/v1"
+	authenticationv1 "k8s.io/api/authentication/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+const fixtures = `
+apiVersion: rbac.authorization.k8s.io/v1
+kind: ClusterRole
+metadata:
+  name: admin
+rules:
+- apiGroups: ["*"]
+  resources: ["*"]
+  verbs: ["*"]
+---
+apiVersion: rbac.authorization.k8s.io/v1
+kind: ClusterRoleBinding
+metadata:
+  name: admin
+roleRef:
+  apiGroup: rbac.authorization.k8s.io
+  kind: ClusterRole
+  name: admin
+subjects:
+- apiGroup: rbac.authorization.k8s.io
+  kind: User
+  name: admin
+`
+
+const roleTemplate = `{"apiVersion":"management.cattle.io/v3","kind":"RoleTemplate","metadata":{"name":"rt"},"context":"cluster",` +
+	`"rules":[{"apiGroups":[""],"resources":["pods"],"verbs":["get"]}]}`
+
+func TestReplay(t *testing.T) {
+	t.Parallel()
+	results, err := Replay([][]byte{[]byte(fixtures)},
+		newReview(t, "1", "RoleTemplate", "admin", roleTemplate),
+		newReview(t, "2", "RoleTemplate", "user", roleTemplate),
+		newReview(t, "3", "ConfigMap", "admin", `{"apiVersion":"v1","kind":"ConfigMap","metadata":{"name":"cm"}}`),
+	)
+	require.NoError(t, err)
+	require.Len(t, results, 3)
+
+	assert.Equal(t, "1", results[0].UID)
+	assert.Equal(t, admissionv1.Create, results[0].Operation)
+	require.NotEmpty(t, results[0].Verdicts)
+	for _, verdict := range results[0].Verdicts {
+		assert.True(t, verdict.Allowed, verdict.Message)
+	}
+
+	// the user has no permissions in the fixtures, so the escalation check denies the request
+	require.NotEmpty(t, results[1].Verdicts)
+	assert.False(t, results[1].Verdicts[0].Allowed)
+	assert.Contains(t, results[1].Verdicts[0].Message, "not currently held")
+
+	assert.True(t, results[2].Skipped)
+	assert.Empty(t, results[2].Verdicts)
+}
+
+func TestReplayInvalidReview(t *testing.T) {
+	t.Parallel()
+	_, err := Replay(nil, []byte(`{"kind":"AdmissionReview"}`))
+	assert.Error(t, err)
+
+	_, err = Replay(nil, []byte(`not json`))
+	assert.Error(t, err)
+}
+
+func newReview(t *testing.T, uid, kind, username, object string) []byte {
+	t.Helper()
+	var obj map[string]any
+	require.NoError(t, json.Unmarshal([]byte(object), &obj))
+	group := "management.cattle.io"
+	version := "v3"
+	if kind == "ConfigMap" {
+		group, version = "", "v1"
+	}
+	review := admissionv1.AdmissionReview{
+		TypeMeta: metav1.TypeMeta{APIVersion: "admission.k8s.io/v1", Kind: "AdmissionReview"},
+		Request: &admissionv1.AdmissionRequest{
+			UID:       types.UID(uid),
+			Kind:      metav1.GroupVersionKind{Group: group, Version: version, Kind: kind},
+			Name:      obj["metadata"].(map[string]any)["name"].(string),
+			Operation: admissionv1.Create,
+			UserInfo:  authenticationv1.UserInfo{Username: username},
+			Object:    runtime.RawExtension{Raw: []byte(object)},
+		},
+	}
+	data, err := json.Marshal(review)
+	require.NoError(t, err)
+	return data
+}
@@ -0,0 +1,289 @@
+// Package offline runs the webhook's validators against manifests without a cluster, so that manifests can be
+// validated before they are applied.
+package offline
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+
+	v3 "github.com/rancher/rancher/pkg/apis/management.cattle.io/v3"
+	provv1 "github.com/rancher/rancher/pkg/apis/provisioning.cattle.io/v1"
+	"github.com/rancher/webhook/pkg/admission"
+	"github.com/rancher/webhook/pkg/auth"
+	controllerv3 "github.com/rancher/webhook/pkg/generated/controllers/management.cattle.io/v3"
+	"github.com/rancher/webhook/pkg/resolvers"
+	"github.com/rancher/webhook/pkg/resources/common"
+	"github.com/rancher/webhook/pkg/resources/management.cattle.io/v3/globalrole"
+	"github.com/rancher/webhook/pkg/resources/management.cattle.io/v3/roletemplate"
+	provisioningCluster "github.com/rancher/webhook/pkg/resources/provisioning.cattle.io/v1/cluster"
+	admissionv1 "k8s.io/api/admission/v1"
+	authenticationv1 "k8s.io/api/authentication/v1"
+	authzv1 "k8s.io/api/authorization/v1"
+	corev1 "k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/yaml"
+	authorizationv1 "k8s.io/client-go/kubernetes/typed/authorization/v1"
+	"k8s.io/kubernetes/pkg/registry/rbac/validation"
+)
+
+// Username is the name of the user the manifests are validated for. The user is allowed every SubjectAccessReview, so
+// checks of the requesting user's permissions, such as privilege escalation checks, always pass.
+const Username = "webhook-validate"
+
+// Result is the outcome of the validation of an object.
+type Result struct {
+	Kind      string
+	Namespace string
+	Name      string
+	// Allowed is true if all validators allowed the object.
+	Allowed bool
+	// Skipped is true if no validator supports the kind of the object.
+	Skipped bool
+	// Message is the reason the object was denied.
+	Message string
+	// Err is set if a validator failed to evaluate the object.
+	Err error
+}
+
+// validators holds the validators run against the manifests and the caches they read from. The caches are filled with
+// the objects of the manifests, in place of the objects of a cluster.
+type validators struct {
+	handlers map[schema.GroupVersionKind]admission.ValidatingAdmissionHandler
+	loaders  map[schema.GroupVersionKind]func(map[string]any) error
+	// creatorIDKinds are the kinds whose mutator sets the creatorID annotation of created objects to the requesting
+	// user. The annotation is set to Username on the objects of these kinds before they are validated.
+	creatorIDKinds map[schema.GroupVersionKind]bool
+}
+
+// newValidators returns the validators and their empty caches. The SubjectAccessReviews of the validators are created
+// with newSAR, from the RBAC objects of the caches.
+func newValidators(newSAR func(auth.RBACRestGetter) authorizationv1.SubjectAccessReviewInterface) *validators {
+	roleTemplates := newNonNamespacedCache[*v3.RoleTemplate](schema.GroupResource{Group: "management.cattle.io", Resource: "roletemplates"})
+	globalRoles := newNonNamespacedCache[*v3.GlobalRole](schema.GroupResource{Group: "management.cattle.io", Resource: "globalroles"})
+	globalRoleBindings := newNonNamespacedCache[*v3.GlobalRoleBinding](schema.GroupResource{Group: "management.cattle.io", Resource: "globalrolebindings"})
+	crtbs := newObjectCache[*v3.ClusterRoleTemplateBinding](schema.GroupResource{Group: "management.cattle.io", Resource: "clusterroletemplatebindings"})
+	prtbs := newObjectCache[*v3.ProjectRoleTemplateBinding](schema.GroupResource{Group: "management.cattle.io", Resource: "projectroletemplatebindings"})
+	psacts := newNonNamespacedCache[*v3.PodSecurityAdmissionConfigurationTemplate](schema.GroupResource{Group: "management.cattle.io", Resource: "podsecurityadmissionconfigurationtemplates"})
+	settings := newNonNamespacedCache[*v3.Setting](schema.GroupResource{Group: "management.cattle.io", Resource: "settings"})
+	secrets := newObjectCache[*corev1.Secret](schema.GroupResource{Resource: "secrets"})
+	clusterRoles := newNonNamespacedCache[*rbacv1.ClusterRole](schema.GroupResource{Group: "rbac.authorization.k8s.io", Resource: "clusterroles"})
+	clusterRoleBindings := newNonNamespacedCache[*rbacv1.ClusterRoleBinding](schema.GroupResource{Group: "rbac.authorization.k8s.io", Resource: "clusterrolebindings"})
+	roles := newObjectCache[*rbacv1.Role](schema.GroupResource{Group: "rbac.authorization.k8s.io", Resource: "roles"})
+	roleBindings := newObjectCache[*rbacv1.RoleBinding](schema.GroupResource{Group: "rbac.authorization.k8s.io", Resource: "rolebindings"})
+
+	rbacRestGetter := auth.RBACRestGetter{
+		Roles:               roles,
+		RoleBindings:        roleBindings,
+		ClusterRoles:        clusterRoles,
+		ClusterRoleBindings: clusterRoleBindings,
+	}
+	defaultResolver := validation.NewDefaultRuleResolver(rbacRestGetter, rbacRestGetter, rbacRestGetter, rbacRestGetter)
+	roleTemplateResolver := auth.NewRoleTemplateResolver(roleTemplates, clusterRoles)
+	globalRoleResolver := auth.NewGlobalRoleResolver(roleTemplateResolver, globalRoles)
+	sar := newSAR(rbacRestGetter)
+
+	management := func(kind string) schema.GroupVersionKind {
+		return v3.SchemeGroupVersion.WithKind(kind)
+	}
+	return &validators{
+		handlers: map[schema.GroupVersionKind]admission.ValidatingAdmissionHandler{
+			management("RoleTemplate"): roletemplate.NewValidator(defaultResolver, roleTemplateResolver, sar, globalRoles, crtbs, prtbs, nil),
+			management("GlobalRole"): globalrole.NewValidator(defaultResolver, resolvers.NewGRBRuleResolvers(globalRoleBindings, globalRoleResolver),
+				sar, globalRoleResolver, nil),
+			provv1.SchemeGroupVersion.WithKind("Cluster"): provisioningCluster.NewValidator(sar, notFoundClusterClient{}, secrets, psacts, settings, roleTemplates, nil, nil, nil, nil, false, common.UninstallServiceAccount{}),
+		},
+		loaders: map[schema.GroupVersionKind]func(map[string]any) error{
+			management("RoleTemplate"):                               loader[v3.RoleTemplate](roleTemplates.objectCache),
+			management("GlobalRole"):                                 loader[v3.GlobalRole](globalRoles.objectCache),
+			management("GlobalRoleBinding"):                          loader[v3.GlobalRoleBinding](globalRoleBindings.objectCache),
+			management("ClusterRoleTemplateBinding"):                 loader[v3.ClusterRoleTemplateBinding](crtbs),
+			management("ProjectRoleTemplateBinding"):                 loader[v3.ProjectRoleTemplateBinding](prtbs),
+			management("PodSecurityAdmissionConfigurationTemplate"):  loader[v3.PodSecurityAdmissionConfigurationTemplate](psacts.objectCache),
+			management("Setting"):                                    loader[v3.Setting](settings.objectCache),
+			corev1.SchemeGroupVersion.WithKind("Secret"):             loader[corev1.Secret](secrets),
+			rbacv1.SchemeGroupVersion.WithKind("ClusterRole"):        loader[rbacv1.ClusterRole](clusterRoles.objectCache),
+			rbacv1.SchemeGroupVersion.WithKind("ClusterRoleBinding"): loader[rbacv1.ClusterRoleBinding](clusterRoleBindings.objectCache),
+			rbacv1.SchemeGroupVersion.WithKind("Role"):               loader[rbacv1.Role](roles),
+			rbacv1.SchemeGroupVersion.WithKind("RoleBinding"):        loader[rbacv1.RoleBinding](roleBindings),
+		},
+		creatorIDKinds: map[schema.GroupVersionKind]bool{
+			provv1.SchemeGroupVersion.WithKind("Cluster"): true,
+		},
+	}
+}
+
+// loader returns a function adding an object decoded from a manifest to the cache.
+func loader[T any, PT interface {
+	*T
+	runtime.Object
+}](cache *objectCache[PT]) func(map[string]any) error {
+	return func(object map[string]any) error {
+		obj := PT(new(T))
+		if err := runtime.DefaultUnstructuredConverter.FromUnstructured(object, obj); err != nil {
+			return err
+		}
+		return cache.add(obj)
+	}
+}
+
+// Validate validates the creation of the objects of the given YAML or JSON manifests, which may hold several
+// documents and lists. The objects of all manifests are considered to exist when the objects are validated, so that
+// references between them, such as a RoleTemplate inheriting another one, are resolved. Objects of kinds no validator
+// supports are reported as skipped.
+func Validate(manifests ...[]byte) ([]Result, error) {
+	var objects []*unstructured.Unstructured
+	for _, manifest := range manifests {
+		decoded, err := decodeManifest(manifest)
+		if err != nil {
+			return nil, err
+		}
+		objects = append(objects, decoded...)
+	}
+
+	v := newValidators(func(auth.RBACRestGetter) authorizationv1.SubjectAccessReviewInterface {
+		return allowAllSubjectAccessReviews{}
+	})
+	if err := v.load(objects); err != nil {
+		return nil, err
+	}
+
+	results := make([]Result, 0, len(objects))
+	for _, obj := range objects {
+		results = append(results, v.validate(obj))
+	}
+	return results, nil
+}
+
+// load adds the objects to the caches. Objects of kinds which aren't cached are ignored.
+func (v *validators) load(objects []*unstructured.Unstructured) error {
+	for _, obj := range objects {
+		load, ok := v.loaders[obj.GroupVersionKind()]
+		if !ok {
+			continue
+		}
+		if err := load(obj.Object); err != nil {
+			return fmt.Errorf("failed to decode %s %s: %w", obj.GetKind(), objectName(obj), err)
+		}
+	}
+	return nil
+}
+
+// validate runs the admitters of the object's validator against the creation of the object.
+func (v *validators) validate(obj *unstructured.Unstructured) Result {
+	result := Result{Kind: obj.GetKind(), Namespace: obj.GetNamespace(), Name: obj.GetName()}
+	handler, ok := v.handlers[obj.GroupVersionKind()]
+	if !ok {
+		result.Skipped = true
+		return result
+	}
+	gvk := obj.GroupVersionKind()
+	gvr := handler.GVR()
+	request := &admission.Request{
+		Context: context.Background(),
+		AdmissionRequest: admissionv1.AdmissionRequest{
+			UID:       "offline",
+			Kind:      metav1.GroupVersionKind{Group: gvk.Group, Version: gvk.Version, Kind: gvk.Kind},
+			Resource:  metav1.GroupVersionResource{Group: gvr.Group, Version: gvr.Version, Resource: gvr.Resource},
+			Name:      obj.GetName(),
+			Namespace: obj.GetNamespace(),
+			Operation: admissionv1.Create,
+			UserInfo:  authenticationv1.UserInfo{Username: Username, Groups: []string{"system:authenticated"}},
+		},
+	}
+	if v.creatorIDKinds[gvk] {
+		// as the mutator of the kind would before the object is validated
+		obj = obj.DeepCopy()
+		common.SetCreatorIDAnnotation(request, obj)
+	}
+	raw, err := obj.MarshalJSON()
+	if err != nil {
+		result.Err = err
+		return result
+	}
+	request.Object = runtime.RawExtension{Raw: raw}
+	for _, admitter := range handler.Admitters() {
+		response, err := admitter.Admit(request)
+		if err != nil {
+			result.Err = err
+			return result
+		}
+		if !response.Allowed {
+			if response.Result != nil {
+				result.Message = response.Result.Message
+			}
+			return result
+		}
+	}
+	result.Allowed = true
+	return result
+}
+
+// decodeManifest decodes the objects of a YAML or JSON manifest. The items of lists are returned as objects.
+func decodeManifest(manifest []byte) ([]*unstructured.Unstructured, error) {
+	var objects []*unstructured.Unstructured
+	decoder := yaml.NewYAMLOrJSONDecoder(bytes.NewReader(manifest), 4096)
+	for {
+		var object map[string]any
+		if err := decoder.Decode(&object); err != nil {
+			if errors.Is(err, io.EOF) {
+				return objects, nil
+			}
+			return nil, fmt.Errorf("failed to decode manifest: %w", err)
+		}
+		if len(object) == 0 {
+			continue
+		}
+		obj := &unstructured.Unstructured{Object: object}
+		if obj.GetKind() == "" || obj.GetAPIVersion() == "" {
+			return nil, fmt.Errorf("object %s is missing apiVersion or kind", objectName(obj))
+		}
+		if !obj.IsList() {
+			objects = append(objects, obj)
+			continue
+		}
+		err := obj.EachListItem(func(item runtime.Object) error {
+			objects = append(objects, item.(*unstructured.Unstructured))
+			return nil
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode list: %w", err)
+		}
+	}
+}
+
+func objectName(obj *unstructured.Unstructured) string {
+	if obj.GetNamespace() == "" {
+		return obj.GetName()
+	}
+	return obj.GetNamespace() + "/" + obj.GetName()
+}
+
+// allowAllSubjectAccessReviews allows every SubjectAccessReview, as if the requesting user had all permissions.
+type allowAllSubjectAccessReviews struct {
+	authorizationv1.SubjectAccessReviewInterface
+}
+
+// Create returns the SubjectAccessReview with an allowed status.
+func (allowAllSubjectAccessReviews) Create(_ context.Context, review *authzv1.SubjectAccessReview, _ metav1.CreateOptions) (*authzv1.SubjectAccessReview, error) {
+	result := review.DeepCopy()
+	result.Status = authzv1.SubjectAccessReviewStatus{Allowed: true, Reason: "offline validation"}
+	return result, nil
+}
+
+// notFoundClusterClient is a management cluster client for which no management cluster exists.
+type notFoundClusterClient struct {
+	controllerv3.ClusterClient
+}
+
+// Get returns a NotFound error.
+func (notFoundClusterClient) Get(name string, _ metav1.GetOptions) (*v3.Cluster, error) {
+	return nil, apierrors.NewNotFound(schema.GroupResource{Group: "management.cattle.io", Resource: "clusters"}, name)
+}
@@ -0,0 +1,103 @@
+package offline
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const manifests = `
+apiVersion: management.cattle.io/v3
+kind: RoleTemplate
+metadata:
+  name: rt-base
+context: cluster
+rules:
+- apiGroups: [""]
+  resources: ["pods"]
+  verbs: ["get"]
+---
+apiVersion: management.cattle.io/v3
+kind: RoleTemplate
+metadata:
+  name: rt-child
+context: cluster
+roleTemplateNames: ["rt-base"]
+---
+apiVersion: v1
+kind: List
+items:
+- apiVersion: management.cattle.io/v3
+  kind: GlobalRole
+  metadata:
+    name: gr-no-verbs
+  rules:
+  - apiGroups: [""]
+    resources: ["pods"]
+- apiVersion: v1
+  kind: ConfigMap
+  metadata:
+    name: settings
+    namespace: default
+`
+
+func TestValidate(t *testing.T) {
+	t.Parallel()
+	results, err := Validate([]byte(manifests), []byte(`{"apiVersion":"management.cattle.io/v3","kind":"RoleTemplate","metadata":{"name":"rt-other"},"context":"project","roleTemplateNames":["rt-missing"]}`))
+	require.NoError(t, err)
+	require.Len(t, results, 5)
+
+	assert.Equal(t, Result{Kind: "RoleTemplate", Name: "rt-base", Allowed: true}, results[0])
+	// RoleTemplates can inherit RoleTemplates from the manifests
+	assert.Equal(t, Result{Kind: "RoleTemplate", Name: "rt-child", Allowed: true}, results[1])
+
+	assert.Equal(t, "GlobalRole", results[2].Kind)
+	assert.False(t, results[2].Allowed)
+	assert.Contains(t, results[2].Message, "verbs must contain at least one value")
+
+	assert.Equal(t, Result{Kind: "ConfigMap", Namespace: "default", Name: "settings", Skipped: true}, results[3])
+
+	assert.Equal(t, "rt-other", results[4].Name)
+	assert.False(t, results[4].Allowed)
+	assert.ErrorContains(t, results[4].Err, "rt-missing")
+}
+
+func TestValidateCluster(t *testing.T) {
+	t.Parallel()
+	results, err := Validate([]byte(`
+apiVersion: provisioning.cattle.io/v1
+kind: Cluster
+metadata:
+  name: rke2
+  namespace: fleet-default
+spec:
+  kubernetesVersion: v1.30.5+rke2r1
+  rkeConfig: {}
+---
+apiVersion: provisioning.cattle.io/v1
+kind: Cluster
+metadata:
+  name: exported
+  namespace: fleet-default
+  annotations:
+    field.cattle.io/creatorId: u-abc
+spec:
+  kubernetesVersion: v1.30.5+rke2r1
+  rkeConfig: {}
+`))
+	require.NoError(t, err)
+	require.Len(t, results, 2)
+	// the creatorID is set to the validating user, as the mutator of provisioning clusters does
+	assert.Equal(t, Result{Kind: "Cluster", Namespace: "fleet-default", Name: "rke2", Allowed: true}, results[0])
+	assert.Equal(t, Result{Kind: "Cluster", Namespace: "fleet-default", Name: "exported", Allowed: true}, results[1])
+}
+
+func TestValidateInvalidManifest(t *testing.T) {
+	t.Parallel()
+	_, err := Validate([]byte("metadata:\n  name: no-kind\n"))
+	assert.Error(t, err)
+
+	_, err = Validate([]byte("kind: [unterminated"))
+	assert.Error(t, err)
+}
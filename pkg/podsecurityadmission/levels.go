@@ -0,0 +1,31 @@
+package podsecurityadmission
+
+import (
+	apisv3 "github.com/rancher/rancher/pkg/apis/management.cattle.io/v3"
+	"k8s.io/pod-security-admission/api"
+)
+
+// levelRanks orders the Pod Security levels from the least to the most restrictive.
+var levelRanks = map[api.Level]int{
+	api.LevelPrivileged: 0,
+	api.LevelBaseline:   1,
+	api.LevelRestricted: 2,
+}
+
+// EnforcedLevel returns the Pod Security level the template enforces by default. Clusters without a template, and
+// templates without a valid level, enforce the privileged level, as the API server does without configuration.
+func EnforcedLevel(template *apisv3.PodSecurityAdmissionConfigurationTemplate) api.Level {
+	if template == nil {
+		return api.LevelPrivileged
+	}
+	level, err := api.ParseLevel(template.Configuration.Defaults.Enforce)
+	if err != nil {
+		return api.LevelPrivileged
+	}
+	return level
+}
+
+// IsDowngrade returns true if the new template enforces a less restrictive level by default than the old one.
+func IsDowngrade(oldTemplate, newTemplate *apisv3.PodSecurityAdmissionConfigurationTemplate) bool {
+	return levelRanks[EnforcedLevel(newTemplate)] < levelRanks[EnforcedLevel(oldTemplate)]
+}
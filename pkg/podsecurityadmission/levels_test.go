@@ -0,0 +1,30 @@
+package podsecurityadmission
+
+import (
+	"testing"
+
+	v3 "github.com/rancher/rancher/pkg/apis/management.cattle.io/v3"
+	"github.com/stretchr/testify/assert"
+	"k8s.io/pod-security-admission/api"
+)
+
+func TestIsDowngrade(t *testing.T) {
+	t.Parallel()
+	template := func(level string) *v3.PodSecurityAdmissionConfigurationTemplate {
+		return &v3.PodSecurityAdmissionConfigurationTemplate{
+			Configuration: v3.PodSecurityAdmissionConfigurationTemplateSpec{
+				Defaults: v3.PodSecurityAdmissionConfigurationTemplateDefaults{Enforce: level},
+			},
+		}
+	}
+	assert.Equal(t, api.LevelPrivileged, EnforcedLevel(nil))
+	assert.Equal(t, api.LevelPrivileged, EnforcedLevel(template("")))
+	assert.Equal(t, api.LevelBaseline, EnforcedLevel(template("baseline")))
+
+	assert.True(t, IsDowngrade(template("restricted"), template("privileged")))
+	assert.True(t, IsDowngrade(template("restricted"), template("baseline")))
+	assert.True(t, IsDowngrade(template("baseline"), nil))
+	assert.False(t, IsDowngrade(template("baseline"), template("restricted")))
+	assert.False(t, IsDowngrade(template("restricted"), template("restricted")))
+	assert.False(t, IsDowngrade(nil, template("privileged")))
+}
@@ -0,0 +1,29 @@
+package quota
+
+import corev1 "k8s.io/api/core/v1"
+
+// ServicesNodePorts is the name of the resource limiting the number of node ports of the Services of a project or
+// namespace.
+const ServicesNodePorts corev1.ResourceName = "servicesNodePorts"
+
+// NodePorts returns the number of node ports the Service uses, counted as the services.nodeports resource of a
+// ResourceQuota: every port of a NodePort Service, and every port of a LoadBalancer Service unless the allocation of
+// its node ports is disabled, in which case only the ports with an explicit node port are counted.
+func NodePorts(service *corev1.Service) int64 {
+	switch service.Spec.Type {
+	case corev1.ServiceTypeNodePort:
+		return int64(len(service.Spec.Ports))
+	case corev1.ServiceTypeLoadBalancer:
+		if service.Spec.AllocateLoadBalancerNodePorts == nil || *service.Spec.AllocateLoadBalancerNodePorts {
+			return int64(len(service.Spec.Ports))
+		}
+		var count int64
+		for _, port := range service.Spec.Ports {
+			if port.NodePort != 0 {
+				count++
+			}
+		}
+		return count
+	}
+	return 0
+}
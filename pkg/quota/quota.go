@@ -0,0 +1,111 @@
+// Package quota parses and compares the resource quotas of projects and namespaces.
+package quota
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	v3 "github.com/rancher/rancher/pkg/apis/management.cattle.io/v3"
+	"github.com/rancher/wrangler/v3/pkg/data/convert"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	quotav1 "k8s.io/apiserver/pkg/quota/v1"
+)
+
+// ExtendedKey is the key of a quota limit holding extended resources, such as requests.nvidia.com/gpu, by name.
+const ExtendedKey = "extended"
+
+// FromLimit converts a management.cattle.io/v3 ResourceQuotaLimit to a ResourceList keyed by the JSON names of its
+// fields, with the extended resources of the limit, if any, keyed by their own names.
+func FromLimit(limit *v3.ResourceQuotaLimit) (corev1.ResourceList, error) {
+	values, err := convert.EncodeToMap(limit)
+	if err != nil {
+		return nil, err
+	}
+	return Parse(values)
+}
+
+// FromNamespaceAnnotation parses the quota set by the field.cattle.io/resourceQuota annotation of a namespace. Unlike
+// a decoded v3.NamespaceResourceQuota, it keeps the extended resources of the limit.
+func FromNamespaceAnnotation(value string) (corev1.ResourceList, error) {
+	var quota struct {
+		Limit map[string]any `json:"limit"`
+	}
+	if err := json.Unmarshal([]byte(value), &quota); err != nil {
+		return nil, err
+	}
+	return Parse(quota.Limit)
+}
+
+// Parse converts the values of a quota limit to a ResourceList. The ExtendedKey holds a map of extended resources,
+// which are added by name. Empty values are skipped, and quantities are otherwise parsed as they are, including
+// negative ones which never fit.
+func Parse(values map[string]any) (corev1.ResourceList, error) {
+	list := corev1.ResourceList{}
+	for key, value := range values {
+		if key == ExtendedKey {
+			extended, ok := value.(map[string]any)
+			if !ok {
+				return nil, fmt.Errorf("%s must be a map of resource names to quantities", ExtendedKey)
+			}
+			for name, quantity := range extended {
+				if err := add(list, name, quantity); err != nil {
+					return nil, err
+				}
+			}
+			continue
+		}
+		if err := add(list, key, value); err != nil {
+			return nil, err
+		}
+	}
+	return list, nil
+}
+
+// add parses the value as the quantity of the resource with the given name, which must not already be set.
+func add(list corev1.ResourceList, name string, value any) error {
+	s := convert.ToString(value)
+	if s == "" {
+		return nil
+	}
+	if _, ok := list[corev1.ResourceName(name)]; ok {
+		return fmt.Errorf("%s is set more than once", name)
+	}
+	q, err := resource.ParseQuantity(s)
+	if err != nil {
+		return fmt.Errorf("invalid quantity %q for %s: %w", s, name, err)
+	}
+	list[corev1.ResourceName(name)] = q
+	return nil
+}
+
+// Fits checks whether the limit is sufficient for the requested quota. Resources missing from the limit are not
+// limited. If it is not sufficient, the requested resources exceeding the limit, or negative, are returned.
+func Fits(requested, limit corev1.ResourceList) (bool, corev1.ResourceList) {
+	_, exceeded := quotav1.LessThanOrEqual(requested, limit)
+	// Include resources with negative values among exceeded resources.
+	exceeded = append(exceeded, quotav1.IsNegative(requested)...)
+	if len(exceeded) == 0 {
+		return true, nil
+	}
+	return false, quotav1.Mask(requested, exceeded)
+}
+
+// Add returns the sum of the two quotas. Resources set in only one of them are kept as they are.
+func Add(a, b corev1.ResourceList) corev1.ResourceList {
+	return quotav1.Add(a, b)
+}
+
+// Format formats the resources as a sorted, comma separated list of name=quantity pairs.
+// directly copied from https://github.com/kubernetes/kubernetes/blob/a66aad2d80dacc70025f95a8f97d2549ebd3208c/pkg/kubelet/util/format/resources.go
+func Format(resources corev1.ResourceList) string {
+	resourceStrings := make([]string, 0, len(resources))
+	for key, value := range resources {
+		resourceStrings = append(resourceStrings, fmt.Sprintf("%v=%v", key, value.String()))
+	}
+	// sort the results for consistent log output
+	sort.Strings(resourceStrings)
+	return strings.Join(resourceStrings, ",")
+}
@@ -0,0 +1,236 @@
+package quota
+
+import (
+	"fmt"
+	"math/rand"
+	"reflect"
+	"testing"
+	"testing/quick"
+
+	v3 "github.com/rancher/rancher/pkg/apis/management.cattle.io/v3"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+)
+
+func TestFromLimit(t *testing.T) {
+	t.Parallel()
+	list, err := FromLimit(&v3.ResourceQuotaLimit{Pods: "10", LimitsMemory: "1Gi", RequestsCPU: "500m"})
+	require.NoError(t, err)
+	assert.Equal(t, "limitsMemory=1Gi,pods=10,requestsCpu=500m", Format(list))
+
+	_, err = FromLimit(&v3.ResourceQuotaLimit{Pods: "ten"})
+	assert.Error(t, err)
+}
+
+func TestFromNamespaceAnnotation(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name    string
+		value   string
+		want    string
+		wantErr bool
+	}{
+		{
+			name:  "standard resources",
+			value: `{"limit":{"pods":"5","limitsCpu":"2"}}`,
+			want:  "limitsCpu=2,pods=5",
+		},
+		{
+			name:  "extended resources",
+			value: `{"limit":{"pods":"5","extended":{"requests.nvidia.com/gpu":"2","count/jobs.batch":3}}}`,
+			want:  "count/jobs.batch=3,pods=5,requests.nvidia.com/gpu=2",
+		},
+		{
+			name:  "empty values",
+			value: `{"limit":{"pods":""}}`,
+			want:  "",
+		},
+		{
+			name:    "invalid json",
+			value:   `{"limit":`,
+			wantErr: true,
+		},
+		{
+			name:    "invalid quantity",
+			value:   `{"limit":{"extended":{"requests.nvidia.com/gpu":"two"}}}`,
+			wantErr: true,
+		},
+		{
+			name:    "invalid extended",
+			value:   `{"limit":{"extended":"requests.nvidia.com/gpu=2"}}`,
+			wantErr: true,
+		},
+		{
+			name:    "extended resource set twice",
+			value:   `{"limit":{"pods":"5","extended":{"pods":"2"}}}`,
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			list, err := FromNamespaceAnnotation(tt.value)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, Format(list))
+		})
+	}
+}
+
+func TestFits(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name      string
+		requested corev1.ResourceList
+		limit     corev1.ResourceList
+		exceeded  string
+	}{
+		{
+			name:      "mixed units",
+			requested: corev1.ResourceList{"limitsMemory": resource.MustParse("1024Mi"), "requestsCpu": resource.MustParse("1")},
+			limit:     corev1.ResourceList{"limitsMemory": resource.MustParse("1Gi"), "requestsCpu": resource.MustParse("1000m")},
+		},
+		{
+			name:      "exceeded extended resource",
+			requested: corev1.ResourceList{"requests.nvidia.com/gpu": resource.MustParse("3"), "pods": resource.MustParse("1")},
+			limit:     corev1.ResourceList{"requests.nvidia.com/gpu": resource.MustParse("2"), "pods": resource.MustParse("1")},
+			exceeded:  "requests.nvidia.com/gpu=3",
+		},
+		{
+			name:      "unlimited resource",
+			requested: corev1.ResourceList{"requests.nvidia.com/gpu": resource.MustParse("3")},
+			limit:     corev1.ResourceList{"pods": resource.MustParse("1")},
+		},
+		{
+			name:      "zero limit",
+			requested: corev1.ResourceList{"pods": resource.MustParse("1")},
+			limit:     corev1.ResourceList{"pods": resource.MustParse("0")},
+			exceeded:  "pods=1",
+		},
+		{
+			name:      "negative request",
+			requested: corev1.ResourceList{"pods": resource.MustParse("-1")},
+			limit:     corev1.ResourceList{"pods": resource.MustParse("1")},
+			exceeded:  "pods=-1",
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			fits, exceeded := Fits(tt.requested, tt.limit)
+			assert.Equal(t, tt.exceeded == "", fits)
+			assert.Equal(t, tt.exceeded, Format(exceeded))
+		})
+	}
+}
+
+// resourceLists generates pairs of ResourceLists sharing resource names, with quantities of various signs and units.
+type resourceLists struct {
+	a, b corev1.ResourceList
+}
+
+func (resourceLists) Generate(r *rand.Rand, _ int) reflect.Value {
+	names := []corev1.ResourceName{"pods", "limitsMemory", "requestsCpu", "requests.nvidia.com/gpu"}
+	suffixes := []string{"", "m", "k", "Mi", "Gi"}
+	quantity := func() resource.Quantity {
+		return resource.MustParse(fmt.Sprintf("%d%s", r.Intn(2001)-1000, suffixes[r.Intn(len(suffixes))]))
+	}
+	lists := resourceLists{a: corev1.ResourceList{}, b: corev1.ResourceList{}}
+	for _, name := range names {
+		if r.Intn(4) != 0 {
+			lists.a[name] = quantity()
+		}
+		if r.Intn(4) != 0 {
+			lists.b[name] = quantity()
+		}
+	}
+	return reflect.ValueOf(lists)
+}
+
+func isNonNegative(list corev1.ResourceList) bool {
+	for _, q := range list {
+		if q.Sign() < 0 {
+			return false
+		}
+	}
+	return true
+}
+
+func TestFitsProperties(t *testing.T) {
+	t.Parallel()
+	config := &quick.Config{MaxCount: 500}
+
+	// a non-negative quota fits in itself
+	require.NoError(t, quick.Check(func(lists resourceLists) bool {
+		fits, _ := Fits(lists.a, lists.a)
+		return fits == isNonNegative(lists.a)
+	}, config))
+
+	// adding a non-negative quota to the limit never makes a fitting quota exceed it
+	require.NoError(t, quick.Check(func(lists resourceLists) bool {
+		if !isNonNegative(lists.b) {
+			return true
+		}
+		fits, _ := Fits(lists.a, lists.a)
+		fitsMore, _ := Fits(lists.a, Add(lists.a, lists.b))
+		return !fits || fitsMore
+	}, config))
+
+	// the exceeded resources are exactly those above their limit or negative
+	require.NoError(t, quick.Check(func(lists resourceLists) bool {
+		fits, exceeded := Fits(lists.a, lists.b)
+		for name, q := range lists.a {
+			limit, limited := lists.b[name]
+			_, isExceeded := exceeded[name]
+			if isExceeded != (q.Sign() < 0 || limited && q.Cmp(limit) > 0) {
+				return false
+			}
+		}
+		return fits == (len(exceeded) == 0)
+	}, config))
+
+	// quantities are compared regardless of their units
+	require.NoError(t, quick.Check(func(lists resourceLists) bool {
+		converted := corev1.ResourceList{}
+		for name, q := range lists.a {
+			converted[name] = *resource.NewMilliQuantity(q.MilliValue(), resource.DecimalSI)
+		}
+		fits, _ := Fits(lists.a, converted)
+		return fits == isNonNegative(lists.a)
+	}, config))
+}
+
+func TestNodePorts(t *testing.T) {
+	t.Parallel()
+	ports := []corev1.ServicePort{{Port: 80}, {Port: 443, NodePort: 30443}}
+	disabled := false
+	tests := []struct {
+		name string
+		spec corev1.ServiceSpec
+		want int64
+	}{
+		{name: "cluster ip", spec: corev1.ServiceSpec{Type: corev1.ServiceTypeClusterIP, Ports: ports}},
+		{name: "external name", spec: corev1.ServiceSpec{Type: corev1.ServiceTypeExternalName}},
+		{name: "node port", spec: corev1.ServiceSpec{Type: corev1.ServiceTypeNodePort, Ports: ports}, want: 2},
+		{name: "load balancer", spec: corev1.ServiceSpec{Type: corev1.ServiceTypeLoadBalancer, Ports: ports}, want: 2},
+		{
+			name: "load balancer without node ports",
+			spec: corev1.ServiceSpec{Type: corev1.ServiceTypeLoadBalancer, Ports: ports, AllocateLoadBalancerNodePorts: &disabled},
+			want: 1,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			assert.Equal(t, tt.want, NodePorts(&corev1.Service{Spec: tt.spec}))
+		})
+	}
+}
@@ -19,6 +19,8 @@ const (
 type PRTBRuleResolver struct {
 	ProjectRoleTemplateBindings v3.ProjectRoleTemplateBindingCache
 	RoleTemplateResolver        *auth.RoleTemplateResolver
+	// excludedBinding is the namespace/name of a PRTB whose rules are ignored when resolving.
+	excludedBinding string
 }
 
 // NewPRTBRuleResolver will create a new PRTBRuleResolver.
@@ -32,6 +34,16 @@ func NewPRTBRuleResolver(prtbCache v3.ProjectRoleTemplateBindingCache, roleTempl
 	}
 }
 
+// ExcludingBinding returns a copy of the resolver which ignores the rules granted by the PRTB with the given namespace and name.
+// The copy shares the underlying cache and indexer, so it is safe to call this for every request.
+func (p *PRTBRuleResolver) ExcludingBinding(namespace, name string) *PRTBRuleResolver {
+	return &PRTBRuleResolver{
+		ProjectRoleTemplateBindings: p.ProjectRoleTemplateBindings,
+		RoleTemplateResolver:        p.RoleTemplateResolver,
+		excludedBinding:             namespace + "/" + name,
+	}
+}
+
 // GetRoleReferenceRules is used to find which roles are granted by a rolebinding/clusterrolebinding. Since we don't
 // use these primitives to refer to role templates return empty list.
 func (p *PRTBRuleResolver) GetRoleReferenceRules(rbacv1.RoleRef, string) ([]rbacv1.PolicyRule, error) {
@@ -59,6 +71,9 @@ func (p *PRTBRuleResolver) VisitRulesFor(user user.Info, namespace string, visit
 			continue
 		}
 		for _, prtb := range prtbs {
+			if p.isExcluded(prtb) {
+				continue
+			}
 			rtRules, err := p.RoleTemplateResolver.RulesFromTemplateName(prtb.RoleTemplateName)
 			if !visitRules(nil, rtRules, err, visitor) {
 				return
@@ -73,6 +88,9 @@ func (p *PRTBRuleResolver) VisitRulesFor(user user.Info, namespace string, visit
 		return
 	}
 	for _, prtb := range prtbs {
+		if p.isExcluded(prtb) {
+			continue
+		}
 		rtRules, err := p.RoleTemplateResolver.RulesFromTemplateName(prtb.RoleTemplateName)
 		if !visitRules(nil, rtRules, err, visitor) {
 			return
@@ -80,6 +98,10 @@ func (p *PRTBRuleResolver) VisitRulesFor(user user.Info, namespace string, visit
 	}
 }
 
+func (p *PRTBRuleResolver) isExcluded(prtb *apisv3.ProjectRoleTemplateBinding) bool {
+	return p.excludedBinding != "" && p.excludedBinding == prtb.Namespace+"/"+prtb.Name
+}
+
 func prtbBySubject(prtb *apisv3.ProjectRoleTemplateBinding) ([]string, error) {
 	namespace, ok := namespaceFromProject(prtb.ProjectName)
 	if !ok {
@@ -270,6 +270,31 @@ func (p *PRTBResolverSuite) TestPRTBRuleResolver() {
 	}
 }
 
+func (p *PRTBResolverSuite) TestPRTBRuleResolverExcludingBinding() {
+	resolver := p.NewTestPRTBResolver()
+	namespace, ok := namespaceFromProject(p.groupAdminPRTB.ProjectName)
+	p.Require().True(ok, "failed to split project namespace from project name")
+
+	excluding := resolver.ExcludingBinding(p.groupAdminPRTB.Namespace, p.groupAdminPRTB.Name)
+	gotRules, err := excluding.RulesFor(NewUserInfo("invalidUser", adminGroup), namespace)
+	p.NoError(err, "unexpected error")
+	p.Empty(gotRules, "rules from the excluded binding should not be returned")
+
+	gotRules, err = excluding.RulesFor(NewUserInfo("invalidUser", authGroup, adminGroup), namespace)
+	p.NoError(err, "unexpected error")
+	wantRules := Rules(copySlices(p.readRT.Rules, p.writeRT.Rules))
+	if !wantRules.Equal(gotRules) {
+		p.Fail("List of rules did not match", "wanted=%+v got=%+v", wantRules, gotRules)
+	}
+
+	// the original resolver must be unaffected
+	gotRules, err = resolver.RulesFor(NewUserInfo("invalidUser", adminGroup), namespace)
+	p.NoError(err, "unexpected error")
+	if !Rules(p.adminRT.Rules).Equal(gotRules) {
+		p.Fail("List of rules did not match", "wanted=%+v got=%+v", p.adminRT.Rules, gotRules)
+	}
+}
+
 func (p *PRTBResolverSuite) NewTestPRTBResolver() *PRTBRuleResolver {
 	ctrl := gomock.NewController(p.T())
 	bindings := []*apisv3.ProjectRoleTemplateBinding{p.user1AdminPRTB, p.user1AReadNS2PRTB, p.user1InvalidNS2PRTB,
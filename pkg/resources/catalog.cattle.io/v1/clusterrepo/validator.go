@@ -4,12 +4,16 @@ package clusterrepo
 import (
 	"errors"
 	"fmt"
+	"net/url"
 
 	catalogv1 "github.com/rancher/rancher/pkg/apis/catalog.cattle.io/v1"
 	"github.com/rancher/webhook/pkg/admission"
 	v1 "github.com/rancher/webhook/pkg/generated/objects/catalog.cattle.io/v1"
+	corev1controller "github.com/rancher/wrangler/v3/pkg/generated/controllers/core/v1"
 	admissionv1 "k8s.io/api/admission/v1"
 	admissionregistrationv1 "k8s.io/api/admissionregistration/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	"k8s.io/apimachinery/pkg/util/validation/field"
 
 	"k8s.io/apimachinery/pkg/runtime/schema"
@@ -21,9 +25,16 @@ var gvr = schema.GroupVersionResource{
 	Resource: "clusterrepos",
 }
 
+// allowedURLSchemes are the schemes accepted in spec.url of a helm ClusterRepo.
+var allowedURLSchemes = []string{"https", "oci"}
+
 // NewValidator will create a newly allocated Validator.
-func NewValidator() *Validator {
-	return &Validator{}
+func NewValidator(secretCache corev1controller.SecretCache) *Validator {
+	return &Validator{
+		admitter: admitter{
+			secretCache: secretCache,
+		},
+	}
 }
 
 // Validator conforms to the webhook.Handler interface and is used for validating request for clusterrepos.
@@ -55,6 +66,7 @@ func (v *Validator) Admitters() []admission.Admitter {
 }
 
 type admitter struct {
+	secretCache corev1controller.SecretCache
 }
 
 // Admit is the entrypoint for the validator. Admit will return an error if it is unable to process the request.
@@ -63,24 +75,32 @@ func (a *admitter) Admit(request *admission.Request) (*admissionv1.AdmissionResp
 	fieldPath := field.NewPath("clusterrepo")
 
 	if request.Operation == admissionv1.Create || request.Operation == admissionv1.Update {
-		newClusterRepo, err := v1.ClusterRepoFromRequest(&request.AdmissionRequest)
+		oldClusterRepo, newClusterRepo, err := v1.ClusterRepoOldAndNewFromRequest(&request.AdmissionRequest)
 		if err != nil {
 			return nil, fmt.Errorf("failed to get clusterRepo from request: %w", err)
 		}
 
 		var fieldErr *field.Error
-		if err := a.validateFields(newClusterRepo, fieldPath); err != nil {
+		if err := a.validateFields(oldClusterRepo, newClusterRepo, fieldPath); err != nil {
 			if errors.As(err, &fieldErr) {
 				return admission.ResponseBadRequest(fieldErr.Error()), nil
 			}
 			return nil, fmt.Errorf("failed to validate fields on ClusterRepo: %w", err)
 		}
+
+		if request.Operation == admissionv1.Update {
+			if err := validateRepoTypeUnchanged(oldClusterRepo, newClusterRepo, fieldPath); err != nil {
+				return admission.ResponseBadRequest(err.Error()), nil
+			}
+		}
 	}
 
 	return admission.ResponseAllowed(), nil
 }
 
-func (a *admitter) validateFields(newClusterrepo *catalogv1.ClusterRepo, fieldPath *field.Path) error {
+// validateFields checks the fields of the ClusterRepo. The URL and the client secret are only checked when they are set
+// or changed, so that existing ClusterRepos which predate the checks, or whose secret was deleted, can still be updated.
+func (a *admitter) validateFields(oldClusterRepo, newClusterrepo *catalogv1.ClusterRepo, fieldPath *field.Path) error {
 	// Both GitRepo and URL can't be specified simultaneously.
 	if newClusterrepo.Spec.URL != "" && newClusterrepo.Spec.GitRepo != "" {
 		return field.Forbidden(fieldPath, "both fields spec.URL and spec.GitRepo cannot be specified simultaneously")
@@ -91,5 +111,66 @@ func (a *admitter) validateFields(newClusterrepo *catalogv1.ClusterRepo, fieldPa
 		return field.Forbidden(fieldPath, "either of fields spec.URL or spec.GitRepo must be specified")
 	}
 
+	if newClusterrepo.Spec.URL != "" && newClusterrepo.Spec.URL != oldClusterRepo.Spec.URL {
+		if err := validateURL(newClusterrepo.Spec.URL, fieldPath.Child("spec", "url")); err != nil {
+			return err
+		}
+	}
+
+	if equality.Semantic.DeepEqual(oldClusterRepo.Spec.ClientSecret, newClusterrepo.Spec.ClientSecret) {
+		return nil
+	}
+	return a.validateClientSecret(newClusterrepo.Spec.ClientSecret, fieldPath.Child("spec", "clientSecret"))
+}
+
+// validateURL checks that the helm repository URL can be parsed and uses one of the allowed schemes.
+func validateURL(repoURL string, fieldPath *field.Path) error {
+	parsed, err := url.Parse(repoURL)
+	if err != nil {
+		return field.Invalid(fieldPath, repoURL, fmt.Sprintf("failed to parse URL: %s", err.Error()))
+	}
+	if parsed.Host == "" {
+		return field.Invalid(fieldPath, repoURL, "URL must contain a host")
+	}
+	for _, scheme := range allowedURLSchemes {
+		if parsed.Scheme == scheme {
+			return nil
+		}
+	}
+	return field.NotSupported(fieldPath.Child("scheme"), parsed.Scheme, allowedURLSchemes)
+}
+
+// validateClientSecret checks that the referenced client secret exists. Since a ClusterRepo is not namespaced, the
+// namespace of the secret must be provided in the reference.
+func (a *admitter) validateClientSecret(secretRef *catalogv1.SecretReference, fieldPath *field.Path) error {
+	if secretRef == nil {
+		return nil
+	}
+	if secretRef.Name == "" {
+		return field.Required(fieldPath.Child("name"), "name of the client secret must be specified")
+	}
+	if secretRef.Namespace == "" {
+		return field.Required(fieldPath.Child("namespace"), "namespace of the client secret must be specified")
+	}
+	_, err := a.secretCache.Get(secretRef.Namespace, secretRef.Name)
+	if apierrors.IsNotFound(err) {
+		return field.NotFound(fieldPath, fmt.Sprintf("%s/%s", secretRef.Namespace, secretRef.Name))
+	}
+	if err != nil {
+		return fmt.Errorf("failed to get client secret %s/%s: %w", secretRef.Namespace, secretRef.Name, err)
+	}
 	return nil
 }
+
+// validateRepoTypeUnchanged prevents switching a ClusterRepo between a git repository and a helm repository.
+func validateRepoTypeUnchanged(oldClusterRepo, newClusterRepo *catalogv1.ClusterRepo, fieldPath *field.Path) *field.Error {
+	oldIsGit := oldClusterRepo.Spec.GitRepo != ""
+	newIsGit := newClusterRepo.Spec.GitRepo != ""
+	if oldIsGit == newIsGit {
+		return nil
+	}
+	if oldIsGit {
+		return field.Forbidden(fieldPath.Child("spec", "url"), "cannot change a git repository into a helm repository")
+	}
+	return field.Forbidden(fieldPath.Child("spec", "gitRepo"), "cannot change a helm repository into a git repository")
+}
@@ -3,14 +3,20 @@ package clusterrepo
 import (
 	"context"
 	"encoding/json"
+	"fmt"
 	"testing"
 
 	catalogv1 "github.com/rancher/rancher/pkg/apis/catalog.cattle.io/v1"
 	"github.com/rancher/webhook/pkg/admission"
+	"github.com/rancher/wrangler/v3/pkg/generic/fake"
 	"github.com/stretchr/testify/assert"
+	"go.uber.org/mock/gomock"
 	admissionv1 "k8s.io/api/admission/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
 )
 
 func TestClusterRepoValidation(t *testing.T) {
@@ -102,14 +108,15 @@ func TestClusterRepoValidation(t *testing.T) {
 		},
 	}
 
-	validator := NewValidator()
+	ctrl := gomock.NewController(t)
+	validator := NewValidator(fake.NewMockCacheInterface[*corev1.Secret](ctrl))
 	admitters := validator.Admitters()
 
 	for _, test := range tests {
 		test := test
 		t.Run(test.name, func(t *testing.T) {
 			t.Parallel()
-			req, err := createClusterRepo(test.clusterRepo, test.operation, false)
+			req, err := createClusterRepo(test.clusterRepo, test.clusterRepo, test.operation, false)
 			assert.NoError(t, err)
 			assert.Len(t, admitters, 1)
 			response, err := admitters[0].Admit(req)
@@ -119,7 +126,230 @@ func TestClusterRepoValidation(t *testing.T) {
 	}
 }
 
-func createClusterRepo(newClusterRepo *catalogv1.ClusterRepo, operation admissionv1.Operation, dryRun bool) (*admission.Request, error) {
+func TestClusterRepoURLValidation(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name        string
+		url         string
+		wantAllowed bool
+	}{
+		{
+			name:        "https URL",
+			url:         "https://charts.example.com/stable",
+			wantAllowed: true,
+		},
+		{
+			name:        "oci URL",
+			url:         "oci://registry.example.com/charts",
+			wantAllowed: true,
+		},
+		{
+			name:        "plain http URL",
+			url:         "http://charts.example.com",
+			wantAllowed: false,
+		},
+		{
+			name:        "unsupported scheme",
+			url:         "ftp://charts.example.com",
+			wantAllowed: false,
+		},
+		{
+			name:        "missing scheme",
+			url:         "charts.example.com",
+			wantAllowed: false,
+		},
+		{
+			name:        "unparsable URL",
+			url:         "https://charts.example.com/%zz",
+			wantAllowed: false,
+		},
+	}
+
+	ctrl := gomock.NewController(t)
+	admitters := NewValidator(fake.NewMockCacheInterface[*corev1.Secret](ctrl)).Admitters()
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+			repo := &catalogv1.ClusterRepo{Spec: catalogv1.RepoSpec{URL: test.url}}
+			req, err := createClusterRepo(nil, repo, admissionv1.Create, false)
+			assert.NoError(t, err)
+			response, err := admitters[0].Admit(req)
+			assert.NoError(t, err)
+			assert.Equal(t, test.wantAllowed, response.Allowed)
+		})
+	}
+}
+
+func TestClusterRepoClientSecretValidation(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name         string
+		clientSecret *catalogv1.SecretReference
+		secretErr    error
+		wantAllowed  bool
+		wantErr      bool
+	}{
+		{
+			name:        "no client secret",
+			wantAllowed: true,
+		},
+		{
+			name:         "existing client secret",
+			clientSecret: &catalogv1.SecretReference{Name: "repo-auth", Namespace: "cattle-system"},
+			wantAllowed:  true,
+		},
+		{
+			name:         "missing client secret",
+			clientSecret: &catalogv1.SecretReference{Name: "repo-auth", Namespace: "cattle-system"},
+			secretErr:    apierrors.NewNotFound(schema.GroupResource{Resource: "secrets"}, "repo-auth"),
+			wantAllowed:  false,
+		},
+		{
+			name:         "client secret without namespace",
+			clientSecret: &catalogv1.SecretReference{Name: "repo-auth"},
+			wantAllowed:  false,
+		},
+		{
+			name:         "client secret without name",
+			clientSecret: &catalogv1.SecretReference{Namespace: "cattle-system"},
+			wantAllowed:  false,
+		},
+		{
+			name:         "failure getting client secret",
+			clientSecret: &catalogv1.SecretReference{Name: "repo-auth", Namespace: "cattle-system"},
+			secretErr:    fmt.Errorf("server unavailable"),
+			wantErr:      true,
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+			ctrl := gomock.NewController(t)
+			secretCache := fake.NewMockCacheInterface[*corev1.Secret](ctrl)
+			if test.clientSecret != nil && test.clientSecret.Name != "" && test.clientSecret.Namespace != "" {
+				secretCache.EXPECT().Get(test.clientSecret.Namespace, test.clientSecret.Name).Return(&corev1.Secret{}, test.secretErr)
+			}
+			repo := &catalogv1.ClusterRepo{
+				Spec: catalogv1.RepoSpec{
+					URL:          "https://charts.example.com",
+					ClientSecret: test.clientSecret,
+				},
+			}
+			req, err := createClusterRepo(nil, repo, admissionv1.Create, false)
+			assert.NoError(t, err)
+			response, err := NewValidator(secretCache).Admitters()[0].Admit(req)
+			if test.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, test.wantAllowed, response.Allowed)
+		})
+	}
+}
+
+func TestClusterRepoTypeChange(t *testing.T) {
+	t.Parallel()
+
+	gitRepo := &catalogv1.ClusterRepo{Spec: catalogv1.RepoSpec{GitRepo: "https://github.com/rancher/charts"}}
+	helmRepo := &catalogv1.ClusterRepo{Spec: catalogv1.RepoSpec{URL: "https://charts.example.com"}}
+	otherHelmRepo := &catalogv1.ClusterRepo{Spec: catalogv1.RepoSpec{URL: "oci://registry.example.com/charts"}}
+
+	tests := []struct {
+		name        string
+		oldRepo     *catalogv1.ClusterRepo
+		newRepo     *catalogv1.ClusterRepo
+		wantAllowed bool
+	}{
+		{
+			name:        "git to helm",
+			oldRepo:     gitRepo,
+			newRepo:     helmRepo,
+			wantAllowed: false,
+		},
+		{
+			name:        "helm to git",
+			oldRepo:     helmRepo,
+			newRepo:     gitRepo,
+			wantAllowed: false,
+		},
+		{
+			name:        "helm URL changed",
+			oldRepo:     helmRepo,
+			newRepo:     otherHelmRepo,
+			wantAllowed: true,
+		},
+		{
+			name:        "git unchanged",
+			oldRepo:     gitRepo,
+			newRepo:     gitRepo,
+			wantAllowed: true,
+		},
+	}
+
+	ctrl := gomock.NewController(t)
+	admitters := NewValidator(fake.NewMockCacheInterface[*corev1.Secret](ctrl)).Admitters()
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+			req, err := createClusterRepo(test.oldRepo, test.newRepo, admissionv1.Update, false)
+			assert.NoError(t, err)
+			response, err := admitters[0].Admit(req)
+			assert.NoError(t, err)
+			assert.Equal(t, test.wantAllowed, response.Allowed)
+		})
+	}
+}
+
+func TestClusterRepoUnchangedFieldsUpdate(t *testing.T) {
+	t.Parallel()
+
+	legacySecret := &catalogv1.SecretReference{Name: "repo-auth"}
+	legacyRepo := &catalogv1.ClusterRepo{Spec: catalogv1.RepoSpec{URL: "http://charts.example.com", ClientSecret: legacySecret}}
+
+	tests := []struct {
+		name        string
+		newRepo     *catalogv1.ClusterRepo
+		wantAllowed bool
+	}{
+		{
+			name:        "unchanged URL and client secret",
+			newRepo:     &catalogv1.ClusterRepo{Spec: catalogv1.RepoSpec{URL: "http://charts.example.com", ClientSecret: legacySecret, ForceUpdate: &metav1.Time{}}},
+			wantAllowed: true,
+		},
+		{
+			name:    "changed URL",
+			newRepo: &catalogv1.ClusterRepo{Spec: catalogv1.RepoSpec{URL: "http://other.example.com", ClientSecret: legacySecret}},
+		},
+		{
+			name:    "changed client secret",
+			newRepo: &catalogv1.ClusterRepo{Spec: catalogv1.RepoSpec{URL: "http://charts.example.com", ClientSecret: &catalogv1.SecretReference{Name: "other-auth"}}},
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+			ctrl := gomock.NewController(t)
+			req, err := createClusterRepo(legacyRepo, test.newRepo, admissionv1.Update, false)
+			assert.NoError(t, err)
+			response, err := NewValidator(fake.NewMockCacheInterface[*corev1.Secret](ctrl)).Admitters()[0].Admit(req)
+			assert.NoError(t, err)
+			assert.Equal(t, test.wantAllowed, response.Allowed)
+		})
+	}
+}
+
+func createClusterRepo(oldClusterRepo, newClusterRepo *catalogv1.ClusterRepo, operation admissionv1.Operation, dryRun bool) (*admission.Request, error) {
 	gvk := metav1.GroupVersionKind{Group: "catalog.cattle.io", Version: "v1", Kind: "ClusterRepo"}
 	gvr := metav1.GroupVersionResource{Group: "catalog.cattle.io", Version: "v1", Resource: "clusterrepos"}
 	req := &admission.Request{
@@ -143,6 +373,13 @@ func createClusterRepo(newClusterRepo *catalogv1.ClusterRepo, operation admissio
 			return nil, err
 		}
 	}
+	if oldClusterRepo != nil {
+		var err error
+		req.OldObject.Raw, err = json.Marshal(oldClusterRepo)
+		if err != nil {
+			return nil, err
+		}
+	}
 
 	return req, nil
 }
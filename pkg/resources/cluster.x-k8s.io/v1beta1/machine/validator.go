@@ -0,0 +1,93 @@
+// Package machine is used for validating CAPI machines.
+package machine
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/rancher/webhook/pkg/admission"
+	admissionv1 "k8s.io/api/admission/v1"
+	admissionregistrationv1 "k8s.io/api/admissionregistration/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/utils/trace"
+)
+
+var gvr = schema.GroupVersionResource{
+	Group:    "cluster.x-k8s.io",
+	Version:  "v1beta1",
+	Resource: "machines",
+}
+
+const (
+	// deploymentNameLabel is set by CAPI on the machines controlled by a MachineDeployment.
+	deploymentNameLabel = "cluster.x-k8s.io/deployment-name"
+	// machinePoolNameLabel is set by Rancher on the MachineDeployments of the machine pools of provisioning clusters,
+	// and propagated to their machines.
+	machinePoolNameLabel = "rke.cattle.io/rke-machine-pool-name"
+	clusterNameLabel     = "rke.cattle.io/cluster-name"
+	// deleteMachineAnnotation marks the machines which are removed first when their MachineDeployment is scaled down.
+	// Rancher sets it when a specific machine of a pool is scaled down.
+	deleteMachineAnnotation = "cluster.x-k8s.io/delete-machine"
+	// capiControllerNamespace is the namespace of the service accounts of the CAPI controllers deployed by Rancher.
+	capiControllerNamespace = "cattle-provisioning-capi-system"
+)
+
+// Validator validates machines.
+type Validator struct {
+	admitter admitter
+}
+
+// NewValidator returns a new Validator for machines.
+func NewValidator() *Validator {
+	return &Validator{}
+}
+
+// GVR returns the GroupVersionResource for this CRD.
+func (v *Validator) GVR() schema.GroupVersionResource {
+	return gvr
+}
+
+// Operations returns list of operations handled by this validator.
+func (v *Validator) Operations() []admissionregistrationv1.OperationType {
+	return []admissionregistrationv1.OperationType{admissionregistrationv1.Delete}
+}
+
+// ValidatingWebhook returns the ValidatingWebhook used for this CRD.
+func (v *Validator) ValidatingWebhook(clientConfig admissionregistrationv1.WebhookClientConfig) []admissionregistrationv1.ValidatingWebhook {
+	valWebhook := admission.NewDefaultValidatingWebhook(v, clientConfig, admissionregistrationv1.NamespacedScope, v.Operations())
+	valWebhook.FailurePolicy = admission.Ptr(admissionregistrationv1.Ignore)
+	return []admissionregistrationv1.ValidatingWebhook{*valWebhook}
+}
+
+// Admitters returns the admitter objects used to validate machines.
+func (v *Validator) Admitters() []admission.Admitter {
+	return []admission.Admitter{&v.admitter}
+}
+
+type admitter struct{}
+
+// Admit handles the webhook admission request sent to this webhook.
+func (a *admitter) Admit(request *admission.Request) (*admissionv1.AdmissionResponse, error) {
+	listTrace := trace.New("machineValidator Admit", trace.Field{Key: "user", Value: request.UserInfo.Username})
+	defer listTrace.LogIfLong(admission.SlowTraceDuration)
+
+	if admission.IsController(request) || admission.IsServiceAccountOfNamespace(request.UserInfo, capiControllerNamespace) {
+		return admission.ResponseAllowed(), nil
+	}
+
+	machine := &metav1.PartialObjectMetadata{}
+	if err := json.Unmarshal(request.OldObject.Raw, machine); err != nil {
+		return nil, fmt.Errorf("failed to decode machine from request: %w", err)
+	}
+	pool, managed := machine.Labels[machinePoolNameLabel]
+	if !managed || machine.Labels[deploymentNameLabel] == "" {
+		return admission.ResponseAllowed(), nil
+	}
+	if _, ok := machine.Annotations[deleteMachineAnnotation]; ok {
+		return admission.ResponseAllowed(), nil
+	}
+	return admission.ResponseBadRequest(fmt.Sprintf("machine %s belongs to the machine pool %s of cluster %s, which is managed by Rancher. "+
+		"Scale the machine pool down instead, or set the %s annotation on the machine before deleting it",
+		machine.Name, pool, machine.Labels[clusterNameLabel], deleteMachineAnnotation)), nil
+}
@@ -0,0 +1,91 @@
+package machine
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/rancher/webhook/pkg/admission"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	admissionv1 "k8s.io/api/admission/v1"
+	authenticationv1 "k8s.io/api/authentication/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+var machineGVR = metav1.GroupVersionResource{Group: "cluster.x-k8s.io", Version: "v1beta1", Resource: "machines"}
+
+func TestAdmit(t *testing.T) {
+	t.Parallel()
+	poolLabels := map[string]string{
+		deploymentNameLabel:  "test-pool1",
+		machinePoolNameLabel: "pool1",
+		clusterNameLabel:     "test",
+	}
+	tests := []struct {
+		name        string
+		labels      map[string]string
+		annotations map[string]string
+		groups      []string
+		wantAllowed bool
+	}{
+		{
+			name:   "machine of a Rancher managed pool",
+			labels: poolLabels,
+		},
+		{
+			name:        "machine of a Rancher managed pool marked for deletion",
+			labels:      poolLabels,
+			annotations: map[string]string{deleteMachineAnnotation: "true"},
+			wantAllowed: true,
+		},
+		{
+			name:        "machine deleted by the CAPI controllers",
+			labels:      poolLabels,
+			groups:      []string{"system:serviceaccounts:" + capiControllerNamespace},
+			wantAllowed: true,
+		},
+		{
+			name:        "machine deleted by a controller",
+			labels:      poolLabels,
+			groups:      []string{"system:serviceaccounts:kube-system"},
+			wantAllowed: true,
+		},
+		{
+			name:        "machine of another MachineDeployment",
+			labels:      map[string]string{deploymentNameLabel: "other"},
+			wantAllowed: true,
+		},
+		{
+			name:        "machine without a MachineDeployment",
+			labels:      map[string]string{machinePoolNameLabel: "pool1"},
+			wantAllowed: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			machine := metav1.PartialObjectMetadata{
+				TypeMeta:   metav1.TypeMeta{APIVersion: "cluster.x-k8s.io/v1beta1", Kind: "Machine"},
+				ObjectMeta: metav1.ObjectMeta{Name: "test-pool1-abcde", Namespace: "fleet-default", Labels: tt.labels, Annotations: tt.annotations},
+			}
+			raw, err := json.Marshal(machine)
+			require.NoError(t, err)
+			request := &admission.Request{AdmissionRequest: admissionv1.AdmissionRequest{
+				Resource:  machineGVR,
+				Name:      machine.Name,
+				Namespace: machine.Namespace,
+				Operation: admissionv1.Delete,
+				UserInfo:  authenticationv1.UserInfo{Username: "user", Groups: tt.groups},
+				OldObject: runtime.RawExtension{Raw: raw},
+			}}
+
+			response, err := NewValidator().Admitters()[0].Admit(request)
+			require.NoError(t, err)
+			assert.Equal(t, tt.wantAllowed, response.Allowed)
+			if !tt.wantAllowed {
+				assert.Contains(t, response.Result.Message, "machine pool pool1 of cluster test")
+			}
+		})
+	}
+}
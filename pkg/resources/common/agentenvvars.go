@@ -0,0 +1,94 @@
+package common
+
+import (
+	"fmt"
+	"strings"
+
+	controllerv3 "github.com/rancher/webhook/pkg/generated/controllers/management.cattle.io/v3"
+	"github.com/sirupsen/logrus"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+)
+
+const (
+	// AgentEnvVarsDenyListSetting is the name of the setting holding a comma separated list of env var names which
+	// can't be set in the agentEnvVars of clusters. A name ending with "*" matches every env var with that prefix.
+	AgentEnvVarsDenyListSetting = "agent-env-vars-deny-list"
+	// AgentEnvVarsAllowListSetting is the name of the setting holding a comma separated list of env var names which
+	// are allowed in the agentEnvVars of clusters even if they match the deny-list, in the same format.
+	AgentEnvVarsAllowListSetting = "agent-env-vars-allow-list"
+)
+
+// ParseEnvVarNamePatterns parses a comma separated list of env var names, each optionally ending with "*" to match
+// every env var with that prefix.
+func ParseEnvVarNamePatterns(value string) ([]string, error) {
+	var patterns []string
+	for _, pattern := range strings.Split(value, ",") {
+		pattern = strings.TrimSpace(pattern)
+		if pattern == "" {
+			continue
+		}
+		if prefix, isPrefix := strings.CutSuffix(pattern, "*"); !isPrefix || prefix != "" {
+			if errs := validation.IsEnvVarName(prefix); len(errs) > 0 {
+				return nil, fmt.Errorf("invalid env var name %q: %s", pattern, strings.Join(errs, ", "))
+			}
+		}
+		patterns = append(patterns, pattern)
+	}
+	return patterns, nil
+}
+
+// ValidateAgentEnvVarNames checks that none of the newNames, the names of the agentEnvVars of a cluster, are denied by
+// the agent-env-vars-deny-list setting without being allowed by the agent-env-vars-allow-list setting. Names already
+// in oldNames aren't checked, so that clusters can still be updated after a name is added to the deny-list.
+func ValidateAgentEnvVarNames(settingCache controllerv3.SettingCache, path *field.Path, oldNames, newNames []string) (field.ErrorList, error) {
+	denyList, err := getEnvVarNamePatterns(settingCache, AgentEnvVarsDenyListSetting)
+	if err != nil || len(denyList) == 0 {
+		return nil, err
+	}
+	allowList, err := getEnvVarNamePatterns(settingCache, AgentEnvVarsAllowListSetting)
+	if err != nil {
+		return nil, err
+	}
+	existing := make(map[string]bool, len(oldNames))
+	for _, name := range oldNames {
+		existing[name] = true
+	}
+	var errs field.ErrorList
+	for i, name := range newNames {
+		if existing[name] || !matchesEnvVarNamePattern(name, denyList) || matchesEnvVarNamePattern(name, allowList) {
+			continue
+		}
+		errs = append(errs, field.Forbidden(path.Index(i).Child("name"),
+			fmt.Sprintf("env var %s is denied by the %s setting", name, AgentEnvVarsDenyListSetting)))
+	}
+	return errs, nil
+}
+
+// getEnvVarNamePatterns returns the env var name patterns held by the setting.
+func getEnvVarNamePatterns(settingCache controllerv3.SettingCache, name string) ([]string, error) {
+	value, err := GetSettingValue(settingCache, name)
+	if err != nil {
+		return nil, err
+	}
+	patterns, err := ParseEnvVarNamePatterns(value)
+	if err != nil {
+		// the setting validator rejects invalid values, don't block provisioning if one got through
+		logrus.Warnf("ignoring invalid %s setting: %v", name, err)
+		return nil, nil
+	}
+	return patterns, nil
+}
+
+func matchesEnvVarNamePattern(name string, patterns []string) bool {
+	for _, pattern := range patterns {
+		if prefix, isPrefix := strings.CutSuffix(pattern, "*"); isPrefix {
+			if strings.HasPrefix(name, prefix) {
+				return true
+			}
+		} else if name == pattern {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,119 @@
+package common
+
+import (
+	"fmt"
+	"testing"
+
+	v3 "github.com/rancher/rancher/pkg/apis/management.cattle.io/v3"
+	"github.com/rancher/wrangler/v3/pkg/generic/fake"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+)
+
+func TestParseEnvVarNamePatterns(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		value   string
+		want    []string
+		wantErr bool
+	}{
+		{value: ""},
+		{value: " HTTP_PROXY_ , CATTLE_*,,*", want: []string{"HTTP_PROXY_", "CATTLE_*", "*"}},
+		{value: "HTTP PROXY", wantErr: true},
+		{value: "CATTLE_*_URL", wantErr: true},
+		{value: "CATTLE_**", wantErr: true},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.value, func(t *testing.T) {
+			t.Parallel()
+			got, err := ParseEnvVarNamePatterns(tt.value)
+			if tt.wantErr {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestValidateAgentEnvVarNames(t *testing.T) {
+	t.Parallel()
+	notFound := apierrors.NewNotFound(schema.GroupResource{Group: "management.cattle.io", Resource: "settings"}, "")
+	tests := []struct {
+		name       string
+		denyList   string
+		allowList  string
+		settingErr error
+		oldNames   []string
+		newNames   []string
+		wantFields []string
+		wantErr    bool
+	}{
+		{
+			name:     "no deny-list",
+			newNames: []string{"CATTLE_SERVER"},
+		},
+		{
+			name:       "settings not found",
+			settingErr: notFound,
+			newNames:   []string{"CATTLE_SERVER"},
+		},
+		{
+			name:       "setting error",
+			settingErr: fmt.Errorf("cache error"),
+			newNames:   []string{"CATTLE_SERVER"},
+			wantErr:    true,
+		},
+		{
+			name:       "denied names and prefixes",
+			denyList:   "HTTP_PROXY_,CATTLE_*",
+			newNames:   []string{"HTTP_PROXY", "HTTP_PROXY_", "CATTLE_SERVER", "NO_PROXY", "CATTLE_CA_CHECKSUM"},
+			wantFields: []string{"spec.agentEnvVars[1].name", "spec.agentEnvVars[2].name", "spec.agentEnvVars[4].name"},
+		},
+		{
+			name:       "allowed names",
+			denyList:   "CATTLE_*",
+			allowList:  "CATTLE_AGENT_LOGLEVEL,CATTLE_AGENT_*",
+			newNames:   []string{"CATTLE_AGENT_LOGLEVEL", "CATTLE_AGENT_BINARY_URL", "CATTLE_SERVER"},
+			wantFields: []string{"spec.agentEnvVars[2].name"},
+		},
+		{
+			name:     "existing names",
+			denyList: "CATTLE_*",
+			oldNames: []string{"CATTLE_SERVER"},
+			newNames: []string{"CATTLE_SERVER"},
+		},
+		{
+			name:     "invalid deny-list",
+			denyList: "CATTLE *",
+			newNames: []string{"CATTLE_SERVER"},
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			settingCache := fake.NewMockNonNamespacedCacheInterface[*v3.Setting](gomock.NewController(t))
+			settingCache.EXPECT().Get(AgentEnvVarsDenyListSetting).Return(&v3.Setting{Value: tt.denyList}, tt.settingErr).AnyTimes()
+			settingCache.EXPECT().Get(AgentEnvVarsAllowListSetting).Return(&v3.Setting{Value: tt.allowList}, tt.settingErr).AnyTimes()
+
+			errs, err := ValidateAgentEnvVarNames(settingCache, field.NewPath("spec", "agentEnvVars"), tt.oldNames, tt.newNames)
+			if tt.wantErr {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			var fields []string
+			for _, fieldErr := range errs {
+				fields = append(fields, fieldErr.Field)
+			}
+			assert.Equal(t, tt.wantFields, fields)
+		})
+	}
+}
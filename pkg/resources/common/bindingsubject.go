@@ -0,0 +1,112 @@
+package common
+
+import (
+	"fmt"
+	"strings"
+
+	controllerv3 "github.com/rancher/webhook/pkg/generated/controllers/management.cattle.io/v3"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+)
+
+// BindingSubjectValidator verifies that the user and group principal bound by a role template binding exist, when the
+// BindingSubjectValidationFeature is enabled.
+type BindingSubjectValidator struct {
+	featureCache    controllerv3.FeatureCache
+	userCache       controllerv3.UserCache
+	authConfigCache controllerv3.AuthConfigCache
+}
+
+// NewBindingSubjectValidator returns a new BindingSubjectValidator.
+func NewBindingSubjectValidator(featureCache controllerv3.FeatureCache, userCache controllerv3.UserCache,
+	authConfigCache controllerv3.AuthConfigCache) *BindingSubjectValidator {
+	return &BindingSubjectValidator{
+		featureCache:    featureCache,
+		userCache:       userCache,
+		authConfigCache: authConfigCache,
+	}
+}
+
+// Validate returns a field.Error if the user with the given name does not exist, or if the auth provider of the group
+// principal does not exist or is disabled. Group principals can not be resolved without querying their auth provider,
+// so only their provider is verified. Empty names are not verified. Nothing is verified if the validator is nil or
+// the BindingSubjectValidationFeature is disabled.
+func (b *BindingSubjectValidator) Validate(userName, groupPrincipalName string, fldPath *field.Path) (*field.Error, error) {
+	if b == nil || (userName == "" && groupPrincipalName == "") {
+		return nil, nil
+	}
+	enabled, err := IsFeatureEnabled(b.featureCache, BindingSubjectValidationFeature)
+	if err != nil || !enabled {
+		return nil, err
+	}
+
+	if userName != "" {
+		if _, err := b.userCache.Get(userName); err != nil {
+			if apierrors.IsNotFound(err) {
+				return field.Invalid(fldPath.Child("userName"), userName, "the referenced user was not found"), nil
+			}
+			return nil, fmt.Errorf("failed to get user %s: %w", userName, err)
+		}
+	}
+
+	if groupPrincipalName != "" {
+		provider, ok := PrincipalProvider(groupPrincipalName)
+		if !ok {
+			return field.Invalid(fldPath.Child("groupPrincipalName"), groupPrincipalName,
+				"must be a principal name of the form <provider>_<type>://<id>"), nil
+		}
+		authConfig, err := b.authConfigCache.Get(provider)
+		if err != nil {
+			if apierrors.IsNotFound(err) {
+				return field.Invalid(fldPath.Child("groupPrincipalName"), groupPrincipalName,
+					fmt.Sprintf("auth provider %s was not found", provider)), nil
+			}
+			return nil, fmt.Errorf("failed to get auth config %s: %w", provider, err)
+		}
+		if !authConfig.Enabled {
+			return field.Invalid(fldPath.Child("groupPrincipalName"), groupPrincipalName,
+				fmt.Sprintf("auth provider %s is not enabled", provider)), nil
+		}
+	}
+	return nil, nil
+}
+
+// PrincipalProvider returns the name of the auth provider of a principal name, such as "github" for
+// "github_team://1234" or "local" for "local://u-abcde".
+func PrincipalProvider(principalName string) (string, bool) {
+	scheme, id, found := strings.Cut(principalName, "://")
+	if !found || scheme == "" || id == "" {
+		return "", false
+	}
+	provider, _, _ := strings.Cut(scheme, "_")
+	return provider, provider != ""
+}
+
+// NormalizeGroupPrincipalName returns the group principal name with a canonical prefix, such as
+// "activedirectory_group://" for "ActiveDirectory_Group://", so that bindings of the same group are not duplicated
+// because of how an auth provider or a client spelled the prefix. The provider is named as its AuthConfig, and the
+// principal type is lowercased. The ID of the principal is kept as is, since some providers compare IDs
+// case-sensitively. Names which are not principal names, or whose provider has no AuthConfig, are returned unchanged.
+func NormalizeGroupPrincipalName(authConfigCache controllerv3.AuthConfigCache, groupPrincipalName string) (string, error) {
+	scheme, id, found := strings.Cut(strings.TrimSpace(groupPrincipalName), "://")
+	if !found || id == "" {
+		return groupPrincipalName, nil
+	}
+	provider, principalType, hasType := strings.Cut(strings.ToLower(strings.TrimSpace(scheme)), "_")
+	authConfigs, err := authConfigCache.List(labels.Everything())
+	if err != nil {
+		return "", fmt.Errorf("failed to list auth configs: %w", err)
+	}
+	for _, authConfig := range authConfigs {
+		if !strings.EqualFold(authConfig.Name, provider) {
+			continue
+		}
+		prefix := authConfig.Name
+		if hasType {
+			prefix += "_" + principalType
+		}
+		return prefix + "://" + id, nil
+	}
+	return groupPrincipalName, nil
+}
@@ -0,0 +1,142 @@
+package common
+
+import (
+	"errors"
+	"testing"
+
+	v3 "github.com/rancher/rancher/pkg/apis/management.cattle.io/v3"
+	"github.com/rancher/webhook/pkg/admission"
+	"github.com/rancher/wrangler/v3/pkg/generic/fake"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+)
+
+func TestFeatureEffectiveValue(t *testing.T) {
+	t.Parallel()
+	assert.True(t, FeatureEffectiveValue(&v3.Feature{Status: v3.FeatureStatus{Default: true}}))
+	assert.False(t, FeatureEffectiveValue(&v3.Feature{Spec: v3.FeatureSpec{Value: admission.Ptr(false)}, Status: v3.FeatureStatus{Default: true}}))
+	assert.True(t, FeatureEffectiveValue(&v3.Feature{
+		Spec:   v3.FeatureSpec{Value: admission.Ptr(false)},
+		Status: v3.FeatureStatus{LockedValue: admission.Ptr(true)},
+	}))
+}
+
+func TestPrincipalProvider(t *testing.T) {
+	t.Parallel()
+	tests := map[string]string{
+		"github_team://1234":                     "github",
+		"activedirectory_group://CN=admins,DC=x": "activedirectory",
+		"local://u-abcde":                        "local",
+		"no-scheme":                              "",
+		"github_team://":                         "",
+		"://1234":                                "",
+	}
+	for principal, want := range tests {
+		provider, ok := PrincipalProvider(principal)
+		assert.Equal(t, want, provider, principal)
+		assert.Equal(t, want != "", ok, principal)
+	}
+}
+
+func TestBindingSubjectValidator(t *testing.T) {
+	t.Parallel()
+	fldPath := field.NewPath("binding")
+	notFound := func(resource, name string) error {
+		return apierrors.NewNotFound(schema.GroupResource{Group: "management.cattle.io", Resource: resource}, name)
+	}
+	tests := []struct {
+		name           string
+		featureEnabled *bool
+		userName       string
+		groupPrincipal string
+		wantField      string
+		wantErr        bool
+	}{
+		{name: "feature missing", userName: "u-missing"},
+		{name: "feature disabled", featureEnabled: admission.Ptr(false), userName: "u-missing"},
+		{name: "existing user", featureEnabled: admission.Ptr(true), userName: "u-12345"},
+		{name: "missing user", featureEnabled: admission.Ptr(true), userName: "u-missing", wantField: "binding.userName"},
+		{name: "user lookup failure", featureEnabled: admission.Ptr(true), userName: "u-error", wantErr: true},
+		{name: "enabled provider", featureEnabled: admission.Ptr(true), groupPrincipal: "github_team://1234"},
+		{name: "disabled provider", featureEnabled: admission.Ptr(true), groupPrincipal: "okta_group://admins", wantField: "binding.groupPrincipalName"},
+		{name: "unknown provider", featureEnabled: admission.Ptr(true), groupPrincipal: "gitub_team://1234", wantField: "binding.groupPrincipalName"},
+		{name: "invalid principal", featureEnabled: admission.Ptr(true), groupPrincipal: "admins", wantField: "binding.groupPrincipalName"},
+		{name: "no subject", featureEnabled: admission.Ptr(true)},
+	}
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+			ctrl := gomock.NewController(t)
+			featureCache := fake.NewMockNonNamespacedCacheInterface[*v3.Feature](ctrl)
+			if test.featureEnabled == nil {
+				featureCache.EXPECT().Get(BindingSubjectValidationFeature).Return(nil, notFound("features", BindingSubjectValidationFeature)).AnyTimes()
+			} else {
+				featureCache.EXPECT().Get(BindingSubjectValidationFeature).Return(&v3.Feature{Spec: v3.FeatureSpec{Value: test.featureEnabled}}, nil).AnyTimes()
+			}
+			userCache := fake.NewMockNonNamespacedCacheInterface[*v3.User](ctrl)
+			userCache.EXPECT().Get("u-12345").Return(&v3.User{ObjectMeta: metav1.ObjectMeta{Name: "u-12345"}}, nil).AnyTimes()
+			userCache.EXPECT().Get("u-missing").Return(nil, notFound("users", "u-missing")).AnyTimes()
+			userCache.EXPECT().Get("u-error").Return(nil, errors.New("unexpected error")).AnyTimes()
+			authConfigCache := fake.NewMockNonNamespacedCacheInterface[*v3.AuthConfig](ctrl)
+			authConfigCache.EXPECT().Get("github").Return(&v3.AuthConfig{Enabled: true}, nil).AnyTimes()
+			authConfigCache.EXPECT().Get("okta").Return(&v3.AuthConfig{}, nil).AnyTimes()
+			authConfigCache.EXPECT().Get("gitub").Return(nil, notFound("authconfigs", "gitub")).AnyTimes()
+
+			validator := NewBindingSubjectValidator(featureCache, userCache, authConfigCache)
+			fieldErr, err := validator.Validate(test.userName, test.groupPrincipal, fldPath)
+			if test.wantErr {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			if test.wantField == "" {
+				assert.Nil(t, fieldErr)
+				return
+			}
+			require.NotNil(t, fieldErr)
+			assert.Equal(t, test.wantField, fieldErr.Field)
+		})
+	}
+
+	var nilValidator *BindingSubjectValidator
+	fieldErr, err := nilValidator.Validate("u-missing", "", fldPath)
+	assert.NoError(t, err)
+	assert.Nil(t, fieldErr)
+}
+
+func TestNormalizeGroupPrincipalName(t *testing.T) {
+	t.Parallel()
+	authConfigCache := fake.NewMockNonNamespacedCacheInterface[*v3.AuthConfig](gomock.NewController(t))
+	authConfigCache.EXPECT().List(gomock.Any()).Return([]*v3.AuthConfig{
+		{ObjectMeta: metav1.ObjectMeta{Name: "activedirectory"}},
+		{ObjectMeta: metav1.ObjectMeta{Name: "okta"}},
+		{ObjectMeta: metav1.ObjectMeta{Name: "local"}},
+	}, nil).AnyTimes()
+
+	tests := map[string]string{
+		"activedirectory_group://CN=Admins,DC=x": "activedirectory_group://CN=Admins,DC=x",
+		"ActiveDirectory_Group://CN=Admins,DC=x": "activedirectory_group://CN=Admins,DC=x",
+		" OKTA_GROUP://Admins":                   "okta_group://Admins",
+		"Local://g-abcde":                        "local://g-abcde",
+		"GitHub_Team://1234":                     "GitHub_Team://1234",
+		"admins":                                 "admins",
+		"okta_group://":                          "okta_group://",
+		"ACTIVEDIRECTORY_group://cn=admins,dc=x": "activedirectory_group://cn=admins,dc=x",
+	}
+	for principal, want := range tests {
+		got, err := NormalizeGroupPrincipalName(authConfigCache, principal)
+		require.NoError(t, err, principal)
+		assert.Equal(t, want, got, principal)
+	}
+
+	failingCache := fake.NewMockNonNamespacedCacheInterface[*v3.AuthConfig](gomock.NewController(t))
+	failingCache.EXPECT().List(gomock.Any()).Return(nil, errors.New("unexpected error"))
+	_, err := NormalizeGroupPrincipalName(failingCache, "okta_group://admins")
+	assert.Error(t, err)
+}
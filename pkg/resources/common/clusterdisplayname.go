@@ -0,0 +1,22 @@
+package common
+
+import (
+	"fmt"
+	"strconv"
+)
+
+// UniqueClusterDisplayNamesSetting is the name of the setting enabling the uniqueness check of the display names of
+// management clusters. An empty value means the check is disabled.
+const UniqueClusterDisplayNamesSetting = "unique-cluster-display-names"
+
+// ParseUniqueClusterDisplayNames parses the value of the unique-cluster-display-names setting.
+func ParseUniqueClusterDisplayNames(value string) (bool, error) {
+	if value == "" {
+		return false, nil
+	}
+	enabled, err := strconv.ParseBool(value)
+	if err != nil {
+		return false, fmt.Errorf("%s is not a boolean", value)
+	}
+	return enabled, nil
+}
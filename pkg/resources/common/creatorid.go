@@ -0,0 +1,65 @@
+package common
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/rancher/webhook/pkg/admission"
+	admissionv1 "k8s.io/api/admission/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+)
+
+// CreatorIDResources are the resources Rancher sets the creatorID annotation on, and uses it to grant the creator
+// access to. A forged creatorID would grant that access to another user.
+var CreatorIDResources = map[schema.GroupVersionResource]bool{
+	{Group: "management.cattle.io", Version: "v3", Resource: "clusters"}:                 true,
+	{Group: "management.cattle.io", Version: "v3", Resource: "projects"}:                 true,
+	{Group: "management.cattle.io", Version: "v3", Resource: "clustertemplates"}:         true,
+	{Group: "management.cattle.io", Version: "v3", Resource: "clustertemplaterevisions"}: true,
+	{Group: "management.cattle.io", Version: "v3", Resource: "nodetemplates"}:            true,
+	{Group: "management.cattle.io", Version: "v3", Resource: "globalroles"}:              true,
+	{Group: "management.cattle.io", Version: "v3", Resource: "roletemplates"}:            true,
+	{Group: "provisioning.cattle.io", Version: "v1", Resource: "clusters"}:               true,
+	{Group: "rke-machine-config.cattle.io", Version: "v1", Resource: "*"}:                true,
+}
+
+// WithCreatorIDCheck adds CheckCreatorIDOnCreate to the handlers of the CreatorIDResources. Other handlers are
+// returned as they are.
+func WithCreatorIDCheck(handlers []admission.ValidatingAdmissionHandler) []admission.ValidatingAdmissionHandler {
+	checked := make([]admission.ValidatingAdmissionHandler, 0, len(handlers))
+	for _, handler := range handlers {
+		if CreatorIDResources[handler.GVR()] {
+			handler = admission.WithAdmitters(handler, admission.AdmitterFunc(admitCreatorID))
+		}
+		checked = append(checked, handler)
+	}
+	return checked
+}
+
+// admitCreatorID denies the creation of objects whose creatorID annotation doesn't match the user creating them.
+func admitCreatorID(request *admission.Request) (*admissionv1.AdmissionResponse, error) {
+	if request.Operation != admissionv1.Create || admission.IsController(request) {
+		return admission.ResponseAllowed(), nil
+	}
+	obj := &metav1.PartialObjectMetadata{}
+	if err := json.Unmarshal(request.Object.Raw, obj); err != nil {
+		return nil, fmt.Errorf("failed to decode object metadata from request: %w", err)
+	}
+	if fieldErr := CheckCreatorIDOnCreate(request, obj); fieldErr != nil {
+		return admission.ResponseBadRequest(fieldErr.Error()), nil
+	}
+	return admission.ResponseAllowed(), nil
+}
+
+// CheckCreatorIDOnCreate checks that the creatorID annotation of a created object, if set, matches the user creating
+// it. Objects without the annotation are allowed, as only some of them are given one by a mutator.
+func CheckCreatorIDOnCreate(request *admission.Request, obj metav1.Object) *field.Error {
+	// CreatorIDAnnotation doesn't have AdminOnly changes, so no SubjectAccessReview is made and it can't fail
+	fieldErrs, _ := creatorIDAnnotations.Validate(request, nil, nil, obj)
+	if len(fieldErrs) > 0 {
+		return fieldErrs[0]
+	}
+	return nil
+}
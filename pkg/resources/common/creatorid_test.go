@@ -0,0 +1,99 @@
+package common
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/rancher/webhook/pkg/admission"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	admissionv1 "k8s.io/api/admission/v1"
+	v1 "k8s.io/api/admissionregistration/v1"
+	authenticationv1 "k8s.io/api/authentication/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+func TestAdmitCreatorID(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name        string
+		operation   admissionv1.Operation
+		userInfo    authenticationv1.UserInfo
+		annotations map[string]string
+		wantAllowed bool
+	}{
+		{
+			name:        "matching creatorID",
+			operation:   admissionv1.Create,
+			userInfo:    authenticationv1.UserInfo{Username: "u-1"},
+			annotations: map[string]string{CreatorIDAnn: "u-1"},
+			wantAllowed: true,
+		},
+		{
+			name:        "no creatorID",
+			operation:   admissionv1.Create,
+			userInfo:    authenticationv1.UserInfo{Username: "u-1"},
+			wantAllowed: true,
+		},
+		{
+			name:        "forged creatorID",
+			operation:   admissionv1.Create,
+			userInfo:    authenticationv1.UserInfo{Username: "u-1"},
+			annotations: map[string]string{CreatorIDAnn: "u-2"},
+		},
+		{
+			name:        "forged creatorID by a controller",
+			operation:   admissionv1.Create,
+			userInfo:    authenticationv1.UserInfo{Username: "system:serviceaccount:cattle-system:rancher", Groups: []string{"system:serviceaccounts:cattle-system"}},
+			annotations: map[string]string{CreatorIDAnn: "u-2"},
+			wantAllowed: true,
+		},
+		{
+			name:        "update is not checked",
+			operation:   admissionv1.Update,
+			userInfo:    authenticationv1.UserInfo{Username: "u-1"},
+			annotations: map[string]string{CreatorIDAnn: "u-2"},
+			wantAllowed: true,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			raw, err := json.Marshal(metav1.PartialObjectMetadata{ObjectMeta: metav1.ObjectMeta{Name: "obj", Annotations: tt.annotations}})
+			require.NoError(t, err)
+			response, err := admitCreatorID(&admission.Request{AdmissionRequest: admissionv1.AdmissionRequest{
+				Operation: tt.operation,
+				UserInfo:  tt.userInfo,
+				Object:    runtime.RawExtension{Raw: raw},
+			}})
+			require.NoError(t, err)
+			assert.Equal(t, tt.wantAllowed, response.Allowed)
+		})
+	}
+}
+
+type fakeHandler struct {
+	gvr schema.GroupVersionResource
+}
+
+func (f fakeHandler) GVR() schema.GroupVersionResource { return f.gvr }
+
+func (f fakeHandler) Operations() []v1.OperationType { return []v1.OperationType{v1.Create} }
+
+func (f fakeHandler) ValidatingWebhook(v1.WebhookClientConfig) []v1.ValidatingWebhook { return nil }
+
+func (f fakeHandler) Admitters() []admission.Admitter { return nil }
+
+func TestWithCreatorIDCheck(t *testing.T) {
+	t.Parallel()
+	tracked := fakeHandler{gvr: schema.GroupVersionResource{Group: "management.cattle.io", Version: "v3", Resource: "projects"}}
+	untracked := fakeHandler{gvr: schema.GroupVersionResource{Group: "management.cattle.io", Version: "v3", Resource: "settings"}}
+
+	handlers := WithCreatorIDCheck([]admission.ValidatingAdmissionHandler{tracked, untracked})
+	require.Len(t, handlers, 2)
+	assert.Len(t, handlers[0].Admitters(), 1)
+	assert.Equal(t, untracked, handlers[1])
+}
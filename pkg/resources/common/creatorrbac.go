@@ -0,0 +1,55 @@
+package common
+
+import (
+	"strings"
+
+	"github.com/rancher/webhook/pkg/admission"
+	controllerv3 "github.com/rancher/webhook/pkg/generated/controllers/management.cattle.io/v3"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	authorizationv1 "k8s.io/client-go/kubernetes/typed/authorization/v1"
+)
+
+// NoCreatorRBACNamespacesSetting is the name of the setting holding a comma separated list of namespaces in which
+// creator RBAC is never applied.
+const NoCreatorRBACNamespacesSetting = "no-creator-rbac-namespaces"
+
+// IsNoCreatorRBACNamespace returns true if the given namespace is listed in the no-creator-rbac-namespaces setting.
+func IsNoCreatorRBACNamespace(settingCache controllerv3.SettingCache, namespace string) (bool, error) {
+	value, err := GetSettingValue(settingCache, NoCreatorRBACNamespacesSetting)
+	if err != nil {
+		return false, err
+	}
+	if value == "" || namespace == "" {
+		return false, nil
+	}
+	for _, ns := range strings.Split(value, ",") {
+		if strings.TrimSpace(ns) == namespace {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// SetNoCreatorRBACAnnotation opts obj out of creator RBAC by setting the no-creator-rbac annotation
+// and removing the creatorId annotation.
+func SetNoCreatorRBACAnnotation(obj metav1.Object) {
+	annotations := obj.GetAnnotations()
+	if annotations == nil {
+		annotations = map[string]string{}
+	}
+	annotations[NoCreatorRBACAnn] = "true"
+	delete(annotations, CreatorIDAnn)
+	obj.SetAnnotations(annotations)
+}
+
+// CheckNoCreatorRBACAllowed checks that the no-creator-rbac annotation is only added to objects outside the
+// no-creator-rbac-namespaces by administrators. oldObj should be nil on create.
+func CheckNoCreatorRBACAllowed(request *admission.Request, sar authorizationv1.SubjectAccessReviewInterface,
+	settingCache controllerv3.SettingCache, oldObj, newObj metav1.Object) (*field.Error, error) {
+	fieldErrs, err := NewProtectedAnnotations(NoCreatorRBACAnnotation(settingCache)).Validate(request, sar, oldObj, newObj)
+	if err != nil || len(fieldErrs) == 0 {
+		return nil, err
+	}
+	return fieldErrs[0], nil
+}
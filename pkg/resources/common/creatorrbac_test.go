@@ -0,0 +1,131 @@
+package common
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	v3 "github.com/rancher/rancher/pkg/apis/management.cattle.io/v3"
+	"github.com/rancher/webhook/pkg/admission"
+	"github.com/rancher/wrangler/v3/pkg/generic/fake"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+	admissionv1 "k8s.io/api/admission/v1"
+	authenticationv1 "k8s.io/api/authentication/v1"
+	authorizationv1 "k8s.io/api/authorization/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	k8fake "k8s.io/client-go/kubernetes/typed/authorization/v1/fake"
+	k8testing "k8s.io/client-go/testing"
+)
+
+func TestCheckNoCreatorRBACAllowed(t *testing.T) {
+	t.Parallel()
+	const (
+		adminUser    = "admin-user"
+		standardUser = "standard-user"
+		errorUser    = "error-user"
+	)
+	withAnnotation := &metav1.ObjectMeta{Annotations: map[string]string{NoCreatorRBACAnn: "true"}}
+	withoutAnnotation := &metav1.ObjectMeta{}
+
+	tests := []struct {
+		name      string
+		username  string
+		namespace string
+		oldObj    metav1.Object
+		newObj    metav1.Object
+		wantField bool
+		wantErr   bool
+	}{
+		{
+			name:      "annotation not set",
+			username:  standardUser,
+			namespace: "fleet-default",
+			newObj:    withoutAnnotation,
+		},
+		{
+			name:      "annotation already set",
+			username:  standardUser,
+			namespace: "fleet-default",
+			oldObj:    withAnnotation,
+			newObj:    withAnnotation,
+		},
+		{
+			name:      "annotation set in a no-creator-rbac namespace",
+			username:  standardUser,
+			namespace: "system-integrations",
+			newObj:    withAnnotation,
+		},
+		{
+			name:      "annotation set by an admin",
+			username:  adminUser,
+			namespace: "fleet-default",
+			newObj:    withAnnotation,
+		},
+		{
+			name:      "annotation set by a non-admin",
+			username:  standardUser,
+			namespace: "fleet-default",
+			newObj:    withAnnotation,
+			wantField: true,
+		},
+		{
+			name:      "annotation added on update by a non-admin",
+			username:  standardUser,
+			namespace: "fleet-default",
+			oldObj:    withoutAnnotation,
+			newObj:    withAnnotation,
+			wantField: true,
+		},
+		{
+			name:      "failure to check if user is an admin",
+			username:  errorUser,
+			namespace: "fleet-default",
+			newObj:    withAnnotation,
+			wantErr:   true,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			settingCache := fake.NewMockNonNamespacedCacheInterface[*v3.Setting](gomock.NewController(t))
+			settingCache.EXPECT().Get(NoCreatorRBACNamespacesSetting).Return(&v3.Setting{
+				ObjectMeta: metav1.ObjectMeta{Name: NoCreatorRBACNamespacesSetting},
+				Value:      "system-integrations",
+			}, nil).AnyTimes()
+			k8Fake := &k8testing.Fake{}
+			k8Fake.AddReactor("create", "subjectaccessreviews", func(action k8testing.Action) (bool, runtime.Object, error) {
+				review := action.(k8testing.CreateActionImpl).GetObject().(*authorizationv1.SubjectAccessReview)
+				if review.Spec.User == errorUser {
+					return true, nil, errors.New("expected test error")
+				}
+				review.Status.Allowed = review.Spec.User == adminUser
+				return true, review, nil
+			})
+			sar := &k8fake.FakeSubjectAccessReviews{Fake: &k8fake.FakeAuthorizationV1{Fake: k8Fake}}
+			request := &admission.Request{
+				AdmissionRequest: admissionv1.AdmissionRequest{
+					Namespace: tt.namespace,
+					UserInfo:  authenticationv1.UserInfo{Username: tt.username},
+				},
+				Context: context.Background(),
+			}
+
+			fieldErr, err := CheckNoCreatorRBACAllowed(request, sar, settingCache, tt.oldObj, tt.newObj)
+			if tt.wantErr {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			if tt.wantField {
+				assert.NotNil(t, fieldErr)
+			} else {
+				assert.Nil(t, fieldErr)
+			}
+		})
+	}
+}
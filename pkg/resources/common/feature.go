@@ -0,0 +1,37 @@
+package common
+
+import (
+	"fmt"
+
+	v3 "github.com/rancher/rancher/pkg/apis/management.cattle.io/v3"
+	controllerv3 "github.com/rancher/webhook/pkg/generated/controllers/management.cattle.io/v3"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+)
+
+// BindingSubjectValidationFeature is the name of the feature enabling the verification that the user and group
+// principal bound by ClusterRoleTemplateBindings and ProjectRoleTemplateBindings exist.
+const BindingSubjectValidationFeature = "binding-subject-validation"
+
+// FeatureEffectiveValue returns the locked value of the feature if set, otherwise its value if set, otherwise its default.
+func FeatureEffectiveValue(feature *v3.Feature) bool {
+	if feature.Status.LockedValue != nil {
+		return *feature.Status.LockedValue
+	}
+	if feature.Spec.Value != nil {
+		return *feature.Spec.Value
+	}
+	return feature.Status.Default
+}
+
+// IsFeatureEnabled returns true if the feature with the given name is enabled.
+// False is returned, without an error, if the feature does not exist.
+func IsFeatureEnabled(featureCache controllerv3.FeatureCache, name string) (bool, error) {
+	feature, err := featureCache.Get(name)
+	if err != nil {
+		if apierrors.IsNotFound(err) {
+			return false, nil
+		}
+		return false, fmt.Errorf("failed to get feature %s: %w", name, err)
+	}
+	return FeatureEffectiveValue(feature), nil
+}
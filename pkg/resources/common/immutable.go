@@ -0,0 +1,147 @@
+package common
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+)
+
+// immutableReason is the detail of the errors of changed immutable fields.
+const immutableReason = "field is immutable"
+
+// ImmutableField declares a field which can't be changed by updates.
+type ImmutableField struct {
+	// Path is the path of the field in the JSON representation of the object, with its elements separated by dots,
+	// such as "spec.clusterName". Map keys holding dots or slashes are written in brackets, such as
+	// "metadata.labels[cattle.io/owner]". The field is compared as a whole, so the path of a struct or list makes all
+	// of its content immutable.
+	Path string
+	// SettableOnce allows updates to set the field if it was empty.
+	SettableOnce bool
+}
+
+// ImmutableFields checks that updates don't change a declared set of fields.
+type ImmutableFields struct {
+	fields []immutableField
+}
+
+type immutableField struct {
+	ImmutableField
+	// elements are the elements of the field's path, and keys tells which of them are map keys.
+	elements []string
+	keys     []bool
+}
+
+// NewImmutableFields returns the ImmutableFields checking the given fields. It panics if a path is malformed, as
+// fields are meant to be declared once in package variables.
+func NewImmutableFields(fields ...ImmutableField) *ImmutableFields {
+	i := &ImmutableFields{}
+	for _, f := range fields {
+		elements, keys, err := parseFieldPath(f.Path)
+		if err != nil {
+			panic(err)
+		}
+		i.fields = append(i.fields, immutableField{ImmutableField: f, elements: elements, keys: keys})
+	}
+	return i
+}
+
+// Validate returns a field.Forbidden error, with paths relative to root, for each declared field changed between the
+// old and new objects, in the order the fields were declared.
+func (i *ImmutableFields) Validate(root *field.Path, oldObj, newObj any) field.ErrorList {
+	oldContent, err := runtime.DefaultUnstructuredConverter.ToUnstructured(oldObj)
+	if err != nil {
+		return field.ErrorList{field.InternalError(root, fmt.Errorf("failed to convert old object: %w", err))}
+	}
+	newContent, err := runtime.DefaultUnstructuredConverter.ToUnstructured(newObj)
+	if err != nil {
+		return field.ErrorList{field.InternalError(root, fmt.Errorf("failed to convert new object: %w", err))}
+	}
+	var errs field.ErrorList
+	for _, f := range i.fields {
+		oldValue := lookupField(oldContent, f.elements)
+		newValue := lookupField(newContent, f.elements)
+		if reflect.DeepEqual(oldValue, newValue) || (f.SettableOnce && isEmptyValue(oldValue)) {
+			continue
+		}
+		errs = append(errs, field.Forbidden(f.path(root), immutableReason))
+	}
+	return errs
+}
+
+// path returns the path of the field relative to root.
+func (f *immutableField) path(root *field.Path) *field.Path {
+	path := root
+	for i, element := range f.elements {
+		if f.keys[i] {
+			path = path.Key(element)
+			continue
+		}
+		path = path.Child(element)
+	}
+	return path
+}
+
+// parseFieldPath splits a path such as "metadata.labels[cattle.io/owner]" into its elements, and tells which of them
+// are map keys written in brackets.
+func parseFieldPath(path string) ([]string, []bool, error) {
+	var elements []string
+	var keys []bool
+	rest := path
+	for rest != "" {
+		if strings.HasPrefix(rest, "[") {
+			end := strings.Index(rest, "]")
+			if end <= 1 {
+				return nil, nil, fmt.Errorf("invalid field path %q: unterminated or empty key", path)
+			}
+			elements = append(elements, rest[1:end])
+			keys = append(keys, true)
+			rest = strings.TrimPrefix(rest[end+1:], ".")
+			continue
+		}
+		end := strings.IndexAny(rest, ".[")
+		if end == -1 {
+			end = len(rest)
+		}
+		if end == 0 {
+			return nil, nil, fmt.Errorf("invalid field path %q: empty element", path)
+		}
+		elements = append(elements, rest[:end])
+		keys = append(keys, false)
+		rest = strings.TrimPrefix(rest[end:], ".")
+	}
+	if len(elements) == 0 {
+		return nil, nil, fmt.Errorf("invalid field path %q: no elements", path)
+	}
+	return elements, keys, nil
+}
+
+// lookupField returns the value at the given path of the unstructured content, or nil if it isn't set.
+func lookupField(content map[string]any, elements []string) any {
+	var value any = content
+	for _, element := range elements {
+		m, ok := value.(map[string]any)
+		if !ok {
+			return nil
+		}
+		value = m[element]
+	}
+	return value
+}
+
+// isEmptyValue returns true if the unstructured value is unset or the zero value of its type.
+func isEmptyValue(value any) bool {
+	if value == nil {
+		return true
+	}
+	v := reflect.ValueOf(value)
+	switch v.Kind() {
+	case reflect.Map, reflect.Slice:
+		return v.Len() == 0
+	default:
+		return v.IsZero()
+	}
+}
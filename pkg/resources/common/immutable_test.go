@@ -0,0 +1,107 @@
+package common
+
+import (
+	"testing"
+
+	v3 "github.com/rancher/rancher/pkg/apis/management.cattle.io/v3"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+)
+
+func TestImmutableFields(t *testing.T) {
+	t.Parallel()
+	fields := NewImmutableFields(
+		ImmutableField{Path: "spec.clusterName"},
+		ImmutableField{Path: "spec.description", SettableOnce: true},
+		ImmutableField{Path: "spec.resourceQuota.limit"},
+		ImmutableField{Path: "metadata.labels[cattle.io/owner]"},
+	)
+	project := v3.Project{}
+	project.Name = "p-abcde"
+	project.Labels = map[string]string{"cattle.io/owner": "user-abcde", "other": "value"}
+	project.Spec.ClusterName = "c-abcde"
+	project.Spec.ResourceQuota = &v3.ProjectResourceQuota{Limit: v3.ResourceQuotaLimit{Pods: "10"}}
+
+	tests := []struct {
+		name      string
+		update    func(*v3.Project)
+		wantPaths []string
+	}{
+		{
+			name:   "mutable fields are changed",
+			update: func(p *v3.Project) { p.Labels["other"] = "changed"; p.Spec.DisplayName = "changed" },
+		},
+		{
+			name:      "field is changed",
+			update:    func(p *v3.Project) { p.Spec.ClusterName = "c-fghij" },
+			wantPaths: []string{"project.spec.clusterName"},
+		},
+		{
+			name:   "settable once field is set",
+			update: func(p *v3.Project) { p.Spec.Description = "set" },
+		},
+		{
+			name: "nested field is changed",
+			update: func(p *v3.Project) {
+				p.Spec.ResourceQuota = &v3.ProjectResourceQuota{Limit: v3.ResourceQuotaLimit{Pods: "20"}}
+			},
+			wantPaths: []string{"project.spec.resourceQuota.limit"},
+		},
+		{
+			name:      "parent of a field is removed",
+			update:    func(p *v3.Project) { p.Spec.ResourceQuota = nil },
+			wantPaths: []string{"project.spec.resourceQuota.limit"},
+		},
+		{
+			name: "several fields are changed",
+			update: func(p *v3.Project) {
+				p.Spec.ClusterName = "c-fghij"
+				p.Labels = nil
+			},
+			wantPaths: []string{"project.spec.clusterName", "project.metadata.labels[cattle.io/owner]"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			updated := project.DeepCopy()
+			tt.update(updated)
+			errs := fields.Validate(field.NewPath("project"), &project, updated)
+			var paths []string
+			for _, err := range errs {
+				assert.Equal(t, field.ErrorTypeForbidden, err.Type)
+				assert.Equal(t, immutableReason, err.Detail)
+				paths = append(paths, err.Field)
+			}
+			assert.Equal(t, tt.wantPaths, paths)
+		})
+	}
+}
+
+func TestImmutableFieldsSettableOnce(t *testing.T) {
+	t.Parallel()
+	fields := NewImmutableFields(ImmutableField{Path: "spec.finalizers", SettableOnce: true})
+	oldNamespace := &corev1.Namespace{}
+	newNamespace := &corev1.Namespace{Spec: corev1.NamespaceSpec{Finalizers: []corev1.FinalizerName{"kubernetes"}}}
+	assert.Empty(t, fields.Validate(field.NewPath("namespace"), oldNamespace, newNamespace))
+
+	errs := fields.Validate(field.NewPath("namespace"), newNamespace, oldNamespace)
+	require.Len(t, errs, 1)
+	assert.Equal(t, "namespace.spec.finalizers", errs[0].Field)
+}
+
+func TestParseFieldPath(t *testing.T) {
+	t.Parallel()
+	elements, keys, err := parseFieldPath("metadata.annotations[field.cattle.io/projectId].value")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"metadata", "annotations", "field.cattle.io/projectId", "value"}, elements)
+	assert.Equal(t, []bool{false, false, true, false}, keys)
+
+	for _, path := range []string{"", "spec..name", "metadata.labels[", "metadata.labels[]", ".spec"} {
+		_, _, err := parseFieldPath(path)
+		assert.Error(t, err, "path %q", path)
+	}
+	assert.Panics(t, func() { NewImmutableFields(ImmutableField{Path: "spec..name"}) })
+}
@@ -0,0 +1,87 @@
+package common
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+)
+
+// ValidateIntOrPercent validates that value, if set, is a non-negative integer or a non-negative percentage, like the
+// maxSurge field of Deployments.
+func ValidateIntOrPercent(value *intstr.IntOrString, path *field.Path) field.ErrorList {
+	if value == nil {
+		return nil
+	}
+	if value.Type == intstr.Int {
+		if value.IntVal < 0 {
+			return field.ErrorList{field.Invalid(path, value.IntVal, "must be greater than or equal to 0")}
+		}
+		return nil
+	}
+	if _, err := parsePercent(value.StrVal); err != nil {
+		return field.ErrorList{field.Invalid(path, value.StrVal, err.Error())}
+	}
+	return nil
+}
+
+// validateMaxUnavailable validates maxUnavailable with ValidateIntOrPercent, and that it isn't a percentage greater than
+// 100%, since no more than all the machines can be unavailable.
+func validateMaxUnavailable(value *intstr.IntOrString, path *field.Path) field.ErrorList {
+	if errList := ValidateIntOrPercent(value, path); len(errList) != 0 {
+		return errList
+	}
+	if value == nil || value.Type == intstr.Int {
+		return nil
+	}
+	if percent, _ := parsePercent(value.StrVal); percent > 100 {
+		return field.ErrorList{field.Invalid(path, value.StrVal, "must not be greater than 100%")}
+	}
+	return nil
+}
+
+// ValidateRollingUpdate validates the maxUnavailable and maxSurge of a rolling update with ValidateIntOrPercent, with
+// maxUnavailable not greater than 100%, and that they aren't both zero, which would stall the update. Unset,
+// maxUnavailable defaults to 0 and maxSurge to 1.
+func ValidateRollingUpdate(maxUnavailable, maxSurge *intstr.IntOrString, path *field.Path) field.ErrorList {
+	errList := validateMaxUnavailable(maxUnavailable, path.Child("maxUnavailable"))
+	errList = append(errList, ValidateIntOrPercent(maxSurge, path.Child("maxSurge"))...)
+	if len(errList) != 0 {
+		return errList
+	}
+	if isZeroIntOrPercent(maxUnavailable) && maxSurge != nil && isZeroIntOrPercent(maxSurge) {
+		var value any = 0
+		if maxUnavailable != nil {
+			value = maxUnavailable.String()
+		}
+		errList = append(errList, field.Invalid(path.Child("maxUnavailable"), value, "may not be 0 when maxSurge is 0"))
+	}
+	return errList
+}
+
+// isZeroIntOrPercent returns true if the validated value is unset, 0 or 0%.
+func isZeroIntOrPercent(value *intstr.IntOrString) bool {
+	if value == nil {
+		return true
+	}
+	if value.Type == intstr.Int {
+		return value.IntVal == 0
+	}
+	percent, _ := parsePercent(value.StrVal)
+	return percent == 0
+}
+
+// parsePercent parses a percentage such as 25%.
+func parsePercent(value string) (int, error) {
+	number, ok := strings.CutSuffix(value, "%")
+	if !ok {
+		return 0, fmt.Errorf("must be an integer or a percentage (e.g. 25%%)")
+	}
+	percent, err := strconv.Atoi(number)
+	if err != nil || percent < 0 {
+		return 0, fmt.Errorf("must be a non-negative integer percentage (e.g. 25%%)")
+	}
+	return percent, nil
+}
@@ -0,0 +1,47 @@
+package common
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+)
+
+func TestValidateRollingUpdate(t *testing.T) {
+	t.Parallel()
+
+	value := func(s string) *intstr.IntOrString {
+		v := intstr.Parse(s)
+		return &v
+	}
+	tests := []struct {
+		name           string
+		maxUnavailable *intstr.IntOrString
+		maxSurge       *intstr.IntOrString
+		wantFields     []string
+	}{
+		{name: "unset"},
+		{name: "integers", maxUnavailable: value("1"), maxSurge: value("0")},
+		{name: "percentages", maxUnavailable: value("0%"), maxSurge: value("100%")},
+		{name: "negative", maxUnavailable: value("-1"), wantFields: []string{"spec.maxUnavailable"}},
+		{name: "negative percentage", maxSurge: value("-5%"), wantFields: []string{"spec.maxSurge"}},
+		{name: "maxUnavailable over 100%", maxUnavailable: value("101%"), wantFields: []string{"spec.maxUnavailable"}},
+		{name: "maxSurge over 100%", maxSurge: value("200%")},
+		{name: "not a number", maxUnavailable: value("all"), wantFields: []string{"spec.maxUnavailable"}},
+		{name: "both zero", maxUnavailable: value("0"), maxSurge: value("0%"), wantFields: []string{"spec.maxUnavailable"}},
+		{name: "unset maxUnavailable and zero maxSurge", maxSurge: value("0"), wantFields: []string{"spec.maxUnavailable"}},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			errs := ValidateRollingUpdate(tt.maxUnavailable, tt.maxSurge, field.NewPath("spec"))
+			var fields []string
+			for _, err := range errs {
+				fields = append(fields, err.Field)
+			}
+			assert.Equal(t, tt.wantFields, fields)
+		})
+	}
+}
@@ -0,0 +1,32 @@
+package common
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/blang/semver"
+)
+
+// MinKubernetesVersionSetting is the name of the setting holding the minimum Kubernetes version of provisioned RKE2 and
+// K3s clusters.
+const MinKubernetesVersionSetting = "provisioning-min-kubernetes-version"
+
+// ParseKubernetesVersion parses a Kubernetes version such as v1.28.3+rke2r1. The leading "v" and the patch version are
+// optional, and the build metadata holding the distribution suffix is dropped so that versions of RKE2 and K3s compare
+// by their Kubernetes version only.
+func ParseKubernetesVersion(version string) (semver.Version, error) {
+	trimmed := strings.TrimPrefix(strings.TrimSpace(version), "v")
+	trimmed, _, _ = strings.Cut(trimmed, "+")
+	core, preRelease, hasPreRelease := strings.Cut(trimmed, "-")
+	if strings.Count(core, ".") == 1 {
+		core += ".0"
+	}
+	if hasPreRelease {
+		core += "-" + preRelease
+	}
+	parsed, err := semver.Parse(core)
+	if err != nil {
+		return semver.Version{}, fmt.Errorf("%s is not a valid Kubernetes version: %w", version, err)
+	}
+	return parsed, nil
+}
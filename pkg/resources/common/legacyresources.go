@@ -0,0 +1,77 @@
+package common
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// LegacyResourceLockdownSetting is the name of the setting holding the comma separated list of legacy
+// management.cattle.io resources which can't be created or updated. An empty value locks down
+// DefaultLockedLegacyResources, and "none" locks down no resource.
+const LegacyResourceLockdownSetting = "legacy-resource-lockdown"
+
+// legacyResourceLockdownNone is the value of the legacy-resource-lockdown setting locking down no resource.
+const legacyResourceLockdownNone = "none"
+
+// LegacyResources are the deprecated management.cattle.io resources which can be locked down, and their replacements.
+var LegacyResources = map[string]string{
+	"composeconfigs":           "Fleet GitRepos",
+	"multiclusterapps":         "Fleet Bundles",
+	"multiclusterapprevisions": "Fleet Bundles",
+	"globaldnses":              "the external-dns chart",
+	"globaldnsproviders":       "the external-dns chart",
+	"catalogs":                 "catalog.cattle.io ClusterRepos",
+	"clustercatalogs":          "catalog.cattle.io ClusterRepos",
+	"projectcatalogs":          "catalog.cattle.io ClusterRepos",
+	"clusteralertgroups":       "the rancher-monitoring chart",
+	"clusteralertrules":        "the rancher-monitoring chart",
+	"projectalertgroups":       "the rancher-monitoring chart",
+	"projectalertrules":        "the rancher-monitoring chart",
+	"notifiers":                "the rancher-monitoring chart",
+	"clusterloggings":          "the rancher-logging chart",
+	"projectloggings":          "the rancher-logging chart",
+}
+
+// DefaultLockedLegacyResources are the legacy resources locked down when the legacy-resource-lockdown setting is empty.
+var DefaultLockedLegacyResources = []string{
+	"composeconfigs",
+	"multiclusterapps",
+	"multiclusterapprevisions",
+	"globaldnses",
+	"globaldnsproviders",
+}
+
+// ParseLegacyResourceLockdown parses the value of the legacy-resource-lockdown setting into the set of locked down
+// resources. Every listed resource must be one of the LegacyResources.
+func ParseLegacyResourceLockdown(value string) (map[string]bool, error) {
+	value = strings.TrimSpace(value)
+	locked := map[string]bool{}
+	switch value {
+	case "":
+		for _, resource := range DefaultLockedLegacyResources {
+			locked[resource] = true
+		}
+		return locked, nil
+	case legacyResourceLockdownNone:
+		return locked, nil
+	}
+	for _, resource := range strings.Split(value, ",") {
+		resource = strings.TrimSpace(resource)
+		if _, ok := LegacyResources[resource]; !ok {
+			return nil, fmt.Errorf("%q is not a legacy resource, must be %q or one of %s", resource, legacyResourceLockdownNone,
+				strings.Join(sortedLegacyResources(), ", "))
+		}
+		locked[resource] = true
+	}
+	return locked, nil
+}
+
+func sortedLegacyResources() []string {
+	resources := make([]string, 0, len(LegacyResources))
+	for resource := range LegacyResources {
+		resources = append(resources, resource)
+	}
+	sort.Strings(resources)
+	return resources
+}
@@ -0,0 +1,95 @@
+package common
+
+import (
+	"encoding/json"
+	"maps"
+	"slices"
+	"strings"
+
+	apivalidation "k8s.io/apimachinery/pkg/api/validation"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	metav1validation "k8s.io/apimachinery/pkg/apis/meta/v1/validation"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+)
+
+// NamespaceMetadataTemplateAnn is the annotation of a project holding, as JSON, the NamespaceMetadataTemplate applied to
+// the namespaces of the project.
+const NamespaceMetadataTemplateAnn = "field.cattle.io/namespace-metadata-template"
+
+// reservedNamespaceMetadataPrefixes are the prefixes of the labels and annotations which are managed by Rancher or
+// Kubernetes, or guarded by other checks, and can't be set by a NamespaceMetadataTemplate.
+var reservedNamespaceMetadataPrefixes = []string{"field.cattle.io/", "pod-security.kubernetes.io/", "kubernetes.io/"}
+
+// NamespaceMetadataTemplate holds the labels and annotations which are added to every namespace of a project.
+type NamespaceMetadataTemplate struct {
+	Labels      map[string]string `json:"labels,omitempty"`
+	Annotations map[string]string `json:"annotations,omitempty"`
+}
+
+// GetNamespaceMetadataTemplate returns the NamespaceMetadataTemplate of the project, or nil if it has none.
+func GetNamespaceMetadataTemplate(project metav1.Object) (*NamespaceMetadataTemplate, error) {
+	value, ok := project.GetAnnotations()[NamespaceMetadataTemplateAnn]
+	if !ok {
+		return nil, nil
+	}
+	template := &NamespaceMetadataTemplate{}
+	if err := json.Unmarshal([]byte(value), template); err != nil {
+		return nil, err
+	}
+	return template, nil
+}
+
+// Validate checks that the labels and annotations of the template are valid and not reserved.
+func (t *NamespaceMetadataTemplate) Validate(path *field.Path) field.ErrorList {
+	errs := metav1validation.ValidateLabels(t.Labels, path.Child("labels"))
+	errs = append(errs, apivalidation.ValidateAnnotations(t.Annotations, path.Child("annotations"))...)
+	for _, key := range slices.Sorted(maps.Keys(t.Labels)) {
+		if isReservedNamespaceMetadata(key) {
+			errs = append(errs, field.Forbidden(path.Child("labels").Key(key), "reserved label"))
+		}
+	}
+	for _, key := range slices.Sorted(maps.Keys(t.Annotations)) {
+		if isReservedNamespaceMetadata(key) {
+			errs = append(errs, field.Forbidden(path.Child("annotations").Key(key), "reserved annotation"))
+		}
+	}
+	return errs
+}
+
+// Apply adds the labels and annotations of the template to the namespace, and returns true if any was added. Labels
+// and annotations already set on the namespace take precedence, and reserved ones are skipped.
+func (t *NamespaceMetadataTemplate) Apply(namespace metav1.Object) bool {
+	labels, labelsAdded := mergeNamespaceMetadata(namespace.GetLabels(), t.Labels)
+	annotations, annotationsAdded := mergeNamespaceMetadata(namespace.GetAnnotations(), t.Annotations)
+	namespace.SetLabels(labels)
+	namespace.SetAnnotations(annotations)
+	return labelsAdded || annotationsAdded
+}
+
+// mergeNamespaceMetadata adds the entries of template missing from metadata.
+func mergeNamespaceMetadata(metadata, template map[string]string) (map[string]string, bool) {
+	added := false
+	for key, value := range template {
+		if isReservedNamespaceMetadata(key) {
+			continue
+		}
+		if _, ok := metadata[key]; ok {
+			continue
+		}
+		if metadata == nil {
+			metadata = map[string]string{}
+		}
+		metadata[key] = value
+		added = true
+	}
+	return metadata, added
+}
+
+func isReservedNamespaceMetadata(key string) bool {
+	for _, prefix := range reservedNamespaceMetadataPrefixes {
+		if strings.HasPrefix(key, prefix) {
+			return true
+		}
+	}
+	return false
+}
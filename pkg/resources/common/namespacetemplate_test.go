@@ -0,0 +1,112 @@
+package common
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+)
+
+func TestGetNamespaceMetadataTemplate(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name        string
+		annotations map[string]string
+		want        *NamespaceMetadataTemplate
+		wantErr     bool
+	}{
+		{
+			name: "no template",
+		},
+		{
+			name:        "template",
+			annotations: map[string]string{NamespaceMetadataTemplateAnn: `{"labels":{"team":"a"},"annotations":{"owner":"x"}}`},
+			want: &NamespaceMetadataTemplate{
+				Labels:      map[string]string{"team": "a"},
+				Annotations: map[string]string{"owner": "x"},
+			},
+		},
+		{
+			name:        "malformed template",
+			annotations: map[string]string{NamespaceMetadataTemplateAnn: `labels: team`},
+			wantErr:     true,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			got, err := GetNamespaceMetadataTemplate(&metav1.ObjectMeta{Annotations: tt.annotations})
+			if tt.wantErr {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestNamespaceMetadataTemplateValidate(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name     string
+		template NamespaceMetadataTemplate
+		wantErrs int
+	}{
+		{
+			name: "valid template",
+			template: NamespaceMetadataTemplate{
+				Labels:      map[string]string{"team": "a", "example.com/tier": "gold"},
+				Annotations: map[string]string{"example.com/owner": "team a"},
+			},
+		},
+		{
+			name: "invalid labels",
+			template: NamespaceMetadataTemplate{
+				Labels: map[string]string{"team": "a b", "-team": "a"},
+			},
+			wantErrs: 2,
+		},
+		{
+			name: "reserved keys",
+			template: NamespaceMetadataTemplate{
+				Labels:      map[string]string{"kubernetes.io/metadata.name": "ns", "pod-security.kubernetes.io/enforce": "privileged"},
+				Annotations: map[string]string{"field.cattle.io/projectId": "c-123xyz:p-abc"},
+			},
+			wantErrs: 3,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			errs := tt.template.Validate(field.NewPath("template"))
+			assert.Len(t, errs, tt.wantErrs, errs)
+		})
+	}
+}
+
+func TestNamespaceMetadataTemplateApply(t *testing.T) {
+	t.Parallel()
+	template := NamespaceMetadataTemplate{
+		Labels:      map[string]string{"team": "a", "pod-security.kubernetes.io/enforce": "privileged"},
+		Annotations: map[string]string{"owner": "x"},
+	}
+
+	namespace := &corev1.Namespace{}
+	assert.True(t, template.Apply(namespace))
+	assert.Equal(t, map[string]string{"team": "a"}, namespace.Labels)
+	assert.Equal(t, map[string]string{"owner": "x"}, namespace.Annotations)
+
+	namespace = &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{
+		Labels:      map[string]string{"team": "b"},
+		Annotations: map[string]string{"owner": "y"},
+	}}
+	assert.False(t, template.Apply(namespace))
+	assert.Equal(t, map[string]string{"team": "b"}, namespace.Labels)
+	assert.Equal(t, map[string]string{"owner": "y"}, namespace.Annotations)
+}
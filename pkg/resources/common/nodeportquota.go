@@ -0,0 +1,116 @@
+package common
+
+import (
+	"fmt"
+	"strings"
+
+	controllerv3 "github.com/rancher/webhook/pkg/generated/controllers/management.cattle.io/v3"
+	"github.com/rancher/webhook/pkg/quota"
+	corev1controller "github.com/rancher/wrangler/v3/pkg/generated/controllers/core/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/labels"
+)
+
+const (
+	// ProjectIDAnnotation is the annotation of namespaces holding the ID of their project, as <cluster>:<project>.
+	ProjectIDAnnotation = "field.cattle.io/projectId"
+	// NamespaceByProjectIndex is the name of the index of namespaces by the value of their ProjectIDAnnotation.
+	NamespaceByProjectIndex = "webhook.cattle.io/namespace-by-project"
+)
+
+// NamespaceByProject indexes namespaces by the value of their project annotation.
+func NamespaceByProject(namespace *corev1.Namespace) ([]string, error) {
+	if projectID := namespace.Annotations[ProjectIDAnnotation]; projectID != "" {
+		return []string{projectID}, nil
+	}
+	return nil, nil
+}
+
+// NodePortQuota checks the node ports used by the Services of the namespaces of a project against the
+// servicesNodePorts limit of the project's resource quota. Unlike the ResourceQuotas Rancher creates in each namespace,
+// it counts the node ports actually in use across the whole project, so that it also holds when namespaces are moved
+// into a project or Services are created before the quota of their namespace is set. Since the Projects are only in
+// the local cluster, it only checks the Services of the local cluster: the webhooks of downstream clusters have no
+// Projects to check their Services against.
+type NodePortQuota struct {
+	projectCache   controllerv3.ProjectCache
+	namespaceCache corev1controller.NamespaceCache
+	serviceCache   corev1controller.ServiceCache
+}
+
+// NewNodePortQuota returns a NodePortQuota. The namespaceCache must be indexed by NamespaceByProjectIndex.
+func NewNodePortQuota(projectCache controllerv3.ProjectCache, namespaceCache corev1controller.NamespaceCache,
+	serviceCache corev1controller.ServiceCache) *NodePortQuota {
+	return &NodePortQuota{
+		projectCache:   projectCache,
+		namespaceCache: namespaceCache,
+		serviceCache:   serviceCache,
+	}
+}
+
+// Check returns a message explaining why the project can't fit the requested node ports in addition to those already
+// used by its Services, or an empty message if it can. Services for which skip returns true, such as the previous
+// version of an updated Service, are not counted. Projects which don't exist or don't limit node ports fit any number
+// of them.
+func (q *NodePortQuota) Check(projectID string, requested int64, skip func(*corev1.Service) bool) (string, error) {
+	if requested <= 0 {
+		return "", nil
+	}
+	clusterName, projectName, ok := strings.Cut(projectID, ":")
+	if !ok {
+		return "", nil
+	}
+	project, err := q.projectCache.Get(clusterName, projectName)
+	if err != nil {
+		if apierrors.IsNotFound(err) {
+			return "", nil
+		}
+		return "", fmt.Errorf("failed to get project %s: %w", projectID, err)
+	}
+	if project.Spec.ResourceQuota == nil {
+		return "", nil
+	}
+	limits, err := quota.FromLimit(&project.Spec.ResourceQuota.Limit)
+	if err != nil {
+		return "", fmt.Errorf("failed to parse the resource quota of project %s: %w", projectID, err)
+	}
+	limit, ok := limits[quota.ServicesNodePorts]
+	if !ok {
+		return "", nil
+	}
+
+	namespaces, err := q.namespaceCache.GetByIndex(NamespaceByProjectIndex, projectID)
+	if err != nil {
+		return "", fmt.Errorf("failed to list namespaces of project %s: %w", projectID, err)
+	}
+	var used int64
+	for _, namespace := range namespaces {
+		count, err := q.NamespaceNodePorts(namespace.Name, skip)
+		if err != nil {
+			return "", err
+		}
+		used += count
+	}
+	if used+requested > limit.Value() {
+		return fmt.Sprintf("project %s allows %d node ports for its services, %d are in use and %d more were requested",
+			projectID, limit.Value(), used, requested), nil
+	}
+	return "", nil
+}
+
+// NamespaceNodePorts returns the number of node ports used by the Services of the namespace, not counting those for
+// which skip returns true.
+func (q *NodePortQuota) NamespaceNodePorts(namespace string, skip func(*corev1.Service) bool) (int64, error) {
+	services, err := q.serviceCache.List(namespace, labels.Everything())
+	if err != nil {
+		return 0, fmt.Errorf("failed to list services of namespace %s: %w", namespace, err)
+	}
+	var count int64
+	for _, service := range services {
+		if skip == nil || !skip(service) {
+			count += quota.NodePorts(service)
+		}
+	}
+	return count, nil
+}
@@ -0,0 +1,25 @@
+package common
+
+import (
+	"fmt"
+	"strconv"
+)
+
+// MaxProjectsPerUserSetting is the name of the setting holding the maximum number of projects a user can be bound into
+// with ProjectRoleTemplateBindings. Zero or an empty value means no limit.
+const MaxProjectsPerUserSetting = "max-projects-per-user"
+
+// ParseMaxProjectsPerUser parses the value of the max-projects-per-user setting.
+func ParseMaxProjectsPerUser(value string) (int, error) {
+	if value == "" {
+		return 0, nil
+	}
+	limit, err := strconv.Atoi(value)
+	if err != nil {
+		return 0, fmt.Errorf("%s is not an integer", value)
+	}
+	if limit < 0 {
+		return 0, fmt.Errorf("%s is negative", value)
+	}
+	return limit, nil
+}
@@ -0,0 +1,211 @@
+package common
+
+import (
+	"fmt"
+
+	"github.com/rancher/webhook/pkg/admission"
+	"github.com/rancher/webhook/pkg/auth"
+	controllerv3 "github.com/rancher/webhook/pkg/generated/controllers/management.cattle.io/v3"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	authorizationv1 "k8s.io/client-go/kubernetes/typed/authorization/v1"
+)
+
+// AnnotationPermission is who may make a kind of change to a protected annotation.
+type AnnotationPermission int
+
+const (
+	// AnyUser allows every user to make the change.
+	AnyUser AnnotationPermission = iota
+	// CreatorOnly allows the change if the new value of the annotation is the username of the user making the
+	// request, which ties the annotation to the user who created the object.
+	CreatorOnly
+	// AdminOnly allows administrators, i.e. users who can perform any verb on any resource, to make the change.
+	AdminOnly
+	// NoUser denies the change to every user.
+	NoUser
+)
+
+// ProtectedAnnotation declares who may set, change or remove an annotation. The zero value of each permission allows
+// any user to make the change, so an annotation which can be set on create but is immutable afterward only declares
+// Add and Change as NoUser.
+type ProtectedAnnotation struct {
+	// Key is the key of the annotation.
+	Key string
+	// Set is who may set the annotation when the object is created.
+	Set AnnotationPermission
+	// Add is who may add the annotation to an existing object.
+	Add AnnotationPermission
+	// Change is who may change the value of the annotation.
+	Change AnnotationPermission
+	// Remove is who may remove the annotation.
+	Remove AnnotationPermission
+	// AdminExempt optionally returns true for requests in which the AdminOnly changes are allowed to every user.
+	AdminExempt func(request *admission.Request) (bool, error)
+}
+
+// ProtectedAnnotations is a registry of protected annotations, which validates the changes requests make to them.
+type ProtectedAnnotations struct {
+	annotations []ProtectedAnnotation
+}
+
+// NewProtectedAnnotations returns a registry of the given annotations. It panics if an annotation is declared twice,
+// as annotations are meant to be declared once in package variables.
+func NewProtectedAnnotations(annotations ...ProtectedAnnotation) *ProtectedAnnotations {
+	p := &ProtectedAnnotations{}
+	for _, annotation := range annotations {
+		p.Register(annotation)
+	}
+	return p
+}
+
+// Register adds an annotation to the registry. It panics if the annotation is already registered.
+func (p *ProtectedAnnotations) Register(annotation ProtectedAnnotation) {
+	for _, registered := range p.annotations {
+		if registered.Key == annotation.Key {
+			panic(fmt.Sprintf("protected annotation %s is already registered", annotation.Key))
+		}
+	}
+	p.annotations = append(p.annotations, annotation)
+}
+
+// With returns a new registry holding the annotations of this registry and the given ones, so that validators can
+// extend a shared registry with the annotations of their own resources.
+func (p *ProtectedAnnotations) With(annotations ...ProtectedAnnotation) *ProtectedAnnotations {
+	return NewProtectedAnnotations(append(append([]ProtectedAnnotation{}, p.annotations...), annotations...)...)
+}
+
+// Validate returns a field.Forbidden error for each change to a registered annotation between oldObj and newObj which
+// the user of the request isn't allowed to make, in the order the annotations were registered. oldObj is nil on
+// create. sar is used to check if the user is an administrator, and is only required by AdminOnly changes.
+func (p *ProtectedAnnotations) Validate(request *admission.Request, sar authorizationv1.SubjectAccessReviewInterface, oldObj, newObj metav1.Object) (field.ErrorList, error) {
+	var oldAnnotations map[string]string
+	if oldObj != nil {
+		oldAnnotations = oldObj.GetAnnotations()
+	}
+	newAnnotations := newObj.GetAnnotations()
+	admin := &adminCheck{request: request, sar: sar}
+
+	var fieldErrs field.ErrorList
+	for _, annotation := range p.annotations {
+		oldValue, oldOK := oldAnnotations[annotation.Key]
+		newValue, newOK := newAnnotations[annotation.Key]
+		var permission AnnotationPermission
+		var change string
+		switch {
+		case oldObj == nil && newOK:
+			permission, change = annotation.Set, "set"
+		case oldObj == nil:
+			continue
+		case !oldOK && newOK:
+			permission, change = annotation.Add, "added"
+		case oldOK && !newOK:
+			permission, change = annotation.Remove, "removed"
+		case oldOK && oldValue != newValue:
+			permission, change = annotation.Change, "changed"
+		default:
+			continue
+		}
+		fieldErr, err := annotation.check(request, admin, permission, change, newValue)
+		if err != nil {
+			return nil, err
+		}
+		if fieldErr != nil {
+			fieldErrs = append(fieldErrs, fieldErr)
+		}
+	}
+	return fieldErrs, nil
+}
+
+// check returns a field error if the user of the request doesn't have the permission to make the change.
+func (a *ProtectedAnnotation) check(request *admission.Request, admin *adminCheck, permission AnnotationPermission, change, newValue string) (*field.Error, error) {
+	path := annotationsFieldPath.Key(a.Key)
+	switch permission {
+	case AnyUser:
+		return nil, nil
+	case CreatorOnly:
+		if newValue == request.UserInfo.Username {
+			return nil, nil
+		}
+		return field.Forbidden(path, fmt.Sprintf("annotation %q does not match user %q", newValue, request.UserInfo.Username)), nil
+	case AdminOnly:
+		if a.AdminExempt != nil {
+			exempt, err := a.AdminExempt(request)
+			if err != nil {
+				return nil, err
+			}
+			if exempt {
+				return nil, nil
+			}
+		}
+		isAdmin, err := admin.isAdmin()
+		if err != nil {
+			return nil, err
+		}
+		if isAdmin {
+			return nil, nil
+		}
+		return field.Forbidden(path, fmt.Sprintf("annotation can only be %s by administrators", change)), nil
+	default:
+		if change == "changed" {
+			return field.Forbidden(path, "annotation is immutable"), nil
+		}
+		return field.Forbidden(path, fmt.Sprintf("annotation can't be %s", change)), nil
+	}
+}
+
+// adminCheck checks if the user of a request is an administrator at most once per request.
+type adminCheck struct {
+	request *admission.Request
+	sar     authorizationv1.SubjectAccessReviewInterface
+	checked bool
+	admin   bool
+}
+
+func (c *adminCheck) isAdmin() (bool, error) {
+	if c.checked {
+		return c.admin, nil
+	}
+	if c.sar == nil {
+		return false, fmt.Errorf("no SubjectAccessReview client to check if user is an administrator")
+	}
+	admin, err := auth.RequestUserIsAdmin(c.request, c.sar)
+	if err != nil {
+		return false, fmt.Errorf("failed to check if user is an administrator: %w", err)
+	}
+	c.checked, c.admin = true, admin
+	return admin, nil
+}
+
+// CreatorIDAnnotation protects the creatorID annotation, which Rancher uses to grant the creator of an object access
+// to it: it can only be set to the user creating the object, and can't be added or changed afterward.
+var CreatorIDAnnotation = ProtectedAnnotation{Key: CreatorIDAnn, Set: CreatorOnly, Add: NoUser, Change: NoUser}
+
+// CreatorPrincipalNameAnnotation protects the creator-principal-name annotation, which can't be added or changed after
+// the object is created.
+var CreatorPrincipalNameAnnotation = ProtectedAnnotation{Key: CreatorPrincipalNameAnn, Add: NoUser, Change: NoUser}
+
+// NoCreatorRBACAnnotation returns the protection of the no-creator-rbac annotation opting an object out of creator
+// RBAC: only administrators can set or add it, except in the namespaces listed in the no-creator-rbac-namespaces
+// setting.
+func NoCreatorRBACAnnotation(settingCache controllerv3.SettingCache) ProtectedAnnotation {
+	return ProtectedAnnotation{
+		Key: NoCreatorRBACAnn,
+		Set: AdminOnly,
+		Add: AdminOnly,
+		AdminExempt: func(request *admission.Request) (bool, error) {
+			return IsNoCreatorRBACNamespace(settingCache, request.Namespace)
+		},
+	}
+}
+
+var (
+	// creatorIDAnnotations are the protected annotations checked on create by CheckCreatorIDOnCreate.
+	creatorIDAnnotations = NewProtectedAnnotations(CreatorIDAnnotation)
+	// creatorAnnotations are the protected annotations checked on update by CheckCreatorAnnotationsOnUpdate.
+	creatorAnnotations = NewProtectedAnnotations(
+		CreatorIDAnnotation,
+		CreatorPrincipalNameAnnotation,
+		ProtectedAnnotation{Key: NoCreatorRBACAnn, Add: NoUser, Change: NoUser},
+	)
+)
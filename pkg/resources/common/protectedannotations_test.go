@@ -0,0 +1,164 @@
+package common
+
+import (
+	"context"
+	"testing"
+
+	"github.com/rancher/webhook/pkg/admission"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	admissionv1 "k8s.io/api/admission/v1"
+	authenticationv1 "k8s.io/api/authentication/v1"
+	authorizationv1 "k8s.io/api/authorization/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	k8fake "k8s.io/client-go/kubernetes/typed/authorization/v1/fake"
+	k8testing "k8s.io/client-go/testing"
+)
+
+func TestProtectedAnnotationsValidate(t *testing.T) {
+	t.Parallel()
+	const (
+		adminUser    = "admin-user"
+		standardUser = "standard-user"
+		creatorKey   = "example.cattle.io/creator"
+		adminKey     = "example.cattle.io/admin"
+		immutableKey = "example.cattle.io/immutable"
+	)
+	registry := NewProtectedAnnotations(
+		ProtectedAnnotation{Key: creatorKey, Set: CreatorOnly, Add: NoUser, Change: NoUser},
+		ProtectedAnnotation{Key: adminKey, Set: AdminOnly, Add: AdminOnly, Change: AdminOnly, Remove: AdminOnly},
+		ProtectedAnnotation{Key: immutableKey, Add: NoUser, Change: NoUser, Remove: NoUser},
+	)
+	withAnnotations := func(annotations map[string]string) metav1.Object {
+		return &metav1.ObjectMeta{Annotations: annotations}
+	}
+
+	tests := []struct {
+		name       string
+		username   string
+		oldObj     metav1.Object
+		newObj     metav1.Object
+		wantFields []string
+		wantSARs   int
+	}{
+		{
+			name:     "create with allowed annotations",
+			username: standardUser,
+			newObj:   withAnnotations(map[string]string{creatorKey: standardUser, immutableKey: "value"}),
+		},
+		{
+			name:       "create with another user as creator",
+			username:   standardUser,
+			newObj:     withAnnotations(map[string]string{creatorKey: adminUser}),
+			wantFields: []string{"metadata.annotations[example.cattle.io/creator]"},
+		},
+		{
+			name:       "create with admin annotation by non-admin",
+			username:   standardUser,
+			newObj:     withAnnotations(map[string]string{adminKey: "true"}),
+			wantFields: []string{"metadata.annotations[example.cattle.io/admin]"},
+			wantSARs:   1,
+		},
+		{
+			name:     "create with admin annotation by admin",
+			username: adminUser,
+			newObj:   withAnnotations(map[string]string{adminKey: "true"}),
+			wantSARs: 1,
+		},
+		{
+			name:     "unchanged annotations",
+			username: standardUser,
+			oldObj:   withAnnotations(map[string]string{creatorKey: adminUser, adminKey: "true", immutableKey: "value"}),
+			newObj:   withAnnotations(map[string]string{creatorKey: adminUser, adminKey: "true", immutableKey: "value"}),
+		},
+		{
+			name:       "add annotations",
+			username:   standardUser,
+			oldObj:     withAnnotations(nil),
+			newObj:     withAnnotations(map[string]string{creatorKey: standardUser, adminKey: "true", immutableKey: "value"}),
+			wantFields: []string{"metadata.annotations[example.cattle.io/creator]", "metadata.annotations[example.cattle.io/admin]", "metadata.annotations[example.cattle.io/immutable]"},
+			wantSARs:   1,
+		},
+		{
+			name:       "change annotations",
+			username:   adminUser,
+			oldObj:     withAnnotations(map[string]string{creatorKey: standardUser, adminKey: "true", immutableKey: "value"}),
+			newObj:     withAnnotations(map[string]string{creatorKey: adminUser, adminKey: "false", immutableKey: "other"}),
+			wantFields: []string{"metadata.annotations[example.cattle.io/creator]", "metadata.annotations[example.cattle.io/immutable]"},
+			wantSARs:   1,
+		},
+		{
+			name:       "remove annotations",
+			username:   standardUser,
+			oldObj:     withAnnotations(map[string]string{creatorKey: standardUser, adminKey: "true", immutableKey: "value"}),
+			newObj:     withAnnotations(nil),
+			wantFields: []string{"metadata.annotations[example.cattle.io/admin]", "metadata.annotations[example.cattle.io/immutable]"},
+			wantSARs:   1,
+		},
+		{
+			name:     "unprotected annotations",
+			username: standardUser,
+			oldObj:   withAnnotations(map[string]string{"example.cattle.io/other": "value"}),
+			newObj:   withAnnotations(map[string]string{"example.cattle.io/other": "changed"}),
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			sars := 0
+			k8Fake := &k8testing.Fake{}
+			k8Fake.AddReactor("create", "subjectaccessreviews", func(action k8testing.Action) (bool, runtime.Object, error) {
+				sars++
+				review := action.(k8testing.CreateActionImpl).GetObject().(*authorizationv1.SubjectAccessReview)
+				review.Status.Allowed = review.Spec.User == adminUser
+				return true, review, nil
+			})
+			sar := &k8fake.FakeSubjectAccessReviews{Fake: &k8fake.FakeAuthorizationV1{Fake: k8Fake}}
+			request := &admission.Request{
+				AdmissionRequest: admissionv1.AdmissionRequest{UserInfo: authenticationv1.UserInfo{Username: tt.username}},
+				Context:          context.Background(),
+			}
+
+			fieldErrs, err := registry.Validate(request, sar, tt.oldObj, tt.newObj)
+			require.NoError(t, err)
+			var fields []string
+			for _, fieldErr := range fieldErrs {
+				fields = append(fields, fieldErr.Field)
+			}
+			assert.Equal(t, tt.wantFields, fields)
+			assert.Equal(t, tt.wantSARs, sars)
+		})
+	}
+}
+
+func TestProtectedAnnotationsAdminExempt(t *testing.T) {
+	t.Parallel()
+	registry := NewProtectedAnnotations(ProtectedAnnotation{
+		Key: "example.cattle.io/admin",
+		Set: AdminOnly,
+		AdminExempt: func(request *admission.Request) (bool, error) {
+			return request.Namespace == "exempt", nil
+		},
+	})
+	obj := &metav1.ObjectMeta{Annotations: map[string]string{"example.cattle.io/admin": "true"}}
+
+	request := &admission.Request{AdmissionRequest: admissionv1.AdmissionRequest{Namespace: "exempt"}}
+	fieldErrs, err := registry.Validate(request, nil, nil, obj)
+	require.NoError(t, err)
+	assert.Empty(t, fieldErrs)
+
+	request = &admission.Request{AdmissionRequest: admissionv1.AdmissionRequest{Namespace: "default"}}
+	_, err = registry.Validate(request, nil, nil, obj)
+	assert.Error(t, err, "expected an error without a SubjectAccessReview client")
+}
+
+func TestProtectedAnnotationsRegister(t *testing.T) {
+	t.Parallel()
+	registry := NewProtectedAnnotations(CreatorIDAnnotation)
+	extended := registry.With(CreatorPrincipalNameAnnotation)
+	assert.Len(t, registry.annotations, 1)
+	assert.Len(t, extended.annotations, 2)
+	assert.Panics(t, func() { registry.Register(CreatorIDAnnotation) })
+}
@@ -0,0 +1,16 @@
+package common
+
+import (
+	v3 "github.com/rancher/rancher/pkg/apis/management.cattle.io/v3"
+)
+
+// PRTBByUserIndex is the name of the index of ProjectRoleTemplateBindings by the name of the user they bind.
+const PRTBByUserIndex = "management.cattle.io/prtb-by-user"
+
+// PRTBByUser indexes ProjectRoleTemplateBindings by the name of the user they bind.
+func PRTBByUser(prtb *v3.ProjectRoleTemplateBinding) ([]string, error) {
+	if prtb.UserName == "" {
+		return nil, nil
+	}
+	return []string{prtb.UserName}, nil
+}
@@ -0,0 +1,49 @@
+package common
+
+import (
+	"fmt"
+
+	apisv3 "github.com/rancher/rancher/pkg/apis/management.cattle.io/v3"
+	v3 "github.com/rancher/webhook/pkg/generated/controllers/management.cattle.io/v3"
+	psa "github.com/rancher/webhook/pkg/podsecurityadmission"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+)
+
+// UpdatePSAVerb is the verb on a cluster needed to change its PodSecurityAdmissionConfigurationTemplate to one
+// enforcing a less restrictive level.
+const UpdatePSAVerb = "updatepsa"
+
+// IsPSACTDowngrade returns true if changing the PodSecurityAdmissionConfigurationTemplate of a cluster from oldName to
+// newName lowers the Pod Security level enforced by default in the cluster, such as from restricted to privileged.
+// Unsetting the template is a downgrade to the privileged level. Templates which don't exist enforce the privileged
+// level, so that a deleted template can be replaced, while using a missing template is rejected by the cluster
+// validators.
+func IsPSACTDowngrade(cache v3.PodSecurityAdmissionConfigurationTemplateCache, oldName, newName string) (bool, error) {
+	if oldName == newName || oldName == "" {
+		return false, nil
+	}
+	oldTemplate, err := getPSACT(cache, oldName)
+	if err != nil {
+		return false, err
+	}
+	newTemplate, err := getPSACT(cache, newName)
+	if err != nil {
+		return false, err
+	}
+	return psa.IsDowngrade(oldTemplate, newTemplate), nil
+}
+
+// getPSACT returns the named template, or nil if the name is empty or the template doesn't exist.
+func getPSACT(cache v3.PodSecurityAdmissionConfigurationTemplateCache, name string) (*apisv3.PodSecurityAdmissionConfigurationTemplate, error) {
+	if name == "" {
+		return nil, nil
+	}
+	template, err := cache.Get(name)
+	if apierrors.IsNotFound(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get PodSecurityAdmissionConfigurationTemplate %s: %w", name, err)
+	}
+	return template, nil
+}
@@ -0,0 +1,58 @@
+package common
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+)
+
+// ValidateResourceRequirements checks the resource requirements overriding those of a deployment, such as the cluster
+// and fleet agents: resource names must be qualified names, quantities can't be negative and requests can't be greater
+// than the limit of the same resource, as the pods would never be scheduled.
+func ValidateResourceRequirements(requirements *corev1.ResourceRequirements, path *field.Path) field.ErrorList {
+	if requirements == nil {
+		return nil
+	}
+	var errList field.ErrorList
+	errList = append(errList, validateResourceList(requirements.Limits, path.Child("limits"))...)
+	errList = append(errList, validateResourceList(requirements.Requests, path.Child("requests"))...)
+
+	for _, name := range sortedResourceNames(requirements.Requests) {
+		request := requirements.Requests[name]
+		limit, ok := requirements.Limits[name]
+		if ok && request.Cmp(limit) > 0 {
+			errList = append(errList, field.Invalid(path.Child("requests").Key(string(name)), request.String(),
+				fmt.Sprintf("must be less than or equal to the %s limit of %s", name, limit.String())))
+		}
+	}
+	return errList
+}
+
+func validateResourceList(resources corev1.ResourceList, path *field.Path) field.ErrorList {
+	var errList field.ErrorList
+	for _, name := range sortedResourceNames(resources) {
+		quantity := resources[name]
+		if errs := validation.IsQualifiedName(string(name)); len(errs) > 0 {
+			errList = append(errList, field.Invalid(path.Key(string(name)), string(name), strings.Join(errs, ", ")))
+			continue
+		}
+		if quantity.Sign() < 0 {
+			errList = append(errList, field.Invalid(path.Key(string(name)), quantity.String(), "must be greater than or equal to 0"))
+		}
+	}
+	return errList
+}
+
+// sortedResourceNames returns the names of the resources in a stable order, so that the errors are too.
+func sortedResourceNames(resources corev1.ResourceList) []corev1.ResourceName {
+	names := make([]corev1.ResourceName, 0, len(resources))
+	for name := range resources {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	return names
+}
@@ -0,0 +1,73 @@
+package common
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+)
+
+func TestValidateResourceRequirements(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name         string
+		requirements *corev1.ResourceRequirements
+		wantFields   []string
+	}{
+		{
+			name: "no requirements",
+		},
+		{
+			name: "valid requirements",
+			requirements: &corev1.ResourceRequirements{
+				Limits: corev1.ResourceList{
+					corev1.ResourceCPU:    resource.MustParse("1"),
+					corev1.ResourceMemory: resource.MustParse("1Gi"),
+				},
+				Requests: corev1.ResourceList{
+					corev1.ResourceCPU:              resource.MustParse("1000m"),
+					corev1.ResourceMemory:           resource.MustParse("512Mi"),
+					corev1.ResourceEphemeralStorage: resource.MustParse("10Gi"),
+				},
+			},
+		},
+		{
+			name: "request greater than limit",
+			requirements: &corev1.ResourceRequirements{
+				Limits:   corev1.ResourceList{corev1.ResourceMemory: resource.MustParse("512Mi")},
+				Requests: corev1.ResourceList{corev1.ResourceMemory: resource.MustParse("1Gi")},
+			},
+			wantFields: []string{"spec.requests[memory]"},
+		},
+		{
+			name: "negative quantities",
+			requirements: &corev1.ResourceRequirements{
+				Limits:   corev1.ResourceList{corev1.ResourceCPU: resource.MustParse("-1")},
+				Requests: corev1.ResourceList{corev1.ResourceMemory: resource.MustParse("-1Mi")},
+			},
+			wantFields: []string{"spec.limits[cpu]", "spec.requests[memory]"},
+		},
+		{
+			name: "invalid resource name",
+			requirements: &corev1.ResourceRequirements{
+				Requests: corev1.ResourceList{"not a resource": resource.MustParse("1")},
+			},
+			wantFields: []string{"spec.requests[not a resource]"},
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			errs := ValidateResourceRequirements(tt.requirements, field.NewPath("spec"))
+			var fields []string
+			for _, err := range errs {
+				fields = append(fields, err.Field)
+			}
+			assert.Equal(t, tt.wantFields, fields)
+		})
+	}
+}
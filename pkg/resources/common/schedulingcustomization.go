@@ -0,0 +1,82 @@
+package common
+
+import (
+	"encoding/json"
+	"fmt"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	"k8s.io/kubernetes/pkg/apis/scheduling"
+)
+
+// ClusterAgentPriorityClassName is the name of the PriorityClass Rancher creates for the cluster agent when its
+// scheduling customization sets a priority class.
+const ClusterAgentPriorityClassName = "cattle-cluster-agent-priority-class"
+
+const (
+	// maxAgentPriorityClassValue is the highest value of the priority class of an agent. Higher values are reserved
+	// for the system priority classes of Kubernetes.
+	maxAgentPriorityClassValue = int(scheduling.HighestUserDefinablePriority)
+	minAgentPriorityClassValue = -maxAgentPriorityClassValue
+)
+
+// AgentSchedulingCustomization is the scheduling customization of an agent deployment. The vendored Rancher API doesn't
+// have it yet, so it is decoded from the raw objects of requests.
+type AgentSchedulingCustomization struct {
+	PriorityClass *PriorityClassSpec `json:"priorityClass,omitempty"`
+}
+
+// PriorityClassSpec is the PriorityClass Rancher creates for an agent.
+type PriorityClassSpec struct {
+	Value            int                      `json:"value,omitempty"`
+	PreemptionPolicy *corev1.PreemptionPolicy `json:"preemptionPolicy,omitempty"`
+}
+
+// ClusterAgentSchedulingCustomization decodes the scheduling customization of the cluster agent of a raw management or
+// provisioning cluster, which both have it under spec.clusterAgentDeploymentCustomization. It returns nil if the raw
+// object is empty or the customization isn't set.
+func ClusterAgentSchedulingCustomization(raw []byte) (*AgentSchedulingCustomization, error) {
+	if len(raw) == 0 {
+		return nil, nil
+	}
+	var cluster struct {
+		Spec struct {
+			ClusterAgentDeploymentCustomization *struct {
+				SchedulingCustomization *AgentSchedulingCustomization `json:"schedulingCustomization,omitempty"`
+			} `json:"clusterAgentDeploymentCustomization,omitempty"`
+		} `json:"spec"`
+	}
+	if err := json.Unmarshal(raw, &cluster); err != nil {
+		return nil, fmt.Errorf("failed to decode scheduling customization: %w", err)
+	}
+	if cluster.Spec.ClusterAgentDeploymentCustomization == nil {
+		return nil, nil
+	}
+	return cluster.Spec.ClusterAgentDeploymentCustomization.SchedulingCustomization, nil
+}
+
+// GetPriorityClass returns the priority class of the scheduling customization, or nil if it isn't set.
+func (s *AgentSchedulingCustomization) GetPriorityClass() *PriorityClassSpec {
+	if s == nil {
+		return nil
+	}
+	return s.PriorityClass
+}
+
+// ValidatePriorityClass checks the priority class of an agent: its value must be outside of the range reserved for
+// the system priority classes, and its preemption policy must be one supported by Kubernetes.
+func ValidatePriorityClass(priorityClass *PriorityClassSpec, path *field.Path) field.ErrorList {
+	if priorityClass == nil {
+		return nil
+	}
+	var errList field.ErrorList
+	if priorityClass.Value < minAgentPriorityClassValue || priorityClass.Value > maxAgentPriorityClassValue {
+		errList = append(errList, field.Invalid(path.Child("value"), priorityClass.Value,
+			fmt.Sprintf("must be between %d and %d", minAgentPriorityClassValue, maxAgentPriorityClassValue)))
+	}
+	if policy := priorityClass.PreemptionPolicy; policy != nil && *policy != corev1.PreemptLowerPriority && *policy != corev1.PreemptNever {
+		errList = append(errList, field.NotSupported(path.Child("preemptionPolicy"), *policy,
+			[]corev1.PreemptionPolicy{corev1.PreemptLowerPriority, corev1.PreemptNever}))
+	}
+	return errList
+}
@@ -0,0 +1,98 @@
+package common
+
+import (
+	"testing"
+
+	"github.com/rancher/webhook/pkg/admission"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+)
+
+func TestClusterAgentSchedulingCustomization(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name string
+		raw  string
+		want *AgentSchedulingCustomization
+	}{
+		{
+			name: "empty object",
+		},
+		{
+			name: "no customization",
+			raw:  `{"spec":{"displayName":"local"}}`,
+		},
+		{
+			name: "no scheduling customization",
+			raw:  `{"spec":{"clusterAgentDeploymentCustomization":{"appendTolerations":[]}}}`,
+		},
+		{
+			name: "priority class",
+			raw:  `{"spec":{"clusterAgentDeploymentCustomization":{"schedulingCustomization":{"priorityClass":{"value":1000,"preemptionPolicy":"Never"}}}}}`,
+			want: &AgentSchedulingCustomization{PriorityClass: &PriorityClassSpec{Value: 1000, PreemptionPolicy: admission.Ptr(corev1.PreemptNever)}},
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			got, err := ClusterAgentSchedulingCustomization([]byte(tt.raw))
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+
+	_, err := ClusterAgentSchedulingCustomization([]byte(`{"spec":[]}`))
+	assert.Error(t, err)
+}
+
+func TestValidatePriorityClass(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name          string
+		priorityClass *PriorityClassSpec
+		wantFields    []string
+	}{
+		{
+			name: "no priority class",
+		},
+		{
+			name:          "highest user definable value",
+			priorityClass: &PriorityClassSpec{Value: 1000000000, PreemptionPolicy: admission.Ptr(corev1.PreemptLowerPriority)},
+		},
+		{
+			name:          "lowest value",
+			priorityClass: &PriorityClassSpec{Value: -1000000000},
+		},
+		{
+			name:          "system value",
+			priorityClass: &PriorityClassSpec{Value: 2000000000},
+			wantFields:    []string{"priorityClass.value"},
+		},
+		{
+			name:          "value too low",
+			priorityClass: &PriorityClassSpec{Value: -1000000001},
+			wantFields:    []string{"priorityClass.value"},
+		},
+		{
+			name:          "unsupported preemption policy",
+			priorityClass: &PriorityClassSpec{Value: 1, PreemptionPolicy: admission.Ptr(corev1.PreemptionPolicy("Always"))},
+			wantFields:    []string{"priorityClass.preemptionPolicy"},
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			var fields []string
+			for _, err := range ValidatePriorityClass(tt.priorityClass, field.NewPath("priorityClass")) {
+				fields = append(fields, err.Field)
+			}
+			assert.Equal(t, tt.wantFields, fields)
+		})
+	}
+}
@@ -0,0 +1,34 @@
+package common
+
+import (
+	"fmt"
+
+	v3 "github.com/rancher/rancher/pkg/apis/management.cattle.io/v3"
+	controllerv3 "github.com/rancher/webhook/pkg/generated/controllers/management.cattle.io/v3"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+)
+
+// AuthUserRefreshMinIntervalSetting is the name of the setting holding the minimum duration between two refreshes of the
+// group memberships of a user requested through its UserAttribute.
+const AuthUserRefreshMinIntervalSetting = "auth-user-refresh-min-interval"
+
+// SettingEffectiveValue returns the value of the setting if set, otherwise its default.
+func SettingEffectiveValue(setting *v3.Setting) string {
+	if setting.Value != "" {
+		return setting.Value
+	}
+	return setting.Default
+}
+
+// GetSettingValue returns the effective value of the setting with the given name.
+// An empty string is returned, without an error, if the setting does not exist.
+func GetSettingValue(settingCache controllerv3.SettingCache, name string) (string, error) {
+	setting, err := settingCache.Get(name)
+	if err != nil {
+		if apierrors.IsNotFound(err) {
+			return "", nil
+		}
+		return "", fmt.Errorf("failed to get setting %s: %w", name, err)
+	}
+	return SettingEffectiveValue(setting), nil
+}
@@ -0,0 +1,40 @@
+package common
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/rancher/webhook/pkg/admission"
+	"k8s.io/apimachinery/pkg/util/validation"
+)
+
+// UninstallServiceAccountEnv is the environment variable setting the service account, formatted as "namespace/name",
+// which is allowed to delete the objects Rancher bootstraps, such as the local cluster and the fleet-local workspace,
+// when uninstalling Rancher.
+const UninstallServiceAccountEnv = "CATTLE_WEBHOOK_UNINSTALL_SERVICE_ACCOUNT"
+
+// UninstallServiceAccount is the service account allowed to delete the objects Rancher bootstraps. No service account
+// is allowed if its name is empty.
+type UninstallServiceAccount struct {
+	Namespace string
+	Name      string
+}
+
+// UninstallServiceAccountFromEnv returns the UninstallServiceAccount set in the environment.
+func UninstallServiceAccountFromEnv() (UninstallServiceAccount, error) {
+	value := os.Getenv(UninstallServiceAccountEnv)
+	if value == "" {
+		return UninstallServiceAccount{}, nil
+	}
+	namespace, name, ok := strings.Cut(value, "/")
+	if !ok || len(validation.IsDNS1123Label(namespace)) > 0 || len(validation.IsDNS1123Subdomain(name)) > 0 {
+		return UninstallServiceAccount{}, fmt.Errorf("invalid value '%s' for %s: must be a service account formatted as namespace/name", value, UninstallServiceAccountEnv)
+	}
+	return UninstallServiceAccount{Namespace: namespace, Name: name}, nil
+}
+
+// IsUser returns true if the request was made by the service account.
+func (s UninstallServiceAccount) IsUser(request *admission.Request) bool {
+	return s.Name != "" && admission.IsServiceAccount(request.UserInfo, s.Namespace, s.Name)
+}
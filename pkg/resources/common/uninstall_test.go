@@ -0,0 +1,50 @@
+package common
+
+import (
+	"testing"
+
+	"github.com/rancher/webhook/pkg/admission"
+	"github.com/stretchr/testify/assert"
+	admissionv1 "k8s.io/api/admission/v1"
+	authenticationv1 "k8s.io/api/authentication/v1"
+)
+
+func TestUninstallServiceAccountFromEnv(t *testing.T) {
+	tests := []struct {
+		name    string
+		value   string
+		want    UninstallServiceAccount
+		wantErr bool
+	}{
+		{name: "unset"},
+		{name: "service account", value: "cattle-system/rancher-uninstall", want: UninstallServiceAccount{Namespace: "cattle-system", Name: "rancher-uninstall"}},
+		{name: "no namespace", value: "rancher-uninstall", wantErr: true},
+		{name: "empty name", value: "cattle-system/", wantErr: true},
+		{name: "invalid namespace", value: "Cattle_System/rancher-uninstall", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv(UninstallServiceAccountEnv, tt.value)
+			got, err := UninstallServiceAccountFromEnv()
+			if tt.wantErr {
+				assert.ErrorContains(t, err, UninstallServiceAccountEnv)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestUninstallServiceAccountIsUser(t *testing.T) {
+	t.Parallel()
+
+	request := func(username string) *admission.Request {
+		return &admission.Request{AdmissionRequest: admissionv1.AdmissionRequest{UserInfo: authenticationv1.UserInfo{Username: username}}}
+	}
+	account := UninstallServiceAccount{Namespace: "cattle-system", Name: "rancher-uninstall"}
+	assert.True(t, account.IsUser(request("system:serviceaccount:cattle-system:rancher-uninstall")))
+	assert.False(t, account.IsUser(request("system:serviceaccount:default:rancher-uninstall")))
+	assert.False(t, account.IsUser(request("rancher-uninstall")))
+	assert.False(t, UninstallServiceAccount{}.IsUser(request("system:serviceaccount::")))
+}
@@ -36,24 +36,18 @@ func CheckCreatorID(request *admission.Request, oldObj, newObj metav1.Object) *m
 	}
 
 	if request.Operation == admissionv1.Create {
-		// When creating the newObj the annotation must match the user creating it
-		if newAnnotations[CreatorIDAnn] != request.UserInfo.Username {
-			status.Message = "creatorID annotation does not match user"
+		// When creating the newObj the annotation is required, and must match the user creating it
+		if _, ok := newAnnotations[CreatorIDAnn]; !ok {
+			status.Message = "creatorID annotation is required"
 			return status
 		}
-		return nil
-	}
-
-	// Check that the anno doesn't exist on the update object, the only allowed
-	// update to this field is deleting it.
-	if _, ok := newAnnotations[CreatorIDAnn]; !ok {
-		return nil
+		oldObj = nil
 	}
 
-	// Compare old vs new because they need to be the same, no updates are allowed for
-	// the CreatorIDAnn
-	if oldObj.GetAnnotations()[CreatorIDAnn] != newAnnotations[CreatorIDAnn] {
-		status.Message = "creatorID annotation cannot be changed"
+	// CreatorIDAnnotation doesn't have AdminOnly changes, so no SubjectAccessReview is made and it can't fail
+	fieldErrs, _ := creatorIDAnnotations.Validate(request, nil, oldObj, newObj)
+	if len(fieldErrs) > 0 {
+		status.Message = fieldErrs.ToAggregate().Error()
 		return status
 	}
 
@@ -109,18 +103,11 @@ func CheckCreatorPrincipalName(userCache controllerv3.UserCache, obj metav1.Obje
 // The only allowed update is removing the annotations.
 // This function should only be called for the update operation.
 func CheckCreatorAnnotationsOnUpdate(oldObj, newObj metav1.Object) *field.Error {
-	oldAnnotations := oldObj.GetAnnotations()
-	newAnnotations := newObj.GetAnnotations()
-
-	for _, annotation := range []string{CreatorIDAnn, CreatorPrincipalNameAnn, NoCreatorRBACAnn} {
-		if _, ok := newAnnotations[annotation]; ok {
-			// If the annotation exists on the new object it must be the same as on the old object.
-			if oldAnnotations[annotation] != newAnnotations[annotation] {
-				return field.Invalid(annotationsFieldPath, annotation, "annotation is immutable")
-			}
-		}
+	// creatorAnnotations don't have AdminOnly or CreatorOnly changes on update, so the request isn't needed
+	fieldErrs, _ := creatorAnnotations.Validate(&admission.Request{}, nil, oldObj, newObj)
+	if len(fieldErrs) > 0 {
+		return fieldErrs[0]
 	}
-
 	return nil
 }
 
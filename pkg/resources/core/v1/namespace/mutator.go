@@ -0,0 +1,118 @@
+package namespace
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/rancher/webhook/pkg/admission"
+	v3 "github.com/rancher/webhook/pkg/generated/controllers/management.cattle.io/v3"
+	objectsv1 "github.com/rancher/webhook/pkg/generated/objects/core/v1"
+	"github.com/rancher/webhook/pkg/patch"
+	"github.com/rancher/webhook/pkg/resources/common"
+	"github.com/sirupsen/logrus"
+	admissionv1 "k8s.io/api/admission/v1"
+	admissionregistrationv1 "k8s.io/api/admissionregistration/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/utils/trace"
+)
+
+// creatorAnnotations are the annotations of a project which are copied onto the namespaces assigned to it.
+var creatorAnnotations = []string{common.CreatorIDAnn, common.CreatorPrincipalNameAnn}
+
+// Mutator implements admission.MutatingAdmissionWebhook.
+type Mutator struct {
+	projectCache v3.ProjectCache
+}
+
+// NewMutator returns a new mutator for namespaces.
+func NewMutator(projectCache v3.ProjectCache) *Mutator {
+	return &Mutator{
+		projectCache: projectCache,
+	}
+}
+
+// GVR returns the GroupVersionKind for this CRD.
+func (m *Mutator) GVR() schema.GroupVersionResource {
+	return schema.GroupVersionResource{
+		Version:  "v1",
+		Resource: "namespaces",
+	}
+}
+
+// Operations returns list of operations handled by this mutator.
+func (m *Mutator) Operations() []admissionregistrationv1.OperationType {
+	return []admissionregistrationv1.OperationType{admissionregistrationv1.Create, admissionregistrationv1.Update}
+}
+
+// MutatingWebhook returns the MutatingWebhook used for this CRD.
+func (m *Mutator) MutatingWebhook(clientConfig admissionregistrationv1.WebhookClientConfig) []admissionregistrationv1.MutatingWebhook {
+	mutatingWebhook := admission.NewDefaultMutatingWebhook(m, clientConfig, admissionregistrationv1.ClusterScope, m.Operations())
+	// The creator annotations are informational and the templates best effort, so namespace writes must not fail when
+	// the webhook is unavailable.
+	mutatingWebhook.FailurePolicy = admission.Ptr(admissionregistrationv1.Ignore)
+	return []admissionregistrationv1.MutatingWebhook{*mutatingWebhook}
+}
+
+// Admit copies the creator annotations of a project onto a namespace when the namespace is assigned to the project, and
+// adds the labels and annotations of the project's namespace metadata template to the namespaces of the project.
+func (m *Mutator) Admit(request *admission.Request) (*admissionv1.AdmissionResponse, error) {
+	listTrace := trace.New("namespaceMutator Admit", trace.Field{Key: "user", Value: request.UserInfo.Username})
+	defer listTrace.LogIfLong(admission.SlowTraceDuration)
+
+	oldNs, newNs, err := objectsv1.NamespaceOldAndNewFromRequest(&request.AdmissionRequest)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode namespace from request: %w", err)
+	}
+
+	projectID, ok := newNs.Annotations[projectNSAnnotation]
+	if !ok {
+		return admission.ResponseAllowed(), nil
+	}
+
+	clusterName, projectName, found := strings.Cut(projectID, ":")
+	if !found {
+		// the projectNamespaceAdmitter rejects malformed project annotations
+		return admission.ResponseAllowed(), nil
+	}
+	project, err := m.projectCache.Get(clusterName, projectName)
+	if err != nil {
+		if apierrors.IsNotFound(err) {
+			return admission.ResponseAllowed(), nil
+		}
+		return nil, fmt.Errorf("failed to get project %s: %w", projectID, err)
+	}
+
+	changed := false
+	if request.Operation == admissionv1.Create || oldNs.Annotations[projectNSAnnotation] != projectID {
+		for _, key := range creatorAnnotations {
+			value, ok := project.Annotations[key]
+			if !ok {
+				continue
+			}
+			// annotations already set on the namespace, e.g. by its own creator, take precedence
+			if _, ok := newNs.Annotations[key]; ok {
+				continue
+			}
+			newNs.Annotations[key] = value
+			changed = true
+		}
+	}
+	template, err := common.GetNamespaceMetadataTemplate(project)
+	if err != nil {
+		// the project validator rejects invalid templates, so this can only happen for projects which predate it
+		logrus.Warnf("[namespaceMutator] ignoring invalid namespace metadata template of project %s: %v", projectID, err)
+	} else if template != nil && template.Apply(newNs) {
+		changed = true
+	}
+	if !changed {
+		return admission.ResponseAllowed(), nil
+	}
+
+	response := &admissionv1.AdmissionResponse{}
+	if err := patch.CreatePatch(request.Object.Raw, newNs, response); err != nil {
+		return nil, fmt.Errorf("failed to create patch: %w", err)
+	}
+	response.Allowed = true
+	return response, nil
+}
@@ -0,0 +1,252 @@
+package namespace
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+
+	jsonpatch "github.com/evanphx/json-patch"
+	v3 "github.com/rancher/rancher/pkg/apis/management.cattle.io/v3"
+	"github.com/rancher/webhook/pkg/admission"
+	"github.com/rancher/webhook/pkg/resources/common"
+	"github.com/rancher/wrangler/v3/pkg/generic/fake"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+	v1 "k8s.io/api/admission/v1"
+	authenticationv1 "k8s.io/api/authentication/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+func TestMutatorAdmit(t *testing.T) {
+	t.Parallel()
+	const (
+		creatorID     = "u-creator"
+		principalName = "local://u-creator"
+	)
+	tests := []struct {
+		name            string
+		operation       v1.Operation
+		oldAnnotations  map[string]string
+		newAnnotations  map[string]string
+		newLabels       map[string]string
+		wantAnnotations map[string]string
+		wantLabels      map[string]string
+		wantErr         bool
+	}{
+		{
+			name:      "create in a project",
+			operation: v1.Create,
+			newAnnotations: map[string]string{
+				projectNSAnnotation: "c-123xyz:p-creator",
+			},
+			wantAnnotations: map[string]string{
+				projectNSAnnotation:            "c-123xyz:p-creator",
+				common.CreatorIDAnn:            creatorID,
+				common.CreatorPrincipalNameAnn: principalName,
+			},
+		},
+		{
+			name:      "update moving into a project",
+			operation: v1.Update,
+			oldAnnotations: map[string]string{
+				projectNSAnnotation: "c-123xyz:p-other",
+			},
+			newAnnotations: map[string]string{
+				projectNSAnnotation: "c-123xyz:p-creator",
+			},
+			wantAnnotations: map[string]string{
+				projectNSAnnotation:            "c-123xyz:p-creator",
+				common.CreatorIDAnn:            creatorID,
+				common.CreatorPrincipalNameAnn: principalName,
+			},
+		},
+		{
+			name:      "existing creator annotations are kept",
+			operation: v1.Create,
+			newAnnotations: map[string]string{
+				projectNSAnnotation: "c-123xyz:p-creator",
+				common.CreatorIDAnn: "u-namespace",
+			},
+			wantAnnotations: map[string]string{
+				projectNSAnnotation:            "c-123xyz:p-creator",
+				common.CreatorIDAnn:            "u-namespace",
+				common.CreatorPrincipalNameAnn: principalName,
+			},
+		},
+		{
+			name:      "update not changing the project",
+			operation: v1.Update,
+			oldAnnotations: map[string]string{
+				projectNSAnnotation: "c-123xyz:p-creator",
+			},
+			newAnnotations: map[string]string{
+				projectNSAnnotation: "c-123xyz:p-creator",
+			},
+		},
+		{
+			name:           "create outside of a project",
+			operation:      v1.Create,
+			newAnnotations: map[string]string{},
+		},
+		{
+			name:      "project without creator annotations",
+			operation: v1.Create,
+			newAnnotations: map[string]string{
+				projectNSAnnotation: "c-123xyz:p-nocreator",
+			},
+		},
+		{
+			name:      "project not found",
+			operation: v1.Create,
+			newAnnotations: map[string]string{
+				projectNSAnnotation: "c-123xyz:p-missing",
+			},
+		},
+		{
+			name:      "malformed project annotation",
+			operation: v1.Create,
+			newAnnotations: map[string]string{
+				projectNSAnnotation: "p-creator",
+			},
+		},
+		{
+			name:      "create in a project with a template",
+			operation: v1.Create,
+			newAnnotations: map[string]string{
+				projectNSAnnotation: "c-123xyz:p-template",
+			},
+			wantAnnotations: map[string]string{
+				projectNSAnnotation: "c-123xyz:p-template",
+				"example.com/team":  "team-a",
+			},
+			wantLabels: map[string]string{
+				"team": "a",
+			},
+		},
+		{
+			name:      "update not changing the project applies the template",
+			operation: v1.Update,
+			oldAnnotations: map[string]string{
+				projectNSAnnotation: "c-123xyz:p-template",
+			},
+			newAnnotations: map[string]string{
+				projectNSAnnotation: "c-123xyz:p-template",
+				"example.com/team":  "team-b",
+			},
+			wantAnnotations: map[string]string{
+				projectNSAnnotation: "c-123xyz:p-template",
+				"example.com/team":  "team-b",
+			},
+			wantLabels: map[string]string{
+				"team": "a",
+			},
+		},
+		{
+			name:      "existing labels are kept",
+			operation: v1.Create,
+			newAnnotations: map[string]string{
+				projectNSAnnotation: "c-123xyz:p-template",
+				"example.com/team":  "team-b",
+			},
+			newLabels: map[string]string{
+				"team": "b",
+			},
+		},
+		{
+			name:      "invalid template is ignored",
+			operation: v1.Create,
+			newAnnotations: map[string]string{
+				projectNSAnnotation: "c-123xyz:p-invalid-template",
+			},
+		},
+		{
+			name:      "project cache error",
+			operation: v1.Create,
+			newAnnotations: map[string]string{
+				projectNSAnnotation: "c-123xyz:p-error",
+			},
+			wantErr: true,
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+			ctrl := gomock.NewController(t)
+			projectCache := fake.NewMockCacheInterface[*v3.Project](ctrl)
+			projectCache.EXPECT().Get("c-123xyz", gomock.Any()).DoAndReturn(func(namespace, name string) (*v3.Project, error) {
+				switch name {
+				case "p-creator":
+					return &v3.Project{ObjectMeta: metav1.ObjectMeta{
+						Name:      name,
+						Namespace: namespace,
+						Annotations: map[string]string{
+							common.CreatorIDAnn:            creatorID,
+							common.CreatorPrincipalNameAnn: principalName,
+						},
+					}}, nil
+				case "p-template":
+					return &v3.Project{ObjectMeta: metav1.ObjectMeta{
+						Name:      name,
+						Namespace: namespace,
+						Annotations: map[string]string{
+							common.NamespaceMetadataTemplateAnn: `{"labels":{"team":"a","pod-security.kubernetes.io/enforce":"privileged"},"annotations":{"example.com/team":"team-a"}}`,
+						},
+					}}, nil
+				case "p-invalid-template":
+					return &v3.Project{ObjectMeta: metav1.ObjectMeta{
+						Name:        name,
+						Namespace:   namespace,
+						Annotations: map[string]string{common.NamespaceMetadataTemplateAnn: "labels: team"},
+					}}, nil
+				case "p-nocreator":
+					return &v3.Project{ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: namespace}}, nil
+				case "p-error":
+					return nil, errors.New("unexpected error")
+				default:
+					return nil, apierrors.NewNotFound(v3.Resource("projects"), name)
+				}
+			}).AnyTimes()
+
+			oldRaw, err := json.Marshal(&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "ns", Annotations: test.oldAnnotations}})
+			require.NoError(t, err)
+			newRaw, err := json.Marshal(&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "ns", Annotations: test.newAnnotations, Labels: test.newLabels}})
+			require.NoError(t, err)
+
+			response, err := NewMutator(projectCache).Admit(&admission.Request{
+				Context: context.Background(),
+				AdmissionRequest: v1.AdmissionRequest{
+					Operation: test.operation,
+					UserInfo:  authenticationv1.UserInfo{Username: "user"},
+					Object:    runtime.RawExtension{Raw: newRaw},
+					OldObject: runtime.RawExtension{Raw: oldRaw},
+				},
+			})
+			if test.wantErr {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			require.True(t, response.Allowed)
+
+			if test.wantAnnotations == nil {
+				assert.Nil(t, response.Patch)
+				return
+			}
+			patchObj, err := jsonpatch.DecodePatch(response.Patch)
+			require.NoError(t, err)
+			patched, err := patchObj.Apply(newRaw)
+			require.NoError(t, err)
+			got := &corev1.Namespace{}
+			require.NoError(t, json.Unmarshal(patched, got))
+			assert.Equal(t, test.wantAnnotations, got.Annotations)
+			assert.Equal(t, test.wantLabels, got.Labels)
+		})
+	}
+}
@@ -0,0 +1,53 @@
+package namespace
+
+import (
+	"fmt"
+
+	"github.com/rancher/webhook/pkg/admission"
+	objectsv1 "github.com/rancher/webhook/pkg/generated/objects/core/v1"
+	"github.com/rancher/webhook/pkg/resources/common"
+	admissionv1 "k8s.io/api/admission/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/utils/trace"
+)
+
+// nodePortQuotaAdmitter denies moving namespaces into a project if the node ports used by the Services of the
+// namespace don't fit in the servicesNodePorts quota left in the project.
+type nodePortQuotaAdmitter struct {
+	nodePorts *common.NodePortQuota
+}
+
+// Admit ensures that the node ports of a namespace moved to another project fit in the remaining quota of that project.
+func (n *nodePortQuotaAdmitter) Admit(request *admission.Request) (*admissionv1.AdmissionResponse, error) {
+	listTrace := trace.New("Namespace nodePortQuota Admit", trace.Field{Key: "user", Value: request.UserInfo.Username})
+	defer listTrace.LogIfLong(admission.SlowTraceDuration)
+
+	// a new namespace has no Services, so only namespaces moved between projects can exceed the quota
+	if n.nodePorts == nil || request.Operation != admissionv1.Update {
+		return admission.ResponseAllowed(), nil
+	}
+
+	oldNs, newNs, err := objectsv1.NamespaceOldAndNewFromRequest(&request.AdmissionRequest)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode namespace from request: %w", err)
+	}
+	projectID := newNs.Annotations[projectNSAnnotation]
+	if projectID == "" || projectID == oldNs.Annotations[projectNSAnnotation] {
+		return admission.ResponseAllowed(), nil
+	}
+	requested, err := n.nodePorts.NamespaceNodePorts(newNs.Name, nil)
+	if err != nil {
+		return nil, err
+	}
+	// the namespace may already be indexed in its new project, its Services are counted as requested instead
+	inNamespace := func(service *corev1.Service) bool { return service.Namespace == newNs.Name }
+	message, err := n.nodePorts.Check(projectID, requested, inNamespace)
+	if err != nil {
+		return nil, err
+	}
+	if message != "" {
+		return admission.ResponseBadRequest(fmt.Sprintf("moving namespace %s to project %s would exceed the project's node port quota: %s",
+			newNs.Name, projectID, message)), nil
+	}
+	return admission.ResponseAllowed(), nil
+}
@@ -0,0 +1,109 @@
+package namespace
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	v3 "github.com/rancher/rancher/pkg/apis/management.cattle.io/v3"
+	"github.com/rancher/webhook/pkg/admission"
+	"github.com/rancher/webhook/pkg/resources/common"
+	"github.com/rancher/wrangler/v3/pkg/generic/fake"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+	v1 "k8s.io/api/admission/v1"
+	authenticationv1 "k8s.io/api/authentication/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+func TestNodePortQuotaAdmitter(t *testing.T) {
+	t.Parallel()
+	const project = "c-123xyz:p-quota"
+	nodePortService := func(namespace string, ports int) *corev1.Service {
+		service := &corev1.Service{ObjectMeta: metav1.ObjectMeta{Namespace: namespace}, Spec: corev1.ServiceSpec{Type: corev1.ServiceTypeNodePort}}
+		for i := 0; i < ports; i++ {
+			service.Spec.Ports = append(service.Spec.Ports, corev1.ServicePort{Port: int32(80 + i)})
+		}
+		return service
+	}
+	tests := []struct {
+		name           string
+		operation      v1.Operation
+		namespace      string
+		oldAnnotations map[string]string
+		newAnnotations map[string]string
+		wantAllowed    bool
+	}{
+		{
+			name:           "create is not checked",
+			operation:      v1.Create,
+			namespace:      "large",
+			newAnnotations: map[string]string{projectNSAnnotation: project},
+			wantAllowed:    true,
+		},
+		{
+			name:           "project unchanged",
+			operation:      v1.Update,
+			namespace:      "large",
+			oldAnnotations: map[string]string{projectNSAnnotation: project},
+			newAnnotations: map[string]string{projectNSAnnotation: project},
+			wantAllowed:    true,
+		},
+		{
+			name:           "moved namespace fits",
+			operation:      v1.Update,
+			namespace:      "small",
+			oldAnnotations: map[string]string{projectNSAnnotation: "c-123xyz:p-old"},
+			newAnnotations: map[string]string{projectNSAnnotation: project},
+			wantAllowed:    true,
+		},
+		{
+			name:           "moved namespace exceeds quota",
+			operation:      v1.Update,
+			namespace:      "large",
+			oldAnnotations: map[string]string{projectNSAnnotation: "c-123xyz:p-old"},
+			newAnnotations: map[string]string{projectNSAnnotation: project},
+		},
+	}
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+			ctrl := gomock.NewController(t)
+			projectCache := fake.NewMockCacheInterface[*v3.Project](ctrl)
+			projectCache.EXPECT().Get("c-123xyz", "p-quota").Return(&v3.Project{
+				Spec: v3.ProjectSpec{ResourceQuota: &v3.ProjectResourceQuota{Limit: v3.ResourceQuotaLimit{ServicesNodePorts: "4"}}},
+			}, nil).AnyTimes()
+			namespaceCache := fake.NewMockNonNamespacedCacheInterface[*corev1.Namespace](ctrl)
+			namespaceCache.EXPECT().GetByIndex(common.NamespaceByProjectIndex, project).Return([]*corev1.Namespace{
+				{ObjectMeta: metav1.ObjectMeta{Name: "member", Annotations: map[string]string{projectNSAnnotation: project}}},
+			}, nil).AnyTimes()
+			serviceCache := fake.NewMockCacheInterface[*corev1.Service](ctrl)
+			serviceCache.EXPECT().List("member", labels.Everything()).Return([]*corev1.Service{nodePortService("member", 2)}, nil).AnyTimes()
+			serviceCache.EXPECT().List("small", labels.Everything()).Return([]*corev1.Service{nodePortService("small", 2)}, nil).AnyTimes()
+			serviceCache.EXPECT().List("large", labels.Everything()).Return([]*corev1.Service{nodePortService("large", 3)}, nil).AnyTimes()
+
+			oldRaw, err := json.Marshal(&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: test.namespace, Annotations: test.oldAnnotations}})
+			require.NoError(t, err)
+			newRaw, err := json.Marshal(&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: test.namespace, Annotations: test.newAnnotations}})
+			require.NoError(t, err)
+
+			admitter := nodePortQuotaAdmitter{nodePorts: common.NewNodePortQuota(projectCache, namespaceCache, serviceCache)}
+			response, err := admitter.Admit(&admission.Request{
+				Context: context.Background(),
+				AdmissionRequest: v1.AdmissionRequest{
+					Operation: test.operation,
+					UserInfo:  authenticationv1.UserInfo{Username: "user"},
+					Object:    runtime.RawExtension{Raw: newRaw},
+					OldObject: runtime.RawExtension{Raw: oldRaw},
+				},
+			})
+			require.NoError(t, err)
+			assert.Equal(t, test.wantAllowed, response.Allowed, response.Result)
+		})
+	}
+}
@@ -7,6 +7,7 @@ import (
 
 	"github.com/rancher/webhook/pkg/admission"
 	objectsv1 "github.com/rancher/webhook/pkg/generated/objects/core/v1"
+	"github.com/rancher/webhook/pkg/resources/common"
 	admissionv1 "k8s.io/api/admission/v1"
 	v1 "k8s.io/api/authorization/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
@@ -18,17 +19,19 @@ const (
 	fleetLocalNs        = "fleet-local"
 	localNs             = "local"
 	manageNSVerb        = "manage-namespaces"
-	projectNSAnnotation = "field.cattle.io/projectId"
+	projectNSAnnotation = common.ProjectIDAnnotation
 )
 
 type projectNamespaceAdmitter struct {
 	sar authorizationv1.SubjectAccessReviewInterface
+	// uninstallServiceAccount is allowed to delete the `local` and `fleet-local` namespaces.
+	uninstallServiceAccount common.UninstallServiceAccount
 }
 
 // Admit ensures that the:
 //   - user has permission to change the namespace annotation for project membership, effectively moving a project from
 //     one namespace to another.
-//   - deletion of `local` and `fleet-local` namespace is not allowed
+//   - deletion of `local` and `fleet-local` namespace is not allowed, except for the uninstall service account
 func (p *projectNamespaceAdmitter) Admit(request *admission.Request) (*admissionv1.AdmissionResponse, error) {
 	listTrace := trace.New("Namespace Admit", trace.Field{Key: "user", Value: request.UserInfo.Username})
 	defer listTrace.LogIfLong(admission.SlowTraceDuration)
@@ -41,7 +44,7 @@ func (p *projectNamespaceAdmitter) Admit(request *admission.Request) (*admission
 	}
 
 	if request.Operation == admissionv1.Delete {
-		if oldNs.Name == localNs || oldNs.Name == fleetLocalNs {
+		if (oldNs.Name == localNs || oldNs.Name == fleetLocalNs) && !p.uninstallServiceAccount.IsUser(request) {
 			return admission.ResponseBadRequest(fmt.Sprintf("deletion of namespace %q is not allowed\n", request.Name)), nil
 		}
 	}
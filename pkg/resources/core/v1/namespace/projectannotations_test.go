@@ -7,6 +7,7 @@ import (
 	"testing"
 
 	"github.com/rancher/webhook/pkg/admission"
+	"github.com/rancher/webhook/pkg/resources/common"
 	"github.com/stretchr/testify/assert"
 	v1 "k8s.io/api/admission/v1"
 	authenticationv1 "k8s.io/api/authentication/v1"
@@ -31,6 +32,7 @@ func TestValidateProjectNamespaceAnnotations(t *testing.T) {
 		wantError                 bool
 		wantAllowed               bool
 		namespaceName             string
+		username                  string
 	}{
 		{
 			name:                     "user can access, create",
@@ -204,6 +206,20 @@ func TestValidateProjectNamespaceAnnotations(t *testing.T) {
 			wantAllowed:   false,
 			namespaceName: "fleet-local",
 		},
+		{
+			name:          "Allow deletion of 'local' namespace by the uninstall service account",
+			operationType: v1.Delete,
+			wantAllowed:   true,
+			namespaceName: "local",
+			username:      "system:serviceaccount:cattle-system:rancher-uninstall",
+		},
+		{
+			name:          "Prevent deletion of 'fleet-local' namespace by another service account",
+			operationType: v1.Delete,
+			wantAllowed:   false,
+			namespaceName: "fleet-local",
+			username:      "system:serviceaccount:cattle-system:rancher",
+		},
 		{
 			name:          "Allow deletion of namespace",
 			operationType: v1.Delete,
@@ -217,7 +233,8 @@ func TestValidateProjectNamespaceAnnotations(t *testing.T) {
 			k8Fake := &k8testing.Fake{}
 			fakeSAR := &k8fake.FakeSubjectAccessReviews{Fake: &k8fake.FakeAuthorizationV1{Fake: k8Fake}}
 			admitter := projectNamespaceAdmitter{
-				sar: fakeSAR,
+				sar:                     fakeSAR,
+				uninstallServiceAccount: common.UninstallServiceAccount{Namespace: "cattle-system", Name: "rancher-uninstall"},
 			}
 			k8Fake.AddReactor("create", "subjectaccessreviews", func(action k8testing.Action) (handled bool, ret runtime.Object, err error) {
 				createAction := action.(k8testing.CreateActionImpl)
@@ -235,6 +252,9 @@ func TestValidateProjectNamespaceAnnotations(t *testing.T) {
 			})
 			request, err := createAnnotationNamespaceRequest(test.projectAnnotationValue, test.oldProjectAnnotationValue, test.includeProjectAnnotation, test.operationType, test.namespaceName)
 			assert.NoError(t, err)
+			if test.username != "" {
+				request.UserInfo.Username = test.username
+			}
 			response, err := admitter.Admit(request)
 			if test.wantError {
 				assert.Error(t, err)
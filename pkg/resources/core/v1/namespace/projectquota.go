@@ -0,0 +1,116 @@
+package namespace
+
+import (
+	"fmt"
+	"strings"
+
+	v3 "github.com/rancher/rancher/pkg/apis/management.cattle.io/v3"
+	"github.com/rancher/webhook/pkg/admission"
+	controllerv3 "github.com/rancher/webhook/pkg/generated/controllers/management.cattle.io/v3"
+	objectsv1 "github.com/rancher/webhook/pkg/generated/objects/core/v1"
+	"github.com/rancher/webhook/pkg/quota"
+	"github.com/rancher/webhook/pkg/resources/common"
+	corev1controller "github.com/rancher/wrangler/v3/pkg/generated/controllers/core/v1"
+	"github.com/sirupsen/logrus"
+	admissionv1 "k8s.io/api/admission/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/utils/trace"
+)
+
+const resourceQuotaAnnotation = "field.cattle.io/resourceQuota"
+
+// projectQuotaAdmitter denies moving namespaces into a project whose resource quota can't fit the quota of the
+// namespace alongside those of the namespaces already in the project.
+type projectQuotaAdmitter struct {
+	projectCache   controllerv3.ProjectCache
+	namespaceCache corev1controller.NamespaceCache
+}
+
+func newProjectQuotaAdmitter(projectCache controllerv3.ProjectCache, namespaceCache corev1controller.NamespaceCache) projectQuotaAdmitter {
+	if namespaceCache != nil {
+		namespaceCache.AddIndexer(common.NamespaceByProjectIndex, common.NamespaceByProject)
+	}
+	return projectQuotaAdmitter{
+		projectCache:   projectCache,
+		namespaceCache: namespaceCache,
+	}
+}
+
+// Admit ensures that the quota of a namespace moved to another project fits in the remaining quota of that project.
+func (p *projectQuotaAdmitter) Admit(request *admission.Request) (*admissionv1.AdmissionResponse, error) {
+	listTrace := trace.New("Namespace projectQuota Admit", trace.Field{Key: "user", Value: request.UserInfo.Username})
+	defer listTrace.LogIfLong(admission.SlowTraceDuration)
+
+	// projects are only available when the webhook runs with multi-cluster management
+	if p.projectCache == nil || p.namespaceCache == nil || request.Operation != admissionv1.Update {
+		return admission.ResponseAllowed(), nil
+	}
+
+	oldNs, newNs, err := objectsv1.NamespaceOldAndNewFromRequest(&request.AdmissionRequest)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode namespace from request: %w", err)
+	}
+	projectID := newNs.Annotations[projectNSAnnotation]
+	if projectID == "" || projectID == oldNs.Annotations[projectNSAnnotation] {
+		return admission.ResponseAllowed(), nil
+	}
+	clusterName, projectName, ok := strings.Cut(projectID, ":")
+	if !ok {
+		// the projectNamespaceAdmitter rejects malformed annotations
+		return admission.ResponseAllowed(), nil
+	}
+	project, err := p.projectCache.Get(clusterName, projectName)
+	if err != nil {
+		if apierrors.IsNotFound(err) {
+			return admission.ResponseAllowed(), nil
+		}
+		return nil, fmt.Errorf("failed to get project %s: %w", projectID, err)
+	}
+	if project.Spec.ResourceQuota == nil {
+		return admission.ResponseAllowed(), nil
+	}
+
+	used, err := namespaceQuota(newNs, project)
+	if err != nil {
+		return admission.ResponseBadRequest(fmt.Sprintf("invalid %s annotation: %v", resourceQuotaAnnotation, err)), nil
+	}
+	namespaces, err := p.namespaceCache.GetByIndex(common.NamespaceByProjectIndex, projectID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list namespaces of project %s: %w", projectID, err)
+	}
+	for _, namespace := range namespaces {
+		if namespace.Name == newNs.Name {
+			continue
+		}
+		namespaceLimit, err := namespaceQuota(namespace, project)
+		if err != nil {
+			logrus.Warnf("[namespace projectQuota] ignoring the quota of namespace %s: %v", namespace.Name, err)
+			continue
+		}
+		used = quota.Add(used, namespaceLimit)
+	}
+
+	limit, err := quota.FromLimit(&project.Spec.ResourceQuota.Limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse the resource quota of project %s: %w", projectID, err)
+	}
+	if fits, exceeded := quota.Fits(used, limit); !fits {
+		return admission.ResponseBadRequest(fmt.Sprintf("moving namespace %s to project %s would exceed the project's resource quota on fields: %s",
+			newNs.Name, projectID, quota.Format(exceeded))), nil
+	}
+	return admission.ResponseAllowed(), nil
+}
+
+// namespaceQuota returns the quota limit of the namespace, which is the project's default namespace quota unless the
+// namespace sets its own.
+func namespaceQuota(namespace *corev1.Namespace, project *v3.Project) (corev1.ResourceList, error) {
+	value := namespace.Annotations[resourceQuotaAnnotation]
+	if value == "" {
+		if project.Spec.NamespaceDefaultResourceQuota == nil {
+			return corev1.ResourceList{}, nil
+		}
+		return quota.FromLimit(&project.Spec.NamespaceDefaultResourceQuota.Limit)
+	}
+	return quota.FromNamespaceAnnotation(value)
+}
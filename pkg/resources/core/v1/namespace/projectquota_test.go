@@ -0,0 +1,178 @@
+package namespace
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+
+	v3 "github.com/rancher/rancher/pkg/apis/management.cattle.io/v3"
+	"github.com/rancher/webhook/pkg/admission"
+	"github.com/rancher/webhook/pkg/resources/common"
+	"github.com/rancher/wrangler/v3/pkg/generic/fake"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+	v1 "k8s.io/api/admission/v1"
+	authenticationv1 "k8s.io/api/authentication/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+func TestProjectQuotaAdmitter(t *testing.T) {
+	t.Parallel()
+	const (
+		oldProject = "c-123xyz:p-old"
+		newProject = "c-123xyz:p-quota"
+	)
+	quota := func(limit string) string {
+		return `{"limit":{"limitsCpu":"` + limit + `"}}`
+	}
+	tests := []struct {
+		name           string
+		operation      v1.Operation
+		oldAnnotations map[string]string
+		newAnnotations map[string]string
+		wantAllowed    bool
+		wantErr        bool
+	}{
+		{
+			name:           "create is not checked",
+			operation:      v1.Create,
+			newAnnotations: map[string]string{projectNSAnnotation: newProject, resourceQuotaAnnotation: quota("10")},
+			wantAllowed:    true,
+		},
+		{
+			name:           "project unchanged",
+			operation:      v1.Update,
+			oldAnnotations: map[string]string{projectNSAnnotation: newProject},
+			newAnnotations: map[string]string{projectNSAnnotation: newProject, resourceQuotaAnnotation: quota("10")},
+			wantAllowed:    true,
+		},
+		{
+			name:           "removed from project",
+			operation:      v1.Update,
+			oldAnnotations: map[string]string{projectNSAnnotation: oldProject},
+			newAnnotations: map[string]string{},
+			wantAllowed:    true,
+		},
+		{
+			name:           "quota fits",
+			operation:      v1.Update,
+			oldAnnotations: map[string]string{projectNSAnnotation: oldProject},
+			newAnnotations: map[string]string{projectNSAnnotation: newProject, resourceQuotaAnnotation: quota("1")},
+			wantAllowed:    true,
+		},
+		{
+			name:           "quota exceeded",
+			operation:      v1.Update,
+			oldAnnotations: map[string]string{projectNSAnnotation: oldProject},
+			newAnnotations: map[string]string{projectNSAnnotation: newProject, resourceQuotaAnnotation: quota("1500m")},
+			wantAllowed:    false,
+		},
+		{
+			name:           "default quota exceeded",
+			operation:      v1.Update,
+			oldAnnotations: map[string]string{projectNSAnnotation: oldProject},
+			newAnnotations: map[string]string{projectNSAnnotation: newProject},
+			wantAllowed:    false,
+		},
+		{
+			name:           "invalid quota annotation",
+			operation:      v1.Update,
+			oldAnnotations: map[string]string{projectNSAnnotation: oldProject},
+			newAnnotations: map[string]string{projectNSAnnotation: newProject, resourceQuotaAnnotation: "{"},
+			wantAllowed:    false,
+		},
+		{
+			name:           "project without quota",
+			operation:      v1.Update,
+			oldAnnotations: map[string]string{projectNSAnnotation: oldProject},
+			newAnnotations: map[string]string{projectNSAnnotation: "c-123xyz:p-noquota", resourceQuotaAnnotation: quota("10")},
+			wantAllowed:    true,
+		},
+		{
+			name:           "project not found",
+			operation:      v1.Update,
+			oldAnnotations: map[string]string{projectNSAnnotation: oldProject},
+			newAnnotations: map[string]string{projectNSAnnotation: "c-123xyz:p-missing", resourceQuotaAnnotation: quota("10")},
+			wantAllowed:    true,
+		},
+		{
+			name:           "project cache error",
+			operation:      v1.Update,
+			oldAnnotations: map[string]string{projectNSAnnotation: oldProject},
+			newAnnotations: map[string]string{projectNSAnnotation: "c-123xyz:p-error"},
+			wantErr:        true,
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+			ctrl := gomock.NewController(t)
+			projectCache := fake.NewMockCacheInterface[*v3.Project](ctrl)
+			projectCache.EXPECT().Get("c-123xyz", gomock.Any()).DoAndReturn(func(namespace, name string) (*v3.Project, error) {
+				switch name {
+				case "p-quota":
+					return &v3.Project{
+						ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: namespace},
+						Spec: v3.ProjectSpec{
+							ResourceQuota:                 &v3.ProjectResourceQuota{Limit: v3.ResourceQuotaLimit{LimitsCPU: "2"}},
+							NamespaceDefaultResourceQuota: &v3.NamespaceResourceQuota{Limit: v3.ResourceQuotaLimit{LimitsCPU: "1500m"}},
+						},
+					}, nil
+				case "p-noquota":
+					return &v3.Project{ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: namespace}}, nil
+				case "p-error":
+					return nil, errors.New("unexpected error")
+				default:
+					return nil, apierrors.NewNotFound(v3.Resource("projects"), name)
+				}
+			}).AnyTimes()
+			namespaceCache := fake.NewMockNonNamespacedCacheInterface[*corev1.Namespace](ctrl)
+			namespaceCache.EXPECT().AddIndexer(common.NamespaceByProjectIndex, gomock.Any())
+			namespaceCache.EXPECT().GetByIndex(common.NamespaceByProjectIndex, newProject).Return([]*corev1.Namespace{
+				{ObjectMeta: metav1.ObjectMeta{Name: "ns", Annotations: map[string]string{projectNSAnnotation: newProject, resourceQuotaAnnotation: quota("5")}}},
+				{ObjectMeta: metav1.ObjectMeta{Name: "other", Annotations: map[string]string{projectNSAnnotation: newProject, resourceQuotaAnnotation: quota("1")}}},
+				{ObjectMeta: metav1.ObjectMeta{Name: "invalid", Annotations: map[string]string{projectNSAnnotation: newProject, resourceQuotaAnnotation: "{"}}},
+			}, nil).AnyTimes()
+
+			oldRaw, err := json.Marshal(&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "ns", Annotations: test.oldAnnotations}})
+			require.NoError(t, err)
+			newRaw, err := json.Marshal(&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "ns", Annotations: test.newAnnotations}})
+			require.NoError(t, err)
+
+			admitter := newProjectQuotaAdmitter(projectCache, namespaceCache)
+			response, err := admitter.Admit(&admission.Request{
+				Context: context.Background(),
+				AdmissionRequest: v1.AdmissionRequest{
+					Operation: test.operation,
+					UserInfo:  authenticationv1.UserInfo{Username: "user"},
+					Object:    runtime.RawExtension{Raw: newRaw},
+					OldObject: runtime.RawExtension{Raw: oldRaw},
+				},
+			})
+			if test.wantErr {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, test.wantAllowed, response.Allowed, response.Result)
+		})
+	}
+}
+
+func TestProjectQuotaAdmitterWithoutCaches(t *testing.T) {
+	t.Parallel()
+	admitter := newProjectQuotaAdmitter(nil, nil)
+	response, err := admitter.Admit(&admission.Request{
+		Context:          context.Background(),
+		AdmissionRequest: v1.AdmissionRequest{Operation: v1.Update},
+	})
+	require.NoError(t, err)
+	assert.True(t, response.Allowed)
+}
@@ -3,6 +3,9 @@ package namespace
 
 import (
 	"github.com/rancher/webhook/pkg/admission"
+	controllerv3 "github.com/rancher/webhook/pkg/generated/controllers/management.cattle.io/v3"
+	"github.com/rancher/webhook/pkg/resources/common"
+	corev1controller "github.com/rancher/wrangler/v3/pkg/generated/controllers/core/v1"
 	admissionv1 "k8s.io/api/admissionregistration/v1"
 	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
@@ -21,18 +24,26 @@ type Validator struct {
 	psaAdmitter                psaLabelAdmitter
 	projectNamespaceAdmitter   projectNamespaceAdmitter
 	requestWithinLimitAdmitter requestLimitAdmitter
+	projectQuotaAdmitter       projectQuotaAdmitter
+	nodePortQuotaAdmitter      nodePortQuotaAdmitter
 }
 
-// NewValidator returns a new validator used for validation of namespace requests.
-func NewValidator(sar authorizationv1.SubjectAccessReviewInterface) *Validator {
+// NewValidator returns a new validator used for validation of namespace requests. The project quota of namespaces
+// moved between projects is only checked if projectCache and namespaceCache are not nil, and their node ports only if
+// nodePorts is not nil. The uninstallServiceAccount can delete the namespaces Rancher bootstraps.
+func NewValidator(sar authorizationv1.SubjectAccessReviewInterface, projectCache controllerv3.ProjectCache,
+	namespaceCache corev1controller.NamespaceCache, nodePorts *common.NodePortQuota, uninstallServiceAccount common.UninstallServiceAccount) *Validator {
 	return &Validator{
 		psaAdmitter: psaLabelAdmitter{
 			sar: sar,
 		},
 		projectNamespaceAdmitter: projectNamespaceAdmitter{
-			sar: sar,
+			sar:                     sar,
+			uninstallServiceAccount: uninstallServiceAccount,
 		},
 		requestWithinLimitAdmitter: requestLimitAdmitter{},
+		projectQuotaAdmitter:       newProjectQuotaAdmitter(projectCache, namespaceCache),
+		nodePortQuotaAdmitter:      nodePortQuotaAdmitter{nodePorts: nodePorts},
 	}
 }
 
@@ -94,7 +105,9 @@ func (v *Validator) ValidatingWebhook(clientConfig admissionv1.WebhookClientConf
 	return []admissionv1.ValidatingWebhook{*standardWebhook, *createWebhook, *kubeSystemCreateWebhook, *deleteWebhook}
 }
 
-// Admitters returns the psaAdmitter and the projectNamespaceAdmitter for namespaces.
+// Admitters returns the psaAdmitter, projectNamespaceAdmitter, requestWithinLimitAdmitter, projectQuotaAdmitter and
+// nodePortQuotaAdmitter for namespaces.
 func (v *Validator) Admitters() []admission.Admitter {
-	return []admission.Admitter{&v.psaAdmitter, &v.projectNamespaceAdmitter, &v.requestWithinLimitAdmitter}
+	return []admission.Admitter{&v.psaAdmitter, &v.projectNamespaceAdmitter, &v.requestWithinLimitAdmitter, &v.projectQuotaAdmitter,
+		&v.nodePortQuotaAdmitter}
 }
@@ -3,6 +3,7 @@ package namespace
 import (
 	"testing"
 
+	"github.com/rancher/webhook/pkg/resources/common"
 	"github.com/stretchr/testify/assert"
 	v1 "k8s.io/api/admissionregistration/v1"
 	corev1 "k8s.io/api/core/v1"
@@ -10,7 +11,7 @@ import (
 )
 
 func TestGVR(t *testing.T) {
-	validator := NewValidator(nil)
+	validator := NewValidator(nil, nil, nil, nil, common.UninstallServiceAccount{})
 	gvr := validator.GVR()
 	assert.Equal(t, "v1", gvr.Version)
 	assert.Equal(t, "namespaces", gvr.Resource)
@@ -18,7 +19,7 @@ func TestGVR(t *testing.T) {
 }
 
 func TestOperations(t *testing.T) {
-	validator := NewValidator(nil)
+	validator := NewValidator(nil, nil, nil, nil, common.UninstallServiceAccount{})
 	operations := validator.Operations()
 	assert.Len(t, operations, 3)
 	assert.Contains(t, operations, v1.Update)
@@ -26,9 +27,9 @@ func TestOperations(t *testing.T) {
 }
 
 func TestAdmitters(t *testing.T) {
-	validator := NewValidator(nil)
+	validator := NewValidator(nil, nil, nil, nil, common.UninstallServiceAccount{})
 	admitters := validator.Admitters()
-	assert.Len(t, admitters, 3)
+	assert.Len(t, admitters, 5)
 	hasPSAAdmitter := false
 	hasProjectNamespaceAdmitter := false
 	for i := range admitters {
@@ -54,7 +55,7 @@ func TestValidatingWebhook(t *testing.T) {
 		URL: &testURL,
 	}
 	wantURL := "test.cattle.io/namespaces"
-	validator := NewValidator(nil)
+	validator := NewValidator(nil, nil, nil, nil, common.UninstallServiceAccount{})
 	webhooks := validator.ValidatingWebhook(clientConfig)
 	assert.Len(t, webhooks, 4)
 	hasAllUpdateWebhook := false
@@ -0,0 +1,107 @@
+// Package node is used for validating connections to nodes.
+package node
+
+import (
+	"fmt"
+
+	"github.com/rancher/webhook/pkg/admission"
+	"github.com/rancher/webhook/pkg/auth"
+	controllerv3 "github.com/rancher/webhook/pkg/generated/controllers/management.cattle.io/v3"
+	admissionv1 "k8s.io/api/admission/v1"
+	admissionregistrationv1 "k8s.io/api/admissionregistration/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	authorizationv1 "k8s.io/client-go/kubernetes/typed/authorization/v1"
+	"k8s.io/utils/trace"
+)
+
+const (
+	// ProductionLabel is the label of the clusters whose nodes can only be connected to by users with the shell verb.
+	// Only the label of the local cluster is checked, as the connections to the nodes of downstream clusters are
+	// reviewed by their webhooks, which have no management clusters.
+	ProductionLabel = "webhook.cattle.io/production"
+	// shellVerb is the verb on nodes users need to connect to the nodes of production clusters.
+	shellVerb = "shell"
+	// localCluster is the name of the cluster the webhook runs in.
+	localCluster = "local"
+)
+
+var gvr = corev1.SchemeGroupVersion.WithResource("nodes")
+
+// NewValidator returns a new validator for connections to nodes.
+func NewValidator(clusterCache controllerv3.ClusterCache, sar authorizationv1.SubjectAccessReviewInterface) *Validator {
+	return &Validator{
+		admitter: admitter{
+			clusterCache: clusterCache,
+			sar:          sar,
+		},
+	}
+}
+
+// Validator validates connections to nodes.
+type Validator struct {
+	admitter admitter
+}
+
+// GVR returns the GroupVersionKind for this CRD.
+func (v *Validator) GVR() schema.GroupVersionResource {
+	return gvr
+}
+
+// Operations returns list of operations handled by this validator.
+func (v *Validator) Operations() []admissionregistrationv1.OperationType {
+	return []admissionregistrationv1.OperationType{admissionregistrationv1.Connect}
+}
+
+// ConnectSubresources returns the subresources of nodes whose connections are validated. The proxy subresource gives
+// access to the kubelet's API, including its exec and run endpoints.
+func (v *Validator) ConnectSubresources() []string {
+	return []string{"proxy"}
+}
+
+// ValidatingWebhook returns the ValidatingWebhook used for this CRD.
+func (v *Validator) ValidatingWebhook(clientConfig admissionregistrationv1.WebhookClientConfig) []admissionregistrationv1.ValidatingWebhook {
+	return []admissionregistrationv1.ValidatingWebhook{*admission.NewDefaultValidatingWebhook(v, clientConfig, admissionregistrationv1.ClusterScope, v.Operations())}
+}
+
+// Admitters returns the admitter objects used to validate connections to nodes.
+func (v *Validator) Admitters() []admission.Admitter {
+	return []admission.Admitter{&v.admitter}
+}
+
+type admitter struct {
+	clusterCache controllerv3.ClusterCache
+	sar          authorizationv1.SubjectAccessReviewInterface
+}
+
+// Admit handles the webhook admission request sent to this webhook.
+func (a *admitter) Admit(request *admission.Request) (*admissionv1.AdmissionResponse, error) {
+	listTrace := trace.New("nodeValidator Admit", trace.Field{Key: "user", Value: request.UserInfo.Username})
+	defer listTrace.LogIfLong(admission.SlowTraceDuration)
+
+	if request.Operation != admissionv1.Connect {
+		return admission.ResponseAllowed(), nil
+	}
+
+	cluster, err := a.clusterCache.Get(localCluster)
+	if apierrors.IsNotFound(err) {
+		return admission.ResponseAllowed(), nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get cluster %s: %w", localCluster, err)
+	}
+	if cluster.Labels[ProductionLabel] != "true" {
+		return admission.ResponseAllowed(), nil
+	}
+
+	canShell, err := auth.RequestUserHasVerb(request, gvr, a.sar, shellVerb, request.Name, "")
+	if err != nil {
+		return nil, fmt.Errorf("failed to check if user can %s node %s: %w", shellVerb, request.Name, err)
+	}
+	if !canShell {
+		return admission.ResponseFailedEscalation(fmt.Sprintf("user %s must have the %s verb on node %s to connect to the %s subresource of nodes of production clusters",
+			request.UserInfo.Username, shellVerb, request.Name, request.SubResource)), nil
+	}
+	return admission.ResponseAllowed(), nil
+}
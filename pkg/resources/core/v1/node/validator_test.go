@@ -0,0 +1,117 @@
+package node
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"testing"
+
+	v3 "github.com/rancher/rancher/pkg/apis/management.cattle.io/v3"
+	"github.com/rancher/webhook/pkg/admission"
+	"github.com/rancher/wrangler/v3/pkg/generic/fake"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+	admissionv1 "k8s.io/api/admission/v1"
+	authenticationv1 "k8s.io/api/authentication/v1"
+	authorizationv1 "k8s.io/api/authorization/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	k8fake "k8s.io/client-go/kubernetes/typed/authorization/v1/fake"
+	k8testing "k8s.io/client-go/testing"
+)
+
+func TestAdmit(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name        string
+		cluster     *v3.Cluster
+		clusterErr  error
+		username    string
+		wantAllowed bool
+		wantReview  bool
+		wantErr     bool
+	}{
+		{
+			name:        "production cluster with the shell verb",
+			cluster:     newCluster(map[string]string{ProductionLabel: "true"}),
+			username:    "shell-user",
+			wantAllowed: true,
+			wantReview:  true,
+		},
+		{
+			name:       "production cluster without the shell verb",
+			cluster:    newCluster(map[string]string{ProductionLabel: "true"}),
+			username:   "user",
+			wantReview: true,
+		},
+		{
+			name:        "cluster without the production label",
+			cluster:     newCluster(map[string]string{ProductionLabel: "false"}),
+			username:    "user",
+			wantAllowed: true,
+		},
+		{
+			name:        "missing local cluster",
+			clusterErr:  apierrors.NewNotFound(schema.GroupResource{Group: "management.cattle.io", Resource: "clusters"}, localCluster),
+			username:    "user",
+			wantAllowed: true,
+		},
+		{
+			name:       "failed to get the local cluster",
+			clusterErr: fmt.Errorf("cache error"),
+			username:   "user",
+			wantErr:    true,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			clusterCache := fake.NewMockNonNamespacedCacheInterface[*v3.Cluster](gomock.NewController(t))
+			clusterCache.EXPECT().Get(localCluster).Return(tt.cluster, tt.clusterErr)
+
+			reviewed := false
+			k8Fake := &k8testing.Fake{}
+			k8Fake.AddReactor("create", "subjectaccessreviews", func(action k8testing.Action) (bool, runtime.Object, error) {
+				review := action.(k8testing.CreateActionImpl).GetObject().(*authorizationv1.SubjectAccessReview)
+				attributes := review.Spec.ResourceAttributes
+				assert.Equal(t, shellVerb, attributes.Verb)
+				assert.Equal(t, "nodes", attributes.Resource)
+				assert.Equal(t, "node-1", attributes.Name)
+				reviewed = true
+				review.Status.Allowed = review.Spec.User == "shell-user"
+				return true, review, nil
+			})
+			sar := &k8fake.FakeSubjectAccessReviews{Fake: &k8fake.FakeAuthorizationV1{Fake: k8Fake}}
+
+			admitters := NewValidator(clusterCache, sar).Admitters()
+			require.Len(t, admitters, 1)
+			response, err := admitters[0].Admit(&admission.Request{
+				Context: context.Background(),
+				AdmissionRequest: admissionv1.AdmissionRequest{
+					Operation:   admissionv1.Connect,
+					Name:        "node-1",
+					SubResource: "proxy",
+					UserInfo:    authenticationv1.UserInfo{Username: tt.username},
+				},
+			})
+			if tt.wantErr {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.wantAllowed, response.Allowed)
+			if !tt.wantAllowed {
+				assert.Equal(t, int32(http.StatusForbidden), response.Result.Code)
+			}
+			assert.Equal(t, tt.wantReview, reviewed)
+		})
+	}
+}
+
+func newCluster(labels map[string]string) *v3.Cluster {
+	return &v3.Cluster{ObjectMeta: metav1.ObjectMeta{Name: localCluster, Labels: labels}}
+}
@@ -0,0 +1,63 @@
+package secret
+
+import (
+	"fmt"
+
+	"github.com/rancher/webhook/pkg/admission"
+	"github.com/rancher/webhook/pkg/auth"
+	objectsv1 "github.com/rancher/webhook/pkg/generated/objects/core/v1"
+	admissionv1 "k8s.io/api/admission/v1"
+	corev1 "k8s.io/api/core/v1"
+	authorizationv1 "k8s.io/client-go/kubernetes/typed/authorization/v1"
+	"k8s.io/utils/trace"
+)
+
+const (
+	// manageOwnedVerb is the verb on secrets which allows users to update and delete secrets of the types owned by Rancher.
+	manageOwnedVerb = "manage-owned"
+
+	cloudCredentialType = corev1.SecretType("provisioning.cattle.io/cloud-credential")
+	machinePlanType     = corev1.SecretType("rke.cattle.io/machine-plan")
+)
+
+// ownedTypes are the secret types managed by Rancher. The value is true if service accounts of the namespace of the
+// secret may also update it, which is the case for the machine plan secrets written by the system agent.
+var ownedTypes = map[corev1.SecretType]bool{
+	cloudCredentialType: false,
+	machinePlanType:     true,
+}
+
+// ownedTypeAdmitter protects secrets of the types owned by Rancher from being updated or deleted by users.
+type ownedTypeAdmitter struct {
+	sar authorizationv1.SubjectAccessReviewInterface
+}
+
+// Admit only allows updates and deletes of secrets of owned types by controllers and users with the manage-owned verb.
+func (o *ownedTypeAdmitter) Admit(request *admission.Request) (*admissionv1.AdmissionResponse, error) {
+	listTrace := trace.New("secret ownedType Admit", trace.Field{Key: "user", Value: request.UserInfo.Username})
+	defer listTrace.LogIfLong(admission.SlowTraceDuration)
+
+	if request.Operation != admissionv1.Update && request.Operation != admissionv1.Delete {
+		return admission.ResponseAllowed(), nil
+	}
+	secret, err := objectsv1.SecretFromRequest(&request.AdmissionRequest)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read secret from request: %w", err)
+	}
+	allowNamespaceServiceAccounts, owned := ownedTypes[secret.Type]
+	if !owned || admission.IsController(request) {
+		return admission.ResponseAllowed(), nil
+	}
+	if allowNamespaceServiceAccounts && admission.IsServiceAccountOfNamespace(request.UserInfo, secret.Namespace) {
+		return admission.ResponseAllowed(), nil
+	}
+	hasVerb, err := auth.RequestUserHasVerb(request, gvr, o.sar, manageOwnedVerb, secret.Name, secret.Namespace)
+	if err != nil {
+		return nil, fmt.Errorf("failed to check for the %s verb on secrets: %w", manageOwnedVerb, err)
+	}
+	if hasVerb {
+		return admission.ResponseAllowed(), nil
+	}
+	return admission.ResponseFailedEscalation(fmt.Sprintf("secrets of type %s are managed by Rancher and can only be changed by users with the %s verb on secrets",
+		secret.Type, manageOwnedVerb)), nil
+}
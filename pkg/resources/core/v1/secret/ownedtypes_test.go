@@ -0,0 +1,146 @@
+package secret
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/rancher/webhook/pkg/admission"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	admissionv1 "k8s.io/api/admission/v1"
+	authenticationv1 "k8s.io/api/authentication/v1"
+	authorizationv1 "k8s.io/api/authorization/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	k8fake "k8s.io/client-go/kubernetes/typed/authorization/v1/fake"
+	k8testing "k8s.io/client-go/testing"
+)
+
+func TestOwnedTypeAdmitter(t *testing.T) {
+	t.Parallel()
+	const (
+		allowedUser = "u-allowed"
+		errorUser   = "u-error"
+	)
+	tests := []struct {
+		name        string
+		operation   admissionv1.Operation
+		secretType  corev1.SecretType
+		userInfo    authenticationv1.UserInfo
+		wantAllowed bool
+		wantErr     bool
+	}{
+		{
+			name:        "create is allowed",
+			operation:   admissionv1.Create,
+			secretType:  cloudCredentialType,
+			userInfo:    authenticationv1.UserInfo{Username: "u-denied"},
+			wantAllowed: true,
+		},
+		{
+			name:        "update of other types is allowed",
+			operation:   admissionv1.Update,
+			secretType:  corev1.SecretTypeOpaque,
+			userInfo:    authenticationv1.UserInfo{Username: "u-denied"},
+			wantAllowed: true,
+		},
+		{
+			name:        "update of cloud credential without verb",
+			operation:   admissionv1.Update,
+			secretType:  cloudCredentialType,
+			userInfo:    authenticationv1.UserInfo{Username: "u-denied"},
+			wantAllowed: false,
+		},
+		{
+			name:        "delete of machine plan without verb",
+			operation:   admissionv1.Delete,
+			secretType:  machinePlanType,
+			userInfo:    authenticationv1.UserInfo{Username: "u-denied"},
+			wantAllowed: false,
+		},
+		{
+			name:        "update of cloud credential with verb",
+			operation:   admissionv1.Update,
+			secretType:  cloudCredentialType,
+			userInfo:    authenticationv1.UserInfo{Username: allowedUser},
+			wantAllowed: true,
+		},
+		{
+			name:        "delete of cloud credential by rancher",
+			operation:   admissionv1.Delete,
+			secretType:  cloudCredentialType,
+			userInfo:    authenticationv1.UserInfo{Username: "system:serviceaccount:cattle-system:rancher", Groups: []string{"system:serviceaccounts:cattle-system"}},
+			wantAllowed: true,
+		},
+		{
+			name:        "update of machine plan by service account of the namespace",
+			operation:   admissionv1.Update,
+			secretType:  machinePlanType,
+			userInfo:    authenticationv1.UserInfo{Username: "system:serviceaccount:fleet-default:m-1-machine-plan", Groups: []string{"system:serviceaccounts:fleet-default"}},
+			wantAllowed: true,
+		},
+		{
+			name:        "update of cloud credential by service account of the namespace",
+			operation:   admissionv1.Update,
+			secretType:  cloudCredentialType,
+			userInfo:    authenticationv1.UserInfo{Username: "system:serviceaccount:fleet-default:default", Groups: []string{"system:serviceaccounts:fleet-default"}},
+			wantAllowed: false,
+		},
+		{
+			name:       "sar error",
+			operation:  admissionv1.Update,
+			secretType: cloudCredentialType,
+			userInfo:   authenticationv1.UserInfo{Username: errorUser},
+			wantErr:    true,
+		},
+	}
+
+	k8Fake := &k8testing.Fake{}
+	k8Fake.AddReactor("create", "subjectaccessreviews", func(action k8testing.Action) (bool, runtime.Object, error) {
+		review := action.(k8testing.CreateActionImpl).GetObject().(*authorizationv1.SubjectAccessReview)
+		if review.Spec.User == errorUser {
+			return true, nil, errors.New("unexpected error")
+		}
+		attributes := review.Spec.ResourceAttributes
+		review.Status.Allowed = review.Spec.User == allowedUser && attributes.Verb == manageOwnedVerb &&
+			attributes.Resource == "secrets" && attributes.Name == "secret" && attributes.Namespace == "fleet-default"
+		return true, review, nil
+	})
+	admitter := ownedTypeAdmitter{sar: &k8fake.FakeSubjectAccessReviews{Fake: &k8fake.FakeAuthorizationV1{Fake: k8Fake}}}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+			raw, err := json.Marshal(&corev1.Secret{
+				ObjectMeta: metav1.ObjectMeta{Name: "secret", Namespace: "fleet-default"},
+				Type:       test.secretType,
+			})
+			require.NoError(t, err)
+			request := &admission.Request{
+				Context: context.Background(),
+				AdmissionRequest: admissionv1.AdmissionRequest{
+					Operation: test.operation,
+					UserInfo:  test.userInfo,
+				},
+			}
+			if test.operation != admissionv1.Delete {
+				request.Object.Raw = raw
+			}
+			if test.operation != admissionv1.Create {
+				request.OldObject.Raw = raw
+			}
+
+			response, err := admitter.Admit(request)
+			if test.wantErr {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, test.wantAllowed, response.Allowed)
+		})
+	}
+}
@@ -14,6 +14,7 @@ import (
 	rbacv1 "k8s.io/api/rbac/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime/schema"
+	authorizationv1 "k8s.io/client-go/kubernetes/typed/authorization/v1"
 	"k8s.io/utils/trace"
 )
 
@@ -25,12 +26,13 @@ const (
 
 // Validator implements admission.ValidatingAdmissionWebhook.
 type Validator struct {
-	admitter admitter
+	admitter          admitter
+	ownedTypeAdmitter ownedTypeAdmitter
 }
 
 // NewValidator creates a new secret validator which ensures secrets which own rbac objects aren't deleted with options
-// to orphan those RBAC resources.
-func NewValidator(roleCache v1.RoleCache, roleBindingCache v1.RoleBindingCache) *Validator {
+// to orphan those RBAC resources, and that secrets of types owned by Rancher are only changed by authorized users.
+func NewValidator(roleCache v1.RoleCache, roleBindingCache v1.RoleBindingCache, sar authorizationv1.SubjectAccessReviewInterface) *Validator {
 	roleCache.AddIndexer(roleOwnerIndex, func(obj *rbacv1.Role) ([]string, error) {
 		return secretOwnerIndexer(obj.ObjectMeta), nil
 	})
@@ -42,6 +44,9 @@ func NewValidator(roleCache v1.RoleCache, roleBindingCache v1.RoleBindingCache)
 			roleCache:        roleCache,
 			roleBindingCache: roleBindingCache,
 		},
+		ownedTypeAdmitter: ownedTypeAdmitter{
+			sar: sar,
+		},
 	}
 }
 
@@ -63,7 +68,7 @@ func (v *Validator) GVR() schema.GroupVersionResource {
 
 // Operations returns list of operations handled by this validator.
 func (v *Validator) Operations() []admissionregistrationv1.OperationType {
-	return []admissionregistrationv1.OperationType{admissionregistrationv1.Delete}
+	return []admissionregistrationv1.OperationType{admissionregistrationv1.Update, admissionregistrationv1.Delete}
 }
 
 // ValidatingWebhook returns the ValidatingWebhook used for this CRD.
@@ -75,7 +80,7 @@ func (v *Validator) ValidatingWebhook(clientConfig admissionregistrationv1.Webho
 
 // Admitters returns the admitter objects used to validate secrets.
 func (v *Validator) Admitters() []admission.Admitter {
-	return []admission.Admitter{&v.admitter}
+	return []admission.Admitter{&v.admitter, &v.ownedTypeAdmitter}
 }
 
 type admitter struct {
@@ -88,6 +93,9 @@ func (a *admitter) Admit(request *admission.Request) (*admissionv1.AdmissionResp
 	listTrace := trace.New("secret Admit", trace.Field{Key: "user", Value: request.UserInfo.Username})
 	defer listTrace.LogIfLong(admission.SlowTraceDuration)
 
+	if request.Operation != admissionv1.Delete {
+		return admission.ResponseAllowed(), nil
+	}
 	var deleteOpts metav1.DeleteOptions
 	err := json.Unmarshal(request.Options.Raw, &deleteOpts)
 	if err != nil {
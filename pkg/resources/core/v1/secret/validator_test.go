@@ -240,10 +240,10 @@ func TestAdmit(t *testing.T) {
 
 			roleCache.EXPECT().AddIndexer(roleOwnerIndex, gomock.Any())
 			roleBindingCache.EXPECT().AddIndexer(roleBindingOwnerIndex, gomock.Any())
-			validator := NewValidator(roleCache, roleBindingCache)
+			validator := NewValidator(roleCache, roleBindingCache, nil)
 
 			admitters := validator.Admitters()
-			assert.Len(t, admitters, 1)
+			assert.Len(t, admitters, 2)
 			response, err := admitters[0].Admit(&req)
 			if test.wantError {
 				assert.Error(t, err)
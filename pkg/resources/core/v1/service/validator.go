@@ -0,0 +1,110 @@
+// Package service is used for validating services.
+package service
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/rancher/webhook/pkg/admission"
+	"github.com/rancher/webhook/pkg/quota"
+	"github.com/rancher/webhook/pkg/resources/common"
+	corev1controller "github.com/rancher/wrangler/v3/pkg/generated/controllers/core/v1"
+	admissionv1 "k8s.io/api/admission/v1"
+	admissionregistrationv1 "k8s.io/api/admissionregistration/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/utils/trace"
+)
+
+var gvr = corev1.SchemeGroupVersion.WithResource("services")
+
+// NewValidator returns a new validator for services.
+func NewValidator(namespaceCache corev1controller.NamespaceCache, nodePorts *common.NodePortQuota) *Validator {
+	return &Validator{
+		admitter: admitter{
+			namespaceCache: namespaceCache,
+			nodePorts:      nodePorts,
+		},
+	}
+}
+
+// Validator validates services.
+type Validator struct {
+	admitter admitter
+}
+
+// GVR returns the GroupVersionKind for this CRD.
+func (v *Validator) GVR() schema.GroupVersionResource {
+	return gvr
+}
+
+// Operations returns list of operations handled by this validator.
+func (v *Validator) Operations() []admissionregistrationv1.OperationType {
+	return []admissionregistrationv1.OperationType{admissionregistrationv1.Create, admissionregistrationv1.Update}
+}
+
+// ValidatingWebhook returns the ValidatingWebhook used for this CRD.
+func (v *Validator) ValidatingWebhook(clientConfig admissionregistrationv1.WebhookClientConfig) []admissionregistrationv1.ValidatingWebhook {
+	return []admissionregistrationv1.ValidatingWebhook{*admission.NewDefaultValidatingWebhook(v, clientConfig, admissionregistrationv1.NamespacedScope, v.Operations())}
+}
+
+// Admitters returns the admitter objects used to validate services.
+func (v *Validator) Admitters() []admission.Admitter {
+	return []admission.Admitter{&v.admitter}
+}
+
+type admitter struct {
+	namespaceCache corev1controller.NamespaceCache
+	nodePorts      *common.NodePortQuota
+}
+
+// Admit handles the webhook admission request sent to this webhook.
+func (a *admitter) Admit(request *admission.Request) (*admissionv1.AdmissionResponse, error) {
+	listTrace := trace.New("serviceValidator Admit", trace.Field{Key: "user", Value: request.UserInfo.Username})
+	defer listTrace.LogIfLong(admission.SlowTraceDuration)
+
+	var newService corev1.Service
+	if err := json.Unmarshal(request.Object.Raw, &newService); err != nil {
+		return nil, fmt.Errorf("failed to decode service from request: %w", err)
+	}
+	requested := quota.NodePorts(&newService)
+	if request.Operation == admissionv1.Update {
+		var oldService corev1.Service
+		if err := json.Unmarshal(request.OldObject.Raw, &oldService); err != nil {
+			return nil, fmt.Errorf("failed to decode old service from request: %w", err)
+		}
+		// updates which don't add node ports are allowed, even if the project is already over its quota
+		if requested <= quota.NodePorts(&oldService) {
+			return admission.ResponseAllowed(), nil
+		}
+	}
+	if requested == 0 {
+		return admission.ResponseAllowed(), nil
+	}
+
+	namespace, err := a.namespaceCache.Get(request.Namespace)
+	if err != nil {
+		if apierrors.IsNotFound(err) {
+			return admission.ResponseAllowed(), nil
+		}
+		return nil, fmt.Errorf("failed to get namespace %s: %w", request.Namespace, err)
+	}
+	projectID := namespace.Annotations[common.ProjectIDAnnotation]
+	if projectID == "" {
+		return admission.ResponseAllowed(), nil
+	}
+	// the service being updated is counted with its new node ports
+	isService := func(service *corev1.Service) bool {
+		return service.Namespace == request.Namespace && service.Name == request.Name
+	}
+	message, err := a.nodePorts.Check(projectID, requested, isService)
+	if err != nil {
+		return nil, err
+	}
+	if message != "" {
+		return admission.ResponseBadRequest(fmt.Sprintf("service %s/%s would exceed the node port quota of its project: %s",
+			request.Namespace, request.Name, message)), nil
+	}
+	return admission.ResponseAllowed(), nil
+}
@@ -0,0 +1,172 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	v3 "github.com/rancher/rancher/pkg/apis/management.cattle.io/v3"
+	"github.com/rancher/webhook/pkg/admission"
+	"github.com/rancher/webhook/pkg/resources/common"
+	"github.com/rancher/wrangler/v3/pkg/generic/fake"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+	admissionv1 "k8s.io/api/admission/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+const project = "c-abcde:p-quota"
+
+func newService(namespace, name string, serviceType corev1.ServiceType, ports int) *corev1.Service {
+	service := &corev1.Service{
+		ObjectMeta: metav1.ObjectMeta{Namespace: namespace, Name: name},
+		Spec:       corev1.ServiceSpec{Type: serviceType},
+	}
+	for i := 0; i < ports; i++ {
+		service.Spec.Ports = append(service.Spec.Ports, corev1.ServicePort{Port: int32(80 + i)})
+	}
+	return service
+}
+
+func TestAdmit(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name       string
+		namespace  string
+		oldService *corev1.Service
+		newService *corev1.Service
+		allowed    bool
+	}{
+		{
+			name:       "cluster ip",
+			namespace:  "ns-1",
+			newService: newService("ns-1", "web", corev1.ServiceTypeClusterIP, 5),
+			allowed:    true,
+		},
+		{
+			name:       "node ports within quota",
+			namespace:  "ns-1",
+			newService: newService("ns-1", "web", corev1.ServiceTypeNodePort, 3),
+			allowed:    true,
+		},
+		{
+			name:       "node ports exceeding quota",
+			namespace:  "ns-1",
+			newService: newService("ns-1", "web", corev1.ServiceTypeNodePort, 4),
+		},
+		{
+			name:       "load balancer exceeding quota",
+			namespace:  "ns-2",
+			newService: newService("ns-2", "web", corev1.ServiceTypeLoadBalancer, 4),
+		},
+		{
+			name:       "namespace without project",
+			namespace:  "ns-unassigned",
+			newService: newService("ns-unassigned", "web", corev1.ServiceTypeNodePort, 10),
+			allowed:    true,
+		},
+		{
+			name:       "namespace of a project without node port quota",
+			namespace:  "ns-noquota",
+			newService: newService("ns-noquota", "web", corev1.ServiceTypeNodePort, 10),
+			allowed:    true,
+		},
+		{
+			name:       "missing namespace",
+			namespace:  "ns-missing",
+			newService: newService("ns-missing", "web", corev1.ServiceTypeNodePort, 10),
+			allowed:    true,
+		},
+		{
+			name:       "update adding node ports within quota",
+			namespace:  "ns-1",
+			oldService: newService("ns-1", "existing", corev1.ServiceTypeNodePort, 2),
+			newService: newService("ns-1", "existing", corev1.ServiceTypeNodePort, 4),
+			allowed:    true,
+		},
+		{
+			name:       "update adding node ports exceeding quota",
+			namespace:  "ns-1",
+			oldService: newService("ns-1", "existing", corev1.ServiceTypeNodePort, 2),
+			newService: newService("ns-1", "existing", corev1.ServiceTypeNodePort, 6),
+		},
+		{
+			name:       "update removing node ports",
+			namespace:  "ns-1",
+			oldService: newService("ns-1", "existing", corev1.ServiceTypeNodePort, 2),
+			newService: newService("ns-1", "existing", corev1.ServiceTypeClusterIP, 2),
+			allowed:    true,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			ctrl := gomock.NewController(t)
+
+			projectCache := fake.NewMockCacheInterface[*v3.Project](ctrl)
+			projectCache.EXPECT().Get("c-abcde", "p-quota").Return(&v3.Project{
+				Spec: v3.ProjectSpec{ResourceQuota: &v3.ProjectResourceQuota{Limit: v3.ResourceQuotaLimit{ServicesNodePorts: "6"}}},
+			}, nil).AnyTimes()
+			projectCache.EXPECT().Get("c-abcde", "p-noquota").Return(&v3.Project{
+				Spec: v3.ProjectSpec{ResourceQuota: &v3.ProjectResourceQuota{Limit: v3.ResourceQuotaLimit{Pods: "10"}}},
+			}, nil).AnyTimes()
+
+			namespaces := map[string]*corev1.Namespace{}
+			for name, projectID := range map[string]string{"ns-1": project, "ns-2": project, "ns-noquota": "c-abcde:p-noquota", "ns-unassigned": ""} {
+				namespace := &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: name}}
+				if projectID != "" {
+					namespace.Annotations = map[string]string{common.ProjectIDAnnotation: projectID}
+				}
+				namespaces[name] = namespace
+			}
+			namespaceCache := fake.NewMockNonNamespacedCacheInterface[*corev1.Namespace](ctrl)
+			namespaceCache.EXPECT().Get(gomock.Any()).DoAndReturn(func(name string) (*corev1.Namespace, error) {
+				if namespace, ok := namespaces[name]; ok {
+					return namespace, nil
+				}
+				return nil, apierrors.NewNotFound(corev1.Resource("namespaces"), name)
+			}).AnyTimes()
+			namespaceCache.EXPECT().GetByIndex(common.NamespaceByProjectIndex, project).Return(
+				[]*corev1.Namespace{namespaces["ns-1"], namespaces["ns-2"]}, nil).AnyTimes()
+
+			// the project uses 3 of its 6 node ports
+			serviceCache := fake.NewMockCacheInterface[*corev1.Service](ctrl)
+			serviceCache.EXPECT().List("ns-1", labels.Everything()).Return([]*corev1.Service{
+				newService("ns-1", "existing", corev1.ServiceTypeNodePort, 2),
+				newService("ns-1", "internal", corev1.ServiceTypeClusterIP, 3),
+			}, nil).AnyTimes()
+			serviceCache.EXPECT().List("ns-2", labels.Everything()).Return([]*corev1.Service{
+				newService("ns-2", "lb", corev1.ServiceTypeLoadBalancer, 1),
+			}, nil).AnyTimes()
+
+			request := &admission.Request{
+				Context: context.Background(),
+				AdmissionRequest: admissionv1.AdmissionRequest{
+					Operation: admissionv1.Create,
+					Namespace: tt.namespace,
+					Name:      tt.newService.Name,
+				},
+			}
+			raw, err := json.Marshal(tt.newService)
+			require.NoError(t, err)
+			request.Object = runtime.RawExtension{Raw: raw}
+			if tt.oldService != nil {
+				request.Operation = admissionv1.Update
+				raw, err := json.Marshal(tt.oldService)
+				require.NoError(t, err)
+				request.OldObject = runtime.RawExtension{Raw: raw}
+			}
+
+			validator := NewValidator(namespaceCache, common.NewNodePortQuota(projectCache, namespaceCache, serviceCache))
+			resp, err := validator.Admitters()[0].Admit(request)
+			require.NoError(t, err)
+			assert.Equal(t, tt.allowed, resp.Allowed, resp.Result)
+		})
+	}
+}
@@ -0,0 +1,192 @@
+package authconfig
+
+import (
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"net/url"
+	"slices"
+	"strings"
+
+	v3 "github.com/rancher/rancher/pkg/apis/management.cattle.io/v3"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+)
+
+const (
+	azureADProvider         = "azureADConfig"
+	activeDirectoryProvider = "activeDirectoryConfig"
+)
+
+// The types of the AuthConfigs sharing a configuration.
+var (
+	oidcProviders = []string{"oidcConfig", "keyCloakOIDCConfig", "genericOIDCConfig", "cognitoConfig"}
+	ldapProviders = []string{"openLdapConfig", "freeIpaConfig"}
+	samlProviders = []string{"pingConfig", "adfsConfig", "keyCloakConfig", "oktaConfig", "shibbolethConfig"}
+)
+
+// validateProvider validates the fields specific to the provider of the given type. Secrets such as passwords and
+// keys are stored by Rancher in Secrets and are not checked. Other providers are not validated.
+func validateProvider(providerType string, raw []byte) (field.ErrorList, error) {
+	switch {
+	case slices.Contains(oidcProviders, providerType):
+		var config v3.OIDCConfig
+		if err := json.Unmarshal(raw, &config); err != nil {
+			return nil, fmt.Errorf("failed to decode %s from request: %w", providerType, err)
+		}
+		return validateOIDC(&config), nil
+	case slices.Contains(ldapProviders, providerType):
+		var config v3.LdapConfig
+		if err := json.Unmarshal(raw, &config); err != nil {
+			return nil, fmt.Errorf("failed to decode %s from request: %w", providerType, err)
+		}
+		return validateLDAP(&config), nil
+	case slices.Contains(samlProviders, providerType):
+		var config v3.SamlConfig
+		if err := json.Unmarshal(raw, &config); err != nil {
+			return nil, fmt.Errorf("failed to decode %s from request: %w", providerType, err)
+		}
+		return validateSAML(&config), nil
+	case providerType == azureADProvider:
+		var config v3.AzureADConfig
+		if err := json.Unmarshal(raw, &config); err != nil {
+			return nil, fmt.Errorf("failed to decode %s from request: %w", providerType, err)
+		}
+		return validateAzureAD(&config), nil
+	case providerType == activeDirectoryProvider:
+		var config v3.ActiveDirectoryConfig
+		if err := json.Unmarshal(raw, &config); err != nil {
+			return nil, fmt.Errorf("failed to decode %s from request: %w", providerType, err)
+		}
+		return validateActiveDirectory(&config), nil
+	}
+	return nil, nil
+}
+
+func validateOIDC(config *v3.OIDCConfig) field.ErrorList {
+	errList := required(field.NewPath("clientId"), config.ClientID)
+	errList = append(errList, validateURL(field.NewPath("issuer"), config.Issuer, true)...)
+	errList = append(errList, validateURL(field.NewPath("rancherUrl"), config.RancherURL, true)...)
+	errList = append(errList, validateURL(field.NewPath("authEndpoint"), config.AuthEndpoint, false)...)
+	errList = append(errList, validateURL(field.NewPath("tokenEndpoint"), config.TokenEndpoint, false)...)
+	errList = append(errList, validateURL(field.NewPath("userInfoEndpoint"), config.UserInfoEndpoint, false)...)
+	errList = append(errList, validateURL(field.NewPath("jwksUrl"), config.JWKSUrl, false)...)
+	errList = append(errList, validateCertificate(field.NewPath("certificate"), config.Certificate)...)
+	return errList
+}
+
+func validateAzureAD(config *v3.AzureADConfig) field.ErrorList {
+	errList := required(field.NewPath("tenantId"), config.TenantID)
+	errList = append(errList, required(field.NewPath("applicationId"), config.ApplicationID)...)
+	errList = append(errList, validateURL(field.NewPath("endpoint"), config.Endpoint, true)...)
+	errList = append(errList, validateURL(field.NewPath("graphEndpoint"), config.GraphEndpoint, true)...)
+	errList = append(errList, validateURL(field.NewPath("tokenEndpoint"), config.TokenEndpoint, true)...)
+	errList = append(errList, validateURL(field.NewPath("authEndpoint"), config.AuthEndpoint, true)...)
+	errList = append(errList, validateURL(field.NewPath("rancherUrl"), config.RancherURL, true)...)
+	return errList
+}
+
+func validateLDAP(config *v3.LdapConfig) field.ErrorList {
+	errList := validateServers(field.NewPath("servers"), config.Servers)
+	errList = append(errList, validatePort(field.NewPath("port"), config.Port)...)
+	errList = append(errList, required(field.NewPath("serviceAccountDistinguishedName"), config.ServiceAccountDistinguishedName)...)
+	errList = append(errList, required(field.NewPath("userSearchBase"), config.UserSearchBase)...)
+	errList = append(errList, validateCertificate(field.NewPath("certificate"), config.Certificate)...)
+	return errList
+}
+
+func validateActiveDirectory(config *v3.ActiveDirectoryConfig) field.ErrorList {
+	errList := validateServers(field.NewPath("servers"), config.Servers)
+	errList = append(errList, validatePort(field.NewPath("port"), config.Port)...)
+	errList = append(errList, required(field.NewPath("serviceAccountUsername"), config.ServiceAccountUsername)...)
+	errList = append(errList, required(field.NewPath("userSearchBase"), config.UserSearchBase)...)
+	errList = append(errList, validateCertificate(field.NewPath("certificate"), config.Certificate)...)
+	return errList
+}
+
+func validateSAML(config *v3.SamlConfig) field.ErrorList {
+	errList := required(field.NewPath("idpMetadataContent"), config.IDPMetadataContent)
+	errList = append(errList, required(field.NewPath("spCert"), config.SpCert)...)
+	errList = append(errList, validateCertificate(field.NewPath("spCert"), config.SpCert)...)
+	errList = append(errList, validateURL(field.NewPath("rancherApiHost"), config.RancherAPIHost, true)...)
+	errList = append(errList, required(field.NewPath("uidField"), config.UIDField)...)
+	errList = append(errList, required(field.NewPath("userNameField"), config.UserNameField)...)
+	errList = append(errList, required(field.NewPath("displayNameField"), config.DisplayNameField)...)
+	return errList
+}
+
+func required(path *field.Path, value string) field.ErrorList {
+	if strings.TrimSpace(value) == "" {
+		return field.ErrorList{field.Required(path, "")}
+	}
+	return nil
+}
+
+// validateURL validates that value is an absolute http or https URL, if set or required.
+func validateURL(path *field.Path, value string, isRequired bool) field.ErrorList {
+	if value == "" {
+		if isRequired {
+			return field.ErrorList{field.Required(path, "")}
+		}
+		return nil
+	}
+	u, err := url.Parse(value)
+	if err != nil {
+		return field.ErrorList{field.Invalid(path, value, err.Error())}
+	}
+	if (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return field.ErrorList{field.Invalid(path, value, "must be an absolute http or https URL")}
+	}
+	return nil
+}
+
+// validateServers validates that servers lists at least one LDAP server, each a host name or IP address without a
+// scheme or port, as Rancher builds the URL of the servers from the port and TLS fields.
+func validateServers(path *field.Path, servers []string) field.ErrorList {
+	if len(servers) == 0 {
+		return field.ErrorList{field.Required(path, "at least one server is required")}
+	}
+	var errList field.ErrorList
+	for i, server := range servers {
+		u, err := url.Parse("ldap://" + server)
+		if server == "" || err != nil || u.Host != server || u.Port() != "" {
+			errList = append(errList, field.Invalid(path.Index(i), server, "must be a host name or IP address, without a scheme or port"))
+		}
+	}
+	return errList
+}
+
+func validatePort(path *field.Path, port int64) field.ErrorList {
+	// an unset port uses the default port of the provider
+	if port < 0 || port > 65535 {
+		return field.ErrorList{field.Invalid(path, port, "must be between 1 and 65535")}
+	}
+	return nil
+}
+
+// validateCertificate validates that value, if set, holds PEM encoded certificates.
+func validateCertificate(path *field.Path, value string) field.ErrorList {
+	if strings.TrimSpace(value) == "" {
+		return nil
+	}
+	rest := []byte(value)
+	count := 0
+	for {
+		var block *pem.Block
+		block, rest = pem.Decode(rest)
+		if block == nil {
+			break
+		}
+		if block.Type != "CERTIFICATE" {
+			return field.ErrorList{field.Invalid(path, field.OmitValueType{}, fmt.Sprintf("unexpected PEM block of type %s", block.Type))}
+		}
+		if _, err := x509.ParseCertificate(block.Bytes); err != nil {
+			return field.ErrorList{field.Invalid(path, field.OmitValueType{}, fmt.Sprintf("failed to parse certificate: %v", err))}
+		}
+		count++
+	}
+	if count == 0 || strings.TrimSpace(string(rest)) != "" {
+		return field.ErrorList{field.Invalid(path, field.OmitValueType{}, "must be PEM encoded certificates")}
+	}
+	return nil
+}
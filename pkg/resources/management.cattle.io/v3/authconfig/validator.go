@@ -0,0 +1,120 @@
+// Package authconfig is used for validating authconfigs.
+package authconfig
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+
+	v3 "github.com/rancher/rancher/pkg/apis/management.cattle.io/v3"
+	"github.com/rancher/webhook/pkg/admission"
+	admissionv1 "k8s.io/api/admission/v1"
+	admissionregistrationv1 "k8s.io/api/admissionregistration/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	"k8s.io/utils/trace"
+)
+
+// localProvider is the name of the AuthConfig of Rancher's local users.
+const localProvider = "local"
+
+var gvr = schema.GroupVersionResource{
+	Group:    "management.cattle.io",
+	Version:  "v3",
+	Resource: "authconfigs",
+}
+
+// NewValidator returns a new validator for authconfigs.
+func NewValidator() *Validator {
+	return &Validator{}
+}
+
+// Validator validates authconfigs.
+type Validator struct {
+	admitter admitter
+}
+
+// GVR returns the GroupVersionKind for this CRD.
+func (v *Validator) GVR() schema.GroupVersionResource {
+	return gvr
+}
+
+// Operations returns list of operations handled by this validator.
+func (v *Validator) Operations() []admissionregistrationv1.OperationType {
+	return []admissionregistrationv1.OperationType{admissionregistrationv1.Create, admissionregistrationv1.Update}
+}
+
+// ValidatingWebhook returns the ValidatingWebhook used for this CRD.
+func (v *Validator) ValidatingWebhook(clientConfig admissionregistrationv1.WebhookClientConfig) []admissionregistrationv1.ValidatingWebhook {
+	return []admissionregistrationv1.ValidatingWebhook{*admission.NewDefaultValidatingWebhook(v, clientConfig, admissionregistrationv1.ClusterScope, v.Operations())}
+}
+
+// Admitters returns the admitter objects used to validate authconfigs.
+func (v *Validator) Admitters() []admission.Admitter {
+	return []admission.Admitter{&v.admitter}
+}
+
+type admitter struct{}
+
+// Admit handles the webhook admission request sent to this webhook.
+func (a *admitter) Admit(request *admission.Request) (*admissionv1.AdmissionResponse, error) {
+	listTrace := trace.New("authConfigValidator Admit", trace.Field{Key: "user", Value: request.UserInfo.Username})
+	defer listTrace.LogIfLong(admission.SlowTraceDuration)
+
+	var newConfig v3.AuthConfig
+	if err := json.Unmarshal(request.Object.Raw, &newConfig); err != nil {
+		return nil, fmt.Errorf("failed to decode authconfig from request: %w", err)
+	}
+	if newConfig.DeletionTimestamp != nil {
+		return admission.ResponseAllowed(), nil
+	}
+
+	if request.Operation == admissionv1.Update {
+		var oldConfig v3.AuthConfig
+		if err := json.Unmarshal(request.OldObject.Raw, &oldConfig); err != nil {
+			return nil, fmt.Errorf("failed to decode old authconfig from request: %w", err)
+		}
+		if newConfig.Name == localProvider && oldConfig.Enabled && !newConfig.Enabled {
+			return admission.ResponseBadRequest(field.Forbidden(field.NewPath("enabled"),
+				"the local provider can't be disabled, as it would lock out the local admin").Error()), nil
+		}
+		// only changed configurations are validated, so that a broken configuration can still be disabled or have its
+		// status updated
+		unchanged, err := sameProviderFields(request.OldObject.Raw, request.Object.Raw)
+		if err != nil {
+			return nil, err
+		}
+		if unchanged && oldConfig.Enabled == newConfig.Enabled {
+			return admission.ResponseAllowed(), nil
+		}
+	}
+
+	if !newConfig.Enabled {
+		return admission.ResponseAllowed(), nil
+	}
+	fieldErrs, err := validateProvider(newConfig.Type, request.Object.Raw)
+	if err != nil {
+		return nil, err
+	}
+	if len(fieldErrs) != 0 {
+		return admission.ResponseBadRequest(fieldErrs.ToAggregate().Error()), nil
+	}
+	return admission.ResponseAllowed(), nil
+}
+
+// sameProviderFields returns true if the two authconfigs have the same fields, ignoring their metadata and status.
+func sameProviderFields(oldRaw, newRaw []byte) (bool, error) {
+	var oldFields, newFields map[string]any
+	if err := json.Unmarshal(oldRaw, &oldFields); err != nil {
+		return false, fmt.Errorf("failed to decode old authconfig from request: %w", err)
+	}
+	if err := json.Unmarshal(newRaw, &newFields); err != nil {
+		return false, fmt.Errorf("failed to decode authconfig from request: %w", err)
+	}
+	for _, fields := range []map[string]any{oldFields, newFields} {
+		for _, key := range []string{"apiVersion", "kind", "metadata", "status"} {
+			delete(fields, key)
+		}
+	}
+	return reflect.DeepEqual(oldFields, newFields), nil
+}
@@ -0,0 +1,187 @@
+package authconfig
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/json"
+	"encoding/pem"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/rancher/webhook/pkg/admission"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	admissionv1 "k8s.io/api/admission/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+func newCertificate(t *testing.T) string {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "idp"},
+		NotBefore:    time.Now(),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	require.NoError(t, err)
+	return string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}))
+}
+
+func TestAdmit(t *testing.T) {
+	t.Parallel()
+	cert := newCertificate(t)
+
+	oidc := map[string]any{
+		"metadata":   map[string]any{"name": "genericoidc"},
+		"type":       "genericOIDCConfig",
+		"enabled":    true,
+		"clientId":   "rancher",
+		"issuer":     "https://idp.example.com/realms/rancher",
+		"rancherUrl": "https://rancher.example.com/verify-auth",
+	}
+	ldap := map[string]any{
+		"metadata":                        map[string]any{"name": "openldap"},
+		"type":                            "openLdapConfig",
+		"enabled":                         true,
+		"servers":                         []string{"ldap.example.com"},
+		"port":                            636,
+		"tls":                             true,
+		"certificate":                     cert,
+		"serviceAccountDistinguishedName": "cn=admin,dc=example,dc=com",
+		"userSearchBase":                  "ou=users,dc=example,dc=com",
+	}
+	saml := map[string]any{
+		"metadata":           map[string]any{"name": "okta"},
+		"type":               "oktaConfig",
+		"enabled":            true,
+		"idpMetadataContent": "<EntityDescriptor/>",
+		"spCert":             cert,
+		"spKey":              "cattle-global-data:oktaconfig-spkey",
+		"rancherApiHost":     "https://rancher.example.com",
+		"uidField":           "uid",
+		"userNameField":      "userName",
+		"displayNameField":   "displayName",
+		"groupsField":        "groups",
+	}
+	azure := map[string]any{
+		"metadata":      map[string]any{"name": "azuread"},
+		"type":          "azureADConfig",
+		"enabled":       true,
+		"tenantId":      "tenant",
+		"applicationId": "app",
+		"endpoint":      "https://login.microsoftonline.com/",
+		"graphEndpoint": "https://graph.microsoft.com",
+		"tokenEndpoint": "https://login.microsoftonline.com/tenant/oauth2/v2.0/token",
+		"authEndpoint":  "https://login.microsoftonline.com/tenant/oauth2/v2.0/authorize",
+		"rancherUrl":    "https://rancher.example.com/verify-auth-azure",
+	}
+	activeDirectory := map[string]any{
+		"metadata":               map[string]any{"name": "activedirectory"},
+		"type":                   "activeDirectoryConfig",
+		"enabled":                true,
+		"servers":                []string{"10.0.0.1"},
+		"serviceAccountUsername": "rancher",
+		"userSearchBase":         "dc=example,dc=com",
+	}
+	local := map[string]any{
+		"metadata": map[string]any{"name": "local"},
+		"type":     "localConfig",
+		"enabled":  true,
+	}
+	with := func(config map[string]any, key string, value any) map[string]any {
+		copied := map[string]any{}
+		for k, v := range config {
+			copied[k] = v
+		}
+		copied[key] = value
+		return copied
+	}
+
+	tests := []struct {
+		name      string
+		oldConfig map[string]any
+		newConfig map[string]any
+		allowed   bool
+	}{
+		{name: "valid oidc", newConfig: oidc, allowed: true},
+		{name: "valid ldap", newConfig: ldap, allowed: true},
+		{name: "valid saml", newConfig: saml, allowed: true},
+		{name: "valid azuread", newConfig: azure, allowed: true},
+		{name: "valid active directory", newConfig: activeDirectory, allowed: true},
+		{name: "other provider", newConfig: local, allowed: true},
+		{name: "disabled invalid oidc", newConfig: with(with(oidc, "issuer", ""), "enabled", false), allowed: true},
+		{name: "empty oidc issuer", newConfig: with(oidc, "issuer", "")},
+		{name: "relative oidc issuer", newConfig: with(oidc, "issuer", "/realms/rancher")},
+		{name: "invalid oidc jwks url", newConfig: with(oidc, "jwksUrl", "ftp://idp.example.com/certs")},
+		{name: "invalid oidc certificate", newConfig: with(oidc, "certificate", "not a certificate")},
+		{name: "ldap server with a scheme", newConfig: with(ldap, "servers", []string{"ldaps://ldap.example.com"})},
+		{name: "ldap server with a port", newConfig: with(ldap, "servers", []string{"ldap.example.com:636"})},
+		{name: "no ldap servers", newConfig: with(ldap, "servers", []string{})},
+		{name: "invalid ldap port", newConfig: with(ldap, "port", 70000)},
+		{name: "missing ldap search base", newConfig: with(ldap, "userSearchBase", "")},
+		{name: "invalid saml certificate", newConfig: with(saml, "spCert", "-----BEGIN CERTIFICATE-----\nAAAA\n-----END CERTIFICATE-----\n")},
+		{name: "missing saml uid field", newConfig: with(saml, "uidField", "")},
+		{name: "missing azuread tenant", newConfig: with(azure, "tenantId", "")},
+		{name: "missing active directory service account", newConfig: with(activeDirectory, "serviceAccountUsername", "")},
+		{
+			name:      "enabling an invalid config",
+			oldConfig: with(with(oidc, "issuer", ""), "enabled", false),
+			newConfig: with(oidc, "issuer", ""),
+		},
+		{
+			name:      "status update of an invalid config",
+			oldConfig: with(oidc, "issuer", ""),
+			newConfig: with(with(oidc, "issuer", ""), "status", map[string]any{"conditions": []any{}}),
+			allowed:   true,
+		},
+		{
+			name:      "disabling an invalid config",
+			oldConfig: with(oidc, "issuer", ""),
+			newConfig: with(with(oidc, "issuer", ""), "enabled", false),
+			allowed:   true,
+		},
+		{
+			name:      "breaking a valid config",
+			oldConfig: ldap,
+			newConfig: with(ldap, "servers", []string{"ldap://ldap.example.com"}),
+		},
+		{
+			name:      "disabling the local provider",
+			oldConfig: local,
+			newConfig: with(local, "enabled", false),
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			request := &admission.Request{
+				Context: context.Background(),
+				AdmissionRequest: admissionv1.AdmissionRequest{
+					Operation: admissionv1.Create,
+				},
+			}
+			raw, err := json.Marshal(tt.newConfig)
+			require.NoError(t, err)
+			request.Object = runtime.RawExtension{Raw: raw}
+			if tt.oldConfig != nil {
+				request.Operation = admissionv1.Update
+				raw, err := json.Marshal(tt.oldConfig)
+				require.NoError(t, err)
+				request.OldObject = runtime.RawExtension{Raw: raw}
+			}
+
+			resp, err := NewValidator().Admitters()[0].Admit(request)
+			require.NoError(t, err)
+			assert.Equal(t, tt.allowed, resp.Allowed, resp.Result)
+		})
+	}
+}
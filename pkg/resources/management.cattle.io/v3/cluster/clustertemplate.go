@@ -0,0 +1,157 @@
+package cluster
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+
+	apisv3 "github.com/rancher/rancher/pkg/apis/management.cattle.io/v3"
+	"github.com/rancher/webhook/pkg/admission"
+	"github.com/rancher/webhook/pkg/auth"
+	"github.com/rancher/webhook/pkg/diff"
+	"github.com/rancher/webhook/pkg/resources/common"
+	admissionv1 "k8s.io/api/admission/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+)
+
+// ClusterTemplateEnforcementSetting is the name of the setting which, when "true", requires non-admin users to create
+// RKE clusters from an enabled ClusterTemplateRevision.
+const ClusterTemplateEnforcementSetting = "cluster-template-enforcement"
+
+// validateClusterTemplate enforces the cluster-template-enforcement setting: RKE clusters created by non-admin users
+// must reference an enabled ClusterTemplateRevision, and the fields set by the revision which are not answerable
+// through one of its questions cannot be overridden in the cluster spec.
+func (a *admitter) validateClusterTemplate(request *admission.Request, oldCluster, newCluster *apisv3.Cluster) (*admissionv1.AdmissionResponse, error) {
+	if a.settingCache == nil || a.revisionCache == nil || newCluster.Spec.RancherKubernetesEngineConfig == nil {
+		return admission.ResponseAllowed(), nil
+	}
+	value, err := common.GetSettingValue(a.settingCache, ClusterTemplateEnforcementSetting)
+	if err != nil {
+		return nil, err
+	}
+	if value != "true" {
+		return admission.ResponseAllowed(), nil
+	}
+
+	revisionName := newCluster.Spec.ClusterTemplateRevisionName
+	if revisionName == "" {
+		if request.Operation != admissionv1.Create {
+			return admission.ResponseAllowed(), nil
+		}
+		isAdmin, err := auth.RequestUserIsAdmin(request, a.sar)
+		if err != nil {
+			return nil, fmt.Errorf("failed to check if user is an administrator: %w", err)
+		}
+		if !isAdmin {
+			return admission.ResponseBadRequest("a ClusterTemplateRevision is required to create a cluster"), nil
+		}
+		return admission.ResponseAllowed(), nil
+	}
+
+	// The revision is referenced as "<namespace>:<name>".
+	namespace, name, found := strings.Cut(revisionName, ":")
+	if !found {
+		return admission.ResponseBadRequest(fmt.Sprintf("invalid ClusterTemplateRevision reference %q: must be of the form <namespace>:<name>", revisionName)), nil
+	}
+	revision, err := a.revisionCache.Get(namespace, name)
+	if err != nil {
+		if apierrors.IsNotFound(err) {
+			return admission.ResponseBadRequest(fmt.Sprintf("ClusterTemplateRevision %s not found", revisionName)), nil
+		}
+		return nil, fmt.Errorf("failed to get ClusterTemplateRevision %s: %w", revisionName, err)
+	}
+	revisionChanged := request.Operation == admissionv1.Create || oldCluster.Spec.ClusterTemplateRevisionName != revisionName
+	if revisionChanged && revision.Spec.Enabled != nil && !*revision.Spec.Enabled {
+		return admission.ResponseBadRequest(fmt.Sprintf("ClusterTemplateRevision %s is disabled", revisionName)), nil
+	}
+	if revision.Spec.ClusterConfig == nil {
+		return admission.ResponseAllowed(), nil
+	}
+
+	overridden, err := overriddenTemplateFields(revision, oldCluster, newCluster, revisionChanged)
+	if err != nil {
+		return nil, fmt.Errorf("failed to compare cluster with ClusterTemplateRevision %s: %w", revisionName, err)
+	}
+	if len(overridden) > 0 {
+		return admission.ResponseBadRequest(fmt.Sprintf("fields %s are set by ClusterTemplateRevision %s and cannot be overridden",
+			strings.Join(overridden, ", "), revisionName)), nil
+	}
+	return admission.ResponseAllowed(), nil
+}
+
+// overriddenTemplateFields returns the sorted paths of the fields set in the revision's cluster config whose value in
+// newCluster differs from the revision. Fields answerable through a question of the revision are not locked. Unless
+// checkAll is true, only fields whose value changed from oldCluster are reported, so that clusters which predate the
+// enforcement can still be updated.
+func overriddenTemplateFields(revision *apisv3.ClusterTemplateRevision, oldCluster, newCluster *apisv3.Cluster, checkAll bool) ([]string, error) {
+	templateFields, err := toMap(revision.Spec.ClusterConfig)
+	if err != nil {
+		return nil, err
+	}
+	newFields, err := toMap(&newCluster.Spec.ClusterSpecBase)
+	if err != nil {
+		return nil, err
+	}
+	changes, err := diff.Objects(&oldCluster.Spec.ClusterSpecBase, &newCluster.Spec.ClusterSpecBase)
+	if err != nil {
+		return nil, err
+	}
+
+	answerable := map[string]bool{}
+	for _, question := range revision.Spec.Questions {
+		answerable[question.Variable] = true
+	}
+
+	var overridden []string
+	var walk func(path string, template map[string]any)
+	walk = func(path string, template map[string]any) {
+		for key, templateValue := range template {
+			fieldPath := key
+			if path != "" {
+				fieldPath = path + "." + key
+			}
+			if answerable[fieldPath] || templateValue == nil || templateValue == "" {
+				continue
+			}
+			if nested, ok := templateValue.(map[string]any); ok {
+				walk(fieldPath, nested)
+				continue
+			}
+			newValue := lookupField(newFields, fieldPath)
+			if reflect.DeepEqual(templateValue, newValue) {
+				continue
+			}
+			if checkAll || changes.Changed(strings.Split(fieldPath, ".")...) {
+				overridden = append(overridden, fieldPath)
+			}
+		}
+	}
+	walk("", templateFields)
+	sort.Strings(overridden)
+	return overridden, nil
+}
+
+// toMap converts obj to its generic JSON representation.
+func toMap(obj any) (map[string]any, error) {
+	data, err := json.Marshal(obj)
+	if err != nil {
+		return nil, err
+	}
+	result := map[string]any{}
+	return result, json.Unmarshal(data, &result)
+}
+
+// lookupField returns the value at the dot separated path in fields, or nil if there is none.
+func lookupField(fields map[string]any, path string) any {
+	var current any = fields
+	for _, key := range strings.Split(path, ".") {
+		m, ok := current.(map[string]any)
+		if !ok {
+			return nil
+		}
+		current = m[key]
+	}
+	return current
+}
@@ -0,0 +1,207 @@
+package cluster
+
+import (
+	"context"
+	"testing"
+
+	v3 "github.com/rancher/rancher/pkg/apis/management.cattle.io/v3"
+	rketypes "github.com/rancher/rke/types"
+	"github.com/rancher/webhook/pkg/admission"
+	"github.com/rancher/wrangler/v3/pkg/generic/fake"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+	admissionv1 "k8s.io/api/admission/v1"
+	authorizationv1 "k8s.io/api/authorization/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	v1 "k8s.io/client-go/kubernetes/typed/authorization/v1"
+)
+
+type adminReviewer struct {
+	v1.SubjectAccessReviewExpansion
+	admin bool
+}
+
+func (a *adminReviewer) Create(
+	_ context.Context,
+	review *authorizationv1.SubjectAccessReview,
+	_ metav1.CreateOptions,
+) (*authorizationv1.SubjectAccessReview, error) {
+	review.Status.Allowed = a.admin && review.Spec.ResourceAttributes.Verb == "*"
+	return review, nil
+}
+
+func rkeCluster(revision, version, plugin string) *v3.Cluster {
+	return &v3.Cluster{
+		ObjectMeta: metav1.ObjectMeta{Name: "c-2bmj5"},
+		Spec: v3.ClusterSpec{
+			ClusterSpecBase: v3.ClusterSpecBase{
+				RancherKubernetesEngineConfig: &rketypes.RancherKubernetesEngineConfig{
+					Version: version,
+					Network: rketypes.NetworkConfig{Plugin: plugin},
+				},
+			},
+			ClusterTemplateRevisionName: revision,
+		},
+	}
+}
+
+func TestValidateClusterTemplate(t *testing.T) {
+	revisions := map[string]*v3.ClusterTemplateRevision{
+		"ctr-enabled": {
+			Spec: v3.ClusterTemplateRevisionSpec{
+				ClusterConfig: &v3.ClusterSpecBase{
+					RancherKubernetesEngineConfig: &rketypes.RancherKubernetesEngineConfig{
+						Version: "v1.28.9-rancher1-1",
+						Network: rketypes.NetworkConfig{Plugin: "canal"},
+					},
+				},
+				Questions: []v3.Question{{Variable: "rancherKubernetesEngineConfig.kubernetesVersion"}},
+			},
+		},
+		"ctr-disabled": {
+			Spec: v3.ClusterTemplateRevisionSpec{
+				Enabled:       admission.Ptr(false),
+				ClusterConfig: &v3.ClusterSpecBase{},
+			},
+		},
+	}
+
+	tests := []struct {
+		name          string
+		enforcement   string
+		admin         bool
+		operation     admissionv1.Operation
+		oldCluster    *v3.Cluster
+		newCluster    *v3.Cluster
+		expectAllowed bool
+	}{
+		{
+			name:          "enforcement disabled",
+			enforcement:   "false",
+			operation:     admissionv1.Create,
+			newCluster:    rkeCluster("", "v1.28.9-rancher1-1", "calico"),
+			expectAllowed: true,
+		},
+		{
+			name:          "create without a revision",
+			enforcement:   "true",
+			operation:     admissionv1.Create,
+			newCluster:    rkeCluster("", "v1.28.9-rancher1-1", "calico"),
+			expectAllowed: false,
+		},
+		{
+			name:          "admin creates without a revision",
+			enforcement:   "true",
+			admin:         true,
+			operation:     admissionv1.Create,
+			newCluster:    rkeCluster("", "v1.28.9-rancher1-1", "calico"),
+			expectAllowed: true,
+		},
+		{
+			name:          "create with a revision",
+			enforcement:   "true",
+			operation:     admissionv1.Create,
+			newCluster:    rkeCluster("cattle-global-data:ctr-enabled", "v1.28.9-rancher1-1", "canal"),
+			expectAllowed: true,
+		},
+		{
+			name:          "create answering a question",
+			enforcement:   "true",
+			operation:     admissionv1.Create,
+			newCluster:    rkeCluster("cattle-global-data:ctr-enabled", "v1.29.4-rancher1-1", "canal"),
+			expectAllowed: true,
+		},
+		{
+			name:          "create overriding a locked field",
+			enforcement:   "true",
+			operation:     admissionv1.Create,
+			newCluster:    rkeCluster("cattle-global-data:ctr-enabled", "v1.28.9-rancher1-1", "calico"),
+			expectAllowed: false,
+		},
+		{
+			name:          "create with a disabled revision",
+			enforcement:   "true",
+			operation:     admissionv1.Create,
+			newCluster:    rkeCluster("cattle-global-data:ctr-disabled", "v1.28.9-rancher1-1", "calico"),
+			expectAllowed: false,
+		},
+		{
+			name:          "create with a missing revision",
+			enforcement:   "true",
+			operation:     admissionv1.Create,
+			newCluster:    rkeCluster("cattle-global-data:ctr-missing", "v1.28.9-rancher1-1", "canal"),
+			expectAllowed: false,
+		},
+		{
+			name:          "create with a malformed revision reference",
+			enforcement:   "true",
+			operation:     admissionv1.Create,
+			newCluster:    rkeCluster("ctr-enabled", "v1.28.9-rancher1-1", "canal"),
+			expectAllowed: false,
+		},
+		{
+			name:          "update of a cluster without a revision",
+			enforcement:   "true",
+			operation:     admissionv1.Update,
+			oldCluster:    rkeCluster("", "v1.28.9-rancher1-1", "calico"),
+			newCluster:    rkeCluster("", "v1.29.4-rancher1-1", "calico"),
+			expectAllowed: true,
+		},
+		{
+			name:          "update keeping a field which predates the enforcement",
+			enforcement:   "true",
+			operation:     admissionv1.Update,
+			oldCluster:    rkeCluster("cattle-global-data:ctr-enabled", "v1.28.9-rancher1-1", "calico"),
+			newCluster:    rkeCluster("cattle-global-data:ctr-enabled", "v1.29.4-rancher1-1", "calico"),
+			expectAllowed: true,
+		},
+		{
+			name:          "update overriding a locked field",
+			enforcement:   "true",
+			operation:     admissionv1.Update,
+			oldCluster:    rkeCluster("cattle-global-data:ctr-enabled", "v1.28.9-rancher1-1", "canal"),
+			newCluster:    rkeCluster("cattle-global-data:ctr-enabled", "v1.28.9-rancher1-1", "calico"),
+			expectAllowed: false,
+		},
+		{
+			name:          "update of a cluster using a revision which was disabled later",
+			enforcement:   "true",
+			operation:     admissionv1.Update,
+			oldCluster:    rkeCluster("cattle-global-data:ctr-disabled", "v1.28.9-rancher1-1", "canal"),
+			newCluster:    rkeCluster("cattle-global-data:ctr-disabled", "v1.29.4-rancher1-1", "canal"),
+			expectAllowed: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			settingCache := fake.NewMockNonNamespacedCacheInterface[*v3.Setting](ctrl)
+			settingCache.EXPECT().Get(ClusterTemplateEnforcementSetting).Return(&v3.Setting{Value: tt.enforcement}, nil)
+			revisionCache := fake.NewMockCacheInterface[*v3.ClusterTemplateRevision](ctrl)
+			revisionCache.EXPECT().Get("cattle-global-data", gomock.Any()).DoAndReturn(func(_, name string) (*v3.ClusterTemplateRevision, error) {
+				if revision, ok := revisions[name]; ok {
+					return revision, nil
+				}
+				return nil, apierrors.NewNotFound(schema.GroupResource{}, name)
+			}).AnyTimes()
+
+			a := admitter{sar: &adminReviewer{admin: tt.admin}, settingCache: settingCache, revisionCache: revisionCache}
+			request := &admission.Request{
+				AdmissionRequest: admissionv1.AdmissionRequest{Operation: tt.operation},
+				Context:          context.Background(),
+			}
+			oldCluster := tt.oldCluster
+			if oldCluster == nil {
+				oldCluster = &v3.Cluster{}
+			}
+
+			response, err := a.validateClusterTemplate(request, oldCluster, tt.newCluster)
+			require.NoError(t, err)
+			assert.Equal(t, tt.expectAllowed, response.Allowed)
+		})
+	}
+}
@@ -17,6 +17,14 @@ import (
 	"k8s.io/apimachinery/pkg/runtime/schema"
 )
 
+const (
+	// VersionManagementAnno is the annotation of imported RKE2 and K3s clusters setting whether Rancher manages their
+	// Kubernetes version: "true", "false", or "system-default" to follow the imported-cluster-version-management setting.
+	VersionManagementAnno = "rancher.io/imported-cluster-version-management"
+	// VersionManagementSystemDefault is the value of VersionManagementAnno following the global setting.
+	VersionManagementSystemDefault = "system-default"
+)
+
 var managementGVR = schema.GroupVersionResource{
 	Group:    "management.cattle.io",
 	Version:  "v3",
@@ -53,9 +61,6 @@ func (m *ManagementClusterMutator) MutatingWebhook(clientConfig admissionregistr
 
 // Admit is the entrypoint for the mutator. Admit will return an error if it is unable to process the request.
 func (m *ManagementClusterMutator) Admit(request *admission.Request) (*admissionv1.AdmissionResponse, error) {
-	if request.DryRun != nil && *request.DryRun {
-		return admission.ResponseAllowed(), nil
-	}
 	oldCluster, newCluster, err := objectsv3.ClusterOldAndNewFromRequest(&request.AdmissionRequest)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get old and new clusters from request: %w", err)
@@ -64,10 +69,51 @@ func (m *ManagementClusterMutator) Admit(request *admission.Request) (*admission
 	if err != nil {
 		return nil, fmt.Errorf("unable to re-marshal new cluster: %w", err)
 	}
-	// no need to mutate the local cluster, or imported cluster which represents a KEv2 cluster (GKE/EKS/AKS) or v1 Provisioning Cluster
-	if newCluster.Name == "local" || newCluster.Spec.RancherKubernetesEngineConfig == nil {
-		return admission.ResponseAllowed(), nil
+	defaultVersionManagement(oldCluster, newCluster)
+	dryRun := request.DryRun != nil && *request.DryRun
+	// no need to mutate the PSA config of the local cluster, or imported cluster which represents a KEv2 cluster
+	// (GKE/EKS/AKS) or v1 Provisioning Cluster
+	if !dryRun && newCluster.Name != "local" && newCluster.Spec.RancherKubernetesEngineConfig != nil {
+		if err := m.mutatePSAConfig(request, oldCluster, newCluster); err != nil {
+			return nil, err
+		}
+	}
+
+	response := &admissionv1.AdmissionResponse{}
+	// we use the re-marshalled new cluster to make sure that the patch doesn't drop "unknown" fields which were
+	// in the json, but not in the cluster struct. This can occur due to out of date RKE versions
+	if err := patch.CreatePatch(newClusterRaw, newCluster, response); err != nil {
+		return response, fmt.Errorf("failed to create patch: %w", err)
+	}
+	response.Allowed = true
+	return response, nil
+}
+
+// defaultVersionManagement sets the version management annotation of imported RKE2 and K3s clusters to
+// system-default if it is missing, so that the cluster follows the imported-cluster-version-management setting.
+// Rancher only sets the driver of imported clusters once their agent connects, so the annotation is defaulted when the
+// driver becomes rke2 or k3s rather than when the cluster is created.
+func defaultVersionManagement(oldCluster, newCluster *apisv3.Cluster) {
+	if !isRKE2OrK3s(newCluster) || isRKE2OrK3s(oldCluster) {
+		return
+	}
+	if newCluster.Annotations[VersionManagementAnno] != "" {
+		return
 	}
+	if newCluster.Annotations == nil {
+		newCluster.Annotations = map[string]string{}
+	}
+	newCluster.Annotations[VersionManagementAnno] = VersionManagementSystemDefault
+}
+
+// isRKE2OrK3s returns true if the driver of the cluster is rke2 or k3s.
+func isRKE2OrK3s(cluster *apisv3.Cluster) bool {
+	return cluster.Status.Driver == apisv3.ClusterDriverRke2 || cluster.Status.Driver == apisv3.ClusterDriverK3s
+}
+
+// mutatePSAConfig updates the PodSecurity config of an RKE1 cluster to match its
+// PodSecurityAdmissionConfigurationTemplate.
+func (m *ManagementClusterMutator) mutatePSAConfig(request *admission.Request, oldCluster, newCluster *apisv3.Cluster) error {
 	newTemplateName := newCluster.Spec.DefaultPodSecurityAdmissionConfigurationTemplateName
 	oldTemplateName := oldCluster.Spec.DefaultPodSecurityAdmissionConfigurationTemplateName
 
@@ -75,37 +121,24 @@ func (m *ManagementClusterMutator) Admit(request *admission.Request) (*admission
 	if newTemplateName != "" {
 		err := m.setPSAConfig(newCluster)
 		if err != nil && !apierrors.IsNotFound(err) {
-			return nil, fmt.Errorf("failed to set PSAconfig: %w", err)
+			return fmt.Errorf("failed to set PSAconfig: %w", err)
 		}
-	} else {
-		switch request.Operation {
-		case admissionv1.Create:
-			return admission.ResponseAllowed(), nil
-		case admissionv1.Update:
-			// It is a valid use case where user switches from using PSACT to putting a PluginConfig for PSA under kube-api.AdmissionConfiguration,
-			// but it is not a valid use case where the PluginConfig for PSA has the same content as the one in the previous-set PSACT,
-			// so we need to drop it in this case.
-			if oldTemplateName != "" {
-				newConfig, found := psa.GetPluginConfigFromCluster(newCluster)
-				if found {
-					// found means there is a Plugin Config for PSA under the kube-api.admission_configuration section
-					oldConfig, _ := psa.GetPluginConfigFromCluster(oldCluster)
-					if reflect.DeepEqual(newConfig, oldConfig) {
-						psa.DropPSAPluginConfigFromAdmissionConfig(newCluster)
-					}
-				}
+		return nil
+	}
+	// It is a valid use case where user switches from using PSACT to putting a PluginConfig for PSA under kube-api.AdmissionConfiguration,
+	// but it is not a valid use case where the PluginConfig for PSA has the same content as the one in the previous-set PSACT,
+	// so we need to drop it in this case.
+	if request.Operation == admissionv1.Update && oldTemplateName != "" {
+		newConfig, found := psa.GetPluginConfigFromCluster(newCluster)
+		if found {
+			// found means there is a Plugin Config for PSA under the kube-api.admission_configuration section
+			oldConfig, _ := psa.GetPluginConfigFromCluster(oldCluster)
+			if reflect.DeepEqual(newConfig, oldConfig) {
+				psa.DropPSAPluginConfigFromAdmissionConfig(newCluster)
 			}
 		}
 	}
-
-	response := &admissionv1.AdmissionResponse{}
-	// we use the re-marshalled new cluster to make sure that the patch doesn't drop "unknown" fields which were
-	// in the json, but not in the cluster struct. This can occur due to out of date RKE versions
-	if err := patch.CreatePatch(newClusterRaw, newCluster, response); err != nil {
-		return response, fmt.Errorf("failed to create patch: %w", err)
-	}
-	response.Allowed = true
-	return response, nil
+	return nil
 }
 
 // setPSAConfig makes sure that the PodSecurity config under the admission_configuration section matches the
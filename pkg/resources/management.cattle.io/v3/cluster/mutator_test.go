@@ -44,3 +44,92 @@ func TestAdmitPreserveUnknownFields(t *testing.T) {
 	assert.Nil(t, err)
 	assert.Nil(t, response.Patch)
 }
+
+func TestAdmitDefaultVersionManagement(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name        string
+		operation   admissionv1.Operation
+		oldDriver   string
+		driver      string
+		annotations map[string]string
+		dryRun      bool
+		wantPatch   bool
+	}{
+		{
+			name:      "imported rke2 cluster",
+			operation: admissionv1.Create,
+			driver:    v3.ClusterDriverRke2,
+			wantPatch: true,
+		},
+		{
+			name:        "imported k3s cluster with an empty annotation",
+			operation:   admissionv1.Create,
+			driver:      v3.ClusterDriverK3s,
+			annotations: map[string]string{VersionManagementAnno: ""},
+			wantPatch:   true,
+		},
+		{
+			name:        "annotation set",
+			operation:   admissionv1.Create,
+			driver:      v3.ClusterDriverRke2,
+			annotations: map[string]string{VersionManagementAnno: "false"},
+		},
+		{
+			name:      "other driver",
+			operation: admissionv1.Create,
+			driver:    v3.ClusterDriverImported,
+		},
+		{
+			name:      "update setting the driver",
+			operation: admissionv1.Update,
+			driver:    v3.ClusterDriverRke2,
+			wantPatch: true,
+		},
+		{
+			name:      "update keeping the driver",
+			operation: admissionv1.Update,
+			oldDriver: v3.ClusterDriverK3s,
+			driver:    v3.ClusterDriverK3s,
+		},
+		{
+			name:      "dry run",
+			operation: admissionv1.Create,
+			driver:    v3.ClusterDriverRke2,
+			dryRun:    true,
+			wantPatch: true,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			cluster := &v3.Cluster{}
+			cluster.Name = "c-abcde"
+			cluster.Annotations = tt.annotations
+			cluster.Status.Driver = tt.driver
+			raw, err := json.Marshal(cluster)
+			assert.NoError(t, err)
+			cluster.Status.Driver = tt.oldDriver
+			oldRaw, err := json.Marshal(cluster)
+			assert.NoError(t, err)
+
+			m := ManagementClusterMutator{}
+			response, err := m.Admit(&admission.Request{
+				AdmissionRequest: admissionv1.AdmissionRequest{
+					Operation: tt.operation,
+					Object:    runtime.RawExtension{Raw: raw},
+					OldObject: runtime.RawExtension{Raw: oldRaw},
+					DryRun:    &tt.dryRun,
+				},
+			})
+			assert.NoError(t, err)
+			assert.True(t, response.Allowed)
+			if !tt.wantPatch {
+				assert.Nil(t, response.Patch)
+				return
+			}
+			assert.Contains(t, string(response.Patch), VersionManagementSystemDefault)
+		})
+	}
+}
@@ -9,36 +9,61 @@ import (
 	"github.com/blang/semver"
 	apisv3 "github.com/rancher/rancher/pkg/apis/management.cattle.io/v3"
 	"github.com/rancher/webhook/pkg/admission"
+	"github.com/rancher/webhook/pkg/auth"
+	"github.com/rancher/webhook/pkg/diff"
 	v3 "github.com/rancher/webhook/pkg/generated/controllers/management.cattle.io/v3"
 	objectsv3 "github.com/rancher/webhook/pkg/generated/objects/management.cattle.io/v3"
 	psa "github.com/rancher/webhook/pkg/podsecurityadmission"
 	"github.com/rancher/webhook/pkg/resources/common"
+	"github.com/sirupsen/logrus"
 	admissionv1 "k8s.io/api/admission/v1"
 	admissionregistrationv1 "k8s.io/api/admissionregistration/v1"
 	authenticationv1 "k8s.io/api/authentication/v1"
 	v1 "k8s.io/api/authorization/v1"
+	corev1 "k8s.io/api/core/v1"
+	schedulingv1 "k8s.io/api/scheduling/v1"
 	"k8s.io/apimachinery/pkg/api/equality"
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/validation/field"
 	authorizationv1 "k8s.io/client-go/kubernetes/typed/authorization/v1"
 )
 
 var parsedRangeLessThan123 = semver.MustParseRange("< 1.23.0-rancher0")
 
-const localCluster = "local"
+var priorityClassGVK = schedulingv1.SchemeGroupVersion.WithKind("PriorityClass")
+
+const (
+	localCluster = "local"
+	// clusterByDisplayNameIndex indexes the management clusters by their display name.
+	clusterByDisplayNameIndex = "webhook.cattle.io/cluster-by-display-name"
+)
 
 // NewValidator returns a new validator for management clusters.
 func NewValidator(
 	sar authorizationv1.SubjectAccessReviewInterface,
 	cache v3.PodSecurityAdmissionConfigurationTemplateCache,
 	userCache v3.UserCache,
+	settingCache v3.SettingCache,
+	revisionCache v3.ClusterTemplateRevisionCache,
+	clusterCache v3.ClusterCache,
+	dynamic dynamicLister,
 ) *Validator {
+	if clusterCache != nil {
+		clusterCache.AddIndexer(clusterByDisplayNameIndex, clusterByDisplayName)
+	}
 	return &Validator{
 		admitter: admitter{
-			sar:       sar,
-			psact:     cache,
-			userCache: userCache, // userCache is nil for downstream clusters.
+			sar:           sar,
+			psact:         cache,
+			userCache:     userCache,     // userCache is nil for downstream clusters.
+			settingCache:  settingCache,  // settingCache is nil for downstream clusters.
+			revisionCache: revisionCache, // revisionCache is nil for downstream clusters.
+			clusterCache:  clusterCache,  // clusterCache is nil for downstream clusters.
+			dynamic:       dynamic,
 		},
 	}
 }
@@ -53,6 +78,11 @@ func (v *Validator) GVR() schema.GroupVersionResource {
 	return managementGVR
 }
 
+// Subresources returns the subresources of clusters whose writes are only allowed for controllers.
+func (v *Validator) Subresources() []string {
+	return []string{"status"}
+}
+
 // Operations returns list of operations handled by this validator.
 func (v *Validator) Operations() []admissionregistrationv1.OperationType {
 	return []admissionregistrationv1.OperationType{admissionregistrationv1.Create, admissionregistrationv1.Update, admissionregistrationv1.Delete}
@@ -71,9 +101,18 @@ func (v *Validator) Admitters() []admission.Admitter {
 }
 
 type admitter struct {
-	sar       authorizationv1.SubjectAccessReviewInterface
-	psact     v3.PodSecurityAdmissionConfigurationTemplateCache
-	userCache v3.UserCache
+	sar           authorizationv1.SubjectAccessReviewInterface
+	psact         v3.PodSecurityAdmissionConfigurationTemplateCache
+	userCache     v3.UserCache
+	settingCache  v3.SettingCache
+	revisionCache v3.ClusterTemplateRevisionCache
+	clusterCache  v3.ClusterCache
+	dynamic       dynamicLister
+}
+
+// dynamicLister lists the objects of resources which the webhook doesn't have a typed cache for.
+type dynamicLister interface {
+	List(gvk schema.GroupVersionKind, namespace string, selector labels.Selector) ([]runtime.Object, error)
 }
 
 // Admit handles the webhook admission request sent to this webhook.
@@ -117,12 +156,57 @@ func (a *admitter) Admit(request *admission.Request) (*admissionv1.AdmissionResp
 	}
 
 	if request.Operation == admissionv1.Create || request.Operation == admissionv1.Update {
+		if a.settingCache != nil {
+			fieldErrs, err := common.ValidateAgentEnvVarNames(a.settingCache, field.NewPath("spec", "agentEnvVars"),
+				envVarNames(oldCluster.Spec.AgentEnvVars), envVarNames(newCluster.Spec.AgentEnvVars))
+			if err != nil {
+				return nil, fmt.Errorf("failed to validate agent env vars: %w", err)
+			}
+			if len(fieldErrs) > 0 {
+				return admission.ResponseBadRequest(fieldErrs.ToAggregate().Error()), nil
+			}
+		}
+
+		// the raw objects are compared, as the vendored Rancher API doesn't have all the fields of the cluster
+		changes, err := diff.JSON(request.OldObject.Raw, request.Object.Raw)
+		if err != nil {
+			return nil, fmt.Errorf("failed to compare old and new clusters: %w", err)
+		}
+
+		if fieldErrs := validateAgentDeploymentCustomizations(changes, newCluster); len(fieldErrs) > 0 {
+			return admission.ResponseBadRequest(fieldErrs.ToAggregate().Error()), nil
+		}
+
+		fieldErrs, err := a.validateSchedulingCustomization(request, changes, newCluster)
+		if err != nil {
+			return nil, fmt.Errorf("failed to validate scheduling customization: %w", err)
+		}
+		if len(fieldErrs) > 0 {
+			return admission.ResponseBadRequest(fieldErrs.ToAggregate().Error()), nil
+		}
+
+		fieldErr, err := a.validateUniqueDisplayName(request, oldCluster, newCluster)
+		if err != nil {
+			return nil, fmt.Errorf("failed to validate display name: %w", err)
+		}
+		if fieldErr != nil {
+			return admission.ResponseBadRequest(fieldErr.Error()), nil
+		}
+
 		// no need to validate the PodSecurityAdmissionConfigurationTemplate on a local cluster,
 		// or imported cluster which represents a KEv2 cluster (GKE/EKS/AKS) or v1 Provisioning Cluster
 		if newCluster.Name == localCluster || newCluster.Spec.RancherKubernetesEngineConfig == nil {
 			return admission.ResponseAllowed(), nil
 		}
 
+		response, err = a.validateClusterTemplate(request, oldCluster, newCluster)
+		if err != nil {
+			return nil, fmt.Errorf("failed to validate cluster template enforcement: %w", err)
+		}
+		if !response.Allowed {
+			return response, nil
+		}
+
 		response, err = a.validatePSACT(oldCluster, newCluster, request.Operation)
 		if err != nil {
 			return nil, fmt.Errorf("failed to validate PodSecurityAdmissionConfigurationTemplate(PSACT): %w", err)
@@ -130,11 +214,160 @@ func (a *admitter) Admit(request *admission.Request) (*admissionv1.AdmissionResp
 		if !response.Allowed {
 			return response, nil
 		}
+
+		response, err = a.validatePSACTDowngrade(request, oldCluster, newCluster)
+		if err != nil {
+			return nil, fmt.Errorf("failed to validate PodSecurityAdmissionConfigurationTemplate(PSACT) downgrade: %w", err)
+		}
+		if !response.Allowed {
+			return response, nil
+		}
 	}
 
 	return admission.ResponseAllowed(), nil
 }
 
+// validateAgentDeploymentCustomizations checks the resource requirements of the cluster and fleet agents. Unchanged
+// customizations are not checked, so that clusters can still be updated by Rancher's controllers.
+func validateAgentDeploymentCustomizations(changes diff.ChangeSet, newCluster *apisv3.Cluster) field.ErrorList {
+	var errList field.ErrorList
+	for _, customization := range []struct {
+		field         string
+		customization *apisv3.AgentDeploymentCustomization
+	}{
+		{"clusterAgentDeploymentCustomization", newCluster.Spec.ClusterAgentDeploymentCustomization},
+		{"fleetAgentDeploymentCustomization", newCluster.Spec.FleetAgentDeploymentCustomization},
+	} {
+		if customization.customization == nil || !changes.Changed("spec", customization.field, "overrideResourceRequirements") {
+			continue
+		}
+		errList = append(errList, common.ValidateResourceRequirements(customization.customization.OverrideResourceRequirements,
+			field.NewPath("spec", customization.field, "overrideResourceRequirements"))...)
+	}
+	return errList
+}
+
+// validateSchedulingCustomization checks the priority class of the cluster agent's scheduling customization when it
+// changes. The PriorityClass of the local cluster's agent is created in the cluster the webhook runs in, so setting a
+// priority class on the local cluster is denied if a different PriorityClass with the agent's name already exists
+// there, since Rancher would take it over and PriorityClasses can't be changed in place.
+func (a *admitter) validateSchedulingCustomization(request *admission.Request, changes diff.ChangeSet, newCluster *apisv3.Cluster) (field.ErrorList, error) {
+	oldCustomization, err := common.ClusterAgentSchedulingCustomization(request.OldObject.Raw)
+	if err != nil {
+		return nil, err
+	}
+	newCustomization, err := common.ClusterAgentSchedulingCustomization(request.Object.Raw)
+	if err != nil {
+		return nil, err
+	}
+	oldPriorityClass, newPriorityClass := oldCustomization.GetPriorityClass(), newCustomization.GetPriorityClass()
+	if newPriorityClass == nil || !changes.Changed("spec", "clusterAgentDeploymentCustomization", "schedulingCustomization", "priorityClass") {
+		return nil, nil
+	}
+	path := field.NewPath("spec", "clusterAgentDeploymentCustomization", "schedulingCustomization", "priorityClass")
+	if fieldErrs := common.ValidatePriorityClass(newPriorityClass, path); len(fieldErrs) > 0 {
+		return fieldErrs, nil
+	}
+	// once the priority class is set, the existing PriorityClass is the one Rancher created
+	if newCluster.Name != localCluster || oldPriorityClass != nil || a.dynamic == nil {
+		return nil, nil
+	}
+
+	existing, err := a.getPriorityClass(common.ClusterAgentPriorityClassName)
+	if err != nil || existing == nil {
+		return nil, err
+	}
+	existingPolicy := corev1.PreemptLowerPriority
+	if existing.PreemptionPolicy != nil {
+		existingPolicy = *existing.PreemptionPolicy
+	}
+	newPolicy := corev1.PreemptLowerPriority
+	if newPriorityClass.PreemptionPolicy != nil {
+		newPolicy = *newPriorityClass.PreemptionPolicy
+	}
+	if int(existing.Value) == newPriorityClass.Value && existingPolicy == newPolicy {
+		return nil, nil
+	}
+	return field.ErrorList{field.Invalid(path, newPriorityClass.Value, fmt.Sprintf(
+		"PriorityClass %s already exists in the local cluster with value %d and preemption policy %s",
+		existing.Name, existing.Value, existingPolicy))}, nil
+}
+
+// getPriorityClass returns the PriorityClass with the given name, or nil if it doesn't exist.
+func (a *admitter) getPriorityClass(name string) (*schedulingv1.PriorityClass, error) {
+	objs, err := a.dynamic.List(priorityClassGVK, "", labels.Everything())
+	if err != nil {
+		return nil, fmt.Errorf("failed to list priority classes: %w", err)
+	}
+	for _, obj := range objs {
+		unstructuredObj, ok := obj.(runtime.Unstructured)
+		if !ok {
+			continue
+		}
+		priorityClass := &schedulingv1.PriorityClass{}
+		if err := runtime.DefaultUnstructuredConverter.FromUnstructured(unstructuredObj.UnstructuredContent(), priorityClass); err != nil {
+			return nil, fmt.Errorf("failed to convert priority class: %w", err)
+		}
+		if priorityClass.Name == name {
+			return priorityClass, nil
+		}
+	}
+	return nil, nil
+}
+
+// validateUniqueDisplayName checks that a display name set or changed by the request isn't already used by another
+// cluster, when enabled by the unique-cluster-display-names setting. Clusters created and updated by Rancher's
+// controllers aren't checked, as their display names come from provisioning clusters in different namespaces.
+func (a *admitter) validateUniqueDisplayName(request *admission.Request, oldCluster, newCluster *apisv3.Cluster) (*field.Error, error) {
+	if a.clusterCache == nil || a.settingCache == nil || admission.IsController(request) {
+		return nil, nil
+	}
+	displayName := newCluster.Spec.DisplayName
+	if displayName == "" || displayName == oldCluster.Spec.DisplayName {
+		return nil, nil
+	}
+	value, err := common.GetSettingValue(a.settingCache, common.UniqueClusterDisplayNamesSetting)
+	if err != nil {
+		return nil, err
+	}
+	enabled, err := common.ParseUniqueClusterDisplayNames(value)
+	if err != nil {
+		// the setting validator rejects invalid values, don't block clusters if one got through
+		logrus.Warnf("[managementClusterValidator] ignoring invalid %s setting: %v", common.UniqueClusterDisplayNamesSetting, err)
+		return nil, nil
+	}
+	if !enabled {
+		return nil, nil
+	}
+
+	clusters, err := a.clusterCache.GetByIndex(clusterByDisplayNameIndex, displayName)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list clusters with display name %s: %w", displayName, err)
+	}
+	for _, cluster := range clusters {
+		if cluster.Name != newCluster.Name {
+			return field.Duplicate(field.NewPath("spec", "displayName"), displayName), nil
+		}
+	}
+	return nil, nil
+}
+
+// clusterByDisplayName is an indexer returning the display name of the cluster.
+func clusterByDisplayName(cluster *apisv3.Cluster) ([]string, error) {
+	if cluster.Spec.DisplayName == "" {
+		return nil, nil
+	}
+	return []string{cluster.Spec.DisplayName}, nil
+}
+
+func envVarNames(envVars []corev1.EnvVar) []string {
+	names := make([]string, 0, len(envVars))
+	for _, envVar := range envVars {
+		names = append(names, envVar.Name)
+	}
+	return names
+}
+
 func toExtra(extra map[string]authenticationv1.ExtraValue) map[string]v1.ExtraValue {
 	result := map[string]v1.ExtraValue{}
 	for k, v := range extra {
@@ -252,6 +485,30 @@ func (a *admitter) validatePSACT(oldCluster, newCluster *apisv3.Cluster, op admi
 	return admission.ResponseAllowed(), nil
 }
 
+// validatePSACTDowngrade checks that users changing the PSACT of a cluster to one enforcing a less restrictive level
+// have the updatepsa verb on the cluster.
+func (a *admitter) validatePSACTDowngrade(request *admission.Request, oldCluster, newCluster *apisv3.Cluster) (*admissionv1.AdmissionResponse, error) {
+	if request.Operation != admissionv1.Update || admission.IsController(request) {
+		return admission.ResponseAllowed(), nil
+	}
+	oldTemplateName := oldCluster.Spec.DefaultPodSecurityAdmissionConfigurationTemplateName
+	newTemplateName := newCluster.Spec.DefaultPodSecurityAdmissionConfigurationTemplateName
+	downgrade, err := common.IsPSACTDowngrade(a.psact, oldTemplateName, newTemplateName)
+	if err != nil || !downgrade {
+		return admission.ResponseAllowed(), err
+	}
+	canDowngrade, err := auth.RequestUserHasVerb(request, managementGVR, a.sar, common.UpdatePSAVerb, newCluster.Name, "")
+	if err != nil {
+		return nil, fmt.Errorf("failed to check if user can %s cluster %s: %w", common.UpdatePSAVerb, newCluster.Name, err)
+	}
+	if !canDowngrade {
+		return admission.ResponseFailedEscalation(fmt.Sprintf("user %s cannot change the PodSecurityAdmissionConfigurationTemplate of cluster %s "+
+			"from %q to %q, which enforces a less restrictive level: the %s verb on the cluster is required",
+			request.UserInfo.Username, newCluster.Name, oldTemplateName, newTemplateName, common.UpdatePSAVerb)), nil
+	}
+	return admission.ResponseAllowed(), nil
+}
+
 // checkPSAConfigOnCluster validates the cluster spec when DefaultPodSecurityAdmissionConfigurationTemplateName is set.
 func (a *admitter) checkPSAConfigOnCluster(cluster *apisv3.Cluster) (*admissionv1.AdmissionResponse, error) {
 	// validate that extra_args.admission-control-config-file is not set at the same time
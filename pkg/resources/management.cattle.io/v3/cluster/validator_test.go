@@ -7,14 +7,22 @@ import (
 
 	v3 "github.com/rancher/rancher/pkg/apis/management.cattle.io/v3"
 	"github.com/rancher/webhook/pkg/admission"
+	"github.com/rancher/webhook/pkg/diff"
 	"github.com/rancher/webhook/pkg/resources/common"
 	"github.com/rancher/wrangler/v3/pkg/generic/fake"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 	"go.uber.org/mock/gomock"
 	admissionv1 "k8s.io/api/admission/v1"
+	authenticationv1 "k8s.io/api/authentication/v1"
 	authorizationv1 "k8s.io/api/authorization/v1"
+	corev1 "k8s.io/api/core/v1"
+	schedulingv1 "k8s.io/api/scheduling/v1"
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/resource"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/labels"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/runtime/schema"
 	v1 "k8s.io/client-go/kubernetes/typed/authorization/v1"
@@ -360,3 +368,531 @@ func TestAdmit(t *testing.T) {
 		})
 	}
 }
+
+func TestAdmitAgentEnvVars(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name          string
+		operation     admissionv1.Operation
+		oldEnvVars    []corev1.EnvVar
+		newEnvVars    []corev1.EnvVar
+		expectAllowed bool
+	}{
+		{
+			name:          "create with allowed env var",
+			operation:     admissionv1.Create,
+			newEnvVars:    []corev1.EnvVar{{Name: "HTTP_PROXY", Value: "http://proxy"}},
+			expectAllowed: true,
+		},
+		{
+			name:       "create with denied env var",
+			operation:  admissionv1.Create,
+			newEnvVars: []corev1.EnvVar{{Name: "CATTLE_SERVER", Value: "https://rancher"}},
+		},
+		{
+			name:          "update keeping denied env var",
+			operation:     admissionv1.Update,
+			oldEnvVars:    []corev1.EnvVar{{Name: "CATTLE_SERVER", Value: "https://rancher"}},
+			newEnvVars:    []corev1.EnvVar{{Name: "CATTLE_SERVER", Value: "https://rancher.example.com"}},
+			expectAllowed: true,
+		},
+		{
+			name:       "update adding denied env var",
+			operation:  admissionv1.Update,
+			newEnvVars: []corev1.EnvVar{{Name: "CATTLE_SERVER", Value: "https://rancher"}},
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			settingCache := fake.NewMockNonNamespacedCacheInterface[*v3.Setting](gomock.NewController(t))
+			settingCache.EXPECT().Get(common.AgentEnvVarsDenyListSetting).Return(&v3.Setting{Value: "CATTLE_*"}, nil).AnyTimes()
+			settingCache.EXPECT().Get(common.AgentEnvVarsAllowListSetting).Return(nil, apierrors.NewNotFound(schema.GroupResource{}, "")).AnyTimes()
+			v := NewValidator(&mockReviewer{}, nil, nil, settingCache, nil, nil, nil)
+
+			oldCluster := v3.Cluster{ObjectMeta: metav1.ObjectMeta{Name: "c-2bmj5"}}
+			oldCluster.Spec.AgentEnvVars = tt.oldEnvVars
+			newCluster := v3.Cluster{ObjectMeta: metav1.ObjectMeta{Name: "c-2bmj5"}}
+			newCluster.Spec.AgentEnvVars = tt.newEnvVars
+			oldClusterBytes, err := json.Marshal(oldCluster)
+			assert.NoError(t, err)
+			newClusterBytes, err := json.Marshal(newCluster)
+			assert.NoError(t, err)
+
+			res, err := v.Admitters()[0].Admit(&admission.Request{
+				AdmissionRequest: admissionv1.AdmissionRequest{
+					Object:    runtime.RawExtension{Raw: newClusterBytes},
+					OldObject: runtime.RawExtension{Raw: oldClusterBytes},
+					Operation: tt.operation,
+				},
+			})
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expectAllowed, res.Allowed)
+		})
+	}
+}
+
+type workspaceReviewer struct {
+	v1.SubjectAccessReviewExpansion
+	allowedWorkspace string
+	reviews          []*authorizationv1.SubjectAccessReview
+}
+
+func (w *workspaceReviewer) Create(
+	_ context.Context,
+	review *authorizationv1.SubjectAccessReview,
+	_ metav1.CreateOptions,
+) (*authorizationv1.SubjectAccessReview, error) {
+	w.reviews = append(w.reviews, review)
+	review.Status.Allowed = review.Spec.ResourceAttributes.Name == w.allowedWorkspace
+	return review, nil
+}
+
+func TestValidateFleetPermissions(t *testing.T) {
+	tests := []struct {
+		name          string
+		oldWorkspace  string
+		newWorkspace  string
+		expectAllowed bool
+		expectReview  bool
+	}{
+		{
+			name:          "move to a workspace the user can add clusters to",
+			oldWorkspace:  "fleet-default",
+			newWorkspace:  "allowed",
+			expectAllowed: true,
+			expectReview:  true,
+		},
+		{
+			name:          "move to a workspace the user cannot add clusters to",
+			oldWorkspace:  "allowed",
+			newWorkspace:  "denied",
+			expectAllowed: false,
+			expectReview:  true,
+		},
+		{
+			name:          "unchanged workspace",
+			oldWorkspace:  "denied",
+			newWorkspace:  "denied",
+			expectAllowed: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			reviewer := &workspaceReviewer{allowedWorkspace: "allowed"}
+			a := admitter{sar: reviewer}
+			request := &admission.Request{
+				AdmissionRequest: admissionv1.AdmissionRequest{Operation: admissionv1.Update},
+				Context:          context.Background(),
+			}
+			oldCluster := &v3.Cluster{Spec: v3.ClusterSpec{FleetWorkspaceName: tt.oldWorkspace}}
+			newCluster := &v3.Cluster{Spec: v3.ClusterSpec{FleetWorkspaceName: tt.newWorkspace}}
+
+			response, err := a.validateFleetPermissions(request, oldCluster, newCluster)
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expectAllowed, response.Allowed)
+			if !tt.expectReview {
+				assert.Empty(t, reviewer.reviews)
+				return
+			}
+			if assert.Len(t, reviewer.reviews, 1) {
+				attributes := reviewer.reviews[0].Spec.ResourceAttributes
+				assert.Equal(t, "fleetaddcluster", attributes.Verb)
+				assert.Equal(t, "fleetworkspaces", attributes.Resource)
+				assert.Equal(t, tt.newWorkspace, attributes.Name)
+			}
+		})
+	}
+}
+
+func TestValidateUniqueDisplayName(t *testing.T) {
+	t.Parallel()
+
+	existing := &v3.Cluster{
+		ObjectMeta: metav1.ObjectMeta{Name: "c-existing"},
+		Spec:       v3.ClusterSpec{DisplayName: "production"},
+	}
+
+	tests := []struct {
+		name           string
+		setting        string
+		username       string
+		oldDisplayName string
+		newDisplayName string
+		clusterName    string
+		expectDenied   bool
+	}{
+		{
+			name:           "duplicate denied when enabled",
+			setting:        "true",
+			newDisplayName: "production",
+			clusterName:    "c-new",
+			expectDenied:   true,
+		},
+		{
+			name:           "unique allowed when enabled",
+			setting:        "true",
+			newDisplayName: "staging",
+			clusterName:    "c-new",
+		},
+		{
+			name:           "duplicate allowed when disabled",
+			setting:        "false",
+			newDisplayName: "production",
+			clusterName:    "c-new",
+		},
+		{
+			name:           "duplicate allowed when setting is empty",
+			newDisplayName: "production",
+			clusterName:    "c-new",
+		},
+		{
+			name:           "duplicate allowed when setting is invalid",
+			setting:        "maybe",
+			newDisplayName: "production",
+			clusterName:    "c-new",
+		},
+		{
+			name:           "unchanged display name allowed",
+			setting:        "true",
+			oldDisplayName: "production",
+			newDisplayName: "production",
+			clusterName:    "c-new",
+		},
+		{
+			name:           "own display name allowed",
+			setting:        "true",
+			newDisplayName: "production",
+			clusterName:    "c-existing",
+		},
+		{
+			name:           "duplicate allowed for controllers",
+			setting:        "true",
+			username:       "system:kube-controller-manager",
+			newDisplayName: "production",
+			clusterName:    "c-new",
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			ctrl := gomock.NewController(t)
+			settingCache := fake.NewMockNonNamespacedCacheInterface[*v3.Setting](ctrl)
+			settingCache.EXPECT().Get(common.UniqueClusterDisplayNamesSetting).Return(&v3.Setting{Value: tt.setting}, nil).AnyTimes()
+			clusterCache := fake.NewMockNonNamespacedCacheInterface[*v3.Cluster](ctrl)
+			clusterCache.EXPECT().AddIndexer(clusterByDisplayNameIndex, gomock.Any())
+			clusterCache.EXPECT().GetByIndex(clusterByDisplayNameIndex, gomock.Any()).DoAndReturn(func(_, displayName string) ([]*v3.Cluster, error) {
+				if displayName == existing.Spec.DisplayName {
+					return []*v3.Cluster{existing}, nil
+				}
+				return nil, nil
+			}).AnyTimes()
+			v := NewValidator(&mockReviewer{}, nil, nil, settingCache, nil, clusterCache, nil)
+
+			request := &admission.Request{
+				AdmissionRequest: admissionv1.AdmissionRequest{
+					Operation: admissionv1.Update,
+					UserInfo:  authenticationv1.UserInfo{Username: tt.username},
+				},
+			}
+			oldCluster := &v3.Cluster{
+				ObjectMeta: metav1.ObjectMeta{Name: tt.clusterName},
+				Spec:       v3.ClusterSpec{DisplayName: tt.oldDisplayName},
+			}
+			newCluster := &v3.Cluster{
+				ObjectMeta: metav1.ObjectMeta{Name: tt.clusterName},
+				Spec:       v3.ClusterSpec{DisplayName: tt.newDisplayName},
+			}
+
+			fieldErr, err := v.admitter.validateUniqueDisplayName(request, oldCluster, newCluster)
+			assert.NoError(t, err)
+			if tt.expectDenied {
+				if assert.NotNil(t, fieldErr) {
+					assert.Equal(t, "spec.displayName", fieldErr.Field)
+				}
+			} else {
+				assert.Nil(t, fieldErr)
+			}
+		})
+	}
+}
+
+func TestValidateAgentDeploymentCustomizations(t *testing.T) {
+	t.Parallel()
+
+	invalidRequirements := &corev1.ResourceRequirements{
+		Limits:   corev1.ResourceList{corev1.ResourceCPU: resource.MustParse("500m")},
+		Requests: corev1.ResourceList{corev1.ResourceCPU: resource.MustParse("1")},
+	}
+	validRequirements := &corev1.ResourceRequirements{
+		Limits:   corev1.ResourceList{corev1.ResourceCPU: resource.MustParse("1")},
+		Requests: corev1.ResourceList{corev1.ResourceCPU: resource.MustParse("500m")},
+	}
+	cluster := func(clusterAgent, fleetAgent *corev1.ResourceRequirements) *v3.Cluster {
+		cluster := &v3.Cluster{}
+		if clusterAgent != nil {
+			cluster.Spec.ClusterAgentDeploymentCustomization = &v3.AgentDeploymentCustomization{OverrideResourceRequirements: clusterAgent}
+		}
+		if fleetAgent != nil {
+			cluster.Spec.FleetAgentDeploymentCustomization = &v3.AgentDeploymentCustomization{OverrideResourceRequirements: fleetAgent}
+		}
+		return cluster
+	}
+
+	tests := []struct {
+		name       string
+		oldCluster *v3.Cluster
+		newCluster *v3.Cluster
+		wantFields []string
+	}{
+		{
+			name:       "no customizations",
+			oldCluster: cluster(nil, nil),
+			newCluster: cluster(nil, nil),
+		},
+		{
+			name:       "valid requirements",
+			oldCluster: cluster(nil, nil),
+			newCluster: cluster(validRequirements, validRequirements),
+		},
+		{
+			name:       "invalid requirements",
+			oldCluster: cluster(nil, nil),
+			newCluster: cluster(invalidRequirements, invalidRequirements),
+			wantFields: []string{
+				"spec.clusterAgentDeploymentCustomization.overrideResourceRequirements.requests[cpu]",
+				"spec.fleetAgentDeploymentCustomization.overrideResourceRequirements.requests[cpu]",
+			},
+		},
+		{
+			name:       "unchanged invalid requirements",
+			oldCluster: cluster(invalidRequirements, nil),
+			newCluster: cluster(invalidRequirements, validRequirements),
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			changes, err := diff.Objects(tt.oldCluster, tt.newCluster)
+			require.NoError(t, err)
+			var fields []string
+			for _, err := range validateAgentDeploymentCustomizations(changes, tt.newCluster) {
+				fields = append(fields, err.Field)
+			}
+			assert.Equal(t, tt.wantFields, fields)
+		})
+	}
+}
+
+type psaReviewer struct {
+	v1.SubjectAccessReviewExpansion
+	allowedUser string
+	reviews     []*authorizationv1.SubjectAccessReview
+}
+
+func (p *psaReviewer) Create(
+	_ context.Context,
+	review *authorizationv1.SubjectAccessReview,
+	_ metav1.CreateOptions,
+) (*authorizationv1.SubjectAccessReview, error) {
+	p.reviews = append(p.reviews, review)
+	review.Status.Allowed = review.Spec.User == p.allowedUser
+	return review, nil
+}
+
+func TestValidatePSACTDowngrade(t *testing.T) {
+	tests := []struct {
+		name          string
+		username      string
+		oldTemplate   string
+		newTemplate   string
+		expectAllowed bool
+		expectReview  bool
+	}{
+		{
+			name:          "upgrade",
+			username:      "user",
+			oldTemplate:   "rancher-privileged",
+			newTemplate:   "rancher-restricted",
+			expectAllowed: true,
+		},
+		{
+			name:         "downgrade without verb",
+			username:     "user",
+			oldTemplate:  "rancher-restricted",
+			newTemplate:  "rancher-privileged",
+			expectReview: true,
+		},
+		{
+			name:         "unset template without verb",
+			username:     "user",
+			oldTemplate:  "rancher-restricted",
+			expectReview: true,
+		},
+		{
+			name:          "downgrade with verb",
+			username:      "psa-user",
+			oldTemplate:   "rancher-restricted",
+			newTemplate:   "rancher-privileged",
+			expectAllowed: true,
+			expectReview:  true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			psactCache := fake.NewMockNonNamespacedCacheInterface[*v3.PodSecurityAdmissionConfigurationTemplate](gomock.NewController(t))
+			psactCache.EXPECT().Get(gomock.Any()).DoAndReturn(func(name string) (*v3.PodSecurityAdmissionConfigurationTemplate, error) {
+				level := map[string]string{"rancher-privileged": "privileged", "rancher-restricted": "restricted"}[name]
+				return &v3.PodSecurityAdmissionConfigurationTemplate{
+					ObjectMeta:    metav1.ObjectMeta{Name: name},
+					Configuration: v3.PodSecurityAdmissionConfigurationTemplateSpec{Defaults: v3.PodSecurityAdmissionConfigurationTemplateDefaults{Enforce: level}},
+				}, nil
+			}).AnyTimes()
+			reviewer := &psaReviewer{allowedUser: "psa-user"}
+			a := admitter{sar: reviewer, psact: psactCache}
+			request := &admission.Request{
+				AdmissionRequest: admissionv1.AdmissionRequest{
+					Operation: admissionv1.Update,
+					UserInfo:  authenticationv1.UserInfo{Username: tt.username},
+				},
+				Context: context.Background(),
+			}
+			oldCluster := &v3.Cluster{ObjectMeta: metav1.ObjectMeta{Name: "c-1"}}
+			oldCluster.Spec.DefaultPodSecurityAdmissionConfigurationTemplateName = tt.oldTemplate
+			newCluster := &v3.Cluster{ObjectMeta: metav1.ObjectMeta{Name: "c-1"}}
+			newCluster.Spec.DefaultPodSecurityAdmissionConfigurationTemplateName = tt.newTemplate
+
+			response, err := a.validatePSACTDowngrade(request, oldCluster, newCluster)
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expectAllowed, response.Allowed)
+			if !tt.expectReview {
+				assert.Empty(t, reviewer.reviews)
+				return
+			}
+			if assert.Len(t, reviewer.reviews, 1) {
+				attributes := reviewer.reviews[0].Spec.ResourceAttributes
+				assert.Equal(t, common.UpdatePSAVerb, attributes.Verb)
+				assert.Equal(t, "clusters", attributes.Resource)
+				assert.Equal(t, "c-1", attributes.Name)
+			}
+		})
+	}
+}
+
+type mockLister struct {
+	objs []runtime.Object
+}
+
+func (m *mockLister) List(gvk schema.GroupVersionKind, _ string, _ labels.Selector) ([]runtime.Object, error) {
+	if gvk != priorityClassGVK {
+		return nil, nil
+	}
+	return m.objs, nil
+}
+
+func TestValidateSchedulingCustomization(t *testing.T) {
+	t.Parallel()
+
+	existing := &schedulingv1.PriorityClass{
+		TypeMeta:   metav1.TypeMeta{APIVersion: "scheduling.k8s.io/v1", Kind: "PriorityClass"},
+		ObjectMeta: metav1.ObjectMeta{Name: common.ClusterAgentPriorityClassName},
+		Value:      1000,
+	}
+	content, err := runtime.DefaultUnstructuredConverter.ToUnstructured(existing)
+	require.NoError(t, err)
+	lister := &mockLister{objs: []runtime.Object{&unstructured.Unstructured{Object: content}}}
+
+	raw := func(priorityClass string) []byte {
+		if priorityClass == "" {
+			return []byte(`{"spec":{}}`)
+		}
+		return []byte(`{"spec":{"clusterAgentDeploymentCustomization":{"schedulingCustomization":{"priorityClass":` + priorityClass + `}}}}`)
+	}
+	tests := []struct {
+		name          string
+		clusterName   string
+		oldObject     []byte
+		newObject     []byte
+		wantInMessage string
+	}{
+		{
+			name:        "no priority class",
+			clusterName: localCluster,
+			newObject:   raw(""),
+		},
+		{
+			name:        "same priority class as existing one",
+			clusterName: localCluster,
+			oldObject:   raw(""),
+			newObject:   raw(`{"value":1000,"preemptionPolicy":"PreemptLowerPriority"}`),
+		},
+		{
+			name:          "colliding priority class",
+			clusterName:   localCluster,
+			oldObject:     raw(""),
+			newObject:     raw(`{"value":2000}`),
+			wantInMessage: "already exists in the local cluster with value 1000",
+		},
+		{
+			name:          "colliding preemption policy",
+			clusterName:   localCluster,
+			newObject:     raw(`{"value":1000,"preemptionPolicy":"Never"}`),
+			wantInMessage: "preemption policy PreemptLowerPriority",
+		},
+		{
+			name:        "changed priority class",
+			clusterName: localCluster,
+			oldObject:   raw(`{"value":1000}`),
+			newObject:   raw(`{"value":2000}`),
+		},
+		{
+			name:        "downstream cluster",
+			clusterName: "c-abcde",
+			oldObject:   raw(""),
+			newObject:   raw(`{"value":2000}`),
+		},
+		{
+			name:          "system value",
+			clusterName:   "c-abcde",
+			newObject:     raw(`{"value":2000000000}`),
+			wantInMessage: "schedulingCustomization.priorityClass.value",
+		},
+		{
+			name:        "unchanged system value",
+			clusterName: "c-abcde",
+			oldObject:   raw(`{"value":2000000000}`),
+			newObject:   raw(`{"value":2000000000}`),
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			v := NewValidator(&mockReviewer{}, nil, nil, nil, nil, nil, lister)
+			request := &admission.Request{AdmissionRequest: admissionv1.AdmissionRequest{
+				Object:    runtime.RawExtension{Raw: tt.newObject},
+				OldObject: runtime.RawExtension{Raw: tt.oldObject},
+			}}
+			cluster := &v3.Cluster{ObjectMeta: metav1.ObjectMeta{Name: tt.clusterName}}
+
+			changes, err := diff.JSON(tt.oldObject, tt.newObject)
+			require.NoError(t, err)
+
+			fieldErrs, err := v.admitter.validateSchedulingCustomization(request, changes, cluster)
+			require.NoError(t, err)
+			if tt.wantInMessage == "" {
+				assert.Empty(t, fieldErrs)
+				return
+			}
+			assert.Contains(t, fieldErrs.ToAggregate().Error(), tt.wantInMessage)
+		})
+	}
+}
@@ -11,6 +11,7 @@ import (
 	v3 "github.com/rancher/webhook/pkg/generated/controllers/management.cattle.io/v3"
 	objectsv3 "github.com/rancher/webhook/pkg/generated/objects/management.cattle.io/v3"
 	"github.com/rancher/webhook/pkg/resolvers"
+	"github.com/rancher/webhook/pkg/resources/common"
 	admissionv1 "k8s.io/api/admission/v1"
 	admissionregistrationv1 "k8s.io/api/admissionregistration/v1"
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
@@ -32,7 +33,8 @@ const (
 
 // NewValidator will create a newly allocated Validator.
 func NewValidator(crtb *resolvers.CRTBRuleResolver, defaultResolver k8validation.AuthorizationRuleResolver,
-	roleTemplateResolver *auth.RoleTemplateResolver, grbCache v3.GlobalRoleBindingCache, clusterCache v3.ClusterCache) *Validator {
+	roleTemplateResolver *auth.RoleTemplateResolver, grbCache v3.GlobalRoleBindingCache, clusterCache v3.ClusterCache,
+	subjectValidator *common.BindingSubjectValidator) *Validator {
 	resolver := resolvers.NewAggregateRuleResolver(defaultResolver, crtb)
 	return &Validator{
 		admitter: admitter{
@@ -40,6 +42,7 @@ func NewValidator(crtb *resolvers.CRTBRuleResolver, defaultResolver k8validation
 			roleTemplateResolver: roleTemplateResolver,
 			grbCache:             grbCache,
 			clusterCache:         clusterCache,
+			subjectValidator:     subjectValidator,
 		},
 	}
 }
@@ -74,6 +77,7 @@ type admitter struct {
 	roleTemplateResolver *auth.RoleTemplateResolver
 	grbCache             v3.GlobalRoleBindingCache
 	clusterCache         v3.ClusterCache
+	subjectValidator     *common.BindingSubjectValidator
 }
 
 // Admit is the entrypoint for the validator. Admit will return an error if it unable to process the request.
@@ -82,6 +86,10 @@ func (a *admitter) Admit(request *admission.Request) (*admissionv1.AdmissionResp
 	listTrace := trace.New("clusterRoleTemplateBindingValidator Admit", trace.Field{Key: "user", Value: request.UserInfo.Username})
 	defer listTrace.LogIfLong(admission.SlowTraceDuration)
 
+	if response, err := admission.DisallowUnknownFields(request, &apisv3.ClusterRoleTemplateBinding{}); err != nil || response != nil {
+		return response, err
+	}
+
 	fieldPath := field.NewPath("clusterroletemplatebinding")
 
 	if request.Operation == admissionv1.Update {
@@ -128,30 +136,28 @@ func (a *admitter) Admit(request *admission.Request) (*admissionv1.AdmissionResp
 	return response, nil
 }
 
+// immutableFields are the fields of CRTBs which can't be updated. The subject of the binding can only be set once, so
+// bindings created with only a principal name can be completed with the matching name.
+var immutableFields = common.NewImmutableFields(
+	common.ImmutableField{Path: "roleTemplateName"},
+	common.ImmutableField{Path: "clusterName"},
+	common.ImmutableField{Path: "userName", SettableOnce: true},
+	common.ImmutableField{Path: "userPrincipalName", SettableOnce: true},
+	common.ImmutableField{Path: "groupName", SettableOnce: true},
+	common.ImmutableField{Path: "groupPrincipalName", SettableOnce: true},
+	common.ImmutableField{Path: "metadata.labels[" + grbOwnerLabel + "]"},
+)
+
 // validUpdateFields checks if the fields being changed are valid update fields.
 func validateUpdateFields(oldCRTB, newCRTB *apisv3.ClusterRoleTemplateBinding, fieldPath *field.Path) *field.Error {
-	const reason = "field is immutable"
-	switch {
-	case oldCRTB.RoleTemplateName != newCRTB.RoleTemplateName:
-		return field.Invalid(fieldPath.Child("roleTemplateName"), newCRTB.RoleTemplateName, reason)
-	case oldCRTB.ClusterName != newCRTB.ClusterName:
-		return field.Invalid(fieldPath.Child("clusterName"), newCRTB.ClusterName, reason)
-	case oldCRTB.UserName != newCRTB.UserName && oldCRTB.UserName != "":
-		return field.Invalid(fieldPath.Child("userName"), newCRTB.UserName, reason)
-	case oldCRTB.UserPrincipalName != newCRTB.UserPrincipalName && oldCRTB.UserPrincipalName != "":
-		return field.Invalid(fieldPath.Child("userPrincipalName"), newCRTB.UserPrincipalName, reason)
-	case oldCRTB.GroupName != newCRTB.GroupName && oldCRTB.GroupName != "":
-		return field.Invalid(fieldPath.Child("groupName"), newCRTB.GroupName, reason)
-	case oldCRTB.GroupPrincipalName != newCRTB.GroupPrincipalName && oldCRTB.GroupPrincipalName != "":
-		return field.Invalid(fieldPath.Child("groupPrincipalName"), newCRTB.GroupPrincipalName, reason)
-	case (newCRTB.GroupName != "" || oldCRTB.GroupPrincipalName != "") && (newCRTB.UserName != "" || oldCRTB.UserPrincipalName != ""):
+	if errs := immutableFields.Validate(fieldPath, oldCRTB, newCRTB); len(errs) > 0 {
+		return errs[0]
+	}
+	if (newCRTB.GroupName != "" || oldCRTB.GroupPrincipalName != "") && (newCRTB.UserName != "" || oldCRTB.UserPrincipalName != "") {
 		return field.Forbidden(fieldPath,
 			"binding target must target either a user [userName]/[userPrincipalName] OR a group [groupName]/[groupPrincipalName]")
-	case (newCRTB.Labels[grbOwnerLabel] != oldCRTB.Labels[grbOwnerLabel]):
-		return field.Forbidden(fieldPath.Child("labels"), fmt.Sprintf("label %s is immutable after creation", grbOwnerLabel))
-	default:
-		return nil
 	}
+	return nil
 }
 
 // validateCreateFields checks if all required fields are present and valid.
@@ -165,6 +171,13 @@ func (a *admitter) validateCreateFields(newCRTB *apisv3.ClusterRoleTemplateBindi
 		return field.Forbidden(fieldPath, "binding must target either a user [userName]/[userPrincipalName] OR a group [groupName]/[groupPrincipalName]")
 	}
 
+	if fieldErr, err := a.subjectValidator.Validate(newCRTB.UserName, newCRTB.GroupPrincipalName, fieldPath); err != nil || fieldErr != nil {
+		if err != nil {
+			return fmt.Errorf("unable to verify the subject of the binding: %w", err)
+		}
+		return fieldErr
+	}
+
 	if newCRTB.ClusterName == "" {
 		return field.Required(fieldPath.Child("clusterName"), reason)
 	}
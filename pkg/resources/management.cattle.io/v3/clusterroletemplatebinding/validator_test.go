@@ -198,7 +198,7 @@ func (c *ClusterRoleTemplateBindingSuite) Test_PrivilegeEscalation() {
 	}, nil).AnyTimes()
 
 	crtbResolver := resolvers.NewCRTBRuleResolver(crtbCache, roleResolver)
-	validator := clusterroletemplatebinding.NewValidator(crtbResolver, resolver, roleResolver, nil, clusterCache)
+	validator := clusterroletemplatebinding.NewValidator(crtbResolver, resolver, roleResolver, nil, clusterCache, nil)
 	type args struct {
 		oldCRTB  func() *apisv3.ClusterRoleTemplateBinding
 		newCRTB  func() *apisv3.ClusterRoleTemplateBinding
@@ -342,7 +342,7 @@ func (c *ClusterRoleTemplateBindingSuite) Test_UpdateValidation() {
 	}, nil).AnyTimes()
 
 	crtbResolver := resolvers.NewCRTBRuleResolver(crtbCache, roleResolver)
-	validator := clusterroletemplatebinding.NewValidator(crtbResolver, resolver, roleResolver, nil, clusterCache)
+	validator := clusterroletemplatebinding.NewValidator(crtbResolver, resolver, roleResolver, nil, clusterCache, nil)
 	type args struct {
 		oldCRTB  func() *apisv3.ClusterRoleTemplateBinding
 		newCRTB  func() *apisv3.ClusterRoleTemplateBinding
@@ -740,7 +740,7 @@ func (c *ClusterRoleTemplateBindingSuite) Test_Create() {
 		clusterCache.EXPECT().Get(nilCluster).Return(nil, nil).AnyTimes()
 
 		crtbResolver := resolvers.NewCRTBRuleResolver(crtbCache, roleResolver)
-		return clusterroletemplatebinding.NewValidator(crtbResolver, resolver, roleResolver, grbCache, clusterCache)
+		return clusterroletemplatebinding.NewValidator(crtbResolver, resolver, roleResolver, grbCache, clusterCache, nil)
 	}
 	type args struct {
 		oldCRTB  func() *apisv3.ClusterRoleTemplateBinding
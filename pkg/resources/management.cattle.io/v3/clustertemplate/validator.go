@@ -0,0 +1,130 @@
+// Package clustertemplate is used for validating clustertemplates.
+package clustertemplate
+
+import (
+	"fmt"
+	"strings"
+
+	v3 "github.com/rancher/rancher/pkg/apis/management.cattle.io/v3"
+	"github.com/rancher/webhook/pkg/admission"
+	controllerv3 "github.com/rancher/webhook/pkg/generated/controllers/management.cattle.io/v3"
+	objectsv3 "github.com/rancher/webhook/pkg/generated/objects/management.cattle.io/v3"
+	admissionv1 "k8s.io/api/admission/v1"
+	admissionregistrationv1 "k8s.io/api/admissionregistration/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/utils/trace"
+)
+
+const byClusterTemplate = "clusterTemplate"
+
+var gvr = schema.GroupVersionResource{
+	Group:    "management.cattle.io",
+	Version:  "v3",
+	Resource: "clustertemplates",
+}
+
+// NewValidator returns a new validator for clustertemplates.
+func NewValidator(clusterCache controllerv3.ClusterCache, revisionCache controllerv3.ClusterTemplateRevisionCache) *Validator {
+	clusterCache.AddIndexer(byClusterTemplate, clusterByTemplate)
+	return &Validator{
+		admitter: admitter{
+			clusterCache:  clusterCache,
+			revisionCache: revisionCache,
+		},
+	}
+}
+
+// Validator for validating clustertemplates.
+type Validator struct {
+	admitter admitter
+}
+
+// GVR returns the GroupVersionKind for this CRD.
+func (v *Validator) GVR() schema.GroupVersionResource {
+	return gvr
+}
+
+// Operations returns list of operations handled by this validator.
+func (v *Validator) Operations() []admissionregistrationv1.OperationType {
+	return []admissionregistrationv1.OperationType{admissionregistrationv1.Create, admissionregistrationv1.Update, admissionregistrationv1.Delete}
+}
+
+// ValidatingWebhook returns the ValidatingWebhook used for this CRD.
+func (v *Validator) ValidatingWebhook(clientConfig admissionregistrationv1.WebhookClientConfig) []admissionregistrationv1.ValidatingWebhook {
+	return []admissionregistrationv1.ValidatingWebhook{*admission.NewDefaultValidatingWebhook(v, clientConfig, admissionregistrationv1.NamespacedScope, v.Operations())}
+}
+
+// Admitters returns the admitter objects used to validate clustertemplates.
+func (v *Validator) Admitters() []admission.Admitter {
+	return []admission.Admitter{&v.admitter}
+}
+
+type admitter struct {
+	clusterCache  controllerv3.ClusterCache
+	revisionCache controllerv3.ClusterTemplateRevisionCache
+}
+
+// Admit handles the webhook admission request sent to this webhook.
+func (a *admitter) Admit(request *admission.Request) (*admissionv1.AdmissionResponse, error) {
+	listTrace := trace.New("clusterTemplateValidator Admit", trace.Field{Key: "user", Value: request.UserInfo.Username})
+	defer listTrace.LogIfLong(admission.SlowTraceDuration)
+
+	oldTemplate, newTemplate, err := objectsv3.ClusterTemplateOldAndNewFromRequest(&request.AdmissionRequest)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get old and new clustertemplates from request: %w", err)
+	}
+
+	switch request.Operation {
+	case admissionv1.Create, admissionv1.Update:
+		if newTemplate.Spec.DefaultRevisionName == "" || newTemplate.Spec.DefaultRevisionName == oldTemplate.Spec.DefaultRevisionName {
+			return admission.ResponseAllowed(), nil
+		}
+		return a.validateDefaultRevision(newTemplate)
+	case admissionv1.Delete:
+		reference := templateReference(oldTemplate)
+		clusters, err := a.clusterCache.GetByIndex(byClusterTemplate, reference)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get clusters using ClusterTemplate %s: %w", reference, err)
+		}
+		if len(clusters) > 0 {
+			return admission.ResponseBadRequest(fmt.Sprintf("cannot delete ClusterTemplate %s as it is used by %d clusters", reference, len(clusters))), nil
+		}
+		return admission.ResponseAllowed(), nil
+	default:
+		return nil, fmt.Errorf("%s operation %v: %w", gvr.Resource, request.Operation, admission.ErrUnsupportedOperation)
+	}
+}
+
+// validateDefaultRevision checks that the default revision of the template exists and is a revision of the template.
+func (a *admitter) validateDefaultRevision(template *v3.ClusterTemplate) (*admissionv1.AdmissionResponse, error) {
+	revisionName := template.Spec.DefaultRevisionName
+	// The revision is referenced as "<namespace>:<name>".
+	namespace, name, found := strings.Cut(revisionName, ":")
+	if !found {
+		return admission.ResponseBadRequest(fmt.Sprintf("invalid default revision %q: must be of the form <namespace>:<name>", revisionName)), nil
+	}
+	revision, err := a.revisionCache.Get(namespace, name)
+	if err != nil {
+		if apierrors.IsNotFound(err) {
+			return admission.ResponseBadRequest(fmt.Sprintf("default revision %s not found", revisionName)), nil
+		}
+		return nil, fmt.Errorf("failed to get ClusterTemplateRevision %s: %w", revisionName, err)
+	}
+	if revision.Spec.ClusterTemplateName != templateReference(template) {
+		return admission.ResponseBadRequest(fmt.Sprintf("default revision %s is not a revision of ClusterTemplate %s", revisionName, templateReference(template))), nil
+	}
+	return admission.ResponseAllowed(), nil
+}
+
+// templateReference returns the reference to the template used by clusters and revisions, of the form <namespace>:<name>.
+func templateReference(template *v3.ClusterTemplate) string {
+	return template.Namespace + ":" + template.Name
+}
+
+func clusterByTemplate(cluster *v3.Cluster) ([]string, error) {
+	if cluster.Spec.ClusterTemplateName == "" {
+		return nil, nil
+	}
+	return []string{cluster.Spec.ClusterTemplateName}, nil
+}
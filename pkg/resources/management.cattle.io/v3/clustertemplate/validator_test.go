@@ -0,0 +1,163 @@
+package clustertemplate
+
+import (
+	"encoding/json"
+	"fmt"
+	"testing"
+
+	v3 "github.com/rancher/rancher/pkg/apis/management.cattle.io/v3"
+	"github.com/rancher/webhook/pkg/admission"
+	"github.com/rancher/wrangler/v3/pkg/generic/fake"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+	admissionv1 "k8s.io/api/admission/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+const (
+	templateNamespace     = "cattle-global-data"
+	templateReferenceName = "cattle-global-data:ct-1"
+)
+
+func TestAdmit(t *testing.T) {
+	t.Parallel()
+
+	newTemplate := func() *v3.ClusterTemplate {
+		return &v3.ClusterTemplate{
+			ObjectMeta: metav1.ObjectMeta{Name: "ct-1", Namespace: templateNamespace},
+			Spec:       v3.ClusterTemplateSpec{DisplayName: "template"},
+		}
+	}
+	withDefaultRevision := func(name string) func() *v3.ClusterTemplate {
+		return func() *v3.ClusterTemplate {
+			template := newTemplate()
+			template.Spec.DefaultRevisionName = name
+			return template
+		}
+	}
+	revisions := map[string]*v3.ClusterTemplateRevision{
+		"ctr-1": {Spec: v3.ClusterTemplateRevisionSpec{ClusterTemplateName: templateReferenceName}},
+		"ctr-2": {Spec: v3.ClusterTemplateRevisionSpec{ClusterTemplateName: "cattle-global-data:ct-2"}},
+	}
+
+	tests := []struct {
+		name        string
+		operation   admissionv1.Operation
+		oldTemplate func() *v3.ClusterTemplate
+		newTemplate func() *v3.ClusterTemplate
+		clusters    []*v3.Cluster
+		clustersErr error
+		wantAllowed bool
+		wantErr     bool
+	}{
+		{
+			name:        "create without default revision",
+			operation:   admissionv1.Create,
+			newTemplate: newTemplate,
+			wantAllowed: true,
+		},
+		{
+			name:        "update default revision",
+			operation:   admissionv1.Update,
+			oldTemplate: newTemplate,
+			newTemplate: withDefaultRevision("cattle-global-data:ctr-1"),
+			wantAllowed: true,
+		},
+		{
+			name:        "unchanged default revision is not checked",
+			operation:   admissionv1.Update,
+			oldTemplate: withDefaultRevision("cattle-global-data:ctr-3"),
+			newTemplate: withDefaultRevision("cattle-global-data:ctr-3"),
+			wantAllowed: true,
+		},
+		{
+			name:        "default revision of another template",
+			operation:   admissionv1.Update,
+			oldTemplate: newTemplate,
+			newTemplate: withDefaultRevision("cattle-global-data:ctr-2"),
+		},
+		{
+			name:        "default revision not found",
+			operation:   admissionv1.Update,
+			oldTemplate: newTemplate,
+			newTemplate: withDefaultRevision("cattle-global-data:ctr-3"),
+		},
+		{
+			name:        "invalid default revision reference",
+			operation:   admissionv1.Create,
+			newTemplate: withDefaultRevision("ctr-1"),
+		},
+		{
+			name:        "failed to get default revision",
+			operation:   admissionv1.Create,
+			newTemplate: withDefaultRevision("cattle-global-data:error"),
+			wantErr:     true,
+		},
+		{
+			name:        "delete unused template",
+			operation:   admissionv1.Delete,
+			oldTemplate: newTemplate,
+			wantAllowed: true,
+		},
+		{
+			name:        "delete used template",
+			operation:   admissionv1.Delete,
+			oldTemplate: newTemplate,
+			clusters:    []*v3.Cluster{{ObjectMeta: metav1.ObjectMeta{Name: "c-1"}}},
+		},
+		{
+			name:        "failed to get clusters",
+			operation:   admissionv1.Delete,
+			oldTemplate: newTemplate,
+			clustersErr: fmt.Errorf("indexer error"),
+			wantErr:     true,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			ctrl := gomock.NewController(t)
+			clusterCache := fake.NewMockNonNamespacedCacheInterface[*v3.Cluster](ctrl)
+			clusterCache.EXPECT().AddIndexer(byClusterTemplate, gomock.Any())
+			clusterCache.EXPECT().GetByIndex(byClusterTemplate, templateReferenceName).Return(tt.clusters, tt.clustersErr).AnyTimes()
+			revisionCache := fake.NewMockCacheInterface[*v3.ClusterTemplateRevision](ctrl)
+			revisionCache.EXPECT().Get(templateNamespace, gomock.Any()).DoAndReturn(func(_, name string) (*v3.ClusterTemplateRevision, error) {
+				if name == "error" {
+					return nil, fmt.Errorf("cache error")
+				}
+				if revision, ok := revisions[name]; ok {
+					return revision, nil
+				}
+				return nil, apierrors.NewNotFound(schema.GroupResource{Group: "management.cattle.io", Resource: "clustertemplaterevisions"}, name)
+			}).AnyTimes()
+			validator := NewValidator(clusterCache, revisionCache)
+
+			request := &admission.Request{AdmissionRequest: admissionv1.AdmissionRequest{Operation: tt.operation}}
+			if tt.newTemplate != nil {
+				request.Object = runtime.RawExtension{Raw: mustMarshal(t, tt.newTemplate())}
+			}
+			if tt.oldTemplate != nil {
+				request.OldObject = runtime.RawExtension{Raw: mustMarshal(t, tt.oldTemplate())}
+			}
+			response, err := validator.Admitters()[0].Admit(request)
+			if tt.wantErr {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.wantAllowed, response.Allowed, response.Result)
+		})
+	}
+}
+
+func mustMarshal(t *testing.T, obj any) []byte {
+	t.Helper()
+	data, err := json.Marshal(obj)
+	require.NoError(t, err)
+	return data
+}
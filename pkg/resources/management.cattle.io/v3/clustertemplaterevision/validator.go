@@ -0,0 +1,219 @@
+// Package clustertemplaterevision is used for validating clustertemplaterevisions.
+package clustertemplaterevision
+
+import (
+	"fmt"
+	"slices"
+	"strconv"
+
+	v3 "github.com/rancher/rancher/pkg/apis/management.cattle.io/v3"
+	"github.com/rancher/webhook/pkg/admission"
+	controllerv3 "github.com/rancher/webhook/pkg/generated/controllers/management.cattle.io/v3"
+	objectsv3 "github.com/rancher/webhook/pkg/generated/objects/management.cattle.io/v3"
+	"github.com/rancher/webhook/pkg/resources/common"
+	admissionv1 "k8s.io/api/admission/v1"
+	admissionregistrationv1 "k8s.io/api/admissionregistration/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	"k8s.io/utils/trace"
+)
+
+const byClusterTemplateRevision = "clusterTemplateRevision"
+
+var gvr = schema.GroupVersionResource{
+	Group:    "management.cattle.io",
+	Version:  "v3",
+	Resource: "clustertemplaterevisions",
+}
+
+// questionTypes are the types of the questions of a revision. Questions without a type are strings.
+var questionTypes = []string{"", "string", "multiline", "password", "int", "float", "boolean", "enum"}
+
+// immutableFields are the fields of revisions which can't be updated.
+var immutableFields = common.NewImmutableFields(common.ImmutableField{Path: "spec.clusterTemplateName"})
+
+// usedImmutableFields are the fields of revisions which can't be updated once clusters were created from the revision.
+var usedImmutableFields = common.NewImmutableFields(
+	common.ImmutableField{Path: "spec.clusterConfig"},
+	common.ImmutableField{Path: "spec.questions"},
+)
+
+// NewValidator returns a new validator for clustertemplaterevisions.
+func NewValidator(clusterCache controllerv3.ClusterCache) *Validator {
+	clusterCache.AddIndexer(byClusterTemplateRevision, clusterByTemplateRevision)
+	return &Validator{
+		admitter: admitter{
+			clusterCache: clusterCache,
+		},
+	}
+}
+
+// Validator for validating clustertemplaterevisions.
+type Validator struct {
+	admitter admitter
+}
+
+// GVR returns the GroupVersionKind for this CRD.
+func (v *Validator) GVR() schema.GroupVersionResource {
+	return gvr
+}
+
+// Operations returns list of operations handled by this validator.
+func (v *Validator) Operations() []admissionregistrationv1.OperationType {
+	return []admissionregistrationv1.OperationType{admissionregistrationv1.Create, admissionregistrationv1.Update, admissionregistrationv1.Delete}
+}
+
+// ValidatingWebhook returns the ValidatingWebhook used for this CRD.
+func (v *Validator) ValidatingWebhook(clientConfig admissionregistrationv1.WebhookClientConfig) []admissionregistrationv1.ValidatingWebhook {
+	return []admissionregistrationv1.ValidatingWebhook{*admission.NewDefaultValidatingWebhook(v, clientConfig, admissionregistrationv1.NamespacedScope, v.Operations())}
+}
+
+// Admitters returns the admitter objects used to validate clustertemplaterevisions.
+func (v *Validator) Admitters() []admission.Admitter {
+	return []admission.Admitter{&v.admitter}
+}
+
+type admitter struct {
+	clusterCache controllerv3.ClusterCache
+}
+
+// Admit handles the webhook admission request sent to this webhook.
+func (a *admitter) Admit(request *admission.Request) (*admissionv1.AdmissionResponse, error) {
+	listTrace := trace.New("clusterTemplateRevisionValidator Admit", trace.Field{Key: "user", Value: request.UserInfo.Username})
+	defer listTrace.LogIfLong(admission.SlowTraceDuration)
+
+	oldRevision, newRevision, err := objectsv3.ClusterTemplateRevisionOldAndNewFromRequest(&request.AdmissionRequest)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get old and new clustertemplaterevisions from request: %w", err)
+	}
+
+	switch request.Operation {
+	case admissionv1.Create:
+		return admitCreateOrUpdate(newRevision), nil
+	case admissionv1.Update:
+		if errs := immutableFields.Validate(field.NewPath("clustertemplaterevision"), oldRevision, newRevision); len(errs) > 0 {
+			return admission.ResponseBadRequest(errs.ToAggregate().Error()), nil
+		}
+		clusters, err := a.clustersUsingRevision(oldRevision)
+		if err != nil {
+			return nil, err
+		}
+		if len(clusters) > 0 {
+			if errs := usedImmutableFields.Validate(field.NewPath("clustertemplaterevision"), oldRevision, newRevision); len(errs) > 0 {
+				return admission.ResponseBadRequest(fmt.Sprintf("ClusterTemplateRevision is used by %d clusters: %s", len(clusters), errs.ToAggregate().Error())), nil
+			}
+		}
+		return admitCreateOrUpdate(newRevision), nil
+	case admissionv1.Delete:
+		clusters, err := a.clustersUsingRevision(oldRevision)
+		if err != nil {
+			return nil, err
+		}
+		if len(clusters) > 0 {
+			return admission.ResponseBadRequest(fmt.Sprintf("cannot delete ClusterTemplateRevision %s as it is used by %d clusters",
+				revisionReference(oldRevision), len(clusters))), nil
+		}
+		return admission.ResponseAllowed(), nil
+	default:
+		return nil, fmt.Errorf("%s operation %v: %w", gvr.Resource, request.Operation, admission.ErrUnsupportedOperation)
+	}
+}
+
+// admitCreateOrUpdate validates the fields of a created or updated revision.
+func admitCreateOrUpdate(revision *v3.ClusterTemplateRevision) *admissionv1.AdmissionResponse {
+	specPath := field.NewPath("clustertemplaterevision", "spec")
+	var errs field.ErrorList
+	if revision.Spec.ClusterTemplateName == "" {
+		errs = append(errs, field.Required(specPath.Child("clusterTemplateName"), ""))
+	}
+	errs = append(errs, validateQuestions(specPath.Child("questions"), revision.Spec.Questions)...)
+	if len(errs) > 0 {
+		return admission.ResponseBadRequest(errs.ToAggregate().Error())
+	}
+	return admission.ResponseAllowed()
+}
+
+// clustersUsingRevision returns the clusters created from the revision.
+func (a *admitter) clustersUsingRevision(revision *v3.ClusterTemplateRevision) ([]*v3.Cluster, error) {
+	clusters, err := a.clusterCache.GetByIndex(byClusterTemplateRevision, revisionReference(revision))
+	if err != nil {
+		return nil, fmt.Errorf("failed to get clusters using ClusterTemplateRevision %s: %w", revisionReference(revision), err)
+	}
+	return clusters, nil
+}
+
+// validateQuestions checks that the variables of the questions and of their subquestions are set and unique, and that
+// the type, default value, options and bounds of each question are consistent.
+func validateQuestions(path *field.Path, questions []v3.Question) field.ErrorList {
+	var errs field.ErrorList
+	variables := map[string]bool{}
+	checkVariable := func(path *field.Path, variable string) {
+		if variable == "" {
+			errs = append(errs, field.Required(path, ""))
+		} else if variables[variable] {
+			errs = append(errs, field.Duplicate(path, variable))
+		}
+		variables[variable] = true
+	}
+	for i, question := range questions {
+		questionPath := path.Index(i)
+		checkVariable(questionPath.Child("variable"), question.Variable)
+		errs = append(errs, validateQuestion(questionPath, question.Type, question.Default, question.Options,
+			question.Min, question.Max, question.MinLength, question.MaxLength)...)
+		for j, subquestion := range question.Subquestions {
+			subquestionPath := questionPath.Child("subquestions").Index(j)
+			checkVariable(subquestionPath.Child("variable"), subquestion.Variable)
+			errs = append(errs, validateQuestion(subquestionPath, subquestion.Type, subquestion.Default, subquestion.Options,
+				subquestion.Min, subquestion.Max, subquestion.MinLength, subquestion.MaxLength)...)
+		}
+	}
+	return errs
+}
+
+// validateQuestion checks the fields shared by questions and subquestions.
+func validateQuestion(path *field.Path, questionType, defaultValue string, options []string, minValue, maxValue, minLength, maxLength int) field.ErrorList {
+	var errs field.ErrorList
+	if !slices.Contains(questionTypes, questionType) {
+		errs = append(errs, field.NotSupported(path.Child("type"), questionType, questionTypes[1:]))
+	}
+	if questionType == "enum" && len(options) == 0 {
+		errs = append(errs, field.Required(path.Child("options"), "enum questions must have options"))
+	}
+	if defaultValue != "" {
+		var err error
+		switch questionType {
+		case "int":
+			_, err = strconv.Atoi(defaultValue)
+		case "float":
+			_, err = strconv.ParseFloat(defaultValue, 64)
+		case "boolean":
+			_, err = strconv.ParseBool(defaultValue)
+		case "enum":
+			if !slices.Contains(options, defaultValue) {
+				err = fmt.Errorf("must be one of the options")
+			}
+		}
+		if err != nil {
+			errs = append(errs, field.Invalid(path.Child("default"), defaultValue, fmt.Sprintf("invalid default for a question of type %s", questionType)))
+		}
+	}
+	if minValue != 0 && maxValue != 0 && minValue > maxValue {
+		errs = append(errs, field.Invalid(path.Child("min"), minValue, "must be less than or equal to max"))
+	}
+	if minLength != 0 && maxLength != 0 && minLength > maxLength {
+		errs = append(errs, field.Invalid(path.Child("minLength"), minLength, "must be less than or equal to maxLength"))
+	}
+	return errs
+}
+
+// revisionReference returns the reference to the revision used by clusters, of the form <namespace>:<name>.
+func revisionReference(revision *v3.ClusterTemplateRevision) string {
+	return revision.Namespace + ":" + revision.Name
+}
+
+func clusterByTemplateRevision(cluster *v3.Cluster) ([]string, error) {
+	if cluster.Spec.ClusterTemplateRevisionName == "" {
+		return nil, nil
+	}
+	return []string{cluster.Spec.ClusterTemplateRevisionName}, nil
+}
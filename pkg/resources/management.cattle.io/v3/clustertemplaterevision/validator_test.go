@@ -0,0 +1,249 @@
+package clustertemplaterevision
+
+import (
+	"encoding/json"
+	"fmt"
+	"testing"
+
+	v3 "github.com/rancher/rancher/pkg/apis/management.cattle.io/v3"
+	rketypes "github.com/rancher/rke/types"
+	"github.com/rancher/webhook/pkg/admission"
+	"github.com/rancher/wrangler/v3/pkg/generic/fake"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+	admissionv1 "k8s.io/api/admission/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+const revisionReferenceName = "cattle-global-data:ctr-1"
+
+func TestAdmit(t *testing.T) {
+	t.Parallel()
+
+	newRevision := func() *v3.ClusterTemplateRevision {
+		return &v3.ClusterTemplateRevision{
+			ObjectMeta: metav1.ObjectMeta{Name: "ctr-1", Namespace: "cattle-global-data"},
+			Spec: v3.ClusterTemplateRevisionSpec{
+				DisplayName:         "revision",
+				ClusterTemplateName: "cattle-global-data:ct-1",
+				ClusterConfig: &v3.ClusterSpecBase{
+					RancherKubernetesEngineConfig: &rketypes.RancherKubernetesEngineConfig{Version: "v1.28.3-rancher1-1"},
+				},
+				Questions: []v3.Question{
+					{Variable: "rancherKubernetesEngineConfig.kubernetesVersion", Type: "string", Default: "v1.28.3-rancher1-1"},
+				},
+			},
+		}
+	}
+
+	tests := []struct {
+		name        string
+		operation   admissionv1.Operation
+		oldRevision func() *v3.ClusterTemplateRevision
+		newRevision func() *v3.ClusterTemplateRevision
+		clusters    []*v3.Cluster
+		clustersErr error
+		wantAllowed bool
+		wantErr     bool
+	}{
+		{
+			name:        "create valid revision",
+			operation:   admissionv1.Create,
+			newRevision: newRevision,
+			wantAllowed: true,
+		},
+		{
+			name:      "create without template",
+			operation: admissionv1.Create,
+			newRevision: func() *v3.ClusterTemplateRevision {
+				revision := newRevision()
+				revision.Spec.ClusterTemplateName = ""
+				return revision
+			},
+		},
+		{
+			name:      "create with invalid questions",
+			operation: admissionv1.Create,
+			newRevision: func() *v3.ClusterTemplateRevision {
+				revision := newRevision()
+				revision.Spec.Questions = append(revision.Spec.Questions, v3.Question{Variable: "replicas", Type: "int", Default: "two"})
+				return revision
+			},
+		},
+		{
+			name:        "update unused revision config",
+			operation:   admissionv1.Update,
+			oldRevision: newRevision,
+			newRevision: func() *v3.ClusterTemplateRevision {
+				revision := newRevision()
+				revision.Spec.ClusterConfig.RancherKubernetesEngineConfig.Version = "v1.29.1-rancher1-1"
+				return revision
+			},
+			wantAllowed: true,
+		},
+		{
+			name:        "update used revision config",
+			operation:   admissionv1.Update,
+			oldRevision: newRevision,
+			newRevision: func() *v3.ClusterTemplateRevision {
+				revision := newRevision()
+				revision.Spec.ClusterConfig.RancherKubernetesEngineConfig.Version = "v1.29.1-rancher1-1"
+				return revision
+			},
+			clusters: []*v3.Cluster{{ObjectMeta: metav1.ObjectMeta{Name: "c-1"}}},
+		},
+		{
+			name:        "update used revision questions",
+			operation:   admissionv1.Update,
+			oldRevision: newRevision,
+			newRevision: func() *v3.ClusterTemplateRevision {
+				revision := newRevision()
+				revision.Spec.Questions = nil
+				return revision
+			},
+			clusters: []*v3.Cluster{{ObjectMeta: metav1.ObjectMeta{Name: "c-1"}}},
+		},
+		{
+			name:        "disable used revision",
+			operation:   admissionv1.Update,
+			oldRevision: newRevision,
+			newRevision: func() *v3.ClusterTemplateRevision {
+				revision := newRevision()
+				revision.Spec.Enabled = admission.Ptr(false)
+				return revision
+			},
+			clusters:    []*v3.Cluster{{ObjectMeta: metav1.ObjectMeta{Name: "c-1"}}},
+			wantAllowed: true,
+		},
+		{
+			name:        "update template",
+			operation:   admissionv1.Update,
+			oldRevision: newRevision,
+			newRevision: func() *v3.ClusterTemplateRevision {
+				revision := newRevision()
+				revision.Spec.ClusterTemplateName = "cattle-global-data:ct-2"
+				return revision
+			},
+		},
+		{
+			name:        "delete unused revision",
+			operation:   admissionv1.Delete,
+			oldRevision: newRevision,
+			wantAllowed: true,
+		},
+		{
+			name:        "delete used revision",
+			operation:   admissionv1.Delete,
+			oldRevision: newRevision,
+			clusters:    []*v3.Cluster{{ObjectMeta: metav1.ObjectMeta{Name: "c-1"}}},
+		},
+		{
+			name:        "failed to get clusters",
+			operation:   admissionv1.Delete,
+			oldRevision: newRevision,
+			clustersErr: fmt.Errorf("indexer error"),
+			wantErr:     true,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			ctrl := gomock.NewController(t)
+			clusterCache := fake.NewMockNonNamespacedCacheInterface[*v3.Cluster](ctrl)
+			clusterCache.EXPECT().AddIndexer(byClusterTemplateRevision, gomock.Any())
+			clusterCache.EXPECT().GetByIndex(byClusterTemplateRevision, revisionReferenceName).Return(tt.clusters, tt.clustersErr).AnyTimes()
+			validator := NewValidator(clusterCache)
+
+			request := &admission.Request{AdmissionRequest: admissionv1.AdmissionRequest{Operation: tt.operation}}
+			if tt.newRevision != nil {
+				request.Object = runtime.RawExtension{Raw: mustMarshal(t, tt.newRevision())}
+			}
+			if tt.oldRevision != nil {
+				request.OldObject = runtime.RawExtension{Raw: mustMarshal(t, tt.oldRevision())}
+			}
+			response, err := validator.Admitters()[0].Admit(request)
+			if tt.wantErr {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.wantAllowed, response.Allowed, response.Result)
+		})
+	}
+}
+
+func TestValidateQuestions(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name      string
+		questions []v3.Question
+		wantErrs  int
+	}{
+		{
+			name: "valid questions",
+			questions: []v3.Question{
+				{Variable: "a", Type: "string", MinLength: 1, MaxLength: 10},
+				{Variable: "b", Type: "int", Default: "3", Min: 1, Max: 5},
+				{Variable: "c", Type: "enum", Options: []string{"x", "y"}, Default: "y"},
+				{Variable: "d", Type: "boolean", Default: "true", Subquestions: []v3.SubQuestion{{Variable: "e", Type: "password"}}},
+				{Variable: "f"},
+			},
+		},
+		{
+			name:      "missing variable",
+			questions: []v3.Question{{Type: "string"}},
+			wantErrs:  1,
+		},
+		{
+			name: "duplicate variables",
+			questions: []v3.Question{
+				{Variable: "a"},
+				{Variable: "b", Subquestions: []v3.SubQuestion{{Variable: "a"}}},
+			},
+			wantErrs: 1,
+		},
+		{
+			name:      "unknown type",
+			questions: []v3.Question{{Variable: "a", Type: "list"}},
+			wantErrs:  1,
+		},
+		{
+			name:      "enum without options",
+			questions: []v3.Question{{Variable: "a", Type: "enum"}},
+			wantErrs:  1,
+		},
+		{
+			name: "invalid defaults",
+			questions: []v3.Question{
+				{Variable: "a", Type: "enum", Options: []string{"x"}, Default: "z"},
+				{Variable: "b", Type: "float", Default: "one"},
+				{Variable: "c", Type: "boolean", Default: "maybe", Subquestions: []v3.SubQuestion{{Variable: "d", Type: "int", Default: "1.5"}}},
+			},
+			wantErrs: 4,
+		},
+		{
+			name:      "invalid bounds",
+			questions: []v3.Question{{Variable: "a", Type: "int", Min: 5, Max: 1, MinLength: 3, MaxLength: 2}},
+			wantErrs:  2,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			errs := validateQuestions(nil, tt.questions)
+			assert.Len(t, errs, tt.wantErrs, errs)
+		})
+	}
+}
+
+func mustMarshal(t *testing.T, obj any) []byte {
+	t.Helper()
+	data, err := json.Marshal(obj)
+	require.NoError(t, err)
+	return data
+}
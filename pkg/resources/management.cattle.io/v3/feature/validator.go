@@ -1,15 +1,24 @@
 package feature
 
 import (
+	"encoding/json"
 	"fmt"
 	"net/http"
+	"sort"
+	"strings"
 
+	"github.com/rancher/lasso/pkg/dynamic"
 	v3 "github.com/rancher/rancher/pkg/apis/management.cattle.io/v3"
 	"github.com/rancher/webhook/pkg/admission"
+	provv1 "github.com/rancher/webhook/pkg/generated/controllers/provisioning.cattle.io/v1"
 	objectsv3 "github.com/rancher/webhook/pkg/generated/objects/management.cattle.io/v3"
+	"github.com/rancher/webhook/pkg/resources/common"
 	admissionv1 "k8s.io/api/admission/v1"
 	admissionregistrationv1 "k8s.io/api/admissionregistration/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/runtime/schema"
 	"k8s.io/kubernetes/pkg/registry/rbac/validation"
 	"k8s.io/utils/trace"
@@ -21,15 +30,57 @@ var gvr = schema.GroupVersionResource{
 	Resource: "features",
 }
 
+// managementClusterGVK is listed through the dynamic cache, since the vendored Rancher API doesn't have the scheduling
+// customization of the cluster agent yet and decoding into the typed clusters would drop it.
+var managementClusterGVK = schema.GroupVersionKind{
+	Group:   "management.cattle.io",
+	Version: "v3",
+	Kind:    "Cluster",
+}
+
+const (
+	// forceAnnotation allows disabling a feature which still has dependents when set to "true".
+	forceAnnotation = "cattle.io/force"
+	rke2Feature     = "rke2"
+	// schedulingCustomizationFeature enables the scheduling customization of the cluster agent.
+	schedulingCustomizationFeature = "cluster-agent-scheduling-customization"
+	// maxListedDependents is the maximum number of dependents named in the message of a denied request.
+	maxListedDependents = 5
+)
+
+// dependency lists the objects which depend on a feature being enabled.
+type dependency struct {
+	// description describes the dependent objects.
+	description string
+	// dependents returns the names of the dependent objects.
+	dependents func() ([]string, error)
+}
+
+// dynamicLister is an interface to abstract away how we list dynamic objects from k8s.
+type dynamicLister interface {
+	List(gvk schema.GroupVersionKind, namespace string, selector labels.Selector) ([]runtime.Object, error)
+}
+
 // Validator for validating features.
 type Validator struct {
 	admitter admitter
 }
 
 // NewValidator returns a new validator for features.
-func NewValidator() *Validator {
+func NewValidator(clusterCache provv1.ClusterCache, dynamic *dynamic.Controller) *Validator {
 	return &Validator{
-		admitter: admitter{},
+		admitter: admitter{
+			dependencies: map[string][]dependency{
+				rke2Feature: {{
+					description: "provisioning clusters with an rkeConfig",
+					dependents:  rkeClusters(clusterCache),
+				}},
+				schedulingCustomizationFeature: {{
+					description: "clusters with a cluster agent scheduling customization",
+					dependents:  schedulingCustomizationClusters(dynamic),
+				}},
+			},
+		},
 	}
 }
 
@@ -57,6 +108,8 @@ func (v *Validator) Admitters() []admission.Admitter {
 
 type admitter struct {
 	ruleResolver validation.AuthorizationRuleResolver
+	// dependencies are the dependencies of each feature, by feature name.
+	dependencies map[string][]dependency
 }
 
 // Admit handles the webhook admission request sent to this webhook.
@@ -81,11 +134,92 @@ func (a *admitter) Admit(request *admission.Request) (*admissionv1.AdmissionResp
 		}, nil
 	}
 
+	if isDisabling(oldFeature, newFeature) && newFeature.Annotations[forceAnnotation] != "true" {
+		for _, dep := range a.dependencies[newFeature.Name] {
+			dependents, err := dep.dependents()
+			if err != nil {
+				return nil, fmt.Errorf("failed to list the dependents of feature %s: %w", newFeature.Name, err)
+			}
+			if len(dependents) > 0 {
+				return admission.ResponseBadRequest(fmt.Sprintf("feature %s can't be disabled while %s exist: %s. Remove them first, or set the %s annotation to \"true\" to disable it anyway",
+					newFeature.Name, dep.description, listDependents(dependents), forceAnnotation)), nil
+			}
+		}
+	}
+
 	return &admissionv1.AdmissionResponse{
 		Allowed: true,
 	}, nil
 }
 
+// isDisabling returns true if the value of the feature is being set to false while the feature is enabled.
+func isDisabling(oldFeature, newFeature *v3.Feature) bool {
+	if newFeature.Spec.Value == nil || *newFeature.Spec.Value {
+		return false
+	}
+	return common.FeatureEffectiveValue(oldFeature)
+}
+
+// listDependents returns the sorted names of the first maxListedDependents dependents, followed by the number of
+// dependents left out.
+func listDependents(dependents []string) string {
+	sort.Strings(dependents)
+	if len(dependents) <= maxListedDependents {
+		return strings.Join(dependents, ", ")
+	}
+	return fmt.Sprintf("%s and %d more", strings.Join(dependents[:maxListedDependents], ", "), len(dependents)-maxListedDependents)
+}
+
+// rkeClusters returns a function listing the provisioning clusters with an rkeConfig, which are provisioned with RKE2
+// or K3s.
+func rkeClusters(clusterCache provv1.ClusterCache) func() ([]string, error) {
+	return func() ([]string, error) {
+		clusters, err := clusterCache.List("", labels.Everything())
+		if err != nil {
+			return nil, err
+		}
+		var names []string
+		for _, cluster := range clusters {
+			if cluster.Spec.RKEConfig != nil {
+				names = append(names, cluster.Namespace+"/"+cluster.Name)
+			}
+		}
+		return names, nil
+	}
+}
+
+// schedulingCustomizationClusters returns a function listing the management clusters whose cluster agent has a
+// scheduling customization. Rancher copies the customization of provisioning clusters to their management cluster, so
+// they are covered as well.
+func schedulingCustomizationClusters(lister dynamicLister) func() ([]string, error) {
+	return func() ([]string, error) {
+		clusters, err := lister.List(managementClusterGVK, "", labels.Everything())
+		if err != nil {
+			return nil, err
+		}
+		var names []string
+		for _, cluster := range clusters {
+			raw, err := json.Marshal(cluster)
+			if err != nil {
+				return nil, fmt.Errorf("failed to encode cluster: %w", err)
+			}
+			customization, err := common.ClusterAgentSchedulingCustomization(raw)
+			if err != nil {
+				return nil, err
+			}
+			if customization == nil {
+				continue
+			}
+			clusterMeta, err := meta.Accessor(cluster)
+			if err != nil {
+				return nil, err
+			}
+			names = append(names, clusterMeta.GetName())
+		}
+		return names, nil
+	}
+}
+
 // isUpdateAllowed checks that the new value does not change on spec unless it's equal to the lockedValue,
 // or lockedValue is nil.
 func isUpdateAllowed(oldFeature, newFeature *v3.Feature) bool {
@@ -5,13 +5,19 @@ import (
 	"testing"
 
 	v3 "github.com/rancher/rancher/pkg/apis/management.cattle.io/v3"
+	provv1 "github.com/rancher/rancher/pkg/apis/provisioning.cattle.io/v1"
 	"github.com/rancher/webhook/pkg/admission"
+	"github.com/rancher/wrangler/v3/pkg/generic/fake"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
 	admissionv1 "k8s.io/api/admission/v1"
 	authenicationv1 "k8s.io/api/authentication/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/labels"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
 )
 
 var (
@@ -101,7 +107,7 @@ func TestFeatureValueValid(t *testing.T) {
 		test := test
 		t.Run(test.name, func(t *testing.T) {
 			t.Parallel()
-			admitters := NewValidator().Admitters()
+			admitters := NewValidator(nil, nil).Admitters()
 			assert.Len(t, admitters, 1)
 
 			req := admission.Request{
@@ -137,7 +143,7 @@ func TestFeatureValueValid(t *testing.T) {
 
 func TestRejectsBadRequest(t *testing.T) {
 	t.Parallel()
-	admitters := NewValidator().Admitters()
+	admitters := NewValidator(nil, nil).Admitters()
 	assert.Len(t, admitters, 1)
 
 	req := admission.Request{
@@ -158,3 +164,148 @@ func TestRejectsBadRequest(t *testing.T) {
 	_, err := admitters[0].Admit(&req)
 	require.Error(t, err)
 }
+
+func TestFeatureDependencies(t *testing.T) {
+	t.Parallel()
+	clusters := []*provv1.Cluster{
+		{ObjectMeta: metav1.ObjectMeta{Namespace: "fleet-default", Name: "rke2"}, Spec: provv1.ClusterSpec{RKEConfig: &provv1.RKEConfig{}}},
+		{ObjectMeta: metav1.ObjectMeta{Namespace: "fleet-default", Name: "imported"}},
+	}
+	tests := []struct {
+		name        string
+		feature     string
+		oldValue    *bool
+		newValue    *bool
+		annotations map[string]string
+		clusters    []*provv1.Cluster
+		wantAdmit   bool
+	}{
+		{
+			name:     "disabling with dependents",
+			feature:  rke2Feature,
+			oldValue: admission.Ptr(true),
+			newValue: admission.Ptr(false),
+			clusters: clusters,
+		},
+		{
+			name:      "disabling a feature enabled by default with dependents",
+			feature:   rke2Feature,
+			newValue:  admission.Ptr(false),
+			clusters:  clusters,
+			wantAdmit: false,
+		},
+		{
+			name:      "disabling without dependents",
+			feature:   rke2Feature,
+			oldValue:  admission.Ptr(true),
+			newValue:  admission.Ptr(false),
+			clusters:  clusters[1:],
+			wantAdmit: true,
+		},
+		{
+			name:        "forced disabling with dependents",
+			feature:     rke2Feature,
+			oldValue:    admission.Ptr(true),
+			newValue:    admission.Ptr(false),
+			annotations: map[string]string{forceAnnotation: "true"},
+			clusters:    clusters,
+			wantAdmit:   true,
+		},
+		{
+			name:      "enabling with dependents",
+			feature:   rke2Feature,
+			oldValue:  admission.Ptr(false),
+			newValue:  admission.Ptr(true),
+			clusters:  clusters,
+			wantAdmit: true,
+		},
+		{
+			name:      "disabling a feature without dependencies",
+			feature:   "harvester",
+			oldValue:  admission.Ptr(true),
+			newValue:  admission.Ptr(false),
+			clusters:  clusters,
+			wantAdmit: true,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+			ctrl := gomock.NewController(t)
+			clusterCache := fake.NewMockCacheInterface[*provv1.Cluster](ctrl)
+			clusterCache.EXPECT().List("", gomock.Any()).Return(test.clusters, nil).AnyTimes()
+			admitters := NewValidator(clusterCache, nil).Admitters()
+
+			oldFeature := v3.Feature{
+				ObjectMeta: metav1.ObjectMeta{Name: test.feature},
+				Spec:       v3.FeatureSpec{Value: test.oldValue},
+				Status:     v3.FeatureStatus{Default: true},
+			}
+			newFeature := *oldFeature.DeepCopy()
+			newFeature.Annotations = test.annotations
+			newFeature.Spec.Value = test.newValue
+			req := admission.Request{
+				AdmissionRequest: admissionv1.AdmissionRequest{
+					UID:       "3",
+					Kind:      featureGVK,
+					Resource:  featureGVR,
+					Name:      test.feature,
+					Operation: admissionv1.Update,
+					UserInfo:  authenicationv1.UserInfo{Username: "test-user"},
+				},
+			}
+			var err error
+			req.Object.Raw, err = json.Marshal(newFeature)
+			require.NoError(t, err)
+			req.OldObject.Raw, err = json.Marshal(oldFeature)
+			require.NoError(t, err)
+
+			response, err := admitters[0].Admit(&req)
+			require.NoError(t, err)
+			assert.Equal(t, test.wantAdmit, response.Allowed)
+			if !test.wantAdmit {
+				assert.Contains(t, response.Result.Message, "fleet-default/rke2")
+				assert.NotContains(t, response.Result.Message, "imported")
+			}
+		})
+	}
+}
+
+type mockLister struct {
+	toReturn []runtime.Object
+}
+
+func (m *mockLister) List(_ schema.GroupVersionKind, _ string, _ labels.Selector) ([]runtime.Object, error) {
+	return m.toReturn, nil
+}
+
+func TestSchedulingCustomizationClusters(t *testing.T) {
+	t.Parallel()
+	lister := &mockLister{toReturn: []runtime.Object{
+		&unstructured.Unstructured{Object: map[string]any{
+			"metadata": map[string]any{"name": "c-customized"},
+			"spec": map[string]any{"clusterAgentDeploymentCustomization": map[string]any{
+				"schedulingCustomization": map[string]any{"priorityClass": map[string]any{"value": int64(1000)}},
+			}},
+		}},
+		&unstructured.Unstructured{Object: map[string]any{
+			"metadata": map[string]any{"name": "c-tolerations"},
+			"spec": map[string]any{"clusterAgentDeploymentCustomization": map[string]any{
+				"appendTolerations": []any{map[string]any{"key": "example"}},
+			}},
+		}},
+		&unstructured.Unstructured{Object: map[string]any{
+			"metadata": map[string]any{"name": "local"},
+		}},
+	}}
+
+	names, err := schedulingCustomizationClusters(lister)()
+	require.NoError(t, err)
+	assert.Equal(t, []string{"c-customized"}, names)
+}
+
+func TestListDependents(t *testing.T) {
+	t.Parallel()
+	assert.Equal(t, "a, b", listDependents([]string{"b", "a"}))
+	assert.Equal(t, "a, b, c, d, e and 2 more", listDependents([]string{"g", "f", "e", "d", "c", "b", "a"}))
+}
@@ -0,0 +1,163 @@
+package fleetworkspace
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	v3 "github.com/rancher/rancher/pkg/apis/management.cattle.io/v3"
+	"github.com/rancher/webhook/pkg/admission"
+	"github.com/rancher/webhook/pkg/auth"
+	controllerv3 "github.com/rancher/webhook/pkg/generated/controllers/management.cattle.io/v3"
+	provv1 "github.com/rancher/webhook/pkg/generated/controllers/provisioning.cattle.io/v1"
+	objectsv3 "github.com/rancher/webhook/pkg/generated/objects/management.cattle.io/v3"
+	"github.com/rancher/webhook/pkg/resources/common"
+	admissionv1 "k8s.io/api/admission/v1"
+	admissionregistrationv1 "k8s.io/api/admissionregistration/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	authorizationv1 "k8s.io/client-go/kubernetes/typed/authorization/v1"
+	"k8s.io/utils/trace"
+)
+
+const (
+	byFleetWorkspace = "fleetWorkspace"
+	// forceAnnotation allows an administrator to delete a FleetWorkspace which still has clusters when set to "true".
+	forceAnnotation = "cattle.io/force"
+	// maxListedClusters is the maximum number of clusters named in the message of a denied deletion.
+	maxListedClusters = 5
+	// fleetLocalWorkspace is the FleetWorkspace of the local cluster, created by Rancher.
+	fleetLocalWorkspace = "fleet-local"
+)
+
+// NewValidator returns a new validator for FleetWorkspaces. Only the uninstallServiceAccount can delete the fleet-local
+// FleetWorkspace.
+func NewValidator(clusterCache controllerv3.ClusterCache, provClusterCache provv1.ClusterCache, sar authorizationv1.SubjectAccessReviewInterface,
+	uninstallServiceAccount common.UninstallServiceAccount) *Validator {
+	clusterCache.AddIndexer(byFleetWorkspace, clusterByFleetWorkspace)
+	return &Validator{
+		admitter: admitter{
+			clusterCache:            clusterCache,
+			provClusterCache:        provClusterCache,
+			sar:                     sar,
+			uninstallServiceAccount: uninstallServiceAccount,
+		},
+	}
+}
+
+// Validator for validating FleetWorkspaces.
+type Validator struct {
+	admitter admitter
+}
+
+// GVR returns the GroupVersionKind for this CRD.
+func (v *Validator) GVR() schema.GroupVersionResource {
+	return gvr
+}
+
+// Operations returns list of operations handled by this validator.
+func (v *Validator) Operations() []admissionregistrationv1.OperationType {
+	return []admissionregistrationv1.OperationType{admissionregistrationv1.Delete}
+}
+
+// ValidatingWebhook returns the ValidatingWebhook used for this CRD.
+func (v *Validator) ValidatingWebhook(clientConfig admissionregistrationv1.WebhookClientConfig) []admissionregistrationv1.ValidatingWebhook {
+	return []admissionregistrationv1.ValidatingWebhook{*admission.NewDefaultValidatingWebhook(v, clientConfig, admissionregistrationv1.ClusterScope, v.Operations())}
+}
+
+// Admitters returns the admitter objects used to validate FleetWorkspaces.
+func (v *Validator) Admitters() []admission.Admitter {
+	return []admission.Admitter{&v.admitter}
+}
+
+type admitter struct {
+	clusterCache            controllerv3.ClusterCache
+	provClusterCache        provv1.ClusterCache
+	sar                     authorizationv1.SubjectAccessReviewInterface
+	uninstallServiceAccount common.UninstallServiceAccount
+}
+
+// Admit handles the webhook admission request sent to this webhook.
+func (a *admitter) Admit(request *admission.Request) (*admissionv1.AdmissionResponse, error) {
+	listTrace := trace.New("fleetWorkspaceValidator Admit", trace.Field{Key: "user", Value: request.UserInfo.Username})
+	defer listTrace.LogIfLong(admission.SlowTraceDuration)
+
+	if request.Operation != admissionv1.Delete {
+		return nil, fmt.Errorf("%s operation %v: %w", gvr.Resource, request.Operation, admission.ErrUnsupportedOperation)
+	}
+	workspace, err := objectsv3.FleetWorkspaceFromRequest(&request.AdmissionRequest)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get FleetWorkspace from request: %w", err)
+	}
+	if workspace.Name == fleetLocalWorkspace && !a.uninstallServiceAccount.IsUser(request) {
+		// the local cluster is assigned to fleet-local, which Rancher only deletes when it is uninstalled
+		return admission.ResponseBadRequest(fmt.Sprintf("cannot delete FleetWorkspace %s as it is required by Rancher", workspace.Name)), nil
+	}
+
+	clusters, err := a.residualClusters(workspace.Name)
+	if err != nil {
+		return nil, err
+	}
+	if len(clusters) == 0 {
+		return admission.ResponseAllowed(), nil
+	}
+	if workspace.Annotations[forceAnnotation] != "true" {
+		return admission.ResponseBadRequest(fmt.Sprintf("cannot delete FleetWorkspace %s as it is used by %d clusters: %s. Move or delete them first, or have an administrator set the %s annotation to \"true\" to delete it anyway",
+			workspace.Name, len(clusters), listClusters(clusters), forceAnnotation)), nil
+	}
+
+	isAdmin, err := auth.RequestUserIsAdmin(request, a.sar)
+	if err != nil {
+		return nil, fmt.Errorf("failed to check if user is an administrator: %w", err)
+	}
+	if !isAdmin {
+		return admission.ResponseFailedEscalation(fmt.Sprintf("only administrators can delete FleetWorkspace %s while it is used by clusters", workspace.Name)), nil
+	}
+	return admission.ResponseAllowed(), nil
+}
+
+// residualClusters returns the names of the management clusters assigned to the workspace, and of the provisioning
+// clusters in its namespace.
+func (a *admitter) residualClusters(workspace string) ([]string, error) {
+	mgmtClusters, err := a.clusterCache.GetByIndex(byFleetWorkspace, workspace)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get clusters in FleetWorkspace %s: %w", workspace, err)
+	}
+	provClusters, err := a.provClusterCache.List(workspace, labels.Everything())
+	if err != nil {
+		return nil, fmt.Errorf("failed to list provisioning clusters in FleetWorkspace %s: %w", workspace, err)
+	}
+	names := make(map[string]struct{}, len(mgmtClusters)+len(provClusters))
+	for _, cluster := range mgmtClusters {
+		names[cluster.Name] = struct{}{}
+	}
+	for _, cluster := range provClusters {
+		// provisioning clusters are backed by a management cluster named in their status
+		if cluster.Status.ClusterName != "" {
+			if _, ok := names[cluster.Status.ClusterName]; ok {
+				continue
+			}
+		}
+		names[cluster.Namespace+"/"+cluster.Name] = struct{}{}
+	}
+	clusters := make([]string, 0, len(names))
+	for name := range names {
+		clusters = append(clusters, name)
+	}
+	sort.Strings(clusters)
+	return clusters, nil
+}
+
+func listClusters(clusters []string) string {
+	if len(clusters) <= maxListedClusters {
+		return strings.Join(clusters, ", ")
+	}
+	return fmt.Sprintf("%s and %d more", strings.Join(clusters[:maxListedClusters], ", "), len(clusters)-maxListedClusters)
+}
+
+func clusterByFleetWorkspace(cluster *v3.Cluster) ([]string, error) {
+	if cluster.Spec.FleetWorkspaceName == "" {
+		return nil, nil
+	}
+	return []string{cluster.Spec.FleetWorkspaceName}, nil
+}
@@ -0,0 +1,192 @@
+package fleetworkspace
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"testing"
+
+	v3 "github.com/rancher/rancher/pkg/apis/management.cattle.io/v3"
+	provv1 "github.com/rancher/rancher/pkg/apis/provisioning.cattle.io/v1"
+	"github.com/rancher/webhook/pkg/admission"
+	"github.com/rancher/webhook/pkg/resources/common"
+	"github.com/rancher/wrangler/v3/pkg/generic/fake"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+	admissionv1 "k8s.io/api/admission/v1"
+	authenticationv1 "k8s.io/api/authentication/v1"
+	authorizationv1 "k8s.io/api/authorization/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	k8fake "k8s.io/client-go/kubernetes/typed/authorization/v1/fake"
+	k8testing "k8s.io/client-go/testing"
+)
+
+func TestValidatorAdmit(t *testing.T) {
+	t.Parallel()
+
+	const workspaceName = "fleet-default"
+	mgmtCluster := &v3.Cluster{ObjectMeta: metav1.ObjectMeta{Name: "c-m-abcde"}, Spec: v3.ClusterSpec{FleetWorkspaceName: workspaceName}}
+	provCluster := &provv1.Cluster{
+		ObjectMeta: metav1.ObjectMeta{Name: "downstream", Namespace: workspaceName},
+		Status:     provv1.ClusterStatus{ClusterName: "c-m-abcde"},
+	}
+	pendingProvCluster := &provv1.Cluster{ObjectMeta: metav1.ObjectMeta{Name: "pending", Namespace: workspaceName}}
+
+	tests := []struct {
+		name          string
+		force         bool
+		mgmtClusters  []*v3.Cluster
+		provClusters  []*provv1.Cluster
+		clustersErr   error
+		isAdmin       bool
+		wantAllowed   bool
+		wantCode      int32
+		wantSAR       bool
+		wantErr       bool
+		wantInMessage string
+	}{
+		{
+			name:        "empty workspace",
+			wantAllowed: true,
+		},
+		{
+			name:          "workspace with clusters",
+			mgmtClusters:  []*v3.Cluster{mgmtCluster},
+			provClusters:  []*provv1.Cluster{provCluster, pendingProvCluster},
+			wantCode:      http.StatusBadRequest,
+			wantInMessage: "used by 2 clusters: c-m-abcde, fleet-default/pending",
+		},
+		{
+			name:         "forced by an admin",
+			force:        true,
+			mgmtClusters: []*v3.Cluster{mgmtCluster},
+			isAdmin:      true,
+			wantAllowed:  true,
+			wantSAR:      true,
+		},
+		{
+			name:         "forced by a non-admin",
+			force:        true,
+			provClusters: []*provv1.Cluster{pendingProvCluster},
+			wantCode:     http.StatusForbidden,
+			wantSAR:      true,
+		},
+		{
+			name:        "forced empty workspace doesn't need an admin",
+			force:       true,
+			wantAllowed: true,
+		},
+		{
+			name:        "failed to get clusters",
+			clustersErr: fmt.Errorf("indexer error"),
+			wantErr:     true,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			ctrl := gomock.NewController(t)
+			clusterCache := fake.NewMockNonNamespacedCacheInterface[*v3.Cluster](ctrl)
+			clusterCache.EXPECT().AddIndexer(byFleetWorkspace, gomock.Any())
+			clusterCache.EXPECT().GetByIndex(byFleetWorkspace, workspaceName).Return(tt.mgmtClusters, tt.clustersErr).AnyTimes()
+			provClusterCache := fake.NewMockCacheInterface[*provv1.Cluster](ctrl)
+			provClusterCache.EXPECT().List(workspaceName, gomock.Any()).Return(tt.provClusters, nil).AnyTimes()
+			sarCalled := false
+			k8Fake := &k8testing.Fake{}
+			k8Fake.AddReactor("create", "subjectaccessreviews", func(action k8testing.Action) (bool, runtime.Object, error) {
+				sarCalled = true
+				review := action.(k8testing.CreateActionImpl).GetObject().(*authorizationv1.SubjectAccessReview)
+				assert.Equal(t, "*", review.Spec.ResourceAttributes.Verb)
+				assert.Equal(t, "*", review.Spec.ResourceAttributes.Resource)
+				review.Status.Allowed = tt.isAdmin
+				return true, review, nil
+			})
+			sar := &k8fake.FakeSubjectAccessReviews{Fake: &k8fake.FakeAuthorizationV1{Fake: k8Fake}}
+
+			workspace := &v3.FleetWorkspace{ObjectMeta: metav1.ObjectMeta{Name: workspaceName}}
+			if tt.force {
+				workspace.Annotations = map[string]string{forceAnnotation: "true"}
+			}
+			raw, err := json.Marshal(workspace)
+			require.NoError(t, err)
+			request := &admission.Request{AdmissionRequest: admissionv1.AdmissionRequest{
+				Operation: admissionv1.Delete,
+				Name:      workspaceName,
+				OldObject: runtime.RawExtension{Raw: raw},
+				UserInfo:  authenticationv1.UserInfo{Username: user},
+			}}
+
+			admitters := NewValidator(clusterCache, provClusterCache, sar, common.UninstallServiceAccount{}).Admitters()
+			require.Len(t, admitters, 1)
+			response, err := admitters[0].Admit(request)
+			if tt.wantErr {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.wantAllowed, response.Allowed)
+			assert.Equal(t, tt.wantSAR, sarCalled)
+			if !tt.wantAllowed {
+				assert.Equal(t, tt.wantCode, response.Result.Code)
+				assert.Contains(t, response.Result.Message, tt.wantInMessage)
+			}
+		})
+	}
+}
+
+func TestValidatorAdmitFleetLocal(t *testing.T) {
+	t.Parallel()
+
+	uninstaller := common.UninstallServiceAccount{Namespace: "cattle-system", Name: "rancher-uninstall"}
+	tests := []struct {
+		name        string
+		username    string
+		wantAllowed bool
+	}{
+		{
+			name:     "deleted by a user",
+			username: user,
+		},
+		{
+			name:     "deleted by another service account",
+			username: "system:serviceaccount:cattle-system:rancher",
+		},
+		{
+			name:        "deleted by the uninstall service account",
+			username:    "system:serviceaccount:cattle-system:rancher-uninstall",
+			wantAllowed: true,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			ctrl := gomock.NewController(t)
+			clusterCache := fake.NewMockNonNamespacedCacheInterface[*v3.Cluster](ctrl)
+			clusterCache.EXPECT().AddIndexer(byFleetWorkspace, gomock.Any())
+			clusterCache.EXPECT().GetByIndex(byFleetWorkspace, fleetLocalWorkspace).Return(nil, nil).AnyTimes()
+			provClusterCache := fake.NewMockCacheInterface[*provv1.Cluster](ctrl)
+			provClusterCache.EXPECT().List(fleetLocalWorkspace, gomock.Any()).Return(nil, nil).AnyTimes()
+
+			raw, err := json.Marshal(&v3.FleetWorkspace{ObjectMeta: metav1.ObjectMeta{Name: fleetLocalWorkspace}})
+			require.NoError(t, err)
+			request := &admission.Request{AdmissionRequest: admissionv1.AdmissionRequest{
+				Operation: admissionv1.Delete,
+				Name:      fleetLocalWorkspace,
+				OldObject: runtime.RawExtension{Raw: raw},
+				UserInfo:  authenticationv1.UserInfo{Username: tt.username},
+			}}
+
+			response, err := NewValidator(clusterCache, provClusterCache, nil, uninstaller).Admitters()[0].Admit(request)
+			require.NoError(t, err)
+			assert.Equal(t, tt.wantAllowed, response.Allowed)
+			if !tt.wantAllowed {
+				assert.Equal(t, int32(http.StatusBadRequest), response.Result.Code)
+				assert.Contains(t, response.Result.Message, "required by Rancher")
+			}
+		})
+	}
+}
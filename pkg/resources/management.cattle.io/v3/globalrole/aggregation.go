@@ -0,0 +1,87 @@
+package globalrole
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+
+	v3 "github.com/rancher/rancher/pkg/apis/management.cattle.io/v3"
+	rbacv1 "k8s.io/api/rbac/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	metav1validation "k8s.io/apimachinery/pkg/apis/meta/v1/validation"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+)
+
+// aggregatedGlobalRole is a GlobalRole with its aggregationRule. The aggregationRule is not part of the v3.GlobalRole
+// type, so it is decoded separately from the raw object.
+type aggregatedGlobalRole struct {
+	v3.GlobalRole
+	// AggregationRule selects the GlobalRoles whose rules are aggregated into this GlobalRole, like the
+	// aggregationRule of a ClusterRole.
+	AggregationRule *rbacv1.AggregationRule `json:"aggregationRule,omitempty"`
+}
+
+// aggregationRuleFromRaw returns the aggregationRule of the raw GlobalRole, or nil if it has none.
+func aggregationRuleFromRaw(raw []byte) (*rbacv1.AggregationRule, error) {
+	if len(raw) == 0 {
+		return nil, nil
+	}
+	var gr aggregatedGlobalRole
+	if err := json.Unmarshal(raw, &gr); err != nil {
+		return nil, fmt.Errorf("failed to decode aggregationRule: %w", err)
+	}
+	return gr.AggregationRule, nil
+}
+
+// validateAggregationRule checks that the aggregationRule has at least one selector and that all of its selectors are
+// valid label selectors.
+func validateAggregationRule(rule *rbacv1.AggregationRule, fldPath *field.Path) field.ErrorList {
+	if rule == nil {
+		return nil
+	}
+	selectorsPath := fldPath.Child("clusterRoleSelectors")
+	if len(rule.ClusterRoleSelectors) == 0 {
+		return field.ErrorList{field.Required(selectorsPath, "at least one selector is required")}
+	}
+	var errs field.ErrorList
+	for i := range rule.ClusterRoleSelectors {
+		errs = append(errs, metav1validation.ValidateLabelSelector(&rule.ClusterRoleSelectors[i], metav1validation.LabelSelectorValidationOptions{}, selectorsPath.Index(i))...)
+	}
+	return errs
+}
+
+// validateBuiltinAggregationRule forbids changes to the aggregationRule of builtin GlobalRoles.
+func validateBuiltinAggregationRule(oldGR *v3.GlobalRole, oldRule, newRule *rbacv1.AggregationRule, fldPath *field.Path) *field.Error {
+	if oldGR == nil || !oldGR.Builtin || reflect.DeepEqual(oldRule, newRule) {
+		return nil
+	}
+	return field.Forbidden(fldPath, "updates to the aggregationRule of builtIn GlobalRoles are forbidden")
+}
+
+// aggregatedRules returns the rules of the GlobalRoles selected by the aggregationRule, excluding the GlobalRole
+// itself. Only the rules of the selected GlobalRoles are aggregated, not the rules they aggregate themselves.
+func (a *admitter) aggregatedRules(grName string, rule *rbacv1.AggregationRule) ([]rbacv1.PolicyRule, error) {
+	if rule == nil {
+		return nil, nil
+	}
+	var rules []rbacv1.PolicyRule
+	seen := map[string]struct{}{grName: {}}
+	for i := range rule.ClusterRoleSelectors {
+		selector, err := metav1.LabelSelectorAsSelector(&rule.ClusterRoleSelectors[i])
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse aggregationRule selector: %w", err)
+		}
+		globalRoles, err := a.grResolver.GlobalRoleCache().List(selector)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list GlobalRoles for aggregationRule: %w", err)
+		}
+		for _, gr := range globalRoles {
+			if _, ok := seen[gr.Name]; ok {
+				continue
+			}
+			seen[gr.Name] = struct{}{}
+			rules = append(rules, a.grResolver.GlobalRulesFromRole(gr)...)
+		}
+	}
+	return rules, nil
+}
@@ -0,0 +1,141 @@
+package globalrole_test
+
+import (
+	"encoding/json"
+	"testing"
+
+	v3 "github.com/rancher/rancher/pkg/apis/management.cattle.io/v3"
+	"github.com/rancher/webhook/pkg/resources/management.cattle.io/v3/globalrole"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+	admissionv1 "k8s.io/api/admission/v1"
+	v1 "k8s.io/api/rbac/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+)
+
+const aggregateLabel = "rbac.example.com/aggregate-to-test"
+
+func newAggregatedGR(t *testing.T, gr *v3.GlobalRole, rule *v1.AggregationRule) []byte {
+	t.Helper()
+	raw, err := json.Marshal(gr)
+	require.NoError(t, err)
+	obj := map[string]any{}
+	require.NoError(t, json.Unmarshal(raw, &obj))
+	if rule != nil {
+		obj["aggregationRule"] = rule
+	}
+	raw, err = json.Marshal(obj)
+	require.NoError(t, err)
+	return raw
+}
+
+func TestAdmitAggregation(t *testing.T) {
+	t.Parallel()
+	selector := metav1.LabelSelector{MatchLabels: map[string]string{aggregateLabel: "true"}}
+	readPodsGR := &v3.GlobalRole{
+		ObjectMeta: metav1.ObjectMeta{Name: "read-pods", Labels: map[string]string{aggregateLabel: "true"}},
+		Rules:      []v1.PolicyRule{ruleReadPods},
+	}
+	adminGR := &v3.GlobalRole{
+		ObjectMeta: metav1.ObjectMeta{Name: "admin", Labels: map[string]string{aggregateLabel: "true"}},
+		Rules:      []v1.PolicyRule{ruleAdmin},
+	}
+	builtinGR := func() *v3.GlobalRole {
+		gr := newDefaultGR()
+		gr.Builtin = true
+		return gr
+	}
+
+	tests := []struct {
+		name       string
+		oldGR      []byte
+		newGR      []byte
+		aggregated []*v3.GlobalRole
+		escalate   bool
+		allowed    bool
+	}{
+		{
+			name:       "aggregate rules the user has",
+			newGR:      newAggregatedGR(t, newDefaultGR(), &v1.AggregationRule{ClusterRoleSelectors: []metav1.LabelSelector{selector}}),
+			aggregated: []*v3.GlobalRole{readPodsGR},
+			allowed:    true,
+		},
+		{
+			name:       "aggregate rules the user does not have",
+			newGR:      newAggregatedGR(t, newDefaultGR(), &v1.AggregationRule{ClusterRoleSelectors: []metav1.LabelSelector{selector}}),
+			aggregated: []*v3.GlobalRole{readPodsGR, adminGR},
+		},
+		{
+			name:       "aggregate rules the user does not have with escalate",
+			newGR:      newAggregatedGR(t, newDefaultGR(), &v1.AggregationRule{ClusterRoleSelectors: []metav1.LabelSelector{selector}}),
+			aggregated: []*v3.GlobalRole{adminGR},
+			escalate:   true,
+			allowed:    true,
+		},
+		{
+			name: "aggregated rules of the role itself are ignored",
+			newGR: func() []byte {
+				gr := newDefaultGR()
+				gr.Labels = map[string]string{aggregateLabel: "true"}
+				return newAggregatedGR(t, gr, &v1.AggregationRule{ClusterRoleSelectors: []metav1.LabelSelector{selector}})
+			}(),
+			aggregated: []*v3.GlobalRole{{ObjectMeta: metav1.ObjectMeta{Name: newDefaultGR().Name}, Rules: []v1.PolicyRule{ruleAdmin}}},
+			allowed:    true,
+		},
+		{
+			name:  "no selectors",
+			newGR: newAggregatedGR(t, newDefaultGR(), &v1.AggregationRule{}),
+		},
+		{
+			name: "invalid selector",
+			newGR: newAggregatedGR(t, newDefaultGR(), &v1.AggregationRule{ClusterRoleSelectors: []metav1.LabelSelector{{
+				MatchExpressions: []metav1.LabelSelectorRequirement{{Key: aggregateLabel, Operator: "Matches"}},
+			}}}),
+		},
+		{
+			name:       "update aggregationRule of builtin role",
+			oldGR:      newAggregatedGR(t, builtinGR(), nil),
+			newGR:      newAggregatedGR(t, builtinGR(), &v1.AggregationRule{ClusterRoleSelectors: []metav1.LabelSelector{selector}}),
+			aggregated: []*v3.GlobalRole{readPodsGR},
+		},
+		{
+			name:    "update builtin role without aggregationRule",
+			oldGR:   newAggregatedGR(t, builtinGR(), nil),
+			newGR:   newAggregatedGR(t, builtinGR(), nil),
+			allowed: true,
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+			state := newDefaultState(t)
+			state.grCacheMock.EXPECT().List(gomock.Any()).DoAndReturn(func(selector labels.Selector) ([]*v3.GlobalRole, error) {
+				var matching []*v3.GlobalRole
+				for _, gr := range test.aggregated {
+					if selector.Matches(labels.Set(gr.Labels)) || gr.Name == newDefaultGR().Name {
+						matching = append(matching, gr)
+					}
+				}
+				return matching, nil
+			}).AnyTimes()
+			setSarResponse(test.escalate, nil, testUser, newDefaultGR().Name, state.sarMock)
+			grResolver := state.createBaseGRResolver()
+			grbResolvers := state.createBaseGRBResolvers(grResolver)
+			admitters := globalrole.NewValidator(state.resolver, grbResolvers, state.sarMock, grResolver, nil).Admitters()
+			require.Len(t, admitters, 1)
+
+			req := createGRRequest(t, testCase{args: args{rawNewGR: test.newGR}})
+			req.Name = newDefaultGR().Name
+			if test.oldGR != nil {
+				req.Operation = admissionv1.Update
+				req.OldObject.Raw = test.oldGR
+			}
+			response, err := admitters[0].Admit(req)
+			require.NoError(t, err)
+			require.Equalf(t, test.allowed, response.Allowed, "unexpected response: %+v", response.Result)
+		})
+	}
+}
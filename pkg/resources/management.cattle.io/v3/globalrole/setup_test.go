@@ -206,7 +206,7 @@ type testState struct {
 // if newGR is nil then a request will be returned as a delete operation.
 // else the request will look like an update operation.
 // if the args.username is empty testUser will be used.
-func createGRRequest(t *testing.T, test testCase) *admission.Request {
+func createGRRequest(t testing.TB, test testCase) *admission.Request {
 	t.Helper()
 	username := test.args.username
 	if username == "" {
@@ -282,7 +282,7 @@ func newDefaultGR() *v3.GlobalRole {
 	}
 }
 
-func newDefaultState(t *testing.T) testState {
+func newDefaultState(t testing.TB) testState {
 	t.Helper()
 	ctrl := gomock.NewController(t)
 	rtCacheMock := fake.NewMockNonNamespacedCacheInterface[*v3.RoleTemplate](ctrl)
@@ -4,7 +4,11 @@ package globalrole
 import (
 	"errors"
 	"fmt"
+	"maps"
+	"os"
 	"reflect"
+	"slices"
+	"strconv"
 
 	v3 "github.com/rancher/rancher/pkg/apis/management.cattle.io/v3"
 	"github.com/rancher/webhook/pkg/admission"
@@ -12,8 +16,10 @@ import (
 	objectsv3 "github.com/rancher/webhook/pkg/generated/objects/management.cattle.io/v3"
 	"github.com/rancher/webhook/pkg/resolvers"
 	"github.com/rancher/webhook/pkg/resources/common"
+	corecontrollers "github.com/rancher/wrangler/v3/pkg/generated/controllers/core/v1"
 	admissionv1 "k8s.io/api/admission/v1"
 	admissionregistrationv1 "k8s.io/api/admissionregistration/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	"k8s.io/apimachinery/pkg/runtime/schema"
 	"k8s.io/apimachinery/pkg/util/validation/field"
@@ -31,16 +37,37 @@ var gvr = schema.GroupVersionResource{
 const (
 	roleTemplateClusterContext = "cluster"
 	escalateVerb               = "escalate"
+
+	// ValidateNamespacesEnv is the environment variable enabling the validation of the namespaces of the
+	// namespacedRules of GlobalRoles against the existing namespaces.
+	ValidateNamespacesEnv = "CATTLE_WEBHOOK_GR_VALIDATE_NAMESPACES"
 )
 
-// NewValidator returns a new validator used for validation globalRoles.
-func NewValidator(ruleResolver validation.AuthorizationRuleResolver, grbResolvers *resolvers.GRBRuleResolvers, sar authorizationv1.SubjectAccessReviewInterface, grResolver *auth.GlobalRoleResolver) *Validator {
+// ValidateNamespacesFromEnv returns true if ValidateNamespacesEnv enables the validation of the namespaces of
+// namespacedRules. It is disabled by default.
+func ValidateNamespacesFromEnv() (bool, error) {
+	value := os.Getenv(ValidateNamespacesEnv)
+	if value == "" {
+		return false, nil
+	}
+	enabled, err := strconv.ParseBool(value)
+	if err != nil {
+		return false, fmt.Errorf("invalid value '%s' for %s: must be a boolean", value, ValidateNamespacesEnv)
+	}
+	return enabled, nil
+}
+
+// NewValidator returns a new validator used for validation globalRoles. The namespaces of namespacedRules are checked
+// against namespaceCache, unless it is nil.
+func NewValidator(ruleResolver validation.AuthorizationRuleResolver, grbResolvers *resolvers.GRBRuleResolvers, sar authorizationv1.SubjectAccessReviewInterface,
+	grResolver *auth.GlobalRoleResolver, namespaceCache corecontrollers.NamespaceCache) *Validator {
 	return &Validator{
 		admitter: admitter{
-			resolver:     ruleResolver,
-			grResolver:   grResolver,
-			grbResolvers: grbResolvers,
-			sar:          sar,
+			resolver:       ruleResolver,
+			grResolver:     grResolver,
+			grbResolvers:   grbResolvers,
+			sar:            sar,
+			namespaceCache: namespaceCache,
 		},
 	}
 }
@@ -71,18 +98,23 @@ func (v *Validator) Admitters() []admission.Admitter {
 }
 
 type admitter struct {
-	resolver     validation.AuthorizationRuleResolver
-	grResolver   *auth.GlobalRoleResolver
-	grbResolvers *resolvers.GRBRuleResolvers
-	sar          authorizationv1.SubjectAccessReviewInterface
+	resolver       validation.AuthorizationRuleResolver
+	grResolver     *auth.GlobalRoleResolver
+	grbResolvers   *resolvers.GRBRuleResolvers
+	sar            authorizationv1.SubjectAccessReviewInterface
+	namespaceCache corecontrollers.NamespaceCache
 }
 
 // Admit is the entrypoint for the validator. Admit will return an error if it's unable to process the request.
 // If this function is called without NewValidator(..) calls will panic.
-func (a *admitter) Admit(request *admission.Request) (*admissionv1.AdmissionResponse, error) {
+func (a *admitter) Admit(request *admission.Request) (response *admissionv1.AdmissionResponse, err error) {
 	listTrace := trace.New("globalRoleValidator Admit", trace.Field{Key: "user", Value: request.UserInfo.Username})
 	defer listTrace.LogIfLong(admission.SlowTraceDuration)
 
+	if response, err := admission.DisallowUnknownFields(request, &aggregatedGlobalRole{}); err != nil || response != nil {
+		return response, err
+	}
+
 	oldGR, newGR, err := objectsv3.GlobalRoleOldAndNewFromRequest(&request.AdmissionRequest)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get GlobalRole from request: %w", err)
@@ -112,6 +144,24 @@ func (a *admitter) Admit(request *admission.Request) (*admissionv1.AdmissionResp
 		return nil, fmt.Errorf("%s operation %v: %w", gvr.Resource, request.Operation, admission.ErrUnsupportedOperation)
 	}
 
+	aggregationRule, err := aggregationRuleFromRaw(request.Object.Raw)
+	if err != nil {
+		return nil, err
+	}
+	aggregationPath := fldPath.Child("aggregationRule")
+	if fieldErrs := validateAggregationRule(aggregationRule, aggregationPath); len(fieldErrs) > 0 {
+		return admission.ResponseBadRequest(fieldErrs.ToAggregate().Error()), nil
+	}
+	if request.Operation == admissionv1.Update {
+		oldAggregationRule, err := aggregationRuleFromRaw(request.OldObject.Raw)
+		if err != nil {
+			return nil, err
+		}
+		if fieldErr := validateBuiltinAggregationRule(oldGR, oldAggregationRule, aggregationRule, aggregationPath); fieldErr != nil {
+			return admission.ResponseBadRequest(fieldErr.Error()), nil
+		}
+	}
+
 	err = a.validateInheritedClusterRoles(oldGR, newGR, fldPath.Child("inheritedClusterRoles"))
 	if err != nil {
 		if errors.As(err, admission.Ptr(new(field.Error))) {
@@ -129,6 +179,20 @@ func (a *admitter) Admit(request *admission.Request) (*admissionv1.AdmissionResp
 		returnError = errors.Join(returnError, common.ValidateRules(rules, true,
 			nsrPath.Child(index)))
 	}
+	warnings, fieldErr, err := a.validateNamespaces(oldGR, newGR, nsrPath)
+	if err != nil {
+		return nil, err
+	}
+	if fieldErr != nil {
+		returnError = errors.Join(returnError, fieldErr)
+	}
+	if len(warnings) > 0 {
+		defer func() {
+			if response != nil && response.Allowed {
+				response.Warnings = append(response.Warnings, warnings...)
+			}
+		}()
+	}
 	// Validate fleet workspace rules
 	if newGR.InheritedFleetWorkspacePermissions != nil && newGR.InheritedFleetWorkspacePermissions.ResourceRules != nil {
 		fleetWorkspaceRules := newGR.InheritedFleetWorkspacePermissions.ResourceRules
@@ -152,28 +216,28 @@ func (a *admitter) Admit(request *admission.Request) (*admissionv1.AdmissionResp
 	if err != nil {
 		return nil, fmt.Errorf("unable to resolve rules for new global role: %w", err)
 	}
+	aggregatedRules, err := a.aggregatedRules(newGR.Name, aggregationRule)
+	if err != nil {
+		return nil, err
+	}
 	fwResourceRules := a.grResolver.FleetWorkspacePermissionsResourceRulesFromRole(newGR)
 	fwWorkspaceVerbsRules := a.grResolver.FleetWorkspacePermissionsWorkspaceVerbsFromRole(newGR)
 
+	// GlobalRoles synced from external systems can have thousands of overlapping rules, so the rules are reduced to
+	// their canonical set before checking that the user has them. The global rules and the rules of the aggregated
+	// GlobalRoles are checked together, as they are both covered by the global rules of the user.
 	escalateChecker := common.NewCachedVerbChecker(request, newGR.Name, a.sar, gvr, escalateVerb)
-	returnError = errors.Join(returnError, escalateChecker.IsRulesAllowed(clusterRules, a.grbResolvers.ICRResolver, ""))
-	if escalateChecker.HasVerb() {
-		return admission.ResponseAllowed(), nil
-	}
-	returnError = errors.Join(returnError, escalateChecker.IsRulesAllowed(globalRules, a.resolver, ""))
-	if escalateChecker.HasVerb() {
-		return admission.ResponseAllowed(), nil
-	}
-	returnError = errors.Join(returnError, escalateChecker.IsRulesAllowed(fwResourceRules, a.grbResolvers.FWRulesResolver, ""))
-	if escalateChecker.HasVerb() {
-		return admission.ResponseAllowed(), nil
+	checks := []escalationCheck{
+		{rules: clusterRules, resolver: a.grbResolvers.ICRResolver},
+		{rules: slices.Concat(globalRules, aggregatedRules), resolver: a.resolver},
+		{rules: fwResourceRules, resolver: a.grbResolvers.FWRulesResolver},
+		{rules: fwWorkspaceVerbsRules, resolver: a.grbResolvers.FWVerbsResolver},
 	}
-	returnError = errors.Join(returnError, escalateChecker.IsRulesAllowed(fwWorkspaceVerbsRules, a.grbResolvers.FWVerbsResolver, ""))
-	if escalateChecker.HasVerb() {
-		return admission.ResponseAllowed(), nil
+	for _, namespace := range slices.Sorted(maps.Keys(newGR.NamespacedRules)) {
+		checks = append(checks, escalationCheck{rules: newGR.NamespacedRules[namespace], resolver: a.resolver, namespace: namespace})
 	}
-	for namespace, rules := range newGR.NamespacedRules {
-		returnError = errors.Join(returnError, escalateChecker.IsRulesAllowed(rules, a.resolver, namespace))
+	for _, check := range checks {
+		returnError = errors.Join(returnError, escalateChecker.IsRulesAllowed(auth.CanonicalRules(check.rules), check.resolver, check.namespace))
 		if escalateChecker.HasVerb() {
 			return admission.ResponseAllowed(), nil
 		}
@@ -185,6 +249,47 @@ func (a *admitter) Admit(request *admission.Request) (*admissionv1.AdmissionResp
 	return admission.ResponseAllowed(), nil
 }
 
+// escalationCheck is a set of rules of a GlobalRole which the user must have according to resolver in namespace.
+type escalationCheck struct {
+	rules     []rbacv1.PolicyRule
+	resolver  validation.AuthorizationRuleResolver
+	namespace string
+}
+
+// validateNamespaces checks that the namespaces of the namespacedRules of the new GlobalRole exist. Unknown namespaces
+// are denied, unless they were already in the old GlobalRole: the namespace was deleted after the GlobalRole was
+// created, which shouldn't prevent updating the GlobalRole, so a warning is returned instead.
+func (a *admitter) validateNamespaces(oldGR, newGR *v3.GlobalRole, fldPath *field.Path) ([]string, *field.Error, error) {
+	if a.namespaceCache == nil || len(newGR.NamespacedRules) == 0 {
+		return nil, nil, nil
+	}
+	namespaces := make([]string, 0, len(newGR.NamespacedRules))
+	for namespace := range newGR.NamespacedRules {
+		namespaces = append(namespaces, namespace)
+	}
+	slices.Sort(namespaces)
+
+	var warnings, unknown []string
+	for _, namespace := range namespaces {
+		_, err := a.namespaceCache.Get(namespace)
+		if err == nil {
+			continue
+		}
+		if !apierrors.IsNotFound(err) {
+			return nil, nil, fmt.Errorf("failed to get namespace %s: %w", namespace, err)
+		}
+		if _, existed := oldGR.NamespacedRules[namespace]; existed {
+			warnings = append(warnings, fmt.Sprintf("namespace %s of the namespacedRules of GlobalRole %s no longer exists", namespace, newGR.Name))
+			continue
+		}
+		unknown = append(unknown, namespace)
+	}
+	if len(unknown) > 0 {
+		return warnings, field.NotFound(fldPath, unknown), nil
+	}
+	return warnings, nil, nil
+}
+
 // validateDelete checks if a global role can be deleted and returns the appropriate response.
 func validateDelete(oldRole *v3.GlobalRole, fldPath *field.Path) (*admissionv1.AdmissionResponse, error) {
 	if oldRole.Builtin {
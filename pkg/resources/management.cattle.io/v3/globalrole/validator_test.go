@@ -7,10 +7,13 @@ import (
 
 	v3 "github.com/rancher/rancher/pkg/apis/management.cattle.io/v3"
 	"github.com/rancher/webhook/pkg/resources/management.cattle.io/v3/globalrole"
+	"github.com/rancher/wrangler/v3/pkg/generic/fake"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
 	admissionv1 "k8s.io/api/admission/v1"
 	authorizationv1 "k8s.io/api/authorization/v1"
+	corev1 "k8s.io/api/core/v1"
 	v1 "k8s.io/api/rbac/v1"
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
@@ -882,7 +885,7 @@ func TestAdmit(t *testing.T) {
 			}
 			grResolver := state.createBaseGRResolver()
 			grbResolvers := state.createBaseGRBResolvers(grResolver)
-			admitters := globalrole.NewValidator(state.resolver, grbResolvers, state.sarMock, grResolver).Admitters()
+			admitters := globalrole.NewValidator(state.resolver, grbResolvers, state.sarMock, grResolver, nil).Admitters()
 			assert.Len(t, admitters, 1)
 
 			req := createGRRequest(t, test)
@@ -897,10 +900,88 @@ func TestAdmit(t *testing.T) {
 	}
 }
 
+func TestAdmitNamespacedRulesNamespaces(t *testing.T) {
+	t.Parallel()
+	withNamespaces := func(namespaces ...string) func() *v3.GlobalRole {
+		return func() *v3.GlobalRole {
+			gr := newDefaultGR()
+			gr.NamespacedRules = map[string][]v1.PolicyRule{}
+			for _, namespace := range namespaces {
+				gr.NamespacedRules[namespace] = []v1.PolicyRule{ruleReadPods}
+			}
+			return gr
+		}
+	}
+	tests := []struct {
+		name         string
+		oldGR        func() *v3.GlobalRole
+		newGR        func() *v3.GlobalRole
+		disabled     bool
+		allowed      bool
+		wantWarnings []string
+	}{
+		{
+			name:    "create with existing namespaces",
+			newGR:   withNamespaces("existing"),
+			allowed: true,
+		},
+		{
+			name:  "create with an unknown namespace",
+			newGR: withNamespaces("existing", "unknown"),
+		},
+		{
+			name:     "create with an unknown namespace while the validation is disabled",
+			newGR:    withNamespaces("unknown"),
+			disabled: true,
+			allowed:  true,
+		},
+		{
+			name:  "update adding an unknown namespace",
+			oldGR: withNamespaces("existing"),
+			newGR: withNamespaces("existing", "unknown"),
+		},
+		{
+			name:         "update keeping a deleted namespace",
+			oldGR:        withNamespaces("existing", "deleted"),
+			newGR:        withNamespaces("existing", "deleted"),
+			allowed:      true,
+			wantWarnings: []string{"namespace deleted of the namespacedRules of GlobalRole gr-new no longer exists"},
+		},
+	}
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+			state := newDefaultState(t)
+			setSarResponse(true, nil, testUser, newDefaultGR().Name, state.sarMock)
+			namespaceCache := fake.NewMockNonNamespacedCacheInterface[*corev1.Namespace](gomock.NewController(t))
+			namespaceCache.EXPECT().Get(gomock.Any()).DoAndReturn(func(name string) (*corev1.Namespace, error) {
+				if name == "existing" {
+					return &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: name}}, nil
+				}
+				return nil, apierrors.NewNotFound(schema.GroupResource{Resource: "namespaces"}, name)
+			}).AnyTimes()
+			grResolver := state.createBaseGRResolver()
+			validator := globalrole.NewValidator(state.resolver, state.createBaseGRBResolvers(grResolver), state.sarMock, grResolver, namespaceCache)
+			if test.disabled {
+				validator = globalrole.NewValidator(state.resolver, state.createBaseGRBResolvers(grResolver), state.sarMock, grResolver, nil)
+			}
+
+			response, err := validator.Admitters()[0].Admit(createGRRequest(t, testCase{args: args{oldGR: test.oldGR, newGR: test.newGR}}))
+			require.NoError(t, err)
+			require.Equal(t, test.allowed, response.Allowed, "response: %+v", response.Result)
+			assert.Equal(t, test.wantWarnings, response.Warnings)
+			if !test.allowed {
+				assert.Contains(t, response.Result.Message, `"unknown"`)
+			}
+		})
+	}
+}
+
 func Test_UnexpectedErrors(t *testing.T) {
 	t.Parallel()
 	resolver, _ := validation.NewTestRuleResolver(nil, nil, nil, nil)
-	validator := globalrole.NewValidator(resolver, nil, nil, nil)
+	validator := globalrole.NewValidator(resolver, nil, nil, nil, nil)
 	admitters := validator.Admitters()
 	require.Len(t, admitters, 1, "wanted only one admitter")
 	test := testCase{
@@ -936,3 +1017,33 @@ func setSarResponse(allowed bool, testErr error, targetUser string, targetGrName
 		return false, nil, nil
 	})
 }
+
+func BenchmarkAdmitLargeGlobalRole(b *testing.B) {
+	verbs := [][]string{{"get", "list", "watch"}, {"get"}, {"create", "update", "patch", "delete"}}
+	rules := make([]v1.PolicyRule, 0, 5000)
+	for i := 0; i < 5000; i++ {
+		rules = append(rules, v1.PolicyRule{
+			APIGroups: []string{fmt.Sprintf("group%d.example.com", i%10)},
+			Resources: []string{fmt.Sprintf("resource%d", i%300), fmt.Sprintf("resource%d/status", i%300)},
+			Verbs:     verbs[i%len(verbs)],
+		})
+	}
+	state := newDefaultState(b)
+	grResolver := state.createBaseGRResolver()
+	admitter := globalrole.NewValidator(state.resolver, state.createBaseGRBResolvers(grResolver), state.sarMock, grResolver, nil).Admitters()[0]
+	request := createGRRequest(b, testCase{args: args{
+		username: adminUser,
+		newGR: func() *v3.GlobalRole {
+			gr := newDefaultGR()
+			gr.Rules = rules
+			return gr
+		},
+	}})
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		response, err := admitter.Admit(request)
+		require.NoError(b, err)
+		require.True(b, response.Allowed)
+	}
+}
@@ -9,6 +9,7 @@ import (
 	v3 "github.com/rancher/webhook/pkg/generated/controllers/management.cattle.io/v3"
 	objectsv3 "github.com/rancher/webhook/pkg/generated/objects/management.cattle.io/v3"
 	"github.com/rancher/webhook/pkg/patch"
+	"github.com/rancher/webhook/pkg/resources/common"
 	admissionv1 "k8s.io/api/admission/v1"
 	admissionregistrationv1 "k8s.io/api/admissionregistration/v1"
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
@@ -21,12 +22,15 @@ import (
 // Mutator implements admission.MutatingAdmissionWebhook.
 type Mutator struct {
 	globalRoles v3.GlobalRoleCache
+	authConfigs v3.AuthConfigCache
 }
 
-// NewMutator returns a new mutator for GlobalRoleBindings.
-func NewMutator(grCache v3.GlobalRoleCache) *Mutator {
+// NewMutator returns a new mutator for GlobalRoleBindings. The group principal names of the bindings are normalized
+// with the AuthConfigs of the authConfigCache.
+func NewMutator(grCache v3.GlobalRoleCache, authConfigCache v3.AuthConfigCache) *Mutator {
 	return &Mutator{
 		globalRoles: grCache,
+		authConfigs: authConfigCache,
 	}
 }
 
@@ -65,6 +69,13 @@ func (m *Mutator) Admit(request *admission.Request) (*admissionv1.AdmissionRespo
 		return nil, fmt.Errorf("failed to add owner reference: %w", err)
 	}
 
+	if newGRB.GroupPrincipalName != "" {
+		newGRB.GroupPrincipalName, err = common.NormalizeGroupPrincipalName(m.authConfigs, newGRB.GroupPrincipalName)
+		if err != nil {
+			return nil, fmt.Errorf("failed to normalize group principal name: %w", err)
+		}
+	}
+
 	response := &admissionv1.AdmissionResponse{}
 	if err := patch.CreatePatch(request.Object.Raw, newGRB, response); err != nil {
 		return nil, fmt.Errorf("failed to create patch: %w", err)
@@ -24,7 +24,13 @@ func Test_MutatorAdmit(t *testing.T) {
 	globalRoleCache.EXPECT().Get(notFoundName).Return(nil, newNotFound(notFoundName)).AnyTimes()
 	globalRoleCache.EXPECT().Get(errName).Return(nil, errServer).AnyTimes()
 
-	validator := globalrolebinding.NewMutator(globalRoleCache)
+	authConfigCache := fake.NewMockNonNamespacedCacheInterface[*apisv3.AuthConfig](ctrl)
+	authConfigCache.EXPECT().List(gomock.Any()).Return([]*apisv3.AuthConfig{
+		{ObjectMeta: metav1.ObjectMeta{Name: "activedirectory"}},
+		{ObjectMeta: metav1.ObjectMeta{Name: "okta"}},
+	}, nil).AnyTimes()
+
+	validator := globalrolebinding.NewMutator(globalRoleCache, authConfigCache)
 
 	tests := []testCase{
 		{
@@ -335,6 +341,60 @@ func Test_MutatorAdmit(t *testing.T) {
 			},
 			allowed: true,
 		},
+		{
+			name: "group principal name with a non-canonical prefix",
+			args: args{
+				username: adminUser,
+				newGRB: func() *apisv3.GlobalRoleBinding {
+					baseGRB := newDefaultGRB()
+					baseGRB.UserName = ""
+					baseGRB.GroupPrincipalName = "ActiveDirectory_Group://CN=Admins,DC=example,DC=com"
+					return baseGRB
+				},
+			},
+			wantGRB: func() *apisv3.GlobalRoleBinding {
+				baseGRB := newDefaultGRB()
+				baseGRB.UserName = ""
+				baseGRB.GroupPrincipalName = "activedirectory_group://CN=Admins,DC=example,DC=com"
+				baseGRB.OwnerReferences = []metav1.OwnerReference{
+					{
+						APIVersion: adminGR.APIVersion,
+						Kind:       adminGR.Kind,
+						Name:       adminGR.Name,
+						UID:        adminGR.UID,
+					},
+				}
+				return baseGRB
+			},
+			allowed: true,
+		},
+		{
+			name: "group principal name of an unknown provider",
+			args: args{
+				username: adminUser,
+				newGRB: func() *apisv3.GlobalRoleBinding {
+					baseGRB := newDefaultGRB()
+					baseGRB.UserName = ""
+					baseGRB.GroupPrincipalName = "GitHub_Team://1234"
+					return baseGRB
+				},
+			},
+			wantGRB: func() *apisv3.GlobalRoleBinding {
+				baseGRB := newDefaultGRB()
+				baseGRB.UserName = ""
+				baseGRB.GroupPrincipalName = "GitHub_Team://1234"
+				baseGRB.OwnerReferences = []metav1.OwnerReference{
+					{
+						APIVersion: adminGR.APIVersion,
+						Kind:       adminGR.Kind,
+						Name:       adminGR.Name,
+						UID:        adminGR.UID,
+					},
+				}
+				return baseGRB
+			},
+			allowed: true,
+		},
 	}
 
 	for i := range tests {
@@ -4,7 +4,9 @@ package globalrolebinding
 import (
 	"errors"
 	"fmt"
+	"os"
 	"strings"
+	"time"
 
 	v3 "github.com/rancher/rancher/pkg/apis/management.cattle.io/v3"
 	"github.com/rancher/webhook/pkg/admission"
@@ -35,17 +37,45 @@ var (
 	}
 )
 
-const bindVerb = "bind"
+const (
+	bindVerb     = "bind"
+	escalateVerb = "escalate"
 
-// NewValidator returns a new validator for GlobalRoleBindings.
+	// ExpiresAtAnn is the annotation holding the RFC3339 time after which a GlobalRoleBinding is expired.
+	ExpiresAtAnn = "authz.management.cattle.io/expires-at"
+
+	// MaxExpirationEnv is the environment variable used to configure how far in the future the expiration of a
+	// GlobalRoleBinding can be set.
+	MaxExpirationEnv = "CATTLE_WEBHOOK_GRB_MAX_EXPIRATION"
+
+	defaultMaxExpiration = 90 * 24 * time.Hour
+)
+
+// MaxExpirationFromEnv returns the value of MaxExpirationEnv, falling back to the default if it is unset.
+func MaxExpirationFromEnv() (time.Duration, error) {
+	value := os.Getenv(MaxExpirationEnv)
+	if value == "" {
+		return defaultMaxExpiration, nil
+	}
+	parsed, err := time.ParseDuration(value)
+	if err != nil || parsed <= 0 {
+		return 0, fmt.Errorf("invalid value '%s' for %s: must be a positive duration", value, MaxExpirationEnv)
+	}
+	return parsed, nil
+}
+
+// NewValidator returns a new validator for GlobalRoleBindings which allows expirations at most maxExpiration in the
+// future.
 func NewValidator(resolver rbacvalidation.AuthorizationRuleResolver, grbResolvers *resolvers.GRBRuleResolvers,
-	sar authorizationv1.SubjectAccessReviewInterface, grResolver *auth.GlobalRoleResolver) *Validator {
+	sar authorizationv1.SubjectAccessReviewInterface, grResolver *auth.GlobalRoleResolver, maxExpiration time.Duration) *Validator {
 	return &Validator{
 		admitter: admitter{
-			resolver:     resolver,
-			grbResolvers: grbResolvers,
-			sar:          sar,
-			grResolver:   grResolver,
+			resolver:      resolver,
+			grbResolvers:  grbResolvers,
+			sar:           sar,
+			grResolver:    grResolver,
+			maxExpiration: maxExpiration,
+			now:           time.Now,
 		},
 	}
 }
@@ -80,6 +110,9 @@ type admitter struct {
 	grbResolvers *resolvers.GRBRuleResolvers
 	grResolver   *auth.GlobalRoleResolver
 	sar          authorizationv1.SubjectAccessReviewInterface
+	// maxExpiration is how far in the future the expiration of a binding can be set.
+	maxExpiration time.Duration
+	now           func() time.Time
 }
 
 // Admit handles the webhook admission request sent to this webhook.
@@ -87,6 +120,10 @@ func (a *admitter) Admit(request *admission.Request) (*admissionv1.AdmissionResp
 	listTrace := trace.New("globalRoleBindingValidator Admit", trace.Field{Key: "user", Value: request.UserInfo.Username})
 	defer listTrace.LogIfLong(admission.SlowTraceDuration)
 
+	if response, err := admission.DisallowUnknownFields(request, &v3.GlobalRoleBinding{}); err != nil || response != nil {
+		return response, err
+	}
+
 	oldGRB, newGRB, err := objectsv3.GlobalRoleBindingOldAndNewFromRequest(&request.AdmissionRequest)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get %s from request: %w", gvr.Resource, err)
@@ -111,8 +148,14 @@ func (a *admitter) Admit(request *admission.Request) (*admissionv1.AdmissionResp
 	switch request.Operation {
 	case admissionv1.Update:
 		err = validateUpdateFields(oldGRB, newGRB, fldPath)
+		if err == nil && oldGRB.Annotations[ExpiresAtAnn] != newGRB.Annotations[ExpiresAtAnn] {
+			err = a.validateExpiration(newGRB, fldPath)
+		}
 	case admissionv1.Create:
 		err = a.validateCreate(newGRB, globalRole, fldPath)
+		if err == nil {
+			err = a.validateExpiration(newGRB, fldPath)
+		}
 	default:
 		return nil, fmt.Errorf("%s operation %v: %w", gvr.Resource, request.Operation, admission.ErrUnsupportedOperation)
 	}
@@ -124,6 +167,16 @@ func (a *admitter) Admit(request *admission.Request) (*admissionv1.AdmissionResp
 		return nil, err
 	}
 
+	if request.Operation == admissionv1.Update && extendsExpiration(oldGRB, newGRB) {
+		canEscalate, err := auth.RequestUserHasVerb(request, gvr, a.sar, escalateVerb, newGRB.Name, "")
+		if err != nil {
+			return nil, fmt.Errorf("failed to check for the %s verb on %s: %w", escalateVerb, gvr.Resource, err)
+		}
+		if !canEscalate {
+			return admission.ResponseFailedEscalation(fmt.Sprintf("extending the expiration of a GlobalRoleBinding requires the %s verb", escalateVerb)), nil
+		}
+	}
+
 	fwResourceRules := a.grResolver.FleetWorkspacePermissionsResourceRulesFromRole(globalRole)
 	fwWorkspaceVerbsRules := a.grResolver.FleetWorkspacePermissionsWorkspaceVerbsFromRole(globalRole)
 	globalRules := a.grResolver.GlobalRulesFromRole(globalRole)
@@ -170,20 +223,19 @@ func (a *admitter) Admit(request *admission.Request) (*admissionv1.AdmissionResp
 	return admission.ResponseAllowed(), nil
 }
 
+// immutableFields are the fields of GlobalRoleBindings which can't be updated.
+var immutableFields = common.NewImmutableFields(
+	common.ImmutableField{Path: "userName"},
+	common.ImmutableField{Path: "groupPrincipalName"},
+	common.ImmutableField{Path: "globalRoleName"},
+)
+
 // validUpdateFields checks if the fields being changed are valid update fields.
 func validateUpdateFields(oldBinding, newBinding *v3.GlobalRoleBinding, fldPath *field.Path) error {
-	var err error
-	const immutable = "field is immutable"
-	switch {
-	case newBinding.UserName != oldBinding.UserName:
-		err = field.Invalid(fldPath.Child("userName"), newBinding.UserName, immutable)
-	case newBinding.GroupPrincipalName != oldBinding.GroupPrincipalName:
-		err = field.Invalid(fldPath.Child("groupPrincipalName"), newBinding.GroupPrincipalName, immutable)
-	case newBinding.GlobalRoleName != oldBinding.GlobalRoleName:
-		err = field.Invalid(fldPath.Child("globalRoleName"), newBinding.GlobalRoleName, immutable)
+	if errs := immutableFields.Validate(fldPath, oldBinding, newBinding); len(errs) > 0 {
+		return errs[0]
 	}
-
-	return err
+	return nil
 }
 
 // validateCreateFields checks if all required fields are present and valid.
@@ -221,3 +273,40 @@ func (a *admitter) validateGlobalRole(globalRole *v3.GlobalRole, fieldPath *fiel
 	}
 	return nil
 }
+
+// validateExpiration checks that the expiration of the binding, if any, is a valid time in the future, no further than
+// the configured maximum.
+func (a *admitter) validateExpiration(binding *v3.GlobalRoleBinding, fldPath *field.Path) error {
+	value, ok := binding.Annotations[ExpiresAtAnn]
+	if !ok {
+		return nil
+	}
+	annPath := fldPath.Child("metadata", "annotations").Key(ExpiresAtAnn)
+	expiresAt, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return field.Invalid(annPath, value, "must be an RFC3339 time")
+	}
+	now := a.now()
+	if !expiresAt.After(now) {
+		return field.Invalid(annPath, value, "must be in the future")
+	}
+	if expiresAt.After(now.Add(a.maxExpiration)) {
+		return field.Invalid(annPath, value, fmt.Sprintf("must be at most %s in the future", a.maxExpiration))
+	}
+	return nil
+}
+
+// extendsExpiration returns true if the new binding expires later than the old binding, or no longer expires.
+func extendsExpiration(oldBinding, newBinding *v3.GlobalRoleBinding) bool {
+	oldExpiresAt, err := time.Parse(time.RFC3339, oldBinding.Annotations[ExpiresAtAnn])
+	if err != nil {
+		// the old binding didn't expire, so there is nothing to extend
+		return false
+	}
+	value, ok := newBinding.Annotations[ExpiresAtAnn]
+	if !ok {
+		return true
+	}
+	newExpiresAt, err := time.Parse(time.RFC3339, value)
+	return err == nil && newExpiresAt.After(oldExpiresAt)
+}
@@ -796,7 +796,7 @@ func TestAdmit(t *testing.T) {
 			}
 			grResolver := auth.NewGlobalRoleResolver(auth.NewRoleTemplateResolver(state.rtCacheMock, nil), state.grCacheMock)
 			gbrResolvers := resolvers.NewGRBRuleResolvers(state.grbCacheMock, grResolver)
-			admitters := globalrolebinding.NewValidator(state.resolver, gbrResolvers, state.sarMock, grResolver).Admitters()
+			admitters := globalrolebinding.NewValidator(state.resolver, gbrResolvers, state.sarMock, grResolver, time.Hour).Admitters()
 			require.Len(t, admitters, 1)
 
 			req := createGRBRequest(t, test)
@@ -812,12 +812,167 @@ func TestAdmit(t *testing.T) {
 	}
 }
 
+func TestAdmitExpiration(t *testing.T) {
+	t.Parallel()
+	now := time.Now()
+	expiresIn := func(d time.Duration) string {
+		return now.Add(d).UTC().Format(time.RFC3339)
+	}
+	grbExpiringIn := func(d time.Duration) func() *v3.GlobalRoleBinding {
+		return func() *v3.GlobalRoleBinding {
+			grb := newDefaultGRB()
+			grb.GlobalRoleName = baseGR.Name
+			grb.Annotations = map[string]string{globalrolebinding.ExpiresAtAnn: expiresIn(d)}
+			return grb
+		}
+	}
+	grbWithExpiration := func(value string) func() *v3.GlobalRoleBinding {
+		return func() *v3.GlobalRoleBinding {
+			grb := newDefaultGRB()
+			grb.GlobalRoleName = baseGR.Name
+			grb.Annotations = map[string]string{globalrolebinding.ExpiresAtAnn: value}
+			return grb
+		}
+	}
+	withoutExpiration := func() *v3.GlobalRoleBinding {
+		grb := newDefaultGRB()
+		grb.GlobalRoleName = baseGR.Name
+		return grb
+	}
+
+	tests := []testCase{
+		{
+			name:    "create without expiration",
+			args:    args{newGRB: withoutExpiration},
+			allowed: true,
+		},
+		{
+			name:    "create with valid expiration",
+			args:    args{newGRB: grbExpiringIn(30 * time.Minute)},
+			allowed: true,
+		},
+		{
+			name: "create with invalid expiration",
+			args: args{newGRB: grbWithExpiration("tomorrow")},
+		},
+		{
+			name: "create with past expiration",
+			args: args{newGRB: grbExpiringIn(-time.Minute)},
+		},
+		{
+			name: "create with expiration above the max",
+			args: args{newGRB: grbExpiringIn(2 * time.Hour)},
+		},
+		{
+			name:    "update shortening the expiration",
+			args:    args{oldGRB: grbExpiringIn(50 * time.Minute), newGRB: grbExpiringIn(10 * time.Minute)},
+			allowed: true,
+		},
+		{
+			name:    "update adding an expiration",
+			args:    args{oldGRB: withoutExpiration, newGRB: grbExpiringIn(10 * time.Minute)},
+			allowed: true,
+		},
+		{
+			name:    "update keeping an elapsed expiration",
+			args:    args{oldGRB: grbExpiringIn(-time.Hour), newGRB: grbExpiringIn(-time.Hour)},
+			allowed: true,
+		},
+		{
+			name: "update extending the expiration without escalate",
+			args: args{
+				oldGRB: grbExpiringIn(10 * time.Minute),
+				newGRB: grbExpiringIn(50 * time.Minute),
+				stateSetup: func(ts testState) {
+					setEscalateSarResponse(false, adminUser, ts.sarMock)
+				},
+			},
+		},
+		{
+			name: "update removing the expiration without escalate",
+			args: args{
+				oldGRB: grbExpiringIn(10 * time.Minute),
+				newGRB: withoutExpiration,
+				stateSetup: func(ts testState) {
+					setEscalateSarResponse(false, adminUser, ts.sarMock)
+				},
+			},
+		},
+		{
+			name: "update extending the expiration with escalate",
+			args: args{
+				oldGRB: grbExpiringIn(10 * time.Minute),
+				newGRB: grbExpiringIn(50 * time.Minute),
+				stateSetup: func(ts testState) {
+					setEscalateSarResponse(true, adminUser, ts.sarMock)
+				},
+			},
+			allowed: true,
+		},
+		{
+			name: "update extending the expiration above the max",
+			args: args{
+				oldGRB: grbExpiringIn(10 * time.Minute),
+				newGRB: grbExpiringIn(2 * time.Hour),
+				stateSetup: func(ts testState) {
+					setEscalateSarResponse(true, adminUser, ts.sarMock)
+				},
+			},
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+			state := newDefaultState(t)
+			if test.args.stateSetup != nil {
+				test.args.stateSetup(state)
+			}
+			test.args.username = adminUser
+			grResolver := auth.NewGlobalRoleResolver(auth.NewRoleTemplateResolver(state.rtCacheMock, nil), state.grCacheMock)
+			gbrResolvers := resolvers.NewGRBRuleResolvers(state.grbCacheMock, grResolver)
+			admitters := globalrolebinding.NewValidator(state.resolver, gbrResolvers, state.sarMock, grResolver, time.Hour).Admitters()
+			require.Len(t, admitters, 1)
+
+			response, err := admitters[0].Admit(createGRBRequest(t, test))
+			require.NoError(t, err)
+			require.Equalf(t, test.allowed, response.Allowed, "Response was incorrectly validated wanted response.Allowed = '%v' got '%v' message=%+v", test.allowed, response.Allowed, response.Result)
+		})
+	}
+}
+
+func TestMaxExpirationFromEnv(t *testing.T) {
+	tests := []struct {
+		value   string
+		want    time.Duration
+		wantErr bool
+	}{
+		{value: "", want: 90 * 24 * time.Hour},
+		{value: "8h", want: 8 * time.Hour},
+		{value: "0s", wantErr: true},
+		{value: "week", wantErr: true},
+	}
+	for _, test := range tests {
+		t.Run(test.value, func(t *testing.T) {
+			t.Setenv(globalrolebinding.MaxExpirationEnv, test.value)
+			got, err := globalrolebinding.MaxExpirationFromEnv()
+			if test.wantErr {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, test.want, got)
+		})
+	}
+}
+
 func Test_UnexpectedErrors(t *testing.T) {
 	t.Parallel()
 	state := newDefaultState(t)
 	grResolver := auth.NewGlobalRoleResolver(auth.NewRoleTemplateResolver(state.rtCacheMock, nil), state.grCacheMock)
 	gbrResolvers := resolvers.NewGRBRuleResolvers(state.grbCacheMock, grResolver)
-	validator := globalrolebinding.NewValidator(state.resolver, gbrResolvers, state.sarMock, grResolver)
+	validator := globalrolebinding.NewValidator(state.resolver, gbrResolvers, state.sarMock, grResolver, time.Hour)
 	admitters := validator.Admitters()
 	require.Len(t, admitters, 1, "wanted only one admitter")
 	admitter := admitters[0]
@@ -855,3 +1010,15 @@ func setSarResponse(allowed bool, testErr error, targetUser string, targetGrName
 		return false, nil, nil
 	})
 }
+
+func setEscalateSarResponse(allowed bool, targetUser string, sarMock *k8fake.FakeSubjectAccessReviews) {
+	sarMock.Fake.AddReactor("create", "subjectaccessreviews", func(action k8testing.Action) (handled bool, ret runtime.Object, err error) {
+		review := action.(k8testing.CreateActionImpl).GetObject().(*authorizationv1.SubjectAccessReview)
+		spec := review.Spec
+		if spec.User == targetUser && spec.ResourceAttributes.Verb == "escalate" && spec.ResourceAttributes.Resource == "globalrolebindings" {
+			review.Status.Allowed = allowed
+			return true, review, nil
+		}
+		return false, nil, nil
+	})
+}
@@ -0,0 +1,126 @@
+// Package legacyresource is used for locking down deprecated management.cattle.io resources.
+package legacyresource
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+
+	"github.com/rancher/webhook/pkg/admission"
+	controllerv3 "github.com/rancher/webhook/pkg/generated/controllers/management.cattle.io/v3"
+	"github.com/rancher/webhook/pkg/resources/common"
+	"github.com/sirupsen/logrus"
+	admissionv1 "k8s.io/api/admission/v1"
+	admissionregistrationv1 "k8s.io/api/admissionregistration/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/utils/trace"
+)
+
+// NewValidators returns a Validator for each of the common.LegacyResources, sorted by resource. Whether a resource is
+// locked down is read from the legacy-resource-lockdown setting on each request.
+func NewValidators(settingCache controllerv3.SettingCache) []admission.ValidatingAdmissionHandler {
+	resources := make([]string, 0, len(common.LegacyResources))
+	for resource := range common.LegacyResources {
+		resources = append(resources, resource)
+	}
+	sort.Strings(resources)
+
+	validators := make([]admission.ValidatingAdmissionHandler, 0, len(resources))
+	for _, resource := range resources {
+		validators = append(validators, NewValidator(resource, settingCache))
+	}
+	return validators
+}
+
+// NewValidator returns a new Validator for the given legacy resource.
+func NewValidator(resource string, settingCache controllerv3.SettingCache) *Validator {
+	return &Validator{
+		gvr: schema.GroupVersionResource{Group: "management.cattle.io", Version: "v3", Resource: resource},
+		admitter: admitter{
+			resource:     resource,
+			replacement:  common.LegacyResources[resource],
+			settingCache: settingCache,
+		},
+	}
+}
+
+// Validator denies the creation and update of a locked down legacy resource.
+type Validator struct {
+	gvr      schema.GroupVersionResource
+	admitter admitter
+}
+
+// GVR returns the GroupVersionResource for this CRD.
+func (v *Validator) GVR() schema.GroupVersionResource {
+	return v.gvr
+}
+
+// Operations returns list of operations handled by this validator.
+func (v *Validator) Operations() []admissionregistrationv1.OperationType {
+	return []admissionregistrationv1.OperationType{admissionregistrationv1.Create, admissionregistrationv1.Update}
+}
+
+// ValidatingWebhook returns the ValidatingWebhook used for this CRD.
+func (v *Validator) ValidatingWebhook(clientConfig admissionregistrationv1.WebhookClientConfig) []admissionregistrationv1.ValidatingWebhook {
+	return []admissionregistrationv1.ValidatingWebhook{*admission.NewDefaultValidatingWebhook(v, clientConfig, admissionregistrationv1.AllScopes, v.Operations())}
+}
+
+// Admitters returns the admitter objects used to validate the legacy resource.
+func (v *Validator) Admitters() []admission.Admitter {
+	return []admission.Admitter{&v.admitter}
+}
+
+type admitter struct {
+	resource     string
+	replacement  string
+	settingCache controllerv3.SettingCache
+}
+
+// Admit handles the webhook admission request sent to this webhook.
+func (a *admitter) Admit(request *admission.Request) (*admissionv1.AdmissionResponse, error) {
+	listTrace := trace.New("legacyResourceValidator Admit", trace.Field{Key: "user", Value: request.UserInfo.Username})
+	defer listTrace.LogIfLong(admission.SlowTraceDuration)
+
+	// Rancher's controllers still clean up legacy objects
+	if admission.IsController(request) {
+		return admission.ResponseAllowed(), nil
+	}
+
+	locked, err := a.isLocked()
+	if err != nil {
+		return nil, err
+	}
+	if !locked {
+		return admission.ResponseAllowed(), nil
+	}
+
+	if request.Operation == admissionv1.Update {
+		obj := &metav1.PartialObjectMetadata{}
+		if err := json.Unmarshal(request.Object.Raw, obj); err != nil {
+			return nil, fmt.Errorf("failed to decode object metadata from request: %w", err)
+		}
+		// removing the finalizers of a deleted object must remain possible
+		if obj.DeletionTimestamp != nil {
+			return admission.ResponseAllowed(), nil
+		}
+	}
+
+	return admission.ResponseBadRequest(fmt.Sprintf("%s are deprecated and locked down by the %s setting, use %s instead",
+		a.resource, common.LegacyResourceLockdownSetting, a.replacement)), nil
+}
+
+// isLocked returns true if the resource is locked down by the legacy-resource-lockdown setting.
+func (a *admitter) isLocked() (bool, error) {
+	value, err := common.GetSettingValue(a.settingCache, common.LegacyResourceLockdownSetting)
+	if err != nil {
+		return false, err
+	}
+	locked, err := common.ParseLegacyResourceLockdown(value)
+	if err != nil {
+		// the setting validator rejects invalid values, fall back to the defaults if one got through
+		logrus.Warnf("[legacyResourceValidator] ignoring invalid %s setting: %v", common.LegacyResourceLockdownSetting, err)
+		locked, _ = common.ParseLegacyResourceLockdown("")
+	}
+	return locked[a.resource], nil
+}
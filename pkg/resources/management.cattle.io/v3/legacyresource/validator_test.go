@@ -0,0 +1,140 @@
+package legacyresource
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	v3 "github.com/rancher/rancher/pkg/apis/management.cattle.io/v3"
+	"github.com/rancher/webhook/pkg/admission"
+	"github.com/rancher/webhook/pkg/resources/common"
+	"github.com/rancher/wrangler/v3/pkg/generic/fake"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+	admissionv1 "k8s.io/api/admission/v1"
+	authenticationv1 "k8s.io/api/authentication/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+func TestNewValidators(t *testing.T) {
+	t.Parallel()
+	validators := NewValidators(nil)
+	require.Len(t, validators, len(common.LegacyResources))
+	for i, validator := range validators {
+		assert.Equal(t, "management.cattle.io", validator.GVR().Group)
+		if i > 0 {
+			assert.Less(t, validators[i-1].GVR().Resource, validator.GVR().Resource)
+		}
+	}
+}
+
+func TestAdmit(t *testing.T) {
+	t.Parallel()
+	deleted := metav1.Now()
+	tests := []struct {
+		name      string
+		resource  string
+		setting   *v3.Setting
+		operation admissionv1.Operation
+		groups    []string
+		deleted   bool
+		allowed   bool
+	}{
+		{
+			name:      "default locked resource",
+			resource:  "composeconfigs",
+			operation: admissionv1.Create,
+		},
+		{
+			name:      "default unlocked resource",
+			resource:  "catalogs",
+			operation: admissionv1.Create,
+			allowed:   true,
+		},
+		{
+			name:      "locked by the setting",
+			resource:  "catalogs",
+			setting:   &v3.Setting{Value: "catalogs"},
+			operation: admissionv1.Update,
+		},
+		{
+			name:      "unlocked by the setting",
+			resource:  "composeconfigs",
+			setting:   &v3.Setting{Value: "catalogs"},
+			operation: admissionv1.Create,
+			allowed:   true,
+		},
+		{
+			name:      "nothing locked",
+			resource:  "composeconfigs",
+			setting:   &v3.Setting{Value: "none"},
+			operation: admissionv1.Create,
+			allowed:   true,
+		},
+		{
+			name:      "setting default",
+			resource:  "catalogs",
+			setting:   &v3.Setting{Default: "catalogs"},
+			operation: admissionv1.Create,
+		},
+		{
+			name:      "invalid setting falls back to the defaults",
+			resource:  "composeconfigs",
+			setting:   &v3.Setting{Value: "clusters"},
+			operation: admissionv1.Create,
+		},
+		{
+			name:      "controller",
+			resource:  "composeconfigs",
+			operation: admissionv1.Create,
+			groups:    []string{"system:serviceaccounts:cattle-system"},
+			allowed:   true,
+		},
+		{
+			name:      "update of a deleted object",
+			resource:  "composeconfigs",
+			operation: admissionv1.Update,
+			deleted:   true,
+			allowed:   true,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			ctrl := gomock.NewController(t)
+			settingCache := fake.NewMockNonNamespacedCacheInterface[*v3.Setting](ctrl)
+			if tt.setting != nil {
+				settingCache.EXPECT().Get(common.LegacyResourceLockdownSetting).Return(tt.setting, nil).AnyTimes()
+			} else {
+				settingCache.EXPECT().Get(common.LegacyResourceLockdownSetting).Return(nil,
+					apierrors.NewNotFound(v3.Resource("settings"), common.LegacyResourceLockdownSetting)).AnyTimes()
+			}
+
+			obj := metav1.PartialObjectMetadata{ObjectMeta: metav1.ObjectMeta{Name: "legacy"}}
+			if tt.deleted {
+				obj.DeletionTimestamp = &deleted
+			}
+			raw, err := json.Marshal(obj)
+			require.NoError(t, err)
+
+			validator := NewValidator(tt.resource, settingCache)
+			resp, err := validator.Admitters()[0].Admit(&admission.Request{
+				Context: context.Background(),
+				AdmissionRequest: admissionv1.AdmissionRequest{
+					Operation: tt.operation,
+					UserInfo:  authenticationv1.UserInfo{Username: "user-abcde", Groups: tt.groups},
+					Object:    runtime.RawExtension{Raw: raw},
+				},
+			})
+			require.NoError(t, err)
+			assert.Equal(t, tt.allowed, resp.Allowed)
+			if !tt.allowed {
+				assert.Contains(t, resp.Result.Message, common.LegacyResources[tt.resource])
+			}
+		})
+	}
+}
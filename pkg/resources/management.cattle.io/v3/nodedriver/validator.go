@@ -3,6 +3,10 @@ package nodedriver
 
 import (
 	"fmt"
+	"net/url"
+	"os"
+	"regexp"
+	"strings"
 
 	"github.com/rancher/lasso/pkg/dynamic"
 	v3 "github.com/rancher/rancher/pkg/apis/management.cattle.io/v3"
@@ -17,7 +21,20 @@ import (
 	"k8s.io/apimachinery/pkg/runtime/schema"
 )
 
+const (
+	// URLAllowListEnv is the environment variable holding the comma separated domains node drivers can be downloaded
+	// from. Any domain is allowed when it is unset.
+	URLAllowListEnv = "CATTLE_WEBHOOK_NODE_DRIVER_URL_ALLOWLIST"
+
+	nodeTemplateByDriverIndex = "management.cattle.io/nodetemplate-by-driver"
+	// localURLScheme is the scheme of the URLs of the drivers built into Rancher, which aren't downloaded.
+	localURLScheme = "local"
+)
+
 var (
+	// checksumRegex matches the hex encoded MD5, SHA-1, SHA-256 or SHA-512 checksums Rancher verifies drivers with.
+	checksumRegex = regexp.MustCompile(`^([0-9a-fA-F]{32}|[0-9a-fA-F]{40}|[0-9a-fA-F]{64}|[0-9a-fA-F]{128})$`)
+
 	gvr = schema.GroupVersionResource{
 		Group:    "management.cattle.io",
 		Version:  "v3",
@@ -39,8 +56,11 @@ type Validator struct {
 }
 
 type admitter struct {
-	nodeCache controllersv3.NodeCache
-	dynamic   dynamicLister
+	nodeCache         controllersv3.NodeCache
+	nodeTemplateCache controllersv3.NodeTemplateCache
+	dynamic           dynamicLister
+	// urlAllowList are the domains driver URLs must belong to, or nil if any domain is allowed.
+	urlAllowList []string
 }
 
 // dynamicLister is an interface to abstract away how we list dynamic objects from k8s
@@ -48,11 +68,32 @@ type dynamicLister interface {
 	List(gvk schema.GroupVersionKind, namespace string, selector labels.Selector) ([]runtime.Object, error)
 }
 
-// NewValidator returns a new Validator for NodeDriver resources
-func NewValidator(nodeCache controllersv3.NodeCache, dynamic *dynamic.Controller) admission.ValidatingAdmissionHandler {
+// URLAllowListFromEnv returns the domains node drivers can be downloaded from, or nil if any domain is allowed.
+func URLAllowListFromEnv() ([]string, error) {
+	value := os.Getenv(URLAllowListEnv)
+	if value == "" {
+		return nil, nil
+	}
+	var domains []string
+	for _, domain := range strings.Split(value, ",") {
+		domain = strings.ToLower(strings.TrimSpace(domain))
+		if domain == "" || strings.ContainsAny(domain, "/:") {
+			return nil, fmt.Errorf("invalid value '%s' for %s: must be comma separated domains", value, URLAllowListEnv)
+		}
+		domains = append(domains, strings.TrimPrefix(domain, "."))
+	}
+	return domains, nil
+}
+
+// NewValidator returns a new Validator for NodeDriver resources. The URLs of drivers must belong to one of the domains
+// of urlAllowList, unless it is empty.
+func NewValidator(nodeCache controllersv3.NodeCache, nodeTemplateCache controllersv3.NodeTemplateCache, dynamic *dynamic.Controller, urlAllowList []string) admission.ValidatingAdmissionHandler {
+	nodeTemplateCache.AddIndexer(nodeTemplateByDriverIndex, nodeTemplateByDriver)
 	return &Validator{admitter: admitter{
-		nodeCache: nodeCache,
-		dynamic:   dynamic,
+		nodeCache:         nodeCache,
+		nodeTemplateCache: nodeTemplateCache,
+		dynamic:           dynamic,
+		urlAllowList:      urlAllowList,
 	}}
 }
 
@@ -63,7 +104,7 @@ func (v *Validator) GVR() schema.GroupVersionResource {
 
 // Operations returns list of operations handled by this validator.
 func (v *Validator) Operations() []admissionregistrationv1.OperationType {
-	return []admissionregistrationv1.OperationType{admissionregistrationv1.Update, admissionregistrationv1.Delete}
+	return []admissionregistrationv1.OperationType{admissionregistrationv1.Create, admissionregistrationv1.Update, admissionregistrationv1.Delete}
 }
 
 // ValidatingWebhook returns the ValidatingWebhook used for this CRD.
@@ -84,6 +125,12 @@ func (a *admitter) Admit(request *admission.Request) (*admissionv1.AdmissionResp
 		return nil, fmt.Errorf("failed to decode object from request: %w", err)
 	}
 
+	if request.Operation == admissionv1.Create || request.Operation == admissionv1.Update {
+		if message := a.validateSource(request, oldObject, newObject); message != "" {
+			return admission.ResponseBadRequest(message), nil
+		}
+	}
+
 	// the check to see if the driver is being disabled is either when we're
 	// running a delete operation OR an update operation where the active flag
 	// toggles from true -> false
@@ -92,6 +139,17 @@ func (a *admitter) Admit(request *admission.Request) (*admissionv1.AdmissionResp
 		return admission.ResponseAllowed(), nil
 	}
 
+	if oldObject.Spec.Builtin {
+		templates, err := a.nodeTemplateCache.GetByIndex(nodeTemplateByDriverIndex, oldObject.Spec.DisplayName)
+		if err != nil {
+			return nil, fmt.Errorf("error listing node templates from cache: %w", err)
+		}
+		if len(templates) > 0 {
+			return admission.ResponseBadRequest(fmt.Sprintf("built-in driver %s is used by %d node templates and cannot be disabled",
+				oldObject.Spec.DisplayName, len(templates))), nil
+		}
+	}
+
 	// check if all node resources have been deleted for both cluster types
 	rke1Deleted, err := a.rke1ResourcesDeleted(oldObject)
 	if err != nil {
@@ -109,6 +167,71 @@ func (a *admitter) Admit(request *admission.Request) (*admissionv1.AdmissionResp
 	return admission.ResponseAllowed(), nil
 }
 
+// validateSource checks where the driver is downloaded from when it is created or changed by a user: its URLs must
+// belong to one of the domains of the allow-list, and a checksum of the binary must be set when the driver is activated
+// or its URL changes while active. Built-in drivers aren't downloaded, so their URL and checksum aren't checked, but only
+// once they are built-in in the old object too: users can't create built-in drivers or make a driver built-in. The
+// drivers managed by Rancher's controllers aren't checked.
+func (a *admitter) validateSource(request *admission.Request, oldDriver, newDriver *v3.NodeDriver) string {
+	if admission.IsController(request) {
+		return ""
+	}
+	create := request.Operation == admissionv1.Create
+	builtin := !create && ((oldDriver.Spec.Builtin && newDriver.Spec.Builtin) ||
+		(isLocalURL(oldDriver.Spec.URL) && oldDriver.Spec.URL == newDriver.Spec.URL))
+	urlChanged := create || oldDriver.Spec.URL != newDriver.Spec.URL
+	if len(a.urlAllowList) > 0 {
+		for _, field := range []struct {
+			name, value, old string
+			skip             bool
+		}{
+			{name: "spec.url", value: newDriver.Spec.URL, old: oldDriver.Spec.URL, skip: builtin},
+			{name: "spec.uiUrl", value: newDriver.Spec.UIURL, old: oldDriver.Spec.UIURL},
+		} {
+			if field.skip || field.value == "" || (!create && field.value == field.old) {
+				continue
+			}
+			if !a.isAllowedURL(field.value) {
+				return fmt.Sprintf("%s %q is not in an allowed domain: must be in one of %s", field.name, field.value, strings.Join(a.urlAllowList, ", "))
+			}
+		}
+	}
+	activating := newDriver.Spec.Active && (create || !oldDriver.Spec.Active || urlChanged)
+	if !builtin && activating && !checksumRegex.MatchString(newDriver.Spec.Checksum) {
+		return fmt.Sprintf("spec.checksum must be the hex encoded MD5, SHA-1, SHA-256 or SHA-512 checksum of the driver to activate driver %s", newDriver.Spec.DisplayName)
+	}
+	return ""
+}
+
+// isAllowedURL returns true if the host of the URL is one of the domains of the allow-list or a subdomain of one.
+func (a *admitter) isAllowedURL(rawURL string) bool {
+	parsed, err := url.Parse(rawURL)
+	if err != nil {
+		return false
+	}
+	host := strings.ToLower(parsed.Hostname())
+	if host == "" {
+		return false
+	}
+	for _, domain := range a.urlAllowList {
+		if host == domain || strings.HasSuffix(host, "."+domain) {
+			return true
+		}
+	}
+	return false
+}
+
+func isLocalURL(rawURL string) bool {
+	return strings.HasPrefix(rawURL, localURLScheme+"://")
+}
+
+func nodeTemplateByDriver(template *v3.NodeTemplate) ([]string, error) {
+	if template.Spec.Driver == "" {
+		return nil, nil
+	}
+	return []string{template.Spec.Driver}, nil
+}
+
 // // RKE1
 // this one is a bit more clean since we're just looking at nodes with
 // the <displayname> provider
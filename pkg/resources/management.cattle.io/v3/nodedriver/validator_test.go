@@ -8,9 +8,11 @@ import (
 	v3 "github.com/rancher/rancher/pkg/apis/management.cattle.io/v3"
 	"github.com/rancher/webhook/pkg/admission"
 	"github.com/rancher/wrangler/v3/pkg/generic/fake"
+	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/suite"
 	"go.uber.org/mock/gomock"
 	admissionv1 "k8s.io/api/admission/v1"
+	authenticationv1 "k8s.io/api/authentication/v1"
 	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/labels"
 	"k8s.io/apimachinery/pkg/runtime"
@@ -185,6 +187,250 @@ func (suite *NodeDriverValidationSuite) TestDeleteRKE2Bad() {
 	suite.False(resp.Allowed, "admission request was allowed")
 }
 
+func (suite *NodeDriverValidationSuite) TestDriverSource() {
+	const checksum = "0123456789abcdef0123456789abcdef0123456789abcdef0123456789abcdef"
+	allowList := []string{"github.com", "releases.example.com"}
+
+	tests := []struct {
+		name        string
+		operation   admissionv1.Operation
+		user        authenticationv1.UserInfo
+		oldSpec     v3.NodeDriverSpec
+		newSpec     v3.NodeDriverSpec
+		allowList   []string
+		wantAllowed bool
+	}{
+		{
+			name:        "create with allowed URL and checksum",
+			operation:   admissionv1.Create,
+			newSpec:     v3.NodeDriverSpec{URL: "https://github.com/org/driver/releases/download/v1/driver", Checksum: checksum, Active: true},
+			allowList:   allowList,
+			wantAllowed: true,
+		},
+		{
+			name:        "create with URL in allowed subdomain",
+			operation:   admissionv1.Create,
+			newSpec:     v3.NodeDriverSpec{URL: "https://cdn.releases.example.com/driver", Checksum: checksum, Active: true},
+			allowList:   allowList,
+			wantAllowed: true,
+		},
+		{
+			name:      "create with URL outside of allow-list",
+			operation: admissionv1.Create,
+			newSpec:   v3.NodeDriverSpec{URL: "https://github.com.evil.io/driver", Checksum: checksum, Active: true},
+			allowList: allowList,
+		},
+		{
+			name:      "create with UI URL outside of allow-list",
+			operation: admissionv1.Create,
+			newSpec:   v3.NodeDriverSpec{URL: "https://github.com/driver", UIURL: "https://evil.io/component.js", Checksum: checksum},
+			allowList: allowList,
+		},
+		{
+			name:        "any URL allowed without allow-list",
+			operation:   admissionv1.Create,
+			newSpec:     v3.NodeDriverSpec{URL: "https://evil.io/driver", Checksum: checksum, Active: true},
+			wantAllowed: true,
+		},
+		{
+			name:        "unchanged URL outside of allow-list",
+			operation:   admissionv1.Update,
+			oldSpec:     v3.NodeDriverSpec{URL: "https://evil.io/driver", Checksum: checksum, Active: true, DisplayName: "driver"},
+			newSpec:     v3.NodeDriverSpec{URL: "https://evil.io/driver", Checksum: checksum, Active: true, DisplayName: "renamed"},
+			allowList:   allowList,
+			wantAllowed: true,
+		},
+		{
+			name:      "updated URL outside of allow-list",
+			operation: admissionv1.Update,
+			oldSpec:   v3.NodeDriverSpec{URL: "https://github.com/driver", Checksum: checksum},
+			newSpec:   v3.NodeDriverSpec{URL: "https://evil.io/driver", Checksum: checksum},
+			allowList: allowList,
+		},
+		{
+			name:      "create builtin driver",
+			operation: admissionv1.Create,
+			newSpec:   v3.NodeDriverSpec{URL: "https://evil.io/driver", Builtin: true, Active: true},
+			allowList: allowList,
+		},
+		{
+			name:      "create local driver",
+			operation: admissionv1.Create,
+			newSpec:   v3.NodeDriverSpec{URL: "local://", Active: true},
+			allowList: allowList,
+		},
+		{
+			name:      "make driver builtin",
+			operation: admissionv1.Update,
+			oldSpec:   v3.NodeDriverSpec{URL: "https://github.com/driver"},
+			newSpec:   v3.NodeDriverSpec{URL: "https://evil.io/driver", Builtin: true, Active: true},
+			allowList: allowList,
+		},
+		{
+			name:        "activate builtin driver",
+			operation:   admissionv1.Update,
+			oldSpec:     v3.NodeDriverSpec{URL: "local://", Builtin: true},
+			newSpec:     v3.NodeDriverSpec{URL: "local://", Builtin: true, Active: true},
+			allowList:   allowList,
+			wantAllowed: true,
+		},
+		{
+			name:        "activate local driver",
+			operation:   admissionv1.Update,
+			oldSpec:     v3.NodeDriverSpec{URL: "local://"},
+			newSpec:     v3.NodeDriverSpec{URL: "local://", Active: true},
+			allowList:   allowList,
+			wantAllowed: true,
+		},
+		{
+			name:      "builtin driver with UI URL outside of allow-list",
+			operation: admissionv1.Update,
+			oldSpec:   v3.NodeDriverSpec{URL: "local://", Builtin: true, Active: true},
+			newSpec:   v3.NodeDriverSpec{URL: "local://", Builtin: true, Active: true, UIURL: "https://evil.io/component.js"},
+			allowList: allowList,
+		},
+		{
+			name:      "controller",
+			operation: admissionv1.Create,
+			user: authenticationv1.UserInfo{
+				Username: "system:serviceaccount:cattle-system:rancher",
+				Groups:   []string{"system:serviceaccounts", "system:serviceaccounts:cattle-system"},
+			},
+			newSpec:     v3.NodeDriverSpec{URL: "https://evil.io/driver", Active: true},
+			allowList:   allowList,
+			wantAllowed: true,
+		},
+		{
+			name:        "create inactive without checksum",
+			operation:   admissionv1.Create,
+			newSpec:     v3.NodeDriverSpec{URL: "https://github.com/driver"},
+			wantAllowed: true,
+		},
+		{
+			name:      "create active without checksum",
+			operation: admissionv1.Create,
+			newSpec:   v3.NodeDriverSpec{URL: "https://github.com/driver", Active: true},
+		},
+		{
+			name:      "activate without checksum",
+			operation: admissionv1.Update,
+			oldSpec:   v3.NodeDriverSpec{URL: "https://github.com/driver"},
+			newSpec:   v3.NodeDriverSpec{URL: "https://github.com/driver", Active: true},
+		},
+		{
+			name:      "activate with invalid checksum",
+			operation: admissionv1.Update,
+			oldSpec:   v3.NodeDriverSpec{URL: "https://github.com/driver"},
+			newSpec:   v3.NodeDriverSpec{URL: "https://github.com/driver", Checksum: "not-a-checksum", Active: true},
+		},
+		{
+			name:      "change URL of active driver without checksum",
+			operation: admissionv1.Update,
+			oldSpec:   v3.NodeDriverSpec{URL: "https://github.com/driver/v1", Active: true},
+			newSpec:   v3.NodeDriverSpec{URL: "https://github.com/driver/v2", Active: true},
+		},
+		{
+			name:        "activate with checksum",
+			operation:   admissionv1.Update,
+			oldSpec:     v3.NodeDriverSpec{URL: "https://github.com/driver"},
+			newSpec:     v3.NodeDriverSpec{URL: "https://github.com/driver", Checksum: checksum, Active: true},
+			wantAllowed: true,
+		},
+	}
+	for _, tt := range tests {
+		suite.Run(tt.name, func() {
+			a := admitter{urlAllowList: tt.allowList}
+			request := &admission.Request{
+				Context: context.Background(),
+				AdmissionRequest: admissionv1.AdmissionRequest{
+					Operation: tt.operation,
+					UserInfo:  tt.user,
+					Object:    runtime.RawExtension{Raw: newNodeDriverWithSpec(tt.newSpec)},
+				},
+			}
+			if tt.operation == admissionv1.Update {
+				request.OldObject = runtime.RawExtension{Raw: newNodeDriverWithSpec(tt.oldSpec)}
+			}
+			resp, err := a.Admit(request)
+			suite.Require().NoError(err)
+			suite.Equal(tt.wantAllowed, resp.Allowed, resp.Result)
+		})
+	}
+}
+
+func (suite *NodeDriverValidationSuite) TestDisableBuiltinDriverUsedByNodeTemplates() {
+	ctrl := gomock.NewController(suite.T())
+	templateCache := fake.NewMockCacheInterface[*v3.NodeTemplate](ctrl)
+	templateCache.EXPECT().GetByIndex(nodeTemplateByDriverIndex, "amazonec2").Return([]*v3.NodeTemplate{{}}, nil)
+
+	a := admitter{nodeTemplateCache: templateCache}
+	resp, err := a.Admit(&admission.Request{
+		Context: context.Background(),
+		AdmissionRequest: admissionv1.AdmissionRequest{
+			Operation: admissionv1.Update,
+			OldObject: runtime.RawExtension{Raw: newNodeDriverWithSpec(v3.NodeDriverSpec{DisplayName: "amazonec2", Builtin: true, Active: true})},
+			Object:    runtime.RawExtension{Raw: newNodeDriverWithSpec(v3.NodeDriverSpec{DisplayName: "amazonec2", Builtin: true})},
+		}})
+
+	suite.Nil(err)
+	suite.False(resp.Allowed, "admission request was allowed")
+	suite.Contains(resp.Result.Message, "used by 1 node templates")
+}
+
+func (suite *NodeDriverValidationSuite) TestDeleteUnusedBuiltinDriver() {
+	ctrl := gomock.NewController(suite.T())
+	templateCache := fake.NewMockCacheInterface[*v3.NodeTemplate](ctrl)
+	templateCache.EXPECT().GetByIndex(nodeTemplateByDriverIndex, "amazonec2").Return(nil, nil)
+	mockCache := fake.NewMockCacheInterface[*v3.Node](ctrl)
+	mockCache.EXPECT().List("", labels.Everything()).Return([]*v3.Node{}, nil)
+
+	a := admitter{
+		nodeCache:         mockCache,
+		nodeTemplateCache: templateCache,
+		dynamic:           &mockLister{},
+	}
+	resp, err := a.Admit(&admission.Request{
+		Context: context.Background(),
+		AdmissionRequest: admissionv1.AdmissionRequest{
+			Operation: admissionv1.Delete,
+			OldObject: runtime.RawExtension{Raw: newNodeDriverWithSpec(v3.NodeDriverSpec{DisplayName: "amazonec2", Builtin: true, Active: true})},
+		}})
+
+	suite.Nil(err)
+	suite.True(resp.Allowed, "admission request was denied")
+}
+
+func TestURLAllowListFromEnv(t *testing.T) {
+	tests := []struct {
+		value   string
+		want    []string
+		wantErr bool
+	}{
+		{value: ""},
+		{value: "github.com", want: []string{"github.com"}},
+		{value: " GitHub.com, .example.com ", want: []string{"github.com", "example.com"}},
+		{value: "github.com,,example.com", wantErr: true},
+		{value: "https://github.com", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			t.Setenv(URLAllowListEnv, tt.value)
+			got, err := URLAllowListFromEnv()
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func newNodeDriverWithSpec(spec v3.NodeDriverSpec) []byte {
+	b, _ := json.Marshal(&v3.NodeDriver{Spec: spec})
+	return b
+}
+
 func newNodeDriver(active bool, annotations map[string]string) []byte {
 	if annotations == nil {
 		annotations = map[string]string{}
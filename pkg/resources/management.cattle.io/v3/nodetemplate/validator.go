@@ -0,0 +1,100 @@
+// Package nodetemplate is used for validating nodetemplates.
+package nodetemplate
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/rancher/webhook/pkg/admission"
+	"github.com/rancher/webhook/pkg/auth"
+	objectsv3 "github.com/rancher/webhook/pkg/generated/objects/management.cattle.io/v3"
+	admissionv1 "k8s.io/api/admission/v1"
+	admissionregistrationv1 "k8s.io/api/admissionregistration/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	authorizationv1 "k8s.io/client-go/kubernetes/typed/authorization/v1"
+	"k8s.io/utils/trace"
+)
+
+var gvr = schema.GroupVersionResource{
+	Group:    "management.cattle.io",
+	Version:  "v3",
+	Resource: "nodetemplates",
+}
+
+var secretsGVR = schema.GroupVersionResource{
+	Version:  "v1",
+	Resource: "secrets",
+}
+
+// NewValidator returns a new validator for nodetemplates.
+func NewValidator(sar authorizationv1.SubjectAccessReviewInterface) *Validator {
+	return &Validator{
+		admitter: admitter{
+			sar: sar,
+		},
+	}
+}
+
+// Validator for validating nodetemplates.
+type Validator struct {
+	admitter admitter
+}
+
+// GVR returns the GroupVersionKind for this CRD.
+func (v *Validator) GVR() schema.GroupVersionResource {
+	return gvr
+}
+
+// Operations returns list of operations handled by this validator.
+func (v *Validator) Operations() []admissionregistrationv1.OperationType {
+	return []admissionregistrationv1.OperationType{admissionregistrationv1.Create, admissionregistrationv1.Update}
+}
+
+// ValidatingWebhook returns the ValidatingWebhook used for this CRD.
+func (v *Validator) ValidatingWebhook(clientConfig admissionregistrationv1.WebhookClientConfig) []admissionregistrationv1.ValidatingWebhook {
+	return []admissionregistrationv1.ValidatingWebhook{*admission.NewDefaultValidatingWebhook(v, clientConfig, admissionregistrationv1.NamespacedScope, v.Operations())}
+}
+
+// Admitters returns the admitter objects used to validate nodetemplates.
+func (v *Validator) Admitters() []admission.Admitter {
+	return []admission.Admitter{&v.admitter}
+}
+
+type admitter struct {
+	sar authorizationv1.SubjectAccessReviewInterface
+}
+
+// Admit handles the webhook admission request sent to this webhook.
+func (a *admitter) Admit(request *admission.Request) (*admissionv1.AdmissionResponse, error) {
+	listTrace := trace.New("nodeTemplateValidator Admit", trace.Field{Key: "user", Value: request.UserInfo.Username})
+	defer listTrace.LogIfLong(admission.SlowTraceDuration)
+
+	oldTemplate, newTemplate, err := objectsv3.NodeTemplateOldAndNewFromRequest(&request.AdmissionRequest)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get old and new nodetemplates from request: %w", err)
+	}
+
+	credential := newTemplate.Spec.CloudCredentialName
+	if credential == "" || credential == oldTemplate.Spec.CloudCredentialName {
+		return admission.ResponseAllowed(), nil
+	}
+
+	namespace, name := cloudCredentialSecret(newTemplate.Namespace, credential)
+	canGet, err := auth.RequestUserHasVerb(request, secretsGVR, a.sar, "get", name, namespace)
+	if err != nil {
+		return nil, fmt.Errorf("failed to check access to cloud credential %s: %w", credential, err)
+	}
+	if !canGet {
+		return admission.ResponseFailedEscalation(fmt.Sprintf("user %s cannot get cloud credential %s", request.UserInfo.Username, credential)), nil
+	}
+	return admission.ResponseAllowed(), nil
+}
+
+// cloudCredentialSecret returns the namespace and name of the secret of a cloud credential, which is referenced either
+// as "<namespace>:<name>" or by name in the namespace of the nodetemplate.
+func cloudCredentialSecret(namespace, credential string) (string, string) {
+	if credentialNamespace, name, found := strings.Cut(credential, ":"); found {
+		return credentialNamespace, name
+	}
+	return namespace, credential
+}
@@ -0,0 +1,136 @@
+package nodetemplate
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	v3 "github.com/rancher/rancher/pkg/apis/management.cattle.io/v3"
+	"github.com/rancher/webhook/pkg/admission"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	admissionv1 "k8s.io/api/admission/v1"
+	authenticationv1 "k8s.io/api/authentication/v1"
+	authorizationv1 "k8s.io/api/authorization/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	k8fake "k8s.io/client-go/kubernetes/typed/authorization/v1/fake"
+	k8testing "k8s.io/client-go/testing"
+)
+
+func newNodeTemplate(credential string) *v3.NodeTemplate {
+	return &v3.NodeTemplate{
+		ObjectMeta: metav1.ObjectMeta{Name: "nt-abcde", Namespace: "u-12345"},
+		Spec: v3.NodeTemplateSpec{
+			Driver:              "amazonec2",
+			CloudCredentialName: credential,
+		},
+	}
+}
+
+func TestAdmit(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name          string
+		operation     admissionv1.Operation
+		oldTemplate   *v3.NodeTemplate
+		newTemplate   *v3.NodeTemplate
+		wantAllowed   bool
+		wantReviewFor string
+	}{
+		{
+			name:          "create with a readable credential",
+			operation:     admissionv1.Create,
+			newTemplate:   newNodeTemplate("cattle-global-data:cc-allowed"),
+			wantAllowed:   true,
+			wantReviewFor: "cattle-global-data/cc-allowed",
+		},
+		{
+			name:          "create with an unreadable credential",
+			operation:     admissionv1.Create,
+			newTemplate:   newNodeTemplate("cattle-global-data:cc-denied"),
+			wantAllowed:   false,
+			wantReviewFor: "cattle-global-data/cc-denied",
+		},
+		{
+			name:          "create with a credential in the namespace of the template",
+			operation:     admissionv1.Create,
+			newTemplate:   newNodeTemplate("cc-allowed"),
+			wantAllowed:   true,
+			wantReviewFor: "u-12345/cc-allowed",
+		},
+		{
+			name:        "create without a credential",
+			operation:   admissionv1.Create,
+			newTemplate: newNodeTemplate(""),
+			wantAllowed: true,
+		},
+		{
+			name:          "update to an unreadable credential",
+			operation:     admissionv1.Update,
+			oldTemplate:   newNodeTemplate("cattle-global-data:cc-allowed"),
+			newTemplate:   newNodeTemplate("cattle-global-data:cc-denied"),
+			wantAllowed:   false,
+			wantReviewFor: "cattle-global-data/cc-denied",
+		},
+		{
+			name:        "update keeping the credential",
+			operation:   admissionv1.Update,
+			oldTemplate: newNodeTemplate("cattle-global-data:cc-denied"),
+			newTemplate: newNodeTemplate("cattle-global-data:cc-denied"),
+			wantAllowed: true,
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+			var reviews []string
+			k8Fake := &k8testing.Fake{}
+			k8Fake.AddReactor("create", "subjectaccessreviews", func(action k8testing.Action) (bool, runtime.Object, error) {
+				review := action.(k8testing.CreateActionImpl).GetObject().(*authorizationv1.SubjectAccessReview)
+				attributes := review.Spec.ResourceAttributes
+				assert.Equal(t, "get", attributes.Verb)
+				assert.Equal(t, "secrets", attributes.Resource)
+				assert.Equal(t, "user", review.Spec.User)
+				reviews = append(reviews, attributes.Namespace+"/"+attributes.Name)
+				review.Status.Allowed = attributes.Name == "cc-allowed"
+				return true, review, nil
+			})
+			sar := &k8fake.FakeSubjectAccessReviews{Fake: &k8fake.FakeAuthorizationV1{Fake: k8Fake}}
+
+			oldTemplate := test.oldTemplate
+			if oldTemplate == nil {
+				oldTemplate = &v3.NodeTemplate{}
+			}
+			oldRaw, err := json.Marshal(oldTemplate)
+			require.NoError(t, err)
+			newRaw, err := json.Marshal(test.newTemplate)
+			require.NoError(t, err)
+
+			admitters := NewValidator(sar).Admitters()
+			require.Len(t, admitters, 1)
+			response, err := admitters[0].Admit(&admission.Request{
+				Context: context.Background(),
+				AdmissionRequest: admissionv1.AdmissionRequest{
+					Operation: test.operation,
+					UserInfo:  authenticationv1.UserInfo{Username: "user"},
+					Object:    runtime.RawExtension{Raw: newRaw},
+					OldObject: runtime.RawExtension{Raw: oldRaw},
+				},
+			})
+			require.NoError(t, err)
+			assert.Equal(t, test.wantAllowed, response.Allowed)
+			if !test.wantAllowed {
+				assert.Equal(t, int32(http.StatusForbidden), response.Result.Code)
+			}
+			if test.wantReviewFor == "" {
+				assert.Empty(t, reviews)
+			} else {
+				assert.Equal(t, []string{test.wantReviewFor}, reviews)
+			}
+		})
+	}
+}
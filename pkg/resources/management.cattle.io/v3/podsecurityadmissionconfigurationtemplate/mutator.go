@@ -0,0 +1,112 @@
+package podsecurityadmissionconfigurationtemplate
+
+import (
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+
+	"github.com/rancher/webhook/pkg/admission"
+	objectsv3 "github.com/rancher/webhook/pkg/generated/objects/management.cattle.io/v3"
+	"github.com/rancher/webhook/pkg/patch"
+	admissionv1 "k8s.io/api/admission/v1"
+	admissionregistrationv1 "k8s.io/api/admissionregistration/v1"
+	"k8s.io/apimachinery/pkg/api/validation"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/utils/trace"
+)
+
+const (
+	// RequiredExemptionsEnv is the environment variable used to configure the comma separated list of namespaces added
+	// to the namespace exemptions of new templates.
+	RequiredExemptionsEnv = "CATTLE_WEBHOOK_PSACT_REQUIRED_NAMESPACE_EXEMPTIONS"
+
+	// NoDefaultExemptionsAnn is the annotation used to opt a template out of the required namespace exemptions.
+	NoDefaultExemptionsAnn = "webhook.cattle.io/no-default-exemptions"
+)
+
+// defaultRequiredExemptions are the namespaces of the Rancher system workloads which must be exempted for them to run.
+var defaultRequiredExemptions = []string{"kube-system", "cattle-system", "cattle-fleet-system", "cattle-impersonation-system"}
+
+// RequiredExemptionsFromEnv returns the namespaces listed in RequiredExemptionsEnv, falling back to the default list if
+// it is unset.
+func RequiredExemptionsFromEnv() ([]string, error) {
+	value, ok := os.LookupEnv(RequiredExemptionsEnv)
+	if !ok {
+		return defaultRequiredExemptions, nil
+	}
+	var namespaces []string
+	for _, namespace := range strings.Split(value, ",") {
+		namespace = strings.TrimSpace(namespace)
+		if namespace == "" {
+			continue
+		}
+		if errs := validation.ValidateNamespaceName(namespace, false); len(errs) > 0 {
+			return nil, fmt.Errorf("invalid value '%s' for %s: %s is not a valid namespace name", value, RequiredExemptionsEnv, namespace)
+		}
+		namespaces = append(namespaces, namespace)
+	}
+	return namespaces, nil
+}
+
+// Mutator implements admission.MutatingAdmissionHandler.
+type Mutator struct {
+	requiredExemptions []string
+}
+
+// NewMutator returns a new mutator for PodSecurityAdmissionConfigurationTemplates which adds the required namespaces to
+// the namespace exemptions of new templates.
+func NewMutator(requiredExemptions []string) *Mutator {
+	return &Mutator{
+		requiredExemptions: requiredExemptions,
+	}
+}
+
+// GVR returns the GroupVersionKind for this CRD.
+func (m *Mutator) GVR() schema.GroupVersionResource {
+	return gvr
+}
+
+// Operations returns list of operations handled by this mutator.
+func (m *Mutator) Operations() []admissionregistrationv1.OperationType {
+	return []admissionregistrationv1.OperationType{admissionregistrationv1.Create}
+}
+
+// MutatingWebhook returns the MutatingWebhook used for this CRD.
+func (m *Mutator) MutatingWebhook(clientConfig admissionregistrationv1.WebhookClientConfig) []admissionregistrationv1.MutatingWebhook {
+	mutatingWebhook := admission.NewDefaultMutatingWebhook(m, clientConfig, admissionregistrationv1.ClusterScope, m.Operations())
+	return []admissionregistrationv1.MutatingWebhook{*mutatingWebhook}
+}
+
+// Admit handles the webhook admission request sent to this webhook.
+func (m *Mutator) Admit(request *admission.Request) (*admissionv1.AdmissionResponse, error) {
+	listTrace := trace.New("podSecurityAdmissionConfigurationTemplateMutator Admit", trace.Field{Key: "user", Value: request.UserInfo.Username})
+	defer listTrace.LogIfLong(admission.SlowTraceDuration)
+
+	template, err := objectsv3.PodSecurityAdmissionConfigurationTemplateFromRequest(&request.AdmissionRequest)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get %s from request: %w", gvr.Resource, err)
+	}
+	if template.Annotations[NoDefaultExemptionsAnn] == "true" {
+		return admission.ResponseAllowed(), nil
+	}
+
+	exemptions := &template.Configuration.Exemptions
+	added := false
+	for _, namespace := range m.requiredExemptions {
+		if !slices.Contains(exemptions.Namespaces, namespace) {
+			exemptions.Namespaces = append(exemptions.Namespaces, namespace)
+			added = true
+		}
+	}
+	if !added {
+		return admission.ResponseAllowed(), nil
+	}
+
+	response := &admissionv1.AdmissionResponse{}
+	if err := patch.CreatePatch(request.Object.Raw, template, response); err != nil {
+		return nil, fmt.Errorf("failed to create patch: %w", err)
+	}
+	response.Allowed = true
+	return response, nil
+}
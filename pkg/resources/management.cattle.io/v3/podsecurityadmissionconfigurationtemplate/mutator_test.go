@@ -0,0 +1,117 @@
+package podsecurityadmissionconfigurationtemplate
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"testing"
+
+	jsonpatch "github.com/evanphx/json-patch"
+	v3 "github.com/rancher/rancher/pkg/apis/management.cattle.io/v3"
+	"github.com/rancher/webhook/pkg/admission"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	admissionv1 "k8s.io/api/admission/v1"
+	authenticationv1 "k8s.io/api/authentication/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+func TestMutatorAdmit(t *testing.T) {
+	t.Parallel()
+	required := []string{"kube-system", "cattle-system"}
+	tests := []struct {
+		name        string
+		annotations map[string]string
+		namespaces  []string
+		want        []string
+	}{
+		{
+			name: "no exemptions",
+			want: []string{"kube-system", "cattle-system"},
+		},
+		{
+			name:       "some exemptions",
+			namespaces: []string{"cattle-system", "longhorn-system"},
+			want:       []string{"cattle-system", "longhorn-system", "kube-system"},
+		},
+		{
+			name:       "all required exemptions",
+			namespaces: []string{"kube-system", "cattle-system"},
+		},
+		{
+			name:        "opted out",
+			annotations: map[string]string{NoDefaultExemptionsAnn: "true"},
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+			template := &v3.PodSecurityAdmissionConfigurationTemplate{
+				ObjectMeta: metav1.ObjectMeta{Name: "template", Annotations: test.annotations},
+				Configuration: v3.PodSecurityAdmissionConfigurationTemplateSpec{
+					Exemptions: v3.PodSecurityAdmissionConfigurationTemplateExemptions{Namespaces: test.namespaces},
+				},
+			}
+			raw, err := json.Marshal(template)
+			require.NoError(t, err)
+
+			response, err := NewMutator(required).Admit(&admission.Request{
+				Context: context.Background(),
+				AdmissionRequest: admissionv1.AdmissionRequest{
+					Operation: admissionv1.Create,
+					UserInfo:  authenticationv1.UserInfo{Username: "user"},
+					Object:    runtime.RawExtension{Raw: raw},
+				},
+			})
+			require.NoError(t, err)
+			require.True(t, response.Allowed)
+			if test.want == nil {
+				assert.Nil(t, response.Patch)
+				return
+			}
+			patchObj, err := jsonpatch.DecodePatch(response.Patch)
+			require.NoError(t, err)
+			patched, err := patchObj.Apply(raw)
+			require.NoError(t, err)
+			got := &v3.PodSecurityAdmissionConfigurationTemplate{}
+			require.NoError(t, json.Unmarshal(patched, got))
+			assert.Equal(t, test.want, got.Configuration.Exemptions.Namespaces)
+		})
+	}
+}
+
+func TestRequiredExemptionsFromEnv(t *testing.T) {
+	tests := []struct {
+		name    string
+		value   *string
+		want    []string
+		wantErr bool
+	}{
+		{name: "unset", want: defaultRequiredExemptions},
+		{name: "empty", value: admission.Ptr("")},
+		{name: "list", value: admission.Ptr("kube-system, longhorn-system,"), want: []string{"kube-system", "longhorn-system"}},
+		{name: "invalid namespace", value: admission.Ptr("kube-system,Longhorn"), wantErr: true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			// t.Setenv restores the previous state of the variable after the test
+			t.Setenv(RequiredExemptionsEnv, "")
+			if test.value == nil {
+				require.NoError(t, os.Unsetenv(RequiredExemptionsEnv))
+			} else {
+				t.Setenv(RequiredExemptionsEnv, *test.value)
+			}
+			got, err := RequiredExemptionsFromEnv()
+			if test.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, test.want, got)
+		})
+	}
+}
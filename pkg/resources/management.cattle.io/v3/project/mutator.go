@@ -9,9 +9,11 @@ import (
 	ctrlv3 "github.com/rancher/webhook/pkg/generated/controllers/management.cattle.io/v3"
 	objectsv3 "github.com/rancher/webhook/pkg/generated/objects/management.cattle.io/v3"
 	"github.com/rancher/webhook/pkg/patch"
+	"github.com/rancher/webhook/pkg/resources/common"
 	"github.com/sirupsen/logrus"
 	admissionv1 "k8s.io/api/admission/v1"
 	admissionregistrationv1 "k8s.io/api/admissionregistration/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	"k8s.io/apimachinery/pkg/runtime/schema"
 	"k8s.io/utils/trace"
 )
@@ -20,6 +22,9 @@ const (
 	roleTemplatesRequired           = "authz.management.cattle.io/creator-role-bindings"
 	indexKey                        = "creatorDefaultUnlocked"
 	mutatorCreatorRoleTemplateIndex = "webhook.cattle.io/creator-role-template-index"
+	// defaultResourceLimitAnnotation holds the JSON encoded ContainerResourceLimit copied into the projects created
+	// without a containerDefaultResourceLimit. It is set on clusters, like it is set on namespaces by Rancher.
+	defaultResourceLimitAnnotation = "field.cattle.io/containerDefaultResourceLimit"
 )
 
 var gvr = schema.GroupVersionResource{
@@ -31,13 +36,17 @@ var gvr = schema.GroupVersionResource{
 // Mutator implements admission.MutatingAdmissionWebhook.
 type Mutator struct {
 	roleTemplateCache ctrlv3.RoleTemplateCache
+	settingCache      ctrlv3.SettingCache
+	clusterCache      ctrlv3.ClusterCache
 }
 
 // NewMutator returns a new mutator which mutates projects
-func NewMutator(roleTemplateCache ctrlv3.RoleTemplateCache) *Mutator {
+func NewMutator(roleTemplateCache ctrlv3.RoleTemplateCache, settingCache ctrlv3.SettingCache, clusterCache ctrlv3.ClusterCache) *Mutator {
 	roleTemplateCache.AddIndexer(mutatorCreatorRoleTemplateIndex, creatorRoleTemplateIndexer)
 	return &Mutator{
 		roleTemplateCache: roleTemplateCache,
+		settingCache:      settingCache,
+		clusterCache:      clusterCache,
 	}
 }
 
@@ -103,6 +112,23 @@ func (m *Mutator) admitCreate(project *v3.Project, request *admission.Request) (
 		return nil, fmt.Errorf("failed to add annotation to project %s: %w", project.Name, err)
 	}
 	newProject.Annotations[roleTemplatesRequired] = annotations
+
+	optOut, err := common.IsNoCreatorRBACNamespace(m.settingCache, request.Namespace)
+	if err != nil {
+		return nil, fmt.Errorf("failed to check creator RBAC policy for project %s: %w", project.Name, err)
+	}
+	if optOut {
+		common.SetNoCreatorRBACAnnotation(newProject)
+	}
+
+	if newProject.Spec.ContainerDefaultResourceLimit == nil {
+		limit, err := m.clusterDefaultResourceLimit(newProject.Spec.ClusterName)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get the default resource limit of cluster %s: %w", newProject.Spec.ClusterName, err)
+		}
+		newProject.Spec.ContainerDefaultResourceLimit = limit
+	}
+
 	response := &admissionv1.AdmissionResponse{}
 	if err := patch.CreatePatch(request.Object.Raw, newProject, response); err != nil {
 		return nil, fmt.Errorf("failed to create patch: %w", err)
@@ -111,6 +137,40 @@ func (m *Mutator) admitCreate(project *v3.Project, request *admission.Request) (
 	return response, nil
 }
 
+// clusterDefaultResourceLimit returns the container default resource limit set on the cluster with the
+// defaultResourceLimitAnnotation, or nil if there is none. An invalid limit is logged and ignored, so that it does not
+// prevent the creation of projects.
+func (m *Mutator) clusterDefaultResourceLimit(clusterName string) (*v3.ContainerResourceLimit, error) {
+	if clusterName == "" {
+		return nil, nil
+	}
+	cluster, err := m.clusterCache.Get(clusterName)
+	if err != nil {
+		if apierrors.IsNotFound(err) {
+			// the validator denies projects of clusters which don't exist
+			return nil, nil
+		}
+		return nil, err
+	}
+	value := cluster.Annotations[defaultResourceLimitAnnotation]
+	if value == "" {
+		return nil, nil
+	}
+	limit := &v3.ContainerResourceLimit{}
+	if err := json.Unmarshal([]byte(value), limit); err != nil {
+		logrus.Warnf("[project-mutation] ignoring the invalid %s annotation of cluster %s: %v", defaultResourceLimitAnnotation, clusterName, err)
+		return nil, nil
+	}
+	if err := validateContainerDefaultResourceLimit(limit); err != nil {
+		logrus.Warnf("[project-mutation] ignoring the invalid %s annotation of cluster %s: %v", defaultResourceLimitAnnotation, clusterName, err)
+		return nil, nil
+	}
+	if *limit == (v3.ContainerResourceLimit{}) {
+		return nil, nil
+	}
+	return limit, nil
+}
+
 func (m *Mutator) getCreatorRoleTemplateAnnotations() (string, error) {
 	roleTemplates, err := m.roleTemplateCache.GetByIndex(mutatorCreatorRoleTemplateIndex, indexKey)
 	if err != nil {
@@ -5,12 +5,17 @@ import (
 	"fmt"
 	"testing"
 
+	jsonpatch "github.com/evanphx/json-patch"
 	v3 "github.com/rancher/rancher/pkg/apis/management.cattle.io/v3"
+	"github.com/rancher/webhook/pkg/resources/common"
 	"github.com/rancher/wrangler/v3/pkg/generic/fake"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 	"go.uber.org/mock/gomock"
 	admissionv1 "k8s.io/api/admission/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
 )
 
 const (
@@ -27,8 +32,11 @@ func TestAdmit(t *testing.T) {
 		oldProject *v3.Project
 		newProject *v3.Project
 		indexer    func() ([]*v3.RoleTemplate, error)
-		wantPatch  []map[string]interface{}
-		wantErr    bool
+		// noCreatorRBACNamespaces is the value of the no-creator-rbac-namespaces setting, unset if empty.
+		noCreatorRBACNamespaces string
+		settingErr              error
+		wantPatch               []map[string]interface{}
+		wantErr                 bool
 	}{
 		{
 			name:       "dry run returns allowed",
@@ -125,6 +133,58 @@ func TestAdmit(t *testing.T) {
 				},
 			},
 		},
+		{
+			name:      "project in a no-creator-rbac namespace opts out of creator RBAC",
+			operation: admissionv1.Create,
+			newProject: &v3.Project{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "testproject",
+					Namespace: "system-integrations",
+				},
+			},
+			noCreatorRBACNamespaces: "other, system-integrations",
+			wantPatch: []map[string]interface{}{
+				{
+					"op":   "add",
+					"path": "/metadata/annotations",
+					"value": map[string]string{
+						"authz.management.cattle.io/creator-role-bindings": "{\"required\":[\"project-owner\"]}",
+						"field.cattle.io/no-creator-rbac":                  "true",
+					},
+				},
+			},
+		},
+		{
+			name:      "project outside of the no-creator-rbac namespaces is not changed",
+			operation: admissionv1.Create,
+			newProject: &v3.Project{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "testproject",
+					Namespace: "c-abcde",
+				},
+			},
+			noCreatorRBACNamespaces: "system-integrations",
+			wantPatch: []map[string]interface{}{
+				{
+					"op":   "add",
+					"path": "/metadata/annotations",
+					"value": map[string]string{
+						"authz.management.cattle.io/creator-role-bindings": "{\"required\":[\"project-owner\"]}",
+					},
+				},
+			},
+		},
+		{
+			name:      "setting error",
+			operation: admissionv1.Create,
+			newProject: &v3.Project{
+				ObjectMeta: metav1.ObjectMeta{
+					Name: "testproject",
+				},
+			},
+			settingErr: fmt.Errorf("setting error"),
+			wantErr:    true,
+		},
 	}
 
 	roleTemplates := []*v3.RoleTemplate{
@@ -155,7 +215,18 @@ func TestAdmit(t *testing.T) {
 			}
 			returnedRTs, returnedErr := indexer()
 			roleTemplateCache.EXPECT().GetByIndex(expectedIndexerName, expectedIndexKey).Return(returnedRTs, returnedErr).AnyTimes()
-			m := NewMutator(roleTemplateCache)
+			settingCache := fake.NewMockNonNamespacedCacheInterface[*v3.Setting](gomock.NewController(t))
+			settingCache.EXPECT().Get(common.NoCreatorRBACNamespacesSetting).DoAndReturn(func(name string) (*v3.Setting, error) {
+				if test.settingErr != nil {
+					return nil, test.settingErr
+				}
+				if test.noCreatorRBACNamespaces == "" {
+					return nil, apierrors.NewNotFound(schema.GroupResource{Group: "management.cattle.io", Resource: "settings"}, name)
+				}
+				return &v3.Setting{ObjectMeta: metav1.ObjectMeta{Name: name}, Value: test.noCreatorRBACNamespaces}, nil
+			}).AnyTimes()
+			clusterCache := fake.NewMockNonNamespacedCacheInterface[*v3.Cluster](gomock.NewController(t))
+			m := NewMutator(roleTemplateCache, settingCache, clusterCache)
 			resp, err := m.Admit(req)
 			if test.wantErr {
 				assert.Error(t, err)
@@ -171,3 +242,97 @@ func TestAdmit(t *testing.T) {
 		})
 	}
 }
+
+func TestAdmitDefaultResourceLimit(t *testing.T) {
+	t.Parallel()
+	clusterDefault := &v3.ContainerResourceLimit{RequestsCPU: "100m", LimitsCPU: "1", RequestsMemory: "64Mi", LimitsMemory: "256Mi"}
+	projectLimit := &v3.ContainerResourceLimit{LimitsCPU: "2"}
+	tests := []struct {
+		name         string
+		annotation   string
+		projectLimit *v3.ContainerResourceLimit
+		clusterErr   error
+		want         *v3.ContainerResourceLimit
+		wantErr      bool
+	}{
+		{
+			name:       "cluster default is copied",
+			annotation: `{"requestsCpu":"100m","limitsCpu":"1","requestsMemory":"64Mi","limitsMemory":"256Mi"}`,
+			want:       clusterDefault,
+		},
+		{
+			name:         "project limit is kept",
+			annotation:   `{"requestsCpu":"100m","limitsCpu":"1","requestsMemory":"64Mi","limitsMemory":"256Mi"}`,
+			projectLimit: projectLimit,
+			want:         projectLimit,
+		},
+		{
+			name: "cluster without default",
+		},
+		{
+			name:       "empty cluster default",
+			annotation: `{}`,
+		},
+		{
+			name:       "invalid cluster default is ignored",
+			annotation: `{"requestsCpu":"2","limitsCpu":"1"}`,
+		},
+		{
+			name:       "malformed cluster default is ignored",
+			annotation: `limitsCpu: 1`,
+		},
+		{
+			name:       "missing cluster",
+			clusterErr: apierrors.NewNotFound(schema.GroupResource{Group: "management.cattle.io", Resource: "clusters"}, "c-abcde"),
+		},
+		{
+			name:       "cluster cache error",
+			clusterErr: fmt.Errorf("cache error"),
+			wantErr:    true,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+			ctrl := gomock.NewController(t)
+			roleTemplateCache := fake.NewMockNonNamespacedCacheInterface[*v3.RoleTemplate](ctrl)
+			roleTemplateCache.EXPECT().AddIndexer(expectedIndexerName, gomock.Any())
+			roleTemplateCache.EXPECT().GetByIndex(expectedIndexerName, expectedIndexKey).Return(nil, nil)
+			settingCache := fake.NewMockNonNamespacedCacheInterface[*v3.Setting](ctrl)
+			settingCache.EXPECT().Get(common.NoCreatorRBACNamespacesSetting).Return(nil,
+				apierrors.NewNotFound(schema.GroupResource{Group: "management.cattle.io", Resource: "settings"}, common.NoCreatorRBACNamespacesSetting))
+			clusterCache := fake.NewMockNonNamespacedCacheInterface[*v3.Cluster](ctrl)
+			cluster := &v3.Cluster{ObjectMeta: metav1.ObjectMeta{Name: "c-abcde"}}
+			if test.annotation != "" {
+				cluster.Annotations = map[string]string{defaultResourceLimitAnnotation: test.annotation}
+			}
+			clusterCache.EXPECT().Get("c-abcde").DoAndReturn(func(string) (*v3.Cluster, error) {
+				if test.clusterErr != nil {
+					return nil, test.clusterErr
+				}
+				return cluster, nil
+			}).MaxTimes(1)
+
+			project := &v3.Project{
+				ObjectMeta: metav1.ObjectMeta{Name: "p-abcde", Namespace: "c-abcde"},
+				Spec:       v3.ProjectSpec{ClusterName: "c-abcde", ContainerDefaultResourceLimit: test.projectLimit},
+			}
+			req, err := createProjectRequest(nil, project, admissionv1.Create, false)
+			require.NoError(t, err)
+			resp, err := NewMutator(roleTemplateCache, settingCache, clusterCache).Admit(req)
+			if test.wantErr {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			require.True(t, resp.Allowed)
+			patch, err := jsonpatch.DecodePatch(resp.Patch)
+			require.NoError(t, err)
+			patched, err := patch.Apply(req.Object.Raw)
+			require.NoError(t, err)
+			got := &v3.Project{}
+			require.NoError(t, json.Unmarshal(patched, got))
+			assert.Equal(t, test.want, got.Spec.ContainerDefaultResourceLimit)
+		})
+	}
+}
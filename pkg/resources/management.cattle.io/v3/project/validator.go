@@ -3,22 +3,21 @@ package project
 import (
 	"errors"
 	"fmt"
-	"sort"
-	"strings"
 
 	v3 "github.com/rancher/rancher/pkg/apis/management.cattle.io/v3"
 	"github.com/rancher/webhook/pkg/admission"
 	controllerv3 "github.com/rancher/webhook/pkg/generated/controllers/management.cattle.io/v3"
 	objectsv3 "github.com/rancher/webhook/pkg/generated/objects/management.cattle.io/v3"
+	"github.com/rancher/webhook/pkg/quota"
 	"github.com/rancher/webhook/pkg/resources/common"
 	"github.com/rancher/wrangler/v3/pkg/data/convert"
 	admissionv1 "k8s.io/api/admission/v1"
 	admissionregistrationv1 "k8s.io/api/admissionregistration/v1"
-	v1 "k8s.io/api/core/v1"
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	"k8s.io/apimachinery/pkg/api/resource"
 	"k8s.io/apimachinery/pkg/runtime/schema"
 	"k8s.io/apimachinery/pkg/util/validation/field"
+	authorizationv1 "k8s.io/client-go/kubernetes/typed/authorization/v1"
 	"k8s.io/utils/trace"
 )
 
@@ -32,17 +31,23 @@ const (
 
 var projectSpecFieldPath = field.NewPath("project").Child("spec")
 
+// immutableFields are the fields of projects which can't be updated.
+var immutableFields = common.NewImmutableFields(common.ImmutableField{Path: "spec." + clusterNameField})
+
 // Validator implements admission.ValidatingAdmissionWebhook.
 type Validator struct {
 	admitter admitter
 }
 
 // NewValidator returns a project validator.
-func NewValidator(clusterCache controllerv3.ClusterCache, userCache controllerv3.UserCache) *Validator {
+func NewValidator(clusterCache controllerv3.ClusterCache, userCache controllerv3.UserCache, settingCache controllerv3.SettingCache,
+	sar authorizationv1.SubjectAccessReviewInterface) *Validator {
 	return &Validator{
 		admitter: admitter{
 			clusterCache: clusterCache,
 			userCache:    userCache,
+			settingCache: settingCache,
+			sar:          sar,
 		},
 	}
 }
@@ -52,6 +57,11 @@ func (v *Validator) GVR() schema.GroupVersionResource {
 	return gvr
 }
 
+// Subresources returns the subresources of projects whose writes are only allowed for controllers.
+func (v *Validator) Subresources() []string {
+	return []string{"status"}
+}
+
 // Operations returns list of operations handled by this validator.
 func (v *Validator) Operations() []admissionregistrationv1.OperationType {
 	return []admissionregistrationv1.OperationType{
@@ -75,6 +85,8 @@ func (v *Validator) Admitters() []admission.Admitter {
 type admitter struct {
 	clusterCache controllerv3.ClusterCache
 	userCache    controllerv3.UserCache
+	settingCache controllerv3.SettingCache
+	sar          authorizationv1.SubjectAccessReviewInterface
 }
 
 // Admit handles the webhook admission request sent to this webhook.
@@ -89,7 +101,7 @@ func (a *admitter) Admit(request *admission.Request) (*admissionv1.AdmissionResp
 
 	switch request.Operation {
 	case admissionv1.Create:
-		return a.admitCreate(newProject)
+		return a.admitCreate(request, newProject)
 	case admissionv1.Update:
 		return a.admitUpdate(oldProject, newProject)
 	case admissionv1.Delete:
@@ -106,7 +118,7 @@ func (a *admitter) admitDelete(project *v3.Project) (*admissionv1.AdmissionRespo
 	return admission.ResponseAllowed(), nil
 }
 
-func (a *admitter) admitCreate(project *v3.Project) (*admissionv1.AdmissionResponse, error) {
+func (a *admitter) admitCreate(request *admission.Request, project *v3.Project) (*admissionv1.AdmissionResponse, error) {
 	fieldErr, err := a.checkClusterExists(project)
 	if err != nil {
 		return nil, fmt.Errorf("error checking cluster name: %w", err)
@@ -117,6 +129,13 @@ func (a *admitter) admitCreate(project *v3.Project) (*admissionv1.AdmissionRespo
 	if fieldErr := common.CheckCreatorIDAndNoCreatorRBAC(project); fieldErr != nil {
 		return admission.ResponseBadRequest(fieldErr.Error()), nil
 	}
+	fieldErr, err = common.CheckNoCreatorRBACAllowed(request, a.sar, a.settingCache, nil, project)
+	if err != nil {
+		return nil, fmt.Errorf("error checking no-creator-rbac annotation: %w", err)
+	}
+	if fieldErr != nil {
+		return admission.ResponseBadRequest(fieldErr.Error()), nil
+	}
 	fieldErr, err = common.CheckCreatorPrincipalName(a.userCache, project)
 	if err != nil {
 		return nil, fmt.Errorf("error checking creator principal: %w", err)
@@ -129,9 +148,8 @@ func (a *admitter) admitCreate(project *v3.Project) (*admissionv1.AdmissionRespo
 }
 
 func (a *admitter) admitUpdate(oldProject, newProject *v3.Project) (*admissionv1.AdmissionResponse, error) {
-	if oldProject.Spec.ClusterName != newProject.Spec.ClusterName {
-		fieldErr := field.Invalid(projectSpecFieldPath.Child(clusterNameField), newProject.Spec.ClusterName, "field is immutable")
-		return admission.ResponseBadRequest(fieldErr.Error()), nil
+	if errs := immutableFields.Validate(field.NewPath("project"), oldProject, newProject); len(errs) > 0 {
+		return admission.ResponseBadRequest(errs.ToAggregate().Error()), nil
 	}
 
 	if fieldErr := common.CheckCreatorAnnotationsOnUpdate(oldProject, newProject); fieldErr != nil {
@@ -146,9 +164,12 @@ func (a *admitter) admitCommonCreateUpdate(oldProject, newProject *v3.Project) (
 	projectQuota := newProject.Spec.ResourceQuota
 	nsQuota := newProject.Spec.NamespaceDefaultResourceQuota
 	containerLimit := newProject.Spec.ContainerDefaultResourceLimit
-	if fieldErr := a.validateContainerDefaultResourceLimit(containerLimit); fieldErr != nil {
+	if fieldErr := validateContainerDefaultResourceLimit(containerLimit); fieldErr != nil {
 		return admission.ResponseBadRequest(fieldErr.Error()), nil
 	}
+	if errs := validateNamespaceMetadataTemplate(newProject); len(errs) > 0 {
+		return admission.ResponseBadRequest(errs.ToAggregate().Error()), nil
+	}
 	if projectQuota == nil && nsQuota == nil {
 		return admission.ResponseAllowed(), nil
 	}
@@ -169,10 +190,24 @@ func (a *admitter) admitCommonCreateUpdate(oldProject, newProject *v3.Project) (
 	return admission.ResponseAllowed(), nil
 }
 
+// validateNamespaceMetadataTemplate checks that the namespace metadata template annotation of the project, if set, can
+// be parsed and only holds valid labels and annotations which aren't reserved.
+func validateNamespaceMetadataTemplate(project *v3.Project) field.ErrorList {
+	fieldPath := field.NewPath("project", "metadata", "annotations").Key(common.NamespaceMetadataTemplateAnn)
+	template, err := common.GetNamespaceMetadataTemplate(project)
+	if err != nil {
+		return field.ErrorList{field.Invalid(fieldPath, project.Annotations[common.NamespaceMetadataTemplateAnn], fmt.Sprintf("failed to parse namespace metadata template: %s", err))}
+	}
+	if template == nil {
+		return nil
+	}
+	return template.Validate(fieldPath)
+}
+
 // validateContainerDefaultResourceLimit checks all resource requests and limits.
 // It returns a fieldError. If the method is ever changed to also return a regular error, the caller's logic
 // needs to be updated to act appropriately based on the kind of error.
-func (a *admitter) validateContainerDefaultResourceLimit(limit *v3.ContainerResourceLimit) error {
+func validateContainerDefaultResourceLimit(limit *v3.ContainerResourceLimit) error {
 	if limit == nil {
 		return nil
 	}
@@ -269,48 +304,37 @@ func (a *admitter) checkQuotaValues(nsQuota, projectQuota *v3.ResourceQuotaLimit
 }
 
 func namespaceQuotaFits(namespaceQuota, projectQuota *v3.ResourceQuotaLimit) (*field.Error, error) {
-	namespaceQuotaResourceList, err := convertLimitToResourceList(namespaceQuota)
+	namespaceQuotaResourceList, err := quota.FromLimit(namespaceQuota)
 	if err != nil {
 		return nil, err
 	}
-	projectQuotaResourceList, err := convertLimitToResourceList(projectQuota)
+	projectQuotaResourceList, err := quota.FromLimit(projectQuota)
 	if err != nil {
 		return nil, err
 	}
-	fits, exceeded := quotaFits(namespaceQuotaResourceList, projectQuotaResourceList)
+	fits, exceeded := quota.Fits(namespaceQuotaResourceList, projectQuotaResourceList)
 	if !fits {
-		return field.Forbidden(projectSpecFieldPath.Child(namespaceQuotaField), fmt.Sprintf("namespace default quota limit exceeds project limit on fields: %s", formatResourceList(exceeded))), nil
+		return field.Forbidden(projectSpecFieldPath.Child(namespaceQuotaField), fmt.Sprintf("namespace default quota limit exceeds project limit on fields: %s", quota.Format(exceeded))), nil
 	}
 	return nil, nil
 }
 
 func usedQuotaFits(usedQuota, projectQuota *v3.ResourceQuotaLimit) (*field.Error, error) {
-	usedQuotaResourceList, err := convertLimitToResourceList(usedQuota)
+	usedQuotaResourceList, err := quota.FromLimit(usedQuota)
 	if err != nil {
 		return nil, err
 	}
-	projectQuotaResourceList, err := convertLimitToResourceList(projectQuota)
+	projectQuotaResourceList, err := quota.FromLimit(projectQuota)
 	if err != nil {
 		return nil, err
 	}
-	fits, exceeded := quotaFits(usedQuotaResourceList, projectQuotaResourceList)
+	fits, exceeded := quota.Fits(usedQuotaResourceList, projectQuotaResourceList)
 	if !fits {
-		return field.Forbidden(projectSpecFieldPath.Child(projectQuotaField), fmt.Sprintf("resourceQuota is below the used limit on fields: %s", formatResourceList(exceeded))), nil
+		return field.Forbidden(projectSpecFieldPath.Child(projectQuotaField), fmt.Sprintf("resourceQuota is below the used limit on fields: %s", quota.Format(exceeded))), nil
 	}
 	return nil, nil
 }
 
-// directly copied from https://github.com/kubernetes/kubernetes/blob/a66aad2d80dacc70025f95a8f97d2549ebd3208c/pkg/kubelet/util/format/resources.go
-func formatResourceList(resources v1.ResourceList) string {
-	resourceStrings := make([]string, 0, len(resources))
-	for key, value := range resources {
-		resourceStrings = append(resourceStrings, fmt.Sprintf("%v=%v", key, value.String()))
-	}
-	// sort the results for consistent log output
-	sort.Strings(resourceStrings)
-	return strings.Join(resourceStrings, ",")
-}
-
 func parseResource(s string) (*resource.Quantity, error) {
 	if s == "" {
 		// Upstream `resource.ParseQuantity` will return an error when given an empty string.
@@ -12,12 +12,16 @@ import (
 	"github.com/rancher/webhook/pkg/resources/common"
 	"github.com/rancher/wrangler/v3/pkg/generic/fake"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 	"go.uber.org/mock/gomock"
 	admissionv1 "k8s.io/api/admission/v1"
+	authorizationv1 "k8s.io/api/authorization/v1"
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/runtime/schema"
+	k8fake "k8s.io/client-go/kubernetes/typed/authorization/v1/fake"
+	k8testing "k8s.io/client-go/testing"
 )
 
 func TestProjectValidation(t *testing.T) {
@@ -25,6 +29,9 @@ func TestProjectValidation(t *testing.T) {
 	type testState struct {
 		clusterCache *fake.MockNonNamespacedCacheInterface[*v3.Cluster]
 		userCache    *fake.MockNonNamespacedCacheInterface[*v3.User]
+		settingCache *fake.MockNonNamespacedCacheInterface[*v3.Setting]
+		// isAdmin is the result of the SubjectAccessReview used to check if the user is an administrator.
+		isAdmin bool
 	}
 	tests := []struct {
 		name        string
@@ -423,6 +430,7 @@ func TestProjectValidation(t *testing.T) {
 				},
 			},
 			stateSetup: func(state *testState) {
+				state.isAdmin = true
 				state.clusterCache.EXPECT().Get("testcluster").Return(&v3.Cluster{
 					ObjectMeta: metav1.ObjectMeta{
 						Name: "testcluster",
@@ -431,6 +439,83 @@ func TestProjectValidation(t *testing.T) {
 			},
 			wantAllowed: true,
 		},
+		{
+			name:      "create with no-creator-rbac annotation by non-admin",
+			operation: admissionv1.Create,
+			newProject: &v3.Project{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "test",
+					Namespace: "testcluster",
+					Annotations: map[string]string{
+						common.NoCreatorRBACAnn: "true",
+					},
+				},
+				Spec: v3.ProjectSpec{
+					ClusterName: "testcluster",
+				},
+			},
+			stateSetup: func(state *testState) {
+				state.clusterCache.EXPECT().Get("testcluster").Return(&v3.Cluster{
+					ObjectMeta: metav1.ObjectMeta{
+						Name: "testcluster",
+					},
+				}, nil)
+			},
+			wantAllowed: false,
+		},
+		{
+			name:      "create with no-creator-rbac annotation by non-admin in a no-creator-rbac namespace",
+			operation: admissionv1.Create,
+			newProject: &v3.Project{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "test",
+					Namespace: "testcluster",
+					Annotations: map[string]string{
+						common.NoCreatorRBACAnn: "true",
+					},
+				},
+				Spec: v3.ProjectSpec{
+					ClusterName: "testcluster",
+				},
+			},
+			stateSetup: func(state *testState) {
+				state.clusterCache.EXPECT().Get("testcluster").Return(&v3.Cluster{
+					ObjectMeta: metav1.ObjectMeta{
+						Name: "testcluster",
+					},
+				}, nil)
+				state.settingCache.EXPECT().Get(common.NoCreatorRBACNamespacesSetting).Return(&v3.Setting{
+					ObjectMeta: metav1.ObjectMeta{Name: common.NoCreatorRBACNamespacesSetting},
+					Value:      "testcluster",
+				}, nil)
+			},
+			wantAllowed: true,
+		},
+		{
+			name:      "create with no-creator-rbac annotation and failure to get setting",
+			operation: admissionv1.Create,
+			newProject: &v3.Project{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "test",
+					Namespace: "testcluster",
+					Annotations: map[string]string{
+						common.NoCreatorRBACAnn: "true",
+					},
+				},
+				Spec: v3.ProjectSpec{
+					ClusterName: "testcluster",
+				},
+			},
+			stateSetup: func(state *testState) {
+				state.clusterCache.EXPECT().Get("testcluster").Return(&v3.Cluster{
+					ObjectMeta: metav1.ObjectMeta{
+						Name: "testcluster",
+					},
+				}, nil)
+				state.settingCache.EXPECT().Get(common.NoCreatorRBACNamespacesSetting).Return(nil, fmt.Errorf("server not available"))
+			},
+			wantErr: true,
+		},
 		{
 			name:      "create with no-creator-rbac and creatorID annotation",
 			operation: admissionv1.Create,
@@ -1327,13 +1412,24 @@ func TestProjectValidation(t *testing.T) {
 			state := testState{
 				clusterCache: fake.NewMockNonNamespacedCacheInterface[*v3.Cluster](ctrl),
 				userCache:    fake.NewMockNonNamespacedCacheInterface[*v3.User](ctrl),
+				settingCache: fake.NewMockNonNamespacedCacheInterface[*v3.Setting](ctrl),
 			}
 			if test.stateSetup != nil {
 				test.stateSetup(&state)
 			}
+			state.settingCache.EXPECT().Get(gomock.Any()).DoAndReturn(func(name string) (*v3.Setting, error) {
+				return nil, apierrors.NewNotFound(schema.GroupResource{}, name)
+			}).AnyTimes()
+			k8Fake := &k8testing.Fake{}
+			k8Fake.AddReactor("create", "subjectaccessreviews", func(action k8testing.Action) (bool, runtime.Object, error) {
+				review := action.(k8testing.CreateActionImpl).GetObject().(*authorizationv1.SubjectAccessReview)
+				review.Status.Allowed = state.isAdmin
+				return true, review, nil
+			})
+			sar := &k8fake.FakeSubjectAccessReviews{Fake: &k8fake.FakeAuthorizationV1{Fake: k8Fake}}
 			req, err := createProjectRequest(test.oldProject, test.newProject, test.operation, false)
 			assert.NoError(t, err)
-			validator := NewValidator(state.clusterCache, state.userCache)
+			validator := NewValidator(state.clusterCache, state.userCache, state.settingCache, sar)
 			admitters := validator.Admitters()
 			assert.Len(t, admitters, 1)
 			response, err := admitters[0].Admit(req)
@@ -1536,7 +1632,7 @@ func TestProjectContainerDefaultLimitsValidation(t *testing.T) {
 				}
 				req, err := createProjectRequest(oldProject, newProject, test.operation, false)
 				assert.NoError(t, err)
-				validator := NewValidator(state.clusterCache, nil)
+				validator := NewValidator(state.clusterCache, nil, nil, nil)
 				admitters := validator.Admitters()
 				assert.Len(t, admitters, 1)
 				response, err := admitters[0].Admit(req)
@@ -1547,6 +1643,81 @@ func TestProjectContainerDefaultLimitsValidation(t *testing.T) {
 	}
 }
 
+func TestProjectNamespaceMetadataTemplateValidation(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name        string
+		template    *string
+		wantAllowed bool
+	}{
+		{
+			name:        "no template",
+			wantAllowed: true,
+		},
+		{
+			name:        "valid template",
+			template:    admission.Ptr(`{"labels":{"team":"a"},"annotations":{"example.com/owner":"team-a"}}`),
+			wantAllowed: true,
+		},
+		{
+			name:     "malformed template",
+			template: admission.Ptr(`labels: team`),
+		},
+		{
+			name:     "invalid label value",
+			template: admission.Ptr(`{"labels":{"team":"a b"}}`),
+		},
+		{
+			name:     "reserved label",
+			template: admission.Ptr(`{"labels":{"pod-security.kubernetes.io/enforce":"privileged"}}`),
+		},
+		{
+			name:     "reserved annotation",
+			template: admission.Ptr(`{"annotations":{"field.cattle.io/projectId":"c-123xyz:p-abc"}}`),
+		},
+	}
+
+	for _, test := range tests {
+		for _, operation := range []admissionv1.Operation{admissionv1.Create, admissionv1.Update} {
+			test := test
+			operation := operation
+			name := fmt.Sprintf("%s on %s", test.name, strings.ToLower(string(operation)))
+			t.Run(name, func(t *testing.T) {
+				t.Parallel()
+				oldProject := &v3.Project{
+					ObjectMeta: metav1.ObjectMeta{
+						Name:      "test",
+						Namespace: "testcluster",
+					},
+					Spec: v3.ProjectSpec{
+						ClusterName: "testcluster",
+					},
+				}
+				newProject := oldProject.DeepCopy()
+				if test.template != nil {
+					newProject.Annotations = map[string]string{common.NamespaceMetadataTemplateAnn: *test.template}
+				}
+				ctrl := gomock.NewController(t)
+				clusterCache := fake.NewMockNonNamespacedCacheInterface[*v3.Cluster](ctrl)
+				if operation == admissionv1.Create {
+					oldProject = nil
+					clusterCache.EXPECT().Get("testcluster").Return(&v3.Cluster{
+						ObjectMeta: metav1.ObjectMeta{
+							Name: "testcluster",
+						},
+					}, nil)
+				}
+				req, err := createProjectRequest(oldProject, newProject, operation, false)
+				require.NoError(t, err)
+				validator := NewValidator(clusterCache, nil, nil, nil)
+				response, err := validator.Admitters()[0].Admit(req)
+				require.NoError(t, err)
+				assert.Equal(t, test.wantAllowed, response.Allowed, response.Result)
+			})
+		}
+	}
+}
+
 func createProjectRequest(oldProject, newProject *v3.Project, operation admissionv1.Operation, dryRun bool) (*admission.Request, error) {
 	gvk := metav1.GroupVersionKind{Group: "management.cattle.io", Version: "v3", Kind: "Project"}
 	gvr := metav1.GroupVersionResource{Group: "management.cattle.io", Version: "v3", Resource: "projects"}
@@ -1568,6 +1739,7 @@ func createProjectRequest(oldProject, newProject *v3.Project, operation admissio
 	}
 	if newProject != nil {
 		var err error
+		req.Namespace = newProject.Namespace
 		req.Object.Raw, err = json.Marshal(newProject)
 		if err != nil {
 			return nil, err
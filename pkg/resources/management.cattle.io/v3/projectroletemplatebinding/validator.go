@@ -12,9 +12,12 @@ import (
 	v3 "github.com/rancher/webhook/pkg/generated/controllers/management.cattle.io/v3"
 	objectsv3 "github.com/rancher/webhook/pkg/generated/objects/management.cattle.io/v3"
 	"github.com/rancher/webhook/pkg/resolvers"
+	"github.com/rancher/webhook/pkg/resources/common"
+	"github.com/sirupsen/logrus"
 	admissionv1 "k8s.io/api/admission/v1"
 	admissionregistrationv1 "k8s.io/api/admissionregistration/v1"
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime/schema"
 	"k8s.io/apimachinery/pkg/util/validation/field"
 	k8validation "k8s.io/kubernetes/pkg/registry/rbac/validation"
@@ -28,18 +31,25 @@ var gvr = schema.GroupVersionResource{
 }
 
 // NewValidator returns a new validator used for validation PRTB.
+// The number of projects a user can be bound into is only limited when settingCache is not nil, in which case the PRTB
+// cache of the resolver must be indexed by common.PRTBByUserIndex, as it is by the user validator.
 func NewValidator(prtb *resolvers.PRTBRuleResolver, crtb *resolvers.CRTBRuleResolver,
 	defaultResolver k8validation.AuthorizationRuleResolver, roleTemplateResolver *auth.RoleTemplateResolver,
-	clusterCache v3.ClusterCache, projectCache v3.ProjectCache) *Validator {
+	clusterCache v3.ClusterCache, projectCache v3.ProjectCache, settingCache v3.SettingCache,
+	subjectValidator *common.BindingSubjectValidator) *Validator {
 	clusterResolver := resolvers.NewAggregateRuleResolver(defaultResolver, crtb)
 	projectResolver := resolvers.NewAggregateRuleResolver(defaultResolver, prtb)
 	return &Validator{
 		admitter: admitter{
 			clusterResolver:      clusterResolver,
 			projectResolver:      projectResolver,
+			defaultResolver:      defaultResolver,
+			prtbResolver:         prtb,
 			roleTemplateResolver: roleTemplateResolver,
 			clusterCache:         clusterCache,
 			projectCache:         projectCache,
+			settingCache:         settingCache,
+			subjectValidator:     subjectValidator,
 		},
 	}
 }
@@ -72,9 +82,13 @@ func (v *Validator) Admitters() []admission.Admitter {
 type admitter struct {
 	clusterResolver      k8validation.AuthorizationRuleResolver
 	projectResolver      k8validation.AuthorizationRuleResolver
+	defaultResolver      k8validation.AuthorizationRuleResolver
+	prtbResolver         *resolvers.PRTBRuleResolver
 	roleTemplateResolver *auth.RoleTemplateResolver
 	clusterCache         v3.ClusterCache
 	projectCache         v3.ProjectCache
+	settingCache         v3.SettingCache
+	subjectValidator     *common.BindingSubjectValidator
 }
 
 // Admit is the entrypoint for the validator. Admit will return an error if it's unable to process the request.
@@ -83,6 +97,10 @@ func (a *admitter) Admit(request *admission.Request) (*admissionv1.AdmissionResp
 	listTrace := trace.New("projectRoleTemplateBindingValidator Admit", trace.Field{Key: "user", Value: request.UserInfo.Username})
 	defer listTrace.LogIfLong(admission.SlowTraceDuration)
 
+	if response, err := admission.DisallowUnknownFields(request, &apisv3.ProjectRoleTemplateBinding{}); err != nil || response != nil {
+		return response, err
+	}
+
 	fieldPath := field.NewPath("projectroletemplatebinding")
 
 	if request.Operation == admissionv1.Update {
@@ -108,6 +126,12 @@ func (a *admitter) Admit(request *admission.Request) (*admissionv1.AdmissionResp
 			}
 			return nil, fmt.Errorf("failed to validate fields on create: %w", err)
 		}
+		if err := a.validateProjectQuota(request, prtb, fieldPath); err != nil {
+			if errors.As(err, &fieldErr) {
+				return admission.ResponseBadRequest(err.Error()), nil
+			}
+			return nil, fmt.Errorf("failed to validate project quota: %w", err)
+		}
 	}
 
 	roleTemplate, err := a.roleTemplateResolver.RoleTemplateCache().Get(prtb.RoleTemplateName)
@@ -131,12 +155,32 @@ func (a *admitter) Admit(request *admission.Request) (*admissionv1.AdmissionResp
 		return &admissionv1.AdmissionResponse{Allowed: true}, nil
 	}
 
+	projectResolver := a.projectResolver
+	if grantsRequesterGroup(request, prtb) {
+		// The requester is a member of the group being bound, so the rules derived from this binding must not count
+		// towards the requester's own access. Otherwise, a user could grant their own group elevated rights.
+		projectResolver = resolvers.NewAggregateRuleResolver(a.defaultResolver, a.prtbResolver.ExcludingBinding(prtb.Namespace, prtb.Name))
+	}
+
 	response := &admissionv1.AdmissionResponse{}
-	auth.SetEscalationResponse(response, auth.ConfirmNoEscalation(request, rules, projectNS, a.projectResolver))
+	auth.SetEscalationResponse(response, auth.ConfirmNoEscalation(request, rules, projectNS, projectResolver))
 
 	return response, nil
 }
 
+// grantsRequesterGroup returns true if the PRTB binds a group that the requesting user is a member of.
+func grantsRequesterGroup(request *admission.Request, prtb *apisv3.ProjectRoleTemplateBinding) bool {
+	if prtb.GroupName == "" && prtb.GroupPrincipalName == "" {
+		return false
+	}
+	for _, group := range request.UserInfo.Groups {
+		if group == prtb.GroupName || group == prtb.GroupPrincipalName {
+			return true
+		}
+	}
+	return false
+}
+
 func clusterAndProjectID(projectName string) (string, string) {
 	pieces := strings.Split(projectName, ":")
 	if len(pieces) < 2 {
@@ -145,30 +189,28 @@ func clusterAndProjectID(projectName string) (string, string) {
 	return pieces[0], pieces[1]
 }
 
+// immutableFields are the fields of PRTBs which can't be updated. The subject of the binding can only be set once, so
+// bindings created with only a principal name can be completed with the matching name.
+var immutableFields = common.NewImmutableFields(
+	common.ImmutableField{Path: "roleTemplateName"},
+	common.ImmutableField{Path: "projectName"},
+	common.ImmutableField{Path: "userName", SettableOnce: true},
+	common.ImmutableField{Path: "userPrincipalName", SettableOnce: true},
+	common.ImmutableField{Path: "groupName", SettableOnce: true},
+	common.ImmutableField{Path: "groupPrincipalName", SettableOnce: true},
+	common.ImmutableField{Path: "serviceAccount"},
+)
+
 // validUpdateFields checks if the fields being changed are valid update fields.
 func validateUpdateFields(oldPRTB, newPRTB *apisv3.ProjectRoleTemplateBinding, fieldPath *field.Path) *field.Error {
-	const reason = "field is immutable"
-	switch {
-	case oldPRTB.RoleTemplateName != newPRTB.RoleTemplateName:
-		return field.Invalid(fieldPath.Child("roleTemplateName"), newPRTB.RoleTemplateName, reason)
-	case oldPRTB.ProjectName != newPRTB.ProjectName:
-		return field.Invalid(fieldPath.Child("projectName"), newPRTB.ProjectName, reason)
-	case oldPRTB.UserName != newPRTB.UserName && oldPRTB.UserName != "":
-		return field.Invalid(fieldPath.Child("userName"), newPRTB.UserName, reason)
-	case oldPRTB.UserPrincipalName != newPRTB.UserPrincipalName && oldPRTB.UserPrincipalName != "":
-		return field.Invalid(fieldPath.Child("userPrincipalName"), newPRTB.UserPrincipalName, reason)
-	case oldPRTB.GroupName != newPRTB.GroupName && oldPRTB.GroupName != "":
-		return field.Invalid(fieldPath.Child("groupName"), newPRTB.GroupName, reason)
-	case oldPRTB.GroupPrincipalName != newPRTB.GroupPrincipalName && oldPRTB.GroupPrincipalName != "":
-		return field.Invalid(fieldPath.Child("groupPrincipalName"), newPRTB.GroupPrincipalName, reason)
-	case (newPRTB.GroupName != "" || oldPRTB.GroupPrincipalName != "") && (newPRTB.UserName != "" || oldPRTB.UserPrincipalName != ""):
+	if errs := immutableFields.Validate(fieldPath, oldPRTB, newPRTB); len(errs) > 0 {
+		return errs[0]
+	}
+	if (newPRTB.GroupName != "" || oldPRTB.GroupPrincipalName != "") && (newPRTB.UserName != "" || oldPRTB.UserPrincipalName != "") {
 		return field.Forbidden(fieldPath,
 			"binding must target either a user [userName]/[userPrincipalName] OR a group [groupName]/[groupPrincipalName]")
-	case oldPRTB.ServiceAccount != newPRTB.ServiceAccount:
-		return field.Forbidden(fieldPath.Child("serviceAccount"), "update is not allowed")
-	default:
-		return nil
 	}
+	return nil
 }
 
 // validateCreateFields checks if all required fields are present and valid.
@@ -182,6 +224,13 @@ func (a *admitter) validateCreateFields(newPRTB *apisv3.ProjectRoleTemplateBindi
 			"binding must target only a user [userName]/[userPrincipalName] OR a group [groupName]/[groupPrincipalName] OR a [serviceAccount]")
 	}
 
+	if fieldErr, err := a.subjectValidator.Validate(newPRTB.UserName, newPRTB.GroupPrincipalName, fieldPath); err != nil || fieldErr != nil {
+		if err != nil {
+			return fmt.Errorf("unable to verify the subject of the binding: %w", err)
+		}
+		return fieldErr
+	}
+
 	if newPRTB.ProjectName == "" {
 		return field.Required(fieldPath.Child("projectName"), "")
 	}
@@ -243,6 +292,44 @@ func (a *admitter) validateCreateFields(newPRTB *apisv3.ProjectRoleTemplateBindi
 	return nil
 }
 
+// validateProjectQuota checks that binding the user into the project of the new PRTB doesn't exceed the number of projects
+// a user can be bound into, set by the max-projects-per-user setting. Bindings created by controllers are not checked,
+// and bindings owned by a controller are not counted towards the limit.
+func (a *admitter) validateProjectQuota(request *admission.Request, newPRTB *apisv3.ProjectRoleTemplateBinding, fieldPath *field.Path) error {
+	if a.settingCache == nil || newPRTB.UserName == "" || admission.IsController(request) {
+		return nil
+	}
+	value, err := common.GetSettingValue(a.settingCache, common.MaxProjectsPerUserSetting)
+	if err != nil {
+		return err
+	}
+	limit, err := common.ParseMaxProjectsPerUser(value)
+	if err != nil {
+		// the setting validator rejects invalid values, don't block bindings if one got through
+		logrus.Warnf("[projectRoleTemplateBindingValidator] ignoring invalid %s setting: %v", common.MaxProjectsPerUserSetting, err)
+		return nil
+	}
+	if limit == 0 {
+		return nil
+	}
+
+	prtbs, err := a.prtbResolver.ProjectRoleTemplateBindings.GetByIndex(common.PRTBByUserIndex, newPRTB.UserName)
+	if err != nil {
+		return fmt.Errorf("failed to list PRTBs of user %s: %w", newPRTB.UserName, err)
+	}
+	projects := map[string]bool{}
+	for _, prtb := range prtbs {
+		if metav1.GetControllerOf(prtb) == nil {
+			projects[prtb.ProjectName] = true
+		}
+	}
+	if projects[newPRTB.ProjectName] || len(projects) < limit {
+		return nil
+	}
+	return field.Forbidden(fieldPath, fmt.Sprintf("the user is already bound into %d projects, the maximum set by the %s setting",
+		len(projects), common.MaxProjectsPerUserSetting))
+}
+
 func onlyOneTrue(values ...bool) bool {
 	var trueCount int
 	for _, v := range values {
@@ -11,6 +11,7 @@ import (
 	"github.com/rancher/webhook/pkg/admission"
 	"github.com/rancher/webhook/pkg/auth"
 	"github.com/rancher/webhook/pkg/resolvers"
+	"github.com/rancher/webhook/pkg/resources/common"
 	"github.com/rancher/webhook/pkg/resources/management.cattle.io/v3/projectroletemplatebinding"
 	"github.com/rancher/wrangler/v3/pkg/generic/fake"
 	"github.com/stretchr/testify/assert"
@@ -220,7 +221,7 @@ func (p *ProjectRoleTemplateBindingSuite) TestPrivilegeEscalation() {
 			ClusterName: clusterID,
 		},
 	}, nil).AnyTimes()
-	validator := projectroletemplatebinding.NewValidator(prtbResolver, crtbResolver, resolver, roleResolver, clusterCache, projectCache)
+	validator := projectroletemplatebinding.NewValidator(prtbResolver, crtbResolver, resolver, roleResolver, clusterCache, projectCache, nil, nil)
 	type args struct {
 		oldPRTB  func() *apisv3.ProjectRoleTemplateBinding
 		newPRTB  func() *apisv3.ProjectRoleTemplateBinding
@@ -345,6 +346,212 @@ func (p *ProjectRoleTemplateBindingSuite) TestPrivilegeEscalation() {
 	}
 }
 
+func (p *ProjectRoleTemplateBindingSuite) TestSelfGroupEscalation() {
+	const (
+		testUser    = "test-userid"
+		selfGroup   = "self-group"
+		adminGroup  = "admin-group"
+		otherGroup  = "other-group"
+		pendingName = "PRTB-new"
+	)
+	resolver, _ := validation.NewTestRuleResolver(nil, nil, nil, nil)
+
+	ctrl := gomock.NewController(p.T())
+	roleTemplateCache := fake.NewMockNonNamespacedCacheInterface[*apisv3.RoleTemplate](ctrl)
+	roleTemplateCache.EXPECT().Get(p.adminRT.Name).Return(p.adminRT, nil).AnyTimes()
+	clusterRoleCache := fake.NewMockNonNamespacedCacheInterface[*rbacv1.ClusterRole](ctrl)
+	roleResolver := auth.NewRoleTemplateResolver(roleTemplateCache, clusterRoleCache)
+	prtbCache := fake.NewMockCacheInterface[*apisv3.ProjectRoleTemplateBinding](ctrl)
+	prtbCache.EXPECT().AddIndexer(gomock.Any(), gomock.Any())
+	// the binding under review already exists and grants admin to selfGroup
+	prtbCache.EXPECT().GetByIndex(gomock.Any(), resolvers.GetGroupKey(selfGroup, projectID)).Return([]*apisv3.ProjectRoleTemplateBinding{
+		{
+			ObjectMeta:       metav1.ObjectMeta{Name: pendingName, Namespace: projectID},
+			GroupName:        selfGroup,
+			RoleTemplateName: p.adminRT.Name,
+		},
+	}, nil).AnyTimes()
+	prtbCache.EXPECT().GetByIndex(gomock.Any(), resolvers.GetGroupKey(adminGroup, projectID)).Return([]*apisv3.ProjectRoleTemplateBinding{
+		{
+			ObjectMeta:       metav1.ObjectMeta{Name: "PRTB-admin", Namespace: projectID},
+			GroupName:        adminGroup,
+			RoleTemplateName: p.adminRT.Name,
+		},
+	}, nil).AnyTimes()
+	prtbCache.EXPECT().GetByIndex(gomock.Any(), gomock.Any()).Return(nil, nil).AnyTimes()
+	crtbCache := fake.NewMockCacheInterface[*apisv3.ClusterRoleTemplateBinding](ctrl)
+	crtbCache.EXPECT().AddIndexer(gomock.Any(), gomock.Any())
+	crtbCache.EXPECT().GetByIndex(gomock.Any(), gomock.Any()).Return(nil, nil).AnyTimes()
+
+	crtbResolver := resolvers.NewCRTBRuleResolver(crtbCache, roleResolver)
+	prtbResolver := resolvers.NewPRTBRuleResolver(prtbCache, roleResolver)
+	validator := projectroletemplatebinding.NewValidator(prtbResolver, crtbResolver, resolver, roleResolver, nil, nil, nil, nil)
+
+	newGroupPRTB := func(group string) *apisv3.ProjectRoleTemplateBinding {
+		basePRTB := newBasePRTB()
+		basePRTB.UserName = ""
+		basePRTB.GroupName = group
+		basePRTB.RoleTemplateName = p.adminRT.Name
+		return basePRTB
+	}
+
+	tests := []struct {
+		name    string
+		groups  []string
+		subject string
+		allowed bool
+	}{
+		{
+			name:    "access only from the binding being reviewed",
+			groups:  []string{selfGroup},
+			subject: selfGroup,
+			allowed: false,
+		},
+		{
+			name:    "access from another binding of a group the user belongs to",
+			groups:  []string{selfGroup, adminGroup},
+			subject: selfGroup,
+			allowed: true,
+		},
+		{
+			name:    "binding for a group the user does not belong to",
+			groups:  []string{selfGroup},
+			subject: otherGroup,
+			allowed: true,
+		},
+	}
+
+	for i := range tests {
+		test := tests[i]
+		p.Run(test.name, func() {
+			p.T().Parallel()
+			oldPRTB := newGroupPRTB(test.subject)
+			newPRTB := newGroupPRTB(test.subject)
+			newPRTB.Labels = map[string]string{"updated": "true"}
+			req := createPRTBRequest(p.T(), oldPRTB, newPRTB, testUser, test.groups...)
+			resp, err := validator.Admitters()[0].Admit(req)
+			p.NoError(err, "Admit failed")
+			if resp.Allowed != test.allowed {
+				p.Failf("Response was incorrectly validated", "Wanted response.Allowed = '%v' got %v: result=%+v", test.allowed, resp.Allowed, resp.Result)
+			}
+		})
+	}
+}
+
+func (p *ProjectRoleTemplateBindingSuite) TestProjectQuota() {
+	const (
+		adminUser   = "admin-userid"
+		fullUser    = "full-user"
+		memberUser  = "member-user"
+		systemUser  = "system-user"
+		noLimitUser = "no-limit-user"
+	)
+	binding := func(project string, owners []metav1.OwnerReference) *apisv3.ProjectRoleTemplateBinding {
+		return &apisv3.ProjectRoleTemplateBinding{
+			ObjectMeta:  metav1.ObjectMeta{Name: "prtb-" + project, Namespace: project, OwnerReferences: owners},
+			ProjectName: clusterID + ":" + project,
+		}
+	}
+	resolver, _ := validation.NewTestRuleResolver(nil, nil, []*rbacv1.ClusterRole{p.adminCR}, []*rbacv1.ClusterRoleBinding{
+		{
+			Subjects: []rbacv1.Subject{{Kind: rbacv1.UserKind, Name: adminUser}},
+			RoleRef:  rbacv1.RoleRef{APIGroup: rbacv1.GroupName, Kind: "ClusterRole", Name: p.adminCR.Name},
+		},
+	})
+
+	ctrl := gomock.NewController(p.T())
+	roleTemplateCache := fake.NewMockNonNamespacedCacheInterface[*apisv3.RoleTemplate](ctrl)
+	roleTemplateCache.EXPECT().Get(p.adminRT.Name).Return(p.adminRT, nil).AnyTimes()
+	clusterRoleCache := fake.NewMockNonNamespacedCacheInterface[*rbacv1.ClusterRole](ctrl)
+	roleResolver := auth.NewRoleTemplateResolver(roleTemplateCache, clusterRoleCache)
+	prtbCache := fake.NewMockCacheInterface[*apisv3.ProjectRoleTemplateBinding](ctrl)
+	prtbCache.EXPECT().AddIndexer(gomock.Any(), gomock.Any())
+	prtbCache.EXPECT().GetByIndex(common.PRTBByUserIndex, fullUser).Return([]*apisv3.ProjectRoleTemplateBinding{
+		binding("p-1", nil), binding("p-2", nil),
+	}, nil).AnyTimes()
+	prtbCache.EXPECT().GetByIndex(common.PRTBByUserIndex, memberUser).Return([]*apisv3.ProjectRoleTemplateBinding{
+		binding("p-1", nil), binding(projectID, nil),
+	}, nil).AnyTimes()
+	prtbCache.EXPECT().GetByIndex(common.PRTBByUserIndex, systemUser).Return([]*apisv3.ProjectRoleTemplateBinding{
+		binding("p-1", nil), binding("p-2", []metav1.OwnerReference{{
+			APIVersion: "management.cattle.io/v3", Kind: "Project", Name: "p-2", Controller: admission.Ptr(true),
+		}}),
+	}, nil).AnyTimes()
+	prtbCache.EXPECT().GetByIndex(gomock.Any(), gomock.Any()).Return(nil, nil).AnyTimes()
+	crtbCache := fake.NewMockCacheInterface[*apisv3.ClusterRoleTemplateBinding](ctrl)
+	crtbCache.EXPECT().AddIndexer(gomock.Any(), gomock.Any())
+	crtbCache.EXPECT().GetByIndex(gomock.Any(), gomock.Any()).Return(nil, nil).AnyTimes()
+	clusterCache := fake.NewMockNonNamespacedCacheInterface[*apisv3.Cluster](ctrl)
+	clusterCache.EXPECT().Get(clusterID).Return(&apisv3.Cluster{ObjectMeta: metav1.ObjectMeta{Name: clusterID}}, nil).AnyTimes()
+	projectCache := fake.NewMockCacheInterface[*apisv3.Project](ctrl)
+	projectCache.EXPECT().Get(clusterID, projectID).Return(&apisv3.Project{
+		ObjectMeta: metav1.ObjectMeta{Namespace: clusterID, Name: projectID},
+		Spec:       apisv3.ProjectSpec{ClusterName: clusterID},
+	}, nil).AnyTimes()
+	settingCache := fake.NewMockNonNamespacedCacheInterface[*apisv3.Setting](ctrl)
+	settingCache.EXPECT().Get(common.MaxProjectsPerUserSetting).Return(&apisv3.Setting{Value: "2"}, nil).AnyTimes()
+
+	crtbResolver := resolvers.NewCRTBRuleResolver(crtbCache, roleResolver)
+	prtbResolver := resolvers.NewPRTBRuleResolver(prtbCache, roleResolver)
+	validator := projectroletemplatebinding.NewValidator(prtbResolver, crtbResolver, resolver, roleResolver, clusterCache, projectCache,
+		settingCache, nil)
+
+	tests := []struct {
+		name          string
+		userName      string
+		principalName string
+		groups        []string
+		allowed       bool
+	}{
+		{
+			name:     "user below the limit",
+			userName: noLimitUser,
+			allowed:  true,
+		},
+		{
+			name:     "user at the limit",
+			userName: fullUser,
+		},
+		{
+			name:          "user principal without a user is not checked",
+			principalName: "local://" + fullUser,
+			allowed:       true,
+		},
+		{
+			name:     "user already bound into the project",
+			userName: memberUser,
+			allowed:  true,
+		},
+		{
+			name:     "bindings owned by a controller are not counted",
+			userName: systemUser,
+			allowed:  true,
+		},
+		{
+			name:     "bindings created by controllers are not checked",
+			userName: fullUser,
+			groups:   []string{"system:serviceaccounts:cattle-system"},
+			allowed:  true,
+		},
+	}
+
+	for i := range tests {
+		test := tests[i]
+		p.Run(test.name, func() {
+			p.T().Parallel()
+			prtb := newBasePRTB()
+			prtb.UserName = test.userName
+			prtb.UserPrincipalName = test.principalName
+			req := createPRTBRequest(p.T(), nil, prtb, adminUser, test.groups...)
+			resp, err := validator.Admitters()[0].Admit(req)
+			p.Require().NoError(err, "Admit failed")
+			if resp.Allowed != test.allowed {
+				p.Failf("Response was incorrectly validated", "Wanted response.Allowed = '%v' got %v: result=%+v", test.allowed, resp.Allowed, resp.Result)
+			}
+		})
+	}
+}
+
 func (p *ProjectRoleTemplateBindingSuite) TestValidationOnUpdate() {
 	const (
 		adminUser    = "admin-userid"
@@ -395,7 +602,7 @@ func (p *ProjectRoleTemplateBindingSuite) TestValidationOnUpdate() {
 		},
 	}, nil).AnyTimes()
 
-	validator := projectroletemplatebinding.NewValidator(prtbResolver, crtbResolver, resolver, roleResolver, clusterCache, projectCache)
+	validator := projectroletemplatebinding.NewValidator(prtbResolver, crtbResolver, resolver, roleResolver, clusterCache, projectCache, nil, nil)
 	type args struct {
 		oldPRTB  func() *apisv3.ProjectRoleTemplateBinding
 		newPRTB  func() *apisv3.ProjectRoleTemplateBinding
@@ -814,7 +1021,7 @@ func (p *ProjectRoleTemplateBindingSuite) TestValidationOnCreate() {
 			},
 		}, nil).AnyTimes()
 
-		return projectroletemplatebinding.NewValidator(prtbResolver, crtbResolver, resolver, roleResolver, clusterCache, projectCache)
+		return projectroletemplatebinding.NewValidator(prtbResolver, crtbResolver, resolver, roleResolver, clusterCache, projectCache, nil, nil)
 	}
 
 	type args struct {
@@ -1237,7 +1444,7 @@ func (p *ProjectRoleTemplateBindingSuite) TestValidationOnCreate() {
 // createPRTBRequest will return a new webhookRequest with the using the given PRTBs
 // if oldPRTB is nil then a request will be returned as a create operation.
 // else the request will look like ana update operation.
-func createPRTBRequest(t *testing.T, oldPRTB, newPRTB *apisv3.ProjectRoleTemplateBinding, username string) *admission.Request {
+func createPRTBRequest(t *testing.T, oldPRTB, newPRTB *apisv3.ProjectRoleTemplateBinding, username string, groups ...string) *admission.Request {
 	t.Helper()
 	gvk := metav1.GroupVersionKind{Group: "management.cattle.io", Version: "v3", Kind: "ProjectRoleTemplateBinding"}
 	gvr := metav1.GroupVersionResource{Group: "management.cattle.io", Version: "v3", Resource: "projectroletemplatebindings"}
@@ -1251,7 +1458,7 @@ func createPRTBRequest(t *testing.T, oldPRTB, newPRTB *apisv3.ProjectRoleTemplat
 			Name:            newPRTB.Name,
 			Namespace:       newPRTB.Namespace,
 			Operation:       v1.Create,
-			UserInfo:        v1authentication.UserInfo{Username: username, UID: ""},
+			UserInfo:        v1authentication.UserInfo{Username: username, UID: "", Groups: groups},
 			Object:          runtime.RawExtension{},
 			OldObject:       runtime.RawExtension{},
 		},
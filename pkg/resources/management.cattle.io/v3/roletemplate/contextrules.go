@@ -0,0 +1,97 @@
+package roletemplate
+
+import (
+	"fmt"
+	"strings"
+
+	v3 "github.com/rancher/rancher/pkg/apis/management.cattle.io/v3"
+	"github.com/rancher/webhook/pkg/resources/common"
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+)
+
+// ContextRulesFeature is the name of the feature denying RoleTemplates whose rules grant resources which can't be
+// granted in the RoleTemplate's context.
+const ContextRulesFeature = "roletemplate-context-rules"
+
+// anyResource matches every resource of an API group in clusterOnlyResources and projectOnlyResources.
+const anyResource = "*"
+
+var (
+	// clusterOnlyResources are the resources, by API group, which aren't namespaced, or are only in the namespace of
+	// the cluster in the management cluster. Rules granting them in project RoleTemplates, which are bound in the
+	// namespaces of a project, grant nothing.
+	clusterOnlyResources = map[string][]string{
+		"":                             {"nodes", "persistentvolumes", "componentstatuses"},
+		"admissionregistration.k8s.io": {"mutatingwebhookconfigurations", "validatingwebhookconfigurations"},
+		"apiextensions.k8s.io":         {"customresourcedefinitions"},
+		"apiregistration.k8s.io":       {"apiservices"},
+		"certificates.k8s.io":          {"certificatesigningrequests"},
+		"node.k8s.io":                  {"runtimeclasses"},
+		"rbac.authorization.k8s.io":    {"clusterroles", "clusterrolebindings"},
+		"scheduling.k8s.io":            {"priorityclasses"},
+		"storage.k8s.io":               {"storageclasses", "csidrivers", "csinodes", "volumeattachments"},
+		"management.cattle.io":         {"clusterroletemplatebindings", "clusterregistrationtokens", "nodepools", "nodes"},
+	}
+	// projectOnlyResources are the resources, by API group, which are only in the namespaces of projects in the
+	// management cluster. Rules granting them in cluster RoleTemplates grant nothing.
+	projectOnlyResources = map[string][]string{
+		"project.cattle.io": {anyResource},
+	}
+)
+
+// validateContextRules checks that the rules of a RoleTemplate don't grant resources which can't be granted in its
+// context, when the ContextRulesFeature is enabled. Builtin RoleTemplates, wildcards and rules which weren't changed by
+// an update are not checked.
+func (a *admitter) validateContextRules(oldRT, newRT *v3.RoleTemplate, fldPath *field.Path) (field.ErrorList, error) {
+	if a.featureCache == nil || newRT.Builtin {
+		return nil, nil
+	}
+	if oldRT != nil && oldRT.Context == newRT.Context && equality.Semantic.DeepEqual(oldRT.Rules, newRT.Rules) {
+		return nil, nil
+	}
+	enabled, err := common.IsFeatureEnabled(a.featureCache, ContextRulesFeature)
+	if err != nil || !enabled {
+		return nil, err
+	}
+
+	var misScoped map[string][]string
+	var scope string
+	switch newRT.Context {
+	case clusterContext:
+		misScoped, scope = projectOnlyResources, projectContext
+	case projectContext:
+		misScoped, scope = clusterOnlyResources, clusterContext
+	default:
+		return nil, nil
+	}
+
+	var errList field.ErrorList
+	for i, rule := range newRT.Rules {
+		for _, group := range rule.APIGroups {
+			for _, resource := range rule.Resources {
+				// subresources are scoped like their resource
+				resource, _, _ = strings.Cut(resource, "/")
+				if !isScopedResource(misScoped, group, resource) {
+					continue
+				}
+				errList = append(errList, field.Invalid(fldPath.Child("rules").Index(i).Child("resources"), resource,
+					fmt.Sprintf("resource %s of API group %q can only be granted by %s RoleTemplates", resource, group, scope)))
+			}
+		}
+	}
+	return errList, nil
+}
+
+// isScopedResource returns true if the resource of the API group is in the resources. Wildcards in rules never match.
+func isScopedResource(resources map[string][]string, group, resource string) bool {
+	if group == "*" || resource == "*" {
+		return false
+	}
+	for _, scoped := range resources[group] {
+		if scoped == anyResource || scoped == resource {
+			return true
+		}
+	}
+	return false
+}
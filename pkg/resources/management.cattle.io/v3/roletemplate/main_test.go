@@ -11,6 +11,7 @@ import (
 	"github.com/rancher/wrangler/v3/pkg/generic/fake"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/suite"
+	"go.uber.org/mock/gomock"
 	v1 "k8s.io/api/admission/v1"
 	authenticationv1 "k8s.io/api/authentication/v1"
 	rbacv1 "k8s.io/api/rbac/v1"
@@ -22,6 +23,22 @@ import (
 
 var errTest = errors.New("bad error")
 
+const (
+	expectedCRTBIndex = "management.cattle.io/crtb-by-role-template"
+	expectedPRTBIndex = "management.cattle.io/prtb-by-role-template"
+)
+
+// newBindingCaches returns CRTB and PRTB caches which expect the validator's indexers and hold no bindings.
+func newBindingCaches(ctrl *gomock.Controller) (*fake.MockCacheInterface[*v3.ClusterRoleTemplateBinding], *fake.MockCacheInterface[*v3.ProjectRoleTemplateBinding]) {
+	crtbCache := fake.NewMockCacheInterface[*v3.ClusterRoleTemplateBinding](ctrl)
+	crtbCache.EXPECT().AddIndexer(expectedCRTBIndex, gomock.Any()).AnyTimes()
+	crtbCache.EXPECT().GetByIndex(expectedCRTBIndex, gomock.Any()).Return(nil, nil).AnyTimes()
+	prtbCache := fake.NewMockCacheInterface[*v3.ProjectRoleTemplateBinding](ctrl)
+	prtbCache.EXPECT().AddIndexer(expectedPRTBIndex, gomock.Any()).AnyTimes()
+	prtbCache.EXPECT().GetByIndex(expectedPRTBIndex, gomock.Any()).Return(nil, nil).AnyTimes()
+	return crtbCache, prtbCache
+}
+
 type testState struct {
 	clusterRoleCacheMock *fake.MockNonNamespacedCacheInterface[*rbacv1.ClusterRole]
 }
@@ -3,6 +3,7 @@ package roletemplate
 
 import (
 	"fmt"
+	"slices"
 	"strings"
 
 	v3 "github.com/rancher/rancher/pkg/apis/management.cattle.io/v3"
@@ -14,10 +15,12 @@ import (
 	"github.com/sirupsen/logrus"
 	admissionv1 "k8s.io/api/admission/v1"
 	admissionregistrationv1 "k8s.io/api/admissionregistration/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
 	"k8s.io/apimachinery/pkg/api/equality"
 	"k8s.io/apimachinery/pkg/runtime/schema"
 	"k8s.io/apimachinery/pkg/util/validation/field"
 	authorizationv1 "k8s.io/client-go/kubernetes/typed/authorization/v1"
+	rbacvalidation "k8s.io/component-helpers/auth/rbac/validation"
 	"k8s.io/kubernetes/pkg/registry/rbac/validation"
 	"k8s.io/utils/trace"
 )
@@ -28,7 +31,14 @@ const (
 	emptyContext     = ""
 	rtRefIndex       = "management.cattle.io/rt-by-reference"
 	rtGlobalRefIndex = "management.cattle.io/rt-by-ref-grb"
+	crtbByRTIndex    = "management.cattle.io/crtb-by-role-template"
+	prtbByRTIndex    = "management.cattle.io/prtb-by-role-template"
 	escalateVerb     = "escalate"
+
+	// ConfirmWideningAnn must be set to "true" by the update widening the permissions of a RoleTemplate which inherits
+	// a builtin RoleTemplate when the policy version is at least admission.PolicyVersion3. An annotation left by a
+	// previous update doesn't confirm the widening.
+	ConfirmWideningAnn = "webhook.cattle.io/confirm-permission-widening"
 )
 
 var gvr = schema.GroupVersionResource{
@@ -39,12 +49,19 @@ var gvr = schema.GroupVersionResource{
 
 // NewValidator returns a new validator used for validating roleTemplates.
 func NewValidator(resolver validation.AuthorizationRuleResolver, roleTemplateResolver *auth.RoleTemplateResolver,
-	sar authorizationv1.SubjectAccessReviewInterface, grCache controllerv3.GlobalRoleCache) *Validator {
+	sar authorizationv1.SubjectAccessReviewInterface, grCache controllerv3.GlobalRoleCache,
+	crtbCache controllerv3.ClusterRoleTemplateBindingCache, prtbCache controllerv3.ProjectRoleTemplateBindingCache,
+	featureCache controllerv3.FeatureCache) *Validator {
 	roleTemplateResolver.RoleTemplateCache().AddIndexer(rtRefIndex, roleTemplatesByReference)
 	grCache.AddIndexer(rtGlobalRefIndex, roleTemplatesByGlobalReference)
+	crtbCache.AddIndexer(crtbByRTIndex, crtbByRoleTemplate)
+	prtbCache.AddIndexer(prtbByRTIndex, prtbByRoleTemplate)
 	return &Validator{
 		admitter: admitter{
 			grCache:              grCache,
+			crtbCache:            crtbCache,
+			prtbCache:            prtbCache,
+			featureCache:         featureCache,
 			resolver:             resolver,
 			roleTemplateResolver: roleTemplateResolver,
 			sar:                  sar,
@@ -79,6 +96,9 @@ func (v *Validator) Admitters() []admission.Admitter {
 
 type admitter struct {
 	grCache              controllerv3.GlobalRoleCache
+	crtbCache            controllerv3.ClusterRoleTemplateBindingCache
+	prtbCache            controllerv3.ProjectRoleTemplateBindingCache
+	featureCache         controllerv3.FeatureCache
 	resolver             validation.AuthorizationRuleResolver
 	roleTemplateResolver *auth.RoleTemplateResolver
 	sar                  authorizationv1.SubjectAccessReviewInterface
@@ -89,6 +109,10 @@ func (a *admitter) Admit(request *admission.Request) (*admissionv1.AdmissionResp
 	listTrace := trace.New("Validator Admit", trace.Field{Key: "user", Value: request.UserInfo.Username})
 	defer listTrace.LogIfLong(admission.SlowTraceDuration)
 
+	if response, err := admission.DisallowUnknownFields(request, &v3.RoleTemplate{}); err != nil || response != nil {
+		return response, err
+	}
+
 	oldRT, newRT, err := objectsv3.RoleTemplateOldAndNewFromRequest(&request.AdmissionRequest)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get RoleTemplate from request: %w", err)
@@ -116,15 +140,27 @@ func (a *admitter) Admit(request *admission.Request) (*admissionv1.AdmissionResp
 	if fieldErr != nil {
 		return admission.ResponseBadRequest(fieldErr.Error()), nil
 	}
+	var previousRT *v3.RoleTemplate
+	if request.Operation == admissionv1.Update {
+		previousRT = oldRT
+	}
+	fieldErrs, err := a.validateContextRules(previousRT, newRT, fldPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to validate the rules of RoleTemplate %s: %w", newRT.Name, err)
+	}
+	if len(fieldErrs) > 0 {
+		return admission.ResponseBadRequest(fieldErrs.ToAggregate().Error()), nil
+	}
 
 	// check for circular references produced by this role.
-	circularTemplate, err := a.checkCircularRef(newRT)
+	chain, err := a.checkCircularRef(newRT)
 	if err != nil {
 		logrus.Errorf("Error when trying to check for a circular ref: %s", err)
 		return nil, err
 	}
-	if circularTemplate != nil {
-		return admission.ResponseBadRequest(fmt.Sprintf("Circular Reference: RoleTemplate %s already inherits RoleTemplate %s", circularTemplate.Name, newRT.Name)), nil
+	if chain != nil {
+		return admission.ResponseBadRequest(fmt.Sprintf("Circular Reference: RoleTemplate %s already inherits RoleTemplate %s (%s)",
+			chain[len(chain)-2], newRT.Name, strings.Join(chain, " -> "))), nil
 	}
 
 	if newRT.ExternalRules != nil {
@@ -152,21 +188,98 @@ func (a *admitter) Admit(request *admission.Request) (*admissionv1.AdmissionResp
 	allowed, err := auth.RequestUserHasVerb(request, gvr, a.sar, escalateVerb, "", "")
 	if err != nil {
 		logrus.Warnf("Failed to check for the 'escalate' verb on RoleTemplates: %v", err)
-	} else if allowed {
+	}
+	if !allowed {
+		if newRT.External && newRT.ExternalRules != nil {
+			// ExternalRules needs 'escalate' permissions. Request would have already been accepted if this user had 'escalate' permissions.
+			return admission.ResponseFailedEscalation("External RoleTemplates with ExternalRules can only be created for users with 'escalate' permissions"), nil
+		}
+
+		err = auth.ConfirmNoEscalation(request, rules, "", a.resolver)
+		if err != nil {
+			return admission.ResponseFailedEscalation(err.Error()), nil
+		}
+	}
+
+	if request.Operation == admissionv1.Update {
+		return a.admitRuleChanges(oldRT, newRT, rules)
+	}
+	return admission.ResponseAllowed(), nil
+}
+
+// admitRuleChanges compares the rules granted by the old and new RoleTemplate. If they differ, the returned response
+// warns how many bindings are affected and whether permissions were widened or narrowed.
+func (a *admitter) admitRuleChanges(oldRT, newRT *v3.RoleTemplate, newRules []rbacv1.PolicyRule) (*admissionv1.AdmissionResponse, error) {
+	oldRules, err := a.roleTemplateResolver.RulesFromTemplate(oldRT)
+	if err != nil {
+		// The previous rules can't be resolved if an inherited RoleTemplate was removed. The impact is unknown, but
+		// the update is otherwise valid.
+		logrus.Warnf("Failed to get previous rules for RoleTemplate '%s': %v", oldRT.Name, err)
+		return admission.ResponseAllowed(), nil
+	}
+	oldCoversNew, _ := rbacvalidation.Covers(oldRules, newRules)
+	newCoversOld, _ := rbacvalidation.Covers(newRules, oldRules)
+	widens, narrows := !oldCoversNew, !newCoversOld
+	if !widens && !narrows {
 		return admission.ResponseAllowed(), nil
 	}
 
-	if newRT.External && newRT.ExternalRules != nil {
-		// ExternalRules needs 'escalate' permissions. Request would have already been accepted if this user had 'escalate' permissions.
-		return admission.ResponseFailedEscalation("External RoleTemplates with ExternalRules can only be created for users with 'escalate' permissions"), nil
+	confirmed := newRT.Annotations[ConfirmWideningAnn] == "true" && oldRT.Annotations[ConfirmWideningAnn] != "true"
+	if widens && admission.CurrentPolicyVersion() >= admission.PolicyVersion3 && !confirmed {
+		builtin, err := a.inheritedBuiltin(newRT)
+		if err != nil {
+			return nil, err
+		}
+		if builtin != "" {
+			return admission.ResponseBadRequest(fmt.Sprintf("RoleTemplate %q inherits builtin RoleTemplate %q: updates which widen its permissions must set the %s annotation to \"true\", removing it first if it is already set",
+				newRT.Name, builtin, ConfirmWideningAnn)), nil
+		}
 	}
 
-	err = auth.ConfirmNoEscalation(request, rules, "", a.resolver)
+	crtbs, err := a.crtbCache.GetByIndex(crtbByRTIndex, newRT.Name)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list ClusterRoleTemplateBindings that reference '%s': %w", newRT.Name, err)
+	}
+	prtbs, err := a.prtbCache.GetByIndex(prtbByRTIndex, newRT.Name)
 	if err != nil {
-		return admission.ResponseFailedEscalation(err.Error()), nil
+		return nil, fmt.Errorf("failed to list ProjectRoleTemplateBindings that reference '%s': %w", newRT.Name, err)
 	}
 
-	return admission.ResponseAllowed(), nil
+	change := "narrows"
+	switch {
+	case widens && narrows:
+		change = "widens and narrows"
+	case widens:
+		change = "widens"
+	}
+	response := admission.ResponseAllowed()
+	response.Warnings = append(response.Warnings, fmt.Sprintf("this update %s the permissions granted by RoleTemplate %q to %d ClusterRoleTemplateBinding(s) and %d ProjectRoleTemplateBinding(s)",
+		change, newRT.Name, len(crtbs), len(prtbs)))
+	return response, nil
+}
+
+// inheritedBuiltin returns the name of the first builtin RoleTemplate inherited, directly or not, by the given
+// RoleTemplate, or "" if none is inherited.
+func (a *admitter) inheritedBuiltin(template *v3.RoleTemplate) (string, error) {
+	seen := map[string]struct{}{template.Name: {}}
+	queue := append([]string(nil), template.RoleTemplateNames...)
+	for len(queue) > 0 {
+		name := queue[0]
+		queue = queue[1:]
+		if _, ok := seen[name]; ok {
+			continue
+		}
+		seen[name] = struct{}{}
+		inherited, err := a.roleTemplateResolver.RoleTemplateCache().Get(name)
+		if err != nil {
+			return "", fmt.Errorf("unable to get roletemplate %s with error %w", name, err)
+		}
+		if inherited.Builtin {
+			return inherited.Name, nil
+		}
+		queue = append(queue, inherited.RoleTemplateNames...)
+	}
+	return "", nil
 }
 
 // validateUpdateFields checks if the fields being changed are valid update fields.
@@ -260,12 +373,22 @@ func roleTemplatesByGlobalReference(gr *v3.GlobalRole) ([]string, error) {
 	return gr.InheritedClusterRoles, nil
 }
 
+func crtbByRoleTemplate(crtb *v3.ClusterRoleTemplateBinding) ([]string, error) {
+	return []string{crtb.RoleTemplateName}, nil
+}
+
+func prtbByRoleTemplate(prtb *v3.ProjectRoleTemplateBinding) ([]string, error) {
+	return []string{prtb.RoleTemplateName}, nil
+}
+
 // checkCircularRef looks for a circular ref between this role template and any role template that it inherits
 // for example - template 1 inherits template 2 which inherits template 1. These setups can cause high cpu usage/crashes
-// If a circular ref was found, returns the first template which inherits this role template. Returns nil otherwise.
+// If a circular ref was found, returns the chain of role template names from this role template back to itself, such as
+// [template1, template2, template1]. Returns nil otherwise.
 // Can return an error if any role template was not found.
-func (a *admitter) checkCircularRef(template *v3.RoleTemplate) (*v3.RoleTemplate, error) {
-	seen := make(map[string]struct{})
+func (a *admitter) checkCircularRef(template *v3.RoleTemplate) ([]string, error) {
+	// inheritedBy maps each role template reached to the role template it was reached from
+	inheritedBy := make(map[string]string)
 	queue := []*v3.RoleTemplate{template}
 	for len(queue) > 0 {
 		current := queue[0]
@@ -275,18 +398,29 @@ func (a *admitter) checkCircularRef(template *v3.RoleTemplate) (*v3.RoleTemplate
 			if inherited == template.Name {
 				// note: we only look for circular references to this role. We don't check for circular dependencies which
 				// don't have this role as one of the targets. Those should have been taken care of when they were originally made
-				return current, nil
+				return inheritanceChain(inheritedBy, template.Name, current.Name), nil
 			}
 			// if we haven't seen this yet, we add to the queue to process
-			if _, ok := seen[inherited]; !ok {
+			if _, ok := inheritedBy[inherited]; !ok {
 				newTemplate, err := a.roleTemplateResolver.RoleTemplateCache().Get(inherited)
 				if err != nil {
 					return nil, fmt.Errorf("unable to get roletemplate %s with error %w", inherited, err)
 				}
-				seen[inherited] = struct{}{}
+				inheritedBy[inherited] = current.Name
 				queue = append(queue, newTemplate)
 			}
 		}
 	}
 	return nil, nil
 }
+
+// inheritanceChain returns the names of the role templates from start to last, followed by start which last inherits.
+func inheritanceChain(inheritedBy map[string]string, start, last string) []string {
+	chain := []string{start}
+	for name := last; name != start; name = inheritedBy[name] {
+		chain = append(chain, name)
+	}
+	chain = append(chain, start)
+	slices.Reverse(chain)
+	return chain
+}
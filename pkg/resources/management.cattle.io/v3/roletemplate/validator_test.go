@@ -3,13 +3,17 @@ package roletemplate_test
 import (
 	"fmt"
 	"strconv"
+	"testing"
 	"time"
 
 	v3 "github.com/rancher/rancher/pkg/apis/management.cattle.io/v3"
+	"github.com/rancher/webhook/pkg/admission"
 	"github.com/rancher/webhook/pkg/auth"
 	controllerv3 "github.com/rancher/webhook/pkg/generated/controllers/management.cattle.io/v3"
 	"github.com/rancher/webhook/pkg/resources/management.cattle.io/v3/roletemplate"
 	"github.com/rancher/wrangler/v3/pkg/generic/fake"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 	"go.uber.org/mock/gomock"
 	v1 "k8s.io/api/admission/v1"
 	authorizationv1 "k8s.io/api/authorization/v1"
@@ -235,7 +239,8 @@ func (r *RoleTemplateSuite) Test_PrivilegeEscalation() {
 				test.stateSetup(state)
 			}
 			roleResolver := auth.NewRoleTemplateResolver(roleTemplateCache, clusterRoleCache)
-			validator := roletemplate.NewValidator(resolver, roleResolver, fakeSAR, grCache)
+			crtbCache, prtbCache := newBindingCaches(ctrl)
+			validator := roletemplate.NewValidator(resolver, roleResolver, fakeSAR, grCache, crtbCache, prtbCache, nil)
 			admitters := validator.Admitters()
 			r.Len(admitters, 1, "wanted only one admitter")
 			req := createRTRequest(r.T(), test.args.oldRT(), test.args.newRT(), test.args.username)
@@ -280,7 +285,8 @@ func (r *RoleTemplateSuite) Test_UpdateValidation() {
 		return true, review, nil
 	})
 
-	validator := roletemplate.NewValidator(resolver, roleResolver, fakeSAR, grCache)
+	crtbCache, prtbCache := newBindingCaches(ctrl)
+	validator := roletemplate.NewValidator(resolver, roleResolver, fakeSAR, grCache, crtbCache, prtbCache, nil)
 	admitters := validator.Admitters()
 	r.Len(admitters, 1, "wanted only one admitter")
 
@@ -740,7 +746,8 @@ func (r *RoleTemplateSuite) Test_Create() {
 				test.stateSetup(state)
 			}
 			roleResolver := auth.NewRoleTemplateResolver(roleTemplateCache, clusterRoleCache)
-			validator := roletemplate.NewValidator(resolver, roleResolver, fakeSAR, grCache)
+			crtbCache, prtbCache := newBindingCaches(ctrl)
+			validator := roletemplate.NewValidator(resolver, roleResolver, fakeSAR, grCache, crtbCache, prtbCache, nil)
 			admitters := validator.Admitters()
 			r.Len(admitters, 1, "wanted only one admitter")
 
@@ -901,7 +908,8 @@ func (r *RoleTemplateSuite) Test_Delete() {
 			r.T().Parallel()
 			ctrl := gomock.NewController(r.T())
 			mocks := test.createMocks(ctrl)
-			validator := roletemplate.NewValidator(resolver, mocks.rtResolver, fakeSAR, mocks.grCache)
+			crtbCache, prtbCache := newBindingCaches(ctrl)
+			validator := roletemplate.NewValidator(resolver, mocks.rtResolver, fakeSAR, mocks.grCache, crtbCache, prtbCache, nil)
 			req := createRTRequest(r.T(), test.args.oldRT(), test.args.newRT(), test.args.username)
 			admitters := validator.Admitters()
 			r.Len(admitters, 1, "wanted only one admitter")
@@ -930,7 +938,8 @@ func (r *RoleTemplateSuite) Test_ErrorHandling() {
 
 	k8Fake := &k8testing.Fake{}
 	fakeSAR := &k8fake.FakeSubjectAccessReviews{Fake: &k8fake.FakeAuthorizationV1{Fake: k8Fake}}
-	validator := roletemplate.NewValidator(resolver, roleResolver, fakeSAR, grCache)
+	crtbCache, prtbCache := newBindingCaches(ctrl)
+	validator := roletemplate.NewValidator(resolver, roleResolver, fakeSAR, grCache, crtbCache, prtbCache, nil)
 	admitters := validator.Admitters()
 	r.Len(admitters, 1, "wanted only one admitter")
 	admitter := admitters[0]
@@ -1040,7 +1049,8 @@ func (r *RoleTemplateSuite) Test_CheckCircularRef() {
 			clusterRoleCache := fake.NewMockNonNamespacedCacheInterface[*rbacv1.ClusterRole](ctrl)
 			roleResolver := auth.NewRoleTemplateResolver(roleTemplateCache, clusterRoleCache)
 
-			validator := roletemplate.NewValidator(resolver, roleResolver, fakeSAR, grCache)
+			crtbCache, prtbCache := newBindingCaches(ctrl)
+			validator := roletemplate.NewValidator(resolver, roleResolver, fakeSAR, grCache, crtbCache, prtbCache, nil)
 			admitters := validator.Admitters()
 			r.Len(admitters, 1, "wanted only one admitter")
 			resp, err := admitters[0].Admit(req)
@@ -1063,6 +1073,48 @@ func (r *RoleTemplateSuite) Test_CheckCircularRef() {
 	}
 }
 
+func (r *RoleTemplateSuite) Test_CheckCircularRefChain() {
+	clusterRoleBindings := []*rbacv1.ClusterRoleBinding{
+		{
+			Subjects: []rbacv1.Subject{
+				{Kind: rbacv1.UserKind, Name: adminUser},
+			},
+			RoleRef: rbacv1.RoleRef{APIGroup: rbacv1.GroupName, Kind: "ClusterRole", Name: r.adminCR.Name},
+		},
+	}
+	resolver, _ := validation.NewTestRuleResolver(nil, nil, []*rbacv1.ClusterRole{r.adminCR}, clusterRoleBindings)
+	k8Fake := &k8testing.Fake{}
+	fakeSAR := &k8fake.FakeSubjectAccessReviews{Fake: &k8fake.FakeAuthorizationV1{Fake: k8Fake}}
+
+	ctrl := gomock.NewController(r.T())
+	roleTemplateCache := fake.NewMockNonNamespacedCacheInterface[*v3.RoleTemplate](ctrl)
+	roleTemplateCache.EXPECT().AddIndexer(expectedIndexerName, gomock.Any())
+	grCache := fake.NewMockNonNamespacedCacheInterface[*v3.GlobalRole](ctrl)
+	grCache.EXPECT().AddIndexer(expectedGlobalRefIndex, gomock.Any())
+
+	// rt-a inherits rt-b and rt-other, rt-b inherits rt-c which inherits rt-a
+	newRT := createRoleTemplate("rt-a")
+	newRT.RoleTemplateNames = []string{"rt-other", "rt-b"}
+	for name, inherits := range map[string][]string{"rt-other": nil, "rt-b": {"rt-c"}, "rt-c": {"rt-other", "rt-a"}} {
+		rt := createRoleTemplate(name)
+		rt.RoleTemplateNames = inherits
+		roleTemplateCache.EXPECT().Get(name).Return(rt, nil).AnyTimes()
+	}
+
+	clusterRoleCache := fake.NewMockNonNamespacedCacheInterface[*rbacv1.ClusterRole](ctrl)
+	roleResolver := auth.NewRoleTemplateResolver(roleTemplateCache, clusterRoleCache)
+	crtbCache, prtbCache := newBindingCaches(ctrl)
+	validator := roletemplate.NewValidator(resolver, roleResolver, fakeSAR, grCache, crtbCache, prtbCache, nil)
+
+	resp, err := validator.Admitters()[0].Admit(createRTRequest(r.T(), nil, newRT, adminUser))
+	r.NoError(err)
+	r.False(resp.Allowed, "expected roleTemplate to be denied")
+	if r.NotNil(resp.Result, "expected response result to be set") {
+		r.Contains(resp.Result.Message, "RoleTemplate rt-c already inherits RoleTemplate rt-a")
+		r.Contains(resp.Result.Message, "rt-a -> rt-b -> rt-c -> rt-a")
+	}
+}
+
 func createNestedRoleTemplate(name string, cache *fake.MockNonNamespacedCacheInterface[*v3.RoleTemplate], depth int, circleDepth int, errDepth int) *v3.RoleTemplate {
 	start := createRoleTemplate(name)
 	prior := start
@@ -1104,3 +1156,251 @@ func createRoleTemplate(name string) *v3.RoleTemplate {
 	}
 	return newRT
 }
+
+func TestUpdateImpact(t *testing.T) {
+	readPods := rbacv1.PolicyRule{APIGroups: []string{""}, Resources: []string{"pods"}, Verbs: []string{"get", "list"}}
+	readNodes := rbacv1.PolicyRule{APIGroups: []string{""}, Resources: []string{"nodes"}, Verbs: []string{"get", "list"}}
+	adminCR := &rbacv1.ClusterRole{
+		ObjectMeta: metav1.ObjectMeta{Name: "admin-role"},
+		Rules:      []rbacv1.PolicyRule{{APIGroups: []string{"*"}, Resources: []string{"*"}, Verbs: []string{"*"}}},
+	}
+	adminCRB := &rbacv1.ClusterRoleBinding{
+		Subjects: []rbacv1.Subject{{Kind: rbacv1.UserKind, Name: adminUser}},
+		RoleRef:  rbacv1.RoleRef{APIGroup: rbacv1.GroupName, Kind: "ClusterRole", Name: adminCR.Name},
+	}
+	resolver, _ := validation.NewTestRuleResolver(nil, nil, []*rbacv1.ClusterRole{adminCR}, []*rbacv1.ClusterRoleBinding{adminCRB})
+	builtinRT := createRoleTemplate("builtin-parent")
+	builtinRT.Builtin = true
+	customRT := createRoleTemplate("custom-parent")
+	customRT.RoleTemplateNames = []string{builtinRT.Name}
+
+	rtWithRules := func(inherits bool, annotated bool, rules ...rbacv1.PolicyRule) *v3.RoleTemplate {
+		rt := newDefaultRT()
+		rt.Rules = rules
+		if inherits {
+			rt.RoleTemplateNames = []string{customRT.Name}
+		}
+		if annotated {
+			rt.Annotations = map[string]string{roletemplate.ConfirmWideningAnn: "true"}
+		}
+		return rt
+	}
+
+	tests := []struct {
+		name          string
+		policyVersion admission.PolicyVersion
+		oldRT         *v3.RoleTemplate
+		newRT         *v3.RoleTemplate
+		allowed       bool
+		wantWarning   string
+	}{
+		{
+			name:          "rules unchanged",
+			policyVersion: admission.PolicyVersion1,
+			oldRT:         rtWithRules(false, false, readPods),
+			newRT:         rtWithRules(false, true, readPods),
+			allowed:       true,
+		},
+		{
+			name:          "rules widened",
+			policyVersion: admission.PolicyVersion1,
+			oldRT:         rtWithRules(false, false, readPods),
+			newRT:         rtWithRules(false, false, readPods, readNodes),
+			allowed:       true,
+			wantWarning:   `this update widens the permissions granted by RoleTemplate "rt-new" to 2 ClusterRoleTemplateBinding(s) and 1 ProjectRoleTemplateBinding(s)`,
+		},
+		{
+			name:          "rules narrowed",
+			policyVersion: admission.PolicyVersion1,
+			oldRT:         rtWithRules(false, false, readPods, readNodes),
+			newRT:         rtWithRules(false, false, readPods),
+			allowed:       true,
+			wantWarning:   `this update narrows the permissions granted by RoleTemplate "rt-new" to 2 ClusterRoleTemplateBinding(s) and 1 ProjectRoleTemplateBinding(s)`,
+		},
+		{
+			name:          "rules widened and narrowed",
+			policyVersion: admission.PolicyVersion1,
+			oldRT:         rtWithRules(false, false, readPods),
+			newRT:         rtWithRules(false, false, readNodes),
+			allowed:       true,
+			wantWarning:   `this update widens and narrows the permissions granted by RoleTemplate "rt-new" to 2 ClusterRoleTemplateBinding(s) and 1 ProjectRoleTemplateBinding(s)`,
+		},
+		{
+			name:          "builtin-derived rules widened without confirmation before policy version 3",
+			policyVersion: admission.PolicyVersion2,
+			oldRT:         rtWithRules(true, false, readPods),
+			newRT:         rtWithRules(true, false, readPods, readNodes),
+			allowed:       true,
+			wantWarning:   `this update widens the permissions granted by RoleTemplate "rt-new" to 2 ClusterRoleTemplateBinding(s) and 1 ProjectRoleTemplateBinding(s)`,
+		},
+		{
+			name:          "builtin-derived rules widened without confirmation",
+			policyVersion: admission.PolicyVersion3,
+			oldRT:         rtWithRules(true, false, readPods),
+			newRT:         rtWithRules(true, false, readPods, readNodes),
+			allowed:       false,
+		},
+		{
+			name:          "builtin-derived rules widened with confirmation",
+			policyVersion: admission.PolicyVersion3,
+			oldRT:         rtWithRules(true, false, readPods),
+			newRT:         rtWithRules(true, true, readPods, readNodes),
+			allowed:       true,
+			wantWarning:   `this update widens the permissions granted by RoleTemplate "rt-new" to 2 ClusterRoleTemplateBinding(s) and 1 ProjectRoleTemplateBinding(s)`,
+		},
+		{
+			name:          "builtin-derived rules widened with confirmation of a previous update",
+			policyVersion: admission.PolicyVersion3,
+			oldRT:         rtWithRules(true, true, readPods),
+			newRT:         rtWithRules(true, true, readPods, readNodes),
+			allowed:       false,
+		},
+		{
+			name:          "builtin-derived rules narrowed without confirmation",
+			policyVersion: admission.PolicyVersion3,
+			oldRT:         rtWithRules(true, false, readPods, readNodes),
+			newRT:         rtWithRules(true, false, readPods),
+			allowed:       true,
+			wantWarning:   `this update narrows the permissions granted by RoleTemplate "rt-new" to 2 ClusterRoleTemplateBinding(s) and 1 ProjectRoleTemplateBinding(s)`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			admission.SetPolicyVersion(tt.policyVersion)
+			t.Cleanup(func() { admission.SetPolicyVersion(admission.PolicyVersion1) })
+
+			ctrl := gomock.NewController(t)
+			roleTemplateCache := fake.NewMockNonNamespacedCacheInterface[*v3.RoleTemplate](ctrl)
+			roleTemplateCache.EXPECT().AddIndexer(expectedIndexerName, gomock.Any())
+			roleTemplateCache.EXPECT().Get(builtinRT.Name).Return(builtinRT, nil).AnyTimes()
+			roleTemplateCache.EXPECT().Get(customRT.Name).Return(customRT, nil).AnyTimes()
+			clusterRoleCache := fake.NewMockNonNamespacedCacheInterface[*rbacv1.ClusterRole](ctrl)
+			grCache := fake.NewMockNonNamespacedCacheInterface[*v3.GlobalRole](ctrl)
+			grCache.EXPECT().AddIndexer(expectedGlobalRefIndex, gomock.Any())
+			crtbCache := fake.NewMockCacheInterface[*v3.ClusterRoleTemplateBinding](ctrl)
+			crtbCache.EXPECT().AddIndexer(expectedCRTBIndex, gomock.Any())
+			crtbCache.EXPECT().GetByIndex(expectedCRTBIndex, "rt-new").Return([]*v3.ClusterRoleTemplateBinding{{}, {}}, nil).AnyTimes()
+			prtbCache := fake.NewMockCacheInterface[*v3.ProjectRoleTemplateBinding](ctrl)
+			prtbCache.EXPECT().AddIndexer(expectedPRTBIndex, gomock.Any())
+			prtbCache.EXPECT().GetByIndex(expectedPRTBIndex, "rt-new").Return([]*v3.ProjectRoleTemplateBinding{{}}, nil).AnyTimes()
+			k8Fake := &k8testing.Fake{}
+			fakeSAR := &k8fake.FakeSubjectAccessReviews{Fake: &k8fake.FakeAuthorizationV1{Fake: k8Fake}}
+
+			roleResolver := auth.NewRoleTemplateResolver(roleTemplateCache, clusterRoleCache)
+			validator := roletemplate.NewValidator(resolver, roleResolver, fakeSAR, grCache, crtbCache, prtbCache, nil)
+			req := createRTRequest(t, tt.oldRT, tt.newRT, adminUser)
+			resp, err := validator.Admitters()[0].Admit(req)
+			require.NoError(t, err)
+			require.Equalf(t, tt.allowed, resp.Allowed, "unexpected response: %v", resp.Result)
+			if tt.wantWarning == "" {
+				assert.Empty(t, resp.Warnings)
+			} else {
+				assert.Equal(t, []string{tt.wantWarning}, resp.Warnings)
+			}
+		})
+	}
+}
+
+func (r *RoleTemplateSuite) Test_ContextRules() {
+	clusterRoleBindings := []*rbacv1.ClusterRoleBinding{
+		{
+			Subjects: []rbacv1.Subject{
+				{Kind: rbacv1.UserKind, Name: adminUser},
+			},
+			RoleRef: rbacv1.RoleRef{APIGroup: rbacv1.GroupName, Kind: "ClusterRole", Name: r.adminCR.Name},
+		},
+	}
+	resolver, _ := validation.NewTestRuleResolver(nil, nil, []*rbacv1.ClusterRole{r.adminCR}, clusterRoleBindings)
+	k8Fake := &k8testing.Fake{}
+	fakeSAR := &k8fake.FakeSubjectAccessReviews{Fake: &k8fake.FakeAuthorizationV1{Fake: k8Fake}}
+
+	newRT := func(context string, rules ...rbacv1.PolicyRule) *v3.RoleTemplate {
+		rt := newDefaultRT()
+		rt.Context = context
+		rt.Rules = rules
+		return rt
+	}
+	nodesRule := rbacv1.PolicyRule{APIGroups: []string{""}, Resources: []string{"pods", "nodes/proxy"}, Verbs: []string{"get"}}
+	appsRule := rbacv1.PolicyRule{APIGroups: []string{"project.cattle.io"}, Resources: []string{"apps"}, Verbs: []string{"get"}}
+	prtbsRule := rbacv1.PolicyRule{APIGroups: []string{"management.cattle.io"}, Resources: []string{"projectroletemplatebindings"}, Verbs: []string{"get"}}
+	wildcardRule := rbacv1.PolicyRule{APIGroups: []string{"*"}, Resources: []string{"*"}, Verbs: []string{"get"}}
+
+	tests := []struct {
+		name         string
+		featureValue *bool
+		oldRT        *v3.RoleTemplate
+		newRT        *v3.RoleTemplate
+		wantDenied   string
+	}{
+		{
+			name:         "project RoleTemplate granting nodes",
+			featureValue: admission.Ptr(true),
+			newRT:        newRT("project", nodesRule),
+			wantDenied:   "roletemplate.rules[0].resources",
+		},
+		{
+			name:         "cluster RoleTemplate granting apps",
+			featureValue: admission.Ptr(true),
+			newRT:        newRT("cluster", nodesRule, appsRule),
+			wantDenied:   "roletemplate.rules[1].resources",
+		},
+		{
+			name:         "cluster RoleTemplate granting nodes",
+			featureValue: admission.Ptr(true),
+			newRT:        newRT("cluster", nodesRule),
+		},
+		{
+			name:         "cluster RoleTemplate granting projectroletemplatebindings",
+			featureValue: admission.Ptr(true),
+			newRT:        newRT("cluster", prtbsRule),
+		},
+		{
+			name:         "wildcards",
+			featureValue: admission.Ptr(true),
+			newRT:        newRT("project", wildcardRule),
+		},
+		{
+			name:         "feature disabled",
+			featureValue: admission.Ptr(false),
+			newRT:        newRT("project", nodesRule),
+		},
+		{
+			name:         "unchanged rules",
+			featureValue: admission.Ptr(true),
+			oldRT:        newRT("project", nodesRule),
+			newRT: func() *v3.RoleTemplate {
+				rt := newRT("project", nodesRule)
+				rt.Annotations = map[string]string{"foo": "bar"}
+				return rt
+			}(),
+		},
+	}
+	for _, test := range tests {
+		r.Run(test.name, func() {
+			ctrl := gomock.NewController(r.T())
+			roleTemplateCache := fake.NewMockNonNamespacedCacheInterface[*v3.RoleTemplate](ctrl)
+			roleTemplateCache.EXPECT().AddIndexer(expectedIndexerName, gomock.Any())
+			clusterRoleCache := fake.NewMockNonNamespacedCacheInterface[*rbacv1.ClusterRole](ctrl)
+			roleResolver := auth.NewRoleTemplateResolver(roleTemplateCache, clusterRoleCache)
+			grCache := fake.NewMockNonNamespacedCacheInterface[*v3.GlobalRole](ctrl)
+			grCache.EXPECT().AddIndexer(expectedGlobalRefIndex, gomock.Any())
+			featureCache := fake.NewMockNonNamespacedCacheInterface[*v3.Feature](ctrl)
+			featureCache.EXPECT().Get(roletemplate.ContextRulesFeature).Return(&v3.Feature{
+				Spec: v3.FeatureSpec{Value: test.featureValue},
+			}, nil).AnyTimes()
+			crtbCache, prtbCache := newBindingCaches(ctrl)
+			validator := roletemplate.NewValidator(resolver, roleResolver, fakeSAR, grCache, crtbCache, prtbCache, featureCache)
+
+			resp, err := validator.Admitters()[0].Admit(createRTRequest(r.T(), test.oldRT, test.newRT, adminUser))
+			r.NoError(err)
+			if test.wantDenied == "" {
+				r.True(resp.Allowed, "expected roleTemplate to be allowed")
+				return
+			}
+			r.False(resp.Allowed, "expected roleTemplate to be denied")
+			if r.NotNil(resp.Result, "expected response result to be set") {
+				r.Contains(resp.Result.Message, test.wantDenied)
+			}
+		})
+	}
+}
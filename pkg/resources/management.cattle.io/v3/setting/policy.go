@@ -0,0 +1,73 @@
+package setting
+
+import (
+	"fmt"
+	"slices"
+
+	corev1controller "github.com/rancher/wrangler/v3/pkg/generated/controllers/core/v1"
+	"github.com/sirupsen/logrus"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"sigs.k8s.io/yaml"
+)
+
+const (
+	// PolicyConfigMapNamespace is the namespace of the ConfigMap holding the setting policies.
+	PolicyConfigMapNamespace = "cattle-system"
+	// PolicyConfigMapName is the name of the ConfigMap holding the setting policies. Each key of the ConfigMap's data
+	// is the name of a setting and its value is the YAML encoded Policy restricting who can change it.
+	PolicyConfigMapName = "rancher-webhook-setting-policies"
+)
+
+// Policy restricts who can change the value of a setting. A user can change it if they are a member of one of the
+// Groups, or if they are allowed the Verb on the setting.
+type Policy struct {
+	// Groups are the groups whose members can change the value of the setting.
+	Groups []string `json:"groups,omitempty"`
+	// Verb, if set, is the verb on the setting, such as "manage", which allows users to change its value. It is
+	// checked with a SubjectAccessReview.
+	Verb string `json:"verb,omitempty"`
+}
+
+// validate returns an error if the policy doesn't allow anyone to change the setting.
+func (p *Policy) validate() error {
+	if len(p.Groups) == 0 && p.Verb == "" {
+		return fmt.Errorf("at least one of groups or verb must be set")
+	}
+	return nil
+}
+
+// allowsGroups returns true if one of the groups is allowed by the policy.
+func (p *Policy) allowsGroups(groups []string) bool {
+	return slices.ContainsFunc(groups, func(group string) bool {
+		return slices.Contains(p.Groups, group)
+	})
+}
+
+// getPolicy returns the policy of the setting with the given name from the policy ConfigMap, or nil if it has none.
+// Policies which can not be decoded or are invalid are logged and ignored.
+func getPolicy(configMapCache corev1controller.ConfigMapCache, name string) (*Policy, error) {
+	if configMapCache == nil {
+		return nil, nil
+	}
+	configMap, err := configMapCache.Get(PolicyConfigMapNamespace, PolicyConfigMapName)
+	if err != nil {
+		if apierrors.IsNotFound(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to get ConfigMap %s/%s: %w", PolicyConfigMapNamespace, PolicyConfigMapName, err)
+	}
+	data, ok := configMap.Data[name]
+	if !ok {
+		return nil, nil
+	}
+	policy := &Policy{}
+	if err := yaml.UnmarshalStrict([]byte(data), policy); err != nil {
+		logrus.Errorf("[settingValidator] ignoring policy of setting %s which could not be decoded: %v", name, err)
+		return nil, nil
+	}
+	if err := policy.validate(); err != nil {
+		logrus.Errorf("[settingValidator] ignoring invalid policy of setting %s: %v", name, err)
+		return nil, nil
+	}
+	return policy, nil
+}
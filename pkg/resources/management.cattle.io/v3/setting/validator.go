@@ -8,8 +8,11 @@ import (
 
 	v3 "github.com/rancher/rancher/pkg/apis/management.cattle.io/v3"
 	"github.com/rancher/webhook/pkg/admission"
+	"github.com/rancher/webhook/pkg/auth"
 	controllerv3 "github.com/rancher/webhook/pkg/generated/controllers/management.cattle.io/v3"
 	objectsv3 "github.com/rancher/webhook/pkg/generated/objects/management.cattle.io/v3"
+	"github.com/rancher/webhook/pkg/resources/common"
+	corev1controller "github.com/rancher/wrangler/v3/pkg/generated/controllers/core/v1"
 	"github.com/robfig/cron"
 	"github.com/sirupsen/logrus"
 	admissionv1 "k8s.io/api/admission/v1"
@@ -18,6 +21,7 @@ import (
 	"k8s.io/apimachinery/pkg/labels"
 	"k8s.io/apimachinery/pkg/runtime/schema"
 	"k8s.io/apimachinery/pkg/util/validation/field"
+	authorizationv1 "k8s.io/client-go/kubernetes/typed/authorization/v1"
 	"k8s.io/utils/trace"
 )
 
@@ -49,11 +53,14 @@ type Validator struct {
 }
 
 // NewValidator returns a new Validator instance.
-func NewValidator(clusterCache controllerv3.ClusterCache, settingCache controllerv3.SettingCache) *Validator {
+func NewValidator(clusterCache controllerv3.ClusterCache, settingCache controllerv3.SettingCache,
+	configMapCache corev1controller.ConfigMapCache, sar authorizationv1.SubjectAccessReviewInterface) *Validator {
 	return &Validator{
 		admitter: admitter{
-			clusterCache: clusterCache,
-			settingCache: settingCache,
+			clusterCache:   clusterCache,
+			settingCache:   settingCache,
+			configMapCache: configMapCache,
+			sar:            sar,
 		},
 	}
 }
@@ -81,8 +88,10 @@ func (v *Validator) Admitters() []admission.Admitter {
 }
 
 type admitter struct {
-	clusterCache controllerv3.ClusterCache
-	settingCache controllerv3.SettingCache
+	clusterCache   controllerv3.ClusterCache
+	settingCache   controllerv3.SettingCache
+	configMapCache corev1controller.ConfigMapCache
+	sar            authorizationv1.SubjectAccessReviewInterface
 }
 
 // Admit handles the webhook admission requests.
@@ -95,6 +104,13 @@ func (a *admitter) Admit(request *admission.Request) (*admissionv1.AdmissionResp
 		return nil, fmt.Errorf("failed to get Setting from request: %w", err)
 	}
 
+	if request.Operation == admissionv1.Create || request.Operation == admissionv1.Update {
+		response, err := a.checkPolicy(request, oldSetting, newSetting)
+		if err != nil || !response.Allowed {
+			return response, err
+		}
+	}
+
 	switch request.Operation {
 	case admissionv1.Create:
 		return a.admitCreate(newSetting)
@@ -105,6 +121,32 @@ func (a *admitter) Admit(request *admission.Request) (*admissionv1.AdmissionResp
 	}
 }
 
+// checkPolicy ensures that the user is allowed to change the value of the setting by the policy of the setting in the
+// setting policy ConfigMap, if any.
+func (a *admitter) checkPolicy(request *admission.Request, oldSetting, newSetting *v3.Setting) (*admissionv1.AdmissionResponse, error) {
+	if newSetting.Value == oldSetting.Value {
+		return admission.ResponseAllowed(), nil
+	}
+	policy, err := getPolicy(a.configMapCache, newSetting.Name)
+	if err != nil {
+		return nil, err
+	}
+	if policy == nil || policy.allowsGroups(request.UserInfo.Groups) {
+		return admission.ResponseAllowed(), nil
+	}
+	if policy.Verb != "" {
+		allowed, err := auth.RequestUserHasVerb(request, gvr, a.sar, policy.Verb, newSetting.Name, "")
+		if err != nil {
+			return nil, fmt.Errorf("failed to check SubjectAccessReview for setting %s: %w", newSetting.Name, err)
+		}
+		if allowed {
+			return admission.ResponseAllowed(), nil
+		}
+	}
+	return admission.ResponseFailedEscalation(fmt.Sprintf("user %s is not allowed to change the value of setting %s by its policy in ConfigMap %s/%s",
+		request.UserInfo.Username, newSetting.Name, PolicyConfigMapNamespace, PolicyConfigMapName)), nil
+}
+
 func (a *admitter) admitCreate(newSetting *v3.Setting) (*admissionv1.AdmissionResponse, error) {
 	return a.admitCommonCreateUpdate(nil, newSetting)
 }
@@ -139,6 +181,18 @@ func (a *admitter) admitCommonCreateUpdate(_, newSetting *v3.Setting) (*admissio
 		err = a.validateUserRetentionCron(newSetting)
 	case AuthUserSessionTTLMinutes:
 		err = a.validateAuthUserSessionTTLMinutes(newSetting)
+	case common.AuthUserRefreshMinIntervalSetting:
+		err = a.validateAuthUserRefreshMinInterval(newSetting)
+	case common.MinKubernetesVersionSetting:
+		err = a.validateMinKubernetesVersion(newSetting)
+	case common.AgentEnvVarsDenyListSetting, common.AgentEnvVarsAllowListSetting:
+		err = a.validateEnvVarNamePatterns(newSetting)
+	case common.MaxProjectsPerUserSetting:
+		err = a.validateMaxProjectsPerUser(newSetting)
+	case common.UniqueClusterDisplayNamesSetting:
+		err = a.validateUniqueClusterDisplayNames(newSetting)
+	case common.LegacyResourceLockdownSetting:
+		err = a.validateLegacyResourceLockdown(newSetting)
 	default:
 	}
 
@@ -280,6 +334,78 @@ func (a *admitter) validateUserRetentionCron(s *v3.Setting) error {
 	return nil
 }
 
+// validateAuthUserRefreshMinInterval validates the auth-user-refresh-min-interval setting
+// to make sure it's zero or a positive duration.
+func (a *admitter) validateAuthUserRefreshMinInterval(s *v3.Setting) error {
+	if s.Value == "" {
+		return nil
+	}
+
+	if _, err := validateDuration(s.Value); err != nil {
+		return field.TypeInvalid(valuePath, s.Value, err.Error())
+	}
+
+	return nil
+}
+
+// validateMinKubernetesVersion validates the provisioning-min-kubernetes-version setting
+// to make sure it's a Kubernetes version.
+func (a *admitter) validateMinKubernetesVersion(s *v3.Setting) error {
+	if s.Value == "" {
+		return nil
+	}
+
+	if _, err := common.ParseKubernetesVersion(s.Value); err != nil {
+		return field.TypeInvalid(valuePath, s.Value, err.Error())
+	}
+
+	return nil
+}
+
+// validateEnvVarNamePatterns validates the agent-env-vars-deny-list and agent-env-vars-allow-list settings
+// to make sure they're comma separated lists of env var names, optionally ending with "*".
+func (a *admitter) validateEnvVarNamePatterns(s *v3.Setting) error {
+	if s.Value == "" {
+		return nil
+	}
+
+	if _, err := common.ParseEnvVarNamePatterns(s.Value); err != nil {
+		return field.Invalid(valuePath, s.Value, err.Error())
+	}
+
+	return nil
+}
+
+// validateMaxProjectsPerUser validates the max-projects-per-user setting
+// to make sure it's a non-negative integer.
+func (a *admitter) validateMaxProjectsPerUser(s *v3.Setting) error {
+	if _, err := common.ParseMaxProjectsPerUser(s.Value); err != nil {
+		return field.Invalid(valuePath, s.Value, err.Error())
+	}
+
+	return nil
+}
+
+// validateLegacyResourceLockdown validates the legacy-resource-lockdown setting
+// to make sure it only lists legacy resources.
+func (a *admitter) validateLegacyResourceLockdown(s *v3.Setting) error {
+	if _, err := common.ParseLegacyResourceLockdown(s.Value); err != nil {
+		return field.Invalid(valuePath, s.Value, err.Error())
+	}
+
+	return nil
+}
+
+// validateUniqueClusterDisplayNames validates the unique-cluster-display-names setting
+// to make sure it's a boolean.
+func (a *admitter) validateUniqueClusterDisplayNames(s *v3.Setting) error {
+	if _, err := common.ParseUniqueClusterDisplayNames(s.Value); err != nil {
+		return field.Invalid(valuePath, s.Value, err.Error())
+	}
+
+	return nil
+}
+
 // validateUserLastLoginDefault validates the user-last-login-default setting
 // to make sure it's a valid RFC3339 formatted date time.
 func (a *admitter) validateUserLastLoginDefault(s *v3.Setting) error {
@@ -10,6 +10,7 @@ import (
 
 	v3 "github.com/rancher/rancher/pkg/apis/management.cattle.io/v3"
 	"github.com/rancher/webhook/pkg/admission"
+	"github.com/rancher/webhook/pkg/resources/common"
 	"github.com/rancher/webhook/pkg/resources/management.cattle.io/v3/setting"
 	"github.com/rancher/wrangler/v3/pkg/generic/fake"
 	"github.com/stretchr/testify/assert"
@@ -19,8 +20,13 @@ import (
 	v1 "k8s.io/api/admission/v1"
 	admissionregistrationv1 "k8s.io/api/admissionregistration/v1"
 	authenticationv1 "k8s.io/api/authentication/v1"
+	authorizationv1 "k8s.io/api/authorization/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
+	k8fake "k8s.io/client-go/kubernetes/typed/authorization/v1/fake"
+	k8testing "k8s.io/client-go/testing"
 )
 
 type SettingSuite struct {
@@ -122,7 +128,7 @@ func (s *SettingSuite) validateDisableInactiveUserAfter(op v1.Operation) {
 				}, nil
 			}).Times(getUserSessionTTLCalledTimes)
 
-			validator := setting.NewValidator(nil, settingCache)
+			validator := setting.NewValidator(nil, settingCache, nil, nil)
 			s.testAdmit(t, validator, &v3.Setting{
 				ObjectMeta: metav1.ObjectMeta{
 					Name: setting.DisableInactiveUserAfter,
@@ -226,7 +232,7 @@ func (s *SettingSuite) validateDeleteInactiveUserAfter(op v1.Operation) {
 				}, nil
 			}).Times(getUserSessionTTLCalledTimes)
 
-			validator := setting.NewValidator(nil, settingCache)
+			validator := setting.NewValidator(nil, settingCache, nil, nil)
 			s.testAdmit(t, validator, &v3.Setting{
 				ObjectMeta: metav1.ObjectMeta{
 					Name: setting.DeleteInactiveUserAfter,
@@ -280,7 +286,7 @@ func (s *SettingSuite) validateUserRetentionCron(op v1.Operation) {
 		s.T().Run(test.desc, func(t *testing.T) {
 			t.Parallel()
 
-			validator := setting.NewValidator(nil, nil)
+			validator := setting.NewValidator(nil, nil, nil, nil)
 			s.testAdmit(t, validator, &v3.Setting{
 				ObjectMeta: metav1.ObjectMeta{
 					Name: setting.UserRetentionCron,
@@ -295,6 +301,354 @@ func (s *SettingSuite) validateUserRetentionCron(op v1.Operation) {
 	}
 }
 
+func (s *SettingSuite) TestValidateAuthUserRefreshMinIntervalOnUpdate() {
+	s.validateAuthUserRefreshMinInterval(v1.Update)
+}
+
+func (s *SettingSuite) TestValidateAuthUserRefreshMinIntervalOnCreate() {
+	s.validateAuthUserRefreshMinInterval(v1.Create)
+}
+
+func (s *SettingSuite) validateAuthUserRefreshMinInterval(op v1.Operation) {
+	tests := []struct {
+		desc    string
+		value   string
+		allowed bool
+	}{
+		{
+			desc:    "disabled",
+			value:   "",
+			allowed: true,
+		},
+		{
+			desc:    "zero",
+			value:   "0s",
+			allowed: true,
+		},
+		{
+			desc:    "positive duration",
+			value:   "5m",
+			allowed: true,
+		},
+		{
+			desc:  "negative duration",
+			value: "-5m",
+		},
+		{
+			desc:  "nonsensical value",
+			value: "foo",
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+		s.T().Run(test.desc, func(t *testing.T) {
+			t.Parallel()
+
+			validator := setting.NewValidator(nil, nil, nil, nil)
+			s.testAdmit(t, validator, &v3.Setting{
+				ObjectMeta: metav1.ObjectMeta{
+					Name: common.AuthUserRefreshMinIntervalSetting,
+				},
+			}, &v3.Setting{
+				ObjectMeta: metav1.ObjectMeta{
+					Name: common.AuthUserRefreshMinIntervalSetting,
+				},
+				Value: test.value,
+			}, op, test.allowed)
+		})
+	}
+}
+
+func (s *SettingSuite) TestValidateMinKubernetesVersionOnUpdate() {
+	s.validateMinKubernetesVersion(v1.Update)
+}
+
+func (s *SettingSuite) TestValidateMinKubernetesVersionOnCreate() {
+	s.validateMinKubernetesVersion(v1.Create)
+}
+
+func (s *SettingSuite) validateMinKubernetesVersion(op v1.Operation) {
+	tests := []struct {
+		desc    string
+		value   string
+		allowed bool
+	}{
+		{
+			desc:    "disabled",
+			value:   "",
+			allowed: true,
+		},
+		{
+			desc:    "full version",
+			value:   "v1.28.3",
+			allowed: true,
+		},
+		{
+			desc:    "minor version",
+			value:   "v1.28",
+			allowed: true,
+		},
+		{
+			desc:    "rke2 version",
+			value:   "v1.28.3+rke2r1",
+			allowed: true,
+		},
+		{
+			desc:  "nonsensical value",
+			value: "latest",
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+		s.T().Run(test.desc, func(t *testing.T) {
+			t.Parallel()
+
+			validator := setting.NewValidator(nil, nil, nil, nil)
+			s.testAdmit(t, validator, &v3.Setting{
+				ObjectMeta: metav1.ObjectMeta{
+					Name: common.MinKubernetesVersionSetting,
+				},
+			}, &v3.Setting{
+				ObjectMeta: metav1.ObjectMeta{
+					Name: common.MinKubernetesVersionSetting,
+				},
+				Value: test.value,
+			}, op, test.allowed)
+		})
+	}
+}
+
+func (s *SettingSuite) TestValidateAgentEnvVarsListsOnUpdate() {
+	s.validateAgentEnvVarsLists(v1.Update)
+}
+
+func (s *SettingSuite) TestValidateAgentEnvVarsListsOnCreate() {
+	s.validateAgentEnvVarsLists(v1.Create)
+}
+
+func (s *SettingSuite) validateAgentEnvVarsLists(op v1.Operation) {
+	tests := []struct {
+		desc    string
+		value   string
+		allowed bool
+	}{
+		{
+			desc:    "disabled",
+			value:   "",
+			allowed: true,
+		},
+		{
+			desc:    "names and prefixes",
+			value:   "HTTP_PROXY_, CATTLE_*,https_proxy",
+			allowed: true,
+		},
+		{
+			desc:  "invalid name",
+			value: "CATTLE_*,HTTP PROXY",
+		},
+		{
+			desc:  "wildcard in the middle",
+			value: "CATTLE_*_URL",
+		},
+	}
+
+	for _, name := range []string{common.AgentEnvVarsDenyListSetting, common.AgentEnvVarsAllowListSetting} {
+		for _, test := range tests {
+			name, test := name, test
+			s.T().Run(name+" "+test.desc, func(t *testing.T) {
+				t.Parallel()
+
+				validator := setting.NewValidator(nil, nil, nil, nil)
+				s.testAdmit(t, validator, &v3.Setting{
+					ObjectMeta: metav1.ObjectMeta{
+						Name: name,
+					},
+				}, &v3.Setting{
+					ObjectMeta: metav1.ObjectMeta{
+						Name: name,
+					},
+					Value: test.value,
+				}, op, test.allowed)
+			})
+		}
+	}
+}
+
+func (s *SettingSuite) TestValidateMaxProjectsPerUserOnUpdate() {
+	s.validateMaxProjectsPerUser(v1.Update)
+}
+
+func (s *SettingSuite) TestValidateMaxProjectsPerUserOnCreate() {
+	s.validateMaxProjectsPerUser(v1.Create)
+}
+
+func (s *SettingSuite) validateMaxProjectsPerUser(op v1.Operation) {
+	tests := []struct {
+		desc    string
+		value   string
+		allowed bool
+	}{
+		{
+			desc:    "disabled",
+			value:   "",
+			allowed: true,
+		},
+		{
+			desc:    "zero",
+			value:   "0",
+			allowed: true,
+		},
+		{
+			desc:    "limit",
+			value:   "10",
+			allowed: true,
+		},
+		{
+			desc:  "negative",
+			value: "-1",
+		},
+		{
+			desc:  "not a number",
+			value: "ten",
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+		s.T().Run(test.desc, func(t *testing.T) {
+			t.Parallel()
+
+			validator := setting.NewValidator(nil, nil, nil, nil)
+			s.testAdmit(t, validator, &v3.Setting{
+				ObjectMeta: metav1.ObjectMeta{
+					Name: common.MaxProjectsPerUserSetting,
+				},
+			}, &v3.Setting{
+				ObjectMeta: metav1.ObjectMeta{
+					Name: common.MaxProjectsPerUserSetting,
+				},
+				Value: test.value,
+			}, op, test.allowed)
+		})
+	}
+}
+
+func (s *SettingSuite) TestValidateLegacyResourceLockdownOnUpdate() {
+	s.validateLegacyResourceLockdown(v1.Update)
+}
+
+func (s *SettingSuite) TestValidateLegacyResourceLockdownOnCreate() {
+	s.validateLegacyResourceLockdown(v1.Create)
+}
+
+func (s *SettingSuite) validateLegacyResourceLockdown(op v1.Operation) {
+	tests := []struct {
+		desc    string
+		value   string
+		allowed bool
+	}{
+		{
+			desc:    "defaults",
+			value:   "",
+			allowed: true,
+		},
+		{
+			desc:    "none",
+			value:   "none",
+			allowed: true,
+		},
+		{
+			desc:    "resources",
+			value:   "composeconfigs, catalogs",
+			allowed: true,
+		},
+		{
+			desc:  "not a legacy resource",
+			value: "composeconfigs,clusters",
+		},
+		{
+			desc:  "empty resource",
+			value: "composeconfigs,",
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+		s.T().Run(test.desc, func(t *testing.T) {
+			t.Parallel()
+
+			validator := setting.NewValidator(nil, nil, nil, nil)
+			s.testAdmit(t, validator, &v3.Setting{
+				ObjectMeta: metav1.ObjectMeta{
+					Name: common.LegacyResourceLockdownSetting,
+				},
+			}, &v3.Setting{
+				ObjectMeta: metav1.ObjectMeta{
+					Name: common.LegacyResourceLockdownSetting,
+				},
+				Value: test.value,
+			}, op, test.allowed)
+		})
+	}
+}
+
+func (s *SettingSuite) TestValidateUniqueClusterDisplayNamesOnUpdate() {
+	s.validateUniqueClusterDisplayNames(v1.Update)
+}
+
+func (s *SettingSuite) TestValidateUniqueClusterDisplayNamesOnCreate() {
+	s.validateUniqueClusterDisplayNames(v1.Create)
+}
+
+func (s *SettingSuite) validateUniqueClusterDisplayNames(op v1.Operation) {
+	tests := []struct {
+		desc    string
+		value   string
+		allowed bool
+	}{
+		{
+			desc:    "empty",
+			value:   "",
+			allowed: true,
+		},
+		{
+			desc:    "enabled",
+			value:   "true",
+			allowed: true,
+		},
+		{
+			desc:    "disabled",
+			value:   "false",
+			allowed: true,
+		},
+		{
+			desc:  "not a boolean",
+			value: "yes please",
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+		s.T().Run(test.desc, func(t *testing.T) {
+			t.Parallel()
+
+			validator := setting.NewValidator(nil, nil, nil, nil)
+			s.testAdmit(t, validator, &v3.Setting{
+				ObjectMeta: metav1.ObjectMeta{
+					Name: common.UniqueClusterDisplayNamesSetting,
+				},
+			}, &v3.Setting{
+				ObjectMeta: metav1.ObjectMeta{
+					Name: common.UniqueClusterDisplayNamesSetting,
+				},
+				Value: test.value,
+			}, op, test.allowed)
+		})
+	}
+}
+
 func (s *SettingSuite) TestValidateUserLastLoginDefaultOnUpdate() {
 	s.validateUserLastLoginDefault(v1.Update)
 }
@@ -334,7 +688,7 @@ func (s *SettingSuite) validateUserLastLoginDefault(op v1.Operation) {
 		s.T().Run(test.desc, func(t *testing.T) {
 			t.Parallel()
 
-			validator := setting.NewValidator(nil, nil)
+			validator := setting.NewValidator(nil, nil, nil, nil)
 			s.testAdmit(t, validator, &v3.Setting{
 				ObjectMeta: metav1.ObjectMeta{
 					Name: setting.UserLastLoginDefault,
@@ -509,7 +863,7 @@ func (s *SettingSuite) validateAuthUserSessionTTLMinutes(op v1.Operation) {
 				return setting, nil
 			}).AnyTimes()
 
-			validator := setting.NewValidator(nil, settingCache)
+			validator := setting.NewValidator(nil, settingCache, nil, nil)
 			s.testAdmit(t, validator, &v3.Setting{
 				ObjectMeta: metav1.ObjectMeta{
 					Name: setting.AuthUserSessionTTLMinutes,
@@ -538,7 +892,7 @@ func (s *SettingSuite) testAdmit(t *testing.T, validator *setting.Validator, old
 
 func (s *SettingSuite) TestValidatingWebhookFailurePolicy() {
 	t := s.T()
-	validator := setting.NewValidator(nil, nil)
+	validator := setting.NewValidator(nil, nil, nil, nil)
 
 	webhook := validator.ValidatingWebhook(admissionregistrationv1.WebhookClientConfig{})
 	require.Len(t, webhook, 1)
@@ -987,7 +1341,7 @@ func TestValidateAgentTLSMode(t *testing.T) {
 			if tc.clusterListerFails {
 				clusterCache.EXPECT().List(gomock.Any()).Return(tc.clusters, errors.New("some error"))
 			}
-			v := setting.NewValidator(clusterCache, nil)
+			v := setting.NewValidator(clusterCache, nil, nil, nil)
 			admitters := v.Admitters()
 			require.Len(t, admitters, 1)
 
@@ -1012,3 +1366,141 @@ func TestValidateAgentTLSMode(t *testing.T) {
 		})
 	}
 }
+
+func TestValidateSettingPolicy(t *testing.T) {
+	t.Parallel()
+
+	policies := map[string]string{
+		"server-url": "groups: [\"local://g-admins\"]\nverb: manage",
+		"auth-image": "groups: [\"local://g-admins\"]",
+		"ui-brand":   "verb: manage",
+		"ui-pl":      "{}",
+		"ui-index":   "roles: [admin]",
+	}
+	tests := []struct {
+		name          string
+		setting       string
+		oldValue      string
+		newValue      string
+		groups        []string
+		sarAllowed    bool
+		configMapErr  error
+		noConfigMap   bool
+		wantAllowed   bool
+		wantErr       bool
+		wantSARCalled bool
+	}{
+		{
+			name:        "no policy",
+			setting:     "engine-iso-url",
+			newValue:    "https://example.com",
+			wantAllowed: true,
+		},
+		{
+			name:        "no policy ConfigMap",
+			setting:     "server-url",
+			newValue:    "https://example.com",
+			noConfigMap: true,
+			wantAllowed: true,
+		},
+		{
+			name:        "unchanged value",
+			setting:     "server-url",
+			oldValue:    "https://example.com",
+			newValue:    "https://example.com",
+			wantAllowed: true,
+		},
+		{
+			name:        "member of an allowed group",
+			setting:     "server-url",
+			newValue:    "https://example.com",
+			groups:      []string{"system:authenticated", "local://g-admins"},
+			wantAllowed: true,
+		},
+		{
+			name:          "allowed verb",
+			setting:       "server-url",
+			newValue:      "https://example.com",
+			sarAllowed:    true,
+			wantAllowed:   true,
+			wantSARCalled: true,
+		},
+		{
+			name:          "neither in group nor allowed verb",
+			setting:       "ui-brand",
+			oldValue:      "rancher",
+			newValue:      "suse",
+			wantSARCalled: true,
+		},
+		{
+			name:     "not in group without verb",
+			setting:  "auth-image",
+			newValue: "rancher/auth:v1",
+			groups:   []string{"system:authenticated"},
+		},
+		{
+			name:        "empty policy is ignored",
+			setting:     "ui-pl",
+			newValue:    "SUSE",
+			wantAllowed: true,
+		},
+		{
+			name:        "invalid policy is ignored",
+			setting:     "ui-index",
+			newValue:    "https://example.com",
+			wantAllowed: true,
+		},
+		{
+			name:         "ConfigMap error",
+			setting:      "server-url",
+			newValue:     "https://example.com",
+			configMapErr: errors.New("cache error"),
+			wantErr:      true,
+		},
+	}
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+			ctrl := gomock.NewController(t)
+			configMapCache := fake.NewMockCacheInterface[*corev1.ConfigMap](ctrl)
+			configMapCache.EXPECT().Get(setting.PolicyConfigMapNamespace, setting.PolicyConfigMapName).DoAndReturn(func(_, name string) (*corev1.ConfigMap, error) {
+				if test.configMapErr != nil {
+					return nil, test.configMapErr
+				}
+				if test.noConfigMap {
+					return nil, apierrors.NewNotFound(corev1.Resource("configmaps"), name)
+				}
+				return &corev1.ConfigMap{Data: policies}, nil
+			}).AnyTimes()
+			sarCalled := false
+			k8Fake := &k8testing.Fake{}
+			k8Fake.AddReactor("create", "subjectaccessreviews", func(action k8testing.Action) (bool, runtime.Object, error) {
+				sarCalled = true
+				review := action.(k8testing.CreateActionImpl).GetObject().(*authorizationv1.SubjectAccessReview)
+				assert.Equal(t, "manage", review.Spec.ResourceAttributes.Verb)
+				assert.Equal(t, test.setting, review.Spec.ResourceAttributes.Name)
+				review.Status.Allowed = test.sarAllowed
+				return true, review, nil
+			})
+			sar := &k8fake.FakeSubjectAccessReviews{Fake: &k8fake.FakeAuthorizationV1{Fake: k8Fake}}
+			validator := setting.NewValidator(nil, nil, configMapCache, sar)
+
+			oldSetting, err := json.Marshal(&v3.Setting{ObjectMeta: metav1.ObjectMeta{Name: test.setting}, Value: test.oldValue})
+			require.NoError(t, err)
+			newSetting, err := json.Marshal(&v3.Setting{ObjectMeta: metav1.ObjectMeta{Name: test.setting}, Value: test.newValue})
+			require.NoError(t, err)
+			request := newRequest(v1.Update, newSetting, oldSetting)
+			request.UserInfo.Groups = test.groups
+
+			response, err := validator.Admitters()[0].Admit(request)
+			if test.wantErr {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, test.wantAllowed, response.Allowed, response.Result)
+			assert.Equal(t, test.wantSARCalled, sarCalled)
+		})
+	}
+}
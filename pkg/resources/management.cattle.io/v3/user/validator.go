@@ -0,0 +1,263 @@
+// Package user is used for validating users.
+package user
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	v3 "github.com/rancher/rancher/pkg/apis/management.cattle.io/v3"
+	"github.com/rancher/webhook/pkg/admission"
+	"github.com/rancher/webhook/pkg/auth"
+	controllerv3 "github.com/rancher/webhook/pkg/generated/controllers/management.cattle.io/v3"
+	objectsv3 "github.com/rancher/webhook/pkg/generated/objects/management.cattle.io/v3"
+	"github.com/rancher/webhook/pkg/resources/common"
+	admissionv1 "k8s.io/api/admission/v1"
+	admissionregistrationv1 "k8s.io/api/admissionregistration/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	authorizationv1 "k8s.io/client-go/kubernetes/typed/authorization/v1"
+	"k8s.io/utils/trace"
+)
+
+const (
+	// CascadeDeleteAnn must be set to "true" on a user to delete it while bindings or active tokens still reference it.
+	CascadeDeleteAnn = "webhook.cattle.io/cascade-delete"
+
+	grbByUserIndex  = "management.cattle.io/grb-by-user"
+	crtbByUserIndex = "management.cattle.io/crtb-by-user"
+	// tokenUserIDLabel is set by Rancher on tokens to the name of the user owning them.
+	tokenUserIDLabel = "authn.management.cattle.io/token-userId"
+	// maxListedReferences bounds the number of references of each kind listed in the denial message.
+	maxListedReferences = 5
+	// activateVerb is the verb users need on a user to re-enable it.
+	activateVerb = "activate"
+)
+
+var gvr = schema.GroupVersionResource{
+	Group:    "management.cattle.io",
+	Version:  "v3",
+	Resource: "users",
+}
+
+// NewValidator returns a new validator for users.
+func NewValidator(grbCache controllerv3.GlobalRoleBindingCache, crtbCache controllerv3.ClusterRoleTemplateBindingCache,
+	prtbCache controllerv3.ProjectRoleTemplateBindingCache, tokenClient controllerv3.TokenClient, sar authorizationv1.SubjectAccessReviewInterface) *Validator {
+	grbCache.AddIndexer(grbByUserIndex, grbByUser)
+	crtbCache.AddIndexer(crtbByUserIndex, crtbByUser)
+	prtbCache.AddIndexer(common.PRTBByUserIndex, common.PRTBByUser)
+	return &Validator{
+		admitter: admitter{
+			grbCache:    grbCache,
+			crtbCache:   crtbCache,
+			prtbCache:   prtbCache,
+			tokenClient: tokenClient,
+			sar:         sar,
+		},
+	}
+}
+
+// Validator for validating users.
+type Validator struct {
+	admitter admitter
+}
+
+// GVR returns the GroupVersionKind for this CRD.
+func (v *Validator) GVR() schema.GroupVersionResource {
+	return gvr
+}
+
+// Operations returns list of operations handled by this validator.
+func (v *Validator) Operations() []admissionregistrationv1.OperationType {
+	return []admissionregistrationv1.OperationType{admissionregistrationv1.Update, admissionregistrationv1.Delete}
+}
+
+// ValidatingWebhook returns the ValidatingWebhook used for this CRD.
+func (v *Validator) ValidatingWebhook(clientConfig admissionregistrationv1.WebhookClientConfig) []admissionregistrationv1.ValidatingWebhook {
+	return []admissionregistrationv1.ValidatingWebhook{*admission.NewDefaultValidatingWebhook(v, clientConfig, admissionregistrationv1.ClusterScope, v.Operations())}
+}
+
+// Admitters returns the admitter objects used to validate users.
+func (v *Validator) Admitters() []admission.Admitter {
+	return []admission.Admitter{&v.admitter}
+}
+
+type admitter struct {
+	grbCache    controllerv3.GlobalRoleBindingCache
+	crtbCache   controllerv3.ClusterRoleTemplateBindingCache
+	prtbCache   controllerv3.ProjectRoleTemplateBindingCache
+	tokenClient controllerv3.TokenClient
+	sar         authorizationv1.SubjectAccessReviewInterface
+}
+
+// Admit handles the webhook admission request sent to this webhook.
+func (a *admitter) Admit(request *admission.Request) (*admissionv1.AdmissionResponse, error) {
+	listTrace := trace.New("userValidator Admit", trace.Field{Key: "user", Value: request.UserInfo.Username})
+	defer listTrace.LogIfLong(admission.SlowTraceDuration)
+
+	switch request.Operation {
+	case admissionv1.Update:
+		return a.admitUpdate(request)
+	case admissionv1.Delete:
+		return a.admitDelete(request)
+	default:
+		return admission.ResponseAllowed(), nil
+	}
+}
+
+// admitUpdate only allows users to be re-enabled by users, other than themselves, allowed to activate them.
+func (a *admitter) admitUpdate(request *admission.Request) (*admissionv1.AdmissionResponse, error) {
+	// The auth providers' controllers re-enable users whose accounts were re-enabled in the provider.
+	if admission.IsController(request) {
+		return admission.ResponseAllowed(), nil
+	}
+
+	oldUser, newUser, err := objectsv3.UserOldAndNewFromRequest(&request.AdmissionRequest)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get old and new users from request: %w", err)
+	}
+	if isEnabled(oldUser) || !isEnabled(newUser) {
+		return admission.ResponseAllowed(), nil
+	}
+	if request.UserInfo.Username == newUser.Name {
+		return admission.ResponseFailedEscalation(fmt.Sprintf("user %s cannot re-enable itself", newUser.Name)), nil
+	}
+	canActivate, err := auth.RequestUserHasVerb(request, gvr, a.sar, activateVerb, newUser.Name, "")
+	if err != nil {
+		return nil, fmt.Errorf("failed to check if %s can activate user %s: %w", request.UserInfo.Username, newUser.Name, err)
+	}
+	if !canActivate {
+		return admission.ResponseFailedEscalation(fmt.Sprintf("user %s is not allowed to %s user %s",
+			request.UserInfo.Username, activateVerb, newUser.Name)), nil
+	}
+	return admission.ResponseAllowed(), nil
+}
+
+// admitDelete denies the deletion of users which are still referenced, unless the deletion is forced.
+func (a *admitter) admitDelete(request *admission.Request) (*admissionv1.AdmissionResponse, error) {
+	// Rancher removes the bindings and tokens of deleted users, and deletes users itself, e.g. for user retention.
+	if admission.IsController(request) {
+		return admission.ResponseAllowed(), nil
+	}
+
+	user, err := objectsv3.UserFromRequest(&request.AdmissionRequest)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get user from request: %w", err)
+	}
+	if user.Annotations[CascadeDeleteAnn] == "true" {
+		return admission.ResponseAllowed(), nil
+	}
+
+	references, err := a.references(user.Name)
+	if err != nil {
+		return nil, err
+	}
+	if len(references) == 0 {
+		return admission.ResponseAllowed(), nil
+	}
+	return admission.ResponseBadRequest(fmt.Sprintf("user %s is still referenced by %s; set the %s annotation to \"true\" to delete it anyway",
+		user.Name, strings.Join(references, ", "), CascadeDeleteAnn)), nil
+}
+
+// references returns a summary of the bindings and active tokens referencing the user, one entry per kind.
+func (a *admitter) references(userName string) ([]string, error) {
+	var references []string
+
+	grbs, err := a.grbCache.GetByIndex(grbByUserIndex, userName)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list GlobalRoleBindings of user %s: %w", userName, err)
+	}
+	names := make([]string, 0, len(grbs))
+	for _, grb := range grbs {
+		names = append(names, grb.Name)
+	}
+	references = appendReferences(references, "GlobalRoleBinding", names)
+
+	crtbs, err := a.crtbCache.GetByIndex(crtbByUserIndex, userName)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list ClusterRoleTemplateBindings of user %s: %w", userName, err)
+	}
+	names = make([]string, 0, len(crtbs))
+	for _, crtb := range crtbs {
+		names = append(names, crtb.Namespace+"/"+crtb.Name)
+	}
+	references = appendReferences(references, "ClusterRoleTemplateBinding", names)
+
+	prtbs, err := a.prtbCache.GetByIndex(common.PRTBByUserIndex, userName)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list ProjectRoleTemplateBindings of user %s: %w", userName, err)
+	}
+	names = make([]string, 0, len(prtbs))
+	for _, prtb := range prtbs {
+		names = append(names, prtb.Namespace+"/"+prtb.Name)
+	}
+	references = appendReferences(references, "ProjectRoleTemplateBinding", names)
+
+	tokens, err := a.tokenClient.List(metav1.ListOptions{
+		LabelSelector: labels.SelectorFromSet(labels.Set{tokenUserIDLabel: userName}).String(),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list tokens of user %s: %w", userName, err)
+	}
+	names = make([]string, 0, len(tokens.Items))
+	now := time.Now()
+	for i := range tokens.Items {
+		if isActive(&tokens.Items[i], now) {
+			names = append(names, tokens.Items[i].Name)
+		}
+	}
+	references = appendReferences(references, "Token", names)
+
+	return references, nil
+}
+
+// appendReferences appends a summary of the references of the given kind, listing at most maxListedReferences names.
+func appendReferences(references []string, kind string, names []string) []string {
+	if len(names) == 0 {
+		return references
+	}
+	listed := names
+	if len(listed) > maxListedReferences {
+		listed = listed[:maxListedReferences]
+	}
+	summary := fmt.Sprintf("%d %s(s) (%s", len(names), kind, strings.Join(listed, ", "))
+	if len(names) > len(listed) {
+		summary += ", ..."
+	}
+	return append(references, summary+")")
+}
+
+// isEnabled returns true if the user is enabled, which is the default.
+func isEnabled(user *v3.User) bool {
+	return user.Enabled == nil || *user.Enabled
+}
+
+// isActive returns true if the token is enabled and not expired.
+func isActive(token *v3.Token, now time.Time) bool {
+	if token.Expired || (token.Enabled != nil && !*token.Enabled) {
+		return false
+	}
+	if token.ExpiresAt == "" {
+		return true
+	}
+	expiresAt, err := time.Parse(time.RFC3339, token.ExpiresAt)
+	if err != nil {
+		return true
+	}
+	return now.Before(expiresAt)
+}
+
+func grbByUser(grb *v3.GlobalRoleBinding) ([]string, error) {
+	if grb.UserName == "" {
+		return nil, nil
+	}
+	return []string{grb.UserName}, nil
+}
+
+func crtbByUser(crtb *v3.ClusterRoleTemplateBinding) ([]string, error) {
+	if crtb.UserName == "" {
+		return nil, nil
+	}
+	return []string{crtb.UserName}, nil
+}
@@ -0,0 +1,264 @@
+package user
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	v3 "github.com/rancher/rancher/pkg/apis/management.cattle.io/v3"
+	"github.com/rancher/webhook/pkg/admission"
+	"github.com/rancher/webhook/pkg/resources/common"
+	"github.com/rancher/wrangler/v3/pkg/generic/fake"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+	admissionv1 "k8s.io/api/admission/v1"
+	authenticationv1 "k8s.io/api/authentication/v1"
+	authorizationv1 "k8s.io/api/authorization/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	k8fake "k8s.io/client-go/kubernetes/typed/authorization/v1/fake"
+	k8testing "k8s.io/client-go/testing"
+)
+
+func TestAdmit(t *testing.T) {
+	t.Parallel()
+	future := time.Now().Add(time.Hour).UTC().Format(time.RFC3339)
+	past := time.Now().Add(-time.Hour).UTC().Format(time.RFC3339)
+
+	tests := []struct {
+		name        string
+		annotations map[string]string
+		groups      []string
+		grbs        []*v3.GlobalRoleBinding
+		crtbs       []*v3.ClusterRoleTemplateBinding
+		prtbs       []*v3.ProjectRoleTemplateBinding
+		tokens      []v3.Token
+		tokenErr    error
+		wantAllowed bool
+		wantErr     bool
+		wantMessage []string
+	}{
+		{
+			name:        "no references",
+			wantAllowed: true,
+		},
+		{
+			name:        "inactive tokens only",
+			tokens:      []v3.Token{{ObjectMeta: metav1.ObjectMeta{Name: "expired"}, Expired: true}, {ObjectMeta: metav1.ObjectMeta{Name: "disabled"}, Enabled: admission.Ptr(false)}, {ObjectMeta: metav1.ObjectMeta{Name: "past"}, ExpiresAt: past}},
+			wantAllowed: true,
+		},
+		{
+			name:   "bindings and active tokens",
+			grbs:   []*v3.GlobalRoleBinding{{ObjectMeta: metav1.ObjectMeta{Name: "grb-user-base"}}},
+			crtbs:  []*v3.ClusterRoleTemplateBinding{{ObjectMeta: metav1.ObjectMeta{Name: "crtb-abc", Namespace: "c-12345"}}},
+			prtbs:  []*v3.ProjectRoleTemplateBinding{{ObjectMeta: metav1.ObjectMeta{Name: "prtb-abc", Namespace: "p-12345"}}},
+			tokens: []v3.Token{{ObjectMeta: metav1.ObjectMeta{Name: "token-abc"}, ExpiresAt: future}, {ObjectMeta: metav1.ObjectMeta{Name: "token-expired"}, Expired: true}},
+			wantMessage: []string{
+				"1 GlobalRoleBinding(s) (grb-user-base)",
+				"1 ClusterRoleTemplateBinding(s) (c-12345/crtb-abc)",
+				"1 ProjectRoleTemplateBinding(s) (p-12345/prtb-abc)",
+				"1 Token(s) (token-abc)",
+			},
+		},
+		{
+			name: "many references are truncated",
+			grbs: []*v3.GlobalRoleBinding{
+				{ObjectMeta: metav1.ObjectMeta{Name: "grb-1"}}, {ObjectMeta: metav1.ObjectMeta{Name: "grb-2"}}, {ObjectMeta: metav1.ObjectMeta{Name: "grb-3"}},
+				{ObjectMeta: metav1.ObjectMeta{Name: "grb-4"}}, {ObjectMeta: metav1.ObjectMeta{Name: "grb-5"}}, {ObjectMeta: metav1.ObjectMeta{Name: "grb-6"}},
+			},
+			wantMessage: []string{"6 GlobalRoleBinding(s) (grb-1, grb-2, grb-3, grb-4, grb-5, ...)"},
+		},
+		{
+			name:        "cascade annotation",
+			annotations: map[string]string{CascadeDeleteAnn: "true"},
+			grbs:        []*v3.GlobalRoleBinding{{ObjectMeta: metav1.ObjectMeta{Name: "grb-user-base"}}},
+			wantAllowed: true,
+		},
+		{
+			name:        "deleted by a controller",
+			groups:      []string{"system:serviceaccounts:cattle-system"},
+			grbs:        []*v3.GlobalRoleBinding{{ObjectMeta: metav1.ObjectMeta{Name: "grb-user-base"}}},
+			wantAllowed: true,
+		},
+		{
+			name:     "failure to list tokens",
+			tokenErr: errors.New("unexpected error"),
+			wantErr:  true,
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+			ctrl := gomock.NewController(t)
+			grbCache := fake.NewMockNonNamespacedCacheInterface[*v3.GlobalRoleBinding](ctrl)
+			grbCache.EXPECT().AddIndexer(grbByUserIndex, gomock.Any())
+			grbCache.EXPECT().GetByIndex(grbByUserIndex, "u-12345").Return(test.grbs, nil).AnyTimes()
+			crtbCache := fake.NewMockCacheInterface[*v3.ClusterRoleTemplateBinding](ctrl)
+			crtbCache.EXPECT().AddIndexer(crtbByUserIndex, gomock.Any())
+			crtbCache.EXPECT().GetByIndex(crtbByUserIndex, "u-12345").Return(test.crtbs, nil).AnyTimes()
+			prtbCache := fake.NewMockCacheInterface[*v3.ProjectRoleTemplateBinding](ctrl)
+			prtbCache.EXPECT().AddIndexer(common.PRTBByUserIndex, gomock.Any())
+			prtbCache.EXPECT().GetByIndex(common.PRTBByUserIndex, "u-12345").Return(test.prtbs, nil).AnyTimes()
+			tokenClient := fake.NewMockNonNamespacedClientInterface[*v3.Token, *v3.TokenList](ctrl)
+			tokenClient.EXPECT().List(metav1.ListOptions{LabelSelector: tokenUserIDLabel + "=u-12345"}).
+				Return(&v3.TokenList{Items: test.tokens}, test.tokenErr).AnyTimes()
+
+			raw, err := json.Marshal(&v3.User{ObjectMeta: metav1.ObjectMeta{Name: "u-12345", Annotations: test.annotations}})
+			require.NoError(t, err)
+
+			admitters := NewValidator(grbCache, crtbCache, prtbCache, tokenClient, nil).Admitters()
+			require.Len(t, admitters, 1)
+			response, err := admitters[0].Admit(&admission.Request{
+				Context: context.Background(),
+				AdmissionRequest: admissionv1.AdmissionRequest{
+					Operation: admissionv1.Delete,
+					Name:      "u-12345",
+					UserInfo:  authenticationv1.UserInfo{Username: "admin", Groups: test.groups},
+					OldObject: runtime.RawExtension{Raw: raw},
+				},
+			})
+			if test.wantErr {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, test.wantAllowed, response.Allowed)
+			if !test.wantAllowed {
+				assert.Equal(t, int32(http.StatusBadRequest), response.Result.Code)
+				for _, message := range test.wantMessage {
+					assert.Contains(t, response.Result.Message, message)
+				}
+			}
+		})
+	}
+}
+
+func TestAdmitUpdate(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name        string
+		oldEnabled  *bool
+		newEnabled  *bool
+		username    string
+		groups      []string
+		canActivate bool
+		wantSAR     bool
+		wantAllowed bool
+	}{
+		{
+			name:        "enabled user is updated",
+			newEnabled:  admission.Ptr(true),
+			username:    "admin",
+			wantAllowed: true,
+		},
+		{
+			name:        "user is disabled",
+			newEnabled:  admission.Ptr(false),
+			username:    "admin",
+			wantAllowed: true,
+		},
+		{
+			name:        "disabled user is updated",
+			oldEnabled:  admission.Ptr(false),
+			newEnabled:  admission.Ptr(false),
+			username:    "admin",
+			wantAllowed: true,
+		},
+		{
+			name:        "user is re-enabled with the activate verb",
+			oldEnabled:  admission.Ptr(false),
+			newEnabled:  admission.Ptr(true),
+			username:    "admin",
+			canActivate: true,
+			wantSAR:     true,
+			wantAllowed: true,
+		},
+		{
+			name:        "user is re-enabled by removing the field",
+			oldEnabled:  admission.Ptr(false),
+			username:    "admin",
+			canActivate: true,
+			wantSAR:     true,
+			wantAllowed: true,
+		},
+		{
+			name:       "user is re-enabled without the activate verb",
+			oldEnabled: admission.Ptr(false),
+			newEnabled: admission.Ptr(true),
+			username:   "admin",
+			wantSAR:    true,
+		},
+		{
+			name:        "user re-enables itself",
+			oldEnabled:  admission.Ptr(false),
+			newEnabled:  admission.Ptr(true),
+			username:    "u-12345",
+			canActivate: true,
+		},
+		{
+			name:        "user is re-enabled by a controller",
+			oldEnabled:  admission.Ptr(false),
+			newEnabled:  admission.Ptr(true),
+			username:    "system:serviceaccount:cattle-system:rancher",
+			groups:      []string{"system:serviceaccounts:cattle-system"},
+			wantAllowed: true,
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+			sarCalled := false
+			k8Fake := &k8testing.Fake{}
+			k8Fake.AddReactor("create", "subjectaccessreviews", func(action k8testing.Action) (bool, runtime.Object, error) {
+				sarCalled = true
+				review := action.(k8testing.CreateActionImpl).GetObject().(*authorizationv1.SubjectAccessReview)
+				assert.Equal(t, activateVerb, review.Spec.ResourceAttributes.Verb)
+				assert.Equal(t, "users", review.Spec.ResourceAttributes.Resource)
+				assert.Equal(t, "u-12345", review.Spec.ResourceAttributes.Name)
+				review.Status.Allowed = test.canActivate
+				return true, review, nil
+			})
+			sar := &k8fake.FakeSubjectAccessReviews{Fake: &k8fake.FakeAuthorizationV1{Fake: k8Fake}}
+
+			ctrl := gomock.NewController(t)
+			grbCache := fake.NewMockNonNamespacedCacheInterface[*v3.GlobalRoleBinding](ctrl)
+			grbCache.EXPECT().AddIndexer(grbByUserIndex, gomock.Any())
+			crtbCache := fake.NewMockCacheInterface[*v3.ClusterRoleTemplateBinding](ctrl)
+			crtbCache.EXPECT().AddIndexer(crtbByUserIndex, gomock.Any())
+			prtbCache := fake.NewMockCacheInterface[*v3.ProjectRoleTemplateBinding](ctrl)
+			prtbCache.EXPECT().AddIndexer(common.PRTBByUserIndex, gomock.Any())
+
+			oldRaw, err := json.Marshal(&v3.User{ObjectMeta: metav1.ObjectMeta{Name: "u-12345"}, Enabled: test.oldEnabled})
+			require.NoError(t, err)
+			newRaw, err := json.Marshal(&v3.User{ObjectMeta: metav1.ObjectMeta{Name: "u-12345"}, Enabled: test.newEnabled})
+			require.NoError(t, err)
+
+			admitters := NewValidator(grbCache, crtbCache, prtbCache, nil, sar).Admitters()
+			response, err := admitters[0].Admit(&admission.Request{
+				Context: context.Background(),
+				AdmissionRequest: admissionv1.AdmissionRequest{
+					Operation: admissionv1.Update,
+					Name:      "u-12345",
+					UserInfo:  authenticationv1.UserInfo{Username: test.username, Groups: test.groups},
+					OldObject: runtime.RawExtension{Raw: oldRaw},
+					Object:    runtime.RawExtension{Raw: newRaw},
+				},
+			})
+			require.NoError(t, err)
+			assert.Equal(t, test.wantAllowed, response.Allowed)
+			assert.Equal(t, test.wantSAR, sarCalled)
+			if !test.wantAllowed {
+				assert.Equal(t, int32(http.StatusForbidden), response.Result.Code)
+			}
+		})
+	}
+}
@@ -0,0 +1,113 @@
+package userattribute
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+
+	apisv3 "github.com/rancher/rancher/pkg/apis/management.cattle.io/v3"
+	"github.com/rancher/webhook/pkg/admission"
+	objectsv3 "github.com/rancher/webhook/pkg/generated/objects/management.cattle.io/v3"
+	"github.com/rancher/webhook/pkg/patch"
+	admissionv1 "k8s.io/api/admission/v1"
+	admissionregistrationv1 "k8s.io/api/admissionregistration/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	"k8s.io/utils/trace"
+)
+
+const (
+	// MaxGroupPrincipalsEnv is the environment variable used to configure the maximum number of group principals a
+	// UserAttribute can hold for a single auth provider.
+	MaxGroupPrincipalsEnv = "CATTLE_WEBHOOK_MAX_GROUP_PRINCIPALS"
+
+	defaultMaxGroupPrincipals = 5000
+)
+
+// MaxGroupPrincipalsFromEnv returns the value of MaxGroupPrincipalsEnv, falling back to the default if it is unset.
+func MaxGroupPrincipalsFromEnv() (int, error) {
+	value := os.Getenv(MaxGroupPrincipalsEnv)
+	if value == "" {
+		return defaultMaxGroupPrincipals, nil
+	}
+	parsed, err := strconv.Atoi(value)
+	if err != nil || parsed <= 0 {
+		return 0, fmt.Errorf("invalid value '%s' for %s: must be a positive integer", value, MaxGroupPrincipalsEnv)
+	}
+	return parsed, nil
+}
+
+// Mutator implements admission.MutatingAdmissionWebhook.
+type Mutator struct {
+	maxGroupPrincipals int
+}
+
+// NewMutator returns a new mutator for UserAttributes which allows at most maxGroupPrincipals group principals per
+// auth provider.
+func NewMutator(maxGroupPrincipals int) *Mutator {
+	return &Mutator{
+		maxGroupPrincipals: maxGroupPrincipals,
+	}
+}
+
+// GVR returns the GroupVersionKind for this CRD.
+func (m *Mutator) GVR() schema.GroupVersionResource {
+	return gvr
+}
+
+// Operations returns list of operations handled by this mutator.
+func (m *Mutator) Operations() []admissionregistrationv1.OperationType {
+	return []admissionregistrationv1.OperationType{admissionregistrationv1.Create, admissionregistrationv1.Update}
+}
+
+// MutatingWebhook returns the MutatingWebhook used for this CRD.
+func (m *Mutator) MutatingWebhook(clientConfig admissionregistrationv1.WebhookClientConfig) []admissionregistrationv1.MutatingWebhook {
+	mutatingWebhook := admission.NewDefaultMutatingWebhook(m, clientConfig, admissionregistrationv1.ClusterScope, m.Operations())
+	return []admissionregistrationv1.MutatingWebhook{*mutatingWebhook}
+}
+
+// Admit handles the webhook admission request sent to this webhook.
+func (m *Mutator) Admit(request *admission.Request) (*admissionv1.AdmissionResponse, error) {
+	listTrace := trace.New("userAttributeMutator Admit", trace.Field{Key: "user", Value: request.UserInfo.Username})
+	defer listTrace.LogIfLong(admission.SlowTraceDuration)
+
+	attribute, err := objectsv3.UserAttributeFromRequest(&request.AdmissionRequest)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get %s from request: %w", gvr.Resource, err)
+	}
+
+	if fieldErr := m.normalizeGroupPrincipals(attribute); fieldErr != nil {
+		return admission.ResponseBadRequest(fieldErr.Error()), nil
+	}
+
+	response := &admissionv1.AdmissionResponse{}
+	if err := patch.CreatePatch(request.Object.Raw, attribute, response); err != nil {
+		return nil, fmt.Errorf("failed to create patch: %w", err)
+	}
+	response.Allowed = true
+	return response, nil
+}
+
+// normalizeGroupPrincipals removes duplicated group principals and sorts them by name for each provider. Lists which
+// still hold more than the maximum number of principals are rejected rather than truncated, since dropping principals
+// would silently remove the user's access granted through those groups.
+func (m *Mutator) normalizeGroupPrincipals(attribute *apisv3.UserAttribute) *field.Error {
+	for provider, principals := range attribute.GroupPrincipals {
+		seen := make(map[string]bool, len(principals.Items))
+		items := make([]apisv3.Principal, 0, len(principals.Items))
+		for _, principal := range principals.Items {
+			if seen[principal.Name] {
+				continue
+			}
+			seen[principal.Name] = true
+			items = append(items, principal)
+		}
+		if len(items) > m.maxGroupPrincipals {
+			return field.TooMany(field.NewPath("GroupPrincipals").Key(provider).Child("Items"), len(items), m.maxGroupPrincipals)
+		}
+		sort.SliceStable(items, func(i, j int) bool { return items[i].Name < items[j].Name })
+		attribute.GroupPrincipals[provider] = apisv3.Principals{Items: items}
+	}
+	return nil
+}
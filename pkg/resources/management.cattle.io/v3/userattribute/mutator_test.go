@@ -0,0 +1,141 @@
+package userattribute_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	jsonpatch "github.com/evanphx/json-patch"
+	apisv3 "github.com/rancher/rancher/pkg/apis/management.cattle.io/v3"
+	"github.com/rancher/webhook/pkg/admission"
+	"github.com/rancher/webhook/pkg/resources/management.cattle.io/v3/userattribute"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	v1 "k8s.io/api/admission/v1"
+	authenticationv1 "k8s.io/api/authentication/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+func principals(names ...string) apisv3.Principals {
+	items := make([]apisv3.Principal, 0, len(names))
+	for _, name := range names {
+		items = append(items, apisv3.Principal{ObjectMeta: metav1.ObjectMeta{Name: name}})
+	}
+	return apisv3.Principals{Items: items}
+}
+
+func TestMutatorAdmit(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name      string
+		groups    map[string]apisv3.Principals
+		wantGroup map[string]apisv3.Principals
+		allowed   bool
+	}{
+		{
+			name:    "no group principals",
+			allowed: true,
+		},
+		{
+			name: "duplicated principals are removed and sorted",
+			groups: map[string]apisv3.Principals{
+				"github": principals("github_team://3", "github_team://1", "github_team://3", "github_team://2"),
+				"ldap":   principals("ldap_group://b", "ldap_group://a", "ldap_group://b"),
+			},
+			wantGroup: map[string]apisv3.Principals{
+				"github": principals("github_team://1", "github_team://2", "github_team://3"),
+				"ldap":   principals("ldap_group://a", "ldap_group://b"),
+			},
+			allowed: true,
+		},
+		{
+			name: "duplicates do not count towards the limit",
+			groups: map[string]apisv3.Principals{
+				"github": principals("github_team://1", "github_team://2", "github_team://3", "github_team://1"),
+			},
+			wantGroup: map[string]apisv3.Principals{
+				"github": principals("github_team://1", "github_team://2", "github_team://3"),
+			},
+			allowed: true,
+		},
+		{
+			name: "too many principals for a provider",
+			groups: map[string]apisv3.Principals{
+				"github": principals("github_team://1", "github_team://2", "github_team://3", "github_team://4"),
+			},
+			allowed: false,
+		},
+	}
+
+	mutator := userattribute.NewMutator(3)
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+			raw, err := json.Marshal(&apisv3.UserAttribute{
+				ObjectMeta:      metav1.ObjectMeta{Name: "u-12345"},
+				GroupPrincipals: test.groups,
+			})
+			require.NoError(t, err)
+
+			request := &admission.Request{
+				Context: context.Background(),
+				AdmissionRequest: v1.AdmissionRequest{
+					Kind:      gvk,
+					Resource:  gvr,
+					Operation: v1.Update,
+					UserInfo:  authenticationv1.UserInfo{Username: "user"},
+					Object:    runtime.RawExtension{Raw: raw},
+				},
+			}
+			response, err := mutator.Admit(request)
+			require.NoError(t, err)
+			require.Equal(t, test.allowed, response.Allowed)
+			if !test.allowed {
+				assert.Equal(t, int32(http.StatusBadRequest), response.Result.Code)
+				return
+			}
+
+			if test.wantGroup == nil {
+				assert.Nil(t, response.Patch)
+				return
+			}
+			patchObj, err := jsonpatch.DecodePatch(response.Patch)
+			require.NoError(t, err)
+			patched, err := patchObj.Apply(raw)
+			require.NoError(t, err)
+			got := &apisv3.UserAttribute{}
+			require.NoError(t, json.Unmarshal(patched, got))
+			assert.Equal(t, test.wantGroup, got.GroupPrincipals)
+		})
+	}
+}
+
+func TestMaxGroupPrincipalsFromEnv(t *testing.T) {
+	tests := []struct {
+		value   string
+		want    int
+		wantErr bool
+	}{
+		{value: "", want: 5000},
+		{value: "100", want: 100},
+		{value: "0", wantErr: true},
+		{value: "-1", wantErr: true},
+		{value: "many", wantErr: true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.value, func(t *testing.T) {
+			t.Setenv(userattribute.MaxGroupPrincipalsEnv, test.value)
+			got, err := userattribute.MaxGroupPrincipalsFromEnv()
+			if test.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, test.want, got)
+		})
+	}
+}
@@ -5,11 +5,18 @@ import (
 	"fmt"
 	"time"
 
+	apisv3 "github.com/rancher/rancher/pkg/apis/management.cattle.io/v3"
 	"github.com/rancher/webhook/pkg/admission"
+	"github.com/rancher/webhook/pkg/auth"
+	v3 "github.com/rancher/webhook/pkg/generated/controllers/management.cattle.io/v3"
+	objectsv3 "github.com/rancher/webhook/pkg/generated/objects/management.cattle.io/v3"
+	"github.com/rancher/webhook/pkg/resources/common"
+	"github.com/sirupsen/logrus"
 	admissionv1 "k8s.io/api/admission/v1"
 	admissionregistrationv1 "k8s.io/api/admissionregistration/v1"
 	"k8s.io/apimachinery/pkg/runtime/schema"
 	"k8s.io/apimachinery/pkg/util/validation/field"
+	authorizationv1 "k8s.io/client-go/kubernetes/typed/authorization/v1"
 	"k8s.io/utils/trace"
 )
 
@@ -25,9 +32,12 @@ type Validator struct {
 }
 
 // NewValidator returns a new Validator instance.
-func NewValidator() *Validator {
+func NewValidator(settingCache v3.SettingCache, sar authorizationv1.SubjectAccessReviewInterface) *Validator {
 	return &Validator{
-		admitter: admitter{},
+		admitter: admitter{
+			settingCache: settingCache,
+			sar:          sar,
+		},
 	}
 }
 
@@ -53,7 +63,10 @@ func (v *Validator) Admitters() []admission.Admitter {
 	return []admission.Admitter{&v.admitter}
 }
 
-type admitter struct{}
+type admitter struct {
+	settingCache v3.SettingCache
+	sar          authorizationv1.SubjectAccessReviewInterface
+}
 
 // Admit handles the webhook admission requests.
 func (a *admitter) Admit(request *admission.Request) (*admissionv1.AdmissionResponse, error) {
@@ -67,9 +80,92 @@ func (a *admitter) Admit(request *admission.Request) (*admissionv1.AdmissionResp
 		}
 	}
 
+	if request.Operation == admissionv1.Update {
+		oldAttribute, newAttribute, err := objectsv3.UserAttributeOldAndNewFromRequest(&request.AdmissionRequest)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get old and new %s from request: %w", gvr.Resource, err)
+		}
+
+		if response, err := a.validateRefreshInterval(oldAttribute, newAttribute); err != nil || response != nil {
+			return response, err
+		}
+
+		if response, err := a.validateGroupPrincipalRemoval(request, oldAttribute, newAttribute); err != nil || response != nil {
+			return response, err
+		}
+	}
+
 	return admission.ResponseAllowed(), nil
 }
 
+// validateRefreshInterval denies requesting a refresh of the group memberships of a user sooner than the duration of
+// the auth-user-refresh-min-interval setting after the last refresh, to prevent refresh storms against auth providers.
+func (a *admitter) validateRefreshInterval(oldAttribute, newAttribute *apisv3.UserAttribute) (*admissionv1.AdmissionResponse, error) {
+	if !newAttribute.NeedsRefresh || oldAttribute.NeedsRefresh || oldAttribute.LastRefresh == "" {
+		return nil, nil
+	}
+
+	value, err := common.GetSettingValue(a.settingCache, common.AuthUserRefreshMinIntervalSetting)
+	if err != nil {
+		return nil, err
+	}
+	if value == "" {
+		return nil, nil
+	}
+	minInterval, err := time.ParseDuration(value)
+	if err != nil {
+		// the setting is validated on write, don't block refreshes if it was set while bypassing the webhook
+		logrus.Warnf("[userattribute-validation] failed to parse setting %s: %v", common.AuthUserRefreshMinIntervalSetting, err)
+		return nil, nil
+	}
+
+	lastRefresh, err := time.Parse(time.RFC3339, oldAttribute.LastRefresh)
+	if err != nil {
+		return nil, nil
+	}
+	if next := lastRefresh.Add(minInterval); time.Now().Before(next) {
+		return admission.ResponseBadRequest(fmt.Sprintf("group memberships of user %s were refreshed at %s, the next refresh can be requested after %s",
+			newAttribute.Name, oldAttribute.LastRefresh, next.UTC().Format(time.RFC3339))), nil
+	}
+	return nil, nil
+}
+
+// validateGroupPrincipalRemoval denies the removal of group principals, which are managed by the auth providers, by
+// users who are not administrators.
+func (a *admitter) validateGroupPrincipalRemoval(request *admission.Request, oldAttribute, newAttribute *apisv3.UserAttribute) (*admissionv1.AdmissionResponse, error) {
+	removed := removedGroupPrincipal(oldAttribute.GroupPrincipals, newAttribute.GroupPrincipals)
+	if removed == "" {
+		return nil, nil
+	}
+
+	isAdmin, err := auth.RequestUserIsAdmin(request, a.sar)
+	if err != nil {
+		return nil, fmt.Errorf("failed to check if user is an administrator: %w", err)
+	}
+	if !isAdmin {
+		return admission.ResponseFailedEscalation(fmt.Sprintf("user %s cannot remove group principal %s from %s",
+			request.UserInfo.Username, removed, newAttribute.Name)), nil
+	}
+	return nil, nil
+}
+
+// removedGroupPrincipal returns the name of a group principal which is in oldGroups but not in newGroups, or an empty
+// string if no group principal was removed.
+func removedGroupPrincipal(oldGroups, newGroups map[string]apisv3.Principals) string {
+	for provider, oldPrincipals := range oldGroups {
+		names := make(map[string]bool, len(newGroups[provider].Items))
+		for _, principal := range newGroups[provider].Items {
+			names[principal.Name] = true
+		}
+		for _, principal := range oldPrincipals.Items {
+			if !names[principal.Name] {
+				return principal.Name
+			}
+		}
+	}
+	return ""
+}
+
 // PartialUserAttribute represents raw values of UserAttribute retention fields.
 type PartialUserAttribute struct {
 	LastLogin    *string `json:"lastLogin"`
@@ -3,17 +3,28 @@ package userattribute_test
 import (
 	"context"
 	"encoding/json"
+	"net/http"
 	"testing"
 	"time"
 
+	apisv3 "github.com/rancher/rancher/pkg/apis/management.cattle.io/v3"
 	"github.com/rancher/webhook/pkg/admission"
+	"github.com/rancher/webhook/pkg/resources/common"
 	"github.com/rancher/webhook/pkg/resources/management.cattle.io/v3/userattribute"
+	"github.com/rancher/wrangler/v3/pkg/generic/fake"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 	"github.com/stretchr/testify/suite"
+	"go.uber.org/mock/gomock"
 	v1 "k8s.io/api/admission/v1"
 	authenticationv1 "k8s.io/api/authentication/v1"
+	authorizationv1 "k8s.io/api/authorization/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	k8fake "k8s.io/client-go/kubernetes/typed/authorization/v1/fake"
+	k8testing "k8s.io/client-go/testing"
 	"k8s.io/utils/pointer"
 )
 
@@ -148,7 +159,7 @@ func (s *RetentionFieldsSuite) validate(op v1.Operation, allowed ...bool) {
 }
 
 func (s *RetentionFieldsSuite) setup() admission.Admitter {
-	validator := userattribute.NewValidator()
+	validator := userattribute.NewValidator(nil, nil)
 	s.Len(validator.Admitters(), 1, "expected 1 admitter")
 
 	return validator.Admitters()[0]
@@ -170,3 +181,186 @@ func newRequest(op v1.Operation, obj []byte) *admission.Request {
 		Context: context.Background(),
 	}
 }
+
+func TestValidateRefreshInterval(t *testing.T) {
+	t.Parallel()
+	recently := time.Now().Add(-time.Minute).UTC().Format(time.RFC3339)
+	longAgo := time.Now().Add(-time.Hour).UTC().Format(time.RFC3339)
+
+	tests := []struct {
+		name         string
+		oldAttribute *apisv3.UserAttribute
+		newAttribute *apisv3.UserAttribute
+		setting      *apisv3.Setting
+		allowed      bool
+	}{
+		{
+			name:         "refresh requested recently after the last refresh",
+			oldAttribute: &apisv3.UserAttribute{LastRefresh: recently},
+			newAttribute: &apisv3.UserAttribute{LastRefresh: recently, NeedsRefresh: true},
+			setting:      &apisv3.Setting{Value: "5m"},
+		},
+		{
+			name:         "refresh requested long after the last refresh",
+			oldAttribute: &apisv3.UserAttribute{LastRefresh: longAgo},
+			newAttribute: &apisv3.UserAttribute{LastRefresh: longAgo, NeedsRefresh: true},
+			setting:      &apisv3.Setting{Value: "5m"},
+			allowed:      true,
+		},
+		{
+			name:         "default of the setting is used",
+			oldAttribute: &apisv3.UserAttribute{LastRefresh: recently},
+			newAttribute: &apisv3.UserAttribute{LastRefresh: recently, NeedsRefresh: true},
+			setting:      &apisv3.Setting{Default: "5m"},
+		},
+		{
+			name:         "throttling disabled",
+			oldAttribute: &apisv3.UserAttribute{LastRefresh: recently},
+			newAttribute: &apisv3.UserAttribute{LastRefresh: recently, NeedsRefresh: true},
+			setting:      &apisv3.Setting{},
+			allowed:      true,
+		},
+		{
+			name:         "setting not found",
+			oldAttribute: &apisv3.UserAttribute{LastRefresh: recently},
+			newAttribute: &apisv3.UserAttribute{LastRefresh: recently, NeedsRefresh: true},
+			allowed:      true,
+		},
+		{
+			name:         "invalid setting",
+			oldAttribute: &apisv3.UserAttribute{LastRefresh: recently},
+			newAttribute: &apisv3.UserAttribute{LastRefresh: recently, NeedsRefresh: true},
+			setting:      &apisv3.Setting{Value: "often"},
+			allowed:      true,
+		},
+		{
+			name:         "never refreshed",
+			oldAttribute: &apisv3.UserAttribute{},
+			newAttribute: &apisv3.UserAttribute{NeedsRefresh: true},
+			setting:      &apisv3.Setting{Value: "5m"},
+			allowed:      true,
+		},
+		{
+			name:         "refresh completed",
+			oldAttribute: &apisv3.UserAttribute{LastRefresh: longAgo, NeedsRefresh: true},
+			newAttribute: &apisv3.UserAttribute{LastRefresh: recently},
+			setting:      &apisv3.Setting{Value: "5m"},
+			allowed:      true,
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+			ctrl := gomock.NewController(t)
+			settingCache := fake.NewMockNonNamespacedCacheInterface[*apisv3.Setting](ctrl)
+			settingCache.EXPECT().Get(common.AuthUserRefreshMinIntervalSetting).DoAndReturn(func(name string) (*apisv3.Setting, error) {
+				if test.setting == nil {
+					return nil, apierrors.NewNotFound(schema.GroupResource{}, name)
+				}
+				return test.setting, nil
+			}).AnyTimes()
+
+			admitters := userattribute.NewValidator(settingCache, nil).Admitters()
+			response, err := admitters[0].Admit(newUpdateRequest(t, test.oldAttribute, test.newAttribute))
+			require.NoError(t, err)
+			assert.Equal(t, test.allowed, response.Allowed)
+		})
+	}
+}
+
+func TestValidateGroupPrincipalRemoval(t *testing.T) {
+	t.Parallel()
+	groups := func(names ...string) map[string]apisv3.Principals {
+		items := make([]apisv3.Principal, 0, len(names))
+		for _, name := range names {
+			items = append(items, apisv3.Principal{ObjectMeta: metav1.ObjectMeta{Name: name}})
+		}
+		return map[string]apisv3.Principals{"github": {Items: items}}
+	}
+
+	tests := []struct {
+		name      string
+		oldGroups map[string]apisv3.Principals
+		newGroups map[string]apisv3.Principals
+		isAdmin   bool
+		wantSAR   bool
+		allowed   bool
+	}{
+		{
+			name:      "adding a group principal",
+			oldGroups: groups("github_team://1"),
+			newGroups: groups("github_team://1", "github_team://2"),
+			allowed:   true,
+		},
+		{
+			name:      "reordering group principals",
+			oldGroups: groups("github_team://1", "github_team://2"),
+			newGroups: groups("github_team://2", "github_team://1"),
+			allowed:   true,
+		},
+		{
+			name:      "non-admin removing a group principal",
+			oldGroups: groups("github_team://1", "github_team://2"),
+			newGroups: groups("github_team://1"),
+			wantSAR:   true,
+		},
+		{
+			name:      "non-admin removing a provider",
+			oldGroups: groups("github_team://1"),
+			wantSAR:   true,
+		},
+		{
+			name:      "admin removing a group principal",
+			oldGroups: groups("github_team://1", "github_team://2"),
+			newGroups: groups("github_team://1"),
+			isAdmin:   true,
+			wantSAR:   true,
+			allowed:   true,
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+			sarCalled := false
+			k8Fake := &k8testing.Fake{}
+			k8Fake.AddReactor("create", "subjectaccessreviews", func(action k8testing.Action) (bool, runtime.Object, error) {
+				sarCalled = true
+				review := action.(k8testing.CreateActionImpl).GetObject().(*authorizationv1.SubjectAccessReview)
+				assert.Equal(t, "*", review.Spec.ResourceAttributes.Verb)
+				assert.Equal(t, "*", review.Spec.ResourceAttributes.Resource)
+				review.Status.Allowed = test.isAdmin
+				return true, review, nil
+			})
+			sar := &k8fake.FakeSubjectAccessReviews{Fake: &k8fake.FakeAuthorizationV1{Fake: k8Fake}}
+
+			admitters := userattribute.NewValidator(nil, sar).Admitters()
+			response, err := admitters[0].Admit(newUpdateRequest(t,
+				&apisv3.UserAttribute{GroupPrincipals: test.oldGroups},
+				&apisv3.UserAttribute{GroupPrincipals: test.newGroups}))
+			require.NoError(t, err)
+			assert.Equal(t, test.allowed, response.Allowed)
+			assert.Equal(t, test.wantSAR, sarCalled)
+			if !test.allowed {
+				assert.Equal(t, int32(http.StatusForbidden), response.Result.Code)
+			}
+		})
+	}
+}
+
+func newUpdateRequest(t *testing.T, oldAttribute, newAttribute *apisv3.UserAttribute) *admission.Request {
+	t.Helper()
+	oldAttribute.Name = "u-12345"
+	newAttribute.Name = "u-12345"
+	oldRaw, err := json.Marshal(oldAttribute)
+	require.NoError(t, err)
+	newRaw, err := json.Marshal(newAttribute)
+	require.NoError(t, err)
+
+	request := newRequest(v1.Update, newRaw)
+	request.OldObject = runtime.RawExtension{Raw: oldRaw}
+	return request
+}
@@ -0,0 +1,115 @@
+package cluster
+
+import (
+	"embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"os"
+	"path"
+	"slices"
+	"strconv"
+	"strings"
+
+	v1 "github.com/rancher/rancher/pkg/apis/provisioning.cattle.io/v1"
+	"github.com/rancher/webhook/pkg/diff"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	openapierrors "k8s.io/kube-openapi/pkg/validation/errors"
+	"k8s.io/kube-openapi/pkg/validation/spec"
+	"k8s.io/kube-openapi/pkg/validation/strfmt"
+	"k8s.io/kube-openapi/pkg/validation/validate"
+)
+
+// ChartValuesValidationEnv is the environment variable enabling the validation of the chart values of built-in charts
+// against the schemas shipped with the webhook when "true".
+const ChartValuesValidationEnv = "CATTLE_WEBHOOK_CHART_VALUES_VALIDATION"
+
+// chartValuesSchemaFiles holds the JSON schemas of the values of the built-in charts, named after the chart.
+//
+//go:embed chartvalues/*.json
+var chartValuesSchemaFiles embed.FS
+
+// chartValuesSchemas are the validators of the values of charts, keyed by chart name.
+type chartValuesSchemas map[string]*validate.SchemaValidator
+
+// builtinChartValuesSchemas are the validators of the values of the built-in charts.
+var builtinChartValuesSchemas = mustLoadChartValuesSchemas()
+
+// ChartValuesValidationFromEnv returns whether chart values are validated against the schemas of the built-in charts.
+func ChartValuesValidationFromEnv() (bool, error) {
+	value := os.Getenv(ChartValuesValidationEnv)
+	if value == "" {
+		return false, nil
+	}
+	enabled, err := strconv.ParseBool(value)
+	if err != nil {
+		return false, fmt.Errorf("invalid value '%s' for %s: must be a boolean", value, ChartValuesValidationEnv)
+	}
+	return enabled, nil
+}
+
+func mustLoadChartValuesSchemas() chartValuesSchemas {
+	entries, err := chartValuesSchemaFiles.ReadDir("chartvalues")
+	if err != nil {
+		panic(err)
+	}
+	validators := make(chartValuesSchemas, len(entries))
+	for _, entry := range entries {
+		data, err := chartValuesSchemaFiles.ReadFile(path.Join("chartvalues", entry.Name()))
+		if err != nil {
+			panic(err)
+		}
+		schema := &spec.Schema{}
+		if err := json.Unmarshal(data, schema); err != nil {
+			panic(fmt.Sprintf("invalid chart values schema %s: %v", entry.Name(), err))
+		}
+		chart := strings.TrimSuffix(entry.Name(), ".json")
+		validators[chart] = validate.NewSchemaValidator(schema, nil, "", strfmt.Default)
+	}
+	return validators
+}
+
+// validateChartValues checks the values of the built-in charts in spec.rkeConfig.chartValues against their schema when
+// they change. Values of other charts aren't checked.
+func (p *provisioningAdmitter) validateChartValues(changes diff.ChangeSet, cluster *v1.Cluster) field.ErrorList {
+	if p.chartValuesSchemas == nil || cluster.Spec.RKEConfig == nil {
+		return nil
+	}
+	path := field.NewPath("spec", "rkeConfig", "chartValues")
+	var fieldErrs field.ErrorList
+	for _, chart := range slices.Sorted(maps.Keys(cluster.Spec.RKEConfig.ChartValues.Data)) {
+		validator, ok := p.chartValuesSchemas[chart]
+		if !ok || !changes.Changed("spec", "rkeConfig", "chartValues", chart) {
+			continue
+		}
+		result := validator.Validate(cluster.Spec.RKEConfig.ChartValues.Data[chart])
+		for _, err := range result.Errors {
+			fieldErrs = append(fieldErrs, chartValuesFieldError(path.Child(chart), err))
+		}
+	}
+	return fieldErrs
+}
+
+// chartValuesFieldError converts an error of the schema validation to an error of the field it is about.
+func chartValuesFieldError(chartPath *field.Path, err error) *field.Error {
+	var validationErr *openapierrors.Validation
+	if !errors.As(err, &validationErr) {
+		return field.Invalid(chartPath, field.OmitValueType{}, err.Error())
+	}
+	fieldPath := chartPath
+	if validationErr.Name != "" && validationErr.Name != "." {
+		for _, name := range strings.Split(validationErr.Name, ".") {
+			if index, err := strconv.Atoi(name); err == nil {
+				fieldPath = fieldPath.Index(index)
+			} else {
+				fieldPath = fieldPath.Child(name)
+			}
+		}
+	}
+	message := validationErr.Error()
+	if _, detail, found := strings.Cut(message, " in body "); found {
+		message = detail
+	}
+	return field.Invalid(fieldPath, field.OmitValueType{}, message)
+}
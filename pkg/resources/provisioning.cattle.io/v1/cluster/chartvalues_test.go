@@ -0,0 +1,140 @@
+package cluster
+
+import (
+	"testing"
+
+	v1 "github.com/rancher/rancher/pkg/apis/provisioning.cattle.io/v1"
+	rkev1 "github.com/rancher/rancher/pkg/apis/rke.cattle.io/v1"
+	"github.com/rancher/webhook/pkg/diff"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"sigs.k8s.io/yaml"
+)
+
+func TestBuiltinChartValuesSchemas(t *testing.T) {
+	t.Parallel()
+	for _, chart := range []string{"rke2-calico", "rke2-canal", "rke2-cilium", "rke2-coredns", "rke2-ingress-nginx", "rke2-metrics-server"} {
+		assert.Contains(t, builtinChartValuesSchemas, chart)
+	}
+}
+
+func TestValidateChartValues(t *testing.T) {
+	t.Parallel()
+
+	clusterWithChartValues := func(values string) *v1.Cluster {
+		data := map[string]any{}
+		require.NoError(t, yaml.Unmarshal([]byte(values), &data))
+		return &v1.Cluster{Spec: v1.ClusterSpec{RKEConfig: &v1.RKEConfig{
+			RKEClusterSpecCommon: rkev1.RKEClusterSpecCommon{ChartValues: rkev1.GenericMap{Data: data}},
+		}}}
+	}
+
+	tests := []struct {
+		name         string
+		disabled     bool
+		oldCluster   *v1.Cluster
+		newCluster   *v1.Cluster
+		failedFields []string
+	}{
+		{
+			name:       "no rkeConfig",
+			newCluster: &v1.Cluster{},
+		},
+		{
+			name: "valid values",
+			newCluster: clusterWithChartValues(`
+rke2-canal:
+  flannel:
+    backend: vxlan
+    backendPort: 8472
+rke2-calico:
+  installation:
+    calicoNetwork:
+      ipPools:
+      - cidr: 10.42.0.0/16
+        encapsulation: VXLAN
+rke2-ingress-nginx:
+  controller:
+    kind: Deployment
+    replicaCount: 2
+`),
+		},
+		{
+			name: "misindented values",
+			newCluster: clusterWithChartValues(`
+rke2-canal:
+  flannel: vxlan
+rke2-calico:
+  installation:
+    calicoNetwork:
+      ipPools:
+      - cidr: 10.42.0.0/16
+        encapsulation: vxlan
+rke2-coredns:
+  nodelocal:
+    enabled: "yes"
+`),
+			failedFields: []string{
+				"spec.rkeConfig.chartValues.rke2-calico.installation.calicoNetwork.ipPools[0].encapsulation",
+				"spec.rkeConfig.chartValues.rke2-canal.flannel",
+				"spec.rkeConfig.chartValues.rke2-coredns.nodelocal.enabled",
+			},
+		},
+		{
+			name:       "values of other charts are not checked",
+			newCluster: clusterWithChartValues("my-chart:\n  flannel: vxlan"),
+		},
+		{
+			name:       "unchanged values are not checked",
+			oldCluster: clusterWithChartValues("rke2-canal:\n  flannel: vxlan"),
+			newCluster: clusterWithChartValues("rke2-canal:\n  flannel: vxlan\nrke2-coredns:\n  replicaCount: 2"),
+		},
+		{
+			name:       "validation disabled",
+			disabled:   true,
+			newCluster: clusterWithChartValues("rke2-canal:\n  flannel: vxlan"),
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			a := provisioningAdmitter{chartValuesSchemas: builtinChartValuesSchemas}
+			if tt.disabled {
+				a.chartValuesSchemas = nil
+			}
+			oldCluster := tt.oldCluster
+			if oldCluster == nil {
+				oldCluster = &v1.Cluster{}
+			}
+			changes, err := diff.Objects(oldCluster, tt.newCluster)
+			require.NoError(t, err)
+			validateFailedPaths(tt.failedFields)(t, a.validateChartValues(changes, tt.newCluster))
+		})
+	}
+}
+
+func TestChartValuesValidationFromEnv(t *testing.T) {
+	tests := []struct {
+		value   string
+		want    bool
+		wantErr bool
+	}{
+		{value: "", want: false},
+		{value: "true", want: true},
+		{value: "false", want: false},
+		{value: "sometimes", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			t.Setenv(ChartValuesValidationEnv, tt.value)
+			got, err := ChartValuesValidationFromEnv()
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
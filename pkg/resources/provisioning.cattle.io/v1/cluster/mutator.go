@@ -45,9 +45,15 @@ const (
 	controlPlaneRoleLabel            = "rke.cattle.io/control-plane-role"
 	secretAnnotation                 = "rke.cattle.io/object-authorized-for-clusters"
 	allowDynamicSchemaDropAnnotation = "provisioning.cattle.io/allow-dynamic-schema-drop"
-	runtimeK3S                       = "k3s"
-	runtimeRKE2                      = "rke2"
-	runtimeRKE                       = "rke"
+	// AdmittedByWebhookAnnotation holds the version of the webhook which admitted the creation of the cluster or the
+	// last change to its spec.
+	AdmittedByWebhookAnnotation = "provisioning.cattle.io/admitted-by-webhook"
+	runtimeK3S                  = "k3s"
+	runtimeRKE2                 = "rke2"
+	runtimeRKE                  = "rke"
+
+	// defaultPSACTSetting is the name of the setting holding the PSACT applied to new clusters which don't set one.
+	defaultPSACTSetting = "default-pod-security-admission-configuration-template-name"
 )
 
 var (
@@ -63,15 +69,17 @@ var gvr = schema.GroupVersionResource{
 
 // ProvisioningClusterMutator implements admission.MutatingAdmissionWebhook.
 type ProvisioningClusterMutator struct {
-	secret corecontroller.SecretController
-	psact  v3.PodSecurityAdmissionConfigurationTemplateCache
+	secret       corecontroller.SecretController
+	psact        v3.PodSecurityAdmissionConfigurationTemplateCache
+	settingCache v3.SettingCache
 }
 
 // NewProvisioningClusterMutator returns a new mutator for provisioning clusters
-func NewProvisioningClusterMutator(secret corecontroller.SecretController, psact v3.PodSecurityAdmissionConfigurationTemplateCache) *ProvisioningClusterMutator {
+func NewProvisioningClusterMutator(secret corecontroller.SecretController, psact v3.PodSecurityAdmissionConfigurationTemplateCache, settingCache v3.SettingCache) *ProvisioningClusterMutator {
 	return &ProvisioningClusterMutator{
-		secret: secret,
-		psact:  psact,
+		secret:       secret,
+		psact:        psact,
+		settingCache: settingCache,
 	}
 }
 
@@ -116,8 +124,21 @@ func (m *ProvisioningClusterMutator) Admit(request *admission.Request) (*admissi
 		return nil, err
 	}
 
+	setAdmittedByAnnotation(request.Operation, oldCluster, cluster, admission.WebhookVersion())
+
 	if request.Operation == admissionv1.Create {
+		optOut, err := common.IsNoCreatorRBACNamespace(m.settingCache, request.Namespace)
+		if err != nil {
+			return nil, err
+		}
+		if optOut {
+			common.SetNoCreatorRBACAnnotation(cluster)
+		}
 		common.SetCreatorIDAnnotation(request, cluster)
+
+		if err := m.setDefaultPSACT(cluster); err != nil {
+			return nil, err
+		}
 	}
 
 	response, err := m.handlePSACT(request, cluster)
@@ -142,6 +163,27 @@ func (m *ProvisioningClusterMutator) Admit(request *admission.Request) (*admissi
 	return response, nil
 }
 
+// setAdmittedByAnnotation stamps the cluster with the version of the webhook on creation and on changes to its spec, so
+// that the webhook version which admitted the current spec is known.
+func setAdmittedByAnnotation(operation admissionv1.Operation, oldCluster, cluster *v1.Cluster, version string) {
+	if version == "" {
+		return
+	}
+	switch operation {
+	case admissionv1.Create:
+	case admissionv1.Update:
+		if equality.Semantic.DeepEqual(oldCluster.Spec, cluster.Spec) {
+			return
+		}
+	default:
+		return
+	}
+	if cluster.Annotations == nil {
+		cluster.Annotations = map[string]string{}
+	}
+	cluster.Annotations[AdmittedByWebhookAnnotation] = version
+}
+
 // handleDynamicSchemaDrop watches for provisioning cluster updates, and reinserts the previous value of the
 // dynamicSchemaSpec field for a machine pool if the "provisioning.cattle.io/allow-dynamic-schema-drop" annotation is
 // not present and true on the cluster. If the value of the annotation is true, no mutation is performed.
@@ -173,6 +215,29 @@ func (m *ProvisioningClusterMutator) handleDynamicSchemaDrop(request *admission.
 	return admission.ResponseAllowed()
 }
 
+// setDefaultPSACT sets spec.defaultPodSecurityAdmissionConfigurationTemplateName to the template named in the
+// "default-pod-security-admission-configuration-template-name" setting if the cluster does not set a template itself.
+// Only clusters which support PSACT (RKE2/K3s clusters at k8s version 1.23 or above) are defaulted.
+func (m *ProvisioningClusterMutator) setDefaultPSACT(cluster *v1.Cluster) error {
+	if cluster.Name == "local" || cluster.Spec.RKEConfig == nil || cluster.Spec.DefaultPodSecurityAdmissionConfigurationTemplateName != "" {
+		return nil
+	}
+	parsedVersion, err := psa.GetClusterVersion(cluster.Spec.KubernetesVersion)
+	if err != nil || parsedRangeLessThan123(parsedVersion) {
+		// PSACT is not supported by this cluster, it is up to the PSACT handling and validation to reject the request if necessary.
+		return nil
+	}
+	templateName, err := common.GetSettingValue(m.settingCache, defaultPSACTSetting)
+	if err != nil {
+		return fmt.Errorf("[provisioning cluster mutator] failed to get default PSACT: %w", err)
+	}
+	if templateName != "" {
+		logrus.Debugf("[provisioning cluster mutator] defaulting PSACT of cluster %s/%s to %s", cluster.Namespace, cluster.Name, templateName)
+		cluster.Spec.DefaultPodSecurityAdmissionConfigurationTemplateName = templateName
+	}
+	return nil
+}
+
 // handlePSACT updates the cluster and an underlying secret to support PSACT.
 // If a PSACT is set in the cluster, handlePSACT generates an admission configuration file, mounts the file into a secret,
 // updates the cluster's spec to mount the secret to the control plane nodes, and configures kube-apisever to use the admission configuration file;
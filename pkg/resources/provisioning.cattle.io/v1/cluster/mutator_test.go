@@ -2,18 +2,28 @@ package cluster
 
 import (
 	"encoding/json"
+	"fmt"
 	"reflect"
 	"testing"
 
+	jsonpatch "github.com/evanphx/json-patch"
+	v3 "github.com/rancher/rancher/pkg/apis/management.cattle.io/v3"
 	v1 "github.com/rancher/rancher/pkg/apis/provisioning.cattle.io/v1"
 	rkev1 "github.com/rancher/rancher/pkg/apis/rke.cattle.io/v1"
 	"github.com/rancher/webhook/pkg/admission"
+	"github.com/rancher/webhook/pkg/resources/common"
 	data2 "github.com/rancher/wrangler/v3/pkg/data"
+	"github.com/rancher/wrangler/v3/pkg/generic/fake"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
 	admissionv1 "k8s.io/api/admission/v1"
+	authenticationv1 "k8s.io/api/authentication/v1"
 	"k8s.io/apimachinery/pkg/api/equality"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
 )
 
 func Test_GetKubeAPIServerArg(t *testing.T) {
@@ -608,7 +618,9 @@ func TestAdmitPreserveUnknownFields(t *testing.T) {
 		},
 	}
 
-	m := ProvisioningClusterMutator{}
+	settingCache := fake.NewMockNonNamespacedCacheInterface[*v3.Setting](gomock.NewController(t))
+	settingCache.EXPECT().Get(gomock.Any()).Return(nil, apierrors.NewNotFound(schema.GroupResource{}, "")).AnyTimes()
+	m := ProvisioningClusterMutator{settingCache: settingCache}
 
 	request.Operation = admissionv1.Create
 	response, err := m.Admit(request)
@@ -892,3 +904,234 @@ func TestDynamicSchemaDrop(t *testing.T) {
 		})
 	}
 }
+
+func TestSetDefaultPSACT(t *testing.T) {
+	t.Parallel()
+
+	notFound := apierrors.NewNotFound(schema.GroupResource{Group: "management.cattle.io", Resource: "settings"}, defaultPSACTSetting)
+
+	tests := []struct {
+		name         string
+		cluster      *v1.Cluster
+		setting      *v3.Setting
+		settingErr   error
+		wantTemplate string
+		wantErr      bool
+	}{
+		{
+			name: "template defaulted from setting value",
+			cluster: &v1.Cluster{
+				Spec: v1.ClusterSpec{KubernetesVersion: "v1.30.4+rke2r1", RKEConfig: &v1.RKEConfig{}},
+			},
+			setting:      &v3.Setting{Value: "rancher-restricted", Default: "rancher-privileged"},
+			wantTemplate: "rancher-restricted",
+		},
+		{
+			name: "template defaulted from setting default",
+			cluster: &v1.Cluster{
+				Spec: v1.ClusterSpec{KubernetesVersion: "v1.30.4+k3s1", RKEConfig: &v1.RKEConfig{}},
+			},
+			setting:      &v3.Setting{Default: "rancher-privileged"},
+			wantTemplate: "rancher-privileged",
+		},
+		{
+			name: "template already set",
+			cluster: &v1.Cluster{
+				Spec: v1.ClusterSpec{
+					KubernetesVersion: "v1.30.4+rke2r1",
+					RKEConfig:         &v1.RKEConfig{},
+					DefaultPodSecurityAdmissionConfigurationTemplateName: "custom",
+				},
+			},
+			wantTemplate: "custom",
+		},
+		{
+			name: "setting is empty",
+			cluster: &v1.Cluster{
+				Spec: v1.ClusterSpec{KubernetesVersion: "v1.30.4+rke2r1", RKEConfig: &v1.RKEConfig{}},
+			},
+			setting: &v3.Setting{},
+		},
+		{
+			name: "setting does not exist",
+			cluster: &v1.Cluster{
+				Spec: v1.ClusterSpec{KubernetesVersion: "v1.30.4+rke2r1", RKEConfig: &v1.RKEConfig{}},
+			},
+			settingErr: notFound,
+		},
+		{
+			name: "failure getting setting",
+			cluster: &v1.Cluster{
+				Spec: v1.ClusterSpec{KubernetesVersion: "v1.30.4+rke2r1", RKEConfig: &v1.RKEConfig{}},
+			},
+			settingErr: fmt.Errorf("server unavailable"),
+			wantErr:    true,
+		},
+		{
+			name: "imported cluster",
+			cluster: &v1.Cluster{
+				Spec: v1.ClusterSpec{KubernetesVersion: "v1.30.4+rke2r1"},
+			},
+		},
+		{
+			name: "local cluster",
+			cluster: &v1.Cluster{
+				ObjectMeta: metav1.ObjectMeta{Name: "local"},
+				Spec:       v1.ClusterSpec{KubernetesVersion: "v1.30.4+rke2r1", RKEConfig: &v1.RKEConfig{}},
+			},
+		},
+		{
+			name: "version without PSACT support",
+			cluster: &v1.Cluster{
+				Spec: v1.ClusterSpec{KubernetesVersion: "v1.22.17+rke2r1", RKEConfig: &v1.RKEConfig{}},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			ctrl := gomock.NewController(t)
+			settingCache := fake.NewMockNonNamespacedCacheInterface[*v3.Setting](ctrl)
+			if tt.setting != nil || tt.settingErr != nil {
+				settingCache.EXPECT().Get(defaultPSACTSetting).Return(tt.setting, tt.settingErr)
+			}
+			m := NewProvisioningClusterMutator(nil, nil, settingCache)
+
+			err := m.setDefaultPSACT(tt.cluster)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.wantTemplate, tt.cluster.Spec.DefaultPodSecurityAdmissionConfigurationTemplateName)
+		})
+	}
+}
+
+func TestNoCreatorRBACNamespaceMutation(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name            string
+		namespace       string
+		annotations     map[string]string
+		wantAnnotations map[string]string
+	}{
+		{
+			name:      "cluster in a no-creator-rbac namespace",
+			namespace: "system-integrations",
+			annotations: map[string]string{
+				common.CreatorIDAnn: "u-12345",
+			},
+			wantAnnotations: map[string]string{
+				common.NoCreatorRBACAnn: "true",
+			},
+		},
+		{
+			name:      "cluster outside of the no-creator-rbac namespaces",
+			namespace: "fleet-default",
+			wantAnnotations: map[string]string{
+				common.CreatorIDAnn: "test-user",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			settingCache := fake.NewMockNonNamespacedCacheInterface[*v3.Setting](gomock.NewController(t))
+			settingCache.EXPECT().Get(common.NoCreatorRBACNamespacesSetting).Return(&v3.Setting{
+				ObjectMeta: metav1.ObjectMeta{Name: common.NoCreatorRBACNamespacesSetting},
+				Value:      "system-integrations,other",
+			}, nil)
+			settingCache.EXPECT().Get(gomock.Any()).Return(nil, apierrors.NewNotFound(schema.GroupResource{}, "")).AnyTimes()
+			m := NewProvisioningClusterMutator(nil, nil, settingCache)
+
+			cluster := &v1.Cluster{
+				ObjectMeta: metav1.ObjectMeta{Name: "test", Namespace: tt.namespace, Annotations: tt.annotations},
+			}
+			raw, err := json.Marshal(cluster)
+			require.NoError(t, err)
+			request := &admission.Request{
+				AdmissionRequest: admissionv1.AdmissionRequest{
+					Operation: admissionv1.Create,
+					Namespace: tt.namespace,
+					UserInfo:  authenticationv1.UserInfo{Username: "test-user"},
+					Object:    runtime.RawExtension{Raw: raw},
+				},
+			}
+
+			response, err := m.Admit(request)
+			require.NoError(t, err)
+			require.True(t, response.Allowed)
+
+			patchObj, err := jsonpatch.DecodePatch(response.Patch)
+			require.NoError(t, err)
+			patched, err := patchObj.Apply(raw)
+			require.NoError(t, err)
+			got := &v1.Cluster{}
+			require.NoError(t, json.Unmarshal(patched, got))
+			assert.Equal(t, tt.wantAnnotations, got.Annotations)
+		})
+	}
+}
+
+func TestSetAdmittedByAnnotation(t *testing.T) {
+	t.Parallel()
+	oldCluster := &v1.Cluster{
+		ObjectMeta: metav1.ObjectMeta{Annotations: map[string]string{AdmittedByWebhookAnnotation: "v0.5.0+abc"}},
+		Spec:       v1.ClusterSpec{KubernetesVersion: "v1.30.4+rke2r1"},
+	}
+	tests := []struct {
+		name       string
+		operation  admissionv1.Operation
+		version    string
+		spec       v1.ClusterSpec
+		annotation string
+	}{
+		{
+			name:       "create",
+			operation:  admissionv1.Create,
+			version:    "v0.6.0+def",
+			annotation: "v0.6.0+def",
+		},
+		{
+			name:       "create without a known version",
+			operation:  admissionv1.Create,
+			annotation: "v0.5.0+abc",
+		},
+		{
+			name:       "update of the spec",
+			operation:  admissionv1.Update,
+			version:    "v0.6.0+def",
+			spec:       v1.ClusterSpec{KubernetesVersion: "v1.31.1+rke2r1"},
+			annotation: "v0.6.0+def",
+		},
+		{
+			name:       "update without spec changes",
+			operation:  admissionv1.Update,
+			version:    "v0.6.0+def",
+			spec:       oldCluster.Spec,
+			annotation: "v0.5.0+abc",
+		},
+		{
+			name:       "delete",
+			operation:  admissionv1.Delete,
+			version:    "v0.6.0+def",
+			annotation: "v0.5.0+abc",
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			cluster := oldCluster.DeepCopy()
+			cluster.Spec = tt.spec
+			setAdmittedByAnnotation(tt.operation, oldCluster, cluster, tt.version)
+			assert.Equal(t, tt.annotation, cluster.Annotations[AdmittedByWebhookAnnotation])
+		})
+	}
+}
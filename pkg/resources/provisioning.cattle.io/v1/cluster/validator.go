@@ -1,33 +1,48 @@
 package cluster
 
 import (
+	"context"
 	"crypto/sha256"
 	"encoding/base64"
 	"fmt"
+	"maps"
+	"net"
 	"net/http"
+	"net/url"
 	"path/filepath"
 	"regexp"
 	"slices"
 	"strings"
 
+	"github.com/blang/semver"
+	"github.com/rancher/lasso/pkg/client"
+	"github.com/rancher/lasso/pkg/dynamic"
 	v1 "github.com/rancher/rancher/pkg/apis/provisioning.cattle.io/v1"
 	rkev1 "github.com/rancher/rancher/pkg/apis/rke.cattle.io/v1"
 	"github.com/rancher/webhook/pkg/admission"
+	"github.com/rancher/webhook/pkg/auth"
 	"github.com/rancher/webhook/pkg/clients"
+	"github.com/rancher/webhook/pkg/diff"
 	v3 "github.com/rancher/webhook/pkg/generated/controllers/management.cattle.io/v3"
 	objectsv1 "github.com/rancher/webhook/pkg/generated/objects/provisioning.cattle.io/v1"
 	psa "github.com/rancher/webhook/pkg/podsecurityadmission"
 	"github.com/rancher/webhook/pkg/resources/common"
+	"github.com/rancher/webhook/pkg/tenantpolicy"
 	corev1controller "github.com/rancher/wrangler/v3/pkg/generated/controllers/core/v1"
 	"github.com/rancher/wrangler/v3/pkg/kv"
+	"github.com/sirupsen/logrus"
 	admissionv1 "k8s.io/api/admission/v1"
 	admissionregistrationv1 "k8s.io/api/admissionregistration/v1"
 	authv1 "k8s.io/api/authorization/v1"
 	k8sv1 "k8s.io/api/core/v1"
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/validation"
+	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/runtime/schema"
+	utilvalidation "k8s.io/apimachinery/pkg/util/validation"
 	"k8s.io/apimachinery/pkg/util/validation/field"
 	authorizationv1 "k8s.io/client-go/kubernetes/typed/authorization/v1"
 	"k8s.io/utils/trace"
@@ -38,23 +53,91 @@ const (
 	localCluster            = "local"
 	systemAgentVarDirEnvVar = "CATTLE_AGENT_VAR_DIR"
 	failureStatus           = "Failure"
+	clusterContext          = "cluster"
+	machineConfigAPIVersion = "rke-machine-config.cattle.io/v1"
+
+	windowsMachineOS = "windows"
+
+	// allowInsecureS3EndpointAnnotation allows the etcd snapshot S3 endpoint to use plain http when set to "true".
+	allowInsecureS3EndpointAnnotation = "provisioning.cattle.io/allow-insecure-s3-endpoint"
+
+	// StrictRKEConfigConversionFeature is the name of the feature requiring forceRKEConfigConversionAnnotation and the
+	// convert verb to add spec.rkeConfig to, or remove it from, existing clusters.
+	StrictRKEConfigConversionFeature = "strict-rkeconfig-conversion"
+	// forceRKEConfigConversionAnnotation must be set to "true" to convert a cluster between imported and provisioned
+	// while the StrictRKEConfigConversionFeature is enabled.
+	forceRKEConfigConversionAnnotation = "provisioning.cattle.io/force-rkeconfig-conversion"
+	// convertVerb is the verb on the cluster needed to convert it between imported and provisioned.
+	convertVerb = "convert"
 )
 
 var (
-	mgmtNameRegex  = regexp.MustCompile("^c-[a-z0-9]{5}$")
-	fleetNameRegex = regexp.MustCompile("^[^-][-a-z0-9]+$")
+	mgmtNameRegex   = regexp.MustCompile("^c-[a-z0-9]{5}$")
+	fleetNameRegex  = regexp.MustCompile("^[^-][-a-z0-9]+$")
+	roleTemplateGVR = schema.GroupVersionResource{Group: "management.cattle.io", Version: "v3", Resource: "roletemplates"}
+
+	s3BucketRegex = regexp.MustCompile(`^[a-z0-9][a-z0-9.-]{1,61}[a-z0-9]$`)
+	s3RegionRegex = regexp.MustCompile(`^[a-z0-9]+(-[a-z0-9]+)*$`)
+	// awsS3EndpointRegionRegex matches the region of regional AWS S3 endpoints, such as s3.us-west-2.amazonaws.com or
+	// s3-us-west-2.amazonaws.com.
+	awsS3EndpointRegionRegex = regexp.MustCompile(`^s3[.-](?:dualstack\.)?([a-z0-9-]+)\.amazonaws\.com(?:\.cn)?$`)
+
+	// parsedRangeLessThanWindows matches RKE2 versions which do not support Windows workers.
+	parsedRangeLessThanWindows = semver.MustParseRange("< 1.22.5")
+	// windowsCNIs are the CNIs of RKE2 which support Windows workers.
+	windowsCNIs = []string{"calico", "flannel"}
 )
 
 // NewProvisioningClusterValidator returns a new validator for provisioning clusters
-func NewProvisioningClusterValidator(client *clients.Clients) *ProvisioningClusterValidator {
-	return &ProvisioningClusterValidator{
+// The values of built-in charts are validated against their schema when validateChartValues is true.
+func NewProvisioningClusterValidator(client *clients.Clients, validateChartValues bool, uninstallServiceAccount common.UninstallServiceAccount) *ProvisioningClusterValidator {
+	return NewValidator(
+		client.SubjectAccessReviews,
+		client.Management.Cluster(),
+		client.Core.Secret().Cache(),
+		client.Management.PodSecurityAdmissionConfigurationTemplate().Cache(),
+		client.Management.Setting().Cache(),
+		client.Management.RoleTemplate().Cache(),
+		client.Management.Feature().Cache(),
+		client.TenantPolicies,
+		client.Dynamic,
+		client.SharedControllerFactory.SharedCacheFactory().SharedClientFactory(),
+		validateChartValues,
+		uninstallServiceAccount,
+	)
+}
+
+// NewValidator returns a new validator for provisioning clusters using the given clients and caches. Machine configs
+// which aren't in the dynamic cache are fetched with the clients of clientFactory. Only the uninstallServiceAccount can
+// delete the local cluster.
+func NewValidator(sar authorizationv1.SubjectAccessReviewInterface, mgmtClusterClient v3.ClusterClient, secretCache corev1controller.SecretCache,
+	psactCache v3.PodSecurityAdmissionConfigurationTemplateCache, settingCache v3.SettingCache, roleTemplateCache v3.RoleTemplateCache,
+	featureCache v3.FeatureCache, tenantPolicies *tenantpolicy.Resolver, dynamic *dynamic.Controller, clientFactory client.SharedClientFactory,
+	validateChartValues bool, uninstallServiceAccount common.UninstallServiceAccount) *ProvisioningClusterValidator {
+	validator := &ProvisioningClusterValidator{
 		admitter: provisioningAdmitter{
-			sar:               client.K8s.AuthorizationV1().SubjectAccessReviews(),
-			mgmtClusterClient: client.Management.Cluster(),
-			secretCache:       client.Core.Secret().Cache(),
-			psactCache:        client.Management.PodSecurityAdmissionConfigurationTemplate().Cache(),
+			sar:                     sar,
+			mgmtClusterClient:       mgmtClusterClient,
+			secretCache:             secretCache,
+			psactCache:              psactCache,
+			settingCache:            settingCache,
+			roleTemplateCache:       roleTemplateCache,
+			featureCache:            featureCache,
+			tenantPolicies:          tenantPolicies,
+			uninstallServiceAccount: uninstallServiceAccount,
 		},
 	}
+	if dynamic != nil {
+		// the machine config references are only checked when objects of arbitrary kinds can be fetched
+		validator.admitter.dynamic = dynamic
+	}
+	if clientFactory != nil {
+		validator.admitter.liveDynamic = &liveGetter{clientFactory: clientFactory}
+	}
+	if validateChartValues {
+		validator.admitter.chartValuesSchemas = builtinChartValuesSchemas
+	}
+	return validator
 }
 
 type ProvisioningClusterValidator struct {
@@ -66,6 +149,11 @@ func (p *ProvisioningClusterValidator) GVR() schema.GroupVersionResource {
 	return gvr
 }
 
+// Subresources returns the subresources of clusters whose writes are only allowed for controllers.
+func (p *ProvisioningClusterValidator) Subresources() []string {
+	return []string{"status"}
+}
+
 // Operations returns list of operations handled by this validator.
 func (p *ProvisioningClusterValidator) Operations() []admissionregistrationv1.OperationType {
 	return []admissionregistrationv1.OperationType{admissionregistrationv1.Update, admissionregistrationv1.Create, admissionregistrationv1.Delete}
@@ -86,6 +174,41 @@ type provisioningAdmitter struct {
 	mgmtClusterClient v3.ClusterClient
 	secretCache       corev1controller.SecretCache
 	psactCache        v3.PodSecurityAdmissionConfigurationTemplateCache
+	settingCache      v3.SettingCache
+	roleTemplateCache v3.RoleTemplateCache
+	featureCache      v3.FeatureCache
+	tenantPolicies    *tenantpolicy.Resolver
+	dynamic           dynamicGetter
+	// liveDynamic gets the objects missing from the dynamic cache from the API server. It is nil when they can't be.
+	liveDynamic dynamicGetter
+	// chartValuesSchemas validate the values of built-in charts, keyed by chart name. Chart values aren't validated
+	// when nil.
+	chartValuesSchemas chartValuesSchemas
+	// uninstallServiceAccount is allowed to delete the local cluster.
+	uninstallServiceAccount common.UninstallServiceAccount
+}
+
+// dynamicGetter is an interface to abstract away how we get dynamic objects from k8s
+type dynamicGetter interface {
+	Get(gvk schema.GroupVersionKind, namespace, name string) (runtime.Object, error)
+}
+
+// liveGetter gets dynamic objects from the API server, bypassing the cache.
+type liveGetter struct {
+	clientFactory client.SharedClientFactory
+}
+
+// Get gets the object of the given kind, namespace and name from the API server.
+func (l *liveGetter) Get(gvk schema.GroupVersionKind, namespace, name string) (runtime.Object, error) {
+	c, err := l.clientFactory.ForKind(gvk)
+	if err != nil {
+		return nil, err
+	}
+	obj := &unstructured.Unstructured{}
+	if err := c.Get(context.TODO(), namespace, name, obj, metav1.GetOptions{}); err != nil {
+		return nil, err
+	}
+	return obj, nil
 }
 
 // Admit handles the webhook admission request sent to this webhook.
@@ -93,8 +216,8 @@ func (p *provisioningAdmitter) Admit(request *admission.Request) (*admissionv1.A
 	listTrace := trace.New("provisioningClusterValidator Admit", trace.Field{Key: "user", Value: request.UserInfo.Username})
 	defer listTrace.LogIfLong(admission.SlowTraceDuration)
 
-	if request.Operation == admissionv1.Delete && request.Name == localCluster {
-		// deleting "local" cluster could corrupt the cluster Rancher is deployed in
+	if request.Operation == admissionv1.Delete && request.Name == localCluster && !p.uninstallServiceAccount.IsUser(request) {
+		// deleting "local" cluster could corrupt the cluster Rancher is deployed in, unless Rancher is being uninstalled
 		return admission.ResponseBadRequest("can't delete local cluster"), nil
 	}
 
@@ -102,50 +225,147 @@ func (p *provisioningAdmitter) Admit(request *admission.Request) (*admissionv1.A
 	if err != nil {
 		return nil, err
 	}
+	return p.checks(oldCluster, cluster).Admit(request)
+}
 
-	response := &admissionv1.AdmissionResponse{}
-	if request.Operation == admissionv1.Create || request.Operation == admissionv1.Update {
-		if err := p.validateClusterName(request, response, cluster); err != nil || response.Result != nil {
-			return response, err
-		}
-
-		if err := p.validateMachinePoolNames(request, response, cluster); err != nil || response.Result != nil {
-			return response, err
-		}
-
-		if response.Result = common.CheckCreatorID(request, oldCluster, cluster); response.Result != nil {
-			return response, nil
-		}
-
-		if response.Result = validateACEConfig(cluster); response.Result != nil {
-			return response, nil
-		}
+// checks returns the chain of checks of the cluster. The checks of the cluster's metadata and of the user's permissions
+// stop at the first denial, while the problems found by the checks of the cluster's fields are all reported at once.
+func (p *provisioningAdmitter) checks(oldCluster, cluster *v1.Cluster) *admission.AdmitterChain {
+	return admission.NewAdmitterChain(admission.StopOnFirstDeny,
+		admission.ChainLink{Name: "creatorID", Order: 0, Admitter: onCreateOrUpdate(admission.AdmitterFunc(func(request *admission.Request) (*admissionv1.AdmissionResponse, error) {
+			return statusResponse(common.CheckCreatorID(request, oldCluster, cluster)), nil
+		}))},
+		admission.ChainLink{Name: "noCreatorRBAC", Order: 0, Admitter: onCreateOrUpdate(responseCheck(func(request *admission.Request, response *admissionv1.AdmissionResponse) error {
+			return p.validateNoCreatorRBAC(request, response, oldCluster, cluster)
+		}))},
+		admission.ChainLink{Name: "rkeConfigConversion", Order: 0, Admitter: responseCheck(func(request *admission.Request, response *admissionv1.AdmissionResponse) error {
+			return p.validateRKEConfigConversion(request, response, oldCluster, cluster)
+		})},
+		admission.ChainLink{Name: "fields", Order: 10, Admitter: onCreateOrUpdate(admission.AdmitterFunc(func(request *admission.Request) (*admissionv1.AdmissionResponse, error) {
+			fieldErrs, err := p.validateFields(request, oldCluster, cluster)
+			if err != nil {
+				return nil, err
+			}
+			return statusResponse(errorListToStatus(fieldErrs)), nil
+		}))},
+		admission.ChainLink{Name: "cloudCredentialAccess", Order: 20, Parallel: true, Admitter: onCreateOrUpdate(responseCheck(func(request *admission.Request, response *admissionv1.AdmissionResponse) error {
+			return p.validateCloudCredentialAccess(request, response, oldCluster, cluster)
+		}))},
+		admission.ChainLink{Name: "defaultClusterRoleForProjectMembers", Order: 20, Parallel: true, Admitter: onCreateOrUpdate(responseCheck(func(request *admission.Request, response *admissionv1.AdmissionResponse) error {
+			return p.validateDefaultClusterRoleForProjectMembers(request, response, oldCluster, cluster)
+		}))},
+		admission.ChainLink{Name: "psact", Order: 40, Admitter: responseCheck(func(request *admission.Request, response *admissionv1.AdmissionResponse) error {
+			return p.validatePSACT(request, response, cluster)
+		})},
+		admission.ChainLink{Name: "psactDowngrade", Order: 40, Admitter: responseCheck(func(request *admission.Request, response *admissionv1.AdmissionResponse) error {
+			return p.validatePSACTDowngrade(request, response, oldCluster, cluster)
+		})},
+		admission.ChainLink{Name: "deprecatedFields", Order: 50, Admitter: onCreateOrUpdate(admission.AdmitterFunc(func(_ *admission.Request) (*admissionv1.AdmissionResponse, error) {
+			return admission.ResponseAllowedWithWarnings(deprecationWarnings(cluster)...), nil
+		}))},
+	)
+}
 
-		if response.Result = errorListToStatus(validateAgentDeploymentCustomization(cluster.Spec.ClusterAgentDeploymentCustomization,
-			field.NewPath("spec", "clusterAgentDeploymentCustomization"))); response.Result != nil {
-			return response, nil
+// validateFields runs all the checks of the cluster's name and spec and returns the errors of all of them, so that
+// users can fix every problem of the cluster at once instead of one at a time.
+func (p *provisioningAdmitter) validateFields(request *admission.Request, oldCluster, cluster *v1.Cluster) (field.ErrorList, error) {
+	changes, err := diff.Objects(oldCluster, cluster)
+	if err != nil {
+		return nil, fmt.Errorf("failed to compare old and new clusters: %w", err)
+	}
+	fieldErrs, err := p.validateClusterName(request, cluster)
+	if err != nil {
+		return nil, err
+	}
+	fieldErrs = append(fieldErrs, validateMachinePoolNames(request, cluster)...)
+	fieldErrs = append(fieldErrs, responseToFieldErrors(field.NewPath("spec", "rkeConfig", "dataDirectories"),
+		p.validateDataDirectories(request, oldCluster, cluster))...)
+
+	for _, check := range []func(oldCluster, newCluster *v1.Cluster) (field.ErrorList, error){
+		p.validateMinKubernetesVersion,
+		p.validateAgentEnvVars,
+		p.validateMachineConfigRefs,
+	} {
+		checkErrs, err := check(oldCluster, cluster)
+		if err != nil {
+			return nil, err
 		}
+		fieldErrs = append(fieldErrs, checkErrs...)
+	}
+	registryErrs, err := p.validateRegistries(changes, cluster)
+	if err != nil {
+		return nil, err
+	}
+	fieldErrs = append(fieldErrs, registryErrs...)
+	tenantPolicyErrs, err := p.validateTenantPolicy(changes, cluster)
+	if err != nil {
+		return nil, err
+	}
+	fieldErrs = append(fieldErrs, tenantPolicyErrs...)
+	schedulingErrs, err := validateSchedulingCustomization(request.OldObject.Raw, request.Object.Raw)
+	if err != nil {
+		return nil, err
+	}
+	fieldErrs = append(fieldErrs, schedulingErrs...)
+
+	fieldErrs = append(fieldErrs, p.validateChartValues(changes, cluster)...)
+	fieldErrs = append(fieldErrs, validateACEConfig(cluster)...)
+	fieldErrs = append(fieldErrs, validateAgentDeploymentCustomization(cluster.Spec.ClusterAgentDeploymentCustomization,
+		field.NewPath("spec", "clusterAgentDeploymentCustomization"))...)
+	fieldErrs = append(fieldErrs, validateAgentDeploymentCustomization(cluster.Spec.FleetAgentDeploymentCustomization,
+		field.NewPath("spec", "fleetAgentDeploymentCustomization"))...)
+	fieldErrs = append(fieldErrs, validateETCDSnapshotS3(changes, cluster)...)
+	fieldErrs = append(fieldErrs, validateWindowsMachinePools(oldCluster, cluster)...)
+	fieldErrs = append(fieldErrs, validateMachinePoolLabelsAndTaints(oldCluster, cluster)...)
+	rollingUpdateErrs, err := validateMachinePoolRollingUpdates(oldCluster, cluster)
+	if err != nil {
+		return nil, err
+	}
+	fieldErrs = append(fieldErrs, rollingUpdateErrs...)
+	return fieldErrs, nil
+}
 
-		if response.Result = errorListToStatus(validateAgentDeploymentCustomization(cluster.Spec.FleetAgentDeploymentCustomization,
-			field.NewPath("spec", "fleetAgentDeploymentCustomization"))); response.Result != nil {
-			return response, nil
-		}
+// responseToFieldErrors returns the message of a denial as an error of the field at the path, or nothing if the
+// response allows the request.
+func responseToFieldErrors(path *field.Path, response *admissionv1.AdmissionResponse) field.ErrorList {
+	if response.Allowed {
+		return nil
+	}
+	message := "request denied"
+	if response.Result != nil {
+		message = response.Result.Message
+	}
+	return field.ErrorList{field.Invalid(path, field.OmitValueType{}, message)}
+}
 
-		if err := p.validateCloudCredentialAccess(request, response, oldCluster, cluster); err != nil || response.Result != nil {
-			return response, err
+// onCreateOrUpdate only runs the admitter for create and update requests, and allows other requests.
+func onCreateOrUpdate(admitter admission.Admitter) admission.Admitter {
+	return admission.AdmitterFunc(func(request *admission.Request) (*admissionv1.AdmissionResponse, error) {
+		if request.Operation != admissionv1.Create && request.Operation != admissionv1.Update {
+			return admission.ResponseAllowed(), nil
 		}
+		return admitter.Admit(request)
+	})
+}
 
-		if response = p.validateDataDirectories(request, oldCluster, cluster); !response.Allowed {
-			return response, err
+// responseCheck adapts a check which sets the result of the given response when denying the request.
+func responseCheck(check func(*admission.Request, *admissionv1.AdmissionResponse) error) admission.Admitter {
+	return admission.AdmitterFunc(func(request *admission.Request) (*admissionv1.AdmissionResponse, error) {
+		response := &admissionv1.AdmissionResponse{}
+		if err := check(request, response); err != nil {
+			return nil, err
 		}
-	}
+		response.Allowed = response.Result == nil
+		return response, nil
+	})
+}
 
-	if err := p.validatePSACT(request, response, cluster); err != nil || response.Result != nil {
-		return response, err
+// statusResponse returns a response denying the request with the status, or allowing it if the status is nil.
+func statusResponse(status *metav1.Status) *admissionv1.AdmissionResponse {
+	if status == nil {
+		return admission.ResponseAllowed()
 	}
-
-	response.Allowed = true
-	return response, nil
+	return &admissionv1.AdmissionResponse{Result: status}
 }
 
 func getEnvVar(name string, envVars []rkev1.EnvVar) *rkev1.EnvVar {
@@ -245,6 +465,16 @@ func (p *provisioningAdmitter) validateDataDirectories(request *admission.Reques
 	return admission.ResponseAllowed()
 }
 
+// deprecationWarnings returns a warning for each deprecated field or behavior used by the cluster. These are still
+// allowed, but will be denied in a future release.
+func deprecationWarnings(cluster *v1.Cluster) []string {
+	var warnings []string
+	if envVar := getEnvVar(systemAgentVarDirEnvVar, cluster.Spec.AgentEnvVars); envVar != nil && envVar.Value != "" {
+		warnings = append(warnings, fmt.Sprintf(`"%s" env var in "cluster.Spec.AgentEnvVars" is deprecated: use "cluster.Spec.RKEConfig.DataDirectories.SystemAgent"`, systemAgentVarDirEnvVar))
+	}
+	return warnings
+}
+
 // validateDataDirectoryFormat ensures that no data directory contains a relative path, environment variables,
 // shell expressions, or references to the current or parent directory via use of "./" and "../" respectively.
 // dir is the path of the data directory, and name corresponds to a print friendly name for this data directory.
@@ -362,6 +592,51 @@ func (p *provisioningAdmitter) validateCloudCredentialAccess(request *admission.
 	return nil
 }
 
+// validateDefaultClusterRoleForProjectMembers ensures that spec.defaultClusterRoleForProjectMembers references an existing,
+// unlocked, cluster context RoleTemplate. Since the role is granted to every future project member, changing it on update
+// requires the bind verb on the new RoleTemplate.
+func (p *provisioningAdmitter) validateDefaultClusterRoleForProjectMembers(request *admission.Request, response *admissionv1.AdmissionResponse, oldCluster, newCluster *v1.Cluster) error {
+	name := newCluster.Spec.DefaultClusterRoleForProjectMembers
+	if name == "" || name == oldCluster.Spec.DefaultClusterRoleForProjectMembers {
+		return nil
+	}
+	fieldPath := field.NewPath("spec", "defaultClusterRoleForProjectMembers")
+
+	roleTemplate, err := p.roleTemplateCache.Get(name)
+	if err != nil {
+		if !apierrors.IsNotFound(err) {
+			return fmt.Errorf("failed to get roleTemplate %s: %w", name, err)
+		}
+		response.Result = errorListToStatus(field.ErrorList{field.NotFound(fieldPath, name)})
+		return nil
+	}
+	if roleTemplate.Locked {
+		response.Result = errorListToStatus(field.ErrorList{field.Forbidden(fieldPath, fmt.Sprintf("roleTemplate %s is locked and cannot be assigned", name))})
+		return nil
+	}
+	if roleTemplate.Context != clusterContext {
+		response.Result = errorListToStatus(field.ErrorList{field.NotSupported(fieldPath.Child("context"), roleTemplate.Context, []string{clusterContext})})
+		return nil
+	}
+
+	if request.Operation != admissionv1.Update {
+		return nil
+	}
+	canBind, err := auth.RequestUserHasVerb(request, roleTemplateGVR, p.sar, "bind", name, "")
+	if err != nil {
+		return fmt.Errorf("failed to check bind permission on roleTemplate %s: %w", name, err)
+	}
+	if !canBind {
+		response.Result = &metav1.Status{
+			Status:  failureStatus,
+			Message: fmt.Sprintf("user is not allowed to bind roleTemplate %s", name),
+			Reason:  metav1.StatusReasonForbidden,
+			Code:    http.StatusForbidden,
+		}
+	}
+	return nil
+}
+
 // getCloudCredentialSecretInfo returns the namespace and name of the secret based off the old cloud cred or new style
 // cloud cred
 func getCloudCredentialSecretInfo(namespace, name string) (string, string) {
@@ -372,9 +647,9 @@ func getCloudCredentialSecretInfo(namespace, name string) (string, string) {
 	return namespace, name
 }
 
-func (p *provisioningAdmitter) validateClusterName(request *admission.Request, response *admissionv1.AdmissionResponse, cluster *v1.Cluster) error {
+func (p *provisioningAdmitter) validateClusterName(request *admission.Request, cluster *v1.Cluster) (field.ErrorList, error) {
 	if request.Operation != admissionv1.Create {
-		return nil
+		return nil, nil
 	}
 
 	// Look for an existing management cluster with the same name. If a management cluster with the given name does not
@@ -382,41 +657,176 @@ func (p *provisioningAdmitter) validateClusterName(request *admission.Request, r
 	// "c-xxxxx" because names of that form are reserved for "legacy" management clusters.
 	_, err := p.mgmtClusterClient.Get(cluster.Name, metav1.GetOptions{})
 	if err != nil && !apierrors.IsNotFound(err) {
-		return err
+		return nil, err
 	}
 	if !isValidName(cluster.Name, cluster.Namespace, err == nil) {
+		return field.ErrorList{field.Invalid(field.NewPath("metadata", "name"), cluster.Name,
+			"cluster name must be 63 characters or fewer, must not begin with a hyphen, cannot be \"local\" nor of the form \"c-xxxxx\", and can only contain lowercase alphanumeric characters or ' - '")}, nil
+	}
+
+	return nil, nil
+}
+
+// validateNoCreatorRBAC ensures the no-creator-rbac annotation is only added by administrators, unless the cluster is in
+// one of the namespaces listed in the no-creator-rbac-namespaces setting.
+func (p *provisioningAdmitter) validateNoCreatorRBAC(request *admission.Request, response *admissionv1.AdmissionResponse, oldCluster, cluster *v1.Cluster) error {
+	fieldErr, err := common.CheckNoCreatorRBACAllowed(request, p.sar, p.settingCache, oldCluster, cluster)
+	if err != nil {
+		return err
+	}
+	if fieldErr != nil {
 		response.Result = &metav1.Status{
 			Status:  failureStatus,
-			Message: "cluster name must be 63 characters or fewer, must not begin with a hyphen, cannot be \"local\" nor of the form \"c-xxxxx\", and can only contain lowercase alphanumeric characters or ' - '",
-			Reason:  metav1.StatusReasonInvalid,
-			Code:    http.StatusUnprocessableEntity,
+			Message: fieldErr.Error(),
+			Reason:  metav1.StatusReasonForbidden,
+			Code:    http.StatusForbidden,
 		}
 	}
-
 	return nil
 }
 
-func (p *provisioningAdmitter) validateMachinePoolNames(request *admission.Request, response *admissionv1.AdmissionResponse, cluster *v1.Cluster) error {
-	if request.Operation != admissionv1.Create {
+// validateRKEConfigConversion denies adding spec.rkeConfig to, or removing it from, an existing cluster while the
+// StrictRKEConfigConversionFeature is enabled, unless the cluster has the forceRKEConfigConversionAnnotation and the
+// user has the convert verb on the cluster. Converting a cluster this way replaces how Rancher manages its nodes, which
+// is only safe as part of a planned migration.
+func (p *provisioningAdmitter) validateRKEConfigConversion(request *admission.Request, response *admissionv1.AdmissionResponse, oldCluster, newCluster *v1.Cluster) error {
+	if request.Operation != admissionv1.Update || p.featureCache == nil {
 		return nil
 	}
+	added := oldCluster.Spec.RKEConfig == nil && newCluster.Spec.RKEConfig != nil
+	removed := oldCluster.Spec.RKEConfig != nil && newCluster.Spec.RKEConfig == nil
+	if !added && !removed {
+		return nil
+	}
+	enabled, err := common.IsFeatureEnabled(p.featureCache, StrictRKEConfigConversionFeature)
+	if err != nil || !enabled {
+		return err
+	}
 
-	if cluster.Spec.RKEConfig == nil {
+	conversion := "from imported to provisioned by adding spec.rkeConfig"
+	if removed {
+		conversion = "from provisioned to imported by removing spec.rkeConfig"
+	}
+	if newCluster.Annotations[forceRKEConfigConversionAnnotation] != "true" {
+		response.Result = &metav1.Status{
+			Status: failureStatus,
+			Message: fmt.Sprintf("cluster %s cannot be converted %s while the %s feature is enabled: to migrate the cluster, "+
+				"set the %s annotation to \"true\" in the same update, which requires the %s verb on the cluster; "+
+				"otherwise, create a new cluster and move the workloads to it", newCluster.Name, conversion,
+				StrictRKEConfigConversionFeature, forceRKEConfigConversionAnnotation, convertVerb),
+			Reason: metav1.StatusReasonInvalid,
+			Code:   http.StatusUnprocessableEntity,
+		}
 		return nil
 	}
 
-	for _, pool := range cluster.Spec.RKEConfig.MachinePools {
+	canConvert, err := auth.RequestUserHasVerb(request, gvr, p.sar, convertVerb, newCluster.Name, newCluster.Namespace)
+	if err != nil {
+		return fmt.Errorf("failed to check if user can %s cluster %s: %w", convertVerb, newCluster.Name, err)
+	}
+	if !canConvert {
+		response.Result = &metav1.Status{
+			Status: failureStatus,
+			Message: fmt.Sprintf("user %s cannot convert cluster %s %s: the %s verb on the cluster is required to use the %s annotation",
+				request.UserInfo.Username, newCluster.Name, conversion, convertVerb, forceRKEConfigConversionAnnotation),
+			Reason: metav1.StatusReasonForbidden,
+			Code:   http.StatusForbidden,
+		}
+	}
+	return nil
+}
+
+func validateMachinePoolNames(request *admission.Request, cluster *v1.Cluster) field.ErrorList {
+	if request.Operation != admissionv1.Create || cluster.Spec.RKEConfig == nil {
+		return nil
+	}
+
+	var fieldErrs field.ErrorList
+	path := field.NewPath("spec", "rkeConfig", "machinePools")
+	for i, pool := range cluster.Spec.RKEConfig.MachinePools {
 		if len(pool.Name) > 63 {
-			response.Result = &metav1.Status{
-				Status:  failureStatus,
-				Message: "pool name must be 63 characters or fewer",
-				Reason:  metav1.StatusReasonInvalid,
-				Code:    http.StatusUnprocessableEntity,
-			}
-			break
+			fieldErrs = append(fieldErrs, field.TooLong(path.Index(i).Child("name"), pool.Name, 63))
 		}
 	}
+	return fieldErrs
+}
+
+// validateMachineConfigRefs checks that the machineConfigRef of each machine pool refers to an existing object in the
+// namespace of the cluster, so that missing machine configs are reported now rather than as opaque errors when
+// provisioning the machines. Machine configs missing from the cache are fetched from the API server before being
+// reported. Only the pools added by the request are checked.
+func (p *provisioningAdmitter) validateMachineConfigRefs(oldCluster, cluster *v1.Cluster) (field.ErrorList, error) {
+	if p.dynamic == nil || cluster.Spec.RKEConfig == nil {
+		return nil, nil
+	}
 
+	existingPools := map[string]bool{}
+	if oldCluster != nil && oldCluster.Spec.RKEConfig != nil {
+		for _, pool := range oldCluster.Spec.RKEConfig.MachinePools {
+			existingPools[pool.Name] = true
+		}
+	}
+
+	var errs field.ErrorList
+	for i, pool := range cluster.Spec.RKEConfig.MachinePools {
+		if existingPools[pool.Name] || pool.NodeConfig == nil {
+			continue
+		}
+		path := field.NewPath("spec", "rkeConfig", "machinePools").Index(i).Child("machineConfigRef")
+		apiVersion := pool.NodeConfig.APIVersion
+		if apiVersion == "" {
+			apiVersion = machineConfigAPIVersion
+		}
+		gv, err := schema.ParseGroupVersion(apiVersion)
+		if err != nil {
+			errs = append(errs, field.Invalid(path.Child("apiVersion"), apiVersion, err.Error()))
+			continue
+		}
+		gvk := gv.WithKind(pool.NodeConfig.Kind)
+		_, err = p.dynamic.Get(gvk, cluster.Namespace, pool.NodeConfig.Name)
+		if apierrors.IsNotFound(err) && p.liveDynamic != nil {
+			// machine configs are usually created right before their cluster, so they may not be in the cache yet
+			_, err = p.liveDynamic.Get(gvk, cluster.Namespace, pool.NodeConfig.Name)
+		}
+		switch {
+		case err == nil:
+		case apierrors.IsNotFound(err):
+			errs = append(errs, field.NotFound(path, fmt.Sprintf("%s %s/%s", gvk.Kind, cluster.Namespace, pool.NodeConfig.Name)))
+		case meta.IsNoMatchError(err):
+			errs = append(errs, field.Invalid(path.Child("kind"), pool.NodeConfig.Kind, fmt.Sprintf("unknown kind in %s", apiVersion)))
+		default:
+			return nil, fmt.Errorf("failed to get machine config %s %s/%s: %w", gvk.Kind, cluster.Namespace, pool.NodeConfig.Name, err)
+		}
+	}
+	return errs, nil
+}
+
+// validatePSACTDowngrade checks that users changing the PSACT of a cluster to one enforcing a less restrictive level
+// have the updatepsa verb on the cluster.
+func (p *provisioningAdmitter) validatePSACTDowngrade(request *admission.Request, response *admissionv1.AdmissionResponse, oldCluster, newCluster *v1.Cluster) error {
+	if request.Operation != admissionv1.Update || admission.IsController(request) {
+		return nil
+	}
+	oldTemplateName := oldCluster.Spec.DefaultPodSecurityAdmissionConfigurationTemplateName
+	newTemplateName := newCluster.Spec.DefaultPodSecurityAdmissionConfigurationTemplateName
+	downgrade, err := common.IsPSACTDowngrade(p.psactCache, oldTemplateName, newTemplateName)
+	if err != nil || !downgrade {
+		return err
+	}
+	canDowngrade, err := auth.RequestUserHasVerb(request, gvr, p.sar, common.UpdatePSAVerb, newCluster.Name, newCluster.Namespace)
+	if err != nil {
+		return fmt.Errorf("failed to check if user can %s cluster %s: %w", common.UpdatePSAVerb, newCluster.Name, err)
+	}
+	if !canDowngrade {
+		response.Result = &metav1.Status{
+			Status: failureStatus,
+			Message: fmt.Sprintf("user %s cannot change the PodSecurityAdmissionConfigurationTemplate of cluster %s from %q to %q, "+
+				"which enforces a less restrictive level: the %s verb on the cluster is required",
+				request.UserInfo.Username, newCluster.Name, oldTemplateName, newTemplateName, common.UpdatePSAVerb),
+			Reason: metav1.StatusReasonForbidden,
+			Code:   http.StatusForbidden,
+		}
+	}
 	return nil
 }
 
@@ -518,6 +928,8 @@ func validateAgentDeploymentCustomization(customization *v1.AgentDeploymentCusto
 
 	errList = append(errList, validateAppendToleration(customization.AppendTolerations, path.Child("appendTolerations"))...)
 	errList = append(errList, validateAffinity(customization.OverrideAffinity, path.Child("overrideAffinity"))...)
+	errList = append(errList, common.ValidateResourceRequirements(customization.OverrideResourceRequirements,
+		path.Child("overrideResourceRequirements"))...)
 
 	return errList
 }
@@ -655,19 +1067,426 @@ func errorListToStatus(errList field.ErrorList) *metav1.Status {
 	}
 }
 
-func validateACEConfig(cluster *v1.Cluster) *metav1.Status {
+func validateACEConfig(cluster *v1.Cluster) field.ErrorList {
 	if cluster.Spec.RKEConfig != nil && cluster.Spec.LocalClusterAuthEndpoint.Enabled && cluster.Spec.LocalClusterAuthEndpoint.CACerts != "" && cluster.Spec.LocalClusterAuthEndpoint.FQDN == "" {
-		return &metav1.Status{
-			Status:  failureStatus,
-			Message: "CACerts defined but FQDN is not defined",
-			Reason:  metav1.StatusReasonInvalid,
-			Code:    http.StatusUnprocessableEntity,
-		}
+		return field.ErrorList{field.Required(field.NewPath("spec", "localClusterAuthEndpoint", "fqdn"), "CACerts defined but FQDN is not defined")}
 	}
 
 	return nil
 }
 
+// validateTenantPolicy checks the cluster against the TenantPolicies of its namespace. Only the fields the policies
+// apply to are checked, and only when they are set or changed, so that clusters created before a policy can still be
+// updated.
+func (p *provisioningAdmitter) validateTenantPolicy(changes diff.ChangeSet, cluster *v1.Cluster) (field.ErrorList, error) {
+	policy, err := p.tenantPolicies.ForNamespace(cluster.Namespace)
+	if err != nil {
+		return nil, err
+	}
+
+	var errList field.ErrorList
+	psactPath := field.NewPath("spec", "defaultPodSecurityAdmissionConfigurationTemplateName")
+	psactName := cluster.Spec.DefaultPodSecurityAdmissionConfigurationTemplateName
+	if changes.Changed("spec", "defaultPodSecurityAdmissionConfigurationTemplateName") || changes.Changed("spec", "rkeConfig") {
+		if psactName == "" {
+			if policy.RequirePodSecurityAdmissionConfigurationTemplate && cluster.Spec.RKEConfig != nil {
+				errList = append(errList, field.Required(psactPath, fmt.Sprintf("is required by the TenantPolicies of namespace %s", cluster.Namespace)))
+			}
+		} else if policy.AllowedPodSecurityAdmissionConfigurationTemplates != nil &&
+			!slices.Contains(policy.AllowedPodSecurityAdmissionConfigurationTemplates, psactName) {
+			errList = append(errList, field.NotSupported(psactPath, psactName, policy.AllowedPodSecurityAdmissionConfigurationTemplates))
+		}
+	}
+	if policy.ForbidInsecureS3Endpoints && cluster.Annotations[allowInsecureS3EndpointAnnotation] == "true" &&
+		changes.Changed("metadata", "annotations", allowInsecureS3EndpointAnnotation) {
+		errList = append(errList, field.Forbidden(field.NewPath("metadata", "annotations").Key(allowInsecureS3EndpointAnnotation),
+			fmt.Sprintf("insecure S3 endpoints are forbidden by the TenantPolicies of namespace %s", cluster.Namespace)))
+	}
+	return errList, nil
+}
+
+// validateETCDSnapshotS3 validates the shape of the S3 configuration of etcd snapshots: the endpoint must be a host with
+// an optional port and https scheme, the bucket must follow the S3 bucket naming rules, and the region must be
+// consistent with regional AWS endpoints. Plain http endpoints are only allowed when the cluster has the
+// allow-insecure-s3-endpoint annotation. The configuration is only validated when it is set or changed, so that existing
+// clusters can still be updated.
+func validateETCDSnapshotS3(changes diff.ChangeSet, newCluster *v1.Cluster) field.ErrorList {
+	if newCluster.Spec.RKEConfig == nil || newCluster.Spec.RKEConfig.ETCD == nil || newCluster.Spec.RKEConfig.ETCD.S3 == nil {
+		return nil
+	}
+	s3 := newCluster.Spec.RKEConfig.ETCD.S3
+	if !changes.Changed("spec", "rkeConfig", "etcd", "s3") &&
+		!changes.Changed("metadata", "annotations", allowInsecureS3EndpointAnnotation) {
+		return nil
+	}
+	path := field.NewPath("spec", "rkeConfig", "etcd", "s3")
+
+	var errList field.ErrorList
+	if s3.Endpoint != "" {
+		endpoint := s3.Endpoint
+		if !strings.Contains(endpoint, "://") {
+			endpoint = "https://" + endpoint
+		}
+		endpointURL, err := url.Parse(endpoint)
+		switch {
+		case err != nil:
+			errList = append(errList, field.Invalid(path.Child("endpoint"), s3.Endpoint, "must be a host with an optional port"))
+		case endpointURL.Host == "" || endpointURL.User != nil || strings.Trim(endpointURL.Path, "/") != "" || endpointURL.RawQuery != "" || endpointURL.Fragment != "":
+			errList = append(errList, field.Invalid(path.Child("endpoint"), s3.Endpoint, "must be a host with an optional port"))
+		case endpointURL.Scheme == "http":
+			if newCluster.Annotations[allowInsecureS3EndpointAnnotation] != "true" {
+				errList = append(errList, field.Forbidden(path.Child("endpoint"),
+					fmt.Sprintf("plain http endpoints are only allowed when the %s annotation is \"true\"", allowInsecureS3EndpointAnnotation)))
+			}
+		case endpointURL.Scheme != "https":
+			errList = append(errList, field.NotSupported(path.Child("endpoint"), endpointURL.Scheme, []string{"https", "http"}))
+		}
+		if err == nil && s3.Region != "" {
+			if match := awsS3EndpointRegionRegex.FindStringSubmatch(endpointURL.Hostname()); match != nil && match[1] != s3.Region {
+				errList = append(errList, field.Invalid(path.Child("region"), s3.Region, fmt.Sprintf("must match the region %s of the endpoint", match[1])))
+			}
+		}
+	}
+
+	if s3.Bucket != "" {
+		if msg := validateS3BucketName(s3.Bucket); msg != "" {
+			errList = append(errList, field.Invalid(path.Child("bucket"), s3.Bucket, msg))
+		}
+	}
+
+	if s3.Region != "" && !s3RegionRegex.MatchString(s3.Region) {
+		errList = append(errList, field.Invalid(path.Child("region"), s3.Region, "must consist of lower case alphanumeric characters separated by '-'"))
+	}
+	return errList
+}
+
+// validateRegistries checks the registries of the cluster: the endpoints of the mirrors must be http or https URLs,
+// the secrets referenced by the configs must exist in the namespace of the cluster, and the same registry must not be
+// configured twice under hostnames which only differ by case or scheme. The registries are only checked when they change.
+func (p *provisioningAdmitter) validateRegistries(changes diff.ChangeSet, newCluster *v1.Cluster) (field.ErrorList, error) {
+	if newCluster.Spec.RKEConfig == nil || newCluster.Spec.RKEConfig.Registries == nil {
+		return nil, nil
+	}
+	registries := newCluster.Spec.RKEConfig.Registries
+	if !changes.Changed("spec", "rkeConfig", "registries") {
+		return nil, nil
+	}
+	path := field.NewPath("spec", "rkeConfig", "registries")
+
+	var errList field.ErrorList
+	mirrorHosts := map[string]bool{}
+	for _, name := range slices.Sorted(maps.Keys(registries.Mirrors)) {
+		mirrorPath := path.Child("mirrors").Key(name)
+		if host := normalizeRegistryHost(name); mirrorHosts[host] {
+			errList = append(errList, field.Duplicate(mirrorPath, name))
+		} else {
+			mirrorHosts[host] = true
+		}
+		for i, endpoint := range registries.Mirrors[name].Endpoints {
+			endpointURL, err := url.Parse(endpoint)
+			switch {
+			case err != nil || endpointURL.Host == "":
+				errList = append(errList, field.Invalid(mirrorPath.Child("endpoint").Index(i), endpoint, "must be a URL with a host"))
+			case endpointURL.Scheme != "https" && endpointURL.Scheme != "http":
+				errList = append(errList, field.NotSupported(mirrorPath.Child("endpoint").Index(i), endpointURL.Scheme, []string{"https", "http"}))
+			}
+		}
+	}
+
+	configHosts := map[string]bool{}
+	for _, name := range slices.Sorted(maps.Keys(registries.Configs)) {
+		configPath := path.Child("configs").Key(name)
+		if host := normalizeRegistryHost(name); configHosts[host] {
+			errList = append(errList, field.Duplicate(configPath, name))
+		} else {
+			configHosts[host] = true
+		}
+		config := registries.Configs[name]
+		for _, secret := range []struct {
+			name string
+			path *field.Path
+		}{
+			{name: config.AuthConfigSecretName, path: configPath.Child("authConfigSecretName")},
+			{name: config.TLSSecretName, path: configPath.Child("tlsSecretName")},
+		} {
+			if secret.name == "" {
+				continue
+			}
+			if _, err := p.secretCache.Get(newCluster.Namespace, secret.name); err != nil {
+				if !apierrors.IsNotFound(err) {
+					return nil, fmt.Errorf("failed to get secret %s/%s: %w", newCluster.Namespace, secret.name, err)
+				}
+				errList = append(errList, field.NotFound(secret.path, secret.name))
+			}
+		}
+	}
+	return errList, nil
+}
+
+// normalizeRegistryHost returns the hostname of a registry without scheme, trailing slash and case differences.
+func normalizeRegistryHost(name string) string {
+	host := strings.ToLower(strings.TrimSpace(name))
+	if _, afterScheme, ok := strings.Cut(host, "://"); ok {
+		host = afterScheme
+	}
+	return strings.TrimSuffix(host, "/")
+}
+
+// validateMinKubernetesVersion ensures that RKE2 and K3s clusters aren't created or upgraded to a Kubernetes version
+// below the one set in the provisioning-min-kubernetes-version setting. Clusters whose version doesn't change are not
+// checked, so that existing clusters below the minimum can still be updated.
+func (p *provisioningAdmitter) validateMinKubernetesVersion(oldCluster, newCluster *v1.Cluster) (field.ErrorList, error) {
+	version := newCluster.Spec.KubernetesVersion
+	if newCluster.Spec.RKEConfig == nil || version == "" || version == oldCluster.Spec.KubernetesVersion {
+		return nil, nil
+	}
+	minVersion, err := common.GetSettingValue(p.settingCache, common.MinKubernetesVersionSetting)
+	if err != nil {
+		return nil, err
+	}
+	if minVersion == "" {
+		return nil, nil
+	}
+	parsedMin, err := common.ParseKubernetesVersion(minVersion)
+	if err != nil {
+		// the setting validator rejects invalid versions, don't block provisioning if one got through
+		logrus.Warnf("[provisioningClusterValidator] ignoring invalid %s setting: %v", common.MinKubernetesVersionSetting, err)
+		return nil, nil
+	}
+	versionPath := field.NewPath("spec", "kubernetesVersion")
+	parsedVersion, err := common.ParseKubernetesVersion(version)
+	if err != nil {
+		return field.ErrorList{field.Invalid(versionPath, version, err.Error())}, nil
+	}
+	if parsedVersion.LT(parsedMin) {
+		return field.ErrorList{field.Invalid(versionPath, version, fmt.Sprintf("must be at least %s, as set in the %s setting",
+			minVersion, common.MinKubernetesVersionSetting))}, nil
+	}
+	return nil, nil
+}
+
+// validateSchedulingCustomization checks the priority class of the cluster agent's scheduling customization when it
+// changes, so that clusters whose priority class was set before it was validated can still be updated. Whether it
+// collides with the PriorityClasses of the local cluster is checked by the management cluster validator.
+func validateSchedulingCustomization(oldObject, newObject []byte) (field.ErrorList, error) {
+	newCustomization, err := common.ClusterAgentSchedulingCustomization(newObject)
+	if err != nil {
+		return nil, err
+	}
+	newPriorityClass := newCustomization.GetPriorityClass()
+	if newPriorityClass == nil {
+		return nil, nil
+	}
+	// the raw objects are compared, as the vendored Rancher API doesn't have the scheduling customization
+	changes, err := diff.JSON(oldObject, newObject)
+	if err != nil {
+		return nil, fmt.Errorf("failed to compare old and new clusters: %w", err)
+	}
+	if !changes.Changed("spec", "clusterAgentDeploymentCustomization", "schedulingCustomization", "priorityClass") {
+		return nil, nil
+	}
+	return common.ValidatePriorityClass(newPriorityClass,
+		field.NewPath("spec", "clusterAgentDeploymentCustomization", "schedulingCustomization", "priorityClass")), nil
+}
+
+// validateAgentEnvVars ensures that the agentEnvVars of the cluster aren't denied by the agent-env-vars-deny-list
+// setting.
+func (p *provisioningAdmitter) validateAgentEnvVars(oldCluster, newCluster *v1.Cluster) (field.ErrorList, error) {
+	return common.ValidateAgentEnvVarNames(p.settingCache, field.NewPath("spec", "agentEnvVars"),
+		envVarNames(oldCluster.Spec.AgentEnvVars), envVarNames(newCluster.Spec.AgentEnvVars))
+}
+
+func envVarNames(envVars []rkev1.EnvVar) []string {
+	names := make([]string, 0, len(envVars))
+	for _, envVar := range envVars {
+		names = append(names, envVar.Name)
+	}
+	return names
+}
+
+// validateWindowsMachinePools validates that clusters with Windows machine pools can actually provision Windows workers:
+// the cluster must run RKE2 at a version supporting Windows, its CNI must support Windows, and the Windows pools may only
+// hold the worker role. The pools are only validated when they, the version or the CNI change, so that existing clusters
+// can still be updated.
+func validateWindowsMachinePools(oldCluster, newCluster *v1.Cluster) field.ErrorList {
+	if newCluster.Spec.RKEConfig == nil || newCluster.DeletionTimestamp != nil {
+		return nil
+	}
+	pools := windowsMachinePools(newCluster)
+	if len(pools) == 0 {
+		return nil
+	}
+	if oldCluster.Spec.KubernetesVersion == newCluster.Spec.KubernetesVersion && getCNI(oldCluster) == getCNI(newCluster) &&
+		slices.EqualFunc(windowsMachinePools(oldCluster), pools, func(a, b v1.RKEMachinePool) bool {
+			return a.Name == b.Name && a.EtcdRole == b.EtcdRole && a.ControlPlaneRole == b.ControlPlaneRole && a.WorkerRole == b.WorkerRole
+		}) {
+		return nil
+	}
+
+	var errList field.ErrorList
+	version := newCluster.Spec.KubernetesVersion
+	versionPath := field.NewPath("spec", "kubernetesVersion")
+	if getRuntime(version) != runtimeRKE2 {
+		errList = append(errList, field.Invalid(versionPath, version, "windows machine pools are only supported on RKE2"))
+	} else if parsedVersion, err := psa.GetClusterVersion(version); err != nil {
+		errList = append(errList, field.Invalid(versionPath, version, err.Error()))
+	} else if parsedRangeLessThanWindows(parsedVersion) {
+		errList = append(errList, field.Invalid(versionPath, version, "windows machine pools require RKE2 v1.22.5 or above"))
+	}
+
+	if cni := getCNI(newCluster); !slices.ContainsFunc(strings.Split(cni, ","), func(c string) bool {
+		return slices.Contains(windowsCNIs, strings.TrimSpace(c))
+	}) {
+		errList = append(errList, field.NotSupported(field.NewPath("spec", "rkeConfig", "machineGlobalConfig", "cni"), cni, windowsCNIs))
+	}
+
+	for i, pool := range newCluster.Spec.RKEConfig.MachinePools {
+		if pool.MachineOS != windowsMachineOS {
+			continue
+		}
+		poolPath := field.NewPath("spec", "rkeConfig", "machinePools").Index(i)
+		if pool.EtcdRole {
+			errList = append(errList, field.Forbidden(poolPath.Child("etcdRole"), "windows machine pools can only have the worker role"))
+		}
+		if pool.ControlPlaneRole {
+			errList = append(errList, field.Forbidden(poolPath.Child("controlPlaneRole"), "windows machine pools can only have the worker role"))
+		}
+	}
+	return errList
+}
+
+// validateMachinePoolLabelsAndTaints validates the syntax of the labels, taints and machineDeploymentLabels of the
+// machine pools, which would otherwise only fail once CAPI reconciles the machines. Pools are only validated when they
+// are new or one of these fields changed, so that existing clusters can still be updated.
+func validateMachinePoolLabelsAndTaints(oldCluster, newCluster *v1.Cluster) field.ErrorList {
+	if newCluster.Spec.RKEConfig == nil || newCluster.DeletionTimestamp != nil {
+		return nil
+	}
+	oldPools := map[string]v1.RKEMachinePool{}
+	if oldCluster.Spec.RKEConfig != nil {
+		for _, pool := range oldCluster.Spec.RKEConfig.MachinePools {
+			oldPools[pool.Name] = pool
+		}
+	}
+
+	var errList field.ErrorList
+	for i, pool := range newCluster.Spec.RKEConfig.MachinePools {
+		if oldPool, ok := oldPools[pool.Name]; ok && maps.Equal(oldPool.Labels, pool.Labels) &&
+			maps.Equal(oldPool.MachineDeploymentLabels, pool.MachineDeploymentLabels) &&
+			slices.EqualFunc(oldPool.Taints, pool.Taints, func(a, b k8sv1.Taint) bool { return a.MatchTaint(&b) && a.Value == b.Value }) {
+			continue
+		}
+		poolPath := field.NewPath("spec", "rkeConfig", "machinePools").Index(i)
+		errList = append(errList, validation.ValidateLabels(pool.Labels, poolPath.Child("labels"))...)
+		errList = append(errList, validation.ValidateLabels(pool.MachineDeploymentLabels, poolPath.Child("machineDeploymentLabels"))...)
+		errList = append(errList, validateTaints(pool.Taints, poolPath.Child("taints"))...)
+	}
+	return errList
+}
+
+// validateMachinePoolRollingUpdates validates the maxUnavailable and maxSurge of the rolling updates of the machine
+// pools, which would otherwise stall the scaling of the pools. Pools are only validated when they are new or their
+// rolling update changed, so that existing clusters can still be updated.
+func validateMachinePoolRollingUpdates(oldCluster, newCluster *v1.Cluster) (field.ErrorList, error) {
+	if newCluster.Spec.RKEConfig == nil || newCluster.DeletionTimestamp != nil {
+		return nil, nil
+	}
+	oldPools := map[string]v1.RKEMachinePool{}
+	if oldCluster.Spec.RKEConfig != nil {
+		for _, pool := range oldCluster.Spec.RKEConfig.MachinePools {
+			oldPools[pool.Name] = pool
+		}
+	}
+
+	var errList field.ErrorList
+	for i, pool := range newCluster.Spec.RKEConfig.MachinePools {
+		if pool.RollingUpdate == nil {
+			continue
+		}
+		if oldPool, ok := oldPools[pool.Name]; ok {
+			// machine pools are a list, so their changes are compared pool by pool
+			changes, err := diff.Objects(oldPool, pool)
+			if err != nil {
+				return nil, fmt.Errorf("failed to compare machine pool %s: %w", pool.Name, err)
+			}
+			if !changes.Changed("rollingUpdate") {
+				continue
+			}
+		}
+		errList = append(errList, common.ValidateRollingUpdate(pool.RollingUpdate.MaxUnavailable, pool.RollingUpdate.MaxSurge,
+			field.NewPath("spec", "rkeConfig", "machinePools").Index(i).Child("rollingUpdate"))...)
+	}
+	return errList, nil
+}
+
+// validateTaints validates the key, value and effect of the taints, and that no two taints share a key and effect.
+func validateTaints(taints []k8sv1.Taint, path *field.Path) field.ErrorList {
+	var errList field.ErrorList
+	seen := map[string]bool{}
+	for i, taint := range taints {
+		taintPath := path.Index(i)
+		for _, msg := range utilvalidation.IsQualifiedName(taint.Key) {
+			errList = append(errList, field.Invalid(taintPath.Child("key"), taint.Key, msg))
+		}
+		for _, msg := range utilvalidation.IsValidLabelValue(taint.Value) {
+			errList = append(errList, field.Invalid(taintPath.Child("value"), taint.Value, msg))
+		}
+		switch taint.Effect {
+		case k8sv1.TaintEffectNoSchedule, k8sv1.TaintEffectPreferNoSchedule, k8sv1.TaintEffectNoExecute:
+		default:
+			errList = append(errList, field.NotSupported(taintPath.Child("effect"), taint.Effect,
+				[]k8sv1.TaintEffect{k8sv1.TaintEffectNoSchedule, k8sv1.TaintEffectPreferNoSchedule, k8sv1.TaintEffectNoExecute}))
+		}
+		id := taint.Key + ":" + string(taint.Effect)
+		if seen[id] {
+			errList = append(errList, field.Duplicate(taintPath, id))
+		}
+		seen[id] = true
+	}
+	return errList
+}
+
+// windowsMachinePools returns the machine pools of the cluster which provision Windows machines.
+func windowsMachinePools(cluster *v1.Cluster) []v1.RKEMachinePool {
+	if cluster.Spec.RKEConfig == nil {
+		return nil
+	}
+	var pools []v1.RKEMachinePool
+	for _, pool := range cluster.Spec.RKEConfig.MachinePools {
+		if pool.MachineOS == windowsMachineOS {
+			pools = append(pools, pool)
+		}
+	}
+	return pools
+}
+
+// getCNI returns the CNI configured in the cluster's MachineGlobalConfig, or an empty string if it is not set, in which
+// case the distribution's default is used.
+func getCNI(cluster *v1.Cluster) string {
+	if cluster.Spec.RKEConfig == nil || cluster.Spec.RKEConfig.MachineGlobalConfig.Data == nil {
+		return ""
+	}
+	cni, _ := cluster.Spec.RKEConfig.MachineGlobalConfig.Data["cni"].(string)
+	return cni
+}
+
+// validateS3BucketName returns why name is not a valid S3 bucket name, or an empty string if it is valid.
+func validateS3BucketName(name string) string {
+	switch {
+	case !s3BucketRegex.MatchString(name):
+		return "must be 3 to 63 lower case alphanumeric characters, '.' or '-', and start and end with an alphanumeric character"
+	case strings.Contains(name, ".."):
+		return "must not contain two adjacent periods"
+	case net.ParseIP(name) != nil:
+		return "must not be formatted as an IP address"
+	case strings.HasPrefix(name, "xn--") || strings.HasPrefix(name, "sthree-"):
+		return "must not start with a reserved prefix"
+	case strings.HasSuffix(name, "-s3alias") || strings.HasSuffix(name, "--ol-s3"):
+		return "must not end with a reserved suffix"
+	}
+	return ""
+}
+
 func isValidName(clusterName, clusterNamespace string, clusterExists bool) bool {
 	// A provisioning cluster with name "local" is only expected to be created in the "fleet-local" namespace.
 	if clusterName == localCluster {
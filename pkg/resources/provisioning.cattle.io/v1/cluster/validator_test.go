@@ -1,18 +1,41 @@
 package cluster
 
 import (
+	"context"
+	"encoding/json"
 	"fmt"
+	"maps"
+	"slices"
 	"strings"
 	"testing"
 
+	v3 "github.com/rancher/rancher/pkg/apis/management.cattle.io/v3"
 	v1 "github.com/rancher/rancher/pkg/apis/provisioning.cattle.io/v1"
 	rkev1 "github.com/rancher/rancher/pkg/apis/rke.cattle.io/v1"
 	"github.com/rancher/webhook/pkg/admission"
+	webhookv1 "github.com/rancher/webhook/pkg/apis/webhook.cattle.io/v1"
+	"github.com/rancher/webhook/pkg/diff"
+	"github.com/rancher/webhook/pkg/resources/common"
+	"github.com/rancher/webhook/pkg/tenantpolicy"
+	"github.com/rancher/wrangler/v3/pkg/generic/fake"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
 	admissionv1 "k8s.io/api/admission/v1"
+	authenticationv1 "k8s.io/api/authentication/v1"
+	authv1 "k8s.io/api/authorization/v1"
 	k8sv1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/api/resource"
 	v12 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/intstr"
 	"k8s.io/apimachinery/pkg/util/validation/field"
+	k8fake "k8s.io/client-go/kubernetes/typed/authorization/v1/fake"
+	k8testing "k8s.io/client-go/testing"
 )
 
 func Test_isValidName(t *testing.T) {
@@ -167,41 +190,25 @@ func TestValidateMachinePoolName(t *testing.T) {
 		},
 	}
 
-	a := provisioningAdmitter{}
-
 	for _, tt := range tests {
 		tt := tt
 		t.Run(tt.name, func(t *testing.T) {
 			t.Parallel()
-			resp := admissionv1.AdmissionResponse{}
-
-			err := a.validateMachinePoolNames(
+			fieldErrs := validateMachinePoolNames(
 				&admission.Request{AdmissionRequest: admissionv1.AdmissionRequest{Operation: admissionv1.Create}},
-				&resp,
 				&v1.Cluster{
 					Spec: v1.ClusterSpec{
 						RKEConfig: &v1.RKEConfig{
-							MachinePools: []v1.RKEMachinePool{{Name: tt.value}},
+							MachinePools: []v1.RKEMachinePool{{Name: "pool"}, {Name: tt.value}},
 						},
 					},
 				},
 			)
 
-			if err != nil {
-				t.Errorf("got error when none was expected: %v", err)
-			}
-
 			if tt.fail {
-				if resp.Result == nil {
-					t.Error("got no result on response when one was expected")
-				}
-				if resp.Result.Status != "Failure" {
-					t.Errorf("got %v when Failure was expected", resp.Result.Status)
-				}
+				validateFailedPaths([]string{"spec.rkeConfig.machinePools[1].name"})(t, fieldErrs)
 			} else {
-				if resp.Result != nil {
-					t.Error("got result on response when none was expected")
-				}
+				assert.Empty(t, fieldErrs)
 			}
 		})
 	}
@@ -1136,6 +1143,45 @@ func TestValidateDataDirectoryHierarchy(t *testing.T) {
 	}
 }
 
+func TestDeprecationWarnings(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name         string
+		envVars      []rkev1.EnvVar
+		wantWarnings int
+	}{
+		{
+			name: "no deprecated fields",
+			envVars: []rkev1.EnvVar{
+				{Name: "HTTP_PROXY", Value: "proxy"},
+			},
+		},
+		{
+			name: "system agent var dir env var",
+			envVars: []rkev1.EnvVar{
+				{Name: systemAgentVarDirEnvVar, Value: "/var/lib/rancher/agent"},
+			},
+			wantWarnings: 1,
+		},
+		{
+			name: "empty system agent var dir env var",
+			envVars: []rkev1.EnvVar{
+				{Name: systemAgentVarDirEnvVar},
+			},
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			cluster := &v1.Cluster{Spec: v1.ClusterSpec{AgentEnvVars: tt.envVars}}
+			warnings := deprecationWarnings(cluster)
+			assert.Len(t, warnings, tt.wantWarnings)
+		})
+	}
+}
+
 func validateFailedPaths(s []string) func(t *testing.T, err field.ErrorList) {
 	return func(t *testing.T, err field.ErrorList) {
 		t.Helper()
@@ -1497,6 +1543,27 @@ func Test_validateAgentDeploymentCustomization(t *testing.T) {
 				"test.overrideAffinity.podAntiAffinity.preferredDuringSchedulingIgnoredDuringExecution[0].podAffinityTerm.namespaceSelector.matchExpressions[1].key",
 			}),
 		},
+		{
+			name: "invalid resource requirements",
+			args: args{
+				customization: &v1.AgentDeploymentCustomization{
+					OverrideResourceRequirements: &k8sv1.ResourceRequirements{
+						Limits: k8sv1.ResourceList{
+							k8sv1.ResourceCPU:    resource.MustParse("500m"),
+							k8sv1.ResourceMemory: resource.MustParse("-1Gi"),
+						},
+						Requests: k8sv1.ResourceList{
+							k8sv1.ResourceCPU: resource.MustParse("1"),
+						},
+					},
+				},
+				path: field.NewPath("test"),
+			},
+			validateFunc: validateFailedPaths([]string{
+				"test.overrideResourceRequirements.limits[memory]",
+				"test.overrideResourceRequirements.requests[cpu]",
+			}),
+		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
@@ -1505,3 +1572,1362 @@ func Test_validateAgentDeploymentCustomization(t *testing.T) {
 		})
 	}
 }
+
+func TestValidateDefaultClusterRoleForProjectMembers(t *testing.T) {
+	t.Parallel()
+	const bindUser = "bind-user"
+	roleTemplates := map[string]*v3.RoleTemplate{
+		"cluster-role": {ObjectMeta: v12.ObjectMeta{Name: "cluster-role"}, Context: "cluster"},
+		"project-role": {ObjectMeta: v12.ObjectMeta{Name: "project-role"}, Context: "project"},
+		"locked-role":  {ObjectMeta: v12.ObjectMeta{Name: "locked-role"}, Context: "cluster", Locked: true},
+	}
+	tests := []struct {
+		name      string
+		operation admissionv1.Operation
+		username  string
+		oldRole   string
+		newRole   string
+		allowed   bool
+		wantErr   bool
+	}{
+		{
+			name:      "unset",
+			operation: admissionv1.Create,
+			allowed:   true,
+		},
+		{
+			name:      "create with cluster role",
+			operation: admissionv1.Create,
+			newRole:   "cluster-role",
+			allowed:   true,
+		},
+		{
+			name:      "create with missing role",
+			operation: admissionv1.Create,
+			newRole:   "missing-role",
+		},
+		{
+			name:      "create with project role",
+			operation: admissionv1.Create,
+			newRole:   "project-role",
+		},
+		{
+			name:      "create with locked role",
+			operation: admissionv1.Create,
+			newRole:   "locked-role",
+		},
+		{
+			name:      "create with failure to get role",
+			operation: admissionv1.Create,
+			newRole:   "error-role",
+			wantErr:   true,
+		},
+		{
+			name:      "update unchanged role without bind permission",
+			operation: admissionv1.Update,
+			oldRole:   "project-role",
+			newRole:   "project-role",
+			allowed:   true,
+		},
+		{
+			name:      "update role with bind permission",
+			operation: admissionv1.Update,
+			username:  bindUser,
+			newRole:   "cluster-role",
+			allowed:   true,
+		},
+		{
+			name:      "update role without bind permission",
+			operation: admissionv1.Update,
+			oldRole:   "other-role",
+			newRole:   "cluster-role",
+		},
+		{
+			name:      "update removing role",
+			operation: admissionv1.Update,
+			oldRole:   "cluster-role",
+			allowed:   true,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			roleTemplateCache := fake.NewMockNonNamespacedCacheInterface[*v3.RoleTemplate](gomock.NewController(t))
+			roleTemplateCache.EXPECT().Get(gomock.Any()).DoAndReturn(func(name string) (*v3.RoleTemplate, error) {
+				if name == "error-role" {
+					return nil, fmt.Errorf("server unavailable")
+				}
+				if rt, ok := roleTemplates[name]; ok {
+					return rt, nil
+				}
+				return nil, apierrors.NewNotFound(schema.GroupResource{}, name)
+			}).AnyTimes()
+			k8Fake := &k8testing.Fake{}
+			k8Fake.AddReactor("create", "subjectaccessreviews", func(action k8testing.Action) (bool, runtime.Object, error) {
+				review := action.(k8testing.CreateActionImpl).GetObject().(*authv1.SubjectAccessReview)
+				review.Status.Allowed = review.Spec.User == bindUser && review.Spec.ResourceAttributes.Verb == "bind"
+				return true, review, nil
+			})
+			a := provisioningAdmitter{
+				roleTemplateCache: roleTemplateCache,
+				sar:               &k8fake.FakeSubjectAccessReviews{Fake: &k8fake.FakeAuthorizationV1{Fake: k8Fake}},
+			}
+			request := &admission.Request{
+				AdmissionRequest: admissionv1.AdmissionRequest{
+					Operation: tt.operation,
+					UserInfo:  authenticationv1.UserInfo{Username: tt.username},
+				},
+				Context: context.Background(),
+			}
+			oldCluster := &v1.Cluster{Spec: v1.ClusterSpec{DefaultClusterRoleForProjectMembers: tt.oldRole}}
+			newCluster := &v1.Cluster{Spec: v1.ClusterSpec{DefaultClusterRoleForProjectMembers: tt.newRole}}
+
+			response := &admissionv1.AdmissionResponse{}
+			err := a.validateDefaultClusterRoleForProjectMembers(request, response, oldCluster, newCluster)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.allowed, response.Result == nil)
+		})
+	}
+}
+
+func TestValidateFields(t *testing.T) {
+	t.Parallel()
+	cluster := &v1.Cluster{
+		ObjectMeta: v12.ObjectMeta{Name: "c-1", Namespace: "fleet-default"},
+		Spec: v1.ClusterSpec{
+			RKEConfig: &v1.RKEConfig{
+				MachinePools: []v1.RKEMachinePool{{Name: strings.Repeat("a", 64)}, {Name: strings.Repeat("b", 64)}},
+			},
+			LocalClusterAuthEndpoint: rkev1.LocalClusterAuthEndpoint{Enabled: true, CACerts: "ca"},
+			ClusterAgentDeploymentCustomization: &v1.AgentDeploymentCustomization{
+				AppendTolerations: []k8sv1.Toleration{{Key: "bad key"}},
+			},
+		},
+	}
+	request := &admission.Request{AdmissionRequest: admissionv1.AdmissionRequest{Operation: admissionv1.Create}}
+	ctrl := gomock.NewController(t)
+	clusterClient := fake.NewMockNonNamespacedClientInterface[*v3.Cluster, *v3.ClusterList](ctrl)
+	clusterClient.EXPECT().Get("c-1", gomock.Any()).Return(&v3.Cluster{}, nil)
+	settingCache := fake.NewMockNonNamespacedCacheInterface[*v3.Setting](ctrl)
+	settingCache.EXPECT().Get(gomock.Any()).DoAndReturn(func(name string) (*v3.Setting, error) {
+		return nil, apierrors.NewNotFound(schema.GroupResource{}, name)
+	}).AnyTimes()
+	a := provisioningAdmitter{mgmtClusterClient: clusterClient, settingCache: settingCache}
+
+	fieldErrs, err := a.validateFields(request, &v1.Cluster{}, cluster)
+	assert.NoError(t, err)
+	validateFailedPaths([]string{
+		"spec.rkeConfig.machinePools[0].name",
+		"spec.rkeConfig.machinePools[1].name",
+		"spec.localClusterAuthEndpoint.fqdn",
+		"spec.clusterAgentDeploymentCustomization.appendTolerations[0]",
+	})(t, fieldErrs)
+
+	status := errorListToStatus(fieldErrs)
+	assert.Equal(t, v12.StatusReasonInvalid, status.Reason)
+	assert.Equal(t, 4, strings.Count(status.Message, "* "))
+}
+
+func TestValidateRKEConfigConversion(t *testing.T) {
+	t.Parallel()
+	const convertUser = "convert-user"
+	force := map[string]string{forceRKEConfigConversionAnnotation: "true"}
+	tests := []struct {
+		name           string
+		operation      admissionv1.Operation
+		featureEnabled bool
+		username       string
+		oldRKEConfig   bool
+		newRKEConfig   bool
+		annotations    map[string]string
+		allowed        bool
+	}{
+		{
+			name:         "feature disabled",
+			operation:    admissionv1.Update,
+			newRKEConfig: true,
+			allowed:      true,
+		},
+		{
+			name:           "create",
+			operation:      admissionv1.Create,
+			featureEnabled: true,
+			newRKEConfig:   true,
+			allowed:        true,
+		},
+		{
+			name:           "unchanged provisioned cluster",
+			operation:      admissionv1.Update,
+			featureEnabled: true,
+			oldRKEConfig:   true,
+			newRKEConfig:   true,
+			allowed:        true,
+		},
+		{
+			name:           "add rkeConfig without annotation",
+			operation:      admissionv1.Update,
+			featureEnabled: true,
+			username:       convertUser,
+			newRKEConfig:   true,
+		},
+		{
+			name:           "remove rkeConfig without annotation",
+			operation:      admissionv1.Update,
+			featureEnabled: true,
+			username:       convertUser,
+			oldRKEConfig:   true,
+		},
+		{
+			name:           "add rkeConfig with annotation and convert verb",
+			operation:      admissionv1.Update,
+			featureEnabled: true,
+			username:       convertUser,
+			newRKEConfig:   true,
+			annotations:    force,
+			allowed:        true,
+		},
+		{
+			name:           "remove rkeConfig with annotation and convert verb",
+			operation:      admissionv1.Update,
+			featureEnabled: true,
+			username:       convertUser,
+			oldRKEConfig:   true,
+			annotations:    force,
+			allowed:        true,
+		},
+		{
+			name:           "add rkeConfig with annotation without convert verb",
+			operation:      admissionv1.Update,
+			featureEnabled: true,
+			username:       "user",
+			newRKEConfig:   true,
+			annotations:    force,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			featureCache := fake.NewMockNonNamespacedCacheInterface[*v3.Feature](gomock.NewController(t))
+			featureCache.EXPECT().Get(StrictRKEConfigConversionFeature).Return(&v3.Feature{
+				ObjectMeta: v12.ObjectMeta{Name: StrictRKEConfigConversionFeature},
+				Spec:       v3.FeatureSpec{Value: &tt.featureEnabled},
+			}, nil).AnyTimes()
+			k8Fake := &k8testing.Fake{}
+			k8Fake.AddReactor("create", "subjectaccessreviews", func(action k8testing.Action) (bool, runtime.Object, error) {
+				review := action.(k8testing.CreateActionImpl).GetObject().(*authv1.SubjectAccessReview)
+				assert.Equal(t, "c-1", review.Spec.ResourceAttributes.Name)
+				review.Status.Allowed = review.Spec.User == convertUser && review.Spec.ResourceAttributes.Verb == convertVerb
+				return true, review, nil
+			})
+			a := provisioningAdmitter{
+				featureCache: featureCache,
+				sar:          &k8fake.FakeSubjectAccessReviews{Fake: &k8fake.FakeAuthorizationV1{Fake: k8Fake}},
+			}
+			request := &admission.Request{
+				AdmissionRequest: admissionv1.AdmissionRequest{
+					Operation: tt.operation,
+					UserInfo:  authenticationv1.UserInfo{Username: tt.username},
+				},
+				Context: context.Background(),
+			}
+			oldCluster := &v1.Cluster{ObjectMeta: v12.ObjectMeta{Name: "c-1", Namespace: "fleet-default"}}
+			if tt.oldRKEConfig {
+				oldCluster.Spec.RKEConfig = &v1.RKEConfig{}
+			}
+			newCluster := &v1.Cluster{ObjectMeta: v12.ObjectMeta{Name: "c-1", Namespace: "fleet-default", Annotations: tt.annotations}}
+			if tt.newRKEConfig {
+				newCluster.Spec.RKEConfig = &v1.RKEConfig{}
+			}
+
+			response := &admissionv1.AdmissionResponse{}
+			err := a.validateRKEConfigConversion(request, response, oldCluster, newCluster)
+			assert.NoError(t, err)
+			assert.Equal(t, tt.allowed, response.Result == nil)
+		})
+	}
+}
+
+func TestValidateRegistries(t *testing.T) {
+	t.Parallel()
+
+	clusterWithRegistries := func(registries *rkev1.Registry) *v1.Cluster {
+		return &v1.Cluster{
+			ObjectMeta: v12.ObjectMeta{Name: "cluster", Namespace: "fleet-default"},
+			Spec: v1.ClusterSpec{
+				RKEConfig: &v1.RKEConfig{
+					RKEClusterSpecCommon: rkev1.RKEClusterSpecCommon{Registries: registries},
+				},
+			},
+		}
+	}
+	mirrors := func(endpoints map[string][]string) *rkev1.Registry {
+		registries := &rkev1.Registry{Mirrors: map[string]rkev1.Mirror{}}
+		for name, mirrorEndpoints := range endpoints {
+			registries.Mirrors[name] = rkev1.Mirror{Endpoints: mirrorEndpoints}
+		}
+		return registries
+	}
+
+	tests := []struct {
+		name         string
+		oldCluster   *v1.Cluster
+		newCluster   *v1.Cluster
+		failedFields []string
+		wantErr      bool
+	}{
+		{
+			name:       "no registries",
+			newCluster: clusterWithRegistries(nil),
+		},
+		{
+			name: "valid registries",
+			newCluster: clusterWithRegistries(&rkev1.Registry{
+				Mirrors: map[string]rkev1.Mirror{
+					"docker.io": {Endpoints: []string{"https://mirror.example.com:5000", "http://10.0.0.1/v2"}},
+				},
+				Configs: map[string]rkev1.RegistryConfig{
+					"mirror.example.com:5000": {AuthConfigSecretName: "registry-auth", TLSSecretName: "registry-tls"},
+				},
+			}),
+		},
+		{
+			name: "invalid mirror endpoints",
+			newCluster: clusterWithRegistries(mirrors(map[string][]string{
+				"docker.io": {"mirror.example.com", "ftp://mirror.example.com", "https://"},
+			})),
+			failedFields: []string{
+				"spec.rkeConfig.registries.mirrors[docker.io].endpoint[0]",
+				"spec.rkeConfig.registries.mirrors[docker.io].endpoint[1]",
+				"spec.rkeConfig.registries.mirrors[docker.io].endpoint[2]",
+			},
+		},
+		{
+			name: "duplicate hostnames",
+			newCluster: clusterWithRegistries(&rkev1.Registry{
+				Mirrors: mirrors(map[string][]string{"docker.io": nil, "Docker.io": nil}).Mirrors,
+				Configs: map[string]rkev1.RegistryConfig{
+					"https://registry.example.com/": {},
+					"registry.example.com":          {},
+				},
+			}),
+			failedFields: []string{
+				"spec.rkeConfig.registries.mirrors[docker.io]",
+				"spec.rkeConfig.registries.configs[registry.example.com]",
+			},
+		},
+		{
+			name: "missing secrets",
+			newCluster: clusterWithRegistries(&rkev1.Registry{
+				Configs: map[string]rkev1.RegistryConfig{
+					"registry.example.com": {AuthConfigSecretName: "missing-auth", TLSSecretName: "missing-tls"},
+				},
+			}),
+			failedFields: []string{
+				"spec.rkeConfig.registries.configs[registry.example.com].authConfigSecretName",
+				"spec.rkeConfig.registries.configs[registry.example.com].tlsSecretName",
+			},
+		},
+		{
+			name: "unchanged registries are not checked",
+			oldCluster: clusterWithRegistries(&rkev1.Registry{
+				Configs: map[string]rkev1.RegistryConfig{"registry.example.com": {AuthConfigSecretName: "missing-auth"}},
+			}),
+			newCluster: clusterWithRegistries(&rkev1.Registry{
+				Configs: map[string]rkev1.RegistryConfig{"registry.example.com": {AuthConfigSecretName: "missing-auth"}},
+			}),
+		},
+		{
+			name: "failed to get secret",
+			newCluster: clusterWithRegistries(&rkev1.Registry{
+				Configs: map[string]rkev1.RegistryConfig{"registry.example.com": {TLSSecretName: "error"}},
+			}),
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			secretCache := fake.NewMockCacheInterface[*k8sv1.Secret](gomock.NewController(t))
+			secretCache.EXPECT().Get("fleet-default", gomock.Any()).DoAndReturn(func(namespace, name string) (*k8sv1.Secret, error) {
+				switch name {
+				case "registry-auth", "registry-tls":
+					return &k8sv1.Secret{ObjectMeta: v12.ObjectMeta{Name: name, Namespace: namespace}}, nil
+				case "error":
+					return nil, fmt.Errorf("cache error")
+				}
+				return nil, apierrors.NewNotFound(schema.GroupResource{Resource: "secrets"}, name)
+			}).AnyTimes()
+			a := provisioningAdmitter{secretCache: secretCache}
+			oldCluster := tt.oldCluster
+			if oldCluster == nil {
+				oldCluster = &v1.Cluster{}
+			}
+			changes, err := diff.Objects(oldCluster, tt.newCluster)
+			assert.NoError(t, err)
+			fieldErrs, err := a.validateRegistries(changes, tt.newCluster)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			validateFailedPaths(tt.failedFields)(t, fieldErrs)
+		})
+	}
+}
+
+func TestValidateETCDSnapshotS3(t *testing.T) {
+	t.Parallel()
+
+	clusterWithS3 := func(s3 *rkev1.ETCDSnapshotS3, annotations map[string]string) *v1.Cluster {
+		return &v1.Cluster{
+			ObjectMeta: v12.ObjectMeta{Annotations: annotations},
+			Spec: v1.ClusterSpec{
+				RKEConfig: &v1.RKEConfig{
+					RKEClusterSpecCommon: rkev1.RKEClusterSpecCommon{
+						ETCD: &rkev1.ETCD{S3: s3},
+					},
+				},
+			},
+		}
+	}
+	allowInsecure := map[string]string{allowInsecureS3EndpointAnnotation: "true"}
+
+	tests := []struct {
+		name         string
+		oldCluster   *v1.Cluster
+		newCluster   *v1.Cluster
+		failedFields []string
+	}{
+		{
+			name:       "no rkeConfig",
+			newCluster: &v1.Cluster{},
+		},
+		{
+			name:       "no s3 configuration",
+			newCluster: clusterWithS3(nil, nil),
+		},
+		{
+			name: "valid aws configuration",
+			newCluster: clusterWithS3(&rkev1.ETCDSnapshotS3{
+				Endpoint: "s3.us-west-2.amazonaws.com",
+				Bucket:   "etcd-snapshots.example.com",
+				Region:   "us-west-2",
+			}, nil),
+		},
+		{
+			name: "valid https endpoint with port",
+			newCluster: clusterWithS3(&rkev1.ETCDSnapshotS3{
+				Endpoint: "https://minio.example.com:9000",
+				Bucket:   "snapshots",
+			}, nil),
+		},
+		{
+			name: "plain http endpoint",
+			newCluster: clusterWithS3(&rkev1.ETCDSnapshotS3{
+				Endpoint: "http://minio.example.com:9000",
+				Bucket:   "snapshots",
+			}, nil),
+			failedFields: []string{"spec.rkeConfig.etcd.s3.endpoint"},
+		},
+		{
+			name: "plain http endpoint with the allow-insecure annotation",
+			newCluster: clusterWithS3(&rkev1.ETCDSnapshotS3{
+				Endpoint: "http://minio.example.com:9000",
+				Bucket:   "snapshots",
+			}, allowInsecure),
+		},
+		{
+			name: "endpoint with a path",
+			newCluster: clusterWithS3(&rkev1.ETCDSnapshotS3{
+				Endpoint: "minio.example.com/snapshots",
+			}, nil),
+			failedFields: []string{"spec.rkeConfig.etcd.s3.endpoint"},
+		},
+		{
+			name: "endpoint with an unsupported scheme",
+			newCluster: clusterWithS3(&rkev1.ETCDSnapshotS3{
+				Endpoint: "ftp://minio.example.com",
+			}, nil),
+			failedFields: []string{"spec.rkeConfig.etcd.s3.endpoint"},
+		},
+		{
+			name: "region inconsistent with the endpoint",
+			newCluster: clusterWithS3(&rkev1.ETCDSnapshotS3{
+				Endpoint: "s3.us-west-2.amazonaws.com",
+				Region:   "eu-central-1",
+			}, nil),
+			failedFields: []string{"spec.rkeConfig.etcd.s3.region"},
+		},
+		{
+			name: "invalid region",
+			newCluster: clusterWithS3(&rkev1.ETCDSnapshotS3{
+				Region: "US_EAST_1",
+			}, nil),
+			failedFields: []string{"spec.rkeConfig.etcd.s3.region"},
+		},
+		{
+			name: "bucket with upper case characters",
+			newCluster: clusterWithS3(&rkev1.ETCDSnapshotS3{
+				Bucket: "Snapshots",
+			}, nil),
+			failedFields: []string{"spec.rkeConfig.etcd.s3.bucket"},
+		},
+		{
+			name: "bucket formatted as an ip address",
+			newCluster: clusterWithS3(&rkev1.ETCDSnapshotS3{
+				Bucket: "192.168.1.1",
+			}, nil),
+			failedFields: []string{"spec.rkeConfig.etcd.s3.bucket"},
+		},
+		{
+			name: "bucket with adjacent periods",
+			newCluster: clusterWithS3(&rkev1.ETCDSnapshotS3{
+				Bucket: "etcd..snapshots",
+			}, nil),
+			failedFields: []string{"spec.rkeConfig.etcd.s3.bucket"},
+		},
+		{
+			name: "bucket with a reserved prefix",
+			newCluster: clusterWithS3(&rkev1.ETCDSnapshotS3{
+				Bucket: "xn--snapshots",
+			}, nil),
+			failedFields: []string{"spec.rkeConfig.etcd.s3.bucket"},
+		},
+		{
+			name: "multiple errors",
+			newCluster: clusterWithS3(&rkev1.ETCDSnapshotS3{
+				Endpoint: "http://minio.example.com",
+				Bucket:   "ab",
+			}, nil),
+			failedFields: []string{"spec.rkeConfig.etcd.s3.endpoint", "spec.rkeConfig.etcd.s3.bucket"},
+		},
+		{
+			name:       "unchanged invalid configuration",
+			oldCluster: clusterWithS3(&rkev1.ETCDSnapshotS3{Endpoint: "http://minio.example.com"}, nil),
+			newCluster: clusterWithS3(&rkev1.ETCDSnapshotS3{Endpoint: "http://minio.example.com"}, nil),
+		},
+		{
+			name:         "removing the allow-insecure annotation",
+			oldCluster:   clusterWithS3(&rkev1.ETCDSnapshotS3{Endpoint: "http://minio.example.com"}, allowInsecure),
+			newCluster:   clusterWithS3(&rkev1.ETCDSnapshotS3{Endpoint: "http://minio.example.com"}, nil),
+			failedFields: []string{"spec.rkeConfig.etcd.s3.endpoint"},
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			oldCluster := tt.oldCluster
+			if oldCluster == nil {
+				oldCluster = &v1.Cluster{}
+			}
+			changes, err := diff.Objects(oldCluster, tt.newCluster)
+			assert.NoError(t, err)
+			validateFailedPaths(tt.failedFields)(t, validateETCDSnapshotS3(changes, tt.newCluster))
+		})
+	}
+}
+
+func TestValidateTenantPolicy(t *testing.T) {
+	t.Parallel()
+
+	const namespace = "fleet-tenant"
+	strictPolicy := &webhookv1.TenantPolicy{
+		ObjectMeta: v12.ObjectMeta{Name: "strict", Namespace: namespace},
+		Spec: webhookv1.TenantPolicySpec{
+			RequirePodSecurityAdmissionConfigurationTemplate:  true,
+			AllowedPodSecurityAdmissionConfigurationTemplates: []string{"rancher-restricted", "tenant"},
+			ForbidInsecureS3Endpoints:                         true,
+		},
+	}
+	cluster := func(psact string, annotations map[string]string) *v1.Cluster {
+		return &v1.Cluster{
+			ObjectMeta: v12.ObjectMeta{Name: "c", Namespace: namespace, Annotations: annotations},
+			Spec: v1.ClusterSpec{
+				RKEConfig: &v1.RKEConfig{},
+				DefaultPodSecurityAdmissionConfigurationTemplateName: psact,
+			},
+		}
+	}
+	allowInsecure := map[string]string{allowInsecureS3EndpointAnnotation: "true"}
+
+	tests := []struct {
+		name         string
+		policies     []*webhookv1.TenantPolicy
+		oldCluster   *v1.Cluster
+		newCluster   *v1.Cluster
+		failedFields []string
+	}{
+		{
+			name:       "no policies",
+			newCluster: cluster("", allowInsecure),
+		},
+		{
+			name:       "allowed template",
+			policies:   []*webhookv1.TenantPolicy{strictPolicy},
+			newCluster: cluster("tenant", nil),
+		},
+		{
+			name:         "missing template",
+			policies:     []*webhookv1.TenantPolicy{strictPolicy},
+			newCluster:   cluster("", nil),
+			failedFields: []string{"spec.defaultPodSecurityAdmissionConfigurationTemplateName"},
+		},
+		{
+			name:     "missing template of an imported cluster",
+			policies: []*webhookv1.TenantPolicy{strictPolicy},
+			newCluster: &v1.Cluster{
+				ObjectMeta: v12.ObjectMeta{Name: "c", Namespace: namespace},
+			},
+		},
+		{
+			name:         "template not allowed",
+			policies:     []*webhookv1.TenantPolicy{strictPolicy},
+			newCluster:   cluster("rancher-privileged", nil),
+			failedFields: []string{"spec.defaultPodSecurityAdmissionConfigurationTemplateName"},
+		},
+		{
+			name:       "unchanged template not allowed",
+			policies:   []*webhookv1.TenantPolicy{strictPolicy},
+			oldCluster: cluster("rancher-privileged", nil),
+			newCluster: cluster("rancher-privileged", map[string]string{"foo": "bar"}),
+		},
+		{
+			name:         "insecure s3 endpoints allowed",
+			policies:     []*webhookv1.TenantPolicy{strictPolicy},
+			newCluster:   cluster("tenant", allowInsecure),
+			failedFields: []string{"metadata.annotations[provisioning.cattle.io/allow-insecure-s3-endpoint]"},
+		},
+		{
+			name:       "insecure s3 endpoints allowed before the policy",
+			policies:   []*webhookv1.TenantPolicy{strictPolicy},
+			oldCluster: cluster("tenant", allowInsecure),
+			newCluster: cluster("tenant", allowInsecure),
+		},
+		{
+			name: "policies without common templates",
+			policies: []*webhookv1.TenantPolicy{
+				strictPolicy,
+				{
+					ObjectMeta: v12.ObjectMeta{Name: "other", Namespace: namespace},
+					Spec:       webhookv1.TenantPolicySpec{AllowedPodSecurityAdmissionConfigurationTemplates: []string{"other"}},
+				},
+			},
+			newCluster:   cluster("tenant", nil),
+			failedFields: []string{"spec.defaultPodSecurityAdmissionConfigurationTemplateName"},
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			ctrl := gomock.NewController(t)
+			policyCache := fake.NewMockCacheInterface[*webhookv1.TenantPolicy](ctrl)
+			policyCache.EXPECT().List(namespace, gomock.Any()).Return(tt.policies, nil).AnyTimes()
+			admitter := provisioningAdmitter{tenantPolicies: tenantpolicy.NewResolver(policyCache)}
+
+			oldCluster := tt.oldCluster
+			if oldCluster == nil {
+				oldCluster = &v1.Cluster{}
+			}
+			changes, err := diff.Objects(oldCluster, tt.newCluster)
+			assert.NoError(t, err)
+			fieldErrs, err := admitter.validateTenantPolicy(changes, tt.newCluster)
+			assert.NoError(t, err)
+			validateFailedPaths(tt.failedFields)(t, fieldErrs)
+		})
+	}
+}
+
+func TestValidateWindowsMachinePools(t *testing.T) {
+	t.Parallel()
+
+	clusterWithPools := func(version, cni string, pools ...v1.RKEMachinePool) *v1.Cluster {
+		cluster := &v1.Cluster{
+			Spec: v1.ClusterSpec{
+				KubernetesVersion: version,
+				RKEConfig: &v1.RKEConfig{
+					MachinePools: pools,
+				},
+			},
+		}
+		if cni != "" {
+			cluster.Spec.RKEConfig.MachineGlobalConfig = rkev1.GenericMap{Data: map[string]any{"cni": cni}}
+		}
+		return cluster
+	}
+	linuxPool := v1.RKEMachinePool{Name: "linux", EtcdRole: true, ControlPlaneRole: true, WorkerRole: true}
+	windowsPool := v1.RKEMachinePool{Name: "windows", MachineOS: "windows", WorkerRole: true}
+
+	tests := []struct {
+		name         string
+		oldCluster   *v1.Cluster
+		newCluster   *v1.Cluster
+		failedFields []string
+	}{
+		{
+			name:       "no rkeConfig",
+			newCluster: &v1.Cluster{},
+		},
+		{
+			name:       "linux pools only",
+			newCluster: clusterWithPools("v1.30.4+k3s1", "", linuxPool),
+		},
+		{
+			name:       "windows pool on rke2 with calico",
+			newCluster: clusterWithPools("v1.30.4+rke2r1", "calico", linuxPool, windowsPool),
+		},
+		{
+			name:       "windows pool on rke2 with flannel and multus",
+			newCluster: clusterWithPools("v1.30.4+rke2r1", "multus,flannel", linuxPool, windowsPool),
+		},
+		{
+			name:         "windows pool on k3s",
+			newCluster:   clusterWithPools("v1.30.4+k3s1", "calico", linuxPool, windowsPool),
+			failedFields: []string{"spec.kubernetesVersion"},
+		},
+		{
+			name:         "windows pool below the version floor",
+			newCluster:   clusterWithPools("v1.21.14+rke2r1", "calico", linuxPool, windowsPool),
+			failedFields: []string{"spec.kubernetesVersion"},
+		},
+		{
+			name:         "windows pool with the default cni",
+			newCluster:   clusterWithPools("v1.30.4+rke2r1", "", linuxPool, windowsPool),
+			failedFields: []string{"spec.rkeConfig.machineGlobalConfig.cni"},
+		},
+		{
+			name:         "windows pool with cilium",
+			newCluster:   clusterWithPools("v1.30.4+rke2r1", "cilium", linuxPool, windowsPool),
+			failedFields: []string{"spec.rkeConfig.machineGlobalConfig.cni"},
+		},
+		{
+			name: "windows pool with the etcd and control plane roles",
+			newCluster: clusterWithPools("v1.30.4+rke2r1", "calico", linuxPool,
+				v1.RKEMachinePool{Name: "windows", MachineOS: "windows", EtcdRole: true, ControlPlaneRole: true, WorkerRole: true}),
+			failedFields: []string{"spec.rkeConfig.machinePools[1].etcdRole", "spec.rkeConfig.machinePools[1].controlPlaneRole"},
+		},
+		{
+			name:       "unchanged invalid configuration",
+			oldCluster: clusterWithPools("v1.30.4+rke2r1", "", linuxPool, windowsPool),
+			newCluster: clusterWithPools("v1.30.4+rke2r1", "", linuxPool, windowsPool),
+		},
+		{
+			name:         "adding a windows pool to a cluster with an unsupported cni",
+			oldCluster:   clusterWithPools("v1.30.4+rke2r1", "", linuxPool),
+			newCluster:   clusterWithPools("v1.30.4+rke2r1", "", linuxPool, windowsPool),
+			failedFields: []string{"spec.rkeConfig.machineGlobalConfig.cni"},
+		},
+		{
+			name:         "changing the cni of a cluster with a windows pool",
+			oldCluster:   clusterWithPools("v1.30.4+rke2r1", "calico", linuxPool, windowsPool),
+			newCluster:   clusterWithPools("v1.30.4+rke2r1", "canal", linuxPool, windowsPool),
+			failedFields: []string{"spec.rkeConfig.machineGlobalConfig.cni"},
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			oldCluster := tt.oldCluster
+			if oldCluster == nil {
+				oldCluster = &v1.Cluster{}
+			}
+			validateFailedPaths(tt.failedFields)(t, validateWindowsMachinePools(oldCluster, tt.newCluster))
+		})
+	}
+}
+
+func TestValidateMinKubernetesVersion(t *testing.T) {
+	t.Parallel()
+
+	rkeCluster := func(version string) *v1.Cluster {
+		return &v1.Cluster{Spec: v1.ClusterSpec{KubernetesVersion: version, RKEConfig: &v1.RKEConfig{}}}
+	}
+	tests := []struct {
+		name         string
+		minVersion   string
+		settingErr   error
+		oldCluster   *v1.Cluster
+		newCluster   *v1.Cluster
+		failedFields []string
+		wantErr      bool
+	}{
+		{
+			name:       "no minimum version",
+			newCluster: rkeCluster("v1.25.16+rke2r1"),
+		},
+		{
+			name:       "setting not found",
+			settingErr: apierrors.NewNotFound(schema.GroupResource{}, ""),
+			newCluster: rkeCluster("v1.25.16+rke2r1"),
+		},
+		{
+			name:       "setting error",
+			settingErr: fmt.Errorf("unexpected error"),
+			newCluster: rkeCluster("v1.25.16+rke2r1"),
+			wantErr:    true,
+		},
+		{
+			name:       "rke2 version above the minimum",
+			minVersion: "v1.28",
+			newCluster: rkeCluster("v1.28.3+rke2r1"),
+		},
+		{
+			name:       "k3s version equal to the minimum",
+			minVersion: "v1.28.3+k3s1",
+			newCluster: rkeCluster("v1.28.3+k3s2"),
+		},
+		{
+			name:         "create below the minimum",
+			minVersion:   "v1.28",
+			newCluster:   rkeCluster("v1.27.9+k3s1"),
+			failedFields: []string{"spec.kubernetesVersion"},
+		},
+		{
+			name:         "release candidate of the minimum",
+			minVersion:   "v1.28.0",
+			newCluster:   rkeCluster("v1.28.0-rc1+rke2r1"),
+			failedFields: []string{"spec.kubernetesVersion"},
+		},
+		{
+			name:         "upgrade below the minimum",
+			minVersion:   "v1.28",
+			oldCluster:   rkeCluster("v1.26.15+rke2r1"),
+			newCluster:   rkeCluster("v1.27.9+rke2r1"),
+			failedFields: []string{"spec.kubernetesVersion"},
+		},
+		{
+			name:       "unchanged version below the minimum",
+			minVersion: "v1.28",
+			oldCluster: rkeCluster("v1.26.15+rke2r1"),
+			newCluster: rkeCluster("v1.26.15+rke2r1"),
+		},
+		{
+			name:         "invalid version",
+			minVersion:   "v1.28",
+			newCluster:   rkeCluster("latest"),
+			failedFields: []string{"spec.kubernetesVersion"},
+		},
+		{
+			name:       "invalid minimum version",
+			minVersion: "latest",
+			newCluster: rkeCluster("v1.25.16+rke2r1"),
+		},
+		{
+			name:       "imported cluster",
+			minVersion: "v1.28",
+			newCluster: &v1.Cluster{Spec: v1.ClusterSpec{KubernetesVersion: "v1.25.16+rke2r1"}},
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			settingCache := fake.NewMockNonNamespacedCacheInterface[*v3.Setting](gomock.NewController(t))
+			settingCache.EXPECT().Get(common.MinKubernetesVersionSetting).Return(&v3.Setting{Value: tt.minVersion}, tt.settingErr).AnyTimes()
+			a := provisioningAdmitter{settingCache: settingCache}
+			oldCluster := tt.oldCluster
+			if oldCluster == nil {
+				oldCluster = &v1.Cluster{}
+			}
+			fieldErrs, err := a.validateMinKubernetesVersion(oldCluster, tt.newCluster)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			validateFailedPaths(tt.failedFields)(t, fieldErrs)
+		})
+	}
+}
+
+func TestValidateAgentEnvVars(t *testing.T) {
+	t.Parallel()
+
+	clusterWithEnvVars := func(names ...string) *v1.Cluster {
+		cluster := &v1.Cluster{}
+		for _, name := range names {
+			cluster.Spec.AgentEnvVars = append(cluster.Spec.AgentEnvVars, rkev1.EnvVar{Name: name, Value: "value"})
+		}
+		return cluster
+	}
+
+	tests := []struct {
+		name         string
+		oldCluster   *v1.Cluster
+		newCluster   *v1.Cluster
+		failedFields []string
+	}{
+		{
+			name:       "no env vars",
+			newCluster: clusterWithEnvVars(),
+		},
+		{
+			name:       "allowed env vars",
+			newCluster: clusterWithEnvVars("HTTP_PROXY", "CATTLE_AGENT_LOGLEVEL"),
+		},
+		{
+			name:         "denied env vars",
+			newCluster:   clusterWithEnvVars("HTTP_PROXY", "HTTPS_PROXY_", "CATTLE_SERVER"),
+			failedFields: []string{"spec.agentEnvVars[1].name", "spec.agentEnvVars[2].name"},
+		},
+		{
+			name:         "denied env var added on update",
+			oldCluster:   clusterWithEnvVars("CATTLE_SERVER"),
+			newCluster:   clusterWithEnvVars("CATTLE_SERVER", "CATTLE_CA_CHECKSUM"),
+			failedFields: []string{"spec.agentEnvVars[1].name"},
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			settingCache := fake.NewMockNonNamespacedCacheInterface[*v3.Setting](gomock.NewController(t))
+			settingCache.EXPECT().Get(common.AgentEnvVarsDenyListSetting).Return(&v3.Setting{Value: "HTTPS_PROXY_,CATTLE_*"}, nil).AnyTimes()
+			settingCache.EXPECT().Get(common.AgentEnvVarsAllowListSetting).Return(&v3.Setting{Value: "CATTLE_AGENT_LOGLEVEL"}, nil).AnyTimes()
+			a := provisioningAdmitter{settingCache: settingCache}
+			oldCluster := tt.oldCluster
+			if oldCluster == nil {
+				oldCluster = &v1.Cluster{}
+			}
+			fieldErrs, err := a.validateAgentEnvVars(oldCluster, tt.newCluster)
+			assert.NoError(t, err)
+			validateFailedPaths(tt.failedFields)(t, fieldErrs)
+		})
+	}
+}
+
+func TestValidateMachineConfigRefs(t *testing.T) {
+	t.Parallel()
+
+	clusterWithPools := func(refs map[string]string) *v1.Cluster {
+		cluster := &v1.Cluster{
+			ObjectMeta: v12.ObjectMeta{Name: "cluster", Namespace: "fleet-default"},
+			Spec:       v1.ClusterSpec{RKEConfig: &v1.RKEConfig{}},
+		}
+		for _, name := range slices.Sorted(maps.Keys(refs)) {
+			cluster.Spec.RKEConfig.MachinePools = append(cluster.Spec.RKEConfig.MachinePools, v1.RKEMachinePool{
+				Name:       name,
+				NodeConfig: &k8sv1.ObjectReference{Kind: "Amazonec2Config", Name: refs[name]},
+			})
+		}
+		return cluster
+	}
+
+	tests := []struct {
+		name         string
+		oldCluster   *v1.Cluster
+		newCluster   *v1.Cluster
+		failedFields []string
+		wantErr      bool
+	}{
+		{
+			name:       "no rkeConfig",
+			newCluster: &v1.Cluster{},
+		},
+		{
+			name:       "existing machine configs",
+			newCluster: clusterWithPools(map[string]string{"pool-1": "config-1", "pool-2": "config-2"}),
+		},
+		{
+			name:         "missing machine config",
+			newCluster:   clusterWithPools(map[string]string{"pool-1": "config-1", "pool-2": "missing"}),
+			failedFields: []string{"spec.rkeConfig.machinePools[1].machineConfigRef"},
+		},
+		{
+			name:       "machine config not in the cache yet",
+			newCluster: clusterWithPools(map[string]string{"pool-1": "config-1", "pool-2": "config-3"}),
+		},
+		{
+			name:       "existing pools are not checked on update",
+			oldCluster: clusterWithPools(map[string]string{"pool-1": "missing"}),
+			newCluster: clusterWithPools(map[string]string{"pool-1": "missing", "pool-2": "config-2"}),
+		},
+		{
+			name:         "missing machine config of added pool",
+			oldCluster:   clusterWithPools(map[string]string{"pool-1": "config-1"}),
+			newCluster:   clusterWithPools(map[string]string{"pool-1": "config-1", "pool-2": "missing"}),
+			failedFields: []string{"spec.rkeConfig.machinePools[1].machineConfigRef"},
+		},
+		{
+			name:       "failed to get machine config",
+			newCluster: clusterWithPools(map[string]string{"pool-1": "error"}),
+			wantErr:    true,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			a := provisioningAdmitter{
+				dynamic:     machineConfigGetter{"fleet-default/config-1", "fleet-default/config-2"},
+				liveDynamic: machineConfigGetter{"fleet-default/config-1", "fleet-default/config-2", "fleet-default/config-3"},
+			}
+			oldCluster := tt.oldCluster
+			if oldCluster == nil {
+				oldCluster = &v1.Cluster{}
+			}
+			fieldErrs, err := a.validateMachineConfigRefs(oldCluster, tt.newCluster)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			validateFailedPaths(tt.failedFields)(t, fieldErrs)
+		})
+	}
+}
+
+// machineConfigGetter gets the Amazonec2Configs with the given namespaced names.
+type machineConfigGetter []string
+
+func (m machineConfigGetter) Get(gvk schema.GroupVersionKind, namespace, name string) (runtime.Object, error) {
+	if gvk != (schema.GroupVersionKind{Group: "rke-machine-config.cattle.io", Version: "v1", Kind: "Amazonec2Config"}) {
+		return nil, &meta.NoKindMatchError{GroupKind: gvk.GroupKind()}
+	}
+	if name == "error" {
+		return nil, fmt.Errorf("cache error")
+	}
+	if !slices.Contains(m, namespace+"/"+name) {
+		return nil, apierrors.NewNotFound(schema.GroupResource{Group: gvk.Group, Resource: gvk.Kind}, name)
+	}
+	return &unstructured.Unstructured{}, nil
+}
+
+func TestValidateMachinePoolLabelsAndTaints(t *testing.T) {
+	t.Parallel()
+
+	clusterWithPools := func(pools ...v1.RKEMachinePool) *v1.Cluster {
+		return &v1.Cluster{Spec: v1.ClusterSpec{RKEConfig: &v1.RKEConfig{MachinePools: pools}}}
+	}
+	pool := func(labels, deploymentLabels map[string]string, taints ...k8sv1.Taint) v1.RKEMachinePool {
+		return v1.RKEMachinePool{
+			Name:                    "pool",
+			RKECommonNodeConfig:     rkev1.RKECommonNodeConfig{Labels: labels, Taints: taints},
+			MachineDeploymentLabels: deploymentLabels,
+		}
+	}
+	validTaint := k8sv1.Taint{Key: "node-role.kubernetes.io/etcd", Value: "true", Effect: k8sv1.TaintEffectNoExecute}
+
+	tests := []struct {
+		name         string
+		oldCluster   *v1.Cluster
+		newCluster   *v1.Cluster
+		failedFields []string
+	}{
+		{
+			name:       "no rkeConfig",
+			newCluster: &v1.Cluster{},
+		},
+		{
+			name:       "valid labels and taints",
+			newCluster: clusterWithPools(pool(map[string]string{"cattle.io/os": "linux"}, map[string]string{"team": "a"}, validTaint)),
+		},
+		{
+			name:         "invalid label key",
+			newCluster:   clusterWithPools(pool(map[string]string{"invalid key": "value"}, nil)),
+			failedFields: []string{"spec.rkeConfig.machinePools[0].labels"},
+		},
+		{
+			name:         "invalid label value",
+			newCluster:   clusterWithPools(pool(map[string]string{"key": "invalid value"}, nil)),
+			failedFields: []string{"spec.rkeConfig.machinePools[0].labels"},
+		},
+		{
+			name:         "invalid machineDeploymentLabels",
+			newCluster:   clusterWithPools(pool(nil, map[string]string{"-key": "value"})),
+			failedFields: []string{"spec.rkeConfig.machinePools[0].machineDeploymentLabels"},
+		},
+		{
+			name: "invalid taints",
+			newCluster: clusterWithPools(pool(nil, nil,
+				k8sv1.Taint{Key: "invalid/key/name", Value: "value", Effect: k8sv1.TaintEffectNoSchedule},
+				k8sv1.Taint{Key: "key", Value: "invalid value", Effect: "Sometimes"},
+				validTaint,
+				validTaint,
+			)),
+			failedFields: []string{
+				"spec.rkeConfig.machinePools[0].taints[0].key",
+				"spec.rkeConfig.machinePools[0].taints[1].value",
+				"spec.rkeConfig.machinePools[0].taints[1].effect",
+				"spec.rkeConfig.machinePools[0].taints[3]",
+			},
+		},
+		{
+			name:       "unchanged invalid pool",
+			oldCluster: clusterWithPools(pool(map[string]string{"invalid key": "value"}, nil)),
+			newCluster: clusterWithPools(pool(map[string]string{"invalid key": "value"}, nil)),
+		},
+		{
+			name:         "changed taints of a pool with invalid labels",
+			oldCluster:   clusterWithPools(pool(map[string]string{"invalid key": "value"}, nil)),
+			newCluster:   clusterWithPools(pool(map[string]string{"invalid key": "value"}, nil, validTaint)),
+			failedFields: []string{"spec.rkeConfig.machinePools[0].labels"},
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			oldCluster := tt.oldCluster
+			if oldCluster == nil {
+				oldCluster = &v1.Cluster{}
+			}
+			validateFailedPaths(tt.failedFields)(t, validateMachinePoolLabelsAndTaints(oldCluster, tt.newCluster))
+		})
+	}
+}
+
+func TestValidateMachinePoolRollingUpdates(t *testing.T) {
+	t.Parallel()
+
+	clusterWithPool := func(maxUnavailable, maxSurge *intstr.IntOrString) *v1.Cluster {
+		return &v1.Cluster{Spec: v1.ClusterSpec{RKEConfig: &v1.RKEConfig{MachinePools: []v1.RKEMachinePool{{
+			Name:          "pool",
+			RollingUpdate: &v1.RKEMachinePoolRollingUpdate{MaxUnavailable: maxUnavailable, MaxSurge: maxSurge},
+		}}}}}
+	}
+	intOrString := func(value string) *intstr.IntOrString {
+		v := intstr.Parse(value)
+		return &v
+	}
+
+	tests := []struct {
+		name         string
+		oldCluster   *v1.Cluster
+		newCluster   *v1.Cluster
+		failedFields []string
+	}{
+		{
+			name:       "no rollingUpdate",
+			newCluster: &v1.Cluster{Spec: v1.ClusterSpec{RKEConfig: &v1.RKEConfig{MachinePools: []v1.RKEMachinePool{{Name: "pool"}}}}},
+		},
+		{
+			name:       "unset values",
+			newCluster: clusterWithPool(nil, nil),
+		},
+		{
+			name:       "valid values",
+			newCluster: clusterWithPool(intOrString("1"), intOrString("25%")),
+		},
+		{
+			name:       "zero maxSurge",
+			newCluster: clusterWithPool(intOrString("10%"), intOrString("0")),
+		},
+		{
+			name:         "both zero",
+			newCluster:   clusterWithPool(intOrString("0%"), intOrString("0")),
+			failedFields: []string{"spec.rkeConfig.machinePools[0].rollingUpdate.maxUnavailable"},
+		},
+		{
+			name:         "zero maxSurge and unset maxUnavailable",
+			newCluster:   clusterWithPool(nil, intOrString("0")),
+			failedFields: []string{"spec.rkeConfig.machinePools[0].rollingUpdate.maxUnavailable"},
+		},
+		{
+			name:         "negative value",
+			newCluster:   clusterWithPool(intOrString("-1"), nil),
+			failedFields: []string{"spec.rkeConfig.machinePools[0].rollingUpdate.maxUnavailable"},
+		},
+		{
+			name:       "bad percentages",
+			newCluster: clusterWithPool(intOrString("110%"), intOrString("ten%")),
+			failedFields: []string{
+				"spec.rkeConfig.machinePools[0].rollingUpdate.maxUnavailable",
+				"spec.rkeConfig.machinePools[0].rollingUpdate.maxSurge",
+			},
+		},
+		{
+			name:         "not a percentage",
+			newCluster:   clusterWithPool(nil, intOrString("one")),
+			failedFields: []string{"spec.rkeConfig.machinePools[0].rollingUpdate.maxSurge"},
+		},
+		{
+			name:       "unchanged invalid pool",
+			oldCluster: clusterWithPool(intOrString("0"), intOrString("0")),
+			newCluster: clusterWithPool(intOrString("0"), intOrString("0")),
+		},
+		{
+			name:         "changed invalid pool",
+			oldCluster:   clusterWithPool(intOrString("0"), intOrString("1")),
+			newCluster:   clusterWithPool(intOrString("0"), intOrString("0")),
+			failedFields: []string{"spec.rkeConfig.machinePools[0].rollingUpdate.maxUnavailable"},
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			oldCluster := tt.oldCluster
+			if oldCluster == nil {
+				oldCluster = &v1.Cluster{}
+			}
+			fieldErrs, err := validateMachinePoolRollingUpdates(oldCluster, tt.newCluster)
+			require.NoError(t, err)
+			validateFailedPaths(tt.failedFields)(t, fieldErrs)
+		})
+	}
+}
+
+func TestValidatePSACTDowngrade(t *testing.T) {
+	t.Parallel()
+	const psaUser = "psa-user"
+	tests := []struct {
+		name        string
+		operation   admissionv1.Operation
+		username    string
+		groups      []string
+		oldTemplate string
+		newTemplate string
+		allowed     bool
+	}{
+		{name: "create", operation: admissionv1.Create, username: "user", newTemplate: "rancher-privileged", allowed: true},
+		{name: "unchanged", operation: admissionv1.Update, username: "user", oldTemplate: "rancher-restricted", newTemplate: "rancher-restricted", allowed: true},
+		{name: "upgrade", operation: admissionv1.Update, username: "user", oldTemplate: "rancher-privileged", newTemplate: "rancher-restricted", allowed: true},
+		{name: "set template", operation: admissionv1.Update, username: "user", newTemplate: "rancher-baseline", allowed: true},
+		{name: "downgrade without verb", operation: admissionv1.Update, username: "user", oldTemplate: "rancher-restricted", newTemplate: "rancher-privileged"},
+		{name: "partial downgrade without verb", operation: admissionv1.Update, username: "user", oldTemplate: "rancher-restricted", newTemplate: "rancher-baseline"},
+		{name: "unset template without verb", operation: admissionv1.Update, username: "user", oldTemplate: "rancher-baseline"},
+		{name: "downgrade with verb", operation: admissionv1.Update, username: psaUser, oldTemplate: "rancher-restricted", newTemplate: "rancher-privileged", allowed: true},
+		{
+			name:        "downgrade by controller",
+			operation:   admissionv1.Update,
+			username:    "system:serviceaccount:cattle-system:rancher",
+			groups:      []string{"system:serviceaccounts:cattle-system"},
+			oldTemplate: "rancher-restricted",
+			newTemplate: "rancher-privileged",
+			allowed:     true,
+		},
+		{name: "replace deleted template", operation: admissionv1.Update, username: "user", oldTemplate: "deleted", newTemplate: "rancher-privileged", allowed: true},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			psactCache := fake.NewMockNonNamespacedCacheInterface[*v3.PodSecurityAdmissionConfigurationTemplate](gomock.NewController(t))
+			psactCache.EXPECT().Get(gomock.Any()).DoAndReturn(func(name string) (*v3.PodSecurityAdmissionConfigurationTemplate, error) {
+				level, ok := map[string]string{"rancher-privileged": "privileged", "rancher-baseline": "baseline", "rancher-restricted": "restricted"}[name]
+				if !ok {
+					return nil, apierrors.NewNotFound(schema.GroupResource{}, name)
+				}
+				return &v3.PodSecurityAdmissionConfigurationTemplate{
+					ObjectMeta:    v12.ObjectMeta{Name: name},
+					Configuration: v3.PodSecurityAdmissionConfigurationTemplateSpec{Defaults: v3.PodSecurityAdmissionConfigurationTemplateDefaults{Enforce: level}},
+				}, nil
+			}).AnyTimes()
+			k8Fake := &k8testing.Fake{}
+			k8Fake.AddReactor("create", "subjectaccessreviews", func(action k8testing.Action) (bool, runtime.Object, error) {
+				review := action.(k8testing.CreateActionImpl).GetObject().(*authv1.SubjectAccessReview)
+				assert.Equal(t, "c-1", review.Spec.ResourceAttributes.Name)
+				review.Status.Allowed = review.Spec.User == psaUser && review.Spec.ResourceAttributes.Verb == common.UpdatePSAVerb
+				return true, review, nil
+			})
+			a := provisioningAdmitter{
+				psactCache: psactCache,
+				sar:        &k8fake.FakeSubjectAccessReviews{Fake: &k8fake.FakeAuthorizationV1{Fake: k8Fake}},
+			}
+			request := &admission.Request{
+				AdmissionRequest: admissionv1.AdmissionRequest{
+					Operation: tt.operation,
+					UserInfo:  authenticationv1.UserInfo{Username: tt.username, Groups: tt.groups},
+				},
+				Context: context.Background(),
+			}
+			oldCluster := &v1.Cluster{ObjectMeta: v12.ObjectMeta{Name: "c-1", Namespace: "fleet-default"}}
+			oldCluster.Spec.DefaultPodSecurityAdmissionConfigurationTemplateName = tt.oldTemplate
+			newCluster := &v1.Cluster{ObjectMeta: v12.ObjectMeta{Name: "c-1", Namespace: "fleet-default"}}
+			newCluster.Spec.DefaultPodSecurityAdmissionConfigurationTemplateName = tt.newTemplate
+
+			response := &admissionv1.AdmissionResponse{}
+			err := a.validatePSACTDowngrade(request, response, oldCluster, newCluster)
+			assert.NoError(t, err)
+			assert.Equal(t, tt.allowed, response.Result == nil, response.Result)
+		})
+	}
+}
+
+func TestAdmitLocalClusterDeletion(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name        string
+		username    string
+		wantAllowed bool
+	}{
+		{
+			name:     "deleted by a user",
+			username: "test-user",
+		},
+		{
+			name:     "deleted by another service account",
+			username: "system:serviceaccount:cattle-system:rancher",
+		},
+		{
+			name:        "deleted by the uninstall service account",
+			username:    "system:serviceaccount:cattle-system:rancher-uninstall",
+			wantAllowed: true,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			raw, err := json.Marshal(&v1.Cluster{ObjectMeta: v12.ObjectMeta{Name: localCluster, Namespace: "fleet-local"}})
+			require.NoError(t, err)
+			request := &admission.Request{AdmissionRequest: admissionv1.AdmissionRequest{
+				Operation: admissionv1.Delete,
+				Name:      localCluster,
+				OldObject: runtime.RawExtension{Raw: raw},
+				UserInfo:  authenticationv1.UserInfo{Username: tt.username},
+			}, Context: context.Background()}
+			validator := NewValidator(nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, false,
+				common.UninstallServiceAccount{Namespace: "cattle-system", Name: "rancher-uninstall"})
+
+			response, err := validator.admitter.Admit(request)
+			require.NoError(t, err)
+			assert.Equal(t, tt.wantAllowed, response.Allowed)
+		})
+	}
+}
+
+func TestValidateSchedulingCustomization(t *testing.T) {
+	t.Parallel()
+	withPriorityClass := func(priorityClass string) []byte {
+		return []byte(`{"spec":{"clusterAgentDeploymentCustomization":{"schedulingCustomization":{"priorityClass":` + priorityClass + `}}}}`)
+	}
+	valid := withPriorityClass(`{"value":1000}`)
+	invalid := withPriorityClass(`{"value":2000000000}`)
+	tests := []struct {
+		name      string
+		oldObject []byte
+		newObject []byte
+		wantErr   bool
+	}{
+		{name: "no priority class", newObject: []byte(`{"spec":{}}`)},
+		{name: "create valid", newObject: valid},
+		{name: "create invalid", newObject: invalid, wantErr: true},
+		{name: "change to invalid", oldObject: valid, newObject: invalid, wantErr: true},
+		{name: "unchanged invalid", oldObject: invalid, newObject: invalid},
+		{name: "change from invalid to valid", oldObject: invalid, newObject: valid},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			fieldErrs, err := validateSchedulingCustomization(tt.oldObject, tt.newObject)
+			require.NoError(t, err)
+			assert.Equal(t, tt.wantErr, len(fieldErrs) > 0, fieldErrs)
+		})
+	}
+}
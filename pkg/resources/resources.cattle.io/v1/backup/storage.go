@@ -0,0 +1,66 @@
+package backup
+
+import (
+	"fmt"
+
+	corev1controller "github.com/rancher/wrangler/v3/pkg/generated/controllers/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+)
+
+const (
+	// OperatorNamespace is the namespace of the rancher-backup operator, which holds the encryption configuration
+	// secrets of backups and restores.
+	OperatorNamespace = "cattle-resources-system"
+	// encryptionConfigKey is the key of the encryption configuration in the secrets named by
+	// encryptionConfigSecretName.
+	encryptionConfigKey = "encryption-provider-config.yaml"
+)
+
+// ValidateStorageLocation checks that the bucket of the storage location is set and that its credentials secret
+// exists. A nil storage location, which stands for the operator's default storage location, is valid.
+func ValidateStorageLocation(secretCache corev1controller.SecretCache, location *StorageLocation, fieldPath *field.Path) (field.ErrorList, error) {
+	if location == nil || location.S3 == nil {
+		return nil, nil
+	}
+	s3 := location.S3
+	s3Path := fieldPath.Child("s3")
+	var fieldErrs field.ErrorList
+	if s3.BucketName == "" {
+		fieldErrs = append(fieldErrs, field.Required(s3Path.Child("bucketName"), "bucket of the S3 storage location must be specified"))
+	}
+	// S3 credentials are optional, e.g. when they're provided by an IAM role.
+	if s3.CredentialSecretName == "" {
+		return fieldErrs, nil
+	}
+	if s3.CredentialSecretNamespace == "" {
+		return append(fieldErrs, field.Required(s3Path.Child("credentialSecretNamespace"), "namespace of the credential secret must be specified")), nil
+	}
+	_, err := secretCache.Get(s3.CredentialSecretNamespace, s3.CredentialSecretName)
+	if apierrors.IsNotFound(err) {
+		return append(fieldErrs, field.NotFound(s3Path.Child("credentialSecretName"), fmt.Sprintf("%s/%s", s3.CredentialSecretNamespace, s3.CredentialSecretName))), nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get credential secret %s/%s: %w", s3.CredentialSecretNamespace, s3.CredentialSecretName, err)
+	}
+	return fieldErrs, nil
+}
+
+// ValidateEncryptionConfigSecret checks that the encryption configuration secret with the given name exists in the
+// operator's namespace and holds an encryption configuration. An empty name, for unencrypted backups, is valid.
+func ValidateEncryptionConfigSecret(secretCache corev1controller.SecretCache, name string, fieldPath *field.Path) (*field.Error, error) {
+	if name == "" {
+		return nil, nil
+	}
+	secret, err := secretCache.Get(OperatorNamespace, name)
+	if apierrors.IsNotFound(err) {
+		return field.NotFound(fieldPath, fmt.Sprintf("%s/%s", OperatorNamespace, name)), nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get encryption config secret %s/%s: %w", OperatorNamespace, name, err)
+	}
+	if len(secret.Data[encryptionConfigKey]) == 0 {
+		return field.Invalid(fieldPath, name, fmt.Sprintf("secret %s/%s doesn't have an encryption configuration under the key %s", OperatorNamespace, name, encryptionConfigKey)), nil
+	}
+	return nil, nil
+}
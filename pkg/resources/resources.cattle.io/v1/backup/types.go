@@ -0,0 +1,51 @@
+package backup
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// The types below mirror the resources.cattle.io/v1 API of the rancher-backup operator, which isn't a dependency of the
+// webhook. They only hold the fields the validators check.
+
+// Backup is a one-time or recurring backup of Rancher's resources.
+type Backup struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   Spec   `json:"spec"`
+	Status Status `json:"status,omitempty"`
+}
+
+// Spec is the spec of a Backup.
+type Spec struct {
+	// StorageLocation is where the backup files are stored. The operator's default storage location is used if nil.
+	StorageLocation *StorageLocation `json:"storageLocation,omitempty"`
+	// EncryptionConfigSecretName is the name of the secret of the operator's namespace holding the encryption
+	// configuration of the backup files.
+	EncryptionConfigSecretName string `json:"encryptionConfigSecretName,omitempty"`
+	// Schedule is the cron schedule of a recurring backup. The backup is one-time if it is empty.
+	Schedule string `json:"schedule,omitempty"`
+	// RetentionCount is the number of backup files of a recurring backup which are kept.
+	RetentionCount int64 `json:"retentionCount,omitempty"`
+}
+
+// Status is the status of a Backup.
+type Status struct {
+	// Filename is the name of the last backup file.
+	Filename string `json:"filename,omitempty"`
+}
+
+// StorageLocation is where backup files are stored.
+type StorageLocation struct {
+	S3 *S3ObjectStore `json:"s3,omitempty"`
+}
+
+// S3ObjectStore is an S3 compatible bucket storing backup files.
+type S3ObjectStore struct {
+	Endpoint                  string `json:"endpoint,omitempty"`
+	CredentialSecretName      string `json:"credentialSecretName,omitempty"`
+	CredentialSecretNamespace string `json:"credentialSecretNamespace,omitempty"`
+	BucketName                string `json:"bucketName,omitempty"`
+	Region                    string `json:"region,omitempty"`
+	Folder                    string `json:"folder,omitempty"`
+}
@@ -0,0 +1,137 @@
+// Package backup is used for validating the backups of the rancher-backup operator.
+package backup
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+
+	"github.com/rancher/webhook/pkg/admission"
+	corev1controller "github.com/rancher/wrangler/v3/pkg/generated/controllers/core/v1"
+	"github.com/robfig/cron"
+	admissionv1 "k8s.io/api/admission/v1"
+	admissionregistrationv1 "k8s.io/api/admissionregistration/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	"k8s.io/utils/trace"
+)
+
+// GVK is the GroupVersionKind of backups.
+var GVK = schema.GroupVersionKind{
+	Group:   "resources.cattle.io",
+	Version: "v1",
+	Kind:    "Backup",
+}
+
+var gvr = schema.GroupVersionResource{
+	Group:    "resources.cattle.io",
+	Version:  "v1",
+	Resource: "backups",
+}
+
+// Validator validates backups.
+type Validator struct {
+	admitter admitter
+}
+
+// NewValidator returns a new Validator for backups.
+func NewValidator(secretCache corev1controller.SecretCache) *Validator {
+	return &Validator{
+		admitter: admitter{
+			secretCache: secretCache,
+		},
+	}
+}
+
+// GVR returns the GroupVersionResource for this CRD.
+func (v *Validator) GVR() schema.GroupVersionResource {
+	return gvr
+}
+
+// Operations returns list of operations handled by this validator.
+func (v *Validator) Operations() []admissionregistrationv1.OperationType {
+	return []admissionregistrationv1.OperationType{admissionregistrationv1.Create, admissionregistrationv1.Update}
+}
+
+// ValidatingWebhook returns the ValidatingWebhook used for this CRD. The rancher-backup operator is optional, and its
+// backups are still checked by the operator, so requests are allowed if the webhook fails.
+func (v *Validator) ValidatingWebhook(clientConfig admissionregistrationv1.WebhookClientConfig) []admissionregistrationv1.ValidatingWebhook {
+	valWebhook := admission.NewDefaultValidatingWebhook(v, clientConfig, admissionregistrationv1.ClusterScope, v.Operations())
+	valWebhook.FailurePolicy = admission.Ptr(admissionregistrationv1.Ignore)
+	return []admissionregistrationv1.ValidatingWebhook{*valWebhook}
+}
+
+// Admitters returns the admitter objects used to validate backups.
+func (v *Validator) Admitters() []admission.Admitter {
+	return []admission.Admitter{&v.admitter}
+}
+
+type admitter struct {
+	secretCache corev1controller.SecretCache
+}
+
+// Admit handles the webhook admission request sent to this webhook.
+func (a *admitter) Admit(request *admission.Request) (*admissionv1.AdmissionResponse, error) {
+	listTrace := trace.New("backupValidator Admit", trace.Field{Key: "user", Value: request.UserInfo.Username})
+	defer listTrace.LogIfLong(admission.SlowTraceDuration)
+
+	if request.Operation != admissionv1.Create && request.Operation != admissionv1.Update {
+		return nil, fmt.Errorf("%s operation %v: %w", gvr.Resource, request.Operation, admission.ErrUnsupportedOperation)
+	}
+	newBackup := &Backup{}
+	if err := json.Unmarshal(request.Object.Raw, newBackup); err != nil {
+		return nil, fmt.Errorf("failed to decode backup from request: %w", err)
+	}
+	var oldBackup *Backup
+	if request.Operation == admissionv1.Update {
+		oldBackup = &Backup{}
+		if err := json.Unmarshal(request.OldObject.Raw, oldBackup); err != nil {
+			return nil, fmt.Errorf("failed to decode old backup from request: %w", err)
+		}
+	}
+	// Backups which are being deleted are not checked so that finalizers can be removed.
+	if newBackup.DeletionTimestamp != nil {
+		return admission.ResponseAllowed(), nil
+	}
+
+	fieldErrs, err := a.validateSpec(oldBackup, newBackup)
+	if err != nil {
+		return nil, err
+	}
+	if len(fieldErrs) > 0 {
+		return admission.ResponseBadRequest(fieldErrs.ToAggregate().Error()), nil
+	}
+	return admission.ResponseAllowed(), nil
+}
+
+// validateSpec checks the spec of the backup. The secrets of the backup are only checked when they change, so that
+// backups whose secrets were deleted can still be updated. oldBackup is nil on create.
+func (a *admitter) validateSpec(oldBackup, newBackup *Backup) (field.ErrorList, error) {
+	specPath := field.NewPath("spec")
+	var fieldErrs field.ErrorList
+	if oldBackup == nil || !reflect.DeepEqual(oldBackup.Spec.StorageLocation, newBackup.Spec.StorageLocation) {
+		errs, err := ValidateStorageLocation(a.secretCache, newBackup.Spec.StorageLocation, specPath.Child("storageLocation"))
+		if err != nil {
+			return nil, err
+		}
+		fieldErrs = append(fieldErrs, errs...)
+	}
+	if oldBackup == nil || oldBackup.Spec.EncryptionConfigSecretName != newBackup.Spec.EncryptionConfigSecretName {
+		fieldErr, err := ValidateEncryptionConfigSecret(a.secretCache, newBackup.Spec.EncryptionConfigSecretName, specPath.Child("encryptionConfigSecretName"))
+		if err != nil {
+			return nil, err
+		}
+		if fieldErr != nil {
+			fieldErrs = append(fieldErrs, fieldErr)
+		}
+	}
+	if newBackup.Spec.Schedule != "" {
+		if _, err := cron.ParseStandard(newBackup.Spec.Schedule); err != nil {
+			fieldErrs = append(fieldErrs, field.Invalid(specPath.Child("schedule"), newBackup.Spec.Schedule, err.Error()))
+		}
+	}
+	if newBackup.Spec.RetentionCount < 0 {
+		fieldErrs = append(fieldErrs, field.Invalid(specPath.Child("retentionCount"), newBackup.Spec.RetentionCount, "must be 0 or more"))
+	}
+	return fieldErrs, nil
+}
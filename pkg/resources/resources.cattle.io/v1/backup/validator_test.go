@@ -0,0 +1,132 @@
+package backup
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/rancher/webhook/pkg/admission"
+	"github.com/rancher/wrangler/v3/pkg/generic/fake"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+	admissionv1 "k8s.io/api/admission/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+func TestAdmit(t *testing.T) {
+	t.Parallel()
+
+	s3 := &StorageLocation{S3: &S3ObjectStore{
+		BucketName:                "backups",
+		CredentialSecretName:      "s3-creds",
+		CredentialSecretNamespace: "default",
+	}}
+	tests := []struct {
+		name          string
+		oldBackup     *Backup
+		newBackup     *Backup
+		wantAllowed   bool
+		wantInMessage []string
+	}{
+		{
+			name:        "default storage location",
+			newBackup:   &Backup{Spec: Spec{Schedule: "@every 1h", RetentionCount: 10}},
+			wantAllowed: true,
+		},
+		{
+			name:        "existing secrets",
+			newBackup:   &Backup{Spec: Spec{StorageLocation: s3, EncryptionConfigSecretName: "encryption-config"}},
+			wantAllowed: true,
+		},
+		{
+			name: "missing secrets",
+			newBackup: &Backup{Spec: Spec{
+				StorageLocation:            &StorageLocation{S3: &S3ObjectStore{BucketName: "backups", CredentialSecretName: "missing", CredentialSecretNamespace: "default"}},
+				EncryptionConfigSecretName: "missing",
+			}},
+			wantInMessage: []string{"spec.storageLocation.s3.credentialSecretName", "spec.encryptionConfigSecretName"},
+		},
+		{
+			name:          "encryption secret without encryption config",
+			newBackup:     &Backup{Spec: Spec{EncryptionConfigSecretName: "empty"}},
+			wantInMessage: []string{"spec.encryptionConfigSecretName"},
+		},
+		{
+			name:          "S3 without bucket nor credential namespace",
+			newBackup:     &Backup{Spec: Spec{StorageLocation: &StorageLocation{S3: &S3ObjectStore{CredentialSecretName: "s3-creds"}}}},
+			wantInMessage: []string{"spec.storageLocation.s3.bucketName", "spec.storageLocation.s3.credentialSecretNamespace"},
+		},
+		{
+			name:          "invalid schedule",
+			newBackup:     &Backup{Spec: Spec{Schedule: "every hour"}},
+			wantInMessage: []string{"spec.schedule"},
+		},
+		{
+			name:          "negative retention count",
+			newBackup:     &Backup{Spec: Spec{Schedule: "0 * * * *", RetentionCount: -1}},
+			wantInMessage: []string{"spec.retentionCount"},
+		},
+		{
+			name:        "unchanged missing secret",
+			oldBackup:   &Backup{Spec: Spec{EncryptionConfigSecretName: "missing"}},
+			newBackup:   &Backup{Spec: Spec{EncryptionConfigSecretName: "missing", Schedule: "@daily"}},
+			wantAllowed: true,
+		},
+		{
+			name:          "changed to missing secret",
+			oldBackup:     &Backup{Spec: Spec{EncryptionConfigSecretName: "encryption-config"}},
+			newBackup:     &Backup{Spec: Spec{EncryptionConfigSecretName: "missing"}},
+			wantInMessage: []string{"spec.encryptionConfigSecretName"},
+		},
+		{
+			name:      "deleted backup",
+			oldBackup: &Backup{Spec: Spec{Schedule: "every hour"}},
+			newBackup: &Backup{
+				ObjectMeta: metav1.ObjectMeta{DeletionTimestamp: &metav1.Time{Time: time.Now()}},
+				Spec:       Spec{Schedule: "every hour"},
+			},
+			wantAllowed: true,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			ctrl := gomock.NewController(t)
+			secretCache := fake.NewMockCacheInterface[*corev1.Secret](ctrl)
+			secretCache.EXPECT().Get(gomock.Any(), gomock.Any()).DoAndReturn(func(namespace, name string) (*corev1.Secret, error) {
+				switch {
+				case namespace == "default" && name == "s3-creds":
+					return &corev1.Secret{}, nil
+				case namespace == OperatorNamespace && name == "encryption-config":
+					return &corev1.Secret{Data: map[string][]byte{encryptionConfigKey: []byte("kind: EncryptionConfiguration")}}, nil
+				case namespace == OperatorNamespace && name == "empty":
+					return &corev1.Secret{}, nil
+				}
+				return nil, apierrors.NewNotFound(corev1.Resource("secrets"), name)
+			}).AnyTimes()
+
+			request := &admission.Request{AdmissionRequest: admissionv1.AdmissionRequest{Operation: admissionv1.Create}}
+			raw, err := json.Marshal(tt.newBackup)
+			require.NoError(t, err)
+			request.Object = runtime.RawExtension{Raw: raw}
+			if tt.oldBackup != nil {
+				request.Operation = admissionv1.Update
+				raw, err := json.Marshal(tt.oldBackup)
+				require.NoError(t, err)
+				request.OldObject = runtime.RawExtension{Raw: raw}
+			}
+
+			response, err := NewValidator(secretCache).Admitters()[0].Admit(request)
+			require.NoError(t, err)
+			assert.Equal(t, tt.wantAllowed, response.Allowed)
+			for _, msg := range tt.wantInMessage {
+				assert.Contains(t, response.Result.Message, msg)
+			}
+		})
+	}
+}
@@ -0,0 +1,182 @@
+// Package restore is used for validating the restores of the rancher-backup operator.
+package restore
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"regexp"
+	"strings"
+
+	"github.com/rancher/webhook/pkg/admission"
+	"github.com/rancher/webhook/pkg/resources/resources.cattle.io/v1/backup"
+	corev1controller "github.com/rancher/wrangler/v3/pkg/generated/controllers/core/v1"
+	admissionv1 "k8s.io/api/admission/v1"
+	admissionregistrationv1 "k8s.io/api/admissionregistration/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	"k8s.io/utils/trace"
+)
+
+var gvr = schema.GroupVersionResource{
+	Group:    "resources.cattle.io",
+	Version:  "v1",
+	Resource: "restores",
+}
+
+const encryptedSuffix = ".enc"
+
+// backupFilenameRegex matches the names of the files of backups: the name of the backup, the UID of the kube-system
+// namespace of the cluster and the time of the backup, such as
+// "rancher-backup-430169aa-edde-4a61-85e8-858f625a755b-2024-03-05T10-12-01Z.tar.gz".
+var backupFilenameRegex = regexp.MustCompile(`^(.+)-[0-9a-f]{8}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{12}-\d{4}-\d{2}-\d{2}T\d{2}-\d{2}-\d{2}Z\.tar\.gz(\.enc)?$`)
+
+// Restore restores Rancher's resources from a backup file. It mirrors the resources.cattle.io/v1 API of the
+// rancher-backup operator, and only holds the fields the validator checks.
+type Restore struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec Spec `json:"spec"`
+}
+
+// Spec is the spec of a Restore.
+type Spec struct {
+	// BackupFilename is the name of the backup file to restore.
+	BackupFilename string `json:"backupFilename"`
+	// StorageLocation is where the backup file is stored. The operator's default storage location is used if nil.
+	StorageLocation *backup.StorageLocation `json:"storageLocation,omitempty"`
+	// EncryptionConfigSecretName is the name of the secret of the operator's namespace holding the encryption
+	// configuration the backup file was encrypted with.
+	EncryptionConfigSecretName string `json:"encryptionConfigSecretName,omitempty"`
+}
+
+// dynamicLister lists the objects of resources which the webhook doesn't have a typed cache for.
+type dynamicLister interface {
+	List(gvk schema.GroupVersionKind, namespace string, selector labels.Selector) ([]runtime.Object, error)
+}
+
+// Validator validates restores.
+type Validator struct {
+	admitter admitter
+}
+
+// NewValidator returns a new Validator for restores. Backups are listed with the dynamic lister.
+func NewValidator(secretCache corev1controller.SecretCache, dynamic dynamicLister) *Validator {
+	return &Validator{
+		admitter: admitter{
+			secretCache: secretCache,
+			dynamic:     dynamic,
+		},
+	}
+}
+
+// GVR returns the GroupVersionResource for this CRD.
+func (v *Validator) GVR() schema.GroupVersionResource {
+	return gvr
+}
+
+// Operations returns list of operations handled by this validator.
+func (v *Validator) Operations() []admissionregistrationv1.OperationType {
+	return []admissionregistrationv1.OperationType{admissionregistrationv1.Create}
+}
+
+// ValidatingWebhook returns the ValidatingWebhook used for this CRD. The rancher-backup operator is optional, and its
+// restores are still checked by the operator, so requests are allowed if the webhook fails.
+func (v *Validator) ValidatingWebhook(clientConfig admissionregistrationv1.WebhookClientConfig) []admissionregistrationv1.ValidatingWebhook {
+	valWebhook := admission.NewDefaultValidatingWebhook(v, clientConfig, admissionregistrationv1.ClusterScope, v.Operations())
+	valWebhook.FailurePolicy = admission.Ptr(admissionregistrationv1.Ignore)
+	return []admissionregistrationv1.ValidatingWebhook{*valWebhook}
+}
+
+// Admitters returns the admitter objects used to validate restores.
+func (v *Validator) Admitters() []admission.Admitter {
+	return []admission.Admitter{&v.admitter}
+}
+
+type admitter struct {
+	secretCache corev1controller.SecretCache
+	dynamic     dynamicLister
+}
+
+// Admit handles the webhook admission request sent to this webhook.
+func (a *admitter) Admit(request *admission.Request) (*admissionv1.AdmissionResponse, error) {
+	listTrace := trace.New("restoreValidator Admit", trace.Field{Key: "user", Value: request.UserInfo.Username})
+	defer listTrace.LogIfLong(admission.SlowTraceDuration)
+
+	if request.Operation != admissionv1.Create {
+		return nil, fmt.Errorf("%s operation %v: %w", gvr.Resource, request.Operation, admission.ErrUnsupportedOperation)
+	}
+	restore := &Restore{}
+	if err := json.Unmarshal(request.Object.Raw, restore); err != nil {
+		return nil, fmt.Errorf("failed to decode restore from request: %w", err)
+	}
+
+	specPath := field.NewPath("spec")
+	fieldErrs, err := backup.ValidateStorageLocation(a.secretCache, restore.Spec.StorageLocation, specPath.Child("storageLocation"))
+	if err != nil {
+		return nil, err
+	}
+	encryptionPath := specPath.Child("encryptionConfigSecretName")
+	fieldErr, err := backup.ValidateEncryptionConfigSecret(a.secretCache, restore.Spec.EncryptionConfigSecretName, encryptionPath)
+	if err != nil {
+		return nil, err
+	}
+	if fieldErr != nil {
+		fieldErrs = append(fieldErrs, fieldErr)
+	}
+	if strings.HasSuffix(restore.Spec.BackupFilename, encryptedSuffix) && restore.Spec.EncryptionConfigSecretName == "" {
+		fieldErrs = append(fieldErrs, field.Required(encryptionPath, fmt.Sprintf("backup file %s is encrypted", restore.Spec.BackupFilename)))
+	}
+	fieldErr, err = a.validateBackupFilename(restore)
+	if err != nil {
+		return nil, err
+	}
+	if fieldErr != nil {
+		fieldErrs = append(fieldErrs, fieldErr)
+	}
+
+	if len(fieldErrs) > 0 {
+		return admission.ResponseBadRequest(fieldErrs.ToAggregate().Error()), nil
+	}
+	return admission.ResponseAllowed(), nil
+}
+
+// validateBackupFilename checks that the backup file of the restore exists when it can be told from the backups: the
+// file was written by a one-time backup, which only ever writes the file recorded in its status, to the storage
+// location of the restore. The files of recurring backups, of deleted backups, and files which weren't named by the
+// operator can't be told apart from existing files, so they're allowed.
+func (a *admitter) validateBackupFilename(restore *Restore) (*field.Error, error) {
+	matches := backupFilenameRegex.FindStringSubmatch(restore.Spec.BackupFilename)
+	if matches == nil {
+		return nil, nil
+	}
+	backupName := matches[1]
+	objs, err := a.dynamic.List(backup.GVK, "", labels.Everything())
+	if err != nil {
+		return nil, fmt.Errorf("failed to list backups: %w", err)
+	}
+	for _, obj := range objs {
+		unstructuredObj, ok := obj.(runtime.Unstructured)
+		if !ok {
+			continue
+		}
+		b := &backup.Backup{}
+		if err := runtime.DefaultUnstructuredConverter.FromUnstructured(unstructuredObj.UnstructuredContent(), b); err != nil {
+			return nil, fmt.Errorf("failed to convert backup: %w", err)
+		}
+		if b.Name != backupName {
+			continue
+		}
+		if b.Spec.Schedule != "" || b.Status.Filename == "" || b.Status.Filename == restore.Spec.BackupFilename ||
+			!reflect.DeepEqual(b.Spec.StorageLocation, restore.Spec.StorageLocation) {
+			return nil, nil
+		}
+		return field.Invalid(field.NewPath("spec", "backupFilename"), restore.Spec.BackupFilename,
+			fmt.Sprintf("file doesn't exist: backup %s only wrote the file %s", b.Name, b.Status.Filename)), nil
+	}
+	return nil, nil
+}
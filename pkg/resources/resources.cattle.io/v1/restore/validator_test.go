@@ -0,0 +1,129 @@
+package restore
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/rancher/webhook/pkg/admission"
+	"github.com/rancher/webhook/pkg/resources/resources.cattle.io/v1/backup"
+	"github.com/rancher/wrangler/v3/pkg/generic/fake"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+	admissionv1 "k8s.io/api/admission/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+type mockLister struct {
+	objs []runtime.Object
+}
+
+func (m *mockLister) List(gvk schema.GroupVersionKind, _ string, _ labels.Selector) ([]runtime.Object, error) {
+	if gvk != backup.GVK {
+		return nil, nil
+	}
+	return m.objs, nil
+}
+
+func TestAdmit(t *testing.T) {
+	t.Parallel()
+
+	const (
+		oneTimeFile   = "one-time-430169aa-edde-4a61-85e8-858f625a755b-2024-03-05T10-12-01Z.tar.gz"
+		otherFile     = "one-time-430169aa-edde-4a61-85e8-858f625a755b-2024-03-04T10-12-01Z.tar.gz"
+		recurringFile = "recurring-430169aa-edde-4a61-85e8-858f625a755b-2024-03-04T10-12-01Z.tar.gz.enc"
+	)
+	s3 := &backup.StorageLocation{S3: &backup.S3ObjectStore{BucketName: "backups"}}
+	backups := []*backup.Backup{
+		{ObjectMeta: metav1.ObjectMeta{Name: "one-time"}, Status: backup.Status{Filename: oneTimeFile}},
+		{ObjectMeta: metav1.ObjectMeta{Name: "recurring"}, Spec: backup.Spec{Schedule: "@daily"}, Status: backup.Status{Filename: "latest.tar.gz.enc"}},
+	}
+
+	tests := []struct {
+		name          string
+		spec          Spec
+		wantAllowed   bool
+		wantInMessage []string
+	}{
+		{
+			name:        "file of a one-time backup",
+			spec:        Spec{BackupFilename: oneTimeFile},
+			wantAllowed: true,
+		},
+		{
+			name:          "missing file of a one-time backup",
+			spec:          Spec{BackupFilename: otherFile},
+			wantInMessage: []string{"spec.backupFilename", "only wrote the file " + oneTimeFile},
+		},
+		{
+			name:        "file in another storage location",
+			spec:        Spec{BackupFilename: otherFile, StorageLocation: s3},
+			wantAllowed: true,
+		},
+		{
+			name:        "file of a recurring backup",
+			spec:        Spec{BackupFilename: recurringFile, EncryptionConfigSecretName: "encryption-config"},
+			wantAllowed: true,
+		},
+		{
+			name:        "file of a deleted backup",
+			spec:        Spec{BackupFilename: "deleted-430169aa-edde-4a61-85e8-858f625a755b-2024-03-04T10-12-01Z.tar.gz"},
+			wantAllowed: true,
+		},
+		{
+			name:        "file not named by the operator",
+			spec:        Spec{BackupFilename: "migration.tar.gz"},
+			wantAllowed: true,
+		},
+		{
+			name:          "encrypted file without encryption config",
+			spec:          Spec{BackupFilename: recurringFile},
+			wantInMessage: []string{"spec.encryptionConfigSecretName: Required value"},
+		},
+		{
+			name:          "missing secrets",
+			spec:          Spec{BackupFilename: "migration.tar.gz.enc", EncryptionConfigSecretName: "missing", StorageLocation: &backup.StorageLocation{S3: &backup.S3ObjectStore{BucketName: "backups", CredentialSecretName: "missing", CredentialSecretNamespace: "default"}}},
+			wantInMessage: []string{"spec.storageLocation.s3.credentialSecretName", "spec.encryptionConfigSecretName: Not found"},
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			ctrl := gomock.NewController(t)
+			secretCache := fake.NewMockCacheInterface[*corev1.Secret](ctrl)
+			secretCache.EXPECT().Get(gomock.Any(), gomock.Any()).DoAndReturn(func(namespace, name string) (*corev1.Secret, error) {
+				if namespace == backup.OperatorNamespace && name == "encryption-config" {
+					return &corev1.Secret{Data: map[string][]byte{"encryption-provider-config.yaml": []byte("kind: EncryptionConfiguration")}}, nil
+				}
+				return nil, apierrors.NewNotFound(corev1.Resource("secrets"), name)
+			}).AnyTimes()
+			lister := &mockLister{}
+			for _, b := range backups {
+				content, err := runtime.DefaultUnstructuredConverter.ToUnstructured(b)
+				require.NoError(t, err)
+				lister.objs = append(lister.objs, &unstructured.Unstructured{Object: content})
+			}
+
+			raw, err := json.Marshal(&Restore{Spec: tt.spec})
+			require.NoError(t, err)
+			request := &admission.Request{AdmissionRequest: admissionv1.AdmissionRequest{
+				Operation: admissionv1.Create,
+				Object:    runtime.RawExtension{Raw: raw},
+			}}
+
+			response, err := NewValidator(secretCache, lister).Admitters()[0].Admit(request)
+			require.NoError(t, err)
+			assert.Equal(t, tt.wantAllowed, response.Allowed)
+			for _, msg := range tt.wantInMessage {
+				assert.Contains(t, response.Result.Message, msg)
+			}
+		})
+	}
+}
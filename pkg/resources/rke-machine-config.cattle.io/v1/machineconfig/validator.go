@@ -1,12 +1,18 @@
 package machineconfig
 
 import (
+	"maps"
+	"slices"
+
 	"github.com/rancher/webhook/pkg/admission"
 	v1 "github.com/rancher/webhook/pkg/generated/objects/core/v1"
 	"github.com/rancher/webhook/pkg/resources/common"
 	admissionv1 "k8s.io/api/admission/v1"
 	admissionregistrationv1 "k8s.io/api/admissionregistration/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/validation/field"
 	"k8s.io/utils/trace"
 )
 
@@ -16,6 +22,37 @@ var gvr = schema.GroupVersionResource{
 	Resource: "*",
 }
 
+// providerFields describes the fields checked for a kind of machine config. No field is required: the drivers fall back
+// to a default for each of the fields checked here, such as the region, when it is unset.
+type providerFields struct {
+	// immutable fields can not be changed once the machine config is created. An unset field is compared as its
+	// default, so that setting a field to its default isn't a change.
+	immutable map[string]string
+	// allowedValues restricts the values of a field, when it is set.
+	allowedValues map[string][]string
+}
+
+// providers holds the checks for each kind of machine config, along with the defaults of their drivers. Machine
+// configs of other kinds are not checked.
+var providers = map[string]providerFields{
+	"Amazonec2Config": {
+		immutable: map[string]string{"region": "us-east-1", "zone": "a"},
+		allowedValues: map[string][]string{
+			"httpEndpoint": {"enabled", "disabled"},
+			"httpTokens":   {"optional", "required"},
+		},
+	},
+	"AzureConfig": {
+		immutable: map[string]string{"location": "westus", "environment": "AzurePublicCloud"},
+	},
+	"DigitaloceanConfig": {
+		immutable: map[string]string{"region": "nyc3"},
+	},
+	"LinodeConfig": {
+		immutable: map[string]string{"region": "us-east"},
+	},
+}
+
 // Validator for validating machineconfigs.
 type Validator struct {
 	admitter admitter
@@ -35,7 +72,7 @@ func (v *Validator) GVR() schema.GroupVersionResource {
 
 // Operations returns list of operations handled by this validator.
 func (v *Validator) Operations() []admissionregistrationv1.OperationType {
-	return []admissionregistrationv1.OperationType{admissionregistrationv1.Update}
+	return []admissionregistrationv1.OperationType{admissionregistrationv1.Update, admissionregistrationv1.Create}
 }
 
 // ValidatingWebhook returns the ValidatingWebhook used for this CRD.
@@ -61,10 +98,69 @@ func (a *admitter) Admit(request *admission.Request) (*admissionv1.AdmissionResp
 	}
 
 	response := &admissionv1.AdmissionResponse{}
-	if response.Result = common.CheckCreatorID(request, oldUnstrConfig, unstrConfig); response.Result != nil {
-		return response, nil
+	if request.Operation == admissionv1.Update {
+		if response.Result = common.CheckCreatorID(request, oldUnstrConfig, unstrConfig); response.Result != nil {
+			return response, nil
+		}
+	}
+
+	// Machine configs which are being deleted are not checked so that finalizers can be removed.
+	if unstrConfig.GetDeletionTimestamp() == nil {
+		if fieldErrs := validateProviderFields(request.Operation, oldUnstrConfig, unstrConfig); len(fieldErrs) > 0 {
+			return admission.ResponseBadRequest(fieldErrs.ToAggregate().Error()), nil
+		}
 	}
 
 	response.Allowed = true
 	return response, nil
 }
+
+// validateProviderFields checks the fields of a machine config against the rules for its kind.
+func validateProviderFields(operation admissionv1.Operation, oldConfig, newConfig *unstructured.Unstructured) field.ErrorList {
+	provider, ok := providers[newConfig.GetKind()]
+	if !ok {
+		return nil
+	}
+	var fieldErrs field.ErrorList
+	for name, allowed := range provider.allowedValues {
+		value, found, _ := unstructured.NestedFieldNoCopy(newConfig.Object, name)
+		if !found || value == nil || value == "" {
+			continue
+		}
+		if !isAllowedValue(value, allowed) {
+			fieldErrs = append(fieldErrs, field.NotSupported(field.NewPath(name), value, allowed))
+		}
+	}
+	if operation == admissionv1.Update {
+		for _, name := range slices.Sorted(maps.Keys(provider.immutable)) {
+			oldValue := fieldOrDefault(oldConfig, name, provider.immutable[name])
+			newValue := fieldOrDefault(newConfig, name, provider.immutable[name])
+			if !equality.Semantic.DeepEqual(oldValue, newValue) {
+				fieldErrs = append(fieldErrs, field.Invalid(field.NewPath(name), newValue, "field is immutable"))
+			}
+		}
+	}
+	return fieldErrs
+}
+
+// fieldOrDefault returns the value of the field of the machine config, or the default of its driver if it's unset.
+func fieldOrDefault(config *unstructured.Unstructured, name, defaultValue string) any {
+	value, found, _ := unstructured.NestedFieldNoCopy(config.Object, name)
+	if !found || value == nil || value == "" {
+		return defaultValue
+	}
+	return value
+}
+
+func isAllowedValue(value any, allowed []string) bool {
+	str, ok := value.(string)
+	if !ok {
+		return false
+	}
+	for _, a := range allowed {
+		if str == a {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,139 @@
+package machineconfig_test
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/rancher/webhook/pkg/admission"
+	"github.com/rancher/webhook/pkg/resources/rke-machine-config.cattle.io/v1/machineconfig"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	admissionv1 "k8s.io/api/admission/v1"
+	authenticationv1 "k8s.io/api/authentication/v1"
+)
+
+const testUser = "test-user"
+
+func newConfig(kind string, fields map[string]any) map[string]any {
+	config := map[string]any{
+		"apiVersion": "rke-machine-config.cattle.io/v1",
+		"kind":       kind,
+		"metadata": map[string]any{
+			"name":        "nc-test",
+			"namespace":   "fleet-default",
+			"annotations": map[string]any{"field.cattle.io/creatorId": testUser},
+		},
+	}
+	for k, v := range fields {
+		config[k] = v
+	}
+	return config
+}
+
+func TestAdmit(t *testing.T) {
+	t.Parallel()
+	validEC2 := map[string]any{"region": "us-east-1", "instanceType": "t3.medium", "zone": "a"}
+
+	tests := []struct {
+		name    string
+		oldObj  map[string]any
+		newObj  map[string]any
+		allowed bool
+	}{
+		{
+			name:    "create valid amazonec2 config",
+			newObj:  newConfig("Amazonec2Config", validEC2),
+			allowed: true,
+		},
+		{
+			name:    "create amazonec2 config relying on the driver defaults",
+			newObj:  newConfig("Amazonec2Config", map[string]any{"instanceType": "t3.medium"}),
+			allowed: true,
+		},
+		{
+			name:    "create azure config relying on the driver defaults",
+			newObj:  newConfig("AzureConfig", nil),
+			allowed: true,
+		},
+		{
+			name:    "create amazonec2 config with IMDSv2 required",
+			newObj:  newConfig("Amazonec2Config", map[string]any{"region": "us-east-1", "instanceType": "t3.medium", "httpTokens": "required", "httpEndpoint": "enabled"}),
+			allowed: true,
+		},
+		{
+			name:   "create amazonec2 config with unsupported httpTokens",
+			newObj: newConfig("Amazonec2Config", map[string]any{"region": "us-east-1", "instanceType": "t3.medium", "httpTokens": "sometimes"}),
+		},
+		{
+			name:    "create config of an unknown provider",
+			newObj:  newConfig("VmwarevsphereConfig", map[string]any{"cpuCount": "2"}),
+			allowed: true,
+		},
+		{
+			name:    "update mutable field",
+			oldObj:  newConfig("Amazonec2Config", validEC2),
+			newObj:  newConfig("Amazonec2Config", map[string]any{"region": "us-east-1", "instanceType": "t3.large", "zone": "a"}),
+			allowed: true,
+		},
+		{
+			name:   "update region",
+			oldObj: newConfig("Amazonec2Config", validEC2),
+			newObj: newConfig("Amazonec2Config", map[string]any{"region": "us-west-2", "instanceType": "t3.medium", "zone": "a"}),
+		},
+		{
+			name:   "update linode region",
+			oldObj: newConfig("LinodeConfig", map[string]any{"region": "us-east", "instanceType": "g6-standard-2", "image": "linode/ubuntu22.04"}),
+			newObj: newConfig("LinodeConfig", map[string]any{"region": "eu-west", "instanceType": "g6-standard-2", "image": "linode/ubuntu22.04"}),
+		},
+		{
+			name:    "update setting a field to its default",
+			oldObj:  newConfig("AzureConfig", nil),
+			newObj:  newConfig("AzureConfig", map[string]any{"location": "westus"}),
+			allowed: true,
+		},
+		{
+			name:   "update removing an immutable field",
+			oldObj: newConfig("AzureConfig", map[string]any{"location": "eastus"}),
+			newObj: newConfig("AzureConfig", nil),
+		},
+		{
+			name:   "update config being deleted",
+			oldObj: newConfig("Amazonec2Config", validEC2),
+			newObj: func() map[string]any {
+				config := newConfig("Amazonec2Config", map[string]any{"instanceType": "t3.medium"})
+				config["metadata"].(map[string]any)["deletionTimestamp"] = "2024-01-01T00:00:00Z"
+				return config
+			}(),
+			allowed: true,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			request := &admission.Request{
+				AdmissionRequest: admissionv1.AdmissionRequest{
+					Operation: admissionv1.Create,
+					UserInfo:  authenticationv1.UserInfo{Username: testUser},
+				},
+				Context: context.Background(),
+			}
+			var err error
+			request.Object.Raw, err = json.Marshal(tt.newObj)
+			require.NoError(t, err)
+			if tt.oldObj != nil {
+				request.Operation = admissionv1.Update
+				request.OldObject.Raw, err = json.Marshal(tt.oldObj)
+				require.NoError(t, err)
+			}
+
+			admitters := machineconfig.NewValidator().Admitters()
+			require.Len(t, admitters, 1)
+			response, err := admitters[0].Admit(request)
+			require.NoError(t, err)
+			assert.Equalf(t, tt.allowed, response.Allowed, "unexpected response: %v", response.Result)
+		})
+	}
+}
@@ -0,0 +1,122 @@
+// Package etcdsnapshot is used for validating etcd snapshots of provisioning clusters.
+package etcdsnapshot
+
+import (
+	"fmt"
+
+	rkev1 "github.com/rancher/rancher/pkg/apis/rke.cattle.io/v1"
+	"github.com/rancher/webhook/pkg/admission"
+	provv1 "github.com/rancher/webhook/pkg/generated/controllers/provisioning.cattle.io/v1"
+	objectsv1 "github.com/rancher/webhook/pkg/generated/objects/rke.cattle.io/v1"
+	admissionv1 "k8s.io/api/admission/v1"
+	admissionregistrationv1 "k8s.io/api/admissionregistration/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	"k8s.io/utils/trace"
+)
+
+var gvr = schema.GroupVersionResource{
+	Group:    "rke.cattle.io",
+	Version:  "v1",
+	Resource: "etcdsnapshots",
+}
+
+// clusterNameLabel is set by Rancher on the snapshots of provisioning clusters.
+const clusterNameLabel = "rke.cattle.io/cluster-name"
+
+// NewValidator returns a new validator for etcd snapshots.
+func NewValidator(clusterCache provv1.ClusterCache) *Validator {
+	return &Validator{
+		admitter: admitter{
+			clusterCache: clusterCache,
+		},
+	}
+}
+
+// Validator validates etcd snapshots.
+type Validator struct {
+	admitter admitter
+}
+
+// GVR returns the GroupVersionKind for this CRD.
+func (v *Validator) GVR() schema.GroupVersionResource {
+	return gvr
+}
+
+// Operations returns list of operations handled by this validator.
+func (v *Validator) Operations() []admissionregistrationv1.OperationType {
+	return []admissionregistrationv1.OperationType{admissionregistrationv1.Update, admissionregistrationv1.Delete}
+}
+
+// ValidatingWebhook returns the ValidatingWebhook used for this CRD.
+func (v *Validator) ValidatingWebhook(clientConfig admissionregistrationv1.WebhookClientConfig) []admissionregistrationv1.ValidatingWebhook {
+	return []admissionregistrationv1.ValidatingWebhook{*admission.NewDefaultValidatingWebhook(v, clientConfig, admissionregistrationv1.NamespacedScope, v.Operations())}
+}
+
+// Admitters returns the admitter objects used to validate etcd snapshots.
+func (v *Validator) Admitters() []admission.Admitter {
+	return []admission.Admitter{&v.admitter}
+}
+
+type admitter struct {
+	clusterCache provv1.ClusterCache
+}
+
+// Admit handles the webhook admission request sent to this webhook.
+func (a *admitter) Admit(request *admission.Request) (*admissionv1.AdmissionResponse, error) {
+	listTrace := trace.New("etcdSnapshotValidator Admit", trace.Field{Key: "user", Value: request.UserInfo.Username})
+	defer listTrace.LogIfLong(admission.SlowTraceDuration)
+
+	oldSnapshot, newSnapshot, err := objectsv1.ETCDSnapshotOldAndNewFromRequest(&request.AdmissionRequest)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get old and new etcd snapshots from request: %w", err)
+	}
+
+	switch request.Operation {
+	case admissionv1.Update:
+		if admission.IsController(request) {
+			return admission.ResponseAllowed(), nil
+		}
+		if !equality.Semantic.DeepEqual(oldSnapshot.SnapshotFile, newSnapshot.SnapshotFile) {
+			return admission.ResponseBadRequest(field.Forbidden(field.NewPath("snapshotFile"),
+				"the snapshot file is recorded by Rancher and can only be changed by its controllers").Error()), nil
+		}
+	case admissionv1.Delete:
+		if admission.IsController(request) {
+			return admission.ResponseAllowed(), nil
+		}
+		return a.admitDelete(oldSnapshot)
+	}
+	return admission.ResponseAllowed(), nil
+}
+
+// admitDelete denies the deletion of a snapshot which its cluster is restoring, as the restore can't complete without
+// it. spec.rkeConfig.etcdSnapshotRestore stays set once the restore completes, so the restore is only considered in
+// progress until the cluster has observed its current generation and is ready again.
+func (a *admitter) admitDelete(snapshot *rkev1.ETCDSnapshot) (*admissionv1.AdmissionResponse, error) {
+	clusterName := snapshot.Spec.ClusterName
+	if clusterName == "" {
+		clusterName = snapshot.Labels[clusterNameLabel]
+	}
+	if clusterName == "" {
+		return admission.ResponseAllowed(), nil
+	}
+	cluster, err := a.clusterCache.Get(snapshot.Namespace, clusterName)
+	if apierrors.IsNotFound(err) {
+		return admission.ResponseAllowed(), nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get cluster %s/%s: %w", snapshot.Namespace, clusterName, err)
+	}
+	if cluster.Spec.RKEConfig == nil || cluster.Spec.RKEConfig.ETCDSnapshotRestore == nil ||
+		cluster.Spec.RKEConfig.ETCDSnapshotRestore.Name != snapshot.Name {
+		return admission.ResponseAllowed(), nil
+	}
+	if cluster.Status.ObservedGeneration >= cluster.Generation && cluster.Status.Ready {
+		return admission.ResponseAllowed(), nil
+	}
+	return admission.ResponseBadRequest(fmt.Sprintf("etcd snapshot %s is being restored by cluster %s/%s and can't be deleted until the restore completes",
+		snapshot.Name, snapshot.Namespace, clusterName)), nil
+}
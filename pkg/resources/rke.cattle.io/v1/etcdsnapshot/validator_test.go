@@ -0,0 +1,175 @@
+package etcdsnapshot
+
+import (
+	"encoding/json"
+	"testing"
+
+	provv1 "github.com/rancher/rancher/pkg/apis/provisioning.cattle.io/v1"
+	rkev1 "github.com/rancher/rancher/pkg/apis/rke.cattle.io/v1"
+	"github.com/rancher/webhook/pkg/admission"
+	"github.com/rancher/wrangler/v3/pkg/generic/fake"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+	admissionv1 "k8s.io/api/admission/v1"
+	authenticationv1 "k8s.io/api/authentication/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+func newSnapshot(name, clusterName, location string) *rkev1.ETCDSnapshot {
+	return &rkev1.ETCDSnapshot{
+		ObjectMeta:   metav1.ObjectMeta{Name: name, Namespace: "fleet-default"},
+		Spec:         rkev1.ETCDSnapshotSpec{ClusterName: clusterName},
+		SnapshotFile: rkev1.ETCDSnapshotFile{Name: name, Location: location},
+	}
+}
+
+func TestAdmit(t *testing.T) {
+	t.Parallel()
+
+	restoring := &provv1.Cluster{
+		ObjectMeta: metav1.ObjectMeta{Name: "restoring", Namespace: "fleet-default", Generation: 2},
+		Spec: provv1.ClusterSpec{
+			RKEConfig: &provv1.RKEConfig{
+				ETCDSnapshotRestore: &rkev1.ETCDSnapshotRestore{Name: "restoring-snapshot", Generation: 1},
+			},
+		},
+		Status: provv1.ClusterStatus{ObservedGeneration: 2},
+	}
+	pending := restoring.DeepCopy()
+	pending.Name = "pending"
+	pending.Status = provv1.ClusterStatus{ObservedGeneration: 1, Ready: true}
+	restored := restoring.DeepCopy()
+	restored.Name = "restored"
+	restored.Status = provv1.ClusterStatus{ObservedGeneration: 2, Ready: true}
+	idle := &provv1.Cluster{
+		ObjectMeta: metav1.ObjectMeta{Name: "idle", Namespace: "fleet-default"},
+		Spec:       provv1.ClusterSpec{RKEConfig: &provv1.RKEConfig{}},
+	}
+
+	tests := []struct {
+		name        string
+		operation   admissionv1.Operation
+		username    string
+		oldSnapshot *rkev1.ETCDSnapshot
+		newSnapshot *rkev1.ETCDSnapshot
+		wantAllowed bool
+	}{
+		{
+			name:        "delete snapshot being restored",
+			operation:   admissionv1.Delete,
+			oldSnapshot: newSnapshot("restoring-snapshot", "restoring", "file:///snapshots/a"),
+		},
+		{
+			name:      "delete snapshot being restored found by label",
+			operation: admissionv1.Delete,
+			oldSnapshot: func() *rkev1.ETCDSnapshot {
+				snapshot := newSnapshot("restoring-snapshot", "", "file:///snapshots/a")
+				snapshot.Labels = map[string]string{clusterNameLabel: "restoring"}
+				return snapshot
+			}(),
+		},
+		{
+			name:        "delete snapshot of a restore not observed yet",
+			operation:   admissionv1.Delete,
+			oldSnapshot: newSnapshot("restoring-snapshot", "pending", "file:///snapshots/a"),
+		},
+		{
+			name:        "delete snapshot of a completed restore",
+			operation:   admissionv1.Delete,
+			oldSnapshot: newSnapshot("restoring-snapshot", "restored", "file:///snapshots/a"),
+			wantAllowed: true,
+		},
+		{
+			name:        "delete snapshot being restored by a controller",
+			operation:   admissionv1.Delete,
+			username:    "system:kube-controller-manager",
+			oldSnapshot: newSnapshot("restoring-snapshot", "restoring", "file:///snapshots/a"),
+			wantAllowed: true,
+		},
+		{
+			name:        "delete other snapshot of a restoring cluster",
+			operation:   admissionv1.Delete,
+			oldSnapshot: newSnapshot("other-snapshot", "restoring", "file:///snapshots/b"),
+			wantAllowed: true,
+		},
+		{
+			name:        "delete snapshot of a cluster without restore",
+			operation:   admissionv1.Delete,
+			oldSnapshot: newSnapshot("restoring-snapshot", "idle", "file:///snapshots/a"),
+			wantAllowed: true,
+		},
+		{
+			name:        "delete snapshot of a missing cluster",
+			operation:   admissionv1.Delete,
+			oldSnapshot: newSnapshot("restoring-snapshot", "missing", "file:///snapshots/a"),
+			wantAllowed: true,
+		},
+		{
+			name:        "update snapshot file",
+			operation:   admissionv1.Update,
+			oldSnapshot: newSnapshot("snapshot", "idle", "file:///snapshots/a"),
+			newSnapshot: newSnapshot("snapshot", "idle", "s3://bucket/a"),
+		},
+		{
+			name:        "update snapshot file by a controller",
+			operation:   admissionv1.Update,
+			username:    "system:kube-controller-manager",
+			oldSnapshot: newSnapshot("snapshot", "idle", "file:///snapshots/a"),
+			newSnapshot: newSnapshot("snapshot", "idle", "s3://bucket/a"),
+			wantAllowed: true,
+		},
+		{
+			name:        "update labels",
+			operation:   admissionv1.Update,
+			oldSnapshot: newSnapshot("snapshot", "idle", "file:///snapshots/a"),
+			newSnapshot: func() *rkev1.ETCDSnapshot {
+				snapshot := newSnapshot("snapshot", "idle", "file:///snapshots/a")
+				snapshot.Labels = map[string]string{"team": "a"}
+				return snapshot
+			}(),
+			wantAllowed: true,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			clusterCache := fake.NewMockCacheInterface[*provv1.Cluster](gomock.NewController(t))
+			clusterCache.EXPECT().Get("fleet-default", gomock.Any()).DoAndReturn(func(_, name string) (*provv1.Cluster, error) {
+				for _, cluster := range []*provv1.Cluster{restoring, pending, restored, idle} {
+					if cluster.Name == name {
+						return cluster, nil
+					}
+				}
+				return nil, apierrors.NewNotFound(schema.GroupResource{}, name)
+			}).AnyTimes()
+			validator := NewValidator(clusterCache)
+
+			request := &admission.Request{
+				AdmissionRequest: admissionv1.AdmissionRequest{
+					Operation: tt.operation,
+					UserInfo:  authenticationv1.UserInfo{Username: tt.username},
+				},
+			}
+			if tt.oldSnapshot != nil {
+				raw, err := json.Marshal(tt.oldSnapshot)
+				require.NoError(t, err)
+				request.OldObject = runtime.RawExtension{Raw: raw}
+			}
+			if tt.newSnapshot != nil {
+				raw, err := json.Marshal(tt.newSnapshot)
+				require.NoError(t, err)
+				request.Object = runtime.RawExtension{Raw: raw}
+			}
+
+			response, err := validator.Admitters()[0].Admit(request)
+			require.NoError(t, err)
+			assert.Equal(t, tt.wantAllowed, response.Allowed)
+		})
+	}
+}
@@ -0,0 +1,46 @@
+package server
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/rancher/webhook/pkg/audit"
+	"github.com/rancher/webhook/pkg/metrics"
+)
+
+// auditMiddleware writes an audit record for each request of the given paths which the webhook denied.
+func auditMiddleware(logger *audit.Logger, pathPrefixes ...string) func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if !hasPrefix(r.URL.Path, pathPrefixes) {
+				next.ServeHTTP(w, r)
+				return
+			}
+			body, err := io.ReadAll(r.Body)
+			if err != nil {
+				http.Error(w, fmt.Sprintf("failed to read request: %v", err), http.StatusBadRequest)
+				return
+			}
+			r.Body = io.NopCloser(bytes.NewReader(body))
+			recorder := &responseRecorder{ResponseWriter: w}
+			next.ServeHTTP(recorder, r)
+
+			response := decodeReview(recorder.body.Bytes())
+			if response == nil || response.Response == nil || response.Response.Allowed {
+				return
+			}
+			review := decodeReview(body)
+			if review == nil || review.Request == nil {
+				return
+			}
+			webhookType := metrics.WebhookTypeValidating
+			if strings.HasPrefix(r.URL.Path, mutationPath) {
+				webhookType = metrics.WebhookTypeMutating
+			}
+			logger.Log(audit.NewRecord(webhookType, review.Request, response.Response))
+		})
+	}
+}
@@ -0,0 +1,84 @@
+package server
+
+import (
+	"bufio"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/rancher/webhook/pkg/audit"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	admissionv1 "k8s.io/api/admission/v1"
+	authenticationv1 "k8s.io/api/authentication/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	k8stypes "k8s.io/apimachinery/pkg/types"
+)
+
+func TestAuditMiddleware(t *testing.T) {
+	t.Parallel()
+	path := filepath.Join(t.TempDir(), "audit.log")
+	logger := audit.NewLogger(audit.Config{FilePath: path, MaxSizeMB: 1})
+	require.NotNil(t, logger)
+
+	handler := auditMiddleware(logger, validationPath, mutationPath)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		review := admissionv1.AdmissionReview{}
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&review))
+		review.Response = &admissionv1.AdmissionResponse{UID: review.Request.UID, Allowed: review.Request.UID == "allowed"}
+		if !review.Response.Allowed {
+			review.Response.Result = &metav1.Status{Code: http.StatusBadRequest, Reason: metav1.StatusReasonBadRequest, Message: "denied"}
+		}
+		review.Request = nil
+		require.NoError(t, json.NewEncoder(w).Encode(review))
+	}))
+
+	for _, request := range []struct{ path, uid string }{
+		{validationPath + "/globalroles", "allowed"},
+		{validationPath + "/globalroles", "denied"},
+		{mutationPath + "/secrets", "mutation-denied"},
+	} {
+		body, err := json.Marshal(admissionv1.AdmissionReview{
+			TypeMeta: metav1.TypeMeta{APIVersion: "admission.k8s.io/v1", Kind: "AdmissionReview"},
+			Request: &admissionv1.AdmissionRequest{
+				UID:       k8stypes.UID(request.uid),
+				Resource:  metav1.GroupVersionResource{Group: "management.cattle.io", Version: "v3", Resource: "globalroles"},
+				Name:      "test",
+				Operation: admissionv1.Update,
+				UserInfo:  authenticationv1.UserInfo{Username: "user"},
+				Object:    runtime.RawExtension{Raw: []byte(`{"rules":[{"verbs":["*"]}]}`)},
+				OldObject: runtime.RawExtension{Raw: []byte(`{"rules":[]}`)},
+			},
+		})
+		require.NoError(t, err)
+		recorder := httptest.NewRecorder()
+		handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodPost, request.path, strings.NewReader(string(body))))
+		require.Equal(t, http.StatusOK, recorder.Code)
+	}
+	require.NoError(t, logger.Close())
+
+	file, err := os.Open(path)
+	require.NoError(t, err)
+	defer file.Close()
+	var records []audit.Record
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		record := audit.Record{}
+		require.NoError(t, json.Unmarshal(scanner.Bytes(), &record))
+		records = append(records, record)
+	}
+	require.Len(t, records, 2)
+	assert.Equal(t, "denied", records[0].UID)
+	assert.Equal(t, "validating", records[0].WebhookType)
+	assert.Equal(t, "user", records[0].User)
+	assert.Equal(t, int32(http.StatusBadRequest), records[0].Code)
+	assert.Equal(t, "denied", records[0].Message)
+	assert.Equal(t, []string{"/rules"}, records[0].ChangedPaths)
+	assert.Equal(t, "mutation-denied", records[1].UID)
+	assert.Equal(t, "mutating", records[1].WebhookType)
+	assert.Equal(t, -1, audit.Verify(records))
+}
@@ -0,0 +1,75 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+	authenticationv1 "k8s.io/api/authentication/v1"
+	authorizationv1 "k8s.io/api/authorization/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	authenticationv1client "k8s.io/client-go/kubernetes/typed/authentication/v1"
+	authorizationv1client "k8s.io/client-go/kubernetes/typed/authorization/v1"
+)
+
+// requestAuthorizer authenticates the callers of an endpoint with the bearer token of their requests, which is verified
+// with a TokenReview, and authorizes them with a SubjectAccessReview.
+type requestAuthorizer struct {
+	tokenReviews authenticationv1client.TokenReviewInterface
+	sars         authorizationv1client.SubjectAccessReviewInterface
+}
+
+// handler returns a handler serving the requests of the callers allowed the attributes with next, and denying the
+// others.
+func (a *requestAuthorizer) handler(attributes authorizationv1.ResourceAttributes, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if code, err := a.authorize(r, attributes); err != nil {
+			http.Error(w, err.Error(), code)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// authorize returns an error and its HTTP status code if the caller isn't allowed the attributes.
+func (a *requestAuthorizer) authorize(r *http.Request, attributes authorizationv1.ResourceAttributes) (int, error) {
+	token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if !ok || token == "" {
+		return http.StatusUnauthorized, errors.New("a bearer token is required")
+	}
+	tokenReview, err := a.tokenReviews.Create(r.Context(), &authenticationv1.TokenReview{
+		Spec: authenticationv1.TokenReviewSpec{Token: token},
+	}, metav1.CreateOptions{})
+	if err != nil {
+		logrus.Errorf("[%s] failed to review token: %v", r.URL.Path, err)
+		return http.StatusInternalServerError, errors.New("failed to authenticate the request")
+	}
+	if !tokenReview.Status.Authenticated {
+		return http.StatusUnauthorized, errors.New("invalid bearer token")
+	}
+
+	caller := tokenReview.Status.User
+	extra := make(map[string]authorizationv1.ExtraValue, len(caller.Extra))
+	for key, value := range caller.Extra {
+		extra[key] = authorizationv1.ExtraValue(value)
+	}
+	sar, err := a.sars.Create(r.Context(), &authorizationv1.SubjectAccessReview{
+		Spec: authorizationv1.SubjectAccessReviewSpec{
+			ResourceAttributes: &attributes,
+			User:               caller.Username,
+			Groups:             caller.Groups,
+			UID:                caller.UID,
+			Extra:              extra,
+		},
+	}, metav1.CreateOptions{})
+	if err != nil {
+		logrus.Errorf("[%s] failed to authorize %s: %v", r.URL.Path, caller.Username, err)
+		return http.StatusInternalServerError, errors.New("failed to authorize the request")
+	}
+	if !sar.Status.Allowed {
+		return http.StatusForbidden, fmt.Errorf("user %s can't %s %s.%s", caller.Username, attributes.Verb, attributes.Resource, attributes.Group)
+	}
+	return 0, nil
+}
@@ -0,0 +1,111 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	authenticationv1 "k8s.io/api/authentication/v1"
+	authorizationv1 "k8s.io/api/authorization/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+	k8testing "k8s.io/client-go/testing"
+)
+
+// newFakeAuthorizer returns an authorizer authenticating the qa-token and dev-token tokens, and allowing qa on the
+// resources of the webhook.cattle.io group.
+func newFakeAuthorizer() *requestAuthorizer {
+	client := fake.NewSimpleClientset()
+	client.PrependReactor("create", "tokenreviews", func(action k8testing.Action) (bool, runtime.Object, error) {
+		review := action.(k8testing.CreateAction).GetObject().(*authenticationv1.TokenReview)
+		switch review.Spec.Token {
+		case "qa-token":
+			review.Status = authenticationv1.TokenReviewStatus{Authenticated: true, User: authenticationv1.UserInfo{Username: "qa"}}
+		case "dev-token":
+			review.Status = authenticationv1.TokenReviewStatus{Authenticated: true, User: authenticationv1.UserInfo{Username: "dev"}}
+		}
+		return true, review, nil
+	})
+	client.PrependReactor("create", "subjectaccessreviews", func(action k8testing.Action) (bool, runtime.Object, error) {
+		review := action.(k8testing.CreateAction).GetObject().(*authorizationv1.SubjectAccessReview)
+		attributes := review.Spec.ResourceAttributes
+		review.Status.Allowed = review.Spec.User == "qa" && attributes.Group == "webhook.cattle.io"
+		return true, review, nil
+	})
+	return &requestAuthorizer{
+		tokenReviews: client.AuthenticationV1().TokenReviews(),
+		sars:         client.AuthorizationV1().SubjectAccessReviews(),
+	}
+}
+
+func TestRequestAuthorizer(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name          string
+		attributes    authorizationv1.ResourceAttributes
+		authorization string
+		wantCode      int
+		wantInBody    string
+	}{
+		{
+			name:          "allowed to simulate RoleTemplates",
+			attributes:    roleTemplateSimulationAttributes,
+			authorization: "Bearer qa-token",
+			wantCode:      http.StatusOK,
+		},
+		{
+			name:       "no token",
+			attributes: roleTemplateSimulationAttributes,
+			wantCode:   http.StatusUnauthorized,
+			wantInBody: "a bearer token is required",
+		},
+		{
+			name:          "invalid token",
+			attributes:    roleTemplateSimulationAttributes,
+			authorization: "Bearer unknown",
+			wantCode:      http.StatusUnauthorized,
+			wantInBody:    "invalid bearer token",
+		},
+		{
+			name:          "not allowed to simulate RoleTemplates",
+			attributes:    roleTemplateSimulationAttributes,
+			authorization: "Bearer dev-token",
+			wantCode:      http.StatusForbidden,
+			wantInBody:    "user dev can't create roletemplatesimulations.webhook.cattle.io",
+		},
+		{
+			name:          "allowed to list webhooks",
+			attributes:    webhooksAttributes,
+			authorization: "Bearer qa-token",
+			wantCode:      http.StatusOK,
+		},
+		{
+			name:          "not allowed to list webhooks",
+			attributes:    webhooksAttributes,
+			authorization: "Bearer dev-token",
+			wantCode:      http.StatusForbidden,
+			wantInBody:    "user dev can't get webhooks.webhook.cattle.io",
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			served := false
+			handler := newFakeAuthorizer().handler(tt.attributes, http.HandlerFunc(func(http.ResponseWriter, *http.Request) {
+				served = true
+			}))
+			request := httptest.NewRequest(http.MethodPost, "/", nil)
+			if tt.authorization != "" {
+				request.Header.Set("Authorization", tt.authorization)
+			}
+			recorder := httptest.NewRecorder()
+			handler.ServeHTTP(recorder, request)
+			assert.Equal(t, tt.wantCode, recorder.Code)
+			assert.Contains(t, recorder.Body.String(), tt.wantInBody)
+			assert.Equal(t, tt.wantCode == http.StatusOK, served)
+		})
+	}
+}
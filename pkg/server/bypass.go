@@ -0,0 +1,146 @@
+package server
+
+import (
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+
+	"github.com/rancher/webhook/pkg/admission"
+	"github.com/rancher/webhook/pkg/metrics"
+	"github.com/sirupsen/logrus"
+	admissionv1 "k8s.io/api/admission/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+const (
+	// BypassGroupEnv is the environment variable setting the Kubernetes group whose members bypass the validators of
+	// the resources in BypassResourcesEnv, such as "rancher-webhook:bypass".
+	BypassGroupEnv = "CATTLE_WEBHOOK_BYPASS_GROUP"
+	// BypassResourcesEnv is the environment variable setting the comma separated list of resources, formatted as
+	// "resource.group", whose validators can be bypassed.
+	BypassResourcesEnv = "CATTLE_WEBHOOK_BYPASS_RESOURCES"
+)
+
+// bypassableResources are the only resources whose validators can be bypassed. The validators of the other resources
+// guard privileges, credentials or objects Rancher depends on, such as the RBAC resources, namespaces, secrets, clusters
+// and nodes, and are never bypassed.
+var bypassableResources = map[string]bool{
+	"settings.management.cattle.io":                                   true,
+	"features.management.cattle.io":                                   true,
+	"clusterproxyconfigs.management.cattle.io":                        true,
+	"nodedrivers.management.cattle.io":                                true,
+	"podsecurityadmissionconfigurationtemplates.management.cattle.io": true,
+	"clusterrepos.catalog.cattle.io":                                  true,
+	"etcdsnapshots.rke.cattle.io":                                     true,
+	"machines.cluster.x-k8s.io":                                       true,
+	"backups.resources.cattle.io":                                     true,
+	"restores.resources.cattle.io":                                    true,
+}
+
+// BypassConfig configures which validators can be bypassed, and by whom, in break-glass scenarios.
+type BypassConfig struct {
+	// Group is the Kubernetes group whose members bypass the validators. Bypassing is disabled if empty.
+	Group string
+	// Resources are the resources, formatted as "resource.group", whose validators can be bypassed.
+	Resources []string
+}
+
+// BypassConfigFromEnv returns the BypassConfig set in the environment.
+func BypassConfigFromEnv() (BypassConfig, error) {
+	config := BypassConfig{Group: os.Getenv(BypassGroupEnv)}
+	value := os.Getenv(BypassResourcesEnv)
+	for _, resource := range strings.Split(value, ",") {
+		resource = strings.TrimSpace(resource)
+		if resource == "" {
+			continue
+		}
+		if !bypassableResources[resource] {
+			return config, fmt.Errorf("invalid value '%s' for %s: the validator of %s can't be bypassed", value, BypassResourcesEnv, resource)
+		}
+		config.Resources = append(config.Resources, resource)
+	}
+	if (config.Group == "") != (len(config.Resources) == 0) {
+		return config, fmt.Errorf("%s and %s must be set together", BypassGroupEnv, BypassResourcesEnv)
+	}
+	return config, nil
+}
+
+// bypassValidators wraps the validators of the resources in the config so that members of the config's group bypass
+// them. The other validators are returned unchanged.
+func bypassValidators(config BypassConfig, validators []admission.ValidatingAdmissionHandler) []admission.ValidatingAdmissionHandler {
+	if config.Group == "" {
+		return validators
+	}
+	wrapped := make([]admission.ValidatingAdmissionHandler, 0, len(validators))
+	for _, validator := range validators {
+		resource := validator.GVR().GroupResource().String()
+		if !slices.Contains(config.Resources, resource) || !bypassableResources[resource] {
+			wrapped = append(wrapped, validator)
+			continue
+		}
+		v := &bypassableValidator{ValidatingAdmissionHandler: validator, group: config.Group}
+		if subresourceValidator, ok := validator.(admission.SubresourceValidator); ok {
+			wrapped = append(wrapped, &bypassableSubresourceValidator{bypassableValidator: v, subresources: subresourceValidator})
+			continue
+		}
+		wrapped = append(wrapped, v)
+	}
+	return wrapped
+}
+
+// bypassableValidator is a ValidatingAdmissionHandler whose denials are overridden for members of the bypass group.
+type bypassableValidator struct {
+	admission.ValidatingAdmissionHandler
+	group string
+}
+
+// Admitters returns the wrapped handler's admitters, which allow the requests of members of the bypass group.
+func (b *bypassableValidator) Admitters() []admission.Admitter {
+	admitters := b.ValidatingAdmissionHandler.Admitters()
+	bypassable := make([]admission.Admitter, 0, len(admitters))
+	for _, admitter := range admitters {
+		bypassable = append(bypassable, &bypassableAdmitter{Admitter: admitter, gvr: b.GVR(), group: b.group})
+	}
+	return bypassable
+}
+
+// bypassableSubresourceValidator keeps the admission.SubresourceValidator implementation of the wrapped handler.
+type bypassableSubresourceValidator struct {
+	*bypassableValidator
+	subresources admission.SubresourceValidator
+}
+
+// Subresources returns the subresources of the wrapped handler.
+func (b *bypassableSubresourceValidator) Subresources() []string {
+	return b.subresources.Subresources()
+}
+
+type bypassableAdmitter struct {
+	admission.Admitter
+	gvr   schema.GroupVersionResource
+	group string
+}
+
+// Admit handles the request with the wrapped admitter, and allows it with a warning if it was denied or failed and
+// the user is a member of the bypass group. Each bypass is logged and counted.
+func (b *bypassableAdmitter) Admit(request *admission.Request) (*admissionv1.AdmissionResponse, error) {
+	response, err := b.Admitter.Admit(request)
+	if (err == nil && response != nil && response.Allowed) || !slices.Contains(request.UserInfo.Groups, b.group) {
+		return response, err
+	}
+	var reason string
+	switch {
+	case err != nil:
+		reason = err.Error()
+	case response != nil && response.Result != nil:
+		reason = response.Result.Message
+	}
+	logrus.Warnf("[bypass] allowing %s of %s %s/%s by %q (request %s) as a member of %s, the validator denied it: %s",
+		request.Operation, b.gvr.GroupResource().String(), request.Namespace, request.Name, request.UserInfo.Username,
+		request.UID, b.group, reason)
+	metrics.BypassedRequests.WithLabelValues(b.gvr.Group, b.gvr.Version, b.gvr.Resource, string(request.Operation)).Inc()
+	allowed := admission.ResponseAllowed()
+	allowed.Warnings = []string{fmt.Sprintf("validation bypassed as a member of %s: %s", b.group, reason)}
+	return allowed, nil
+}
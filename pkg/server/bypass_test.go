@@ -0,0 +1,121 @@
+package server
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/rancher/webhook/pkg/admission"
+	"github.com/rancher/webhook/pkg/metrics"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	admissionv1 "k8s.io/api/admission/v1"
+	authenticationv1 "k8s.io/api/authentication/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+func TestBypassConfigFromEnv(t *testing.T) {
+	tests := []struct {
+		name      string
+		group     string
+		resources string
+		want      BypassConfig
+		wantErr   bool
+	}{
+		{
+			name: "disabled",
+		},
+		{
+			name:      "custom values",
+			group:     "rancher-webhook:bypass",
+			resources: "settings.management.cattle.io, clusterrepos.catalog.cattle.io",
+			want:      BypassConfig{Group: "rancher-webhook:bypass", Resources: []string{"settings.management.cattle.io", "clusterrepos.catalog.cattle.io"}},
+		},
+		{name: "escalation validator", group: "rancher-webhook:bypass", resources: "globalroles.management.cattle.io", wantErr: true},
+		{name: "namespaces", group: "rancher-webhook:bypass", resources: "namespaces", wantErr: true},
+		{name: "secrets", group: "rancher-webhook:bypass", resources: "secrets", wantErr: true},
+		{name: "provisioning clusters", group: "rancher-webhook:bypass", resources: "clusters.provisioning.cattle.io", wantErr: true},
+		{name: "management clusters", group: "rancher-webhook:bypass", resources: "clusters.management.cattle.io", wantErr: true},
+		{name: "nodes", group: "rancher-webhook:bypass", resources: "nodes", wantErr: true},
+		{name: "group without resources", group: "rancher-webhook:bypass", wantErr: true},
+		{name: "resources without group", resources: "settings.management.cattle.io", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv(BypassGroupEnv, tt.group)
+			t.Setenv(BypassResourcesEnv, tt.resources)
+			got, err := BypassConfigFromEnv()
+			if tt.wantErr {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestBypassValidators(t *testing.T) {
+	t.Parallel()
+	validators := []admission.ValidatingAdmissionHandler{&denyingHandler{}}
+	assert.Same(t, validators[0], bypassValidators(BypassConfig{}, validators)[0], "validators are unchanged while bypassing is disabled")
+	assert.Same(t, validators[0], bypassValidators(BypassConfig{Group: "bypass", Resources: []string{"settings.management.cattle.io"}}, validators)[0],
+		"validators of other resources are unchanged")
+
+	bypassed := bypassValidators(BypassConfig{Group: "bypass", Resources: []string{"nodedrivers.management.cattle.io"}}, validators)
+	require.Len(t, bypassed, 1)
+	admitters := bypassed[0].Admitters()
+	require.Len(t, admitters, 1)
+	counter := metrics.BypassedRequests.WithLabelValues("management.cattle.io", "v3", "nodedrivers", "CREATE")
+	before := testutil.ToFloat64(counter)
+
+	request := &admission.Request{AdmissionRequest: admissionv1.AdmissionRequest{
+		Operation: admissionv1.Create,
+		Name:      "driver",
+		UserInfo:  authenticationv1.UserInfo{Username: "user", Groups: []string{"system:authenticated"}},
+	}}
+	response, err := admitters[0].Admit(request)
+	require.NoError(t, err)
+	assert.False(t, response.Allowed, "users outside of the bypass group are validated")
+	assert.Equal(t, before, testutil.ToFloat64(counter))
+
+	request.UserInfo.Groups = append(request.UserInfo.Groups, "bypass")
+	response, err = admitters[0].Admit(request)
+	require.NoError(t, err)
+	assert.True(t, response.Allowed)
+	require.Len(t, response.Warnings, 1)
+	assert.Contains(t, response.Warnings[0], "denied")
+	assert.Equal(t, before+1, testutil.ToFloat64(counter))
+}
+
+func TestBypassableAdmitterError(t *testing.T) {
+	t.Parallel()
+	admitter := &bypassableAdmitter{Admitter: admission.AdmitterFunc(func(*admission.Request) (*admissionv1.AdmissionResponse, error) {
+		return nil, errors.New("cache not synced")
+	}), group: "bypass"}
+	request := &admission.Request{AdmissionRequest: admissionv1.AdmissionRequest{
+		Operation: admissionv1.Update,
+		UserInfo:  authenticationv1.UserInfo{Username: "user", Groups: []string{"bypass"}},
+	}}
+	response, err := admitter.Admit(request)
+	require.NoError(t, err)
+	assert.True(t, response.Allowed)
+	assert.Contains(t, response.Warnings[0], "cache not synced")
+}
+
+func TestNonBypassableValidators(t *testing.T) {
+	t.Parallel()
+	// a config can't be loaded with these resources, but they are also skipped when wrapping
+	validators := []admission.ValidatingAdmissionHandler{&globalRoleHandler{}}
+	bypassed := bypassValidators(BypassConfig{Group: "bypass", Resources: []string{"globalroles.management.cattle.io"}}, validators)
+	assert.Same(t, validators[0], bypassed[0])
+}
+
+// globalRoleHandler is a denyingHandler for GlobalRoles.
+type globalRoleHandler struct {
+	denyingHandler
+}
+
+func (g *globalRoleHandler) GVR() schema.GroupVersionResource {
+	return schema.GroupVersionResource{Group: "management.cattle.io", Version: "v3", Resource: "globalroles"}
+}
@@ -0,0 +1,163 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rancher/webhook/pkg/metrics"
+	"github.com/sirupsen/logrus"
+	admissionv1 "k8s.io/api/admission/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+const (
+	// UnsyncedModeEnv is the environment variable setting how admission requests received before the webhook's
+	// caches have synced are handled.
+	UnsyncedModeEnv = "CATTLE_WEBHOOK_UNSYNCED_MODE"
+	// UnsyncedModeQueue holds the requests until the caches have synced, up to maxUnsyncedWait or the request's
+	// timeout, after which the request fails and the webhook's failurePolicy applies. It is the default.
+	UnsyncedModeQueue = "queue"
+	// UnsyncedModeAllow admits the requests with a warning, as if the webhook was not registered.
+	UnsyncedModeAllow = "allow"
+
+	// maxUnsyncedWait is how long a queued request waits for the caches to sync, which is shorter than the default
+	// webhook timeout of 10 seconds so that a response is sent before the API server gives up on the request.
+	maxUnsyncedWait = 8 * time.Second
+)
+
+// UnsyncedModeFromEnv returns the mode set by UnsyncedModeEnv, or UnsyncedModeQueue if unset.
+func UnsyncedModeFromEnv() (string, error) {
+	switch value := os.Getenv(UnsyncedModeEnv); value {
+	case "":
+		return UnsyncedModeQueue, nil
+	case UnsyncedModeQueue, UnsyncedModeAllow:
+		return value, nil
+	default:
+		return "", fmt.Errorf("invalid value '%s' for %s: must be %s or %s", value, UnsyncedModeEnv, UnsyncedModeQueue, UnsyncedModeAllow)
+	}
+}
+
+// cacheSyncGate lets the webhook serve before its caches have synced. Until then, admission requests are queued or
+// allowed depending on the mode, since the handlers would otherwise read incomplete caches, and the webhook isn't
+// ready. Its readiness check lists the kinds whose caches haven't synced yet.
+type cacheSyncGate struct {
+	mode string
+	// status returns whether the cache of each kind has synced, without waiting if ctx is done.
+	status   func(ctx context.Context) map[schema.GroupVersionKind]bool
+	synced   chan struct{}
+	syncOnce sync.Once
+}
+
+func newCacheSyncGate(mode string, status func(ctx context.Context) map[schema.GroupVersionKind]bool) *cacheSyncGate {
+	return &cacheSyncGate{mode: mode, status: status, synced: make(chan struct{})}
+}
+
+// markSynced records that all the caches have synced, releasing the queued requests.
+func (g *cacheSyncGate) markSynced() {
+	g.syncOnce.Do(func() {
+		close(g.synced)
+		logrus.Info("[cacheSyncGate] caches synced, admitting requests")
+	})
+}
+
+// isSynced returns true once all the caches have synced.
+func (g *cacheSyncGate) isSynced() bool {
+	select {
+	case <-g.synced:
+		return true
+	default:
+		return false
+	}
+}
+
+// Name returns the Name of the checker.
+func (g *cacheSyncGate) Name() string { return "Caches Synced" }
+
+// Check returns an error listing the kinds whose caches haven't synced, until all of them have.
+func (g *cacheSyncGate) Check(_ *http.Request) error {
+	if g.isSynced() {
+		return nil
+	}
+	var unsynced []string
+	if g.status != nil {
+		// a done context returns the current status without waiting
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		for gvk, synced := range g.status(ctx) {
+			if !synced {
+				unsynced = append(unsynced, gvk.String())
+			}
+		}
+	}
+	if len(unsynced) == 0 {
+		return fmt.Errorf("caches have not synced")
+	}
+	slices.Sort(unsynced)
+	return fmt.Errorf("caches of %s have not synced", strings.Join(unsynced, ", "))
+}
+
+// middleware holds or allows the admission requests of the given paths until the caches have synced.
+func (g *cacheSyncGate) middleware(pathPrefixes ...string) func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if g.isSynced() || !hasPrefix(r.URL.Path, pathPrefixes) {
+				next.ServeHTTP(w, r)
+				return
+			}
+			if g.mode == UnsyncedModeAllow {
+				metrics.AdmissionRequestsUnsynced.WithLabelValues(metrics.UnsyncedResultAllowed).Inc()
+				writeUnsyncedAllowedResponse(w, r)
+				return
+			}
+
+			ctx, cancel := context.WithTimeout(r.Context(), maxUnsyncedWait)
+			defer cancel()
+			select {
+			case <-g.synced:
+				metrics.AdmissionRequestsUnsynced.WithLabelValues(metrics.UnsyncedResultQueued).Inc()
+				next.ServeHTTP(w, r)
+			case <-ctx.Done():
+				metrics.AdmissionRequestsUnsynced.WithLabelValues(metrics.UnsyncedResultTimedOut).Inc()
+				http.Error(w, "webhook caches have not synced", http.StatusServiceUnavailable)
+			}
+		})
+	}
+}
+
+// writeUnsyncedAllowedResponse admits the AdmissionReview of the request with a warning.
+func writeUnsyncedAllowedResponse(w http.ResponseWriter, r *http.Request) {
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("failed to read request: %v", err), http.StatusBadRequest)
+		return
+	}
+	var review limitedReview
+	if err := json.NewDecoder(bytes.NewReader(body)).Decode(&review); err != nil || review.Request == nil {
+		http.Error(w, "failed to decode admission review", http.StatusBadRequest)
+		return
+	}
+	response := admissionv1.AdmissionReview{
+		TypeMeta: review.TypeMeta,
+		Response: &admissionv1.AdmissionResponse{
+			UID:      review.Request.UID,
+			Allowed:  true,
+			Warnings: []string{"the request was not validated by the Rancher webhook, whose caches have not synced"},
+		},
+	}
+	if response.APIVersion == "" {
+		response.SetGroupVersionKind(admissionv1.SchemeGroupVersion.WithKind("AdmissionReview"))
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		logrus.Warnf("failed to encode response: %s", err)
+	}
+}
@@ -0,0 +1,127 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	admissionv1 "k8s.io/api/admission/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+func TestUnsyncedModeFromEnv(t *testing.T) {
+	tests := []struct {
+		name    string
+		value   string
+		want    string
+		wantErr bool
+	}{
+		{name: "default", want: UnsyncedModeQueue},
+		{name: "queue", value: "queue", want: UnsyncedModeQueue},
+		{name: "allow", value: "allow", want: UnsyncedModeAllow},
+		{name: "invalid", value: "deny", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv(UnsyncedModeEnv, tt.value)
+			got, err := UnsyncedModeFromEnv()
+			if tt.wantErr {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestCacheSyncGateCheck(t *testing.T) {
+	t.Parallel()
+	status := map[schema.GroupVersionKind]bool{
+		{Group: "management.cattle.io", Version: "v3", Kind: "Project"}: false,
+		{Group: "", Version: "v1", Kind: "Secret"}:                      true,
+		{Group: "management.cattle.io", Version: "v3", Kind: "Cluster"}: false,
+	}
+	gate := newCacheSyncGate(UnsyncedModeQueue, func(ctx context.Context) map[schema.GroupVersionKind]bool {
+		assert.Error(t, ctx.Err(), "the status must be read without waiting")
+		return status
+	})
+
+	err := gate.Check(nil)
+	require.Error(t, err)
+	assert.Equal(t, "caches of management.cattle.io/v3, Kind=Cluster, management.cattle.io/v3, Kind=Project have not synced", err.Error())
+
+	gate.markSynced()
+	gate.markSynced()
+	assert.NoError(t, gate.Check(nil))
+}
+
+func TestCacheSyncGateMiddleware(t *testing.T) {
+	t.Parallel()
+	next := http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusTeapot)
+	})
+
+	t.Run("non admission paths are served", func(t *testing.T) {
+		t.Parallel()
+		gate := newCacheSyncGate(UnsyncedModeQueue, nil)
+		rec := httptest.NewRecorder()
+		gate.middleware(validationPath)(next).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+		assert.Equal(t, http.StatusTeapot, rec.Code)
+	})
+
+	t.Run("synced requests are served", func(t *testing.T) {
+		t.Parallel()
+		gate := newCacheSyncGate(UnsyncedModeAllow, nil)
+		gate.markSynced()
+		rec := httptest.NewRecorder()
+		gate.middleware(validationPath)(next).ServeHTTP(rec, httptest.NewRequest(http.MethodPost, validationPath+"/secrets", nil))
+		assert.Equal(t, http.StatusTeapot, rec.Code)
+	})
+
+	t.Run("queued requests are served once synced", func(t *testing.T) {
+		t.Parallel()
+		gate := newCacheSyncGate(UnsyncedModeQueue, nil)
+		go func() {
+			time.Sleep(50 * time.Millisecond)
+			gate.markSynced()
+		}()
+		rec := httptest.NewRecorder()
+		gate.middleware(validationPath)(next).ServeHTTP(rec, httptest.NewRequest(http.MethodPost, validationPath+"/secrets", nil))
+		assert.Equal(t, http.StatusTeapot, rec.Code)
+	})
+
+	t.Run("queued requests time out", func(t *testing.T) {
+		t.Parallel()
+		gate := newCacheSyncGate(UnsyncedModeQueue, nil)
+		ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+		defer cancel()
+		req := httptest.NewRequest(http.MethodPost, validationPath+"/secrets", nil).WithContext(ctx)
+		rec := httptest.NewRecorder()
+		gate.middleware(validationPath)(next).ServeHTTP(rec, req)
+		assert.Equal(t, http.StatusServiceUnavailable, rec.Code)
+	})
+
+	t.Run("requests are allowed with a warning", func(t *testing.T) {
+		t.Parallel()
+		gate := newCacheSyncGate(UnsyncedModeAllow, nil)
+		body := newReviewBody(t, "1", "alice", 10)
+		rec := httptest.NewRecorder()
+		gate.middleware(validationPath)(next).ServeHTTP(rec, httptest.NewRequest(http.MethodPost, validationPath+"/secrets", strings.NewReader(body)))
+		require.Equal(t, http.StatusOK, rec.Code)
+
+		var review admissionv1.AdmissionReview
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &review))
+		require.NotNil(t, review.Response)
+		assert.True(t, review.Response.Allowed)
+		assert.Equal(t, "uid-1", string(review.Response.UID))
+		assert.Len(t, review.Response.Warnings, 1)
+		assert.Equal(t, "AdmissionReview", review.Kind)
+	})
+}
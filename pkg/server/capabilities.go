@@ -0,0 +1,102 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/rancher/webhook/pkg/admission"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+const (
+	// CapabilitiesConfigMapName is the name of the ConfigMap in the webhook's namespace where the webhook publishes
+	// its Capabilities, so that Rancher can tell after an upgrade whether the running webhook validates what it expects.
+	CapabilitiesConfigMapName = "rancher-webhook-capabilities"
+	// capabilitiesKey is the key of the JSON encoded Capabilities in the data of the capabilities ConfigMap.
+	capabilitiesKey = "capabilities.json"
+)
+
+// Capabilities describes the validation performed by the running webhook.
+type Capabilities struct {
+	WebhookVersion string                  `json:"webhookVersion"`
+	PolicyVersion  admission.PolicyVersion `json:"policyVersion"`
+	// UnknownFields is how objects with fields unknown to the webhook are admitted.
+	UnknownFields admission.UnknownFieldsMode `json:"unknownFields"`
+	// Validating and Mutating are the resources, formatted as "resource.group/version", reviewed by the enabled
+	// validators and mutators.
+	Validating []string `json:"validating"`
+	Mutating   []string `json:"mutating"`
+}
+
+// capabilities returns the Capabilities of the handlers which are currently enabled.
+func (s *secretHandler) capabilities() Capabilities {
+	capabilities := Capabilities{
+		WebhookVersion: admission.WebhookVersion(),
+		PolicyVersion:  admission.CurrentPolicyVersion(),
+		UnknownFields:  s.unknownFields,
+		Validating:     []string{},
+		Mutating:       []string{},
+	}
+	if capabilities.UnknownFields == "" {
+		capabilities.UnknownFields = admission.UnknownFieldsIgnore
+	}
+	for _, validator := range s.validators {
+		if isEnabled(validator) {
+			capabilities.Validating = append(capabilities.Validating, gvrString(validator.GVR()))
+		}
+	}
+	for _, mutator := range s.mutators {
+		if isEnabled(mutator) {
+			capabilities.Mutating = append(capabilities.Mutating, gvrString(mutator.GVR()))
+		}
+	}
+	return capabilities
+}
+
+// publishCapabilities creates or updates the capabilities ConfigMap with the Capabilities of the enabled handlers.
+func (s *secretHandler) publishCapabilities() error {
+	if s.configMaps == nil {
+		return nil
+	}
+	data, err := json.Marshal(s.capabilities())
+	if err != nil {
+		return fmt.Errorf("failed to encode capabilities: %w", err)
+	}
+
+	current, err := s.configMaps.Get(namespace, CapabilitiesConfigMapName, metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		_, err = s.configMaps.Create(&corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{Name: CapabilitiesConfigMapName, Namespace: namespace},
+			Data:       map[string]string{capabilitiesKey: string(data)},
+		})
+		if err != nil {
+			return fmt.Errorf("failed to create capabilities configmap: %w", err)
+		}
+		return nil
+	} else if err != nil {
+		return fmt.Errorf("failed to get capabilities configmap: %w", err)
+	}
+	if current.Data[capabilitiesKey] == string(data) {
+		return nil
+	}
+	current = current.DeepCopy()
+	if current.Data == nil {
+		current.Data = map[string]string{}
+	}
+	current.Data[capabilitiesKey] = string(data)
+	if _, err = s.configMaps.Update(current); err != nil {
+		return fmt.Errorf("failed to update capabilities configmap: %w", err)
+	}
+	return nil
+}
+
+// gvrString formats a GroupVersionResource as "resource.group/version", or "resource/version" for the core group.
+func gvrString(gvr schema.GroupVersionResource) string {
+	if gvr.Group == "" {
+		return gvr.Resource + "/" + gvr.Version
+	}
+	return gvr.Resource + "." + gvr.Group + "/" + gvr.Version
+}
@@ -0,0 +1,76 @@
+package server
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/rancher/webhook/pkg/admission"
+	"github.com/rancher/wrangler/v3/pkg/generic/fake"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+func TestPublishCapabilities(t *testing.T) {
+	t.Parallel()
+	handler := &secretHandler{
+		validators: append([]admission.ValidatingAdmissionHandler{&denyingHandler{}},
+			toggleValidators([]admission.ValidatingAdmissionHandler{&denyingHandler{}}, func() bool { return false })...),
+		mutators:      []admission.MutatingAdmissionHandler{&denyingHandler{}},
+		unknownFields: admission.UnknownFieldsWarn,
+	}
+
+	capabilities := handler.capabilities()
+	assert.Equal(t, admission.UnknownFieldsWarn, capabilities.UnknownFields)
+	assert.Equal(t, []string{"nodedrivers.management.cattle.io/v3"}, capabilities.Validating, "disabled handlers should not be published")
+	assert.Equal(t, []string{"nodedrivers.management.cattle.io/v3"}, capabilities.Mutating)
+	expected, err := json.Marshal(capabilities)
+	require.NoError(t, err)
+
+	t.Run("create", func(t *testing.T) {
+		t.Parallel()
+		configMaps := fake.NewMockClientInterface[*corev1.ConfigMap, *corev1.ConfigMapList](gomock.NewController(t))
+		configMaps.EXPECT().Get(namespace, CapabilitiesConfigMapName, gomock.Any()).
+			Return(nil, apierrors.NewNotFound(schema.GroupResource{Resource: "configmaps"}, CapabilitiesConfigMapName))
+		configMaps.EXPECT().Create(gomock.Any()).DoAndReturn(func(configMap *corev1.ConfigMap) (*corev1.ConfigMap, error) {
+			assert.Equal(t, namespace, configMap.Namespace)
+			assert.JSONEq(t, string(expected), configMap.Data[capabilitiesKey])
+			return configMap, nil
+		})
+		handler := *handler
+		handler.configMaps = configMaps
+		require.NoError(t, handler.publishCapabilities())
+	})
+
+	t.Run("update", func(t *testing.T) {
+		t.Parallel()
+		configMaps := fake.NewMockClientInterface[*corev1.ConfigMap, *corev1.ConfigMapList](gomock.NewController(t))
+		configMaps.EXPECT().Get(namespace, CapabilitiesConfigMapName, gomock.Any()).Return(&corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{Name: CapabilitiesConfigMapName, Namespace: namespace},
+			Data:       map[string]string{capabilitiesKey: "{}"},
+		}, nil)
+		configMaps.EXPECT().Update(gomock.Any()).DoAndReturn(func(configMap *corev1.ConfigMap) (*corev1.ConfigMap, error) {
+			assert.JSONEq(t, string(expected), configMap.Data[capabilitiesKey])
+			return configMap, nil
+		})
+		handler := *handler
+		handler.configMaps = configMaps
+		require.NoError(t, handler.publishCapabilities())
+	})
+
+	t.Run("unchanged", func(t *testing.T) {
+		t.Parallel()
+		configMaps := fake.NewMockClientInterface[*corev1.ConfigMap, *corev1.ConfigMapList](gomock.NewController(t))
+		configMaps.EXPECT().Get(namespace, CapabilitiesConfigMapName, gomock.Any()).Return(&corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{Name: CapabilitiesConfigMapName, Namespace: namespace},
+			Data:       map[string]string{capabilitiesKey: string(expected)},
+		}, nil)
+		handler := *handler
+		handler.configMaps = configMaps
+		require.NoError(t, handler.publishCapabilities())
+	})
+}
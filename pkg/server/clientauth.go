@@ -0,0 +1,175 @@
+package server
+
+import (
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rancher/webhook/pkg/health"
+	"github.com/rancher/webhook/pkg/metrics"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	// ClientCAFileEnv is the environment variable setting the file holding the CA bundle client certificates are
+	// verified with, typically mounted from a Secret or a ConfigMap.
+	ClientCAFileEnv = "CATTLE_WEBHOOK_CLIENT_CA_FILE"
+	// RequireClientCertEnv is the environment variable which, when true, makes the webhook fail to start if the client
+	// CA file can't be read, instead of serving requests without authenticating them.
+	RequireClientCertEnv = "CATTLE_WEBHOOK_REQUIRE_CLIENT_CERT"
+	// AllowedCNsEnv is the environment variable holding the comma separated common names allowed for client
+	// certificates. Any common name is allowed if unset.
+	AllowedCNsEnv = "ALLOWED_CNS"
+)
+
+// defaultClientCAFile is where the client CA bundle is read from if ClientCAFileEnv is unset.
+var defaultClientCAFile = filepath.Join(os.TempDir(), "k8s-webhook-server", "client-ca", "ca.crt")
+
+// ClientAuthConfig configures the verification of the certificates presented by the webhook's clients.
+type ClientAuthConfig struct {
+	// CAFile is the file holding the CA bundle client certificates must be signed by.
+	CAFile string
+	// Required makes a missing CA file an error instead of disabling the verification.
+	Required bool
+	// AllowedCNs are the common names allowed for client certificates. Any common name is allowed if empty.
+	AllowedCNs []string
+}
+
+// ClientAuthConfigFromEnv returns the ClientAuthConfig set in the environment.
+func ClientAuthConfigFromEnv() (ClientAuthConfig, error) {
+	config := ClientAuthConfig{CAFile: defaultClientCAFile}
+	if value := os.Getenv(ClientCAFileEnv); value != "" {
+		config.CAFile = value
+	}
+	if value := os.Getenv(RequireClientCertEnv); value != "" {
+		required, err := strconv.ParseBool(value)
+		if err != nil {
+			return config, fmt.Errorf("invalid value '%s' for %s: must be a boolean", value, RequireClientCertEnv)
+		}
+		config.Required = required
+	}
+	if value := os.Getenv(AllowedCNsEnv); value != "" {
+		config.AllowedCNs = strings.Split(value, ",")
+	}
+	return config, nil
+}
+
+// clientCertAuth verifies the certificates presented by clients against the client CA bundle. The bundle is read
+// again whenever its file changes, so that a rotated Secret or ConfigMap is picked up without a restart.
+type clientCertAuth struct {
+	config ClientAuthConfig
+
+	mu      sync.RWMutex
+	roots   *x509.CertPool
+	modTime time.Time
+}
+
+// newClientCertAuth returns a clientCertAuth for the config, or nil if the CA file can't be read and client
+// certificates aren't required.
+func newClientCertAuth(config ClientAuthConfig) (*clientCertAuth, error) {
+	auth := &clientCertAuth{config: config}
+	if err := auth.load(); err != nil {
+		if config.Required {
+			return nil, fmt.Errorf("failed to load client CA: %w", err)
+		}
+		logrus.Infof("could not read client CA file at %s, incoming requests will not be authenticated: %v", config.CAFile, err)
+		return nil, nil
+	}
+	return auth, nil
+}
+
+// load reads the CA bundle if its file was modified since it was last read.
+func (c *clientCertAuth) load() error {
+	info, err := os.Stat(c.config.CAFile)
+	if err != nil {
+		return err
+	}
+	c.mu.RLock()
+	unchanged := c.roots != nil && info.ModTime().Equal(c.modTime)
+	c.mu.RUnlock()
+	if unchanged {
+		return nil
+	}
+
+	caCert, err := os.ReadFile(c.config.CAFile)
+	if err != nil {
+		return err
+	}
+	roots := x509.NewCertPool()
+	if !roots.AppendCertsFromPEM(caCert) {
+		return errors.New("no certificate found in client CA file")
+	}
+	c.mu.Lock()
+	c.roots, c.modTime = roots, info.ModTime()
+	c.mu.Unlock()
+	return nil
+}
+
+// verifyOptions returns the options client certificates are verified with. If the CA bundle can't be read again,
+// the last bundle read is used.
+func (c *clientCertAuth) verifyOptions() x509.VerifyOptions {
+	if err := c.load(); err != nil {
+		logrus.Warnf("could not reload client CA file at %s, using the previous CA: %v", c.config.CAFile, err)
+	}
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return x509.VerifyOptions{
+		Roots:         c.roots,
+		Intermediates: x509.NewCertPool(),
+		KeyUsages:     []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}
+}
+
+// middleware rejects requests from clients without a certificate signed by the client CA and with an allowed
+// common name. This is done as a middleware instead of using tls.RequireAndVerifyClientCert because an exception
+// needs to be made for the unauthenticated /healthz and /readyz endpoints. Rejected requests are counted in
+// metrics.ClientCertRejections.
+func (c *clientCertAuth) middleware(next http.Handler) http.Handler {
+	if c == nil {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		logrus.Tracef("running cert check middleware for request %s", r.URL.Path)
+		if health.IsHealthPath(r.URL.Path) { // kubelet does not present client cert for health checks
+			next.ServeHTTP(w, r)
+			return
+		}
+		if r.TLS == nil || len(r.TLS.PeerCertificates) == 0 {
+			logrus.Warn("client did not present certificates")
+			metrics.ClientCertRejections.WithLabelValues(metrics.ClientCertReasonMissing).Inc()
+			http.Error(w, "could not verify client certificates", http.StatusUnauthorized)
+			return
+		}
+		opts := c.verifyOptions()
+		for _, cert := range r.TLS.PeerCertificates[1:] {
+			opts.Intermediates.AddCert(cert)
+		}
+		if _, err := r.TLS.PeerCertificates[0].Verify(opts); err != nil {
+			logrus.Warnf("could not verify client certificates: %v", err)
+			metrics.ClientCertRejections.WithLabelValues(metrics.ClientCertReasonInvalid).Inc()
+			http.Error(w, "could not verify client certificates", http.StatusUnauthorized)
+			return
+		}
+		if len(c.config.AllowedCNs) == 0 {
+			next.ServeHTTP(w, r)
+			return
+		}
+		requestCN := r.TLS.PeerCertificates[0].Subject.CommonName
+		for _, allowed := range c.config.AllowedCNs {
+			if allowed == requestCN {
+				next.ServeHTTP(w, r)
+				return
+			}
+		}
+		logrus.Warnf("could not find common name %s in allowed list", requestCN)
+		metrics.ClientCertRejections.WithLabelValues(metrics.ClientCertReasonCommonName).Inc()
+		http.Error(w, "common name is not allowed", http.StatusUnauthorized)
+	})
+}
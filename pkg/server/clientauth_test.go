@@ -0,0 +1,225 @@
+package server
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/rancher/webhook/pkg/metrics"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type testCA struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+	pem  []byte
+}
+
+func newTestCA(t *testing.T) *testCA {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "test-ca"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	require.NoError(t, err)
+	cert, err := x509.ParseCertificate(der)
+	require.NoError(t, err)
+	return &testCA{cert: cert, key: key, pem: pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})}
+}
+
+func (c *testCA) clientCert(t *testing.T, commonName string) *x509.Certificate {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(2),
+		Subject:      pkix.Name{CommonName: commonName},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, c.cert, &key.PublicKey, c.key)
+	require.NoError(t, err)
+	cert, err := x509.ParseCertificate(der)
+	require.NoError(t, err)
+	return cert
+}
+
+func writeCAFile(t *testing.T, path string, caPEM []byte, modTime time.Time) {
+	t.Helper()
+	require.NoError(t, os.WriteFile(path, caPEM, 0o600))
+	require.NoError(t, os.Chtimes(path, modTime, modTime))
+}
+
+func TestClientAuthConfigFromEnv(t *testing.T) {
+	tests := []struct {
+		name    string
+		env     map[string]string
+		want    ClientAuthConfig
+		wantErr bool
+	}{
+		{
+			name: "defaults",
+			want: ClientAuthConfig{CAFile: defaultClientCAFile},
+		},
+		{
+			name: "custom values",
+			env:  map[string]string{ClientCAFileEnv: "/etc/webhook/ca.crt", RequireClientCertEnv: "true", AllowedCNsEnv: "kube-apiserver,front-proxy"},
+			want: ClientAuthConfig{CAFile: "/etc/webhook/ca.crt", Required: true, AllowedCNs: []string{"kube-apiserver", "front-proxy"}},
+		},
+		{name: "invalid required", env: map[string]string{RequireClientCertEnv: "always"}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for _, key := range []string{ClientCAFileEnv, RequireClientCertEnv, AllowedCNsEnv} {
+				t.Setenv(key, tt.env[key])
+			}
+			got, err := ClientAuthConfigFromEnv()
+			if tt.wantErr {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestNewClientCertAuthMissingCA(t *testing.T) {
+	t.Parallel()
+	caFile := filepath.Join(t.TempDir(), "ca.crt")
+
+	auth, err := newClientCertAuth(ClientAuthConfig{CAFile: caFile})
+	require.NoError(t, err)
+	assert.Nil(t, auth, "verification should be disabled when the CA is optional")
+
+	_, err = newClientCertAuth(ClientAuthConfig{CAFile: caFile, Required: true})
+	assert.Error(t, err)
+}
+
+func TestClientCertAuthMiddleware(t *testing.T) {
+	t.Parallel()
+	ca := newTestCA(t)
+	otherCA := newTestCA(t)
+	caFile := filepath.Join(t.TempDir(), "ca.crt")
+	writeCAFile(t, caFile, ca.pem, time.Now())
+
+	auth, err := newClientCertAuth(ClientAuthConfig{CAFile: caFile, AllowedCNs: []string{"kube-apiserver"}})
+	require.NoError(t, err)
+	handler := auth.middleware(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	tests := []struct {
+		name       string
+		path       string
+		certs      []*x509.Certificate
+		wantCode   int
+		wantReason string
+	}{
+		{
+			name:     "valid certificate",
+			path:     validationPath,
+			certs:    []*x509.Certificate{ca.clientCert(t, "kube-apiserver")},
+			wantCode: http.StatusOK,
+		},
+		{
+			name:       "no certificate",
+			path:       validationPath,
+			wantCode:   http.StatusUnauthorized,
+			wantReason: metrics.ClientCertReasonMissing,
+		},
+		{
+			name:       "certificate of another CA",
+			path:       validationPath,
+			certs:      []*x509.Certificate{otherCA.clientCert(t, "kube-apiserver")},
+			wantCode:   http.StatusUnauthorized,
+			wantReason: metrics.ClientCertReasonInvalid,
+		},
+		{
+			name:       "common name not allowed",
+			path:       mutationPath,
+			certs:      []*x509.Certificate{ca.clientCert(t, "someone")},
+			wantCode:   http.StatusUnauthorized,
+			wantReason: metrics.ClientCertReasonCommonName,
+		},
+		{
+			name:     "health check without certificate",
+			path:     "/healthz",
+			wantCode: http.StatusOK,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var before float64
+			if tt.wantReason != "" {
+				before = testutil.ToFloat64(metrics.ClientCertRejections.WithLabelValues(tt.wantReason))
+			}
+			req := httptest.NewRequest(http.MethodPost, tt.path, nil)
+			req.TLS = &tls.ConnectionState{PeerCertificates: tt.certs}
+			rec := httptest.NewRecorder()
+
+			handler.ServeHTTP(rec, req)
+
+			assert.Equal(t, tt.wantCode, rec.Code)
+			if tt.wantReason != "" {
+				assert.Equal(t, before+1, testutil.ToFloat64(metrics.ClientCertRejections.WithLabelValues(tt.wantReason)))
+			}
+		})
+	}
+}
+
+func TestClientCertAuthReloadsCA(t *testing.T) {
+	t.Parallel()
+	oldCA := newTestCA(t)
+	newCA := newTestCA(t)
+	caFile := filepath.Join(t.TempDir(), "ca.crt")
+	start := time.Now().Add(-time.Minute)
+	writeCAFile(t, caFile, oldCA.pem, start)
+
+	auth, err := newClientCertAuth(ClientAuthConfig{CAFile: caFile})
+	require.NoError(t, err)
+	handler := auth.middleware(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	serve := func(cert *x509.Certificate) int {
+		req := httptest.NewRequest(http.MethodPost, validationPath, nil)
+		req.TLS = &tls.ConnectionState{PeerCertificates: []*x509.Certificate{cert}}
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec.Code
+	}
+
+	assert.Equal(t, http.StatusOK, serve(oldCA.clientCert(t, "kube-apiserver")))
+	assert.Equal(t, http.StatusUnauthorized, serve(newCA.clientCert(t, "kube-apiserver")))
+
+	writeCAFile(t, caFile, newCA.pem, start.Add(time.Second))
+	assert.Equal(t, http.StatusUnauthorized, serve(oldCA.clientCert(t, "kube-apiserver")))
+	assert.Equal(t, http.StatusOK, serve(newCA.clientCert(t, "kube-apiserver")))
+
+	// the last CA read keeps being used if the file is removed
+	require.NoError(t, os.Remove(caFile))
+	assert.Equal(t, http.StatusOK, serve(newCA.clientCert(t, "kube-apiserver")))
+}
@@ -0,0 +1,206 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/rancher/webhook/pkg/admission"
+	"github.com/sirupsen/logrus"
+	admissionv1 "k8s.io/api/admission/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	corev1client "k8s.io/client-go/kubernetes/typed/core/v1"
+)
+
+const (
+	// DenialEventsEnv is the environment variable enabling the Events recording denied requests.
+	DenialEventsEnv = "CATTLE_WEBHOOK_DENIAL_EVENTS"
+	// DenialEventsIntervalEnv is the environment variable holding the minimum duration between two Events of the same
+	// object.
+	DenialEventsIntervalEnv = "CATTLE_WEBHOOK_DENIAL_EVENTS_INTERVAL"
+
+	defaultDenialEventsInterval = time.Minute
+
+	// denialEventReason is the reason of the Events recording denied requests.
+	denialEventReason = "AdmissionDenied"
+	// denialEventComponent is the source component of the Events recording denied requests.
+	denialEventComponent = "rancher-webhook"
+	// maxDenialEventMessage is the maximum length of the message of an Event, as enforced by the API server.
+	maxDenialEventMessage = 1024
+	// clusterScopedEventNamespace is the namespace of the Events of cluster scoped objects.
+	clusterScopedEventNamespace = metav1.NamespaceDefault
+	// denialEventTimeout is how long the creation of an Event can take.
+	denialEventTimeout = 10 * time.Second
+)
+
+// DenialEventsConfig configures the Events recording denied requests on the denied object.
+type DenialEventsConfig struct {
+	// Enabled is true if Events are created for denied requests.
+	Enabled bool
+	// Interval is the minimum duration between two Events of the same object.
+	Interval time.Duration
+}
+
+// DenialEventsConfigFromEnv returns the DenialEventsConfig set by the environment.
+func DenialEventsConfigFromEnv() (DenialEventsConfig, error) {
+	config := DenialEventsConfig{Interval: defaultDenialEventsInterval}
+	if value := os.Getenv(DenialEventsEnv); value != "" {
+		enabled, err := strconv.ParseBool(value)
+		if err != nil {
+			return DenialEventsConfig{}, fmt.Errorf("invalid value '%s' for %s: %w", value, DenialEventsEnv, err)
+		}
+		config.Enabled = enabled
+	}
+	if value := os.Getenv(DenialEventsIntervalEnv); value != "" {
+		interval, err := time.ParseDuration(value)
+		if err != nil {
+			return DenialEventsConfig{}, fmt.Errorf("invalid value '%s' for %s: %w", value, DenialEventsIntervalEnv, err)
+		}
+		if interval < 0 {
+			return DenialEventsConfig{}, fmt.Errorf("invalid value '%s' for %s: must not be negative", value, DenialEventsIntervalEnv)
+		}
+		config.Interval = interval
+	}
+	return config, nil
+}
+
+// denialEventRecorder creates a Warning Event on the objects of denied requests, so that users whose requests are
+// made by automation, such as GitOps pipelines, can see why they were denied. At most one Event is created per object
+// and interval, to avoid flooding the namespace when a request is retried.
+type denialEventRecorder struct {
+	events   corev1client.EventsGetter
+	interval time.Duration
+	now      func() time.Time
+	// create creates the Event, asynchronously so that denials aren't delayed by it.
+	create func(event *corev1.Event)
+
+	mu   sync.Mutex
+	last map[string]time.Time
+}
+
+func newDenialEventRecorder(config DenialEventsConfig, events corev1client.EventsGetter) *denialEventRecorder {
+	if !config.Enabled {
+		return nil
+	}
+	r := &denialEventRecorder{
+		events:   events,
+		interval: config.Interval,
+		now:      time.Now,
+		last:     map[string]time.Time{},
+	}
+	r.create = func(event *corev1.Event) { go r.createEvent(event) }
+	return r
+}
+
+// middleware records an Event for each denied request of the given paths.
+func (r *denialEventRecorder) middleware(pathPrefixes ...string) func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			if !hasPrefix(req.URL.Path, pathPrefixes) {
+				next.ServeHTTP(w, req)
+				return
+			}
+			body, err := io.ReadAll(req.Body)
+			if err != nil {
+				http.Error(w, fmt.Sprintf("failed to read request: %v", err), http.StatusBadRequest)
+				return
+			}
+			req.Body = io.NopCloser(bytes.NewReader(body))
+			recorder := &responseRecorder{ResponseWriter: w}
+			next.ServeHTTP(recorder, req)
+
+			response := decodeReview(recorder.body.Bytes())
+			if response == nil || response.Response == nil || response.Response.Allowed {
+				return
+			}
+			review := decodeReview(body)
+			if review == nil || review.Request == nil {
+				return
+			}
+			r.record(review.Request, response.Response)
+		})
+	}
+}
+
+// record creates an Event for the denied request, unless one was created for the same object within the interval.
+// Requests without a name, such as creations using generateName, and dry runs are not recorded.
+func (r *denialEventRecorder) record(request *admissionv1.AdmissionRequest, response *admissionv1.AdmissionResponse) {
+	if request.Name == "" || (request.DryRun != nil && *request.DryRun) {
+		return
+	}
+	namespace := request.Namespace
+	if namespace == "" {
+		namespace = clusterScopedEventNamespace
+	}
+	key := fmt.Sprintf("%s/%s/%s/%s", request.Resource.Group, request.Resource.Resource, request.Namespace, request.Name)
+	now := r.now()
+	if !r.allow(key, now) {
+		return
+	}
+
+	user := request.UserInfo.Username
+	if impersonation := admission.ImpersonationOf(request.UserInfo); impersonation != nil && impersonation.Username != "" {
+		user = fmt.Sprintf("%s (%s)", user, impersonation.Username)
+	}
+	message := fmt.Sprintf("%s of %s %s by %s denied", request.Operation, request.Kind.Kind, request.Name, user)
+	if response.Result != nil && response.Result.Message != "" {
+		message += ": " + response.Result.Message
+	}
+	if len(message) > maxDenialEventMessage {
+		message = message[:maxDenialEventMessage-3] + "..."
+	}
+	timestamp := metav1.NewTime(now)
+	r.create(&corev1.Event{
+		ObjectMeta: metav1.ObjectMeta{
+			GenerateName: request.Name + ".",
+			Namespace:    namespace,
+		},
+		InvolvedObject: corev1.ObjectReference{
+			APIVersion: metav1.GroupVersion{Group: request.Kind.Group, Version: request.Kind.Version}.String(),
+			Kind:       request.Kind.Kind,
+			Namespace:  request.Namespace,
+			Name:       request.Name,
+		},
+		Reason:              denialEventReason,
+		Message:             message,
+		Type:                corev1.EventTypeWarning,
+		Source:              corev1.EventSource{Component: denialEventComponent},
+		ReportingController: denialEventComponent,
+		FirstTimestamp:      timestamp,
+		LastTimestamp:       timestamp,
+		Count:               1,
+	})
+}
+
+// allow returns true, and records the time, if no Event was created for the key within the interval.
+func (r *denialEventRecorder) allow(key string, now time.Time) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if last, ok := r.last[key]; ok && now.Sub(last) < r.interval {
+		return false
+	}
+	// forget the objects whose interval is over, so that the map doesn't grow with every denied object
+	for k, last := range r.last {
+		if now.Sub(last) >= r.interval {
+			delete(r.last, k)
+		}
+	}
+	r.last[key] = now
+	return true
+}
+
+func (r *denialEventRecorder) createEvent(event *corev1.Event) {
+	ctx, cancel := context.WithTimeout(context.Background(), denialEventTimeout)
+	defer cancel()
+	if _, err := r.events.Events(event.Namespace).Create(ctx, event, metav1.CreateOptions{}); err != nil {
+		logrus.Warnf("[denialEventRecorder] failed to create event for %s %s/%s: %v", event.InvolvedObject.Kind,
+			event.InvolvedObject.Namespace, event.InvolvedObject.Name, err)
+	}
+}
@@ -0,0 +1,124 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	admissionv1 "k8s.io/api/admission/v1"
+	authenticationv1 "k8s.io/api/authentication/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	k8stypes "k8s.io/apimachinery/pkg/types"
+)
+
+func TestDenialEventsConfigFromEnv(t *testing.T) {
+	tests := []struct {
+		name    string
+		env     map[string]string
+		want    DenialEventsConfig
+		wantErr bool
+	}{
+		{
+			name: "defaults",
+			want: DenialEventsConfig{Interval: defaultDenialEventsInterval},
+		},
+		{
+			name: "enabled",
+			env:  map[string]string{DenialEventsEnv: "true", DenialEventsIntervalEnv: "5m"},
+			want: DenialEventsConfig{Enabled: true, Interval: 5 * time.Minute},
+		},
+		{name: "invalid enabled", env: map[string]string{DenialEventsEnv: "yes please"}, wantErr: true},
+		{name: "invalid interval", env: map[string]string{DenialEventsIntervalEnv: "5"}, wantErr: true},
+		{name: "negative interval", env: map[string]string{DenialEventsIntervalEnv: "-1m"}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for _, key := range []string{DenialEventsEnv, DenialEventsIntervalEnv} {
+				t.Setenv(key, tt.env[key])
+			}
+			got, err := DenialEventsConfigFromEnv()
+			if tt.wantErr {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestDenialEventRecorderMiddleware(t *testing.T) {
+	t.Parallel()
+	assert.Nil(t, newDenialEventRecorder(DenialEventsConfig{Interval: time.Minute}, nil))
+
+	recorder := newDenialEventRecorder(DenialEventsConfig{Enabled: true, Interval: time.Minute}, nil)
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	recorder.now = func() time.Time { return now }
+	var mu sync.Mutex
+	var events []*corev1.Event
+	recorder.create = func(event *corev1.Event) {
+		mu.Lock()
+		defer mu.Unlock()
+		events = append(events, event)
+	}
+
+	handler := recorder.middleware(validationPath)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		review := admissionv1.AdmissionReview{}
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&review))
+		review.Response = &admissionv1.AdmissionResponse{UID: review.Request.UID, Allowed: review.Request.UID == "allowed"}
+		if !review.Response.Allowed {
+			review.Response.Result = &metav1.Status{Code: http.StatusBadRequest, Message: strings.Repeat("denied ", 200)}
+		}
+		review.Request = nil
+		require.NoError(t, json.NewEncoder(w).Encode(review))
+	}))
+	send := func(uid, namespace, name string, dryRun bool) {
+		body, err := json.Marshal(admissionv1.AdmissionReview{
+			TypeMeta: metav1.TypeMeta{APIVersion: "admission.k8s.io/v1", Kind: "AdmissionReview"},
+			Request: &admissionv1.AdmissionRequest{
+				UID:       k8stypes.UID(uid),
+				Kind:      metav1.GroupVersionKind{Group: "management.cattle.io", Version: "v3", Kind: "Project"},
+				Resource:  metav1.GroupVersionResource{Group: "management.cattle.io", Version: "v3", Resource: "projects"},
+				Namespace: namespace,
+				Name:      name,
+				Operation: admissionv1.Update,
+				UserInfo:  authenticationv1.UserInfo{Username: "gitops"},
+				DryRun:    &dryRun,
+			},
+		})
+		require.NoError(t, err)
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, validationPath+"/projects", strings.NewReader(string(body))))
+		require.Equal(t, http.StatusOK, rec.Code)
+	}
+
+	send("allowed", "c-abcde", "p-1", false)
+	send("denied", "c-abcde", "p-1", false)
+	send("denied", "c-abcde", "p-1", false)
+	send("denied", "c-abcde", "p-2", true)
+	send("denied", "c-abcde", "", false)
+	send("denied", "", "cluster-scoped", false)
+	now = now.Add(time.Minute)
+	send("denied", "c-abcde", "p-1", false)
+
+	mu.Lock()
+	defer mu.Unlock()
+	require.Len(t, events, 3)
+	event := events[0]
+	assert.Equal(t, "c-abcde", event.Namespace)
+	assert.Equal(t, "p-1.", event.GenerateName)
+	assert.Equal(t, corev1.ObjectReference{APIVersion: "management.cattle.io/v3", Kind: "Project", Namespace: "c-abcde", Name: "p-1"}, event.InvolvedObject)
+	assert.Equal(t, denialEventReason, event.Reason)
+	assert.Equal(t, corev1.EventTypeWarning, event.Type)
+	assert.Len(t, event.Message, maxDenialEventMessage)
+	assert.True(t, strings.HasPrefix(event.Message, "UPDATE of Project p-1 by gitops denied: denied"))
+	assert.Equal(t, metav1.NamespaceDefault, events[1].Namespace)
+	assert.Equal(t, "p-1", events[2].InvolvedObject.Name)
+}
@@ -7,89 +7,188 @@ import (
 	"github.com/rancher/webhook/pkg/resolvers"
 	"github.com/rancher/webhook/pkg/resources/catalog.cattle.io/v1/clusterrepo"
 	"github.com/rancher/webhook/pkg/resources/cluster.cattle.io/v3/clusterauthtoken"
+	"github.com/rancher/webhook/pkg/resources/cluster.x-k8s.io/v1beta1/machine"
+	"github.com/rancher/webhook/pkg/resources/common"
 	nshandler "github.com/rancher/webhook/pkg/resources/core/v1/namespace"
+	"github.com/rancher/webhook/pkg/resources/core/v1/node"
 	"github.com/rancher/webhook/pkg/resources/core/v1/secret"
+	"github.com/rancher/webhook/pkg/resources/core/v1/service"
+	"github.com/rancher/webhook/pkg/resources/management.cattle.io/v3/authconfig"
 	managementCluster "github.com/rancher/webhook/pkg/resources/management.cattle.io/v3/cluster"
 	"github.com/rancher/webhook/pkg/resources/management.cattle.io/v3/clusterproxyconfig"
 	"github.com/rancher/webhook/pkg/resources/management.cattle.io/v3/clusterroletemplatebinding"
+	"github.com/rancher/webhook/pkg/resources/management.cattle.io/v3/clustertemplate"
+	"github.com/rancher/webhook/pkg/resources/management.cattle.io/v3/clustertemplaterevision"
 	"github.com/rancher/webhook/pkg/resources/management.cattle.io/v3/feature"
 	"github.com/rancher/webhook/pkg/resources/management.cattle.io/v3/fleetworkspace"
 	"github.com/rancher/webhook/pkg/resources/management.cattle.io/v3/globalrole"
 	"github.com/rancher/webhook/pkg/resources/management.cattle.io/v3/globalrolebinding"
+	"github.com/rancher/webhook/pkg/resources/management.cattle.io/v3/legacyresource"
 	"github.com/rancher/webhook/pkg/resources/management.cattle.io/v3/nodedriver"
+	"github.com/rancher/webhook/pkg/resources/management.cattle.io/v3/nodetemplate"
 	"github.com/rancher/webhook/pkg/resources/management.cattle.io/v3/podsecurityadmissionconfigurationtemplate"
 	"github.com/rancher/webhook/pkg/resources/management.cattle.io/v3/project"
 	"github.com/rancher/webhook/pkg/resources/management.cattle.io/v3/projectroletemplatebinding"
 	"github.com/rancher/webhook/pkg/resources/management.cattle.io/v3/roletemplate"
 	"github.com/rancher/webhook/pkg/resources/management.cattle.io/v3/setting"
 	"github.com/rancher/webhook/pkg/resources/management.cattle.io/v3/token"
+	"github.com/rancher/webhook/pkg/resources/management.cattle.io/v3/user"
 	"github.com/rancher/webhook/pkg/resources/management.cattle.io/v3/userattribute"
 	provisioningCluster "github.com/rancher/webhook/pkg/resources/provisioning.cattle.io/v1/cluster"
 	"github.com/rancher/webhook/pkg/resources/rbac.authorization.k8s.io/v1/clusterrole"
 	"github.com/rancher/webhook/pkg/resources/rbac.authorization.k8s.io/v1/clusterrolebinding"
 	"github.com/rancher/webhook/pkg/resources/rbac.authorization.k8s.io/v1/role"
 	"github.com/rancher/webhook/pkg/resources/rbac.authorization.k8s.io/v1/rolebinding"
+	"github.com/rancher/webhook/pkg/resources/resources.cattle.io/v1/backup"
+	"github.com/rancher/webhook/pkg/resources/resources.cattle.io/v1/restore"
 	"github.com/rancher/webhook/pkg/resources/rke-machine-config.cattle.io/v1/machineconfig"
+	"github.com/rancher/webhook/pkg/resources/rke.cattle.io/v1/etcdsnapshot"
+	corecontrollers "github.com/rancher/wrangler/v3/pkg/generated/controllers/core/v1"
 )
 
 // Validation returns a list of all ValidatingAdmissionHandlers used by the webhook.
 func Validation(clients *clients.Clients) ([]admission.ValidatingAdmissionHandler, error) {
+	handlers, mcmHandlers, nonMCMHandlers, err := validationHandlers(clients)
+	if err != nil {
+		return nil, err
+	}
+	if clients.MultiClusterManagement {
+		return append(handlers, mcmHandlers...), nil
+	}
+	return append(handlers, nonMCMHandlers...), nil
+}
+
+// validationHandlers returns the ValidatingAdmissionHandlers used regardless of multi-cluster management, those only
+// used when it is enabled, and those only used when it is disabled. The handlers which depend on multi-cluster
+// management are only created if clients.MultiClusterManagement is true. The handlers of the common.CreatorIDResources
+// check the creatorID of the objects they review.
+func validationHandlers(clients *clients.Clients) (handlers, mcmHandlers, nonMCMHandlers []admission.ValidatingAdmissionHandler, err error) {
 	var userCache v3.UserCache
+	var settingCache v3.SettingCache
+	var revisionCache v3.ClusterTemplateRevisionCache
+	var clusterCache v3.ClusterCache
+	var projectCache v3.ProjectCache
+	var namespaceCache corecontrollers.NamespaceCache
+	var nodePorts *common.NodePortQuota
 	if clients.MultiClusterManagement {
+		projectCache = clients.Management.Project().Cache()
+		namespaceCache = clients.Core.Namespace().Cache()
+		// the namespace validator indexes the namespaceCache by project
+		nodePorts = common.NewNodePortQuota(projectCache, namespaceCache, clients.Core.Service().Cache())
 		userCache = clients.Management.User().Cache()
+		settingCache = clients.Management.Setting().Cache()
+		revisionCache = clients.Management.ClusterTemplateRevision().Cache()
+		clusterCache = clients.Management.Cluster().Cache()
+	}
+
+	validateChartValues, err := provisioningCluster.ChartValuesValidationFromEnv()
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	uninstallServiceAccount, err := common.UninstallServiceAccountFromEnv()
+	if err != nil {
+		return nil, nil, nil, err
 	}
 
 	clusters := managementCluster.NewValidator(
-		clients.K8s.AuthorizationV1().SubjectAccessReviews(),
+		clients.SubjectAccessReviews,
 		clients.Management.PodSecurityAdmissionConfigurationTemplate().Cache(),
 		userCache,
+		settingCache,
+		revisionCache,
+		clusterCache,
+		clients.Dynamic,
 	)
 
-	handlers := []admission.ValidatingAdmissionHandler{
-		feature.NewValidator(),
+	handlers = []admission.ValidatingAdmissionHandler{
+		feature.NewValidator(clients.Provisioning.Cluster().Cache(), clients.Dynamic),
 		clusters,
-		provisioningCluster.NewProvisioningClusterValidator(clients),
+		provisioningCluster.NewProvisioningClusterValidator(clients, validateChartValues, uninstallServiceAccount),
 		machineconfig.NewValidator(),
-		nshandler.NewValidator(clients.K8s.AuthorizationV1().SubjectAccessReviews()),
-		clusterrepo.NewValidator(),
+		etcdsnapshot.NewValidator(clients.Provisioning.Cluster().Cache()),
+		nshandler.NewValidator(clients.SubjectAccessReviews, projectCache, namespaceCache, nodePorts, uninstallServiceAccount),
+		clusterrepo.NewValidator(clients.Core.Secret().Cache()),
 	}
 
 	if clients.MultiClusterManagement {
-		crtbResolver := resolvers.NewCRTBRuleResolver(clients.Management.ClusterRoleTemplateBinding().Cache(), clients.RoleTemplateResolver)
-		prtbResolver := resolvers.NewPRTBRuleResolver(clients.Management.ProjectRoleTemplateBinding().Cache(), clients.RoleTemplateResolver)
+		crtbResolver := clients.CRTBResolver
+		prtbResolver := clients.PRTBResolver
 		grbResolvers := resolvers.NewGRBRuleResolvers(clients.Management.GlobalRoleBinding().Cache(), clients.GlobalRoleResolver)
+		maxExpiration, err := globalrolebinding.MaxExpirationFromEnv()
+		if err != nil {
+			return nil, nil, nil, err
+		}
+		validateGRNamespaces, err := globalrole.ValidateNamespacesFromEnv()
+		if err != nil {
+			return nil, nil, nil, err
+		}
+		nodeDriverURLAllowList, err := nodedriver.URLAllowListFromEnv()
+		if err != nil {
+			return nil, nil, nil, err
+		}
+		var grNamespaceCache corecontrollers.NamespaceCache
+		if validateGRNamespaces {
+			grNamespaceCache = clients.Core.Namespace().Cache()
+		}
+		subjectValidator := common.NewBindingSubjectValidator(clients.Management.Feature().Cache(), clients.Management.User().Cache(),
+			clients.Management.AuthConfig().Cache())
 
-		handlers = append(
-			handlers,
+		mcmHandlers = []admission.ValidatingAdmissionHandler{
+			authconfig.NewValidator(),
 			clusterproxyconfig.NewValidator(clients.Management.ClusterProxyConfig().Cache()),
 			podsecurityadmissionconfigurationtemplate.NewValidator(clients.Management.Cluster().Cache(), clients.Provisioning.Cluster().Cache()),
-			globalrole.NewValidator(clients.DefaultResolver, grbResolvers, clients.K8s.AuthorizationV1().SubjectAccessReviews(), clients.GlobalRoleResolver),
-			globalrolebinding.NewValidator(clients.DefaultResolver, grbResolvers, clients.K8s.AuthorizationV1().SubjectAccessReviews(), clients.GlobalRoleResolver),
-			projectroletemplatebinding.NewValidator(prtbResolver, crtbResolver, clients.DefaultResolver, clients.RoleTemplateResolver, clients.Management.Cluster().Cache(), clients.Management.Project().Cache()),
-			clusterroletemplatebinding.NewValidator(crtbResolver, clients.DefaultResolver, clients.RoleTemplateResolver, clients.Management.GlobalRoleBinding().Cache(), clients.Management.Cluster().Cache()),
-			roletemplate.NewValidator(clients.DefaultResolver, clients.RoleTemplateResolver, clients.K8s.AuthorizationV1().SubjectAccessReviews(), clients.Management.GlobalRole().Cache()),
-			secret.NewValidator(clients.RBAC.Role().Cache(), clients.RBAC.RoleBinding().Cache()),
-			nodedriver.NewValidator(clients.Management.Node().Cache(), clients.Dynamic),
-			project.NewValidator(clients.Management.Cluster().Cache(), clients.Management.User().Cache()),
+			globalrole.NewValidator(clients.DefaultResolver, grbResolvers, clients.SubjectAccessReviews, clients.GlobalRoleResolver, grNamespaceCache),
+			globalrolebinding.NewValidator(clients.DefaultResolver, grbResolvers, clients.SubjectAccessReviews, clients.GlobalRoleResolver, maxExpiration),
+			projectroletemplatebinding.NewValidator(prtbResolver, crtbResolver, clients.DefaultResolver, clients.RoleTemplateResolver, clients.Management.Cluster().Cache(), clients.Management.Project().Cache(), clients.Management.Setting().Cache(), subjectValidator),
+			clusterroletemplatebinding.NewValidator(crtbResolver, clients.DefaultResolver, clients.RoleTemplateResolver, clients.Management.GlobalRoleBinding().Cache(), clients.Management.Cluster().Cache(), subjectValidator),
+			clustertemplate.NewValidator(clients.Management.Cluster().Cache(), clients.Management.ClusterTemplateRevision().Cache()),
+			clustertemplaterevision.NewValidator(clients.Management.Cluster().Cache()),
+			fleetworkspace.NewValidator(clients.Management.Cluster().Cache(), clients.Provisioning.Cluster().Cache(), clients.SubjectAccessReviews, uninstallServiceAccount),
+			roletemplate.NewValidator(clients.DefaultResolver, clients.RoleTemplateResolver, clients.SubjectAccessReviews, clients.Management.GlobalRole().Cache(),
+				clients.Management.ClusterRoleTemplateBinding().Cache(), clients.Management.ProjectRoleTemplateBinding().Cache(), clients.Management.Feature().Cache()),
+			service.NewValidator(namespaceCache, nodePorts),
+			secret.NewValidator(clients.RBAC.Role().Cache(), clients.RBAC.RoleBinding().Cache(), clients.SubjectAccessReviews),
+			nodedriver.NewValidator(clients.Management.Node().Cache(), clients.Management.NodeTemplate().Cache(), clients.Dynamic, nodeDriverURLAllowList),
+			nodetemplate.NewValidator(clients.SubjectAccessReviews),
+			node.NewValidator(clients.Management.Cluster().Cache(), clients.SubjectAccessReviews),
+			project.NewValidator(clients.Management.Cluster().Cache(), clients.Management.User().Cache(), clients.Management.Setting().Cache(), clients.SubjectAccessReviews),
 			role.NewValidator(),
 			rolebinding.NewValidator(),
-			setting.NewValidator(clients.Management.Cluster().Cache(), clients.Management.Setting().Cache()),
+			setting.NewValidator(clients.Management.Cluster().Cache(), clients.Management.Setting().Cache(), clients.Core.ConfigMap().Cache(), clients.SubjectAccessReviews),
 			token.NewValidator(),
-			userattribute.NewValidator(),
+			user.NewValidator(clients.Management.GlobalRoleBinding().Cache(), clients.Management.ClusterRoleTemplateBinding().Cache(),
+				clients.Management.ProjectRoleTemplateBinding().Cache(), clients.Management.Token(), clients.SubjectAccessReviews),
+			userattribute.NewValidator(clients.Management.Setting().Cache(), clients.SubjectAccessReviews),
 			clusterrole.NewValidator(),
 			clusterrolebinding.NewValidator(),
-		)
-	} else {
-		handlers = append(handlers, clusterauthtoken.NewValidator())
+			machine.NewValidator(),
+			backup.NewValidator(clients.Core.Secret().Cache()),
+			restore.NewValidator(clients.Core.Secret().Cache(), clients.Dynamic),
+		}
+		mcmHandlers = append(mcmHandlers, legacyresource.NewValidators(clients.Management.Setting().Cache())...)
 	}
+	nonMCMHandlers = []admission.ValidatingAdmissionHandler{clusterauthtoken.NewValidator()}
 
-	return handlers, nil
+	return common.WithCreatorIDCheck(handlers), common.WithCreatorIDCheck(mcmHandlers), common.WithCreatorIDCheck(nonMCMHandlers), nil
 }
 
 // Mutation returns a list of all MutatingAdmissionHandlers used by the webhook.
 func Mutation(clients *clients.Clients) ([]admission.MutatingAdmissionHandler, error) {
-	mutators := []admission.MutatingAdmissionHandler{
-		provisioningCluster.NewProvisioningClusterMutator(clients.Core.Secret(), clients.Management.PodSecurityAdmissionConfigurationTemplate().Cache()),
+	mutators, mcmMutators, err := mutationHandlers(clients)
+	if err != nil {
+		return nil, err
+	}
+	if clients.MultiClusterManagement {
+		return append(mutators, mcmMutators...), nil
+	}
+	return mutators, nil
+}
+
+// mutationHandlers returns the MutatingAdmissionHandlers used regardless of multi-cluster management and those only
+// used when it is enabled. The latter are only created if clients.MultiClusterManagement is true.
+func mutationHandlers(clients *clients.Clients) (mutators, mcmMutators []admission.MutatingAdmissionHandler, err error) {
+	mutators = []admission.MutatingAdmissionHandler{
+		provisioningCluster.NewProvisioningClusterMutator(clients.Core.Secret(), clients.Management.PodSecurityAdmissionConfigurationTemplate().Cache(), clients.Management.Setting().Cache()),
 		managementCluster.NewManagementClusterMutator(clients.Management.PodSecurityAdmissionConfigurationTemplate().Cache()),
 		fleetworkspace.NewMutator(clients),
 		&machineconfig.Mutator{},
@@ -97,10 +196,21 @@ func Mutation(clients *clients.Clients) ([]admission.MutatingAdmissionHandler, e
 
 	if clients.MultiClusterManagement {
 		secrets := secret.NewMutator(clients.RBAC.Role(), clients.RBAC.RoleBinding())
-		projects := project.NewMutator(clients.Management.RoleTemplate().Cache())
-		grbs := globalrolebinding.NewMutator(clients.Management.GlobalRole().Cache())
-		mutators = append(mutators, secrets, projects, grbs)
+		projects := project.NewMutator(clients.Management.RoleTemplate().Cache(), clients.Management.Setting().Cache(), clients.Management.Cluster().Cache())
+		grbs := globalrolebinding.NewMutator(clients.Management.GlobalRole().Cache(), clients.Management.AuthConfig().Cache())
+		maxGroupPrincipals, err := userattribute.MaxGroupPrincipalsFromEnv()
+		if err != nil {
+			return nil, nil, err
+		}
+		userAttributes := userattribute.NewMutator(maxGroupPrincipals)
+		namespaces := nshandler.NewMutator(clients.Management.Project().Cache())
+		requiredExemptions, err := podsecurityadmissionconfigurationtemplate.RequiredExemptionsFromEnv()
+		if err != nil {
+			return nil, nil, err
+		}
+		psacts := podsecurityadmissionconfigurationtemplate.NewMutator(requiredExemptions)
+		mcmMutators = []admission.MutatingAdmissionHandler{secrets, projects, grbs, userAttributes, namespaces, psacts}
 	}
 
-	return mutators, nil
+	return mutators, mcmMutators, nil
 }
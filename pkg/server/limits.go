@@ -0,0 +1,290 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rancher/webhook/pkg/admission"
+	"github.com/rancher/webhook/pkg/metrics"
+	"github.com/sirupsen/logrus"
+	"golang.org/x/time/rate"
+	admissionv1 "k8s.io/api/admission/v1"
+	authenticationv1 "k8s.io/api/authentication/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+const (
+	// MaxRequestBytesEnv is the environment variable setting the maximum size of an AdmissionReview in bytes.
+	MaxRequestBytesEnv = "CATTLE_WEBHOOK_MAX_REQUEST_BYTES"
+	// RateLimitQPSEnv is the environment variable setting the number of admission requests per second allowed for a
+	// single user. Rate limiting is disabled if unset or 0.
+	RateLimitQPSEnv = "CATTLE_WEBHOOK_RATE_LIMIT_QPS"
+	// RateLimitBurstEnv is the environment variable setting the number of admission requests a single user can make
+	// in a burst above RateLimitQPSEnv.
+	RateLimitBurstEnv = "CATTLE_WEBHOOK_RATE_LIMIT_BURST"
+
+	// The kube-apiserver limits request bodies to 3MiB, and an AdmissionReview can hold both the old and new object.
+	defaultMaxRequestBytes = 8 << 20
+	defaultRateLimitQPS    = 0
+	defaultRateLimitBurst  = 200
+
+	// limiterIdleTimeout is how long the rate limiter of a user is kept after the user's last request.
+	limiterIdleTimeout = 10 * time.Minute
+	retryAfterSeconds  = 1
+)
+
+// RequestLimits configures the limits applied to incoming admission requests.
+type RequestLimits struct {
+	// MaxRequestBytes is the maximum size of an AdmissionReview.
+	MaxRequestBytes int64
+	// QPS is the number of requests per second allowed for a single user. Rate limiting is disabled if 0. Requests made
+	// by controllers, as found by admission.IsController, are never rate limited.
+	QPS float64
+	// Burst is the number of requests a single user can make at once.
+	Burst int
+}
+
+// RequestLimitsFromEnv returns the RequestLimits set in the environment, using the defaults for unset values.
+func RequestLimitsFromEnv() (RequestLimits, error) {
+	limits := RequestLimits{
+		MaxRequestBytes: defaultMaxRequestBytes,
+		QPS:             defaultRateLimitQPS,
+		Burst:           defaultRateLimitBurst,
+	}
+	if value := os.Getenv(MaxRequestBytesEnv); value != "" {
+		parsed, err := strconv.ParseInt(value, 10, 64)
+		if err != nil || parsed <= 0 {
+			return limits, fmt.Errorf("invalid value '%s' for %s: must be a positive integer", value, MaxRequestBytesEnv)
+		}
+		limits.MaxRequestBytes = parsed
+	}
+	if value := os.Getenv(RateLimitQPSEnv); value != "" {
+		parsed, err := strconv.ParseFloat(value, 64)
+		if err != nil || parsed < 0 {
+			return limits, fmt.Errorf("invalid value '%s' for %s: must be a non-negative number", value, RateLimitQPSEnv)
+		}
+		limits.QPS = parsed
+	}
+	if value := os.Getenv(RateLimitBurstEnv); value != "" {
+		parsed, err := strconv.Atoi(value)
+		if err != nil || parsed <= 0 {
+			return limits, fmt.Errorf("invalid value '%s' for %s: must be a positive integer", value, RateLimitBurstEnv)
+		}
+		limits.Burst = parsed
+	}
+	return limits, nil
+}
+
+// requestLimiter rejects admission requests which are too large or come from users exceeding their rate limit.
+type requestLimiter struct {
+	limits RequestLimits
+	now    func() time.Time
+
+	mu          sync.Mutex
+	users       map[string]*userLimiter
+	lastCleanup time.Time
+}
+
+type userLimiter struct {
+	limiter  *rate.Limiter
+	lastSeen time.Time
+}
+
+func newRequestLimiter(limits RequestLimits) *requestLimiter {
+	return &requestLimiter{
+		limits: limits,
+		now:    time.Now,
+		users:  map[string]*userLimiter{},
+	}
+}
+
+// limitedReview holds the fields of an AdmissionReview needed to apply the limits.
+type limitedReview struct {
+	metav1.TypeMeta `json:",inline"`
+	Request         *struct {
+		UID      types.UID                 `json:"uid"`
+		UserInfo authenticationv1.UserInfo `json:"userInfo"`
+	} `json:"request"`
+}
+
+// middleware applies the limits to the requests of the given paths. The requests are rejected with an AdmissionReview
+// denying the request with a 413 or 429 status, so that the reason is returned to the client. Limited requests are
+// counted in metrics.AdmissionRequestsLimited.
+func (l *requestLimiter) middleware(pathPrefixes ...string) func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if !hasPrefix(r.URL.Path, pathPrefixes) {
+				next.ServeHTTP(w, r)
+				return
+			}
+			body, err := io.ReadAll(io.LimitReader(r.Body, l.limits.MaxRequestBytes+1))
+			if err != nil {
+				http.Error(w, fmt.Sprintf("failed to read request: %v", err), http.StatusBadRequest)
+				return
+			}
+			if int64(len(body)) > l.limits.MaxRequestBytes {
+				metrics.AdmissionRequestsLimited.WithLabelValues(metrics.LimitReasonSize).Inc()
+				review := partialReview(body)
+				logrus.Warnf("rejecting admission request %s from user %q: request exceeds %d bytes", review.uid, review.username, l.limits.MaxRequestBytes)
+				writeLimitedResponse(w, review, http.StatusRequestEntityTooLarge, metav1.StatusReasonRequestEntityTooLarge,
+					fmt.Sprintf("admission request exceeds the maximum size of %d bytes", l.limits.MaxRequestBytes))
+				return
+			}
+			r.Body = io.NopCloser(bytes.NewReader(body))
+
+			var review limitedReview
+			if l.limits.QPS == 0 || json.Unmarshal(body, &review) != nil || review.Request == nil {
+				// Malformed requests are rejected by the admission handlers.
+				next.ServeHTTP(w, r)
+				return
+			}
+			if admission.IsController(&admission.Request{AdmissionRequest: admissionv1.AdmissionRequest{UserInfo: review.Request.UserInfo}, Context: r.Context()}) {
+				// Rancher's and Kubernetes' controllers must not be slowed down by the requests of users
+				next.ServeHTTP(w, r)
+				return
+			}
+			username := review.Request.UserInfo.Username
+			if !l.allow(username) {
+				metrics.AdmissionRequestsLimited.WithLabelValues(metrics.LimitReasonRate).Inc()
+				logrus.Warnf("rejecting admission request %s from user %q: rate limit exceeded", review.Request.UID, username)
+				writeLimitedResponse(w, reviewInfo{apiVersion: review.APIVersion, kind: review.Kind, uid: review.Request.UID, username: username},
+					http.StatusTooManyRequests, metav1.StatusReasonTooManyRequests,
+					fmt.Sprintf("too many admission requests from user %q, retry later", username))
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// allow returns true if the user has not exceeded their rate limit.
+func (l *requestLimiter) allow(username string) bool {
+	now := l.now()
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if now.Sub(l.lastCleanup) > limiterIdleTimeout {
+		for user, limiter := range l.users {
+			if now.Sub(limiter.lastSeen) > limiterIdleTimeout {
+				delete(l.users, user)
+			}
+		}
+		l.lastCleanup = now
+	}
+	user, ok := l.users[username]
+	if !ok {
+		user = &userLimiter{limiter: rate.NewLimiter(rate.Limit(l.limits.QPS), l.limits.Burst)}
+		l.users[username] = user
+	}
+	user.lastSeen = now
+	return user.limiter.AllowN(now, 1)
+}
+
+// reviewInfo identifies the AdmissionReview a response is sent for.
+type reviewInfo struct {
+	apiVersion string
+	kind       string
+	uid        types.UID
+	username   string
+}
+
+// partialReview extracts the type, request UID and username from the beginning of a truncated AdmissionReview. The
+// fields which could not be found before the end of the body are left empty.
+func partialReview(body []byte) reviewInfo {
+	var info reviewInfo
+	_ = walkJSON(json.NewDecoder(bytes.NewReader(body)), "", func(path, value string) bool {
+		switch path {
+		case ".apiVersion":
+			info.apiVersion = value
+		case ".kind":
+			info.kind = value
+		case ".request.uid":
+			info.uid = types.UID(value)
+		case ".request.userInfo.username":
+			info.username = value
+		}
+		// the object, which may be large, comes after the user in the request
+		return info.username == ""
+	})
+	return info
+}
+
+var errStopWalk = errors.New("stop walking")
+
+// walkJSON calls visit with the path of each string value read from the decoder, until visit returns false or the
+// decoder fails.
+func walkJSON(decoder *json.Decoder, path string, visit func(path, value string) bool) error {
+	token, err := decoder.Token()
+	if err != nil {
+		return err
+	}
+	switch t := token.(type) {
+	case json.Delim:
+		for decoder.More() {
+			childPath := path + "[]"
+			if t == '{' {
+				key, err := decoder.Token()
+				if err != nil {
+					return err
+				}
+				childPath = fmt.Sprintf("%s.%v", path, key)
+			}
+			if err := walkJSON(decoder, childPath, visit); err != nil {
+				return err
+			}
+		}
+		// consume the closing delimiter
+		_, err = decoder.Token()
+		return err
+	case string:
+		if !visit(path, t) {
+			return errStopWalk
+		}
+	}
+	return nil
+}
+
+// writeLimitedResponse writes an AdmissionReview denying the request with the given status.
+func writeLimitedResponse(w http.ResponseWriter, info reviewInfo, code int32, reason metav1.StatusReason, message string) {
+	review := admissionv1.AdmissionReview{
+		TypeMeta: metav1.TypeMeta{APIVersion: info.apiVersion, Kind: info.kind},
+		Response: &admissionv1.AdmissionResponse{
+			UID:     info.uid,
+			Allowed: false,
+			Result: &metav1.Status{
+				Status:  metav1.StatusFailure,
+				Code:    code,
+				Reason:  reason,
+				Message: message,
+			},
+		},
+	}
+	if code == http.StatusTooManyRequests {
+		review.Response.Result.Details = &metav1.StatusDetails{RetryAfterSeconds: retryAfterSeconds}
+	}
+	if review.APIVersion == "" {
+		review.SetGroupVersionKind(admissionv1.SchemeGroupVersion.WithKind("AdmissionReview"))
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(review); err != nil {
+		logrus.Warnf("failed to encode response: %s", err)
+	}
+}
+
+func hasPrefix(path string, prefixes []string) bool {
+	for _, prefix := range prefixes {
+		if strings.HasPrefix(path, prefix) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,161 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	admissionv1 "k8s.io/api/admission/v1"
+	authenticationv1 "k8s.io/api/authentication/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	k8stypes "k8s.io/apimachinery/pkg/types"
+)
+
+func newReviewBody(t *testing.T, uid, username string, objectSize int) string {
+	t.Helper()
+	review := admissionv1.AdmissionReview{
+		TypeMeta: metav1.TypeMeta{APIVersion: "admission.k8s.io/v1", Kind: "AdmissionReview"},
+		Request: &admissionv1.AdmissionRequest{
+			UID:       k8stypes.UID("uid-" + uid),
+			Operation: admissionv1.Create,
+			UserInfo:  authenticationv1.UserInfo{Username: username, Groups: []string{"system:authenticated"}},
+			Object:    runtime.RawExtension{Raw: []byte(`{"data":"` + strings.Repeat("a", objectSize) + `"}`)},
+		},
+	}
+	body, err := json.Marshal(review)
+	require.NoError(t, err)
+	return string(body)
+}
+
+func TestRequestLimitsFromEnv(t *testing.T) {
+	tests := []struct {
+		name    string
+		env     map[string]string
+		want    RequestLimits
+		wantErr bool
+	}{
+		{
+			name: "defaults",
+			want: RequestLimits{MaxRequestBytes: defaultMaxRequestBytes, QPS: defaultRateLimitQPS, Burst: defaultRateLimitBurst},
+		},
+		{
+			name: "custom values",
+			env:  map[string]string{MaxRequestBytesEnv: "1024", RateLimitQPSEnv: "0.5", RateLimitBurstEnv: "5"},
+			want: RequestLimits{MaxRequestBytes: 1024, QPS: 0.5, Burst: 5},
+		},
+		{
+			name: "rate limiting disabled",
+			env:  map[string]string{RateLimitQPSEnv: "0"},
+			want: RequestLimits{MaxRequestBytes: defaultMaxRequestBytes, QPS: 0, Burst: defaultRateLimitBurst},
+		},
+		{name: "invalid size", env: map[string]string{MaxRequestBytesEnv: "0"}, wantErr: true},
+		{name: "invalid qps", env: map[string]string{RateLimitQPSEnv: "-1"}, wantErr: true},
+		{name: "invalid burst", env: map[string]string{RateLimitBurstEnv: "many"}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for _, key := range []string{MaxRequestBytesEnv, RateLimitQPSEnv, RateLimitBurstEnv} {
+				t.Setenv(key, tt.env[key])
+			}
+			got, err := RequestLimitsFromEnv()
+			if tt.wantErr {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestRequestLimiterMiddleware(t *testing.T) {
+	t.Parallel()
+	limiter := newRequestLimiter(RequestLimits{MaxRequestBytes: 1024, QPS: 1, Burst: 2})
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	limiter.now = func() time.Time { return now }
+
+	var handled []string
+	handler := limiter.middleware(validationPath)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		review := admissionv1.AdmissionReview{}
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&review))
+		handled = append(handled, string(review.Request.UID))
+		w.WriteHeader(http.StatusOK)
+	}))
+	send := func(path, body string) *admissionv1.AdmissionResponse {
+		recorder := httptest.NewRecorder()
+		handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodPost, path, strings.NewReader(body)))
+		if recorder.Body.Len() == 0 {
+			return nil
+		}
+		review := admissionv1.AdmissionReview{}
+		require.NoError(t, json.NewDecoder(recorder.Body).Decode(&review))
+		assert.Equal(t, "AdmissionReview", review.Kind)
+		return review.Response
+	}
+
+	// requests within the burst are handled
+	assert.Nil(t, send(validationPath+"/globalroles", newReviewBody(t, "1", "controller", 10)))
+	assert.Nil(t, send(validationPath+"/globalroles", newReviewBody(t, "2", "controller", 10)))
+
+	// the next request from the same user is rate limited
+	response := send(validationPath+"/globalroles", newReviewBody(t, "3", "controller", 10))
+	require.NotNil(t, response)
+	assert.False(t, response.Allowed)
+	assert.Equal(t, "uid-3", string(response.UID))
+	assert.Equal(t, int32(http.StatusTooManyRequests), response.Result.Code)
+	assert.Equal(t, metav1.StatusReasonTooManyRequests, response.Result.Reason)
+	assert.Contains(t, response.Result.Message, `"controller"`)
+
+	// other users have their own limit
+	assert.Nil(t, send(validationPath+"/globalroles", newReviewBody(t, "4", "user", 10)))
+
+	// the limit is refilled over time
+	now = now.Add(time.Second)
+	assert.Nil(t, send(validationPath+"/globalroles", newReviewBody(t, "5", "controller", 10)))
+
+	// requests which are too large are rejected regardless of the rate limit
+	response = send(validationPath+"/globalroles", newReviewBody(t, "6", "user", 2048))
+	require.NotNil(t, response)
+	assert.False(t, response.Allowed)
+	assert.Equal(t, "uid-6", string(response.UID))
+	assert.Equal(t, int32(http.StatusRequestEntityTooLarge), response.Result.Code)
+	assert.Equal(t, metav1.StatusReasonRequestEntityTooLarge, response.Result.Reason)
+
+	// controllers are never rate limited
+	for _, uid := range []string{"c1", "c2", "c3"} {
+		assert.Nil(t, send(validationPath+"/globalroles", newReviewBody(t, uid, "system:kube-controller-manager", 10)))
+	}
+
+	// other paths are not limited
+	assert.Nil(t, send("/healthz", newReviewBody(t, "7", "controller", 2048)))
+
+	assert.Equal(t, []string{"uid-1", "uid-2", "uid-4", "uid-5", "uid-c1", "uid-c2", "uid-c3", "uid-7"}, handled)
+}
+
+func TestRequestLimiterForgetsIdleUsers(t *testing.T) {
+	t.Parallel()
+	limiter := newRequestLimiter(RequestLimits{MaxRequestBytes: 1024, QPS: 1, Burst: 1})
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	limiter.now = func() time.Time { return now }
+
+	assert.True(t, limiter.allow("idle"))
+	now = now.Add(limiterIdleTimeout + time.Second)
+	assert.True(t, limiter.allow("active"))
+	assert.NotContains(t, limiter.users, "idle")
+	assert.Contains(t, limiter.users, "active")
+}
+
+func TestPartialReview(t *testing.T) {
+	t.Parallel()
+	body := newReviewBody(t, "1", "controller", 4096)
+	info := partialReview([]byte(body[:len(body)/2]))
+	assert.Equal(t, reviewInfo{apiVersion: "admission.k8s.io/v1", kind: "AdmissionReview", uid: "uid-1", username: "controller"}, info)
+
+	assert.Equal(t, reviewInfo{}, partialReview([]byte(`{"request":`)))
+}
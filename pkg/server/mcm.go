@@ -0,0 +1,197 @@
+package server
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"sync/atomic"
+
+	v3 "github.com/rancher/rancher/pkg/apis/management.cattle.io/v3"
+	"github.com/rancher/webhook/pkg/admission"
+	"github.com/rancher/webhook/pkg/clients"
+	"github.com/rancher/webhook/pkg/resources/common"
+	"github.com/sirupsen/logrus"
+	admissionv1 "k8s.io/api/admission/v1"
+)
+
+const (
+	// DynamicMCMEnv is the environment variable used to make the webhook follow the multi-cluster-management Feature at
+	// runtime instead of only reading ENABLE_MCM on startup.
+	DynamicMCMEnv = "CATTLE_WEBHOOK_DYNAMIC_MCM"
+
+	mcmFeature = "multi-cluster-management"
+)
+
+// DynamicMCMFromEnv returns the value of DynamicMCMEnv, which defaults to false.
+func DynamicMCMFromEnv() (bool, error) {
+	value := os.Getenv(DynamicMCMEnv)
+	if value == "" {
+		return false, nil
+	}
+	parsed, err := strconv.ParseBool(value)
+	if err != nil {
+		return false, fmt.Errorf("invalid value '%s' for %s: must be a boolean", value, DynamicMCMEnv)
+	}
+	return parsed, nil
+}
+
+// mcmToggle tracks whether multi-cluster management is enabled by watching the multi-cluster-management Feature.
+type mcmToggle struct {
+	enabled atomic.Bool
+	// onChange is called whenever the effective value of the feature changes.
+	onChange func()
+}
+
+func newMCMToggle(enabled bool, onChange func()) *mcmToggle {
+	toggle := &mcmToggle{onChange: onChange}
+	toggle.enabled.Store(enabled)
+	return toggle
+}
+
+// Enabled returns true if multi-cluster management is currently enabled.
+func (m *mcmToggle) Enabled() bool {
+	return m.enabled.Load()
+}
+
+// Disabled returns true if multi-cluster management is currently disabled.
+func (m *mcmToggle) Disabled() bool {
+	return !m.enabled.Load()
+}
+
+// sync updates the toggle from the multi-cluster-management Feature. The last known value is kept if the feature is
+// deleted.
+func (m *mcmToggle) sync(_ string, feature *v3.Feature) (*v3.Feature, error) {
+	if feature == nil || feature.Name != mcmFeature {
+		return feature, nil
+	}
+	enabled := common.FeatureEffectiveValue(feature)
+	if m.enabled.Swap(enabled) != enabled {
+		logrus.Infof("[mcmToggle] multi-cluster management is now enabled=%t, updating webhook configurations", enabled)
+		m.onChange()
+	}
+	return feature, nil
+}
+
+// toggleable is implemented by handlers which can be enabled and disabled at runtime. Disabled handlers are left out
+// of the webhook configurations.
+type toggleable interface {
+	Enabled() bool
+}
+
+// isEnabled returns false if the handler is toggleable and currently disabled.
+func isEnabled(handler any) bool {
+	if t, ok := handler.(toggleable); ok {
+		return t.Enabled()
+	}
+	return true
+}
+
+// toggledValidator is a ValidatingAdmissionHandler which is only used while enabled returns true. Requests received
+// while disabled, for example before the webhook configuration was updated, are allowed as if the handler wasn't
+// registered.
+type toggledValidator struct {
+	admission.ValidatingAdmissionHandler
+	enabled func() bool
+}
+
+// Enabled returns true if the handler is currently enabled.
+func (t *toggledValidator) Enabled() bool {
+	return t.enabled()
+}
+
+// Admitters returns the wrapped handler's admitters, which allow all requests while the handler is disabled.
+func (t *toggledValidator) Admitters() []admission.Admitter {
+	admitters := t.ValidatingAdmissionHandler.Admitters()
+	toggled := make([]admission.Admitter, 0, len(admitters))
+	for _, admitter := range admitters {
+		toggled = append(toggled, &toggledAdmitter{Admitter: admitter, enabled: t.enabled})
+	}
+	return toggled
+}
+
+// toggledSubresourceValidator keeps the admission.SubresourceValidator implementation of the wrapped handler.
+type toggledSubresourceValidator struct {
+	*toggledValidator
+	subresources admission.SubresourceValidator
+}
+
+// Subresources returns the subresources of the wrapped handler.
+func (t *toggledSubresourceValidator) Subresources() []string {
+	return t.subresources.Subresources()
+}
+
+// toggledMutator is a MutatingAdmissionHandler which is only used while enabled returns true.
+type toggledMutator struct {
+	admission.MutatingAdmissionHandler
+	enabled func() bool
+}
+
+// Enabled returns true if the handler is currently enabled.
+func (t *toggledMutator) Enabled() bool {
+	return t.enabled()
+}
+
+// Admit handles the request with the wrapped handler while enabled, and allows it unchanged otherwise.
+func (t *toggledMutator) Admit(request *admission.Request) (*admissionv1.AdmissionResponse, error) {
+	if !t.enabled() {
+		return admission.ResponseAllowed(), nil
+	}
+	return t.MutatingAdmissionHandler.Admit(request)
+}
+
+type toggledAdmitter struct {
+	admission.Admitter
+	enabled func() bool
+}
+
+// Admit handles the request with the wrapped admitter while enabled, and allows it otherwise.
+func (t *toggledAdmitter) Admit(request *admission.Request) (*admissionv1.AdmissionResponse, error) {
+	if !t.enabled() {
+		return admission.ResponseAllowed(), nil
+	}
+	return t.Admitter.Admit(request)
+}
+
+// toggleValidators wraps the validators so they are only used while enabled returns true.
+func toggleValidators(validators []admission.ValidatingAdmissionHandler, enabled func() bool) []admission.ValidatingAdmissionHandler {
+	toggled := make([]admission.ValidatingAdmissionHandler, 0, len(validators))
+	for _, validator := range validators {
+		v := &toggledValidator{ValidatingAdmissionHandler: validator, enabled: enabled}
+		if subresourceValidator, ok := validator.(admission.SubresourceValidator); ok {
+			toggled = append(toggled, &toggledSubresourceValidator{toggledValidator: v, subresources: subresourceValidator})
+			continue
+		}
+		toggled = append(toggled, v)
+	}
+	return toggled
+}
+
+// toggleMutators wraps the mutators so they are only used while enabled returns true.
+func toggleMutators(mutators []admission.MutatingAdmissionHandler, enabled func() bool) []admission.MutatingAdmissionHandler {
+	toggled := make([]admission.MutatingAdmissionHandler, 0, len(mutators))
+	for _, mutator := range mutators {
+		toggled = append(toggled, &toggledMutator{MutatingAdmissionHandler: mutator, enabled: enabled})
+	}
+	return toggled
+}
+
+// dynamicMCMHandlers returns all the handlers of the webhook, with those depending on whether multi-cluster management
+// is enabled toggled by the toggle. wrapValidators is applied to the validators before they are toggled.
+func dynamicMCMHandlers(clients *clients.Clients, toggle *mcmToggle,
+	wrapValidators func([]admission.ValidatingAdmissionHandler) []admission.ValidatingAdmissionHandler,
+) ([]admission.ValidatingAdmissionHandler, []admission.MutatingAdmissionHandler, error) {
+	validators, mcmValidators, nonMCMValidators, err := validationHandlers(clients)
+	if err != nil {
+		return nil, nil, err
+	}
+	validators = wrapValidators(validators)
+	validators = append(validators, toggleValidators(wrapValidators(mcmValidators), toggle.Enabled)...)
+	validators = append(validators, toggleValidators(wrapValidators(nonMCMValidators), toggle.Disabled)...)
+
+	mutators, mcmMutators, err := mutationHandlers(clients)
+	if err != nil {
+		return nil, nil, err
+	}
+	mutators = append(mutators, toggleMutators(mcmMutators, toggle.Enabled)...)
+	return validators, mutators, nil
+}
@@ -0,0 +1,128 @@
+package server
+
+import (
+	"testing"
+
+	v3 "github.com/rancher/rancher/pkg/apis/management.cattle.io/v3"
+	"github.com/rancher/webhook/pkg/admission"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	admissionv1 "k8s.io/api/admission/v1"
+	admissionregistrationv1 "k8s.io/api/admissionregistration/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+func TestDynamicMCMFromEnv(t *testing.T) {
+	tests := []struct {
+		value   string
+		want    bool
+		wantErr bool
+	}{
+		{value: "", want: false},
+		{value: "true", want: true},
+		{value: "false", want: false},
+		{value: "sometimes", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			t.Setenv(DynamicMCMEnv, tt.value)
+			got, err := DynamicMCMFromEnv()
+			if tt.wantErr {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestMCMToggleSync(t *testing.T) {
+	t.Parallel()
+	changes := 0
+	toggle := newMCMToggle(true, func() { changes++ })
+	feature := func(name string, value bool) *v3.Feature {
+		return &v3.Feature{ObjectMeta: metav1.ObjectMeta{Name: name}, Spec: v3.FeatureSpec{Value: admission.Ptr(value)}}
+	}
+
+	_, err := toggle.sync("", feature(mcmFeature, true))
+	require.NoError(t, err)
+	assert.True(t, toggle.Enabled())
+	assert.Equal(t, 0, changes)
+
+	_, err = toggle.sync("", feature("harvester", false))
+	require.NoError(t, err)
+	assert.True(t, toggle.Enabled())
+	assert.Equal(t, 0, changes)
+
+	_, err = toggle.sync("", feature(mcmFeature, false))
+	require.NoError(t, err)
+	assert.False(t, toggle.Enabled())
+	assert.True(t, toggle.Disabled())
+	assert.Equal(t, 1, changes)
+
+	// a deleted feature keeps the last known value
+	_, err = toggle.sync(mcmFeature, nil)
+	require.NoError(t, err)
+	assert.False(t, toggle.Enabled())
+	assert.Equal(t, 1, changes)
+}
+
+func TestToggledHandlers(t *testing.T) {
+	t.Parallel()
+	enabled := true
+	validators := toggleValidators([]admission.ValidatingAdmissionHandler{&denyingHandler{}}, func() bool { return enabled })
+	mutators := toggleMutators([]admission.MutatingAdmissionHandler{&denyingHandler{}}, func() bool { return enabled })
+	require.Len(t, validators, 1)
+	require.Len(t, mutators, 1)
+	request := &admission.Request{}
+
+	assert.True(t, isEnabled(validators[0]))
+	assert.True(t, isEnabled(mutators[0]))
+	response, err := validators[0].Admitters()[0].Admit(request)
+	require.NoError(t, err)
+	assert.False(t, response.Allowed)
+	response, err = mutators[0].Admit(request)
+	require.NoError(t, err)
+	assert.False(t, response.Allowed)
+
+	enabled = false
+	assert.False(t, isEnabled(validators[0]))
+	assert.False(t, isEnabled(mutators[0]))
+	response, err = validators[0].Admitters()[0].Admit(request)
+	require.NoError(t, err)
+	assert.True(t, response.Allowed)
+	response, err = mutators[0].Admit(request)
+	require.NoError(t, err)
+	assert.True(t, response.Allowed)
+
+	assert.True(t, isEnabled(&denyingHandler{}), "handlers which aren't toggled are always enabled")
+}
+
+// denyingHandler is a validating and mutating handler which denies all requests.
+type denyingHandler struct{}
+
+func (d *denyingHandler) GVR() schema.GroupVersionResource {
+	return schema.GroupVersionResource{Group: "management.cattle.io", Version: "v3", Resource: "nodedrivers"}
+}
+
+func (d *denyingHandler) Operations() []admissionregistrationv1.OperationType {
+	return []admissionregistrationv1.OperationType{admissionregistrationv1.Create}
+}
+
+func (d *denyingHandler) ValidatingWebhook(clientConfig admissionregistrationv1.WebhookClientConfig) []admissionregistrationv1.ValidatingWebhook {
+	return []admissionregistrationv1.ValidatingWebhook{*admission.NewDefaultValidatingWebhook(d, clientConfig, admissionregistrationv1.ClusterScope, d.Operations())}
+}
+
+func (d *denyingHandler) MutatingWebhook(clientConfig admissionregistrationv1.WebhookClientConfig) []admissionregistrationv1.MutatingWebhook {
+	return []admissionregistrationv1.MutatingWebhook{*admission.NewDefaultMutatingWebhook(d, clientConfig, admissionregistrationv1.ClusterScope, d.Operations())}
+}
+
+func (d *denyingHandler) Admitters() []admission.Admitter {
+	return []admission.Admitter{d}
+}
+
+func (d *denyingHandler) Admit(_ *admission.Request) (*admissionv1.AdmissionResponse, error) {
+	return admission.ResponseBadRequest("denied"), nil
+}
@@ -0,0 +1,344 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/rand"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/rancher/webhook/pkg/metrics"
+	"github.com/sirupsen/logrus"
+	admissionv1 "k8s.io/api/admission/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+const (
+	// ShadowURLEnv is the environment variable setting the base URL of a shadow webhook, such as
+	// "https://rancher-webhook-shadow.cattle-system.svc", to which admission requests are mirrored. Mirroring is
+	// disabled if unset.
+	ShadowURLEnv = "CATTLE_WEBHOOK_SHADOW_URL"
+	// ShadowPercentEnv is the environment variable setting the percentage of admission requests mirrored to the
+	// shadow webhook.
+	ShadowPercentEnv = "CATTLE_WEBHOOK_SHADOW_PERCENT"
+	// ShadowCAFileEnv is the environment variable setting the path of the PEM encoded CA bundle used to verify the
+	// certificate of the shadow webhook. The system's CAs are used if unset.
+	ShadowCAFileEnv = "CATTLE_WEBHOOK_SHADOW_CA_FILE"
+	// ShadowCertFileEnv and ShadowKeyFileEnv are the environment variables setting the paths of the PEM encoded client
+	// certificate and key presented to the shadow webhook, if it requires client certificates.
+	ShadowCertFileEnv = "CATTLE_WEBHOOK_SHADOW_CERT_FILE"
+	ShadowKeyFileEnv  = "CATTLE_WEBHOOK_SHADOW_KEY_FILE"
+
+	defaultShadowPercent = 10
+	shadowTimeout        = 10 * time.Second
+	// maxShadowInFlight bounds the number of requests being mirrored at once. Requests above it are not mirrored, so
+	// that a slow shadow webhook can not exhaust the webhook's memory.
+	maxShadowInFlight = 32
+)
+
+// ShadowConfig configures the mirroring of admission requests to a shadow webhook.
+type ShadowConfig struct {
+	// URL is the base URL of the shadow webhook. Mirroring is disabled if empty.
+	URL string
+	// Percent is the percentage of admission requests mirrored to the shadow webhook.
+	Percent float64
+	// CAFile is the path of the CA bundle used to verify the shadow webhook's certificate.
+	CAFile string
+	// CertFile and KeyFile are the paths of the client certificate and key presented to the shadow webhook.
+	CertFile string
+	KeyFile  string
+}
+
+// ShadowConfigFromEnv returns the ShadowConfig set in the environment, using the defaults for unset values.
+func ShadowConfigFromEnv() (ShadowConfig, error) {
+	config := ShadowConfig{
+		URL:      strings.TrimSuffix(os.Getenv(ShadowURLEnv), "/"),
+		Percent:  defaultShadowPercent,
+		CAFile:   os.Getenv(ShadowCAFileEnv),
+		CertFile: os.Getenv(ShadowCertFileEnv),
+		KeyFile:  os.Getenv(ShadowKeyFileEnv),
+	}
+	if value := os.Getenv(ShadowPercentEnv); value != "" {
+		parsed, err := strconv.ParseFloat(value, 64)
+		if err != nil || parsed < 0 || parsed > 100 {
+			return config, fmt.Errorf("invalid value '%s' for %s: must be a number between 0 and 100", value, ShadowPercentEnv)
+		}
+		config.Percent = parsed
+	}
+	if config.URL != "" && !strings.HasPrefix(config.URL, "https://") {
+		return config, fmt.Errorf("invalid value '%s' for %s: must be an https URL", config.URL, ShadowURLEnv)
+	}
+	if (config.CertFile == "") != (config.KeyFile == "") {
+		return config, fmt.Errorf("%s and %s must be set together", ShadowCertFileEnv, ShadowKeyFileEnv)
+	}
+	return config, nil
+}
+
+// requestMirror asynchronously forwards a sample of the admission requests to a shadow webhook running another build
+// of the webhook, and reports the requests for which the shadow webhook made another decision. The responses of the
+// shadow webhook are never returned to the API server.
+type requestMirror struct {
+	url      string
+	client   *http.Client
+	sample   func() bool
+	inFlight chan struct{}
+}
+
+// newRequestMirror returns the requestMirror for the given config, or nil if mirroring is disabled.
+func newRequestMirror(config ShadowConfig) (*requestMirror, error) {
+	if config.URL == "" || config.Percent == 0 {
+		return nil, nil
+	}
+	tlsConfig := &tls.Config{MinVersion: tls.VersionTLS12}
+	if config.CAFile != "" {
+		pem, err := os.ReadFile(config.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read the shadow webhook CA bundle: %w", err)
+		}
+		tlsConfig.RootCAs = x509.NewCertPool()
+		if !tlsConfig.RootCAs.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates found in the shadow webhook CA bundle %s", config.CAFile)
+		}
+	}
+	if config.CertFile != "" {
+		cert, err := tls.LoadX509KeyPair(config.CertFile, config.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load the shadow webhook client certificate: %w", err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+	logrus.Infof("[shadow] mirroring %.4g%% of admission requests to %s", config.Percent, config.URL)
+	return &requestMirror{
+		url: config.URL,
+		client: &http.Client{
+			Timeout:   shadowTimeout,
+			Transport: &http.Transport{TLSClientConfig: tlsConfig},
+		},
+		sample:   func() bool { return rand.Float64()*100 < config.Percent },
+		inFlight: make(chan struct{}, maxShadowInFlight),
+	}, nil
+}
+
+// middleware mirrors a sample of the requests of the given paths once they have been handled.
+func (m *requestMirror) middleware(pathPrefixes ...string) func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if !hasPrefix(r.URL.Path, pathPrefixes) || !m.sample() {
+				next.ServeHTTP(w, r)
+				return
+			}
+			body, err := io.ReadAll(r.Body)
+			if err != nil {
+				http.Error(w, fmt.Sprintf("failed to read request: %v", err), http.StatusBadRequest)
+				return
+			}
+			r.Body = io.NopCloser(bytes.NewReader(body))
+			recorder := &responseRecorder{ResponseWriter: w}
+			next.ServeHTTP(recorder, r)
+
+			select {
+			case m.inFlight <- struct{}{}:
+			default:
+				if review := decodeReview(body); review != nil && review.Request != nil {
+					observeShadowRequest(r.URL.Path, review.Request, metrics.ShadowResultDropped)
+				}
+				return
+			}
+			go func() {
+				defer func() { <-m.inFlight }()
+				m.mirror(r.URL.Path, body, recorder.body.Bytes())
+			}()
+		})
+	}
+}
+
+// mirror sends the sanitized request to the shadow webhook and compares its decision with the given response.
+func (m *requestMirror) mirror(path string, body, response []byte) {
+	review := decodeReview(body)
+	primary := decodeReview(response)
+	if review == nil || review.Request == nil || primary == nil || primary.Response == nil {
+		// the request was rejected before being handled, there is no decision to compare
+		return
+	}
+	request := review.Request
+	if !mirrorable(request.Kind) {
+		return
+	}
+	sanitizeRequest(request)
+	shadowBody, err := json.Marshal(review)
+	if err != nil {
+		logrus.Warnf("[shadow] failed to encode admission request %s: %v", request.UID, err)
+		return
+	}
+
+	shadow, err := m.send(path, shadowBody)
+	if err != nil {
+		logrus.Warnf("[shadow] failed to mirror admission request %s to %s: %v", request.UID, m.url+path, err)
+		observeShadowRequest(path, request, metrics.ShadowResultError)
+		return
+	}
+	if diff := compareResponses(primary.Response, shadow); diff != "" {
+		logrus.Warnf("[shadow] decision diverged for %s of %s %s/%s by %q (request %s): %s", request.Operation,
+			request.Resource.String(), request.Namespace, request.Name, request.UserInfo.Username, request.UID, diff)
+		observeShadowRequest(path, request, metrics.ShadowResultDiverged)
+		return
+	}
+	observeShadowRequest(path, request, metrics.ShadowResultMatch)
+}
+
+// send posts the AdmissionReview to the shadow webhook and returns its response.
+func (m *requestMirror) send(path string, body []byte) (*admissionv1.AdmissionResponse, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), shadowTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, m.url+path, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := m.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status %s", resp.Status)
+	}
+	review := &admissionv1.AdmissionReview{}
+	if err := json.NewDecoder(resp.Body).Decode(review); err != nil {
+		return nil, fmt.Errorf("failed to decode response: %w", err)
+	}
+	if review.Response == nil {
+		return nil, fmt.Errorf("response holds no AdmissionResponse")
+	}
+	return review.Response, nil
+}
+
+// compareResponses returns a description of the differences between the decisions of the webhook and the shadow
+// webhook, or an empty string if they made the same decision.
+func compareResponses(primary, shadow *admissionv1.AdmissionResponse) string {
+	var diffs []string
+	if primary.Allowed != shadow.Allowed {
+		diffs = append(diffs, fmt.Sprintf("allowed %t, shadow allowed %t (%s)", primary.Allowed, shadow.Allowed, responseMessage(shadow)))
+	} else if !primary.Allowed && responseCode(primary) != responseCode(shadow) {
+		diffs = append(diffs, fmt.Sprintf("denied with code %d, shadow denied with code %d", responseCode(primary), responseCode(shadow)))
+	}
+	if !bytes.Equal(primary.Patch, shadow.Patch) {
+		diffs = append(diffs, fmt.Sprintf("patch %s, shadow patch %s", primary.Patch, shadow.Patch))
+	}
+	return strings.Join(diffs, "; ")
+}
+
+func responseCode(response *admissionv1.AdmissionResponse) int32 {
+	if response.Result == nil {
+		return 0
+	}
+	return response.Result.Code
+}
+
+func responseMessage(response *admissionv1.AdmissionResponse) string {
+	if response.Result == nil {
+		return ""
+	}
+	return response.Result.Message
+}
+
+// sensitiveFields are the top-level fields holding credentials of the admitted kinds which carry some. They are
+// removed from the objects of mirrored requests, along with their last-applied-configuration annotation, which holds a
+// copy of them.
+var sensitiveFields = map[schema.GroupKind][]string{
+	{Kind: "Secret"}: {"data", "stringData"},
+	{Group: "management.cattle.io", Kind: "Token"}:         {"token"},
+	{Group: "management.cattle.io", Kind: "User"}:          {"password"},
+	{Group: "cluster.cattle.io", Kind: "ClusterAuthToken"}: {"hash"},
+	{Group: "management.cattle.io", Kind: "AuthConfig"}: {
+		"applicationSecret", "clientSecret", "oauthCredential", "privateKey", "serviceAccountCredential",
+		"serviceAccountPassword", "spKey",
+	},
+}
+
+// unmirroredKinds are the admitted kinds which are never mirrored, as their credentials are held in driver specific
+// fields which can't be listed.
+var unmirroredKinds = map[schema.GroupKind]bool{
+	{Group: "management.cattle.io", Kind: "NodeTemplate"}: true,
+}
+
+// unmirroredGroups are the groups whose kinds are never mirrored, for the same reason as unmirroredKinds.
+var unmirroredGroups = map[string]bool{
+	"rke-machine-config.cattle.io": true,
+}
+
+// mirrorable returns whether requests for objects of the kind may leave the webhook.
+func mirrorable(kind metav1.GroupVersionKind) bool {
+	return !unmirroredGroups[kind.Group] && !unmirroredKinds[schema.GroupKind{Group: kind.Group, Kind: kind.Kind}]
+}
+
+// sanitizeRequest removes the sensitive fields of the request before it leaves the webhook: the credentials held by the
+// objects, listed in sensitiveFields, and the extra fields of the user, which may hold credentials. Objects which can't
+// be decoded are removed.
+func sanitizeRequest(request *admissionv1.AdmissionRequest) {
+	request.UserInfo.Extra = nil
+	fields, ok := sensitiveFields[schema.GroupKind{Group: request.Kind.Group, Kind: request.Kind.Kind}]
+	if !ok {
+		return
+	}
+	for _, raw := range []*[]byte{&request.Object.Raw, &request.OldObject.Raw} {
+		if len(*raw) == 0 {
+			continue
+		}
+		var object map[string]any
+		if err := json.Unmarshal(*raw, &object); err != nil {
+			*raw = nil
+			continue
+		}
+		for _, field := range fields {
+			delete(object, field)
+		}
+		if metadata, ok := object["metadata"].(map[string]any); ok {
+			if annotations, ok := metadata["annotations"].(map[string]any); ok {
+				delete(annotations, "kubectl.kubernetes.io/last-applied-configuration")
+			}
+		}
+		sanitized, err := json.Marshal(object)
+		if err != nil {
+			*raw = nil
+			continue
+		}
+		*raw = sanitized
+	}
+}
+
+func decodeReview(data []byte) *admissionv1.AdmissionReview {
+	review := &admissionv1.AdmissionReview{}
+	if err := json.Unmarshal(data, review); err != nil {
+		return nil
+	}
+	return review
+}
+
+func observeShadowRequest(path string, request *admissionv1.AdmissionRequest, result string) {
+	webhookType := metrics.WebhookTypeValidating
+	if strings.HasPrefix(path, mutationPath) {
+		webhookType = metrics.WebhookTypeMutating
+	}
+	metrics.ShadowRequests.WithLabelValues(webhookType, request.Resource.Group, request.Resource.Version, request.Resource.Resource, result).Inc()
+}
+
+// responseRecorder keeps a copy of the response body written to the wrapped ResponseWriter.
+type responseRecorder struct {
+	http.ResponseWriter
+	body bytes.Buffer
+}
+
+func (r *responseRecorder) Write(data []byte) (int, error) {
+	r.body.Write(data)
+	return r.ResponseWriter.Write(data)
+}
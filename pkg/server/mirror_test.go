@@ -0,0 +1,210 @@
+package server
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/rancher/webhook/pkg/metrics"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	admissionv1 "k8s.io/api/admission/v1"
+	authenticationv1 "k8s.io/api/authentication/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	k8stypes "k8s.io/apimachinery/pkg/types"
+)
+
+func TestShadowConfigFromEnv(t *testing.T) {
+	tests := []struct {
+		name    string
+		env     map[string]string
+		want    ShadowConfig
+		wantErr bool
+	}{
+		{
+			name: "disabled",
+			want: ShadowConfig{Percent: defaultShadowPercent},
+		},
+		{
+			name: "custom values",
+			env: map[string]string{
+				ShadowURLEnv:      "https://rancher-webhook-shadow.cattle-system.svc/",
+				ShadowPercentEnv:  "2.5",
+				ShadowCAFileEnv:   "/etc/shadow/ca.crt",
+				ShadowCertFileEnv: "/etc/shadow/tls.crt",
+				ShadowKeyFileEnv:  "/etc/shadow/tls.key",
+			},
+			want: ShadowConfig{
+				URL:      "https://rancher-webhook-shadow.cattle-system.svc",
+				Percent:  2.5,
+				CAFile:   "/etc/shadow/ca.crt",
+				CertFile: "/etc/shadow/tls.crt",
+				KeyFile:  "/etc/shadow/tls.key",
+			},
+		},
+		{name: "invalid percent", env: map[string]string{ShadowPercentEnv: "150"}, wantErr: true},
+		{name: "http url", env: map[string]string{ShadowURLEnv: "http://rancher-webhook-shadow"}, wantErr: true},
+		{name: "cert without key", env: map[string]string{ShadowCertFileEnv: "/etc/shadow/tls.crt"}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for _, key := range []string{ShadowURLEnv, ShadowPercentEnv, ShadowCAFileEnv, ShadowCertFileEnv, ShadowKeyFileEnv} {
+				t.Setenv(key, tt.env[key])
+			}
+			got, err := ShadowConfigFromEnv()
+			if tt.wantErr {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestRequestMirrorMiddleware(t *testing.T) {
+	t.Parallel()
+	resource := metav1.GroupVersionResource{Group: "mirror.cattle.io", Version: "v1", Resource: "tests"}
+	newBody := func(uid string) string {
+		body, err := json.Marshal(admissionv1.AdmissionReview{
+			TypeMeta: metav1.TypeMeta{APIVersion: "admission.k8s.io/v1", Kind: "AdmissionReview"},
+			Request: &admissionv1.AdmissionRequest{
+				UID:       k8stypes.UID(uid),
+				Resource:  resource,
+				Operation: admissionv1.Create,
+				UserInfo:  authenticationv1.UserInfo{Username: "user", Extra: map[string]authenticationv1.ExtraValue{"token": {"secret"}}},
+				Object:    runtime.RawExtension{Raw: []byte(`{}`)},
+			},
+		})
+		require.NoError(t, err)
+		return string(body)
+	}
+	respond := func(t *testing.T, w http.ResponseWriter, r *http.Request, allowed func(uid string) bool) string {
+		review := admissionv1.AdmissionReview{}
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&review))
+		review.Response = &admissionv1.AdmissionResponse{UID: review.Request.UID, Allowed: allowed(string(review.Request.UID))}
+		if !review.Response.Allowed {
+			review.Response.Result = &metav1.Status{Code: http.StatusBadRequest, Message: "denied"}
+		}
+		review.Request = nil
+		require.NoError(t, json.NewEncoder(w).Encode(review))
+		return string(review.Response.UID)
+	}
+
+	var mu sync.Mutex
+	var mirrored []string
+	shadow := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, validationPath+"/tests", r.URL.Path)
+		body, err := io.ReadAll(r.Body)
+		require.NoError(t, err)
+		assert.NotContains(t, string(body), "secret")
+		r.Body = io.NopCloser(strings.NewReader(string(body)))
+		uid := respond(t, w, r, func(uid string) bool { return uid != "diverged" })
+		mu.Lock()
+		mirrored = append(mirrored, uid)
+		mu.Unlock()
+	}))
+	defer shadow.Close()
+
+	mirror := &requestMirror{
+		url:      shadow.URL,
+		client:   shadow.Client(),
+		sample:   func() bool { return true },
+		inFlight: make(chan struct{}, maxShadowInFlight),
+	}
+	handler := mirror.middleware(validationPath)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		respond(t, w, r, func(string) bool { return true })
+	}))
+
+	for _, uid := range []string{"match", "diverged"} {
+		recorder := httptest.NewRecorder()
+		handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodPost, validationPath+"/tests", strings.NewReader(newBody(uid))))
+		review := admissionv1.AdmissionReview{}
+		require.NoError(t, json.NewDecoder(recorder.Body).Decode(&review))
+		// the decision of the shadow webhook is never returned
+		assert.True(t, review.Response.Allowed)
+	}
+
+	counter := func(result string) float64 {
+		return testutil.ToFloat64(metrics.ShadowRequests.WithLabelValues(metrics.WebhookTypeValidating, resource.Group, resource.Version, resource.Resource, result))
+	}
+	assert.Eventually(t, func() bool {
+		return counter(metrics.ShadowResultMatch) == 1 && counter(metrics.ShadowResultDiverged) == 1
+	}, 5*time.Second, 10*time.Millisecond)
+	mu.Lock()
+	assert.ElementsMatch(t, []string{"match", "diverged"}, mirrored)
+	mu.Unlock()
+}
+
+func TestSanitizeRequest(t *testing.T) {
+	t.Parallel()
+	request := &admissionv1.AdmissionRequest{
+		Kind:      metav1.GroupVersionKind{Version: "v1", Kind: "Secret"},
+		UserInfo:  authenticationv1.UserInfo{Username: "user", Extra: map[string]authenticationv1.ExtraValue{"token": {"value"}}},
+		Object:    runtime.RawExtension{Raw: []byte(`{"metadata":{"name":"s","annotations":{"kubectl.kubernetes.io/last-applied-configuration":"{}","other":"kept"}},"data":{"key":"dmFsdWU="},"type":"Opaque"}`)},
+		OldObject: runtime.RawExtension{Raw: []byte(`{"metadata":{"name":"s"},"stringData":{"key":"value"}}`)},
+	}
+	sanitizeRequest(request)
+	assert.Nil(t, request.UserInfo.Extra)
+	assert.JSONEq(t, `{"metadata":{"name":"s","annotations":{"other":"kept"}},"type":"Opaque"}`, string(request.Object.Raw))
+	assert.JSONEq(t, `{"metadata":{"name":"s"}}`, string(request.OldObject.Raw))
+
+	configMap := &admissionv1.AdmissionRequest{
+		Kind:   metav1.GroupVersionKind{Version: "v1", Kind: "ConfigMap"},
+		Object: runtime.RawExtension{Raw: []byte(`{"data":{"key":"value"}}`)},
+	}
+	sanitizeRequest(configMap)
+	assert.JSONEq(t, `{"data":{"key":"value"}}`, string(configMap.Object.Raw))
+
+	token := &admissionv1.AdmissionRequest{
+		Kind:      metav1.GroupVersionKind{Group: "management.cattle.io", Version: "v3", Kind: "Token"},
+		Object:    runtime.RawExtension{Raw: []byte(`{"metadata":{"name":"token-abc"},"token":"secret","userId":"u-abc"}`)},
+		OldObject: runtime.RawExtension{Raw: []byte(`{"metadata":{"name":"token-abc"},"token":"secret","userId":"u-abc"}`)},
+	}
+	sanitizeRequest(token)
+	assert.JSONEq(t, `{"metadata":{"name":"token-abc"},"userId":"u-abc"}`, string(token.Object.Raw))
+	assert.JSONEq(t, `{"metadata":{"name":"token-abc"},"userId":"u-abc"}`, string(token.OldObject.Raw))
+
+	authConfig := &admissionv1.AdmissionRequest{
+		Kind:   metav1.GroupVersionKind{Group: "management.cattle.io", Version: "v3", Kind: "AuthConfig"},
+		Object: runtime.RawExtension{Raw: []byte(`{"metadata":{"name":"github"},"clientId":"id","clientSecret":"secret"}`)},
+	}
+	sanitizeRequest(authConfig)
+	assert.JSONEq(t, `{"metadata":{"name":"github"},"clientId":"id"}`, string(authConfig.Object.Raw))
+
+	invalid := &admissionv1.AdmissionRequest{
+		Kind:   metav1.GroupVersionKind{Group: "management.cattle.io", Version: "v3", Kind: "User"},
+		Object: runtime.RawExtension{Raw: []byte(`{"password":`)},
+	}
+	sanitizeRequest(invalid)
+	assert.Nil(t, invalid.Object.Raw)
+}
+
+func TestMirrorable(t *testing.T) {
+	t.Parallel()
+	assert.True(t, mirrorable(metav1.GroupVersionKind{Version: "v1", Kind: "Secret"}))
+	assert.True(t, mirrorable(metav1.GroupVersionKind{Group: "management.cattle.io", Version: "v3", Kind: "Token"}))
+	assert.False(t, mirrorable(metav1.GroupVersionKind{Group: "management.cattle.io", Version: "v3", Kind: "NodeTemplate"}))
+	assert.False(t, mirrorable(metav1.GroupVersionKind{Group: "rke-machine-config.cattle.io", Version: "v1", Kind: "Amazonec2Config"}))
+}
+
+func TestCompareResponses(t *testing.T) {
+	t.Parallel()
+	allowed := &admissionv1.AdmissionResponse{Allowed: true}
+	denied := &admissionv1.AdmissionResponse{Result: &metav1.Status{Code: http.StatusBadRequest, Message: "invalid"}}
+	forbidden := &admissionv1.AdmissionResponse{Result: &metav1.Status{Code: http.StatusForbidden}}
+	patched := &admissionv1.AdmissionResponse{Allowed: true, Patch: []byte(`[{"op":"add","path":"/a","value":1}]`)}
+
+	assert.Empty(t, compareResponses(allowed, &admissionv1.AdmissionResponse{Allowed: true}))
+	assert.Empty(t, compareResponses(denied, denied))
+	assert.Contains(t, compareResponses(allowed, denied), "shadow allowed false (invalid)")
+	assert.Contains(t, compareResponses(denied, forbidden), "shadow denied with code 403")
+	assert.Contains(t, compareResponses(allowed, patched), "shadow patch")
+}
@@ -0,0 +1,155 @@
+package server
+
+import (
+	"fmt"
+	"sort"
+	"sync/atomic"
+
+	"github.com/sirupsen/logrus"
+	v1 "k8s.io/api/admissionregistration/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"sigs.k8s.io/yaml"
+)
+
+const (
+	// OverridesConfigMapName is the name of the ConfigMap in the webhook's namespace holding the overrides of the
+	// webhook configurations. Each key of the ConfigMap's data is an API group, or a resource formatted as
+	// "resource.group", and its value is the YAML encoded WebhookOverride. The core API group is named "core".
+	OverridesConfigMapName = "rancher-webhook-overrides"
+
+	coreGroupKey      = "core"
+	maxTimeoutSeconds = 30
+)
+
+// WebhookOverride holds the settings of the webhooks of an API group or resource which replace the webhook's defaults.
+type WebhookOverride struct {
+	FailurePolicy  *v1.FailurePolicyType `json:"failurePolicy,omitempty"`
+	TimeoutSeconds *int32                `json:"timeoutSeconds,omitempty"`
+	MatchPolicy    *v1.MatchPolicyType   `json:"matchPolicy,omitempty"`
+}
+
+// validate returns an error if any of the override's settings is not accepted by the Kubernetes API server.
+func (o *WebhookOverride) validate() error {
+	if o.FailurePolicy != nil && *o.FailurePolicy != v1.Fail && *o.FailurePolicy != v1.Ignore {
+		return fmt.Errorf("unsupported failurePolicy %q: must be %q or %q", *o.FailurePolicy, v1.Fail, v1.Ignore)
+	}
+	if o.TimeoutSeconds != nil && (*o.TimeoutSeconds < 1 || *o.TimeoutSeconds > maxTimeoutSeconds) {
+		return fmt.Errorf("invalid timeoutSeconds %d: must be between 1 and %d", *o.TimeoutSeconds, maxTimeoutSeconds)
+	}
+	if o.MatchPolicy != nil && *o.MatchPolicy != v1.Exact && *o.MatchPolicy != v1.Equivalent {
+		return fmt.Errorf("unsupported matchPolicy %q: must be %q or %q", *o.MatchPolicy, v1.Exact, v1.Equivalent)
+	}
+	return nil
+}
+
+// webhookOverrides holds the overrides loaded from the overrides ConfigMap.
+type webhookOverrides struct {
+	overrides atomic.Pointer[map[string]WebhookOverride]
+	// changed is called when the overrides change, so that the webhook configurations can be reconciled.
+	changed func()
+}
+
+func newWebhookOverrides(changed func()) *webhookOverrides {
+	o := &webhookOverrides{changed: changed}
+	o.overrides.Store(&map[string]WebhookOverride{})
+	return o
+}
+
+// sync loads the overrides from the overrides ConfigMap whenever it changes.
+func (o *webhookOverrides) sync(key string, configMap *corev1.ConfigMap) (*corev1.ConfigMap, error) {
+	if key != namespace+"/"+OverridesConfigMapName {
+		return configMap, nil
+	}
+	var data map[string]string
+	if configMap != nil && configMap.DeletionTimestamp == nil {
+		data = configMap.Data
+	}
+	o.load(data)
+	if o.changed != nil {
+		o.changed()
+	}
+	return configMap, nil
+}
+
+// load replaces the overrides. Overrides which can not be decoded or are invalid are logged and skipped.
+func (o *webhookOverrides) load(data map[string]string) {
+	keys := make([]string, 0, len(data))
+	for key := range data {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	overrides := make(map[string]WebhookOverride, len(data))
+	for _, key := range keys {
+		var override WebhookOverride
+		if err := yaml.UnmarshalStrict([]byte(data[key]), &override); err != nil {
+			logrus.Errorf("[webhook-overrides] skipping override %s which could not be decoded: %v", key, err)
+			continue
+		}
+		if err := override.validate(); err != nil {
+			logrus.Errorf("[webhook-overrides] skipping invalid override %s: %v", key, err)
+			continue
+		}
+		overrides[key] = override
+	}
+	o.overrides.Store(&overrides)
+	logrus.Infof("[webhook-overrides] loaded %d overrides", len(overrides))
+}
+
+// forResource returns the override for the given resource. The override of the resource is merged over the override
+// of its API group.
+func (o *webhookOverrides) forResource(gvr schema.GroupVersionResource) WebhookOverride {
+	overrides := *o.overrides.Load()
+	groupKey := gvr.Group
+	if groupKey == "" {
+		groupKey = coreGroupKey
+	}
+	override := overrides[groupKey]
+	resourceOverride, ok := overrides[gvr.GroupResource().String()]
+	if !ok {
+		return override
+	}
+	if resourceOverride.FailurePolicy != nil {
+		override.FailurePolicy = resourceOverride.FailurePolicy
+	}
+	if resourceOverride.TimeoutSeconds != nil {
+		override.TimeoutSeconds = resourceOverride.TimeoutSeconds
+	}
+	if resourceOverride.MatchPolicy != nil {
+		override.MatchPolicy = resourceOverride.MatchPolicy
+	}
+	return override
+}
+
+// applyValidating applies the override of the resource to its validating webhooks.
+func (o *webhookOverrides) applyValidating(gvr schema.GroupVersionResource, webhooks []v1.ValidatingWebhook) {
+	override := o.forResource(gvr)
+	for i := range webhooks {
+		if override.FailurePolicy != nil {
+			webhooks[i].FailurePolicy = override.FailurePolicy
+		}
+		if override.TimeoutSeconds != nil {
+			webhooks[i].TimeoutSeconds = override.TimeoutSeconds
+		}
+		if override.MatchPolicy != nil {
+			webhooks[i].MatchPolicy = override.MatchPolicy
+		}
+	}
+}
+
+// applyMutating applies the override of the resource to its mutating webhooks.
+func (o *webhookOverrides) applyMutating(gvr schema.GroupVersionResource, webhooks []v1.MutatingWebhook) {
+	override := o.forResource(gvr)
+	for i := range webhooks {
+		if override.FailurePolicy != nil {
+			webhooks[i].FailurePolicy = override.FailurePolicy
+		}
+		if override.TimeoutSeconds != nil {
+			webhooks[i].TimeoutSeconds = override.TimeoutSeconds
+		}
+		if override.MatchPolicy != nil {
+			webhooks[i].MatchPolicy = override.MatchPolicy
+		}
+	}
+}
@@ -0,0 +1,76 @@
+package server
+
+import (
+	"testing"
+
+	"github.com/rancher/webhook/pkg/admission"
+	"github.com/stretchr/testify/assert"
+	v1 "k8s.io/api/admissionregistration/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+func TestWebhookOverrides(t *testing.T) {
+	t.Parallel()
+	globalRoles := schema.GroupVersionResource{Group: "management.cattle.io", Version: "v3", Resource: "globalroles"}
+	settings := schema.GroupVersionResource{Group: "management.cattle.io", Version: "v3", Resource: "settings"}
+	namespaces := schema.GroupVersionResource{Version: "v1", Resource: "namespaces"}
+	clusters := schema.GroupVersionResource{Group: "provisioning.cattle.io", Version: "v1", Resource: "clusters"}
+
+	changed := 0
+	overrides := newWebhookOverrides(func() { changed++ })
+	_, err := overrides.sync(namespace+"/"+OverridesConfigMapName, &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: OverridesConfigMapName, Namespace: namespace},
+		Data: map[string]string{
+			"management.cattle.io":               "failurePolicy: Ignore\ntimeoutSeconds: 5\n",
+			"settings.management.cattle.io":      "failurePolicy: Fail\nmatchPolicy: Exact\n",
+			"core":                               "timeoutSeconds: 20\n",
+			"provisioning.cattle.io":             "timeoutSeconds: 60\n",
+			"clusters.management.cattle.io":      "failurePolicy: Sometimes\n",
+			"roletemplates.management.cattle.io": "failurPolicy: Ignore\n",
+		},
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, 1, changed)
+
+	assert.Equal(t, WebhookOverride{FailurePolicy: admission.Ptr(v1.Ignore), TimeoutSeconds: admission.Ptr(int32(5))}, overrides.forResource(globalRoles))
+	assert.Equal(t, WebhookOverride{
+		FailurePolicy:  admission.Ptr(v1.Fail),
+		TimeoutSeconds: admission.Ptr(int32(5)),
+		MatchPolicy:    admission.Ptr(v1.Exact),
+	}, overrides.forResource(settings))
+	assert.Equal(t, WebhookOverride{TimeoutSeconds: admission.Ptr(int32(20))}, overrides.forResource(namespaces))
+	// invalid overrides are skipped
+	assert.Equal(t, WebhookOverride{}, overrides.forResource(clusters))
+	assert.Len(t, *overrides.overrides.Load(), 3)
+
+	webhooks := []v1.ValidatingWebhook{
+		{Name: "first", FailurePolicy: admission.Ptr(v1.Fail), MatchPolicy: admission.Ptr(v1.Equivalent)},
+		{Name: "second", FailurePolicy: admission.Ptr(v1.Fail), MatchPolicy: admission.Ptr(v1.Equivalent)},
+	}
+	overrides.applyValidating(settings, webhooks)
+	for _, webhook := range webhooks {
+		assert.Equal(t, v1.Fail, *webhook.FailurePolicy)
+		assert.Equal(t, v1.Exact, *webhook.MatchPolicy)
+		assert.Equal(t, int32(5), *webhook.TimeoutSeconds)
+	}
+
+	mutatingWebhooks := []v1.MutatingWebhook{{Name: "mutating", FailurePolicy: admission.Ptr(v1.Fail), MatchPolicy: admission.Ptr(v1.Equivalent)}}
+	overrides.applyMutating(namespaces, mutatingWebhooks)
+	assert.Equal(t, v1.Fail, *mutatingWebhooks[0].FailurePolicy)
+	assert.Equal(t, v1.Equivalent, *mutatingWebhooks[0].MatchPolicy)
+	assert.Equal(t, int32(20), *mutatingWebhooks[0].TimeoutSeconds)
+
+	// other ConfigMaps are ignored
+	_, err = overrides.sync(namespace+"/other", &corev1.ConfigMap{})
+	assert.NoError(t, err)
+	assert.Equal(t, 1, changed)
+	assert.Len(t, *overrides.overrides.Load(), 3)
+
+	// deleting the ConfigMap removes the overrides
+	_, err = overrides.sync(namespace+"/"+OverridesConfigMapName, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, changed)
+	assert.Equal(t, WebhookOverride{}, overrides.forResource(globalRoles))
+}
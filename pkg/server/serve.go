@@ -0,0 +1,160 @@
+package server
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/rancher/dynamiclistener"
+	"github.com/rancher/dynamiclistener/storage/kubernetes"
+	"github.com/rancher/dynamiclistener/storage/memory"
+	"github.com/rancher/webhook/pkg/health"
+	corecontrollers "github.com/rancher/wrangler/v3/pkg/generated/controllers/core/v1"
+	"github.com/sirupsen/logrus"
+	"golang.org/x/net/http2"
+)
+
+const (
+	// ShutdownDelayEnv is the environment variable setting how long the webhook keeps serving requests, while
+	// reporting that it isn't ready, once it is asked to terminate. This lets the Service stop routing requests to the
+	// terminating pod before its listener is closed.
+	ShutdownDelayEnv = "CATTLE_WEBHOOK_SHUTDOWN_DELAY"
+	// ShutdownTimeoutEnv is the environment variable setting how long the webhook waits for in-flight requests to
+	// complete after closing its listener, before closing the remaining connections.
+	ShutdownTimeoutEnv = "CATTLE_WEBHOOK_SHUTDOWN_TIMEOUT"
+	// MaxConcurrentStreamsEnv is the environment variable setting the maximum number of concurrent HTTP/2 streams of a
+	// connection. 0 uses the Go default of 250.
+	MaxConcurrentStreamsEnv = "CATTLE_WEBHOOK_HTTP2_MAX_CONCURRENT_STREAMS"
+
+	// The defaults fit in the default termination grace period of 30 seconds.
+	defaultShutdownDelay   = 5 * time.Second
+	defaultShutdownTimeout = 20 * time.Second
+)
+
+var errShuttingDown = errors.New("shutting down")
+
+// ServingConfig configures how the webhook server serves connections and shuts down.
+type ServingConfig struct {
+	// ShutdownDelay is how long requests are still served, while readiness checks fail, once shutting down.
+	ShutdownDelay time.Duration
+	// ShutdownTimeout is how long in-flight requests are waited for once the listener is closed.
+	ShutdownTimeout time.Duration
+	// MaxConcurrentStreams is the maximum number of concurrent HTTP/2 streams of a connection. The Go default is
+	// used if 0.
+	MaxConcurrentStreams uint32
+}
+
+// ServingConfigFromEnv returns the ServingConfig set in the environment, using the defaults for unset values.
+func ServingConfigFromEnv() (ServingConfig, error) {
+	config := ServingConfig{
+		ShutdownDelay:   defaultShutdownDelay,
+		ShutdownTimeout: defaultShutdownTimeout,
+	}
+	if value := os.Getenv(ShutdownDelayEnv); value != "" {
+		parsed, err := time.ParseDuration(value)
+		if err != nil || parsed < 0 {
+			return config, fmt.Errorf("invalid value '%s' for %s: must be a non-negative duration", value, ShutdownDelayEnv)
+		}
+		config.ShutdownDelay = parsed
+	}
+	if value := os.Getenv(ShutdownTimeoutEnv); value != "" {
+		parsed, err := time.ParseDuration(value)
+		if err != nil || parsed <= 0 {
+			return config, fmt.Errorf("invalid value '%s' for %s: must be a positive duration", value, ShutdownTimeoutEnv)
+		}
+		config.ShutdownTimeout = parsed
+	}
+	if value := os.Getenv(MaxConcurrentStreamsEnv); value != "" {
+		parsed, err := strconv.ParseUint(value, 10, 32)
+		if err != nil {
+			return config, fmt.Errorf("invalid value '%s' for %s: must be a non-negative integer", value, MaxConcurrentStreamsEnv)
+		}
+		config.MaxConcurrentStreams = uint32(parsed)
+	}
+	return config, nil
+}
+
+// serveTLS serves the handler on the given port, with the certificate stored in the webhook's TLS secret, until ctx
+// is done. The server is then drained following the config, while readiness reports errShuttingDown. The returned
+// channel is closed once the server has shut down.
+func serveTLS(ctx context.Context, port int, handler http.Handler, secrets corecontrollers.SecretController,
+	tlsConfig *tls.Config, config ServingConfig, readiness *health.ErrorChecker) (<-chan struct{}, error) {
+	tcpListener, err := dynamiclistener.NewTCPListener("", port)
+	if err != nil {
+		return nil, err
+	}
+	caCerts, caKey, err := kubernetes.LoadOrGenCAChain(secrets, namespace, caName)
+	if err != nil {
+		return nil, err
+	}
+	tlsConfig.NextProtos = []string{"h2", "http/1.1"}
+	listener, certHandler, err := dynamiclistener.NewListenerWithChain(tcpListener,
+		kubernetes.Load(ctx, secrets, namespace, certName, memory.New()), caCerts, caKey, dynamiclistener.Config{
+			SANs: []string{
+				tlsName,
+			},
+			FilterCN:  dynamiclistener.OnlyAllow(tlsName),
+			TLSConfig: tlsConfig,
+		})
+	if err != nil {
+		return nil, err
+	}
+
+	httpServer := newHTTPServer(ctx, http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		certHandler.ServeHTTP(rw, req)
+		handler.ServeHTTP(rw, req)
+	}))
+	if err := http2.ConfigureServer(httpServer, &http2.Server{MaxConcurrentStreams: config.MaxConcurrentStreams}); err != nil {
+		return nil, fmt.Errorf("failed to configure HTTP/2: %w", err)
+	}
+
+	go func() {
+		logrus.Infof("Listening on :%d", port)
+		if err := httpServer.Serve(listener); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			logrus.Fatalf("https server failed: %v", err)
+		}
+	}()
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		<-ctx.Done()
+		drain(httpServer, config, readiness)
+	}()
+	return done, nil
+}
+
+// newHTTPServer returns a server for the handler. The requests' contexts aren't canceled with ctx, so that in-flight
+// requests can complete while the server is drained.
+func newHTTPServer(ctx context.Context, handler http.Handler) *http.Server {
+	return &http.Server{
+		Handler: handler,
+		BaseContext: func(net.Listener) context.Context {
+			return context.WithoutCancel(ctx)
+		},
+		ErrorLog: log.New(logrus.StandardLogger().WriterLevel(logrus.ErrorLevel), "", log.LstdFlags),
+	}
+}
+
+// drain keeps serving requests for the shutdown delay while readiness checks fail, then closes the listener and
+// waits for in-flight requests up to the shutdown timeout before closing the remaining connections.
+func drain(httpServer *http.Server, config ServingConfig, readiness *health.ErrorChecker) {
+	readiness.Store(errShuttingDown)
+	logrus.Infof("[drain] shutting down, serving requests for %s before closing the listener", config.ShutdownDelay)
+	time.Sleep(config.ShutdownDelay)
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), config.ShutdownTimeout)
+	defer cancel()
+	if err := httpServer.Shutdown(shutdownCtx); err != nil {
+		logrus.Warnf("[drain] in-flight requests did not complete within %s, closing their connections: %v", config.ShutdownTimeout, err)
+		_ = httpServer.Close()
+		return
+	}
+	logrus.Info("[drain] server shut down")
+}
@@ -0,0 +1,140 @@
+package server
+
+import (
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/rancher/webhook/pkg/health"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestServingConfigFromEnv(t *testing.T) {
+	tests := []struct {
+		name    string
+		env     map[string]string
+		want    ServingConfig
+		wantErr bool
+	}{
+		{
+			name: "defaults",
+			want: ServingConfig{ShutdownDelay: defaultShutdownDelay, ShutdownTimeout: defaultShutdownTimeout},
+		},
+		{
+			name: "custom values",
+			env:  map[string]string{ShutdownDelayEnv: "0s", ShutdownTimeoutEnv: "1m", MaxConcurrentStreamsEnv: "100"},
+			want: ServingConfig{ShutdownDelay: 0, ShutdownTimeout: time.Minute, MaxConcurrentStreams: 100},
+		},
+		{name: "invalid delay", env: map[string]string{ShutdownDelayEnv: "-1s"}, wantErr: true},
+		{name: "invalid timeout", env: map[string]string{ShutdownTimeoutEnv: "0s"}, wantErr: true},
+		{name: "invalid streams", env: map[string]string{MaxConcurrentStreamsEnv: "-1"}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for _, key := range []string{ShutdownDelayEnv, ShutdownTimeoutEnv, MaxConcurrentStreamsEnv} {
+				t.Setenv(key, tt.env[key])
+			}
+			got, err := ServingConfigFromEnv()
+			if tt.wantErr {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestDrain(t *testing.T) {
+	t.Parallel()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	started := make(chan struct{})
+	release := make(chan struct{})
+	httpServer := newHTTPServer(ctx, http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		close(started)
+		<-release
+		// the request's context outlives the server's context
+		if req.Context().Err() != nil {
+			http.Error(rw, req.Context().Err().Error(), http.StatusServiceUnavailable)
+			return
+		}
+		_, _ = io.WriteString(rw, "done")
+	}))
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	go func() { _ = httpServer.Serve(listener) }()
+
+	type result struct {
+		body string
+		err  error
+	}
+	results := make(chan result, 1)
+	go func() {
+		resp, err := http.Get("http://" + listener.Addr().String())
+		if err != nil {
+			results <- result{err: err}
+			return
+		}
+		defer resp.Body.Close()
+		body, err := io.ReadAll(resp.Body)
+		results <- result{body: string(body), err: err}
+	}()
+	<-started
+
+	readiness := health.NewErrorChecker("Shutdown")
+	readiness.Store(nil)
+	cancel()
+	drained := make(chan struct{})
+	go func() {
+		defer close(drained)
+		drain(httpServer, ServingConfig{ShutdownDelay: 10 * time.Millisecond, ShutdownTimeout: 10 * time.Second}, readiness)
+	}()
+
+	require.Eventually(t, func() bool { return readiness.Check(nil) != nil }, time.Second, time.Millisecond)
+	select {
+	case <-drained:
+		t.Fatal("server shut down before the in-flight request completed")
+	case <-time.After(50 * time.Millisecond):
+	}
+	close(release)
+	res := <-results
+	require.NoError(t, res.err)
+	assert.Equal(t, "done", res.body)
+	<-drained
+
+	_, err = http.Get("http://" + listener.Addr().String())
+	assert.Error(t, err, "the listener is closed once drained")
+}
+
+func TestDrainTimeout(t *testing.T) {
+	t.Parallel()
+	release := make(chan struct{})
+	defer close(release)
+	started := make(chan struct{})
+	httpServer := newHTTPServer(context.Background(), http.HandlerFunc(func(http.ResponseWriter, *http.Request) {
+		close(started)
+		<-release
+	}))
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	go func() { _ = httpServer.Serve(listener) }()
+	errs := make(chan error, 1)
+	go func() {
+		resp, err := http.Get("http://" + listener.Addr().String())
+		if err == nil {
+			resp.Body.Close()
+		}
+		errs <- err
+	}()
+	<-started
+
+	readiness := health.NewErrorChecker("Shutdown")
+	drain(httpServer, ServingConfig{ShutdownTimeout: 10 * time.Millisecond}, readiness)
+	assert.Error(t, <-errs, "connections of requests still in-flight after the timeout are closed")
+}
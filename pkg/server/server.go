@@ -4,29 +4,32 @@ package server
 import (
 	"context"
 	"crypto/tls"
-	"crypto/x509"
 	"fmt"
-	"io/ioutil"
 	"net/http"
 	"os"
-	"path/filepath"
 	"strconv"
 	"strings"
 	"time"
 
 	"github.com/gorilla/mux"
-	"github.com/rancher/dynamiclistener"
-	"github.com/rancher/dynamiclistener/server"
 	"github.com/rancher/webhook/pkg/admission"
+	"github.com/rancher/webhook/pkg/audit"
+	"github.com/rancher/webhook/pkg/celpolicy"
 	"github.com/rancher/webhook/pkg/clients"
 	"github.com/rancher/webhook/pkg/health"
+	"github.com/rancher/webhook/pkg/metrics"
+	"github.com/rancher/webhook/pkg/resolvers"
+	"github.com/rancher/webhook/pkg/simulation"
 	admissionregistration "github.com/rancher/wrangler/v3/pkg/generated/controllers/admissionregistration.k8s.io/v1"
+	corecontrollers "github.com/rancher/wrangler/v3/pkg/generated/controllers/core/v1"
+	"github.com/rancher/wrangler/v3/pkg/schemes"
 	"github.com/sirupsen/logrus"
 	v1 "k8s.io/api/admissionregistration/v1"
 	corev1 "k8s.io/api/core/v1"
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/client-go/rest"
+	"sigs.k8s.io/yaml"
 )
 
 const (
@@ -37,16 +40,14 @@ const (
 	caName                  = "cattle-webhook-ca"
 	validationPath          = "/v1/webhook/validation"
 	mutationPath            = "/v1/webhook/mutation"
+	metricsPath             = "/metrics"
+	metricsRulesPath        = "/metrics/rules"
 	clientPort              = int32(443)
-	webhookHTTPPort         = 0 // value of 0 indicates we do not want to use http.
 	defaultWebhookHTTPSPort = 9443
 	webhookPortEnvKey       = "CATTLE_PORT"
 	webhookURLEnvKey        = "CATTLE_WEBHOOK_URL"
-	allowedCNsEnv           = "ALLOWED_CNS"
 )
 
-var caFile = filepath.Join(os.TempDir(), "k8s-webhook-server", "client-ca", "ca.crt")
-
 // tlsOpt option function applied to all webhook servers.
 var tlsOpt = func(config *tls.Config) {
 	config.MinVersion = tls.VersionTLS12
@@ -61,12 +62,37 @@ var tlsOpt = func(config *tls.Config) {
 	config.ClientAuth = tls.RequestClientCert
 }
 
-// ListenAndServe starts the webhook server.
+// ListenAndServe starts the webhook server, and blocks until the server is shut down once ctx is done.
 func ListenAndServe(ctx context.Context, cfg *rest.Config, mcmEnabled bool) error {
-	clients, err := clients.New(ctx, cfg, mcmEnabled)
+	policyVersion, err := admission.PolicyVersionFromEnv()
+	if err != nil {
+		return err
+	}
+	admission.SetPolicyVersion(policyVersion)
+	logrus.Infof("[ListenAndServe] using admission policy version %d", policyVersion)
+
+	stripManagedFields, err := admission.StripManagedFieldsFromEnv()
+	if err != nil {
+		return err
+	}
+	admission.SetStripManagedFields(stripManagedFields)
+
+	dynamicMCM, err := DynamicMCMFromEnv()
+	if err != nil {
+		return err
+	}
+
+	if err = setupTracing(ctx, cfg); err != nil {
+		return err
+	}
+
+	// when following the multi-cluster-management feature, the clients used by its handlers are always needed
+	clients, err := clients.New(ctx, cfg, mcmEnabled || dynamicMCM)
 	if err != nil {
 		return fmt.Errorf("failed to create a new client: %w", err)
 	}
+	// service accounts trusted by the validators are verified against their ServiceAccount rather than by name
+	admission.SetServiceAccountGetter(clients.Core.ServiceAccount().Cache())
 
 	if err = setCertificateExpirationDays(); err != nil {
 		// If this error occurs, certificate creation will still work. However, our override will likely not have worked.
@@ -75,17 +101,92 @@ func ListenAndServe(ctx context.Context, cfg *rest.Config, mcmEnabled bool) erro
 		logrus.Infof("[ListenAndServe] could not set certificate expiration days via environment variable: %v", err)
 	}
 
-	validators, err := Validation(clients)
+	limits, err := RequestLimitsFromEnv()
+	if err != nil {
+		return err
+	}
+
+	shadow, err := ShadowConfigFromEnv()
+	if err != nil {
+		return err
+	}
+
+	auditConfig, err := audit.ConfigFromEnv()
+	if err != nil {
+		return err
+	}
+
+	denialEvents, err := DenialEventsConfigFromEnv()
+	if err != nil {
+		return err
+	}
+
+	serving, err := ServingConfigFromEnv()
+	if err != nil {
+		return err
+	}
+
+	clientAuth, err := ClientAuthConfigFromEnv()
+	if err != nil {
+		return err
+	}
+
+	bypass, err := BypassConfigFromEnv()
+	if err != nil {
+		return err
+	}
+	if bypass.Group != "" {
+		logrus.Warnf("[ListenAndServe] members of %s bypass the validators of %s", bypass.Group, strings.Join(bypass.Resources, ", "))
+	}
+
+	unknownFields, err := admission.UnknownFieldsModeFromEnv()
 	if err != nil {
 		return err
 	}
 
-	mutators, err := Mutation(clients)
+	unsyncedMode, err := UnsyncedModeFromEnv()
 	if err != nil {
 		return err
 	}
 
-	if err = listenAndServe(ctx, clients, validators, mutators); err != nil {
+	policyEngine, err := celpolicy.NewEngine()
+	if err != nil {
+		return err
+	}
+	clients.Core.ConfigMap().OnChange(ctx, "external-policies", policyEngine.Sync)
+	wrapValidators := func(validators []admission.ValidatingAdmissionHandler) []admission.ValidatingAdmissionHandler {
+		validators = admission.WithUnknownFieldsCheck(validators, unknownFields, schemes.All)
+		return bypassValidators(bypass, celpolicy.WrapValidators(policyEngine, validators))
+	}
+
+	var validators []admission.ValidatingAdmissionHandler
+	var mutators []admission.MutatingAdmissionHandler
+	if dynamicMCM {
+		toggle := newMCMToggle(mcmEnabled, func() {
+			// reapply the webhook configurations with the handlers which are now enabled
+			clients.Core.Secret().Enqueue(namespace, caName)
+		})
+		clients.Management.Feature().OnChange(ctx, "mcm-toggle", toggle.sync)
+		logrus.Infof("[ListenAndServe] following the %s feature, initially enabled=%t", mcmFeature, mcmEnabled)
+		validators, mutators, err = dynamicMCMHandlers(clients, toggle, wrapValidators)
+		if err != nil {
+			return err
+		}
+	} else {
+		validators, err = Validation(clients)
+		if err != nil {
+			return err
+		}
+		validators = wrapValidators(validators)
+
+		mutators, err = Mutation(clients)
+		if err != nil {
+			return err
+		}
+	}
+
+	done, err := listenAndServe(ctx, clients, validators, mutators, limits, shadow, auditConfig, denialEvents, serving, clientAuth, unknownFields, unsyncedMode)
+	if err != nil {
 		return err
 	}
 
@@ -93,9 +194,30 @@ func ListenAndServe(ctx context.Context, cfg *rest.Config, mcmEnabled bool) erro
 		return fmt.Errorf("failed to start client: %w", err)
 	}
 
+	<-done
 	return nil
 }
 
+// metricsRulesHandler serves example Prometheus recording and alerting rules for the registered webhooks.
+func metricsRulesHandler(validators []admission.ValidatingAdmissionHandler, mutators []admission.MutatingAdmissionHandler) http.Handler {
+	webhooks := make([]metrics.Webhook, 0, len(validators)+len(mutators))
+	for _, validator := range validators {
+		webhooks = append(webhooks, metrics.Webhook{Type: metrics.WebhookTypeValidating, GVR: validator.GVR()})
+	}
+	for _, mutator := range mutators {
+		webhooks = append(webhooks, metrics.Webhook{Type: metrics.WebhookTypeMutating, GVR: mutator.GVR()})
+	}
+	rules, err := yaml.Marshal(metrics.PrometheusRules(webhooks))
+	return http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		if err != nil {
+			http.Error(w, fmt.Sprintf("failed to generate rules: %v", err), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/yaml")
+		_, _ = w.Write(rules)
+	})
+}
+
 // By default, dynamiclistener sets newly signed certificates to expire after 365 days. Since the
 // self-signed certificate for webhook does not need to be rotated, we increase expiration time
 // beyond relevance. In this case, that's 3650 days (10 years).
@@ -107,11 +229,45 @@ func setCertificateExpirationDays() error {
 	return nil
 }
 
-func listenAndServe(ctx context.Context, clients *clients.Clients, validators []admission.ValidatingAdmissionHandler, mutators []admission.MutatingAdmissionHandler) (rErr error) {
+func listenAndServe(ctx context.Context, clients *clients.Clients, validators []admission.ValidatingAdmissionHandler, mutators []admission.MutatingAdmissionHandler, limits RequestLimits, shadow ShadowConfig, auditConfig audit.Config, denialEvents DenialEventsConfig, serving ServingConfig, clientAuth ClientAuthConfig, unknownFields admission.UnknownFieldsMode, unsyncedMode string) (done <-chan struct{}, rErr error) {
 	router := mux.NewRouter()
 	errChecker := health.NewErrorChecker("Config Applied")
-	health.RegisterHealthCheckers(router, errChecker)
-	router.Use(certAuth())
+	certChecker := health.NewCertificateChecker(clients.Core.Secret().Cache(), namespace, certName)
+	apiServerChecker := health.NewAPIServerChecker(clients.K8s.Discovery().RESTClient())
+	shutdownChecker := health.NewErrorChecker("Shutdown")
+	shutdownChecker.Store(nil)
+	// the server starts before the caches have synced, so that the webhook responds while they fill
+	syncGate := newCacheSyncGate(unsyncedMode, clients.SharedControllerFactory.SharedCacheFactory().WaitForCacheSync)
+	health.RegisterHealthCheckers(router, errChecker, certChecker)
+	health.RegisterReadinessCheckers(router, errChecker, certChecker, apiServerChecker, shutdownChecker, syncGate)
+	router.Handle(metricsPath, metrics.Handler())
+	router.Handle(metricsRulesPath, metricsRulesHandler(validators, mutators))
+	certAuth, err := newClientCertAuth(clientAuth)
+	if err != nil {
+		return nil, err
+	}
+	router.Use(certAuth.middleware)
+	router.Use(newRequestLimiter(limits).middleware(validationPath, mutationPath))
+	router.Use(syncGate.middleware(validationPath, mutationPath))
+	if auditLogger := audit.NewLogger(auditConfig); auditLogger != nil {
+		router.Use(auditMiddleware(auditLogger, validationPath, mutationPath))
+		go func() {
+			<-ctx.Done()
+			if err := auditLogger.Close(); err != nil {
+				logrus.Warnf("[audit] %v", err)
+			}
+		}()
+	}
+	if eventRecorder := newDenialEventRecorder(denialEvents, clients.K8s.CoreV1()); eventRecorder != nil {
+		router.Use(eventRecorder.middleware(validationPath, mutationPath))
+	}
+	mirror, err := newRequestMirror(shadow)
+	if err != nil {
+		return nil, err
+	}
+	if mirror != nil {
+		router.Use(mirror.middleware(validationPath, mutationPath))
+	}
 
 	logrus.Debug("Creating Webhook routes")
 	for _, webhook := range validators {
@@ -125,20 +281,50 @@ func listenAndServe(ctx context.Context, clients *clients.Clients, validators []
 		logrus.Debugf("creating route: %s", path)
 	}
 
+	overrides := newWebhookOverrides(func() {
+		// reapply the webhook configurations with the new overrides
+		clients.Core.Secret().Enqueue(namespace, caName)
+	})
+	clients.Core.ConfigMap().OnChange(ctx, "webhook-overrides", overrides.sync)
+
 	handler := &secretHandler{
 		validators:           validators,
 		mutators:             mutators,
+		overrides:            overrides,
 		errChecker:           errChecker,
 		validatingController: clients.Admission.ValidatingWebhookConfiguration(),
 		mutatingController:   clients.Admission.MutatingWebhookConfiguration(),
+		configMaps:           clients.Core.ConfigMap(),
+		unknownFields:        unknownFields,
 	}
 	clients.Core.Secret().OnChange(ctx, "secrets", handler.sync)
+	authorizer := &requestAuthorizer{
+		tokenReviews: clients.K8s.AuthenticationV1().TokenReviews(),
+		sars:         clients.SubjectAccessReviews,
+	}
+	router.Handle(webhooksPath, authorizer.handler(webhooksAttributes, handler.webhooksHandler()))
+	reviewSimulator := &simulator{
+		validators: validators,
+		mutators:   mutators,
+		authorizer: authorizer,
+	}
+	router.Handle(simulatePath, reviewSimulator.handler()).Methods(http.MethodPost)
+	if clients.MultiClusterManagement {
+		ruleResolver := resolvers.NewAggregateRuleResolver(clients.DefaultResolver, clients.CRTBResolver, clients.PRTBResolver)
+		simulator := simulation.NewSimulator(clients.RoleTemplateResolver, ruleResolver,
+			clients.CRTBResolver.ClusterRoleTemplateBindings, clients.PRTBResolver.ProjectRoleTemplateBindings)
+		router.Handle(simulation.RoleTemplateSimulationPath,
+			authorizer.handler(roleTemplateSimulationAttributes, simulator.Handler())).Methods(http.MethodPost)
+	}
 
 	defer func() {
 		if rErr != nil {
 			return
 		}
 		rErr = clients.Start(ctx)
+		if rErr == nil {
+			syncGate.markSynced()
+		}
 	}()
 
 	tlsConfig := &tls.Config{}
@@ -148,31 +334,22 @@ func listenAndServe(ctx context.Context, clients *clients.Clients, validators []
 		var err error
 		webhookHTTPSPort, err = strconv.Atoi(portStr)
 		if err != nil {
-			return fmt.Errorf("failed to decode webhook port value '%s': %w", portStr, err)
+			return nil, fmt.Errorf("failed to decode webhook port value '%s': %w", portStr, err)
 		}
 	}
-	return server.ListenAndServe(ctx, webhookHTTPSPort, webhookHTTPPort, router, &server.ListenOpts{
-		Secrets:       clients.Core.Secret(),
-		CertNamespace: namespace,
-		CertName:      certName,
-		CAName:        caName,
-		TLSListenerConfig: dynamiclistener.Config{
-			SANs: []string{
-				tlsName,
-			},
-			FilterCN:  dynamiclistener.OnlyAllow(tlsName),
-			TLSConfig: tlsConfig,
-		},
-		DisplayServerLogs: true,
-	})
+	return serveTLS(ctx, webhookHTTPSPort, router, clients.Core.Secret(), tlsConfig, serving, shutdownChecker)
 }
 
 type secretHandler struct {
 	validators           []admission.ValidatingAdmissionHandler
 	mutators             []admission.MutatingAdmissionHandler
+	overrides            *webhookOverrides
 	errChecker           *health.ErrorChecker
 	validatingController admissionregistration.ValidatingWebhookConfigurationClient
 	mutatingController   admissionregistration.MutatingWebhookConfigurationClient
+	// configMaps is used to publish the webhook's Capabilities once the webhook configurations are applied.
+	configMaps    corecontrollers.ConfigMapClient
+	unknownFields admission.UnknownFieldsMode
 }
 
 // sync updates the validating admission configuration whenever the TLS cert changes.
@@ -185,42 +362,20 @@ func (s *secretHandler) sync(_ string, secret *corev1.Secret) (*corev1.Secret, e
 	// Sleep here to make sure server is listening and all caches are primed
 	time.Sleep(15 * time.Second)
 
-	validationClientConfig := v1.WebhookClientConfig{
-		Service: &v1.ServiceReference{
-			Namespace: namespace,
-			Name:      serviceName,
-			Path:      admission.Ptr(validationPath),
-			Port:      admission.Ptr(clientPort),
-		},
-		CABundle: secret.Data[corev1.TLSCertKey],
-	}
-
-	mutationClientConfig := v1.WebhookClientConfig{
-		Service: &v1.ServiceReference{
-			Namespace: namespace,
-			Name:      serviceName,
-			Path:      admission.Ptr(mutationPath),
-			Port:      admission.Ptr(clientPort),
-		},
-		CABundle: secret.Data[corev1.TLSCertKey],
-	}
-	if devURL, ok := os.LookupEnv(webhookURLEnvKey); ok {
-		validationURL := devURL + validationPath
-		mutationURL := devURL + mutationPath
-		validationClientConfig = v1.WebhookClientConfig{
-			URL: &validationURL,
-		}
-		mutationClientConfig = v1.WebhookClientConfig{
-			URL: &mutationURL,
-		}
-	}
+	validationClientConfig, mutationClientConfig := clientConfigs(secret.Data[corev1.TLSCertKey])
 	validatingWebhooks := make([]v1.ValidatingWebhook, 0, len(s.validators))
 	for _, webhook := range s.validators {
-		validatingWebhooks = append(validatingWebhooks, webhook.ValidatingWebhook(validationClientConfig)...)
+		if !isEnabled(webhook) {
+			continue
+		}
+		validatingWebhooks = append(validatingWebhooks, s.validatingWebhooks(webhook, validationClientConfig)...)
 	}
 	mutatingWebhooks := make([]v1.MutatingWebhook, 0, len(s.mutators))
 	for _, webhook := range s.mutators {
-		mutatingWebhooks = append(mutatingWebhooks, webhook.MutatingWebhook(mutationClientConfig)...)
+		if !isEnabled(webhook) {
+			continue
+		}
+		mutatingWebhooks = append(mutatingWebhooks, s.mutatingWebhooks(webhook, mutationClientConfig)...)
 	}
 	validatingConfig := &v1.ValidatingWebhookConfiguration{
 		ObjectMeta: metav1.ObjectMeta{
@@ -237,6 +392,9 @@ func (s *secretHandler) sync(_ string, secret *corev1.Secret) (*corev1.Secret, e
 	err := s.ensureWebhookConfiguration(validatingConfig, mutatingConfig)
 	if err != nil {
 		logrus.Errorf("Failed to ensure configuration: %s", err.Error())
+	} else if capabilitiesErr := s.publishCapabilities(); capabilitiesErr != nil {
+		// the webhook works without its capabilities published, so this isn't reported as a configuration error
+		logrus.Warnf("Failed to publish capabilities: %v", capabilitiesErr)
 	}
 
 	s.errChecker.Store(err)
@@ -244,6 +402,53 @@ func (s *secretHandler) sync(_ string, secret *corev1.Secret) (*corev1.Secret, e
 
 }
 
+// clientConfigs returns the client configurations of the validating and mutating webhooks, which are either the
+// webhook's service or the URL set by CATTLE_WEBHOOK_URL.
+func clientConfigs(caBundle []byte) (validation, mutation v1.WebhookClientConfig) {
+	if devURL, ok := os.LookupEnv(webhookURLEnvKey); ok {
+		validationURL := devURL + validationPath
+		mutationURL := devURL + mutationPath
+		return v1.WebhookClientConfig{URL: &validationURL}, v1.WebhookClientConfig{URL: &mutationURL}
+	}
+	validation = v1.WebhookClientConfig{
+		Service: &v1.ServiceReference{
+			Namespace: namespace,
+			Name:      serviceName,
+			Path:      admission.Ptr(validationPath),
+			Port:      admission.Ptr(clientPort),
+		},
+		CABundle: caBundle,
+	}
+	mutation = v1.WebhookClientConfig{
+		Service: &v1.ServiceReference{
+			Namespace: namespace,
+			Name:      serviceName,
+			Path:      admission.Ptr(mutationPath),
+			Port:      admission.Ptr(clientPort),
+		},
+		CABundle: caBundle,
+	}
+	return validation, mutation
+}
+
+// validatingWebhooks returns the webhooks of the validator, with the overrides applied.
+func (s *secretHandler) validatingWebhooks(validator admission.ValidatingAdmissionHandler, clientConfig v1.WebhookClientConfig) []v1.ValidatingWebhook {
+	webhooks := validator.ValidatingWebhook(clientConfig)
+	if s.overrides != nil {
+		s.overrides.applyValidating(validator.GVR(), webhooks)
+	}
+	return webhooks
+}
+
+// mutatingWebhooks returns the webhooks of the mutator, with the overrides applied.
+func (s *secretHandler) mutatingWebhooks(mutator admission.MutatingAdmissionHandler, clientConfig v1.WebhookClientConfig) []v1.MutatingWebhook {
+	webhooks := mutator.MutatingWebhook(clientConfig)
+	if s.overrides != nil {
+		s.overrides.applyMutating(mutator.GVR(), webhooks)
+	}
+	return webhooks
+}
+
 // ensureWebhookConfiguration creates or updates the current validating and mutating webhook configuration to have the desired webhook.
 func (s *secretHandler) ensureWebhookConfiguration(validatingConfig *v1.ValidatingWebhookConfiguration, mutatingConfig *v1.MutatingWebhookConfiguration) error {
 
@@ -281,80 +486,3 @@ func (s *secretHandler) ensureWebhookConfiguration(validatingConfig *v1.Validati
 
 	return nil
 }
-
-// certAuth returns a middleware for cert-based authentication.
-// This is done as a middleware instead of using tls.RequireAndVerifyClientCert because an exception
-// needs to be made for the unauthenticated /healthz endpoint.
-func certAuth() func(next http.Handler) http.Handler {
-	opts := getVerifyOptions()
-	allowedCNs := getAllowedCNs()
-	return func(next http.Handler) http.Handler {
-		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-			logrus.Tracef("running cert check middleware for request %s", r.URL.Path)
-			if opts == nil {
-				next.ServeHTTP(w, r)
-				return
-			}
-			if r.URL.Path == "/healthz" { // apiserver does not present client cert for health checks
-				next.ServeHTTP(w, r)
-				return
-			}
-			if len(r.TLS.PeerCertificates) == 0 {
-				logrus.Warn("client did not present certificates")
-				http.Error(w, "could not verify client certificates", http.StatusUnauthorized)
-				return
-			}
-			for _, cert := range r.TLS.PeerCertificates[1:] {
-				opts.Intermediates.AddCert(cert)
-			}
-			_, err := r.TLS.PeerCertificates[0].Verify(*opts)
-			if err != nil {
-				logrus.Warnf("could not verify client certificates: %v", err)
-				http.Error(w, "could not verify client certificates", http.StatusUnauthorized)
-				return
-			}
-			if len(allowedCNs) == 0 {
-				next.ServeHTTP(w, r)
-				return
-			}
-			requestCN := r.TLS.PeerCertificates[0].Subject.CommonName
-			found := false
-			for _, allowed := range allowedCNs {
-				if allowed == requestCN {
-					found = true
-					break
-				}
-			}
-			if !found {
-				logrus.Warnf("could not find common name %s in allowed list", requestCN)
-				http.Error(w, "common name is not allowed", http.StatusUnauthorized)
-				return
-			}
-			next.ServeHTTP(w, r)
-		})
-	}
-}
-
-func getVerifyOptions() *x509.VerifyOptions {
-	caCert, err := ioutil.ReadFile(caFile)
-	if err != nil {
-		logrus.Infof("could not read client CA file at %s, incoming requests will not be authenticated", caFile)
-		return nil
-	}
-	caCertPool := x509.NewCertPool()
-	caCertPool.AppendCertsFromPEM(caCert)
-	opts := x509.VerifyOptions{
-		Roots:         caCertPool,
-		Intermediates: x509.NewCertPool(),
-		KeyUsages:     []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
-	}
-	return &opts
-}
-
-func getAllowedCNs() []string {
-	allowedCNString := os.Getenv(allowedCNsEnv)
-	if len(allowedCNString) == 0 {
-		return nil
-	}
-	return strings.Split(allowedCNString, ",")
-}
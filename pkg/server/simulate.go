@@ -0,0 +1,205 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+
+	jsonpatch "github.com/evanphx/json-patch"
+	"github.com/rancher/webhook/pkg/admission"
+	"github.com/rancher/webhook/pkg/simulation"
+	admissionv1 "k8s.io/api/admission/v1"
+	authorizationv1 "k8s.io/api/authorization/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	utilyaml "k8s.io/apimachinery/pkg/util/yaml"
+)
+
+const (
+	simulatePath = "/v1/simulate"
+	// maxSimulationBytes is the largest body accepted by the simulate endpoint.
+	maxSimulationBytes = 32 << 20
+)
+
+// simulationAttributes are the attributes of the SubjectAccessReview callers of the simulate endpoint must be allowed,
+// on a resource which only exists for authorization.
+var simulationAttributes = authorizationv1.ResourceAttributes{
+	Verb:     "create",
+	Group:    "webhook.cattle.io",
+	Resource: "simulations",
+}
+
+// roleTemplateSimulationAttributes are the attributes of the SubjectAccessReview callers of the RoleTemplate simulation
+// endpoint must be allowed, since the simulations list the bindings and rules of any user.
+var roleTemplateSimulationAttributes = authorizationv1.ResourceAttributes{
+	Verb:     "create",
+	Group:    simulation.Group,
+	Resource: "roletemplatesimulations",
+}
+
+// simulationResult is the verdict of the webhook on a simulated AdmissionReview.
+type simulationResult struct {
+	UID       types.UID             `json:"uid"`
+	Kind      string                `json:"kind"`
+	Operation admissionv1.Operation `json:"operation"`
+	Namespace string                `json:"namespace,omitempty"`
+	Name      string                `json:"name,omitempty"`
+	// Handled is false if no enabled handler is registered for the resource and operation of the request, which is
+	// then allowed.
+	Handled bool `json:"handled"`
+	// Mutated is true if a mutating handler patched the object before it was validated.
+	Mutated  bool     `json:"mutated,omitempty"`
+	Allowed  bool     `json:"allowed"`
+	Code     int32    `json:"code,omitempty"`
+	Message  string   `json:"message,omitempty"`
+	Warnings []string `json:"warnings,omitempty"`
+}
+
+// simulator runs AdmissionReviews through the registered handlers, as the Kubernetes API server would send them to the
+// webhook, and reports their verdicts.
+type simulator struct {
+	validators []admission.ValidatingAdmissionHandler
+	mutators   []admission.MutatingAdmissionHandler
+	authorizer *requestAuthorizer
+}
+
+// handler returns the handler of the simulate endpoint. The body holds AdmissionReviews as a stream of JSON objects or
+// as a multi-document YAML, and the response lists their verdicts in the same order. Callers are authenticated with
+// the bearer token of the request and must be allowed to create simulations.webhook.cattle.io.
+func (s *simulator) handler() http.Handler {
+	return s.authorizer.handler(simulationAttributes, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		decoder := utilyaml.NewYAMLOrJSONDecoder(http.MaxBytesReader(w, r.Body, maxSimulationBytes), 4096)
+		var results []simulationResult
+		for i := 0; ; i++ {
+			review := &admissionv1.AdmissionReview{}
+			err := decoder.Decode(review)
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			if err != nil {
+				http.Error(w, fmt.Sprintf("failed to decode AdmissionReview %d: %v", i, err), http.StatusBadRequest)
+				return
+			}
+			if review.Request == nil {
+				http.Error(w, fmt.Sprintf("AdmissionReview %d has no request", i), http.StatusBadRequest)
+				return
+			}
+			result, err := s.simulate(r, review.Request)
+			if err != nil {
+				http.Error(w, fmt.Sprintf("failed to simulate AdmissionReview %d: %v", i, err), http.StatusInternalServerError)
+				return
+			}
+			results = append(results, result)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(results)
+	}))
+}
+
+// simulate runs the request through the enabled mutating handlers of its resource and operation, then through the
+// validating handlers with the patched object, stopping at the first denial. The request is always a dry run, so that
+// handlers with side effects skip them.
+func (s *simulator) simulate(r *http.Request, request *admissionv1.AdmissionRequest) (simulationResult, error) {
+	request.DryRun = admission.Ptr(true)
+	result := simulationResult{
+		UID:       request.UID,
+		Kind:      request.Kind.Kind,
+		Operation: request.Operation,
+		Namespace: request.Namespace,
+		Name:      request.Name,
+		Allowed:   true,
+	}
+	gvr := schema.GroupVersionResource{Group: request.Resource.Group, Version: request.Resource.Version, Resource: request.Resource.Resource}
+
+	for _, mutator := range s.mutators {
+		if !handles(mutator, gvr, request.Operation) {
+			continue
+		}
+		result.Handled = true
+		response, err := review(r, admission.NewMutatingHandlerFunc(mutator), request)
+		if err != nil {
+			return result, err
+		}
+		if !result.record(response) {
+			return result, nil
+		}
+		if len(response.Patch) == 0 {
+			continue
+		}
+		patch, err := jsonpatch.DecodePatch(response.Patch)
+		if err != nil {
+			return result, fmt.Errorf("failed to decode patch of %s: %w", admission.Path(mutationPath, mutator), err)
+		}
+		patched, err := patch.Apply(request.Object.Raw)
+		if err != nil {
+			return result, fmt.Errorf("failed to apply patch of %s: %w", admission.Path(mutationPath, mutator), err)
+		}
+		request.Object.Raw = patched
+		result.Mutated = true
+	}
+	for _, validator := range s.validators {
+		if !handles(validator, gvr, request.Operation) {
+			continue
+		}
+		result.Handled = true
+		response, err := review(r, admission.NewValidatingHandlerFunc(validator), request)
+		if err != nil {
+			return result, err
+		}
+		if !result.record(response) {
+			return result, nil
+		}
+	}
+	return result, nil
+}
+
+// record adds the response to the result and returns false if it denied the request.
+func (r *simulationResult) record(response *admissionv1.AdmissionResponse) bool {
+	r.Warnings = append(r.Warnings, response.Warnings...)
+	if response.Allowed {
+		return true
+	}
+	r.Allowed = false
+	if response.Result != nil {
+		r.Code = response.Result.Code
+		r.Message = response.Result.Message
+	}
+	return false
+}
+
+// handles returns true if the handler is enabled and registered for the resource and operation.
+func handles(handler admission.WebhookHandler, gvr schema.GroupVersionResource, operation admissionv1.Operation) bool {
+	if handler.GVR() != gvr || !isEnabled(handler) {
+		return false
+	}
+	for _, op := range handler.Operations() {
+		if string(op) == string(operation) || op == "*" {
+			return true
+		}
+	}
+	return false
+}
+
+// review sends the request to the handler function served on the handler's path, like the requests of the API server,
+// and returns its response.
+func review(r *http.Request, handlerFunc http.HandlerFunc, request *admissionv1.AdmissionRequest) (*admissionv1.AdmissionResponse, error) {
+	body, err := json.Marshal(&admissionv1.AdmissionReview{
+		TypeMeta: metav1.TypeMeta{APIVersion: admissionv1.SchemeGroupVersion.String(), Kind: "AdmissionReview"},
+		Request:  request,
+	})
+	if err != nil {
+		return nil, err
+	}
+	recorder := httptest.NewRecorder()
+	handlerFunc(recorder, httptest.NewRequest(http.MethodPost, r.URL.Path, bytes.NewReader(body)).WithContext(r.Context()))
+	response := &admissionv1.AdmissionReview{}
+	if err := json.NewDecoder(recorder.Body).Decode(response); err != nil || response.Response == nil {
+		return nil, fmt.Errorf("handler responded with status %d and no AdmissionReview", recorder.Code)
+	}
+	return response.Response, nil
+}
@@ -0,0 +1,223 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/rancher/webhook/pkg/admission"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	admissionv1 "k8s.io/api/admission/v1"
+	admissionregistrationv1 "k8s.io/api/admissionregistration/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+var configMapsGVR = schema.GroupVersionResource{Version: "v1", Resource: "configmaps"}
+
+// labelingMutator labels the config maps of dry-run requests.
+type labelingMutator struct{}
+
+func (l *labelingMutator) GVR() schema.GroupVersionResource { return configMapsGVR }
+
+func (l *labelingMutator) Operations() []admissionregistrationv1.OperationType {
+	return []admissionregistrationv1.OperationType{admissionregistrationv1.Create}
+}
+
+func (l *labelingMutator) MutatingWebhook(clientConfig admissionregistrationv1.WebhookClientConfig) []admissionregistrationv1.MutatingWebhook {
+	return []admissionregistrationv1.MutatingWebhook{*admission.NewDefaultMutatingWebhook(l, clientConfig, admissionregistrationv1.NamespacedScope, l.Operations())}
+}
+
+func (l *labelingMutator) Admit(request *admission.Request) (*admissionv1.AdmissionResponse, error) {
+	if request.DryRun == nil || !*request.DryRun {
+		return nil, errors.New("not a dry run")
+	}
+	response := admission.ResponseAllowed()
+	response.Patch = []byte(`[{"op":"add","path":"/metadata/labels","value":{"mutated":"true"}}]`)
+	response.PatchType = admission.Ptr(admissionv1.PatchTypeJSONPatch)
+	return response, nil
+}
+
+// labelValidator denies the config maps which weren't labeled by the labelingMutator, and those named denied.
+type labelValidator struct{}
+
+func (l *labelValidator) GVR() schema.GroupVersionResource { return configMapsGVR }
+
+func (l *labelValidator) Operations() []admissionregistrationv1.OperationType {
+	return []admissionregistrationv1.OperationType{admissionregistrationv1.Create, admissionregistrationv1.Update}
+}
+
+func (l *labelValidator) ValidatingWebhook(clientConfig admissionregistrationv1.WebhookClientConfig) []admissionregistrationv1.ValidatingWebhook {
+	return []admissionregistrationv1.ValidatingWebhook{*admission.NewDefaultValidatingWebhook(l, clientConfig, admissionregistrationv1.NamespacedScope, l.Operations())}
+}
+
+func (l *labelValidator) Admitters() []admission.Admitter {
+	return []admission.Admitter{l}
+}
+
+func (l *labelValidator) Admit(request *admission.Request) (*admissionv1.AdmissionResponse, error) {
+	obj := &metav1.PartialObjectMetadata{}
+	if err := json.Unmarshal(request.Object.Raw, obj); err != nil {
+		return nil, err
+	}
+	if obj.Name == "denied" || obj.Labels["mutated"] != "true" {
+		return admission.ResponseBadRequest("denied " + obj.Name), nil
+	}
+	return admission.ResponseAllowedWithWarnings("checked " + obj.Name), nil
+}
+
+func newSimulationReview(uid, kind, resource, group string, operation admissionv1.Operation, name string) string {
+	review := admissionv1.AdmissionReview{
+		TypeMeta: metav1.TypeMeta{APIVersion: "admission.k8s.io/v1", Kind: "AdmissionReview"},
+		Request: &admissionv1.AdmissionRequest{
+			UID:       types.UID("uid-" + uid),
+			Kind:      metav1.GroupVersionKind{Group: group, Version: "v1", Kind: kind},
+			Resource:  metav1.GroupVersionResource{Group: group, Version: "v1", Resource: resource},
+			Operation: operation,
+			Name:      name,
+			Object:    runtime.RawExtension{Raw: []byte(`{"metadata":{"name":"` + name + `"}}`)},
+		},
+	}
+	data, _ := json.Marshal(review)
+	return string(data)
+}
+
+func newFakeSimulator() *simulator {
+	return &simulator{
+		validators: append([]admission.ValidatingAdmissionHandler{&labelValidator{}},
+			toggleValidators([]admission.ValidatingAdmissionHandler{&denyingHandler{}}, func() bool { return false })...),
+		mutators:   []admission.MutatingAdmissionHandler{&labelingMutator{}},
+		authorizer: newFakeAuthorizer(),
+	}
+}
+
+func TestSimulate(t *testing.T) {
+	t.Parallel()
+
+	body := strings.Join([]string{
+		newSimulationReview("1", "ConfigMap", "configmaps", "", admissionv1.Create, "allowed"),
+		newSimulationReview("2", "ConfigMap", "configmaps", "", admissionv1.Create, "denied"),
+		newSimulationReview("3", "ConfigMap", "configmaps", "", admissionv1.Update, "not-mutated"),
+		newSimulationReview("4", "NodeDriver", "nodedrivers", "management.cattle.io", admissionv1.Create, "disabled"),
+		newSimulationReview("5", "Secret", "secrets", "", admissionv1.Create, "unhandled"),
+	}, "\n")
+	request := httptest.NewRequest(http.MethodPost, simulatePath, strings.NewReader(body))
+	request.Header.Set("Authorization", "Bearer qa-token")
+	recorder := httptest.NewRecorder()
+	newFakeSimulator().handler().ServeHTTP(recorder, request)
+	require.Equal(t, http.StatusOK, recorder.Code, recorder.Body.String())
+
+	var results []simulationResult
+	require.NoError(t, json.NewDecoder(recorder.Body).Decode(&results))
+	assert.Equal(t, []simulationResult{
+		{UID: "uid-1", Kind: "ConfigMap", Operation: admissionv1.Create, Name: "allowed", Handled: true, Mutated: true, Allowed: true, Warnings: []string{"checked allowed"}},
+		{UID: "uid-2", Kind: "ConfigMap", Operation: admissionv1.Create, Name: "denied", Handled: true, Mutated: true, Code: http.StatusBadRequest, Message: "denied denied"},
+		{UID: "uid-3", Kind: "ConfigMap", Operation: admissionv1.Update, Name: "not-mutated", Handled: true, Code: http.StatusBadRequest, Message: "denied not-mutated"},
+		{UID: "uid-4", Kind: "NodeDriver", Operation: admissionv1.Create, Name: "disabled", Allowed: true},
+		{UID: "uid-5", Kind: "Secret", Operation: admissionv1.Create, Name: "unhandled", Allowed: true},
+	}, results)
+}
+
+func TestSimulateYAML(t *testing.T) {
+	t.Parallel()
+
+	body := `apiVersion: admission.k8s.io/v1
+kind: AdmissionReview
+request:
+  uid: uid-1
+  kind: {version: v1, kind: ConfigMap}
+  resource: {version: v1, resource: configmaps}
+  operation: CREATE
+  name: first
+  object: {metadata: {name: first}}
+---
+apiVersion: admission.k8s.io/v1
+kind: AdmissionReview
+request:
+  uid: uid-2
+  kind: {version: v1, kind: ConfigMap}
+  resource: {version: v1, resource: configmaps}
+  operation: CREATE
+  name: denied
+  object: {metadata: {name: denied}}
+`
+	request := httptest.NewRequest(http.MethodPost, simulatePath, strings.NewReader(body))
+	request.Header.Set("Authorization", "Bearer qa-token")
+	recorder := httptest.NewRecorder()
+	newFakeSimulator().handler().ServeHTTP(recorder, request)
+	require.Equal(t, http.StatusOK, recorder.Code, recorder.Body.String())
+
+	var results []simulationResult
+	require.NoError(t, json.NewDecoder(recorder.Body).Decode(&results))
+	require.Len(t, results, 2)
+	assert.True(t, results[0].Allowed)
+	assert.False(t, results[1].Allowed)
+}
+
+func TestSimulateErrors(t *testing.T) {
+	t.Parallel()
+
+	review := newSimulationReview("1", "ConfigMap", "configmaps", "", admissionv1.Create, "allowed")
+	tests := []struct {
+		name          string
+		authorization string
+		body          string
+		wantCode      int
+		wantInBody    string
+	}{
+		{
+			name:       "no token",
+			body:       review,
+			wantCode:   http.StatusUnauthorized,
+			wantInBody: "a bearer token is required",
+		},
+		{
+			name:          "invalid token",
+			authorization: "Bearer unknown",
+			body:          review,
+			wantCode:      http.StatusUnauthorized,
+			wantInBody:    "invalid bearer token",
+		},
+		{
+			name:          "not allowed",
+			authorization: "Bearer dev-token",
+			body:          review,
+			wantCode:      http.StatusForbidden,
+			wantInBody:    "user dev can't create simulations.webhook.cattle.io",
+		},
+		{
+			name:          "review without request",
+			authorization: "Bearer qa-token",
+			body:          review + `{"apiVersion":"admission.k8s.io/v1","kind":"AdmissionReview"}`,
+			wantCode:      http.StatusBadRequest,
+			wantInBody:    "AdmissionReview 1 has no request",
+		},
+		{
+			name:          "invalid review",
+			authorization: "Bearer qa-token",
+			body:          `{"request": []}`,
+			wantCode:      http.StatusBadRequest,
+			wantInBody:    "failed to decode AdmissionReview 0",
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			request := httptest.NewRequest(http.MethodPost, simulatePath, strings.NewReader(tt.body))
+			if tt.authorization != "" {
+				request.Header.Set("Authorization", tt.authorization)
+			}
+			recorder := httptest.NewRecorder()
+			newFakeSimulator().handler().ServeHTTP(recorder, request)
+			assert.Equal(t, tt.wantCode, recorder.Code)
+			assert.Contains(t, recorder.Body.String(), tt.wantInBody)
+		})
+	}
+}
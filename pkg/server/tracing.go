@@ -0,0 +1,68 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+
+	"github.com/sirupsen/logrus"
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracegrpc"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+	"k8s.io/client-go/rest"
+)
+
+const (
+	// otlpEndpointEnv and otlpTracesEndpointEnv are the standard OpenTelemetry environment variables configuring where
+	// traces are exported. Tracing is only enabled if one of them is set.
+	otlpEndpointEnv       = "OTEL_EXPORTER_OTLP_ENDPOINT"
+	otlpTracesEndpointEnv = "OTEL_EXPORTER_OTLP_TRACES_ENDPOINT"
+)
+
+// tracingEnabled returns true if an OTLP endpoint is configured for traces.
+func tracingEnabled() bool {
+	return os.Getenv(otlpEndpointEnv) != "" || os.Getenv(otlpTracesEndpointEnv) != ""
+}
+
+// setupTracing installs a global tracer provider exporting the spans of admission requests over OTLP gRPC, configured
+// with the standard OTEL_EXPORTER_OTLP_* environment variables. Calls made to the API server while handling a request
+// are recorded as child spans of the request through the transport of cfg. The provider is flushed and shut down once
+// ctx is done. Nothing is done if no OTLP endpoint is configured.
+func setupTracing(ctx context.Context, cfg *rest.Config) error {
+	if !tracingEnabled() {
+		return nil
+	}
+	exporter, err := otlptracegrpc.New(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to create OTLP trace exporter: %w", err)
+	}
+	res, err := resource.Merge(resource.Default(), resource.NewSchemaless(attribute.String("service.name", serviceName)))
+	if err != nil {
+		return fmt.Errorf("failed to create trace resource: %w", err)
+	}
+	provider := sdktrace.NewTracerProvider(sdktrace.WithBatcher(exporter), sdktrace.WithResource(res))
+	otel.SetTracerProvider(provider)
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+
+	cfg.Wrap(func(rt http.RoundTripper) http.RoundTripper {
+		// only calls made on behalf of an admission request are traced, not those of the informers
+		return otelhttp.NewTransport(rt, otelhttp.WithFilter(func(req *http.Request) bool {
+			return trace.SpanContextFromContext(req.Context()).IsValid()
+		}))
+	})
+
+	go func() {
+		<-ctx.Done()
+		if err := provider.Shutdown(context.Background()); err != nil {
+			logrus.Warnf("[setupTracing] failed to shut down tracer provider: %v", err)
+		}
+	}()
+	logrus.Info("[setupTracing] exporting traces of admission requests over OTLP")
+	return nil
+}
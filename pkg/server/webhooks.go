@@ -0,0 +1,112 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/rancher/webhook/pkg/admission"
+	"github.com/rancher/webhook/pkg/metrics"
+	v1 "k8s.io/api/admissionregistration/v1"
+	authorizationv1 "k8s.io/api/authorization/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const webhooksPath = "/v1/webhooks"
+
+// webhooksAttributes are the attributes of the SubjectAccessReview callers of the webhooks endpoint must be allowed, on
+// a resource which only exists for authorization.
+var webhooksAttributes = authorizationv1.ResourceAttributes{
+	Verb:     "get",
+	Group:    "webhook.cattle.io",
+	Resource: "webhooks",
+}
+
+// registeredHandler describes a handler registered in the webhook server, as listed by the webhooks endpoint.
+type registeredHandler struct {
+	// Type is either validating or mutating.
+	Type       string             `json:"type"`
+	Group      string             `json:"group"`
+	Version    string             `json:"version"`
+	Resource   string             `json:"resource"`
+	Path       string             `json:"path"`
+	Operations []v1.OperationType `json:"operations"`
+	// Enabled is false if the handler is currently disabled, such as the handlers which depend on multi-cluster
+	// management while it's disabled. The webhooks of disabled handlers aren't in the webhook configurations.
+	Enabled  bool                `json:"enabled"`
+	Webhooks []registeredWebhook `json:"webhooks"`
+}
+
+// registeredWebhook describes a webhook of a handler, as it is set in the webhook configurations.
+type registeredWebhook struct {
+	Name               string                     `json:"name"`
+	Rules              []v1.RuleWithOperations    `json:"rules"`
+	FailurePolicy      *v1.FailurePolicyType      `json:"failurePolicy,omitempty"`
+	MatchPolicy        *v1.MatchPolicyType        `json:"matchPolicy,omitempty"`
+	NamespaceSelector  *metav1.LabelSelector      `json:"namespaceSelector,omitempty"`
+	ObjectSelector     *metav1.LabelSelector      `json:"objectSelector,omitempty"`
+	MatchConditions    []v1.MatchCondition        `json:"matchConditions,omitempty"`
+	TimeoutSeconds     *int32                     `json:"timeoutSeconds,omitempty"`
+	ReinvocationPolicy *v1.ReinvocationPolicyType `json:"reinvocationPolicy,omitempty"`
+}
+
+// webhooksHandler serves the handlers registered in the webhook server and the rules of their webhooks, which are
+// built like the webhook configurations, to diagnose which requests are sent to the webhook.
+func (s *secretHandler) webhooksHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(s.registeredHandlers())
+	})
+}
+
+// registeredHandlers returns the registered validators followed by the registered mutators.
+func (s *secretHandler) registeredHandlers() []registeredHandler {
+	validationClientConfig, mutationClientConfig := clientConfigs(nil)
+	handlers := make([]registeredHandler, 0, len(s.validators)+len(s.mutators))
+	for _, validator := range s.validators {
+		handler := newRegisteredHandler(metrics.WebhookTypeValidating, validationPath, validator)
+		for _, webhook := range s.validatingWebhooks(validator, validationClientConfig) {
+			handler.Webhooks = append(handler.Webhooks, registeredWebhook{
+				Name:              webhook.Name,
+				Rules:             webhook.Rules,
+				FailurePolicy:     webhook.FailurePolicy,
+				MatchPolicy:       webhook.MatchPolicy,
+				NamespaceSelector: webhook.NamespaceSelector,
+				ObjectSelector:    webhook.ObjectSelector,
+				MatchConditions:   webhook.MatchConditions,
+				TimeoutSeconds:    webhook.TimeoutSeconds,
+			})
+		}
+		handlers = append(handlers, handler)
+	}
+	for _, mutator := range s.mutators {
+		handler := newRegisteredHandler(metrics.WebhookTypeMutating, mutationPath, mutator)
+		for _, webhook := range s.mutatingWebhooks(mutator, mutationClientConfig) {
+			handler.Webhooks = append(handler.Webhooks, registeredWebhook{
+				Name:               webhook.Name,
+				Rules:              webhook.Rules,
+				FailurePolicy:      webhook.FailurePolicy,
+				MatchPolicy:        webhook.MatchPolicy,
+				NamespaceSelector:  webhook.NamespaceSelector,
+				ObjectSelector:     webhook.ObjectSelector,
+				MatchConditions:    webhook.MatchConditions,
+				TimeoutSeconds:     webhook.TimeoutSeconds,
+				ReinvocationPolicy: webhook.ReinvocationPolicy,
+			})
+		}
+		handlers = append(handlers, handler)
+	}
+	return handlers
+}
+
+func newRegisteredHandler(webhookType, basePath string, handler admission.WebhookHandler) registeredHandler {
+	gvr := handler.GVR()
+	return registeredHandler{
+		Type:       webhookType,
+		Group:      gvr.Group,
+		Version:    gvr.Version,
+		Resource:   gvr.Resource,
+		Path:       admission.Path(basePath, handler),
+		Operations: handler.Operations(),
+		Enabled:    isEnabled(handler),
+	}
+}
@@ -0,0 +1,53 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/rancher/webhook/pkg/admission"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	admissionregistrationv1 "k8s.io/api/admissionregistration/v1"
+)
+
+func TestWebhooksHandler(t *testing.T) {
+	t.Parallel()
+	overrides := newWebhookOverrides(func() {})
+	overrides.load(map[string]string{"management.cattle.io": "failurePolicy: Ignore"})
+	handler := &secretHandler{
+		validators: append([]admission.ValidatingAdmissionHandler{&denyingHandler{}},
+			toggleValidators([]admission.ValidatingAdmissionHandler{&denyingHandler{}}, func() bool { return false })...),
+		mutators:  []admission.MutatingAdmissionHandler{&denyingHandler{}},
+		overrides: overrides,
+	}
+
+	recorder := httptest.NewRecorder()
+	handler.webhooksHandler().ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, webhooksPath, nil))
+	require.Equal(t, http.StatusOK, recorder.Code)
+	assert.Equal(t, "application/json", recorder.Header().Get("Content-Type"))
+	var handlers []registeredHandler
+	require.NoError(t, json.NewDecoder(recorder.Body).Decode(&handlers))
+	require.Len(t, handlers, 3)
+
+	validator := handlers[0]
+	assert.Equal(t, "validating", validator.Type)
+	assert.Equal(t, "management.cattle.io", validator.Group)
+	assert.Equal(t, "v3", validator.Version)
+	assert.Equal(t, "nodedrivers", validator.Resource)
+	assert.Equal(t, "/v1/webhook/validation/nodedrivers.management.cattle.io", validator.Path)
+	assert.Equal(t, []admissionregistrationv1.OperationType{admissionregistrationv1.Create}, validator.Operations)
+	assert.True(t, validator.Enabled)
+	require.Len(t, validator.Webhooks, 1)
+	assert.Equal(t, "rancher.cattle.io.nodedrivers.management.cattle.io", validator.Webhooks[0].Name)
+	require.Len(t, validator.Webhooks[0].Rules, 1)
+	assert.Equal(t, []string{"nodedrivers"}, validator.Webhooks[0].Rules[0].Resources)
+	assert.Equal(t, admissionregistrationv1.ClusterScope, *validator.Webhooks[0].Rules[0].Scope)
+	assert.Equal(t, admissionregistrationv1.Ignore, *validator.Webhooks[0].FailurePolicy, "the overrides are applied")
+
+	assert.False(t, handlers[1].Enabled, "disabled handlers are listed")
+	assert.Equal(t, "mutating", handlers[2].Type)
+	assert.Equal(t, "/v1/webhook/mutation/nodedrivers.management.cattle.io", handlers[2].Path)
+	assert.True(t, handlers[2].Enabled)
+}
@@ -0,0 +1,213 @@
+// Package simulation computes what changes to RBAC objects would allow before they are applied.
+package simulation
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+
+	v3 "github.com/rancher/rancher/pkg/apis/management.cattle.io/v3"
+	"github.com/rancher/webhook/pkg/auth"
+	controllerv3 "github.com/rancher/webhook/pkg/generated/controllers/management.cattle.io/v3"
+	"github.com/sirupsen/logrus"
+	rbacv1 "k8s.io/api/rbac/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apiserver/pkg/authentication/user"
+	rbacvalidation "k8s.io/component-helpers/auth/rbac/validation"
+	"k8s.io/kubernetes/pkg/registry/rbac/validation"
+)
+
+const (
+	// Group is the API group of the simulations.
+	Group = "webhook.cattle.io"
+	// Version is the API version of the simulations.
+	Version = "v1"
+	// RoleTemplateSimulationKind is the kind of RoleTemplate simulations.
+	RoleTemplateSimulationKind = "RoleTemplateSimulation"
+	// RoleTemplateSimulationPath is the path RoleTemplate simulations are posted to. It follows the layout of an
+	// aggregated API, so that the endpoint can be registered with an APIService.
+	RoleTemplateSimulationPath = "/apis/" + Group + "/" + Version + "/roletemplatesimulations"
+)
+
+// RoleTemplateSimulation is posted to the RoleTemplate simulation endpoint with a spec, and returned with a status
+// holding the outcome of the simulation. Like a SubjectAccessReview, it is never persisted.
+type RoleTemplateSimulation struct {
+	metav1.TypeMeta `json:",inline"`
+	Spec            RoleTemplateSimulationSpec   `json:"spec"`
+	Status          RoleTemplateSimulationStatus `json:"status,omitempty"`
+}
+
+// RoleTemplateSimulationSpec is the RoleTemplate whose change is simulated, and optionally the subject whose access
+// is simulated.
+type RoleTemplateSimulationSpec struct {
+	// RoleTemplate is the RoleTemplate as it would be applied. It is compared to the existing RoleTemplate of the same
+	// name, if any.
+	RoleTemplate v3.RoleTemplate `json:"roleTemplate"`
+	// User and Groups are the subject whose access in Namespace is simulated.
+	User   string   `json:"user,omitempty"`
+	Groups []string `json:"groups,omitempty"`
+	// Namespace is the namespace of a cluster or of a project in which the subject's access is simulated.
+	Namespace string `json:"namespace,omitempty"`
+}
+
+// RoleTemplateSimulationStatus is the outcome of a RoleTemplate simulation.
+type RoleTemplateSimulationStatus struct {
+	// Added are the rules the RoleTemplate would grant which it doesn't grant yet, including the rules of the
+	// RoleTemplates it inherits.
+	Added []rbacv1.PolicyRule `json:"added,omitempty"`
+	// Removed are the rules the RoleTemplate grants which it would no longer grant.
+	Removed []rbacv1.PolicyRule `json:"removed,omitempty"`
+	// Bindings are the namespaced names of the ClusterRoleTemplateBindings and ProjectRoleTemplateBindings binding the
+	// subject to the RoleTemplate in the namespace.
+	Bindings []string `json:"bindings,omitempty"`
+	// Gained are the added rules the subject would gain through the bindings, which it doesn't already hold in the
+	// namespace.
+	Gained []rbacv1.PolicyRule `json:"gained,omitempty"`
+}
+
+// Simulator simulates changes to RoleTemplates.
+type Simulator struct {
+	roleTemplateResolver *auth.RoleTemplateResolver
+	ruleResolver         validation.AuthorizationRuleResolver
+	crtbCache            controllerv3.ClusterRoleTemplateBindingCache
+	prtbCache            controllerv3.ProjectRoleTemplateBindingCache
+}
+
+// NewSimulator returns a new Simulator. The ruleResolver resolves the rules the subjects hold in the namespaces of
+// clusters and projects.
+func NewSimulator(roleTemplateResolver *auth.RoleTemplateResolver, ruleResolver validation.AuthorizationRuleResolver,
+	crtbCache controllerv3.ClusterRoleTemplateBindingCache, prtbCache controllerv3.ProjectRoleTemplateBindingCache) *Simulator {
+	return &Simulator{
+		roleTemplateResolver: roleTemplateResolver,
+		ruleResolver:         ruleResolver,
+		crtbCache:            crtbCache,
+		prtbCache:            prtbCache,
+	}
+}
+
+// SimulateRoleTemplate sets the status of the simulation.
+func (s *Simulator) SimulateRoleTemplate(simulation *RoleTemplateSimulation) error {
+	spec := simulation.Spec
+	if spec.RoleTemplate.Name == "" {
+		return fieldError("spec.roleTemplate.metadata.name is required")
+	}
+	hasSubject := spec.User != "" || len(spec.Groups) > 0
+	if hasSubject && spec.Namespace == "" {
+		return fieldError("spec.namespace is required when a subject is set")
+	}
+
+	var current []rbacv1.PolicyRule
+	_, err := s.roleTemplateResolver.RoleTemplateCache().Get(spec.RoleTemplate.Name)
+	switch {
+	case err == nil:
+		current, err = s.roleTemplateResolver.RulesFromTemplateName(spec.RoleTemplate.Name)
+		if err != nil {
+			return err
+		}
+	case !apierrors.IsNotFound(err):
+		return fmt.Errorf("failed to get RoleTemplate %s: %w", spec.RoleTemplate.Name, err)
+	}
+	proposed, err := s.roleTemplateResolver.RulesFromTemplate(&spec.RoleTemplate)
+	if err != nil {
+		return err
+	}
+	status := RoleTemplateSimulationStatus{
+		Added:   uncovered(current, proposed),
+		Removed: uncovered(proposed, current),
+	}
+
+	if hasSubject {
+		status.Bindings, err = s.bindings(spec)
+		if err != nil {
+			return err
+		}
+		if len(status.Bindings) > 0 && len(status.Added) > 0 {
+			held, err := s.ruleResolver.RulesFor(&user.DefaultInfo{Name: spec.User, Groups: spec.Groups}, spec.Namespace)
+			if err != nil {
+				// the rules are additive, the rules which were resolved are still held
+				logrus.Debugf("[roleTemplateSimulation] failed to resolve all rules of the subject: %v", err)
+			}
+			status.Gained = uncovered(held, status.Added)
+		}
+	}
+	simulation.Status = status
+	return nil
+}
+
+// uncovered returns the rules which are not covered by the owner rules, or nil if all of them are.
+func uncovered(ownerRules, rules []rbacv1.PolicyRule) []rbacv1.PolicyRule {
+	if covered, missing := rbacvalidation.Covers(ownerRules, rules); !covered {
+		return missing
+	}
+	return nil
+}
+
+// bindings returns the namespaced names of the bindings of the subject of the spec to its RoleTemplate in its namespace.
+func (s *Simulator) bindings(spec RoleTemplateSimulationSpec) ([]string, error) {
+	isSubject := func(userName, groupName, groupPrincipalName string) bool {
+		if spec.User != "" && userName == spec.User {
+			return true
+		}
+		for _, group := range spec.Groups {
+			if group != "" && (groupName == group || groupPrincipalName == group) {
+				return true
+			}
+		}
+		return false
+	}
+
+	var bindings []string
+	crtbs, err := s.crtbCache.List(spec.Namespace, labels.Everything())
+	if err != nil {
+		return nil, fmt.Errorf("failed to list ClusterRoleTemplateBindings: %w", err)
+	}
+	for _, crtb := range crtbs {
+		if crtb.RoleTemplateName == spec.RoleTemplate.Name && isSubject(crtb.UserName, crtb.GroupName, crtb.GroupPrincipalName) {
+			bindings = append(bindings, crtb.Namespace+"/"+crtb.Name)
+		}
+	}
+	prtbs, err := s.prtbCache.List(spec.Namespace, labels.Everything())
+	if err != nil {
+		return nil, fmt.Errorf("failed to list ProjectRoleTemplateBindings: %w", err)
+	}
+	for _, prtb := range prtbs {
+		if prtb.RoleTemplateName == spec.RoleTemplate.Name && isSubject(prtb.UserName, prtb.GroupName, prtb.GroupPrincipalName) {
+			bindings = append(bindings, prtb.Namespace+"/"+prtb.Name)
+		}
+	}
+	return bindings, nil
+}
+
+// Handler returns the handler of the RoleTemplate simulation endpoint. It decodes a RoleTemplateSimulation from the
+// body of the request and responds with the simulation and its status.
+func (s *Simulator) Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		simulation := &RoleTemplateSimulation{}
+		if err := json.NewDecoder(r.Body).Decode(simulation); err != nil {
+			http.Error(w, fmt.Sprintf("failed to decode %s: %v", RoleTemplateSimulationKind, err), http.StatusBadRequest)
+			return
+		}
+		if err := s.SimulateRoleTemplate(simulation); err != nil {
+			code := http.StatusInternalServerError
+			if errors.As(err, new(fieldError)) {
+				code = http.StatusBadRequest
+			}
+			http.Error(w, err.Error(), code)
+			return
+		}
+		simulation.APIVersion = Group + "/" + Version
+		simulation.Kind = RoleTemplateSimulationKind
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(simulation)
+	})
+}
+
+// fieldError is an error of the spec of a simulation.
+type fieldError string
+
+func (e fieldError) Error() string {
+	return string(e)
+}
@@ -0,0 +1,180 @@
+package simulation
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	v3 "github.com/rancher/rancher/pkg/apis/management.cattle.io/v3"
+	"github.com/rancher/webhook/pkg/auth"
+	"github.com/rancher/wrangler/v3/pkg/generic/fake"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+	rbacv1 "k8s.io/api/rbac/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/kubernetes/pkg/registry/rbac/validation"
+)
+
+var (
+	getPods  = rbacv1.PolicyRule{APIGroups: []string{""}, Resources: []string{"pods"}, Verbs: []string{"get"}}
+	listPods = rbacv1.PolicyRule{APIGroups: []string{""}, Resources: []string{"pods"}, Verbs: []string{"list"}}
+	getNodes = rbacv1.PolicyRule{APIGroups: []string{""}, Resources: []string{"nodes"}, Verbs: []string{"get"}}
+)
+
+func TestSimulateRoleTemplate(t *testing.T) {
+	t.Parallel()
+
+	roleTemplate := func(name string, rules ...rbacv1.PolicyRule) v3.RoleTemplate {
+		return v3.RoleTemplate{ObjectMeta: metav1.ObjectMeta{Name: name}, Context: "cluster", Rules: rules}
+	}
+	tests := []struct {
+		name     string
+		spec     RoleTemplateSimulationSpec
+		want     RoleTemplateSimulationStatus
+		wantErr  bool
+		errorMsg string
+	}{
+		{
+			name: "new RoleTemplate",
+			spec: RoleTemplateSimulationSpec{RoleTemplate: roleTemplate("rt-new", getPods)},
+			want: RoleTemplateSimulationStatus{Added: []rbacv1.PolicyRule{getPods}},
+		},
+		{
+			name: "added and removed rules",
+			spec: RoleTemplateSimulationSpec{RoleTemplate: roleTemplate("rt", listPods, getNodes)},
+			want: RoleTemplateSimulationStatus{
+				Added:   []rbacv1.PolicyRule{listPods, getNodes},
+				Removed: []rbacv1.PolicyRule{getPods},
+			},
+		},
+		{
+			name: "rules of the subject's bindings",
+			spec: RoleTemplateSimulationSpec{
+				RoleTemplate: roleTemplate("rt", getPods, listPods, getNodes),
+				User:         "u-bound",
+				Namespace:    "c-1",
+			},
+			want: RoleTemplateSimulationStatus{
+				Added:    []rbacv1.PolicyRule{listPods, getNodes},
+				Bindings: []string{"c-1/crtb-1"},
+				// the user can already list pods through another binding
+				Gained: []rbacv1.PolicyRule{getNodes},
+			},
+		},
+		{
+			name: "group of the subject",
+			spec: RoleTemplateSimulationSpec{
+				RoleTemplate: roleTemplate("rt", getPods, getNodes),
+				Groups:       []string{"local://g-1"},
+				Namespace:    "p-1",
+			},
+			want: RoleTemplateSimulationStatus{
+				Added:    []rbacv1.PolicyRule{getNodes},
+				Bindings: []string{"p-1/prtb-1"},
+				Gained:   []rbacv1.PolicyRule{getNodes},
+			},
+		},
+		{
+			name: "subject without bindings",
+			spec: RoleTemplateSimulationSpec{
+				RoleTemplate: roleTemplate("rt", getPods, getNodes),
+				User:         "u-other",
+				Namespace:    "c-1",
+			},
+			want: RoleTemplateSimulationStatus{Added: []rbacv1.PolicyRule{getNodes}},
+		},
+		{
+			name:    "subject without namespace",
+			spec:    RoleTemplateSimulationSpec{RoleTemplate: roleTemplate("rt", getPods), User: "u-bound"},
+			wantErr: true,
+		},
+		{
+			name:    "missing name",
+			spec:    RoleTemplateSimulationSpec{RoleTemplate: roleTemplate("", getPods)},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			simulation := &RoleTemplateSimulation{Spec: tt.spec}
+			err := newSimulator(t).SimulateRoleTemplate(simulation)
+			if tt.wantErr {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, simulation.Status)
+		})
+	}
+}
+
+func TestHandler(t *testing.T) {
+	t.Parallel()
+	handler := newSimulator(t).Handler()
+
+	body, err := json.Marshal(RoleTemplateSimulation{Spec: RoleTemplateSimulationSpec{
+		RoleTemplate: v3.RoleTemplate{ObjectMeta: metav1.ObjectMeta{Name: "rt"}, Context: "cluster", Rules: []rbacv1.PolicyRule{getPods, getNodes}},
+	}})
+	require.NoError(t, err)
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodPost, RoleTemplateSimulationPath, bytes.NewReader(body)))
+	require.Equal(t, http.StatusOK, recorder.Code, recorder.Body.String())
+	simulation := &RoleTemplateSimulation{}
+	require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), simulation))
+	assert.Equal(t, RoleTemplateSimulationKind, simulation.Kind)
+	assert.Equal(t, []rbacv1.PolicyRule{getNodes}, simulation.Status.Added)
+
+	recorder = httptest.NewRecorder()
+	handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodPost, RoleTemplateSimulationPath, bytes.NewReader([]byte(`{"spec":{}}`))))
+	assert.Equal(t, http.StatusBadRequest, recorder.Code)
+
+	recorder = httptest.NewRecorder()
+	handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodPost, RoleTemplateSimulationPath, bytes.NewReader([]byte(`not json`))))
+	assert.Equal(t, http.StatusBadRequest, recorder.Code)
+}
+
+// newSimulator returns a simulator with the RoleTemplate rt granting getPods, which is bound to the user u-bound in
+// the cluster c-1 and to the group local://g-1 in the project p-1. The user u-bound can list pods in c-1.
+func newSimulator(t *testing.T) *Simulator {
+	t.Helper()
+	ctrl := gomock.NewController(t)
+	roleTemplateCache := fake.NewMockNonNamespacedCacheInterface[*v3.RoleTemplate](ctrl)
+	roleTemplateCache.EXPECT().Get("rt").Return(&v3.RoleTemplate{
+		ObjectMeta: metav1.ObjectMeta{Name: "rt"},
+		Context:    "cluster",
+		Rules:      []rbacv1.PolicyRule{getPods},
+	}, nil).AnyTimes()
+	roleTemplateCache.EXPECT().Get(gomock.Any()).DoAndReturn(func(name string) (*v3.RoleTemplate, error) {
+		return nil, apierrors.NewNotFound(schema.GroupResource{Group: "management.cattle.io", Resource: "roletemplates"}, name)
+	}).AnyTimes()
+	clusterRoleCache := fake.NewMockNonNamespacedCacheInterface[*rbacv1.ClusterRole](ctrl)
+
+	crtbCache := fake.NewMockCacheInterface[*v3.ClusterRoleTemplateBinding](ctrl)
+	crtbCache.EXPECT().List("c-1", gomock.Any()).Return([]*v3.ClusterRoleTemplateBinding{
+		{ObjectMeta: metav1.ObjectMeta{Name: "crtb-1", Namespace: "c-1"}, UserName: "u-bound", RoleTemplateName: "rt"},
+		{ObjectMeta: metav1.ObjectMeta{Name: "crtb-2", Namespace: "c-1"}, UserName: "u-other", RoleTemplateName: "rt-other"},
+	}, nil).AnyTimes()
+	crtbCache.EXPECT().List(gomock.Any(), gomock.Any()).Return(nil, nil).AnyTimes()
+	prtbCache := fake.NewMockCacheInterface[*v3.ProjectRoleTemplateBinding](ctrl)
+	prtbCache.EXPECT().List("p-1", gomock.Any()).Return([]*v3.ProjectRoleTemplateBinding{
+		{ObjectMeta: metav1.ObjectMeta{Name: "prtb-1", Namespace: "p-1"}, GroupPrincipalName: "local://g-1", RoleTemplateName: "rt"},
+	}, nil).AnyTimes()
+	prtbCache.EXPECT().List(gomock.Any(), gomock.Any()).Return(nil, nil).AnyTimes()
+
+	ruleResolver, _ := validation.NewTestRuleResolver(
+		[]*rbacv1.Role{{ObjectMeta: metav1.ObjectMeta{Name: "list-pods", Namespace: "c-1"}, Rules: []rbacv1.PolicyRule{listPods}}},
+		[]*rbacv1.RoleBinding{{
+			ObjectMeta: metav1.ObjectMeta{Name: "list-pods", Namespace: "c-1"},
+			Subjects:   []rbacv1.Subject{{Kind: rbacv1.UserKind, Name: "u-bound"}},
+			RoleRef:    rbacv1.RoleRef{APIGroup: rbacv1.GroupName, Kind: "Role", Name: "list-pods"},
+		}},
+		nil, nil)
+	return NewSimulator(auth.NewRoleTemplateResolver(roleTemplateCache, clusterRoleCache), ruleResolver, crtbCache, prtbCache)
+}
@@ -0,0 +1,70 @@
+// Package tenantpolicy resolves the TenantPolicies tightening the validation of the objects of a namespace.
+package tenantpolicy
+
+import (
+	"fmt"
+	"slices"
+	"sort"
+
+	webhookv1 "github.com/rancher/webhook/pkg/apis/webhook.cattle.io/v1"
+	"github.com/rancher/wrangler/v3/pkg/generic"
+	"k8s.io/apimachinery/pkg/labels"
+)
+
+// Resolver resolves the policy applying to the objects of a namespace from the cached TenantPolicies. It is shared by
+// the admitters enforcing the policies. A nil Resolver resolves every namespace to an empty policy, which is used when
+// the TenantPolicy CRD isn't installed.
+type Resolver struct {
+	cache generic.CacheInterface[*webhookv1.TenantPolicy]
+}
+
+// NewResolver returns a Resolver reading the TenantPolicies from the cache.
+func NewResolver(cache generic.CacheInterface[*webhookv1.TenantPolicy]) *Resolver {
+	return &Resolver{cache: cache}
+}
+
+// ForNamespace returns the policy of the namespace, combining all of its TenantPolicies: a requirement set by any
+// policy applies, and only the values allowed by every policy setting an allow-list are allowed. A nil allow-list in
+// the returned policy allows everything.
+func (r *Resolver) ForNamespace(namespace string) (webhookv1.TenantPolicySpec, error) {
+	var spec webhookv1.TenantPolicySpec
+	if r == nil || namespace == "" {
+		return spec, nil
+	}
+	policies, err := r.cache.List(namespace, labels.Everything())
+	if err != nil {
+		return spec, fmt.Errorf("failed to list TenantPolicies of namespace %s: %w", namespace, err)
+	}
+	// sort the policies so that the combined allow-lists are in a stable order
+	sort.Slice(policies, func(i, j int) bool { return policies[i].Name < policies[j].Name })
+	for _, policy := range policies {
+		spec.RequirePodSecurityAdmissionConfigurationTemplate = spec.RequirePodSecurityAdmissionConfigurationTemplate ||
+			policy.Spec.RequirePodSecurityAdmissionConfigurationTemplate
+		spec.ForbidInsecureS3Endpoints = spec.ForbidInsecureS3Endpoints || policy.Spec.ForbidInsecureS3Endpoints
+		spec.AllowedPodSecurityAdmissionConfigurationTemplates = intersect(spec.AllowedPodSecurityAdmissionConfigurationTemplates,
+			policy.Spec.AllowedPodSecurityAdmissionConfigurationTemplates)
+	}
+	return spec, nil
+}
+
+// intersect returns the values allowed by both the combined allow-list and the allow-list of a policy. A nil combined
+// list and an empty policy list allow everything, while an empty non-nil combined list, which results from policies
+// without common values, allows nothing.
+func intersect(allowed, other []string) []string {
+	if len(other) == 0 {
+		return allowed
+	}
+	if allowed == nil {
+		return slices.Clone(other)
+	}
+	var result []string
+	for _, value := range allowed {
+		if slices.Contains(other, value) {
+			result = append(result, value)
+		}
+	}
+	if result == nil {
+		result = []string{}
+	}
+	return result
+}
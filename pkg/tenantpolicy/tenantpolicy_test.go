@@ -0,0 +1,105 @@
+package tenantpolicy
+
+import (
+	"errors"
+	"testing"
+
+	webhookv1 "github.com/rancher/webhook/pkg/apis/webhook.cattle.io/v1"
+	"github.com/rancher/wrangler/v3/pkg/generic/fake"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestForNamespace(t *testing.T) {
+	t.Parallel()
+
+	const namespace = "fleet-tenant"
+	policy := func(name string, spec webhookv1.TenantPolicySpec) *webhookv1.TenantPolicy {
+		return &webhookv1.TenantPolicy{ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: namespace}, Spec: spec}
+	}
+
+	tests := []struct {
+		name     string
+		policies []*webhookv1.TenantPolicy
+		want     webhookv1.TenantPolicySpec
+	}{
+		{
+			name: "no policies",
+		},
+		{
+			name: "single policy",
+			policies: []*webhookv1.TenantPolicy{
+				policy("a", webhookv1.TenantPolicySpec{
+					RequirePodSecurityAdmissionConfigurationTemplate:  true,
+					AllowedPodSecurityAdmissionConfigurationTemplates: []string{"x", "y"},
+				}),
+			},
+			want: webhookv1.TenantPolicySpec{
+				RequirePodSecurityAdmissionConfigurationTemplate:  true,
+				AllowedPodSecurityAdmissionConfigurationTemplates: []string{"x", "y"},
+			},
+		},
+		{
+			name: "requirements of any policy apply",
+			policies: []*webhookv1.TenantPolicy{
+				policy("a", webhookv1.TenantPolicySpec{RequirePodSecurityAdmissionConfigurationTemplate: true}),
+				policy("b", webhookv1.TenantPolicySpec{ForbidInsecureS3Endpoints: true}),
+			},
+			want: webhookv1.TenantPolicySpec{
+				RequirePodSecurityAdmissionConfigurationTemplate: true,
+				ForbidInsecureS3Endpoints:                        true,
+			},
+		},
+		{
+			name: "allow-lists are intersected",
+			policies: []*webhookv1.TenantPolicy{
+				policy("b", webhookv1.TenantPolicySpec{AllowedPodSecurityAdmissionConfigurationTemplates: []string{"z", "y", "x"}}),
+				policy("a", webhookv1.TenantPolicySpec{AllowedPodSecurityAdmissionConfigurationTemplates: []string{"x", "y"}}),
+				policy("c", webhookv1.TenantPolicySpec{}),
+			},
+			want: webhookv1.TenantPolicySpec{AllowedPodSecurityAdmissionConfigurationTemplates: []string{"x", "y"}},
+		},
+		{
+			name: "allow-lists without common values allow nothing",
+			policies: []*webhookv1.TenantPolicy{
+				policy("a", webhookv1.TenantPolicySpec{AllowedPodSecurityAdmissionConfigurationTemplates: []string{"x"}}),
+				policy("b", webhookv1.TenantPolicySpec{AllowedPodSecurityAdmissionConfigurationTemplates: []string{"y"}}),
+			},
+			want: webhookv1.TenantPolicySpec{AllowedPodSecurityAdmissionConfigurationTemplates: []string{}},
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			ctrl := gomock.NewController(t)
+			cache := fake.NewMockCacheInterface[*webhookv1.TenantPolicy](ctrl)
+			cache.EXPECT().List(namespace, gomock.Any()).Return(tt.policies, nil)
+
+			got, err := NewResolver(cache).ForNamespace(namespace)
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestForNamespaceWithoutPolicies(t *testing.T) {
+	t.Parallel()
+
+	var resolver *Resolver
+	got, err := resolver.ForNamespace("fleet-default")
+	require.NoError(t, err)
+	assert.Equal(t, webhookv1.TenantPolicySpec{}, got)
+
+	ctrl := gomock.NewController(t)
+	cache := fake.NewMockCacheInterface[*webhookv1.TenantPolicy](ctrl)
+	got, err = NewResolver(cache).ForNamespace("")
+	require.NoError(t, err)
+	assert.Equal(t, webhookv1.TenantPolicySpec{}, got)
+
+	cache.EXPECT().List("fleet-default", gomock.Any()).Return(nil, errors.New("unexpected"))
+	_, err = NewResolver(cache).ForNamespace("fleet-default")
+	assert.Error(t, err)
+}
@@ -0,0 +1,102 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+
+	"github.com/rancher/webhook/pkg/offline"
+	"github.com/sirupsen/logrus"
+)
+
+// defaultFixturesFile is the fixtures file read from the replayed directory when no fixtures file is given.
+const defaultFixturesFile = "fixtures.yaml"
+
+// replay runs the webhook's validators against the AdmissionReviews, as JSON files, of the given directory and prints
+// the verdict of each admitter. The caches of the validators are filled with the objects of the fixtures file.
+func replay(args []string, stdout, stderr io.Writer) int {
+	flags := flag.NewFlagSet("replay", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	flags.Usage = func() {
+		fmt.Fprintln(stderr, "Usage: webhook replay [-fixtures <fixtures.yaml>] <dir>")
+		flags.PrintDefaults()
+	}
+	fixturesFile := flags.String("fixtures", "", "path to a YAML or JSON manifest of the objects the validators read, "+
+		"defaults to "+defaultFixturesFile+" in the directory if it exists")
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	if flags.NArg() != 1 {
+		flags.Usage()
+		return 2
+	}
+	dir := flags.Arg(0)
+
+	// the validators log the details of denied requests, which would clutter the report
+	logrus.SetLevel(logrus.FatalLevel)
+
+	var fixtures [][]byte
+	fixturesPath := *fixturesFile
+	if fixturesPath == "" {
+		fixturesPath = filepath.Join(dir, defaultFixturesFile)
+	}
+	data, err := os.ReadFile(fixturesPath)
+	switch {
+	case err == nil:
+		fixtures = append(fixtures, data)
+	case *fixturesFile == "" && errors.Is(err, os.ErrNotExist):
+	default:
+		fmt.Fprintln(stderr, err)
+		return 1
+	}
+
+	files, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return 1
+	}
+	slices.Sort(files)
+	reviews := make([][]byte, 0, len(files))
+	for _, file := range files {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			fmt.Fprintln(stderr, err)
+			return 1
+		}
+		reviews = append(reviews, data)
+	}
+	results, err := offline.Replay(fixtures, reviews...)
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return 1
+	}
+
+	for i, result := range results {
+		name := result.Name
+		if result.Namespace != "" {
+			name = result.Namespace + "/" + name
+		}
+		fmt.Fprintf(stdout, "%s: %s %s %s (uid %s)\n", filepath.Base(files[i]), result.Operation, result.Kind, name, result.UID)
+		if result.Skipped {
+			fmt.Fprintln(stdout, "  SKIPPED no validator for this kind")
+		}
+		for j, verdict := range result.Verdicts {
+			switch {
+			case verdict.Err != nil:
+				fmt.Fprintf(stdout, "  admitter %d: ERROR %v\n", j, verdict.Err)
+			case verdict.Allowed:
+				fmt.Fprintf(stdout, "  admitter %d: ALLOWED\n", j)
+			default:
+				fmt.Fprintf(stdout, "  admitter %d: DENIED %d %s\n", j, verdict.Code, verdict.Message)
+			}
+			for _, warning := range verdict.Warnings {
+				fmt.Fprintf(stdout, "    warning: %s\n", warning)
+			}
+		}
+	}
+	return 0
+}
@@ -25,6 +25,8 @@ func (m *IntegrationSuite) TestRKEMachineConfig() {
 	validCreateObj.SetName("test-rke.machine")
 	validCreateObj.SetNamespace(testNamespace)
 	validCreateObj.SetGroupVersionKind(objGVK)
+	validCreateObj.Object["location"] = "westus"
+	validCreateObj.Object["size"] = "Standard_D2_v2"
 	invalidUpdate := func(_ *unstructured.Unstructured) *unstructured.Unstructured {
 		invalidUpdateObj := validCreateObj.DeepCopy()
 		invalidUpdateObj.SetAnnotations(map[string]string{common.CreatorIDAnn: "foobar"})
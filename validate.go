@@ -0,0 +1,99 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/rancher/webhook/pkg/offline"
+	"github.com/sirupsen/logrus"
+)
+
+// fileFlags is a flag which can be repeated to list several files.
+type fileFlags []string
+
+func (f *fileFlags) String() string {
+	return strings.Join(*f, ",")
+}
+
+func (f *fileFlags) Set(value string) error {
+	*f = append(*f, value)
+	return nil
+}
+
+// validate runs the webhook's validators against the objects of the given manifests without a cluster, and reports
+// the objects which were denied. It returns a non-zero exit code if an object was denied or could not be validated.
+func validate(args []string, stdout, stderr io.Writer) int {
+	flags := flag.NewFlagSet("validate", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	flags.Usage = func() {
+		fmt.Fprintln(stderr, "Usage: webhook validate -f <manifest.yaml> [-f <manifest.yaml>...]")
+		flags.PrintDefaults()
+	}
+	var files fileFlags
+	flags.Var(&files, "f", "path to a YAML or JSON manifest to validate, or - to read from stdin; can be repeated")
+	verbose := flags.Bool("v", false, "also list the objects which were allowed or skipped")
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	if len(files) == 0 || flags.NArg() != 0 {
+		flags.Usage()
+		return 2
+	}
+
+	// the validators log the details of denied requests, which would clutter the report
+	logrus.SetLevel(logrus.FatalLevel)
+
+	manifests := make([][]byte, 0, len(files))
+	for _, file := range files {
+		var data []byte
+		var err error
+		if file == "-" {
+			data, err = io.ReadAll(os.Stdin)
+		} else {
+			data, err = os.ReadFile(file)
+		}
+		if err != nil {
+			fmt.Fprintln(stderr, err)
+			return 1
+		}
+		manifests = append(manifests, data)
+	}
+	results, err := offline.Validate(manifests...)
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return 1
+	}
+
+	var denied, skipped int
+	for _, result := range results {
+		name := result.Name
+		if result.Namespace != "" {
+			name = result.Namespace + "/" + name
+		}
+		switch {
+		case result.Err != nil:
+			denied++
+			fmt.Fprintf(stdout, "ERROR %s %s: %v\n", result.Kind, name, result.Err)
+		case result.Skipped:
+			skipped++
+			if *verbose {
+				fmt.Fprintf(stdout, "SKIPPED %s %s\n", result.Kind, name)
+			}
+		case !result.Allowed:
+			denied++
+			fmt.Fprintf(stdout, "DENIED %s %s: %s\n", result.Kind, name, result.Message)
+		default:
+			if *verbose {
+				fmt.Fprintf(stdout, "ALLOWED %s %s\n", result.Kind, name)
+			}
+		}
+	}
+	fmt.Fprintf(stdout, "%d objects validated, %d denied, %d skipped\n", len(results)-skipped, denied, skipped)
+	if denied > 0 {
+		return 1
+	}
+	return 0
+}